@@ -0,0 +1,119 @@
+package evaluator
+
+import (
+    "strings"
+)
+
+// define registers a native method that takes (self, *args, **kwargs)
+func (c *Class) define(name string, fn BuiltinFunction) {
+    c.Dict.SetStr(name, &Builtin{Name: name, Fn: fn, Owner: c})
+}
+
+// defineNew registers __new__, which receives the class instead of an instance
+func (c *Class) defineNew(fn BuiltinFunction) {
+    c.Dict.SetStr("__new__", &Builtin{Name: "__new__", Fn: fn})
+}
+
+// classMethod registers a native classmethod; args[0] is the class it was called on
+func (c *Class) classMethod(name string, fn BuiltinFunction) {
+    c.Dict.SetStr(name, &ClassMethod{Function: &Builtin{Name: name, Fn: fn}, Dict: NewDict()})
+}
+
+// property registers a read-only attribute computed from self
+func (c *Class) property(name string, fn func(self Object) Object) {
+    getter := &Builtin{Name: name, Fn: func(env *Environment, args []Object, kwargs *Dict) Object {
+        return fn(args[0])
+    }}
+    c.Dict.SetStr(name, &Property{Getter: getter, Name: name})
+}
+
+// method registers a native method taking between min and max positional
+// arguments besides self, and no keywords
+func (c *Class) method(name string, min, max int, fn func(env *Environment, args []Object) Object) {
+    qualified := c.Name + "." + name
+    c.define(name, func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs(qualified, args[1:], kwargs, min, max); err != nil {
+            return err
+        }
+        return fn(env, args)
+    })
+}
+
+// checkArgs validates the argument count of a native function
+func checkArgs(name string, args []Object, kwargs *Dict, min, max int) *Error {
+    if kwargs.Len() > 0 {
+        return typeError("%s() takes no keyword arguments", name)
+    }
+    n := len(args)
+    switch {
+    case min == max && n != min:
+        switch min {
+        case 0:
+            return typeError("%s() takes no arguments (%d given)", name, n)
+        case 1:
+            return typeError("%s() takes exactly one argument (%d given)", name, n)
+        }
+        return typeError("%s expected %d arguments, got %d", name, min, n)
+    case n < min:
+        return typeError("%s expected at least %d argument%s, got %d", name, min, plural(min), n)
+    case max >= 0 && n > max:
+        return typeError("%s expected at most %d argument%s, got %d", name, max, plural(max), n)
+    }
+    return nil
+}
+
+// parseArgs binds the arguments of a native function to its parameter names.
+// Names starting with '*' are keyword-only; the first `required` parameters
+// must be supplied. Missing optional parameters come back as nil.
+func parseArgs(name string, args []Object, kwargs *Dict, params []string, required int) ([]Object, *Error) {
+    values := make([]Object, len(params))
+    positional := 0
+    for _, param := range params {
+        if !strings.HasPrefix(param, "*") {
+            positional++
+        }
+    }
+    if len(args) > positional {
+        return nil, typeError("%s() takes at most %d argument%s (%d given)", name, positional, plural(positional), len(args))
+    }
+    copy(values, args)
+
+    if kwargs != nil {
+        for _, entry := range kwargs.Entries() {
+            key := entry.Key.(*String).Value
+            index := -1
+            for i, param := range params {
+                if strings.TrimPrefix(param, "*") == key {
+                    index = i
+                    break
+                }
+            }
+            if index < 0 {
+                return nil, typeError("'%s' is an invalid keyword argument for %s()", key, name)
+            }
+            if values[index] != nil {
+                return nil, typeError("argument for %s() given by name ('%s') and position (%d)", name, key, index+1)
+            }
+            values[index] = entry.Value
+        }
+    }
+
+    for i := 0; i < required; i++ {
+        if values[i] == nil {
+            return nil, typeError("%s() missing required argument '%s' (pos %d)", name, strings.TrimPrefix(params[i], "*"), i+1)
+        }
+    }
+    return values, nil
+}
+
+// toIndex converts an argument to a Go int the way __index__ does
+func toIndex(env *Environment, obj Object) (int, *Error) {
+    value, err := indexValue(env, obj)
+    if err != nil {
+        return 0, err
+    }
+    if !value.IsInt64() || value.Int64() != int64(int(value.Int64())) {
+        return 0, indexError("cannot fit 'int' into an index-sized integer")
+    }
+    return int(value.Int64()), nil
+}
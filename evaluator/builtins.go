@@ -0,0 +1,1063 @@
+// Comments in this file are inspired by Harold Gunderson - the associate who ends up doing every odd job in the firm
+
+package evaluator
+
+import (
+    "fmt"
+    "math/big"
+    "strings"
+)
+
+// The rest of the builtins namespace: everything a script reaches for
+// without importing a thing, and the lazy iterator types behind it.
+
+// Range is range(): three numbers standing in for a whole sequence
+type Range struct {
+    Start *big.Int
+    Stop  *big.Int
+    Step  *big.Int
+}
+
+func (r *Range) Type() ObjectType { return RANGE_OBJ }
+func (r *Range) Inspect() string {
+    if r.Step.Cmp(big.NewInt(1)) == 0 {
+        return fmt.Sprintf("range(%s, %s)", r.Start, r.Stop)
+    }
+    return fmt.Sprintf("range(%s, %s, %s)", r.Start, r.Stop, r.Step)
+}
+
+// length is how many numbers the range holds - it may not fit an int
+func (r *Range) length() *big.Int {
+    span := new(big.Int)
+    if r.Step.Sign() > 0 {
+        span.Sub(r.Stop, r.Start)
+    } else {
+        span.Sub(r.Start, r.Stop)
+    }
+    if span.Sign() <= 0 {
+        return span.SetInt64(0)
+    }
+    step := new(big.Int).Abs(r.Step)
+    span.Sub(span, big.NewInt(1)).Quo(span, step)
+    return span.Add(span, big.NewInt(1))
+}
+
+// at is the i'th number, for an i already known to be in range
+func (r *Range) at(i *big.Int) *big.Int {
+    n := new(big.Int).Mul(i, r.Step)
+    return n.Add(n, r.Start)
+}
+
+// position is where n sits in the range, or -1 when it isn't there
+func (r *Range) position(n *big.Int) *big.Int {
+    offset := new(big.Int).Sub(n, r.Start)
+    i, m := new(big.Int).QuoRem(offset, r.Step, new(big.Int))
+    if m.Sign() != 0 || i.Sign() < 0 || i.Cmp(r.length()) >= 0 {
+        return big.NewInt(-1)
+    }
+    return i
+}
+
+func asRange(obj Object) *Range { return obj.(*Range) }
+
+// rangeLen is len() of a range, which has to fit a Py_ssize_t like everything else
+func rangeLen(r *Range) (int64, *Error) {
+    n := r.length()
+    if !n.IsInt64() {
+        return 0, overflowError("Python int too large to convert to C ssize_t")
+    }
+    return n.Int64(), nil
+}
+
+// rangeIterator counts from start in steps for n numbers
+func rangeIterator(start, step, n *big.Int) *Iter {
+    if start.IsInt64() && step.IsInt64() && n.IsInt64() {
+        last := new(big.Int).Add(start, new(big.Int).Mul(step, n))
+        if last.IsInt64() {
+            // the fast lane: it all fits in machine words
+            i, by, left := start.Int64(), step.Int64(), n.Int64()
+            return newIterator(rangeIteratorType, func(env *Environment) (Object, bool) {
+                if left <= 0 {
+                    return nil, false
+                }
+                left--
+                i += by
+                return newInt(i - by), true
+            })
+        }
+    }
+    i, left := new(big.Int).Set(start), new(big.Int).Set(n)
+    return newIterator(rangeIteratorType, func(env *Environment) (Object, bool) {
+        if left.Sign() <= 0 {
+            return nil, false
+        }
+        left.Sub(left, big.NewInt(1))
+        value := newBigInt(new(big.Int).Set(i))
+        i.Add(i, step)
+        return value, true
+    })
+}
+
+var (
+    rangeType            = newBuiltinClass("range", objectType)
+    rangeIteratorType    = newIteratorClass("range_iterator")
+    enumerateType        = newIteratorClass("enumerate")
+    zipType              = newIteratorClass("zip")
+    mapType              = newIteratorClass("map")
+    filterType           = newIteratorClass("filter")
+    reversedType         = newIteratorClass("reversed")
+    callableIteratorType = newIteratorClass("callable_iterator")
+)
+
+func init() {
+    initRange()
+    initIteratorBuiltins()
+    initFunctionBuiltins()
+    initAttributeBuiltins()
+
+    for _, cls := range []*Class{rangeType, enumerateType, zipType, mapType, filterType, reversedType, sliceType} {
+        builtins[cls.Name] = cls
+    }
+}
+
+func initRange() {
+    rangeType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        if kwargs.Len() > 0 {
+            return typeError("range() takes no keyword arguments")
+        }
+        if err := checkArgs("range", args[1:], nil, 1, 3); err != nil {
+            return err
+        }
+        bounds := make([]*big.Int, len(args)-1)
+        for i, arg := range args[1:] {
+            n, err := indexValue(env, arg)
+            if err != nil {
+                return err
+            }
+            bounds[i] = n
+        }
+        r := &Range{Start: big.NewInt(0), Step: big.NewInt(1)}
+        switch len(bounds) {
+        case 1:
+            r.Stop = bounds[0]
+        case 2:
+            r.Start, r.Stop = bounds[0], bounds[1]
+        case 3:
+            r.Start, r.Stop, r.Step = bounds[0], bounds[1], bounds[2]
+            if r.Step.Sign() == 0 {
+                return valueError("range() arg 3 must not be zero")
+            }
+        }
+        return r
+    })
+    rangeType.method("__repr__", 0, 0, func(env *Environment, args []Object) Object {
+        return &String{Value: args[0].Inspect()}
+    })
+    rangeType.method("__len__", 0, 0, func(env *Environment, args []Object) Object {
+        n, err := rangeLen(asRange(args[0]))
+        if err != nil {
+            return err
+        }
+        return newInt(n)
+    })
+    rangeType.method("__bool__", 0, 0, func(env *Environment, args []Object) Object {
+        return nativeBool(asRange(args[0]).length().Sign() != 0)
+    })
+    rangeType.method("__iter__", 0, 0, func(env *Environment, args []Object) Object {
+        r := asRange(args[0])
+        return rangeIterator(r.Start, r.Step, r.length())
+    })
+    rangeType.method("__reversed__", 0, 0, func(env *Environment, args []Object) Object {
+        r := asRange(args[0])
+        n := r.length()
+        last := r.at(new(big.Int).Sub(n, big.NewInt(1)))
+        return rangeIterator(last, new(big.Int).Neg(r.Step), n)
+    })
+    rangeType.method("__getitem__", 1, 1, func(env *Environment, args []Object) Object {
+        r := asRange(args[0])
+        if s, ok := args[1].(*Slice); ok {
+            n, err := rangeLen(r)
+            if err != nil {
+                return err
+            }
+            start, stop, step, err := s.indices(env, int(n))
+            if err != nil {
+                return err
+            }
+            by := big.NewInt(int64(step))
+            return &Range{
+                Start: r.at(big.NewInt(int64(start))),
+                Stop:  r.at(big.NewInt(int64(stop))),
+                Step:  by.Mul(by, r.Step),
+            }
+        }
+        if _, ok := toBigInt(args[1]); !ok && typeOf(args[1]).lookupName("__index__") == nil {
+            return typeError("range indices must be integers or slices, not %s", typeName(args[1]))
+        }
+        i, err := indexValue(env, args[1])
+        if err != nil {
+            return err
+        }
+        n := r.length()
+        if i.Sign() < 0 {
+            i = new(big.Int).Add(i, n)
+        }
+        if i.Sign() < 0 || i.Cmp(n) >= 0 {
+            return indexError("range object index out of range")
+        }
+        return newBigInt(r.at(i))
+    })
+    rangeType.method("__contains__", 1, 1, func(env *Environment, args []Object) Object {
+        r := asRange(args[0])
+        switch args[1].(type) {
+        case *Integer, *Boolean:
+            n, _ := toBigInt(args[1])
+            return nativeBool(r.position(n).Sign() >= 0)
+        }
+        found, err := contains(env, rangeIterator(r.Start, r.Step, r.length()), args[1])
+        if err != nil {
+            return err
+        }
+        return nativeBool(found)
+    })
+    rangeType.method("count", 1, 1, func(env *Environment, args []Object) Object {
+        r := asRange(args[0])
+        switch args[1].(type) {
+        case *Integer, *Boolean:
+            n, _ := toBigInt(args[1])
+            if r.position(n).Sign() >= 0 {
+                return newInt(1)
+            }
+            return newInt(0)
+        }
+        count := 0
+        if err := iterate(env, rangeIterator(r.Start, r.Step, r.length()), func(item Object) *Error {
+            eq, err := equals(env, item, args[1])
+            if eq {
+                count++
+            }
+            return err
+        }); err != nil {
+            return err
+        }
+        return newInt(int64(count))
+    })
+    rangeType.method("index", 1, 1, func(env *Environment, args []Object) Object {
+        r := asRange(args[0])
+        switch args[1].(type) {
+        case *Integer, *Boolean:
+            n, _ := toBigInt(args[1])
+            if i := r.position(n); i.Sign() >= 0 {
+                return newBigInt(i)
+            }
+            return valueError("%s is not in range", n)
+        }
+        index, found := 0, false
+        if err := iterate(env, rangeIterator(r.Start, r.Step, r.length()), func(item Object) *Error {
+            eq, err := equals(env, item, args[1])
+            if err != nil {
+                return err
+            }
+            if eq {
+                found = true
+                return errStopLoop
+            }
+            index++
+            return nil
+        }); err != nil {
+            return err
+        }
+        if !found {
+            return valueError("sequence.index(x): x not in sequence")
+        }
+        return newInt(int64(index))
+    })
+    rangeType.method("__eq__", 1, 1, func(env *Environment, args []Object) Object {
+        other, ok := args[1].(*Range)
+        if !ok {
+            return NotImplemented
+        }
+        return nativeBool(sameRange(asRange(args[0]), other))
+    })
+    rangeType.method("__ne__", 1, 1, func(env *Environment, args []Object) Object {
+        other, ok := args[1].(*Range)
+        if !ok {
+            return NotImplemented
+        }
+        return nativeBool(!sameRange(asRange(args[0]), other))
+    })
+    rangeType.method("__hash__", 0, 0, func(env *Environment, args []Object) Object {
+        // hashed like CPython: as (len, start, step), with what doesn't matter as None
+        r := asRange(args[0])
+        n := r.length()
+        key := []Object{newBigInt(n), NULL, NULL}
+        if n.Sign() > 0 {
+            key[1] = newBigInt(r.Start)
+            if n.Cmp(big.NewInt(1)) > 0 {
+                key[2] = newBigInt(r.Step)
+            }
+        }
+        h, err := hashOf(env, &Tuple{Elements: key})
+        if err != nil {
+            return err
+        }
+        return newInt(h)
+    })
+    rangeType.property("start", func(self Object) Object { return newBigInt(asRange(self).Start) })
+    rangeType.property("stop", func(self Object) Object { return newBigInt(asRange(self).Stop) })
+    rangeType.property("step", func(self Object) Object { return newBigInt(asRange(self).Step) })
+}
+
+// sameRange compares ranges as the sequences they stand for
+func sameRange(a, b *Range) bool {
+    n := a.length()
+    switch {
+    case n.Cmp(b.length()) != 0:
+        return false
+    case n.Sign() == 0:
+        return true
+    case a.Start.Cmp(b.Start) != 0:
+        return false
+    }
+    return n.Cmp(big.NewInt(1)) == 0 || a.Step.Cmp(b.Step) == 0
+}
+
+// argumentCount is "f expected N arguments, got M" - CPython's wording for
+// the builtins that are really classes
+func argumentCount(name string, args []Object, kwargs *Dict, n int) *Error {
+    if kwargs.Len() > 0 {
+        return typeError("%s() takes no keyword arguments", name)
+    }
+    if len(args) != n {
+        return typeError("%s expected %d argument%s, got %d", name, n, plural(n), len(args))
+    }
+    return nil
+}
+
+// iterators turns every argument into an iterator, or says which one can't be
+func iterators(env *Environment, args []Object) ([]Object, *Error) {
+    result := make([]Object, len(args))
+    for i, arg := range args {
+        iterator := getIter(env, arg)
+        if err, ok := iterator.(*Error); ok {
+            return nil, err
+        }
+        result[i] = iterator
+    }
+    return result, nil
+}
+
+func initIteratorBuiltins() {
+    enumerateType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        params, err := parseArgs("enumerate", args[1:], kwargs, []string{"iterable", "start"}, 0)
+        if err != nil {
+            return err
+        }
+        if params[0] == nil {
+            return typeError("enumerate() missing required argument 'iterable'")
+        }
+        iterator := getIter(env, params[0])
+        if isError(iterator) {
+            return iterator
+        }
+        count := big.NewInt(0)
+        if params[1] != nil {
+            if count, err = indexValue(env, params[1]); err != nil {
+                return err
+            }
+        }
+        count = new(big.Int).Set(count)
+        return newIterator(enumerateType, func(env *Environment) (Object, bool) {
+            item, ok := iterNext(env, iterator)
+            if !ok || isError(item) {
+                return item, ok
+            }
+            index := newBigInt(new(big.Int).Set(count))
+            count.Add(count, big.NewInt(1))
+            return &Tuple{Elements: []Object{index, item}}, true
+        })
+    })
+
+    zipType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        strict := false
+        for _, entry := range kwargs.Entries() {
+            if name := entry.Key.(*String).Value; name != "strict" {
+                return typeError("'%s' is an invalid keyword argument for zip()", name)
+            }
+            var err *Error
+            if strict, err = truthy(env, entry.Value); err != nil {
+                return err
+            }
+        }
+        sources, err := iterators(env, args[1:])
+        if err != nil {
+            return err
+        }
+        return newIterator(zipType, func(env *Environment) (Object, bool) {
+            if len(sources) == 0 {
+                return nil, false
+            }
+            items := make([]Object, len(sources))
+            for i, source := range sources {
+                item, ok := iterNext(env, source)
+                if !ok {
+                    if strict {
+                        return zipMismatch(env, sources, i)
+                    }
+                    return nil, false
+                }
+                if isError(item) {
+                    return item, true
+                }
+                items[i] = item
+            }
+            return &Tuple{Elements: items}, true
+        })
+    })
+
+    mapType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        if kwargs.Len() > 0 {
+            return typeError("map() takes no keyword arguments")
+        }
+        if len(args) < 3 {
+            return typeError("map() must have at least two arguments.")
+        }
+        fn := args[1]
+        sources, err := iterators(env, args[2:])
+        if err != nil {
+            return err
+        }
+        return newIterator(mapType, func(env *Environment) (Object, bool) {
+            items := make([]Object, len(sources))
+            for i, source := range sources {
+                item, ok := iterNext(env, source)
+                if !ok || isError(item) {
+                    return item, ok
+                }
+                items[i] = item
+            }
+            return applyFunction(env, fn, items, nil), true
+        })
+    })
+
+    filterType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := argumentCount("filter", args[1:], kwargs, 2); err != nil {
+            return err
+        }
+        fn := args[1]
+        iterator := getIter(env, args[2])
+        if isError(iterator) {
+            return iterator
+        }
+        return newIterator(filterType, func(env *Environment) (Object, bool) {
+            for {
+                item, ok := iterNext(env, iterator)
+                if !ok || isError(item) {
+                    return item, ok
+                }
+                verdict := item
+                if fn != NULL {
+                    if verdict = applyFunction(env, fn, []Object{item}, nil); isError(verdict) {
+                        return verdict, true
+                    }
+                }
+                keep, err := truthy(env, verdict)
+                if err != nil {
+                    return err, true
+                }
+                if keep {
+                    return item, true
+                }
+            }
+        })
+    })
+
+    reversedType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := argumentCount("reversed", args[1:], kwargs, 1); err != nil {
+            return err
+        }
+        return reversedOf(env, args[1])
+    })
+}
+
+// zipMismatch is strict zip() finding out source i ran dry: wrong if it is
+// the first to stop, or if the first stopped and some other one hasn't
+func zipMismatch(env *Environment, sources []Object, i int) (Object, bool) {
+    others := func(i int) string {
+        if i == 1 {
+            return "argument 1"
+        }
+        return fmt.Sprintf("arguments 1-%d", i)
+    }
+    if i > 0 {
+        return valueError("zip() argument %d is shorter than %s", i+1, others(i)), true
+    }
+    for j, source := range sources[1:] {
+        item, ok := iterNext(env, source)
+        if !ok {
+            continue
+        }
+        if isError(item) {
+            return item, true
+        }
+        return valueError("zip() argument %d is longer than %s", j+2, others(j+1)), true
+    }
+    return nil, false
+}
+
+// reversedOf is reversed(seq): __reversed__, or walking a sequence backwards
+func reversedOf(env *Environment, seq Object) Object {
+    cls := typeOf(seq)
+    if method := cls.lookupName("__reversed__"); method != nil {
+        if method == NULL {
+            return typeError("'%s' object is not reversible", cls.Name)
+        }
+        return callMethod(env, method, seq)
+    }
+    getitem := cls.lookupName("__getitem__")
+    if _, isDict := payload(seq).(*Dict); isDict || getitem == nil || cls.lookupName("__len__") == nil {
+        return typeError("'%s' object is not reversible", cls.Name)
+    }
+    n, err := length(env, seq)
+    if err != nil {
+        return err
+    }
+    i := n - 1
+    return newIterator(reversedType, func(env *Environment) (Object, bool) {
+        if i < 0 {
+            return nil, false
+        }
+        item := callMethod(env, getitem, seq, newInt(int64(i)))
+        i--
+        if err, ok := item.(*Error); ok && (err.matches(indexErrorType) || err.matches(stopIterationType)) {
+            return nil, false
+        }
+        return item, true
+    })
+}
+
+// callIterator is iter(fn, sentinel): call fn until it hands back the sentinel
+func callIterator(env *Environment, fn, sentinel Object) Object {
+    if !isCallable(fn) {
+        return typeError("iter(v, w): v must be callable")
+    }
+    return newIterator(callableIteratorType, func(env *Environment) (Object, bool) {
+        value := applyFunction(env, fn, nil, nil)
+        if err, ok := value.(*Error); ok {
+            if err.matches(stopIterationType) {
+                return nil, false
+            }
+            return err, true
+        }
+        done, err := equals(env, value, sentinel)
+        if err != nil {
+            return err, true
+        }
+        if done {
+            return nil, false
+        }
+        return value, true
+    })
+}
+
+// minMax is min() and max(): one iterable or several arguments, with key= and default=
+func minMax(name, operator string) BuiltinFunction {
+    return func(env *Environment, args []Object, kwargs *Dict) Object {
+        var key, fallback Object
+        for _, entry := range kwargs.Entries() {
+            switch keyword := entry.Key.(*String).Value; keyword {
+            case "key":
+                key = entry.Value
+            case "default":
+                fallback = entry.Value
+            default:
+                return typeError("'%s' is an invalid keyword argument for %s()", keyword, name)
+            }
+        }
+        switch {
+        case len(args) == 0:
+            return typeError("%s expected at least 1 argument, got 0", name)
+        case len(args) > 1 && fallback != nil:
+            return typeError("Cannot specify a default for %s() with multiple positional arguments", name)
+        }
+
+        var best, bestKey Object
+        consider := func(item Object) *Error {
+            itemKey := item
+            if key != nil && key != NULL {
+                itemKey = applyFunction(env, key, []Object{item}, nil)
+                if err, ok := itemKey.(*Error); ok {
+                    return err
+                }
+            }
+            if best == nil {
+                best, bestKey = item, itemKey
+                return nil
+            }
+            result := richCompare(env, operator, itemKey, bestKey)
+            if err, ok := result.(*Error); ok {
+                return err
+            }
+            better, err := truthy(env, result)
+            if better {
+                best, bestKey = item, itemKey
+            }
+            return err
+        }
+        if len(args) == 1 {
+            if err := iterate(env, args[0], consider); err != nil {
+                return err
+            }
+        } else {
+            for _, item := range args {
+                if err := consider(item); err != nil {
+                    return err
+                }
+            }
+        }
+        if best == nil {
+            if fallback != nil {
+                return fallback
+            }
+            return valueError("%s() arg is an empty sequence", name)
+        }
+        return best
+    }
+}
+
+// ternaryPow is pow(base, exp, mod). Only ints can do it - or a class whose
+// __pow__ takes the modulus; floats and complexes refuse outright.
+func ternaryPow(env *Environment, base, exp, mod Object) Object {
+    b, isInt := toBigInt(base)
+    e, expInt := toBigInt(exp)
+    _, modInt := toBigInt(mod)
+    if isInt && expInt && modInt {
+        return intPow(env, b, e, mod)
+    }
+    if method := typeOf(base).lookupName("__pow__"); method != nil && !isNumber(base) {
+        if result := callMethod(env, method, base, exp, mod); result != NotImplemented {
+            return result
+        }
+    }
+    for _, operand := range []Object{base, exp, mod} {
+        switch payload(operand).(type) {
+        case *Float:
+            return typeError("pow() 3rd argument not allowed unless all arguments are integers")
+        case *Complex:
+            return valueError("complex modulo")
+        }
+    }
+    return typeError("unsupported operand type(s) for ** or pow(): '%s', '%s', '%s'", typeName(base), typeName(exp), typeName(mod))
+}
+
+// characterCode is what chr() accepts: an int that fits a C int
+func characterCode(env *Environment, obj Object) (int64, *Error) {
+    n, err := indexValue(env, obj)
+    if err != nil {
+        return 0, err
+    }
+    if !n.IsInt64() || n.Int64() != int64(int32(n.Int64())) {
+        return 0, overflowError("Python int too large to convert to C int")
+    }
+    return n.Int64(), nil
+}
+
+// integerText is bin(), oct() and hex(): the sign, the prefix, then the digits
+func integerText(env *Environment, obj Object, base int, prefix string) Object {
+    n, err := indexValue(env, obj)
+    if err != nil {
+        return err
+    }
+    sign := ""
+    if n.Sign() < 0 {
+        sign = "-"
+    }
+    return &String{Value: sign + prefix + new(big.Int).Abs(n).Text(base)}
+}
+
+// asciiRepr is ascii(): repr, with everything past ASCII escaped
+func asciiRepr(text string) string {
+    var b strings.Builder
+    for _, r := range codePoints(text) {
+        if r < 0x80 {
+            b.WriteRune(r)
+        } else {
+            b.WriteString(escapeCodePoint(r))
+        }
+    }
+    return b.String()
+}
+
+func initFunctionBuiltins() {
+    registerBuiltin("sorted", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if len(args) != 1 {
+            return typeError("sorted expected 1 argument, got %d", len(args))
+        }
+        items, err := iterableToSlice(env, args[0])
+        if err != nil {
+            return err
+        }
+        list := &List{Elements: items}
+        if result := applyFunction(env, listType.lookupName("sort"), []Object{list}, kwargs); isError(result) {
+            return result
+        }
+        return list
+    })
+    registerBuiltin("min", minMax("min", "<"))
+    registerBuiltin("max", minMax("max", ">"))
+    registerBuiltin("sum", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if len(args) == 0 {
+            return typeError("sum() takes at least 1 positional argument (0 given)")
+        }
+        params, err := parseArgs("sum", args[1:], kwargs, []string{"start"}, 0)
+        if err != nil {
+            if len(args) > 2 {
+                return typeError("sum() takes at most 2 arguments (%d given)", len(args))
+            }
+            return err
+        }
+        var total Object = newInt(0)
+        if params[0] != nil {
+            total = params[0]
+            switch payload(total).(type) {
+            case *String:
+                return typeError("sum() can't sum strings [use ''.join(seq) instead]")
+            case *Bytes:
+                return typeError("sum() can't sum bytes [use b''.join(seq) instead]")
+            case *ByteArray:
+                return typeError("sum() can't sum bytearray [use b''.join(seq) instead]")
+            }
+        }
+        if err := iterate(env, args[0], func(item Object) *Error {
+            total = binaryOperation(env, "+", total, item)
+            if err, ok := total.(*Error); ok {
+                return err
+            }
+            return nil
+        }); err != nil {
+            return err
+        }
+        return total
+    })
+    for _, name := range []string{"any", "all"} {
+        want := name == "any"
+        registerBuiltin(name, func(env *Environment, args []Object, kwargs *Dict) Object {
+            if err := checkArgs(name, args, kwargs, 1, 1); err != nil {
+                return err
+            }
+            found := false
+            if err := iterate(env, args[0], func(item Object) *Error {
+                ok, err := truthy(env, item)
+                if err != nil {
+                    return err
+                }
+                if ok == want {
+                    found = true
+                    return errStopLoop
+                }
+                return nil
+            }); err != nil {
+                return err
+            }
+            return nativeBool(found == want)
+        })
+    }
+    registerBuiltin("round", func(env *Environment, args []Object, kwargs *Dict) Object {
+        params, err := parseArgs("round", args, kwargs, []string{"number", "ndigits"}, 1)
+        if err != nil {
+            return err
+        }
+        method := typeOf(params[0]).lookupName("__round__")
+        if method == nil {
+            return typeError("type %s doesn't define __round__ method", typeName(params[0]))
+        }
+        if params[1] == nil || params[1] == NULL {
+            return callMethod(env, method, params[0])
+        }
+        return callMethod(env, method, params[0], params[1])
+    })
+    registerBuiltin("divmod", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("divmod", args, kwargs, 2, 2); err != nil {
+            return err
+        }
+        result := tryBinaryOperation(env, "divmod", args[0], args[1])
+        if result == NotImplemented {
+            return unsupportedOperands("divmod", "divmod", args[0], args[1])
+        }
+        return result
+    })
+    registerBuiltin("pow", func(env *Environment, args []Object, kwargs *Dict) Object {
+        params, err := parseArgs("pow", args, kwargs, []string{"base", "exp", "mod"}, 2)
+        if err != nil {
+            return err
+        }
+        if params[2] == nil || params[2] == NULL {
+            return binaryOperation(env, "**", params[0], params[1])
+        }
+        return ternaryPow(env, params[0], params[1], params[2])
+    })
+    registerBuiltin("id", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("id", args, kwargs, 1, 1); err != nil {
+            return err
+        }
+        return newInt(objectID(args[0]))
+    })
+    registerBuiltin("ascii", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("ascii", args, kwargs, 1, 1); err != nil {
+            return err
+        }
+        repr, err := reprString(env, args[0])
+        if err != nil {
+            return err
+        }
+        return &String{Value: asciiRepr(repr)}
+    })
+    registerBuiltin("chr", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("chr", args, kwargs, 1, 1); err != nil {
+            return err
+        }
+        code, err := characterCode(env, args[0])
+        if err != nil {
+            return err
+        }
+        if code < 0 || code >= 0x110000 {
+            return valueError("chr() arg not in range(0x110000)")
+        }
+        return &String{Value: fromCodePoints([]rune{rune(code)})}
+    })
+    registerBuiltin("ord", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("ord", args, kwargs, 1, 1); err != nil {
+            return err
+        }
+        switch value := payload(args[0]).(type) {
+        case *String:
+            text := codePoints(value.Value)
+            if len(text) != 1 {
+                return typeError("ord() expected a character, but string of length %d found", len(text))
+            }
+            return newInt(int64(text[0]))
+        case *Bytes, *ByteArray:
+            data := selfBytes(value)
+            if len(data) != 1 {
+                return typeError("ord() expected a character, but string of length %d found", len(data))
+            }
+            return newInt(int64(data[0]))
+        }
+        return typeError("ord() expected string of length 1, but %s found", typeName(args[0]))
+    })
+    for _, spec := range []struct {
+        name   string
+        base   int
+        prefix string
+    }{{"bin", 2, "0b"}, {"oct", 8, "0o"}, {"hex", 16, "0x"}} {
+        spec := spec
+        registerBuiltin(spec.name, func(env *Environment, args []Object, kwargs *Dict) Object {
+            if err := checkArgs(spec.name, args, kwargs, 1, 1); err != nil {
+                return err
+            }
+            return integerText(env, args[0], spec.base, spec.prefix)
+        })
+    }
+    registerBuiltin("callable", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("callable", args, kwargs, 1, 1); err != nil {
+            return err
+        }
+        return nativeBool(isCallable(args[0]))
+    })
+    registerBuiltin("input", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("input", args, kwargs, 0, 1); err != nil {
+            return err
+        }
+        stdin, err := env.interp.stream("stdin")
+        if err != nil {
+            return err
+        }
+        stdout, err := env.interp.stream("stdout")
+        if err != nil {
+            return err
+        }
+        if len(args) == 1 {
+            prompt := strOf(env, args[0])
+            if isError(prompt) {
+                return prompt
+            }
+            if err := writeStream(env, stdout, payload(prompt).(*String).Value); err != nil {
+                return err
+            }
+        }
+        if err := flushStream(env, stdout); err != nil {
+            return err
+        }
+        readline := getAttribute(env, stdin, "readline")
+        if isError(readline) {
+            return readline
+        }
+        line := applyFunction(env, readline, nil, nil)
+        if isError(line) {
+            return line
+        }
+        text, ok := payload(line).(*String)
+        if !ok {
+            return typeError("object.readline() returned non-string")
+        }
+        if text.Value == "" {
+            return newErrorKind(exceptionClasses["EOFError"], "EOF when reading a line")
+        }
+        return &String{Value: strings.TrimSuffix(text.Value, "\n")}
+    })
+}
+
+// attributeName is the name getattr() and friends were given, which has to be a str
+func attributeName(obj Object) (string, *Error) {
+    name, ok := payload(obj).(*String)
+    if !ok {
+        return "", typeError("attribute name must be string, not '%s'", typeName(obj))
+    }
+    return name.Value, nil
+}
+
+func initAttributeBuiltins() {
+    registerBuiltin("getattr", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("getattr", args, kwargs, 2, 3); err != nil {
+            return err
+        }
+        name, err := attributeName(args[1])
+        if err != nil {
+            return err
+        }
+        value := getAttribute(env, args[0], name)
+        if err, ok := value.(*Error); ok && len(args) == 3 && err.matches(attributeErrorType) {
+            return args[2]
+        }
+        return value
+    })
+    registerBuiltin("hasattr", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("hasattr", args, kwargs, 2, 2); err != nil {
+            return err
+        }
+        name, err := attributeName(args[1])
+        if err != nil {
+            return err
+        }
+        value := getAttribute(env, args[0], name)
+        if err, ok := value.(*Error); ok {
+            if err.matches(attributeErrorType) {
+                return FALSE
+            }
+            return err
+        }
+        return TRUE
+    })
+    registerBuiltin("setattr", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("setattr", args, kwargs, 3, 3); err != nil {
+            return err
+        }
+        name, err := attributeName(args[1])
+        if err != nil {
+            return err
+        }
+        if err := setAttribute(env, args[0], name, args[2]); err != nil {
+            return err
+        }
+        return NULL
+    })
+    registerBuiltin("delattr", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("delattr", args, kwargs, 2, 2); err != nil {
+            return err
+        }
+        name, err := attributeName(args[1])
+        if err != nil {
+            return err
+        }
+        if err := delAttribute(env, args[0], name); err != nil {
+            return err
+        }
+        return NULL
+    })
+    registerBuiltin("vars", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("vars", args, kwargs, 0, 1); err != nil {
+            return err
+        }
+        if len(args) == 0 {
+            return env.locals()
+        }
+        dict := getAttribute(env, args[0], "__dict__")
+        if err, ok := dict.(*Error); ok && err.matches(attributeErrorType) {
+            return typeError("vars() argument must have __dict__ attribute")
+        }
+        return dict
+    })
+    registerBuiltin("dir", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("dir", args, kwargs, 0, 1); err != nil {
+            return err
+        }
+        var names Object
+        if len(args) == 0 {
+            var keys []Object
+            for _, entry := range env.locals().Entries() {
+                keys = append(keys, entry.Key)
+            }
+            names = &List{Elements: keys}
+        } else {
+            names = callMethod(env, typeOf(args[0]).lookupName("__dir__"), args[0])
+            if isError(names) {
+                return names
+            }
+        }
+        items, err := iterableToSlice(env, names)
+        if err != nil {
+            return err
+        }
+        sorted, err := sortObjects(env, items, nil, false)
+        if err != nil {
+            return err
+        }
+        return &List{Elements: sorted}
+    })
+    registerBuiltin("globals", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("globals", args, kwargs, 0, 0); err != nil {
+            return err
+        }
+        return env.globals().namespaceDict()
+    })
+    registerBuiltin("locals", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("locals", args, kwargs, 0, 0); err != nil {
+            return err
+        }
+        return env.locals()
+    })
+
+    // dir()'s default answer: the instance's own attributes, then its class's
+    objectType.method("__dir__", 0, 0, func(env *Environment, args []Object) Object {
+        names := NewSet()
+        add := func(dict *Dict) {
+            for _, entry := range dict.Entries() {
+                names.Items.insert(entry.Key, entry.Key, entry.hash)
+            }
+        }
+        names.Items.SetStr("__class__", &String{Value: "__class__"})
+        if dict := instanceDict(args[0]); dict != nil {
+            names.Items.SetStr("__dict__", &String{Value: "__dict__"})
+            add(dict)
+        }
+        for _, cls := range typeOf(args[0]).MRO {
+            add(cls.Dict)
+        }
+        return &List{Elements: names.elements()}
+    })
+    typeType.method("__dir__", 0, 0, func(env *Environment, args []Object) Object {
+        cls, ok := args[0].(*Class)
+        if !ok {
+            return typeError("descriptor '__dir__' requires a 'type' object but received a '%s'", typeName(args[0]))
+        }
+        names := NewSet()
+        for _, c := range cls.MRO {
+            for _, entry := range c.Dict.Entries() {
+                if _, seen := names.Items.GetStr(entry.Key.(*String).Value); !seen {
+                    names.Items.insert(entry.Key, entry.Key, entry.hash)
+                }
+            }
+        }
+        return &List{Elements: names.elements()}
+    })
+}
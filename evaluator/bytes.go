@@ -0,0 +1,1569 @@
+// Comments in this file are inspired by Benjamin - the only one at the firm who reads raw data for fun
+
+package evaluator
+
+import (
+    "bytes"
+    "fmt"
+    "strings"
+    "unicode/utf8"
+)
+
+// Bytes. What text turns into once it leaves the building.
+type Bytes struct {
+    Value []byte
+}
+
+func (b *Bytes) Type() ObjectType { return BYTES_OBJ }
+func (b *Bytes) Inspect() string  { return bytesRepr(b.Value) }
+
+// ByteArray is bytes you're allowed to edit
+type ByteArray struct {
+    Value []byte
+}
+
+func (b *ByteArray) Type() ObjectType { return BYTEARRAY_OBJ }
+func (b *ByteArray) Inspect() string  { return "bytearray(" + bytesRepr(b.Value) + ")" }
+
+// MemoryView looks at somebody else's bytes without copying them. Reads and
+// writes go straight through to source, so a view of a bytearray sees every
+// change made to it.
+type MemoryView struct {
+    source   Object // the *Bytes or *ByteArray underneath
+    start    int
+    step     int
+    length   int
+    released bool
+}
+
+func (m *MemoryView) Type() ObjectType { return MEMORYVIEW_OBJ }
+func (m *MemoryView) Inspect() string {
+    if m.released {
+        return fmt.Sprintf("<released memory at %#x>", objectID(m))
+    }
+    return fmt.Sprintf("<memory at %#x>", objectID(m))
+}
+
+var (
+    bytesType      = newBuiltinClass("bytes", objectType)
+    bytearrayType  = newBuiltinClass("bytearray", objectType)
+    memoryviewType = newBuiltinClass("memoryview", objectType)
+
+    bytesIteratorType     = newIteratorClass("bytes_iterator")
+    bytearrayIteratorType = newIteratorClass("bytearray_iterator")
+    memoryIteratorType    = newIteratorClass("memory_iterator")
+)
+
+func asBytes(obj Object) *Bytes           { return payload(obj).(*Bytes) }
+func asByteArray(obj Object) *ByteArray   { return payload(obj).(*ByteArray) }
+func asMemoryView(obj Object) *MemoryView { return payload(obj).(*MemoryView) }
+
+// buffer is the whole of what the view looks into
+func (m *MemoryView) buffer() []byte {
+    if b, ok := m.source.(*Bytes); ok {
+        return b.Value
+    }
+    return m.source.(*ByteArray).Value
+}
+
+func (m *MemoryView) readonly() bool {
+    _, ok := m.source.(*Bytes)
+    return ok
+}
+
+// position maps an index into the view to an index into the buffer. The
+// bytearray may have shrunk since the view was taken; we don't lock it the
+// way CPython does, we just notice.
+func (m *MemoryView) position(i int) (int, *Error) {
+    at := m.start + i*m.step
+    if at >= len(m.buffer()) {
+        return 0, valueError("memoryview: underlying buffer was resized")
+    }
+    return at, nil
+}
+
+// bytes copies out what the view sees
+func (m *MemoryView) bytes() ([]byte, *Error) {
+    if err := m.check(); err != nil {
+        return nil, err
+    }
+    data := make([]byte, m.length)
+    for i := range data {
+        at, err := m.position(i)
+        if err != nil {
+            return nil, err
+        }
+        data[i] = m.buffer()[at]
+    }
+    return data, nil
+}
+
+func (m *MemoryView) check() *Error {
+    if m.released {
+        return valueError("operation forbidden on released memoryview object")
+    }
+    return nil
+}
+
+// bytesLike reads anything that supports the buffer protocol. ok is false
+// for everything else; err is set when a released view is handed in.
+func bytesLike(obj Object) (data []byte, ok bool, err *Error) {
+    switch obj := payload(obj).(type) {
+    case *Bytes:
+        return obj.Value, true, nil
+    case *ByteArray:
+        return obj.Value, true, nil
+    case *MemoryView:
+        data, err := obj.bytes()
+        return data, true, err
+    }
+    return nil, false, nil
+}
+
+// bytesArgument is bytesLike for parameters that must be bytes-like
+func bytesArgument(obj Object) ([]byte, *Error) {
+    data, ok, err := bytesLike(obj)
+    if !ok {
+        return nil, typeError("a bytes-like object is required, not '%s'", typeName(obj))
+    }
+    return data, err
+}
+
+// selfBytes is the data of a bytes or bytearray method's self
+func selfBytes(obj Object) []byte {
+    if b, ok := payload(obj).(*Bytes); ok {
+        return b.Value
+    }
+    return asByteArray(obj).Value
+}
+
+// sameKind wraps a method's result in the type of self: bytes methods give
+// bytes and bytearray methods give bytearrays
+func sameKind(self Object, data []byte) Object {
+    if _, ok := payload(self).(*ByteArray); ok {
+        return &ByteArray{Value: data}
+    }
+    return &Bytes{Value: data}
+}
+
+// byteValue is an int that has to fit in a byte. outOfRange is the message
+// for ints that don't, which CPython words differently in different places.
+func byteValue(env *Environment, obj Object, outOfRange string) (byte, *Error) {
+    if _, ok := toBigInt(obj); !ok && typeOf(obj).lookupName("__index__") == nil {
+        return 0, typeError("'%s' object cannot be interpreted as an integer", typeName(obj))
+    }
+    n, err := indexValue(env, obj)
+    if err != nil {
+        return 0, err
+    }
+    if !n.IsInt64() || n.Int64() < 0 || n.Int64() > 255 {
+        return 0, valueError("%s", outOfRange)
+    }
+    return byte(n.Int64()), nil
+}
+
+// bytesFromObject is what bytes(...) and bytearray(...) do with their arguments
+func bytesFromObject(env *Environment, name string, source, encoding, errors Object) ([]byte, *Error) {
+    if source == nil {
+        switch {
+        case encoding != nil:
+            return nil, typeError("encoding without a string argument")
+        case errors != nil:
+            return nil, typeError("errors without a string argument")
+        }
+        return []byte{}, nil
+    }
+    if s, ok := payload(source).(*String); ok {
+        if encoding == nil {
+            return nil, typeError("string argument without an encoding")
+        }
+        options, err := codecOptions(name, []Object{encoding, errors})
+        if err != nil {
+            return nil, err
+        }
+        encoded := encodeString(s.Value, options[0], options[1])
+        if err, ok := encoded.(*Error); ok {
+            return nil, err
+        }
+        return encoded.(*Bytes).Value, nil
+    }
+    switch {
+    case encoding != nil:
+        return nil, typeError("encoding without a string argument")
+    case errors != nil:
+        return nil, typeError("errors without a string argument")
+    }
+
+    if name == "bytes" {
+        if method := typeOf(source).lookupName("__bytes__"); method != nil {
+            result := applyFunction(env, method, []Object{source}, nil)
+            if isError(result) {
+                return nil, result.(*Error)
+            }
+            b, ok := payload(result).(*Bytes)
+            if !ok {
+                return nil, typeError("__bytes__ returned non-bytes (type %s)", typeName(result))
+            }
+            return b.Value, nil
+        }
+    }
+    if data, ok, err := bytesLike(source); ok {
+        if err != nil {
+            return nil, err
+        }
+        return append([]byte{}, data...), nil
+    }
+    if _, ok := toBigInt(source); ok || typeOf(source).lookupName("__index__") != nil {
+        value, err := indexValue(env, source)
+        if err != nil {
+            return nil, err
+        }
+        if !value.IsInt64() {
+            return nil, overflowError("cannot fit 'int' into an index-sized integer")
+        }
+        if value.Sign() < 0 {
+            return nil, valueError("negative count")
+        }
+        n, err := allocation(int(value.Int64()), 1)
+        if err != nil {
+            return nil, err
+        }
+        return make([]byte, n), nil
+    }
+    if typeOf(source).lookupName("__iter__") == nil && typeOf(source).lookupName("__getitem__") == nil {
+        return nil, typeError("cannot convert '%s' object to %s", typeName(source), name)
+    }
+    data := []byte{}
+    err := iterate(env, source, func(item Object) *Error {
+        c, err := byteValue(env, item, "bytes must be in range(0, 256)")
+        if err != nil {
+            return err
+        }
+        data = append(data, c)
+        return nil
+    })
+    return data, err
+}
+
+// codecOptions reads the encoding and errors arguments, either of which may be missing
+func codecOptions(name string, params []Object) ([]string, *Error) {
+    options := []string{"utf-8", "strict"}
+    for i, param := range params {
+        if param == nil {
+            continue
+        }
+        s, ok := payload(param).(*String)
+        if !ok {
+            return nil, typeError("%s() argument '%s' must be str, not %s", name, []string{"encoding", "errors"}[i], typeName(param))
+        }
+        options[i] = s.Value
+    }
+    return options, nil
+}
+
+// bytesRepr is b'...', quoted like strRepr but escaping everything past ASCII
+func bytesRepr(data []byte) string {
+    quote := byte('\'')
+    if strings.IndexByte(string(data), '\'') >= 0 && strings.IndexByte(string(data), '"') < 0 {
+        quote = '"'
+    }
+
+    var b strings.Builder
+    b.WriteByte('b')
+    b.WriteByte(quote)
+    for _, c := range data {
+        switch {
+        case c == quote || c == '\\':
+            b.WriteByte('\\')
+            b.WriteByte(c)
+        case c == '\n':
+            b.WriteString(`\n`)
+        case c == '\r':
+            b.WriteString(`\r`)
+        case c == '\t':
+            b.WriteString(`\t`)
+        case c < 0x20 || c >= 0x7f:
+            fmt.Fprintf(&b, `\x%02x`, c)
+        default:
+            b.WriteByte(c)
+        }
+    }
+    b.WriteByte(quote)
+    return b.String()
+}
+
+// normalizeEncoding maps the spellings of the codecs we carry to one name
+func normalizeEncoding(encoding string) (string, bool) {
+    switch strings.ReplaceAll(strings.ToLower(encoding), "_", "-") {
+    case "utf-8", "utf8", "u8":
+        return "utf-8", true
+    case "ascii", "us-ascii":
+        return "ascii", true
+    case "latin-1", "latin1", "iso-8859-1", "iso8859-1", "l1":
+        return "latin-1", true
+    }
+    return "", false
+}
+
+// escapeCodePoint is how codec errors show a character: always escaped
+func escapeCodePoint(r rune) string {
+    switch {
+    case r < 0x100:
+        return fmt.Sprintf(`\x%02x`, r)
+    case r < 0x10000:
+        return fmt.Sprintf(`\u%04x`, r)
+    }
+    return fmt.Sprintf(`\U%08x`, r)
+}
+
+// encodeString is str.encode. errors names the handler for characters the
+// codec can't represent.
+func encodeString(s, encoding, errors string) Object {
+    codec, ok := normalizeEncoding(encoding)
+    if !ok {
+        return newErrorKind(lookupErrorType, "unknown encoding: %s", encoding)
+    }
+    if codec == "utf-8" && !strings.Contains(s, "\xed") {
+        return &Bytes{Value: []byte(s)}
+    }
+    limit, reason := rune(0x80), "ordinal not in range(128)"
+    switch codec {
+    case "latin-1":
+        limit, reason = 0x100, "ordinal not in range(256)"
+    case "utf-8":
+        // only surrogates are out of reach
+        limit, reason = utf8.MaxRune+1, "surrogates not allowed"
+    }
+    encodable := func(r rune) bool { return r < limit && !isSurrogate(r) }
+
+    text := codePoints(s)
+    data := make([]byte, 0, len(s))
+    for i := 0; i < len(text); i++ {
+        if encodable(text[i]) {
+            if codec == "utf-8" {
+                data = utf8.AppendRune(data, text[i])
+            } else {
+                data = append(data, byte(text[i]))
+            }
+            continue
+        }
+        end := i + 1
+        for end < len(text) && !encodable(text[end]) {
+            end++
+        }
+        switch errors {
+        case "ignore":
+        case "replace":
+            data = append(data, strings.Repeat("?", end-i)...)
+        case "surrogateescape", "strict":
+            escaped := errors == "surrogateescape"
+            for _, r := range text[i:end] {
+                escaped = escaped && r >= 0xdc80 && r <= 0xdcff
+            }
+            if escaped {
+                for _, r := range text[i:end] {
+                    data = append(data, byte(r-0xdc00))
+                }
+                break
+            }
+            where := fmt.Sprintf("character '%s' in position %d", escapeCodePoint(text[i]), i)
+            if end-i > 1 {
+                where = fmt.Sprintf("characters in position %d-%d", i, end-1)
+            }
+            return newErrorKind(exceptionClasses["UnicodeEncodeError"], "'%s' codec can't encode %s: %s", codec, where, reason)
+        default:
+            return newErrorKind(lookupErrorType, "unknown error handler name '%s'", errors)
+        }
+        i = end - 1
+    }
+    return &Bytes{Value: data}
+}
+
+// decodeBytes is bytes.decode, the way back
+func decodeBytes(data []byte, encoding, errors string) Object {
+    codec, ok := normalizeEncoding(encoding)
+    if !ok {
+        return newErrorKind(lookupErrorType, "unknown encoding: %s", encoding)
+    }
+
+    var b strings.Builder
+    for i := 0; i < len(data); {
+        r, size := rune(data[i]), 1
+        reason := ""
+        switch codec {
+        case "utf-8":
+            r, size = utf8.DecodeRune(data[i:])
+            if r == utf8.RuneError && size <= 1 {
+                reason, size = utf8Problem(data[i:])
+            }
+        case "ascii":
+            if r >= 0x80 {
+                reason = "ordinal not in range(128)"
+            }
+        }
+        if reason == "" {
+            b.WriteRune(r)
+            i += size
+            continue
+        }
+        switch errors {
+        case "ignore":
+        case "replace":
+            b.WriteRune(utf8.RuneError)
+        case "surrogateescape":
+            // every bad byte becomes U+DC80..U+DCFF, so encoding gets it back
+            for _, c := range data[i : i+size] {
+                writeCodePoint(&b, 0xdc00+rune(c))
+            }
+        case "strict":
+            where := fmt.Sprintf("byte 0x%02x in position %d", data[i], i)
+            if size > 1 {
+                where = fmt.Sprintf("bytes in position %d-%d", i, i+size-1)
+            }
+            return newErrorKind(exceptionClasses["UnicodeDecodeError"], "'%s' codec can't decode %s: %s", codec, where, reason)
+        default:
+            return newErrorKind(lookupErrorType, "unknown error handler name '%s'", errors)
+        }
+        i += size
+    }
+    return &String{Value: b.String()}
+}
+
+// utf8Problem says what is wrong with the sequence at the start of data,
+// and how many bytes it spoils
+func utf8Problem(data []byte) (string, int) {
+    c := data[0]
+    need := 0
+    switch {
+    case c >= 0xc2 && c <= 0xdf:
+        need = 2
+    case c >= 0xe0 && c <= 0xef:
+        need = 3
+    case c >= 0xf0 && c <= 0xf4:
+        need = 4
+    default:
+        return "invalid start byte", 1
+    }
+    // the second byte is where overlongs, surrogates and too-big code
+    // points give themselves away
+    low, high := byte(0x80), byte(0xbf)
+    switch c {
+    case 0xe0:
+        low = 0xa0
+    case 0xed:
+        high = 0x9f
+    case 0xf0:
+        low = 0x90
+    case 0xf4:
+        high = 0x8f
+    }
+    size := 1
+    for size < need && size < len(data) && data[size] >= low && data[size] <= high {
+        size++
+        low, high = 0x80, 0xbf
+    }
+    if size < need && size == len(data) {
+        return "unexpected end of data", size
+    }
+    return "invalid continuation byte", size
+}
+
+// hexString is bytes.hex. A positive every counts the groups between
+// separators from the right, a negative one from the left.
+func hexString(data []byte, sep string, every int) string {
+    const digits = "0123456789abcdef"
+    var b strings.Builder
+    for i, c := range data {
+        if sep != "" && i > 0 && (every > 0 && (len(data)-i)%every == 0 || every < 0 && i%-every == 0) {
+            b.WriteString(sep)
+        }
+        b.WriteByte(digits[c>>4])
+        b.WriteByte(digits[c&15])
+    }
+    return b.String()
+}
+
+// hexArguments reads the sep and bytes_per_sep of hex()
+func hexArguments(env *Environment, args []Object, kwargs *Dict) (string, int, *Error) {
+    params, err := parseArgs("hex", args[1:], kwargs, []string{"sep", "bytes_per_sep"}, 0)
+    if err != nil {
+        return "", 0, err
+    }
+    every := 1
+    if params[1] != nil {
+        if every, err = toIndex(env, params[1]); err != nil {
+            return "", 0, err
+        }
+    }
+    if params[0] == nil {
+        return "", every, nil
+    }
+    sep := ""
+    if s, ok := payload(params[0]).(*String); ok {
+        sep = s.Value
+    } else if data, ok, err := bytesLike(params[0]); ok {
+        if err != nil {
+            return "", 0, err
+        }
+        sep = string(data)
+    } else {
+        return "", 0, typeError("sep must be str or bytes.")
+    }
+    if codePointCount(sep) != 1 {
+        return "", 0, valueError("sep must be length 1.")
+    }
+    if sep[0] >= utf8.RuneSelf {
+        return "", 0, valueError("sep must be ASCII.")
+    }
+    return sep, every, nil
+}
+
+// fromHex is bytes.fromhex: pairs of hex digits, whitespace between pairs
+func fromHex(s string) ([]byte, *Error) {
+    text := codePoints(s)
+    data := []byte{}
+    digit := func(i int) (byte, *Error) {
+        if i < len(text) {
+            switch r := text[i]; {
+            case r >= '0' && r <= '9':
+                return byte(r - '0'), nil
+            case r >= 'a' && r <= 'f':
+                return byte(r - 'a' + 10), nil
+            case r >= 'A' && r <= 'F':
+                return byte(r - 'A' + 10), nil
+            }
+        }
+        return 0, valueError("non-hexadecimal number found in fromhex() arg at position %d", i)
+    }
+    for i := 0; i < len(text); i++ {
+        if text[i] < utf8.RuneSelf && isSpaceByte(byte(text[i])) {
+            continue
+        }
+        high, err := digit(i)
+        if err != nil {
+            return nil, err
+        }
+        low, err := digit(i + 1)
+        if err != nil {
+            return nil, err
+        }
+        data = append(data, high<<4|low)
+        i++
+    }
+    return data, nil
+}
+
+// subArgument is what find, count and the rest look for: bytes, or one byte as an int
+func subArgument(env *Environment, obj Object) ([]byte, *Error) {
+    if _, ok := toBigInt(obj); ok || typeOf(obj).lookupName("__index__") != nil {
+        c, err := byteValue(env, obj, "byte must be in range(0, 256)")
+        return []byte{c}, err
+    }
+    data, ok, err := bytesLike(obj)
+    if !ok {
+        return nil, typeError("argument should be integer or bytes-like object, not '%s'", typeName(obj))
+    }
+    return data, err
+}
+
+// indexBytes finds sub in data[start:end], from the left or from the right
+func indexBytes(data, sub []byte, start, end int, last bool) int {
+    if start > len(data) || end-start < len(sub) {
+        return -1
+    }
+    i := bytes.Index(data[start:end], sub)
+    if last {
+        i = bytes.LastIndex(data[start:end], sub)
+    }
+    if i < 0 {
+        return -1
+    }
+    return start + i
+}
+
+// bytesFind is find, rfind, index and rindex
+func bytesFind(env *Environment, args []Object, last, strict bool) Object {
+    data := selfBytes(args[0])
+    sub, err := subArgument(env, args[1])
+    if err != nil {
+        return err
+    }
+    start, end, err := substringBounds(env, args[2:], len(data))
+    if err != nil {
+        return err
+    }
+    i := indexBytes(data, sub, start, end, last)
+    if i < 0 && strict {
+        return valueError("subsection not found")
+    }
+    return newInt(int64(i))
+}
+
+// bytesAffix is startswith and endswith
+func bytesAffix(env *Environment, name string, args []Object, suffix bool) Object {
+    data := selfBytes(args[0])
+    start, end, err := substringBounds(env, args[2:], len(data))
+    if err != nil {
+        return err
+    }
+    choices := []Object{args[1]}
+    if tuple, ok := payload(args[1]).(*Tuple); ok {
+        choices = tuple.Elements
+    } else if _, ok, _ := bytesLike(args[1]); !ok {
+        return typeError("%s first arg must be bytes or a tuple of bytes, not %s", name, typeName(args[1]))
+    }
+    for _, choice := range choices {
+        affix, ok, err := bytesLike(choice)
+        if !ok {
+            return typeError("a bytes-like object is required, not '%s'", typeName(choice))
+        }
+        if err != nil {
+            return err
+        }
+        if start > len(data) || end-len(affix) < start {
+            continue
+        }
+        at := start
+        if suffix {
+            at = end - len(affix)
+        }
+        if bytes.HasPrefix(data[at:end], affix) {
+            return TRUE
+        }
+    }
+    return FALSE
+}
+
+// splitBytesWhitespace is split() with no separator: runs of ASCII whitespace
+func splitBytesWhitespace(data []byte, maxsplit int, last bool) [][]byte {
+    parts := [][]byte{}
+    if last {
+        for i := len(data); ; {
+            for i > 0 && isSpaceByte(data[i-1]) {
+                i--
+            }
+            if i == 0 {
+                break
+            }
+            if maxsplit == 0 {
+                parts = append(parts, data[:i])
+                break
+            }
+            j := i
+            for j > 0 && !isSpaceByte(data[j-1]) {
+                j--
+            }
+            parts = append(parts, data[j:i])
+            maxsplit--
+            i = j
+        }
+        for l, r := 0, len(parts)-1; l < r; l, r = l+1, r-1 {
+            parts[l], parts[r] = parts[r], parts[l]
+        }
+        return parts
+    }
+    for i := 0; ; {
+        for i < len(data) && isSpaceByte(data[i]) {
+            i++
+        }
+        if i == len(data) {
+            break
+        }
+        if maxsplit == 0 {
+            parts = append(parts, data[i:])
+            break
+        }
+        j := i
+        for j < len(data) && !isSpaceByte(data[j]) {
+            j++
+        }
+        parts = append(parts, data[i:j])
+        maxsplit--
+        i = j
+    }
+    return parts
+}
+
+// bytesSplit is split and rsplit
+func bytesSplit(env *Environment, name string, args []Object, kwargs *Dict) Object {
+    params, err := parseArgs(name, args[1:], kwargs, []string{"sep", "maxsplit"}, 0)
+    if err != nil {
+        return err
+    }
+    maxsplit := -1
+    if params[1] != nil {
+        if maxsplit, err = toIndex(env, params[1]); err != nil {
+            return err
+        }
+    }
+    data := selfBytes(args[0])
+    var parts [][]byte
+    if params[0] == nil || params[0] == NULL {
+        parts = splitBytesWhitespace(data, maxsplit, name == "rsplit")
+    } else {
+        sep, err := bytesArgument(params[0])
+        if err != nil {
+            return err
+        }
+        if len(sep) == 0 {
+            return valueError("empty separator")
+        }
+        var pieces []string
+        switch {
+        case name == "rsplit":
+            pieces = rsplitSeparator(string(data), string(sep), maxsplit)
+        case maxsplit < 0:
+            pieces = strings.Split(string(data), string(sep))
+        default:
+            pieces = strings.SplitN(string(data), string(sep), maxsplit+1)
+        }
+        for _, piece := range pieces {
+            parts = append(parts, []byte(piece))
+        }
+    }
+    elements := make([]Object, len(parts))
+    for i, part := range parts {
+        elements[i] = sameKind(args[0], append([]byte{}, part...))
+    }
+    return &List{Elements: elements}
+}
+
+// bytesStrip is strip, lstrip and rstrip
+func bytesStrip(args []Object, left, right bool) Object {
+    data := selfBytes(args[0])
+    cut := isSpaceByte
+    if len(args) > 1 && args[1] != NULL {
+        chars, err := bytesArgument(args[1])
+        if err != nil {
+            return err
+        }
+        cut = func(c byte) bool { return bytes.IndexByte(chars, c) >= 0 }
+    }
+    i, j := 0, len(data)
+    for left && i < j && cut(data[i]) {
+        i++
+    }
+    for right && j > i && cut(data[j-1]) {
+        j--
+    }
+    return sameKind(args[0], append([]byte{}, data[i:j]...))
+}
+
+// replaceBytes is bytes.Replace, except that an empty old fits between
+// every byte rather than every UTF-8 character
+func replaceBytes(data, old, new []byte, count int) []byte {
+    if len(old) > 0 {
+        return bytes.Replace(data, old, new, count)
+    }
+    result := []byte{}
+    for i := 0; i <= len(data); i++ {
+        if count != 0 {
+            result = append(result, new...)
+            count--
+        }
+        if i < len(data) {
+            result = append(result, data[i])
+        }
+    }
+    return result
+}
+
+// bytesPartition is partition and rpartition
+func bytesPartition(args []Object, last bool) Object {
+    data := selfBytes(args[0])
+    sep, err := bytesArgument(args[1])
+    if err != nil {
+        return err
+    }
+    if len(sep) == 0 {
+        return valueError("empty separator")
+    }
+    i := bytes.Index(data, sep)
+    if last {
+        i = bytes.LastIndex(data, sep)
+    }
+    parts := [][]byte{data, nil, nil}
+    switch {
+    case i >= 0:
+        parts = [][]byte{data[:i], sep, data[i+len(sep):]}
+    case last:
+        parts = [][]byte{nil, nil, data}
+    }
+    elements := make([]Object, 3)
+    for i, part := range parts {
+        elements[i] = sameKind(args[0], append([]byte{}, part...))
+    }
+    return &Tuple{Elements: elements}
+}
+
+// mapASCII changes the case of ASCII letters and leaves every other byte alone
+func mapASCII(data []byte, upper bool) []byte {
+    result := make([]byte, len(data))
+    for i, c := range data {
+        switch {
+        case upper && c >= 'a' && c <= 'z':
+            c -= 'a' - 'A'
+        case !upper && c >= 'A' && c <= 'Z':
+            c += 'a' - 'A'
+        }
+        result[i] = c
+    }
+    return result
+}
+
+var bytesPredicates = map[string]func(c byte) bool{
+    "isalpha": func(c byte) bool { return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' },
+    "isdigit": func(c byte) bool { return c >= '0' && c <= '9' },
+    "isalnum": func(c byte) bool { return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' },
+    "isspace": isSpaceByte,
+}
+
+// selectBytes copies out the bytes a slice picks
+func selectBytes(data []byte, start, stop, step int) []byte {
+    result := make([]byte, 0, sliceLength(start, stop, step))
+    for i, n := start, sliceLength(start, stop, step); n > 0; i, n = i+step, n-1 {
+        result = append(result, data[i])
+    }
+    return result
+}
+
+// byteSource reads what extend and slice assignment take: bytes-like, or
+// an iterable of ints
+func byteSource(env *Environment, value Object) ([]byte, *Error) {
+    if data, ok, err := bytesLike(value); ok {
+        return append([]byte{}, data...), err
+    }
+    if typeOf(value).lookupName("__iter__") == nil {
+        return nil, typeError("can assign only bytes, buffers, or iterables of ints in range(0, 256)")
+    }
+    data := []byte{}
+    err := iterate(env, value, func(item Object) *Error {
+        c, err := byteValue(env, item, "byte must be in range(0, 256)")
+        data = append(data, c)
+        return err
+    })
+    return data, err
+}
+
+func init() {
+    initBytes()
+    initByteArray()
+    initMemoryView()
+}
+
+func initBytes() {
+    bytesType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        cls, err := newClassArg("bytes", args)
+        if err != nil {
+            return err
+        }
+        params, err := parseArgs("bytes", args[1:], kwargs, []string{"source", "encoding", "errors"}, 0)
+        if err != nil {
+            return err
+        }
+        data, err := bytesFromObject(env, "bytes", params[0], params[1], params[2])
+        if err != nil {
+            return err
+        }
+        return wrapBuiltinValue(cls, bytesType, &Bytes{Value: data})
+    })
+    bytesType.method("__hash__", 0, 0, func(env *Environment, args []Object) Object {
+        return newInt(hashString(string(asBytes(args[0]).Value)))
+    })
+    bytesType.method("__repr__", 0, 0, func(env *Environment, args []Object) Object {
+        return &String{Value: bytesRepr(asBytes(args[0]).Value)}
+    })
+    initBytesMethods(bytesType, bytesIteratorType)
+}
+
+// initBytesMethods fills in everything bytes and bytearray have in common
+func initBytesMethods(cls, iteratorType *Class) {
+    name := cls.Name
+    cls.method("__len__", 0, 0, func(env *Environment, args []Object) Object {
+        return newInt(int64(len(selfBytes(args[0]))))
+    })
+    cls.method("__iter__", 0, 0, func(env *Environment, args []Object) Object {
+        self, i := args[0], 0
+        return newIterator(iteratorType, func(env *Environment) (Object, bool) {
+            data := selfBytes(self)
+            if i >= len(data) {
+                return nil, false
+            }
+            i++
+            return newInt(int64(data[i-1])), true
+        })
+    })
+    cls.method("__getitem__", 1, 1, func(env *Environment, args []Object) Object {
+        data := selfBytes(args[0])
+        if s, ok := args[1].(*Slice); ok {
+            start, stop, step, err := s.indices(env, len(data))
+            if err != nil {
+                return err
+            }
+            return sameKind(args[0], selectBytes(data, start, stop, step))
+        }
+        what := name
+        if cls == bytesType {
+            what = "byte"
+        }
+        i, err := sequenceIndex(env, args[1], len(data), what)
+        if err != nil {
+            if err.matches(indexErrorType) && cls == bytesType {
+                return indexError("index out of range")
+            }
+            return err
+        }
+        return newInt(int64(data[i]))
+    })
+    cls.method("__contains__", 1, 1, func(env *Environment, args []Object) Object {
+        data := selfBytes(args[0])
+        if _, ok := toBigInt(args[1]); ok || typeOf(args[1]).lookupName("__index__") != nil {
+            c, err := byteValue(env, args[1], "byte must be in range(0, 256)")
+            if err != nil {
+                return err
+            }
+            return nativeBool(bytes.IndexByte(data, c) >= 0)
+        }
+        sub, err := bytesArgument(args[1])
+        if err != nil {
+            return err
+        }
+        return nativeBool(bytes.Contains(data, sub))
+    })
+    cls.method("__add__", 1, 1, func(env *Environment, args []Object) Object {
+        other, ok, err := bytesLike(args[1])
+        if !ok {
+            return typeError("can't concat %s to %s", typeName(args[1]), typeName(args[0]))
+        }
+        if err != nil {
+            return err
+        }
+        return sameKind(args[0], append(append([]byte{}, selfBytes(args[0])...), other...))
+    })
+    for _, method := range []string{"__mul__", "__rmul__"} {
+        cls.method(method, 1, 1, func(env *Environment, args []Object) Object {
+            n, result := repeatCount(env, args[1])
+            if result != nil {
+                return result
+            }
+            data := selfBytes(args[0])
+            if _, err := allocation(n, len(data)); err != nil {
+                return err
+            }
+            return sameKind(args[0], bytes.Repeat(data, n))
+        })
+    }
+    for _, operator := range []string{"==", "!=", "<", "<=", ">", ">="} {
+        operator := operator
+        cls.method(comparisonOperators[operator][0], 1, 1, func(env *Environment, args []Object) Object {
+            var other []byte
+            switch o := payload(args[1]).(type) {
+            case *Bytes:
+                other = o.Value
+            case *ByteArray:
+                other = o.Value
+            default:
+                return NotImplemented
+            }
+            return compareResult(operator, bytes.Compare(selfBytes(args[0]), other))
+        })
+    }
+
+    cls.define("decode", func(env *Environment, args []Object, kwargs *Dict) Object {
+        params, err := parseArgs("decode", args[1:], kwargs, []string{"encoding", "errors"}, 0)
+        if err != nil {
+            return err
+        }
+        options, err := codecOptions("decode", params)
+        if err != nil {
+            return err
+        }
+        return decodeBytes(selfBytes(args[0]), options[0], options[1])
+    })
+    cls.define("hex", func(env *Environment, args []Object, kwargs *Dict) Object {
+        sep, every, err := hexArguments(env, args, kwargs)
+        if err != nil {
+            return err
+        }
+        return &String{Value: hexString(selfBytes(args[0]), sep, every)}
+    })
+    cls.Dict.SetStr("fromhex", &ClassMethod{Function: &Builtin{Name: "fromhex", Fn: func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("fromhex", args[1:], kwargs, 1, 1); err != nil {
+            return err
+        }
+        s, ok := payload(args[1]).(*String)
+        if !ok {
+            return typeError("fromhex() argument must be str, not %s", typeName(args[1]))
+        }
+        data, err := fromHex(s.Value)
+        if err != nil {
+            return err
+        }
+        switch args[0] {
+        case bytesType:
+            return &Bytes{Value: data}
+        case bytearrayType:
+            return &ByteArray{Value: data}
+        }
+        // subclasses get built from the result, like CPython does
+        return applyFunction(env, args[0], []Object{&Bytes{Value: data}}, nil)
+    }}, Dict: NewDict()})
+
+    for _, method := range []string{"find", "rfind", "index", "rindex"} {
+        last, strict := method[0] == 'r', strings.HasSuffix(method, "index")
+        cls.method(method, 1, 3, func(env *Environment, args []Object) Object {
+            return bytesFind(env, args, last, strict)
+        })
+    }
+    cls.method("count", 1, 3, func(env *Environment, args []Object) Object {
+        data := selfBytes(args[0])
+        sub, err := subArgument(env, args[1])
+        if err != nil {
+            return err
+        }
+        start, end, err := substringBounds(env, args[2:], len(data))
+        if err != nil {
+            return err
+        }
+        switch {
+        case start > len(data) || end < start:
+            return newInt(0)
+        case len(sub) == 0:
+            return newInt(int64(end - start + 1))
+        }
+        return newInt(int64(bytes.Count(data[start:end], sub)))
+    })
+    cls.method("startswith", 1, 3, func(env *Environment, args []Object) Object {
+        return bytesAffix(env, "startswith", args, false)
+    })
+    cls.method("endswith", 1, 3, func(env *Environment, args []Object) Object {
+        return bytesAffix(env, "endswith", args, true)
+    })
+    cls.method("join", 1, 1, func(env *Environment, args []Object) Object {
+        items, err := iterableToSlice(env, args[1])
+        if err != nil {
+            return err
+        }
+        parts := make([][]byte, len(items))
+        for i, item := range items {
+            data, ok, err := bytesLike(item)
+            if !ok {
+                return typeError("sequence item %d: expected a bytes-like object, %s found", i, typeName(item))
+            }
+            if err != nil {
+                return err
+            }
+            parts[i] = data
+        }
+        return sameKind(args[0], bytes.Join(parts, selfBytes(args[0])))
+    })
+    cls.define("split", func(env *Environment, args []Object, kwargs *Dict) Object {
+        return bytesSplit(env, "split", args, kwargs)
+    })
+    cls.define("rsplit", func(env *Environment, args []Object, kwargs *Dict) Object {
+        return bytesSplit(env, "rsplit", args, kwargs)
+    })
+    cls.method("strip", 0, 1, func(env *Environment, args []Object) Object {
+        return bytesStrip(args, true, true)
+    })
+    cls.method("lstrip", 0, 1, func(env *Environment, args []Object) Object {
+        return bytesStrip(args, true, false)
+    })
+    cls.method("rstrip", 0, 1, func(env *Environment, args []Object) Object {
+        return bytesStrip(args, false, true)
+    })
+    cls.method("replace", 2, 3, func(env *Environment, args []Object) Object {
+        old, err := bytesArgument(args[1])
+        if err != nil {
+            return err
+        }
+        new, err := bytesArgument(args[2])
+        if err != nil {
+            return err
+        }
+        count := -1
+        if len(args) > 3 {
+            if count, err = toIndex(env, args[3]); err != nil {
+                return err
+            }
+        }
+        return sameKind(args[0], replaceBytes(selfBytes(args[0]), old, new, count))
+    })
+    cls.method("partition", 1, 1, func(env *Environment, args []Object) Object {
+        return bytesPartition(args, false)
+    })
+    cls.method("rpartition", 1, 1, func(env *Environment, args []Object) Object {
+        return bytesPartition(args, true)
+    })
+    cls.method("removeprefix", 1, 1, func(env *Environment, args []Object) Object {
+        prefix, err := bytesArgument(args[1])
+        if err != nil {
+            return err
+        }
+        return sameKind(args[0], append([]byte{}, bytes.TrimPrefix(selfBytes(args[0]), prefix)...))
+    })
+    cls.method("removesuffix", 1, 1, func(env *Environment, args []Object) Object {
+        suffix, err := bytesArgument(args[1])
+        if err != nil {
+            return err
+        }
+        return sameKind(args[0], append([]byte{}, bytes.TrimSuffix(selfBytes(args[0]), suffix)...))
+    })
+    cls.method("upper", 0, 0, func(env *Environment, args []Object) Object {
+        return sameKind(args[0], mapASCII(selfBytes(args[0]), true))
+    })
+    cls.method("lower", 0, 0, func(env *Environment, args []Object) Object {
+        return sameKind(args[0], mapASCII(selfBytes(args[0]), false))
+    })
+    for method, predicate := range bytesPredicates {
+        predicate := predicate
+        cls.method(method, 0, 0, func(env *Environment, args []Object) Object {
+            data := selfBytes(args[0])
+            for _, c := range data {
+                if !predicate(c) {
+                    return FALSE
+                }
+            }
+            return nativeBool(len(data) > 0)
+        })
+    }
+    for _, method := range []string{"islower", "isupper"} {
+        upper := method == "isupper"
+        cls.method(method, 0, 0, func(env *Environment, args []Object) Object {
+            data := selfBytes(args[0])
+            // cased means the case mapping the other way changes something
+            other := mapASCII(data, !upper)
+            return nativeBool(bytes.Equal(mapASCII(data, upper), data) && !bytes.Equal(other, data))
+        })
+    }
+}
+
+func initByteArray() {
+    bytearrayType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        cls, err := newClassArg("bytearray", args)
+        if err != nil {
+            return err
+        }
+        return wrapBuiltinValue(cls, bytearrayType, &ByteArray{Value: []byte{}})
+    })
+    bytearrayType.define("__init__", func(env *Environment, args []Object, kwargs *Dict) Object {
+        params, err := parseArgs("bytearray", args[1:], kwargs, []string{"source", "encoding", "errors"}, 0)
+        if err != nil {
+            return err
+        }
+        data, err := bytesFromObject(env, "bytearray", params[0], params[1], params[2])
+        if err != nil {
+            return err
+        }
+        asByteArray(args[0]).Value = data
+        return NULL
+    })
+    bytearrayType.Dict.SetStr("__hash__", NULL)
+    bytearrayType.method("__repr__", 0, 0, func(env *Environment, args []Object) Object {
+        return &String{Value: typeName(args[0]) + "(" + bytesRepr(asByteArray(args[0]).Value) + ")"}
+    })
+    initBytesMethods(bytearrayType, bytearrayIteratorType)
+
+    bytearrayType.method("__setitem__", 2, 2, func(env *Environment, args []Object) Object {
+        ba := asByteArray(args[0])
+        if s, ok := args[1].(*Slice); ok {
+            if _, ok := payload(args[2]).(*String); ok {
+                return typeError("can assign only bytes, buffers, or iterables of ints in range(0, 256)")
+            }
+            data, err := byteSource(env, args[2])
+            if err != nil {
+                return err
+            }
+            start, stop, step, err := s.indices(env, len(ba.Value))
+            if err != nil {
+                return err
+            }
+            if step == 1 {
+                stop = max(stop, start)
+                ba.Value = append(append(append([]byte{}, ba.Value[:start]...), data...), ba.Value[stop:]...)
+                return NULL
+            }
+            n := sliceLength(start, stop, step)
+            if len(data) != n {
+                return valueError("attempt to assign bytes of size %d to extended slice of size %d", len(data), n)
+            }
+            for i, k := start, 0; k < n; i, k = i+step, k+1 {
+                ba.Value[i] = data[k]
+            }
+            return NULL
+        }
+        i, err := sequenceIndex(env, args[1], len(ba.Value), "bytearray")
+        if err != nil {
+            return err
+        }
+        c, err := byteValue(env, args[2], "byte must be in range(0, 256)")
+        if err != nil {
+            return err
+        }
+        ba.Value[i] = c
+        return NULL
+    })
+    bytearrayType.method("__delitem__", 1, 1, func(env *Environment, args []Object) Object {
+        ba := asByteArray(args[0])
+        if s, ok := args[1].(*Slice); ok {
+            start, stop, step, err := s.indices(env, len(ba.Value))
+            if err != nil {
+                return err
+            }
+            drop := map[int]bool{}
+            for i, n := start, sliceLength(start, stop, step); n > 0; i, n = i+step, n-1 {
+                drop[i] = true
+            }
+            kept := []byte{}
+            for i, c := range ba.Value {
+                if !drop[i] {
+                    kept = append(kept, c)
+                }
+            }
+            ba.Value = kept
+            return NULL
+        }
+        i, err := sequenceIndex(env, args[1], len(ba.Value), "bytearray")
+        if err != nil {
+            return err
+        }
+        ba.Value = append(ba.Value[:i:i], ba.Value[i+1:]...)
+        return NULL
+    })
+    bytearrayType.method("__iadd__", 1, 1, func(env *Environment, args []Object) Object {
+        other, ok, err := bytesLike(args[1])
+        if !ok {
+            return typeError("can't concat %s to %s", typeName(args[1]), typeName(args[0]))
+        }
+        if err != nil {
+            return err
+        }
+        ba := asByteArray(args[0])
+        ba.Value = append(ba.Value, other...)
+        return args[0]
+    })
+    bytearrayType.method("__imul__", 1, 1, func(env *Environment, args []Object) Object {
+        n, result := repeatCount(env, args[1])
+        if result != nil {
+            return result
+        }
+        ba := asByteArray(args[0])
+        if _, err := allocation(n, len(ba.Value)); err != nil {
+            return err
+        }
+        ba.Value = bytes.Repeat(ba.Value, n)
+        return args[0]
+    })
+    bytearrayType.method("append", 1, 1, func(env *Environment, args []Object) Object {
+        c, err := byteValue(env, args[1], "byte must be in range(0, 256)")
+        if err != nil {
+            return err
+        }
+        ba := asByteArray(args[0])
+        ba.Value = append(ba.Value, c)
+        return NULL
+    })
+    bytearrayType.method("extend", 1, 1, func(env *Environment, args []Object) Object {
+        data, err := byteSource(env, args[1])
+        if err != nil {
+            if typeOf(args[1]).lookupName("__iter__") == nil {
+                return typeError("can't extend bytearray with %s", typeName(args[1]))
+            }
+            return err
+        }
+        ba := asByteArray(args[0])
+        ba.Value = append(ba.Value, data...)
+        return NULL
+    })
+    bytearrayType.method("insert", 2, 2, func(env *Environment, args []Object) Object {
+        ba := asByteArray(args[0])
+        i, err := toIndex(env, args[1])
+        if err != nil {
+            return err
+        }
+        c, err := byteValue(env, args[2], "byte must be in range(0, 256)")
+        if err != nil {
+            return err
+        }
+        n := len(ba.Value)
+        if i < 0 {
+            i = max(i+n, 0)
+        }
+        i = min(i, n)
+        ba.Value = append(ba.Value, 0)
+        copy(ba.Value[i+1:], ba.Value[i:])
+        ba.Value[i] = c
+        return NULL
+    })
+    bytearrayType.method("pop", 0, 1, func(env *Environment, args []Object) Object {
+        ba := asByteArray(args[0])
+        if len(ba.Value) == 0 {
+            return indexError("pop from empty bytearray")
+        }
+        i := len(ba.Value) - 1
+        if len(args) > 1 {
+            var err *Error
+            if i, err = sequenceIndex(env, args[1], len(ba.Value), "pop"); err != nil {
+                return err
+            }
+        }
+        c := ba.Value[i]
+        ba.Value = append(ba.Value[:i:i], ba.Value[i+1:]...)
+        return newInt(int64(c))
+    })
+    bytearrayType.method("remove", 1, 1, func(env *Environment, args []Object) Object {
+        c, err := byteValue(env, args[1], "byte must be in range(0, 256)")
+        if err != nil {
+            return err
+        }
+        ba := asByteArray(args[0])
+        i := bytes.IndexByte(ba.Value, c)
+        if i < 0 {
+            return valueError("value not found in bytearray")
+        }
+        ba.Value = append(ba.Value[:i:i], ba.Value[i+1:]...)
+        return NULL
+    })
+    bytearrayType.method("clear", 0, 0, func(env *Environment, args []Object) Object {
+        asByteArray(args[0]).Value = []byte{}
+        return NULL
+    })
+    bytearrayType.method("copy", 0, 0, func(env *Environment, args []Object) Object {
+        return &ByteArray{Value: append([]byte{}, asByteArray(args[0]).Value...)}
+    })
+    bytearrayType.method("reverse", 0, 0, func(env *Environment, args []Object) Object {
+        data := asByteArray(args[0]).Value
+        for l, r := 0, len(data)-1; l < r; l, r = l+1, r-1 {
+            data[l], data[r] = data[r], data[l]
+        }
+        return NULL
+    })
+}
+
+func (m *MemoryView) attribute(name string) (Object, bool) {
+    switch name {
+    case "obj", "readonly", "nbytes", "itemsize", "format", "ndim", "shape", "strides", "contiguous", "c_contiguous":
+        if err := m.check(); err != nil {
+            return err, true
+        }
+    }
+    switch name {
+    case "obj":
+        return m.source, true
+    case "readonly":
+        return nativeBool(m.readonly()), true
+    case "nbytes":
+        return newInt(int64(m.length)), true
+    case "itemsize", "ndim":
+        return newInt(1), true
+    case "format":
+        return &String{Value: "B"}, true
+    case "shape":
+        return &Tuple{Elements: []Object{newInt(int64(m.length))}}, true
+    case "strides":
+        return &Tuple{Elements: []Object{newInt(int64(m.step))}}, true
+    case "contiguous", "c_contiguous":
+        return nativeBool(m.step == 1 || m.length <= 1), true
+    case "released":
+        return nativeBool(m.released), true
+    }
+    return nil, false
+}
+
+func initMemoryView() {
+    memoryviewType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        if _, err := newClassArg("memoryview", args); err != nil {
+            return err
+        }
+        params, err := parseArgs("memoryview", args[1:], kwargs, []string{"object"}, 1)
+        if err != nil {
+            return err
+        }
+        switch source := payload(params[0]).(type) {
+        case *Bytes:
+            return &MemoryView{source: source, step: 1, length: len(source.Value)}
+        case *ByteArray:
+            return &MemoryView{source: source, step: 1, length: len(source.Value)}
+        case *MemoryView:
+            if err := source.check(); err != nil {
+                return err
+            }
+            view := *source
+            return &view
+        }
+        return typeError("memoryview: a bytes-like object is required, not '%s'", typeName(params[0]))
+    })
+    // every method starts by making sure the view is still open
+    method := func(name string, min, max int, fn func(env *Environment, m *MemoryView, args []Object) Object) {
+        memoryviewType.method(name, min, max, func(env *Environment, args []Object) Object {
+            m := asMemoryView(args[0])
+            if err := m.check(); err != nil {
+                return err
+            }
+            return fn(env, m, args)
+        })
+    }
+    method("__len__", 0, 0, func(env *Environment, m *MemoryView, args []Object) Object {
+        return newInt(int64(m.length))
+    })
+    method("__getitem__", 1, 1, func(env *Environment, m *MemoryView, args []Object) Object {
+        if s, ok := args[1].(*Slice); ok {
+            start, stop, step, err := s.indices(env, m.length)
+            if err != nil {
+                return err
+            }
+            return &MemoryView{source: m.source, start: m.start + start*m.step, step: m.step * step, length: sliceLength(start, stop, step)}
+        }
+        if _, ok := toBigInt(args[1]); !ok && typeOf(args[1]).lookupName("__index__") == nil {
+            return typeError("memoryview: invalid slice key")
+        }
+        i, err := sequenceIndex(env, args[1], m.length, "memoryview")
+        if err != nil {
+            return indexError("index out of bounds on dimension 1")
+        }
+        at, err := m.position(i)
+        if err != nil {
+            return err
+        }
+        return newInt(int64(m.buffer()[at]))
+    })
+    method("__setitem__", 2, 2, func(env *Environment, m *MemoryView, args []Object) Object {
+        if m.readonly() {
+            return typeError("cannot modify read-only memory")
+        }
+        if s, ok := args[1].(*Slice); ok {
+            data, err := bytesArgument(args[2])
+            if err != nil {
+                return err
+            }
+            data = append([]byte{}, data...)
+            start, stop, step, err := s.indices(env, m.length)
+            if err != nil {
+                return err
+            }
+            if len(data) != sliceLength(start, stop, step) {
+                return valueError("memoryview assignment: lvalue and rvalue have different structures")
+            }
+            for i, k := start, 0; k < len(data); i, k = i+step, k+1 {
+                at, err := m.position(i)
+                if err != nil {
+                    return err
+                }
+                m.buffer()[at] = data[k]
+            }
+            return NULL
+        }
+        if _, ok := toBigInt(args[1]); !ok && typeOf(args[1]).lookupName("__index__") == nil {
+            return typeError("memoryview: invalid slice key")
+        }
+        i, err := sequenceIndex(env, args[1], m.length, "memoryview")
+        if err != nil {
+            return indexError("index out of bounds on dimension 1")
+        }
+        if _, ok := toBigInt(args[2]); !ok {
+            return typeError("memoryview: invalid type for format 'B'")
+        }
+        c, err := byteValue(env, args[2], "memoryview: invalid value for format 'B'")
+        if err != nil {
+            return err
+        }
+        at, err := m.position(i)
+        if err != nil {
+            return err
+        }
+        m.buffer()[at] = c
+        return NULL
+    })
+    method("__delitem__", 1, 1, func(env *Environment, m *MemoryView, args []Object) Object {
+        return typeError("cannot delete memory")
+    })
+    method("__iter__", 0, 0, func(env *Environment, m *MemoryView, args []Object) Object {
+        i := 0
+        return newIterator(memoryIteratorType, func(env *Environment) (Object, bool) {
+            if i >= m.length || m.released {
+                return nil, false
+            }
+            at, err := m.position(i)
+            if err != nil {
+                return nil, false
+            }
+            i++
+            return newInt(int64(m.buffer()[at])), true
+        })
+    })
+    for _, operator := range []string{"==", "!="} {
+        operator := operator
+        memoryviewType.method(comparisonOperators[operator][0], 1, 1, func(env *Environment, args []Object) Object {
+            m := asMemoryView(args[0])
+            if args[0] == args[1] {
+                return compareResult(operator, 0)
+            }
+            other, ok, err := bytesLike(args[1])
+            if !ok {
+                return NotImplemented
+            }
+            // a released view only equals itself
+            if err != nil || m.released {
+                return compareResult(operator, 1)
+            }
+            data, err := m.bytes()
+            if err != nil {
+                return err
+            }
+            return compareResult(operator, bytes.Compare(data, other))
+        })
+    }
+    method("__hash__", 0, 0, func(env *Environment, m *MemoryView, args []Object) Object {
+        if !m.readonly() {
+            return valueError("cannot hash writable memoryview object")
+        }
+        data, err := m.bytes()
+        if err != nil {
+            return err
+        }
+        return newInt(hashString(string(data)))
+    })
+    memoryviewType.method("__repr__", 0, 0, func(env *Environment, args []Object) Object {
+        return &String{Value: asMemoryView(args[0]).Inspect()}
+    })
+    method("tobytes", 0, 0, func(env *Environment, m *MemoryView, args []Object) Object {
+        data, err := m.bytes()
+        if err != nil {
+            return err
+        }
+        return &Bytes{Value: data}
+    })
+    method("tolist", 0, 0, func(env *Environment, m *MemoryView, args []Object) Object {
+        data, err := m.bytes()
+        if err != nil {
+            return err
+        }
+        elements := make([]Object, len(data))
+        for i, c := range data {
+            elements[i] = newInt(int64(c))
+        }
+        return &List{Elements: elements}
+    })
+    memoryviewType.define("hex", func(env *Environment, args []Object, kwargs *Dict) Object {
+        data, err := asMemoryView(args[0]).bytes()
+        if err != nil {
+            return err
+        }
+        sep, every, err := hexArguments(env, args, kwargs)
+        if err != nil {
+            return err
+        }
+        return &String{Value: hexString(data, sep, every)}
+    })
+    memoryviewType.method("release", 0, 0, func(env *Environment, args []Object) Object {
+        asMemoryView(args[0]).released = true
+        return NULL
+    })
+    method("__enter__", 0, 0, func(env *Environment, m *MemoryView, args []Object) Object {
+        return args[0]
+    })
+    memoryviewType.method("__exit__", 3, 3, func(env *Environment, args []Object) Object {
+        asMemoryView(args[0]).released = true
+        return FALSE
+    })
+}
@@ -0,0 +1,1033 @@
+// Comments in this file are inspired by Jessica Pearson - she built the firm, I built the classes
+
+package evaluator
+
+import (
+    "fmt"
+    "interpreter/parser"
+    "reflect"
+    "strings"
+)
+
+// Class is a Python type. Builtin types and user classes are the same thing here.
+type Class struct {
+    Name  string
+    Bases []*Class
+    MRO   []*Class
+    Dict  *Dict
+    // virtual subclasses, for ABCs that let classes register()
+    virtual []*Class
+    // written in Go, so Python code can't reach in and change it
+    native bool
+}
+
+func (c *Class) Type() ObjectType { return CLASS_OBJ }
+func (c *Class) Inspect() string  {
+    if module := c.module(); module != "builtins" {
+        return fmt.Sprintf("<class '%s.%s'>", module, c.qualname())
+    }
+    return fmt.Sprintf("<class '%s'>", c.Name)
+}
+
+func (c *Class) module() string {
+    if module, ok := c.Dict.GetStr("__module__"); ok {
+        if s, ok := module.(*String); ok {
+            return s.Value
+        }
+    }
+    return "builtins"
+}
+
+func (c *Class) qualname() string {
+    if qualname, ok := c.Dict.GetStr("__qualname__"); ok {
+        if s, ok := qualname.(*String); ok {
+            return s.Value
+        }
+    }
+    return c.Name
+}
+
+// lookup walks the MRO. The first one to answer wins.
+func (c *Class) lookup(name string) (Object, *Class) {
+    for _, cls := range c.MRO {
+        if value, ok := cls.Dict.GetStr(name); ok {
+            return value, cls
+        }
+    }
+    return nil, nil
+}
+
+func (c *Class) lookupName(name string) Object {
+    value, _ := c.lookup(name)
+    return value
+}
+
+func (c *Class) isSubclass(other *Class) bool {
+    if c == other {
+        return true
+    }
+    for _, cls := range c.MRO {
+        if cls == other {
+            return true
+        }
+    }
+    return false
+}
+
+// Instance of a user class. Value carries the builtin payload when the class
+// derives from something like list or int.
+type Instance struct {
+    Class *Class
+    Dict  *Dict
+    Value Object
+}
+
+func (i *Instance) Type() ObjectType { return INSTANCE_OBJ }
+func (i *Instance) Inspect() string  { return defaultRepr(i) }
+
+// Function is a def or a lambda. It remembers where it was born.
+type Function struct {
+    Name       string
+    Qualname   string
+    Signature  *parser.Signature
+    Defaults   []Object // evaluated once, when the def ran
+    KwDefaults *Dict    // keyword-only defaults, nil when there are none
+    Body       []parser.Statement
+    Env        *Environment
+    Scope      *parser.Scope // where each of the body's names lives
+    Owner      *Environment  // the class body it was written in, for super()
+    Dict       *Dict
+    Generator  bool   // calling it makes a generator
+    Doc        Object // the docstring, if the body opened with one
+}
+
+func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
+func (f *Function) Inspect() string  {
+    qualname := f.Qualname
+    if f.Dict != nil {
+        if value, ok := f.Dict.GetStr("__qualname__"); ok {
+            if s, ok := value.(*String); ok {
+                qualname = s.Value // functools.wraps got here first
+            }
+        }
+    }
+    return fmt.Sprintf("<function %s at %#x>", qualname, objectID(f))
+}
+
+// BoundMethod glues a function to the object it was looked up on
+type BoundMethod struct {
+    Self     Object
+    Function Object
+}
+
+func (m *BoundMethod) Type() ObjectType { return BOUND_METHOD_OBJ }
+func (m *BoundMethod) Inspect() string  {
+    switch fn := m.Function.(type) {
+    case *Builtin:
+        if fn.Wraps != nil {
+            return fmt.Sprintf("<bound method %s of %s>", wrappedName(fn.Wraps, "__qualname__", fn.Name), m.Self.Inspect())
+        }
+        return fmt.Sprintf("<built-in method %s of %s object at %#x>", fn.Name, typeName(m.Self), objectID(m.Self))
+    case *Function:
+        return fmt.Sprintf("<bound method %s of %s>", fn.Qualname, m.Self.Inspect())
+    }
+    return fmt.Sprintf("<bound method of %s>", m.Self.Inspect())
+}
+
+// Super proxies attribute lookups to the classes after Class in Self's MRO
+type Super struct {
+    Class     *Class
+    Self      Object
+    SelfClass *Class
+}
+
+func (s *Super) Type() ObjectType { return SUPER_OBJ }
+func (s *Super) Inspect() string  {
+    return fmt.Sprintf("<super: <class '%s'>, <%s object>>", s.Class.Name, s.SelfClass.Name)
+}
+
+// The builtin types. Every one of them answers to object, eventually.
+var (
+    objectType         = newBuiltinClass("object")
+    typeType           = newBuiltinClass("type", objectType)
+    intType            = newBuiltinClass("int", objectType)
+    boolType           = newBuiltinClass("bool", intType)
+    floatType          = newBuiltinClass("float", objectType)
+    strType            = newBuiltinClass("str", objectType)
+    listType           = newBuiltinClass("list", objectType)
+    tupleType          = newBuiltinClass("tuple", objectType)
+    dictType           = newBuiltinClass("dict", objectType)
+    setType            = newBuiltinClass("set", objectType)
+    frozensetType      = newBuiltinClass("frozenset", objectType)
+    noneType           = newBuiltinClass("NoneType", objectType)
+    notImplementedType = newBuiltinClass("NotImplementedType", objectType)
+    functionType       = newBuiltinClass("function", objectType)
+    builtinType        = newBuiltinClass("builtin_function_or_method", objectType)
+    methodType         = newBuiltinClass("method", objectType)
+    superType          = newBuiltinClass("super", objectType)
+    sliceType          = newBuiltinClass("slice", objectType)
+)
+
+func newBuiltinClass(name string, bases ...*Class) *Class {
+    cls := &Class{Name: name, Bases: bases, Dict: NewDict(), native: true}
+    cls.MRO, _ = linearize(cls)
+    return cls
+}
+
+// typeOf tells you who you're really dealing with
+func typeOf(obj Object) *Class {
+    switch obj := obj.(type) {
+    case *Instance:
+        return obj.Class
+    case *Exception:
+        return obj.Class
+    case *Integer:
+        return intType
+    case *Boolean:
+        return boolType
+    case *Float:
+        return floatType
+    case *String:
+        return strType
+    case *Bytes:
+        return bytesType
+    case *ByteArray:
+        return bytearrayType
+    case *MemoryView:
+        return memoryviewType
+    case *Complex:
+        return complexType
+    case *NullObject:
+        return noneType
+    case *List:
+        return listType
+    case *Tuple:
+        return tupleType
+    case *Dict:
+        return dictType
+    case *Set:
+        if obj.Frozen {
+            return frozensetType
+        }
+        return setType
+    case *Iter:
+        return obj.class
+    case *DictView:
+        return obj.class
+    case *Slice:
+        return sliceType
+    case *Class:
+        return typeType
+    case *Function:
+        return functionType
+    case *Builtin:
+        if obj.Wraps != nil {
+            return functionType
+        }
+        return builtinType
+    case *BoundMethod:
+        return methodType
+    case *Super:
+        return superType
+    case *NotImplementedObject:
+        return notImplementedType
+    case *Traceback:
+        return tracebackType
+    case *Frame:
+        return frameType
+    case *Generator:
+        return generatorType
+    case *StaticMethod:
+        return staticmethodType
+    case *ClassMethod:
+        return classmethodType
+    case *Property:
+        return propertyType
+    case *Module:
+        return moduleType
+    case *File:
+        return obj.class
+    case *Range:
+        return rangeType
+    case *RegexPattern:
+        return patternType
+    case *RegexMatch:
+        return matchType
+    case *Deque:
+        return dequeType
+    case *Partial:
+        return partialType
+    case *TimeDelta:
+        return timedeltaType
+    case *Date:
+        return dateType
+    case *TimeOfDay:
+        return timeOfDayType
+    case *DateTime:
+        return datetimeType
+    case *TimeZone:
+        return timezoneType
+    case *ZoneInfo:
+        return zoneInfoType
+    case *Random:
+        if obj.system {
+            return systemRandomType
+        }
+        return randomType
+    }
+    return objectType
+}
+
+func typeName(obj Object) string {
+    return typeOf(obj).Name
+}
+
+// payload unwraps instances of builtin subclasses to the builtin value inside
+func payload(obj Object) Object {
+    if instance, ok := obj.(*Instance); ok && instance.Value != nil {
+        return instance.Value
+    }
+    return obj
+}
+
+// objectID is the identity of an object - its address, like CPython
+func objectID(obj Object) int64 {
+    value := reflect.ValueOf(obj)
+    if value.Kind() == reflect.Ptr {
+        return int64(value.Pointer())
+    }
+    return 0
+}
+
+// computeMRO is C3 linearization. Order matters - ask anyone at Pearson Hardman.
+func computeMRO(cls *Class) ([]*Class, *Error) {
+    mro, ok := linearize(cls)
+    if !ok {
+        names := []string{}
+        for _, base := range cls.Bases {
+            names = append(names, base.Name)
+        }
+        return nil, typeError("Cannot create a consistent method resolution order (MRO) for bases %s",
+            strings.Join(names, ", "))
+    }
+    return mro, nil
+}
+
+// linearize does the C3 merge; builtin types use it directly, before errors exist
+func linearize(cls *Class) ([]*Class, bool) {
+    sequences := [][]*Class{}
+    for _, base := range cls.Bases {
+        sequences = append(sequences, append([]*Class{}, base.MRO...))
+    }
+    sequences = append(sequences, append([]*Class{}, cls.Bases...))
+
+    mro := []*Class{cls}
+    for {
+        empty := true
+        for _, seq := range sequences {
+            if len(seq) > 0 {
+                empty = false
+            }
+        }
+        if empty {
+            return mro, true
+        }
+
+        var candidate *Class
+        for _, seq := range sequences {
+            if len(seq) == 0 {
+                continue
+            }
+            head := seq[0]
+            inTail := false
+            for _, other := range sequences {
+                for _, c := range other[min(1, len(other)):] {
+                    if c == head {
+                        inTail = true
+                    }
+                }
+            }
+            if !inTail {
+                candidate = head
+                break
+            }
+        }
+        if candidate == nil {
+            return nil, false
+        }
+
+        mro = append(mro, candidate)
+        for i, seq := range sequences {
+            if len(seq) > 0 && seq[0] == candidate {
+                sequences[i] = seq[1:]
+            }
+        }
+    }
+}
+
+// newClass builds a class out of a name, its bases and its namespace
+func newClass(name string, bases []*Class, dict *Dict) (*Class, *Error) {
+    if len(bases) == 0 {
+        bases = []*Class{objectType}
+    }
+    cls := &Class{Name: name, Bases: bases, Dict: dict}
+    if _, ok := dict.GetStr("__doc__"); !ok {
+        dict.SetStr("__doc__", NULL) // a docstring isn't inherited
+    }
+    mro, err := computeMRO(cls)
+    if err != nil {
+        return nil, err
+    }
+    cls.MRO = mro
+
+    // Defining __eq__ without __hash__ makes instances unhashable, same as Python
+    if _, ok := dict.GetStr("__eq__"); ok {
+        if _, ok := dict.GetStr("__hash__"); !ok {
+            dict.SetStr("__hash__", NULL)
+        }
+    }
+    return cls, nil
+}
+
+func evalClassDefinition(node *parser.ClassDefinition, env *Environment) Object {
+    decorators := evalExpressions(node.Decorators, env)
+    if len(decorators) == 1 && isError(decorators[0]) {
+        return decorators[0]
+    }
+    bases := []*Class{}
+    for _, baseNode := range node.Bases {
+        base := Eval(baseNode, env)
+        if isError(base) {
+            return base
+        }
+        cls, ok := base.(*Class)
+        if !ok {
+            return typeError("bases must be types")
+        }
+        if cls == boolType || cls == noneType || cls == functionType {
+            return typeError("type '%s' is not an acceptable base type", cls.Name)
+        }
+        bases = append(bases, cls)
+    }
+    if len(node.Keywords) > 0 {
+        return typeError("%s.__init_subclass__() takes no keyword arguments", node.Name)
+    }
+
+    classEnv := NewEnclosedEnvironment(env)
+    classEnv.classScope = true
+    classEnv.scope = node.Scope
+    classEnv.Set("__module__", &String{Value: env.moduleName()})
+    classEnv.Set("__qualname__", &String{Value: qualifiedName(node.Name, env)})
+    if doc := docstring(node.Body); doc != nil {
+        classEnv.Set("__doc__", doc)
+    }
+
+    result := runFrame(classEnv, node.Name, func() Object { return evalBlock(node.Body, classEnv) })
+    if isError(result) {
+        return result
+    }
+
+    dict := NewDict()
+    for _, name := range classEnv.Names() {
+        dict.SetStr(name, classEnv.store[name])
+    }
+    cls, err := newClass(node.Name, bases, dict)
+    if err != nil {
+        return err
+    }
+    classEnv.class = cls
+    if err := setNames(env, cls); err != nil {
+        return err
+    }
+
+    decorated := decorate(env, node.Decorators, decorators, cls)
+    if isError(decorated) {
+        return decorated
+    }
+    env.bind(node.Name, decorated)
+    return NULL
+}
+
+// typeCall is type(name, bases, dict) - a class statement without the statement
+func typeCall(env *Environment, name, bases, namespace Object) Object {
+    className, ok := name.(*String)
+    if !ok {
+        return typeError("type.__new__() argument 1 must be str, not %s", typeName(name))
+    }
+    baseTuple, ok := bases.(*Tuple)
+    if !ok {
+        return typeError("type.__new__() argument 2 must be tuple, not %s", typeName(bases))
+    }
+    source, ok := namespace.(*Dict)
+    if !ok {
+        return typeError("type.__new__() argument 3 must be dict, not %s", typeName(namespace))
+    }
+    classes := []*Class{}
+    for _, base := range baseTuple.Elements {
+        cls, ok := base.(*Class)
+        if !ok {
+            return typeError("bases must be types")
+        }
+        if cls == boolType || cls == noneType || cls == functionType {
+            return typeError("type '%s' is not an acceptable base type", cls.Name)
+        }
+        classes = append(classes, cls)
+    }
+    dict := source.Copy()
+    if _, ok := dict.GetStr("__module__"); !ok {
+        dict.SetStr("__module__", &String{Value: env.moduleName()})
+    }
+    if _, ok := dict.GetStr("__qualname__"); !ok {
+        dict.SetStr("__qualname__", className)
+    }
+    cls, err := newClass(className.Value, classes, dict)
+    if err != nil {
+        return err
+    }
+    if err := setNames(env, cls); err != nil {
+        return err
+    }
+    return cls
+}
+
+// instantiate is calling a class: __new__ makes it, __init__ sets it up
+func instantiate(env *Environment, cls *Class, args []Object, kwargs *Dict) Object {
+    if cls == typeType && len(args) == 1 && kwargs == nil {
+        return typeOf(args[0])
+    }
+
+    newFn := cls.lookupName("__new__")
+    obj := applyFunction(env, newFn, append([]Object{cls}, args...), kwargs)
+    if isError(obj) || !typeOf(obj).isSubclass(cls) {
+        return obj
+    }
+
+    init, _ := typeOf(obj).lookup("__init__")
+    result := applyFunction(env, init, append([]Object{obj}, args...), kwargs)
+    if isError(result) {
+        return result
+    }
+    if result != NULL {
+        return typeError("__init__() should return None, not '%s'", typeName(result))
+    }
+    return obj
+}
+
+// getAttribute is obj.name - descriptors, instance dict, class, in that order
+func getAttribute(env *Environment, obj Object, name string) Object {
+    switch obj := obj.(type) {
+    case *Class:
+        return classAttribute(env, obj, name)
+    case *Super:
+        return superAttribute(env, obj, name)
+    case *Module:
+        return moduleAttribute(env, obj, name)
+    }
+
+    cls := typeOf(obj)
+    if name == "__class__" {
+        return cls
+    }
+
+    attr := cls.lookupName(name)
+    if attr != nil && isDataDescriptor(attr) {
+        return descriptorGet(env, attr, obj, cls)
+    }
+
+    if dict := instanceDict(obj); dict != nil {
+        if name == "__dict__" {
+            return dict
+        }
+        if value, ok := dict.GetStr(name); ok {
+            return value
+        }
+    }
+
+    if attr != nil {
+        if name == "__new__" {
+            return attr // __new__ is implicitly static
+        }
+        return descriptorGet(env, attr, obj, cls)
+    }
+
+    if getter, ok := obj.(interface{ attribute(name string) (Object, bool) }); ok {
+        if value, ok := getter.attribute(name); ok {
+            return value
+        }
+    }
+
+    if getattr := cls.lookupName("__getattr__"); getattr != nil {
+        return applyFunction(env, getattr, []Object{obj, &String{Value: name}}, nil)
+    }
+    return attributeError("'%s' object has no attribute '%s'", cls.Name, name)
+}
+
+// bindAttribute turns a function found on the class into a method of obj
+func bindAttribute(attr Object, obj Object) Object {
+    switch attr := attr.(type) {
+    case *Function:
+        return &BoundMethod{Self: obj, Function: attr}
+    case *Builtin:
+        if attr.Owner != nil || attr.Wraps != nil {
+            return &BoundMethod{Self: obj, Function: attr}
+        }
+    }
+    return attr
+}
+
+func instanceDict(obj Object) *Dict {
+    switch obj := obj.(type) {
+    case *Instance:
+        return obj.Dict
+    case *Function:
+        return obj.Dict
+    case *Builtin:
+        return obj.Dict
+    case *Partial:
+        return obj.Dict
+    case *Exception:
+        return obj.Dict
+    case *StaticMethod:
+        return obj.Dict
+    case *ClassMethod:
+        return obj.Dict
+    }
+    return nil
+}
+
+func classAttribute(env *Environment, cls *Class, name string) Object {
+    switch name {
+    case "__name__":
+        return &String{Value: cls.Name}
+    case "__qualname__":
+        return &String{Value: cls.qualname()}
+    case "__module__":
+        return &String{Value: cls.module()}
+    case "__dict__":
+        return cls.Dict.Copy()
+    case "__class__":
+        return typeType
+    case "__bases__":
+        bases := make([]Object, len(cls.Bases))
+        for i, base := range cls.Bases {
+            bases[i] = base
+        }
+        return &Tuple{Elements: bases}
+    case "__mro__":
+        mro := make([]Object, len(cls.MRO))
+        for i, c := range cls.MRO {
+            mro[i] = c
+        }
+        return &Tuple{Elements: mro}
+    case "__doc__":
+        if doc := cls.lookupName("__doc__"); doc != nil {
+            return doc
+        }
+        return NULL
+    }
+
+    if attr := cls.lookupName(name); attr != nil {
+        return descriptorGet(env, attr, nil, cls)
+    }
+    if attr := typeType.lookupName(name); attr != nil {
+        return bindAttribute(attr, cls)
+    }
+    return attributeError("type object '%s' has no attribute '%s'", cls.Name, name)
+}
+
+func superAttribute(env *Environment, s *Super, name string) Object {
+    mro := s.SelfClass.MRO
+    start := 0
+    for i, cls := range mro {
+        if cls == s.Class {
+            start = i + 1
+            break
+        }
+    }
+    for _, cls := range mro[start:] {
+        if attr, ok := cls.Dict.GetStr(name); ok {
+            if name == "__new__" {
+                return attr
+            }
+            if s.Self == Object(s.SelfClass) {
+                return descriptorGet(env, attr, nil, s.SelfClass) // super() inside a classmethod
+            }
+            return descriptorGet(env, attr, s.Self, s.SelfClass)
+        }
+    }
+    return attributeError("'super' object has no attribute '%s'", name)
+}
+
+// setAttribute is obj.name = value. Builtins don't take kindly to it.
+func setAttribute(env *Environment, obj Object, name string, value Object) *Error {
+    cls := typeOf(obj)
+    if setattr, owner := cls.lookup("__setattr__"); owner != nil && owner != objectType {
+        result := applyFunction(env, setattr, []Object{obj, &String{Value: name}, value}, nil)
+        if err, ok := result.(*Error); ok {
+            return err
+        }
+        return nil
+    }
+    return genericSetAttribute(env, obj, name, value)
+}
+
+func genericSetAttribute(env *Environment, obj Object, name string, value Object) *Error {
+    switch target := obj.(type) {
+    case *Class:
+        if target.native {
+            return immutableType(name, target)
+        }
+        target.Dict.SetStr(name, value)
+        return nil
+    case *Module:
+        target.Env.Set(name, value)
+        return nil
+    }
+    if attr := typeOf(obj).lookupName(name); attr != nil {
+        if set := typeOf(attr).lookupName("__set__"); set != nil {
+            if err, ok := callMethod(env, set, attr, obj, value).(*Error); ok {
+                return err
+            }
+            return nil
+        }
+    }
+    switch target := obj.(type) {
+    case *Exception:
+        if handled, err := target.setAttribute(env, name, value); handled {
+            return err
+        }
+    }
+    if dict := instanceDict(obj); dict != nil {
+        dict.SetStr(name, value)
+        return nil
+    }
+    return attributeError("'%s' object has no attribute '%s'", typeName(obj), name)
+}
+
+func immutableType(name string, cls *Class) *Error {
+    if module := cls.module(); module != "builtins" {
+        return typeError("cannot set '%s' attribute of immutable type '%s.%s'", name, module, cls.qualname())
+    }
+    return typeError("cannot set '%s' attribute of immutable type '%s'", name, cls.Name)
+}
+
+// delAttribute is del obj.name
+func delAttribute(env *Environment, obj Object, name string) *Error {
+    if delattr, owner := typeOf(obj).lookup("__delattr__"); owner != nil && owner != objectType {
+        if err, ok := applyFunction(env, delattr, []Object{obj, &String{Value: name}}, nil).(*Error); ok {
+            return err
+        }
+        return nil
+    }
+    return genericDelAttribute(env, obj, name)
+}
+
+func genericDelAttribute(env *Environment, obj Object, name string) *Error {
+    switch target := obj.(type) {
+    case *Class:
+        if target.native {
+            return immutableType(name, target)
+        }
+        if _, ok := target.Dict.GetStr(name); !ok {
+            return attributeError("type object '%s' has no attribute '%s'", target.Name, name)
+        }
+        target.Dict.Delete(env, &String{Value: name})
+        return nil
+    case *Module:
+        if !target.Env.Delete(name) {
+            return attributeError("module '%s' has no attribute '%s'", target.Name, name)
+        }
+        return nil
+    }
+    if attr := typeOf(obj).lookupName(name); attr != nil {
+        if del := typeOf(attr).lookupName("__delete__"); del != nil {
+            if err, ok := callMethod(env, del, attr, obj).(*Error); ok {
+                return err
+            }
+            return nil
+        }
+    }
+    if dict := instanceDict(obj); dict != nil {
+        if _, ok := dict.GetStr(name); ok {
+            dict.Delete(env, &String{Value: name})
+            return nil
+        }
+    }
+    return attributeError("'%s' object has no attribute '%s'", typeName(obj), name)
+}
+
+// hasVirtual is whether cls, or one of its bases, was registered with c
+func (c *Class) hasVirtual(cls *Class) bool {
+    for _, virtual := range c.virtual {
+        if cls.isSubclass(virtual) {
+            return true
+        }
+    }
+    return false
+}
+
+// classCheck backs isinstance and issubclass, tuples of classes included
+func classCheck(fname string, cls *Class, classinfo Object) (bool, *Error) {
+    switch info := classinfo.(type) {
+    case *Class:
+        return cls.isSubclass(info) || info.hasVirtual(cls), nil
+    case *Tuple:
+        for _, element := range info.Elements {
+            ok, err := classCheck(fname, cls, element)
+            if err != nil || ok {
+                return ok, err
+            }
+        }
+        return false, nil
+    }
+    return false, typeError("%s() arg 2 must be a type, a tuple of types, or a union", fname)
+}
+
+// super() with no arguments works out the class and instance from the calling method
+func builtinSuper(env *Environment, args []Object, kwargs *Dict) Object {
+    if err := checkArgs("super", args, kwargs, 0, 2); err != nil {
+        return err
+    }
+
+    var cls *Class
+    var self Object
+    switch len(args) {
+    case 0:
+        fn, frame := env.function()
+        if fn == nil || fn.Owner == nil || fn.Owner.class == nil {
+            return runtimeError("super(): __class__ cell not found")
+        }
+        if len(fn.Signature.Parameters) == 0 {
+            return runtimeError("super(): no arguments")
+        }
+        cls = fn.Owner.class
+        self = frame.store[fn.Signature.Parameters[0]]
+    case 1:
+        return runtimeError("super(): single-argument form is not supported")
+    case 2:
+        c, ok := args[0].(*Class)
+        if !ok {
+            return typeError("super() argument 1 must be a type, not %s", typeName(args[0]))
+        }
+        cls, self = c, args[1]
+    }
+
+    selfClass := typeOf(self)
+    if c, ok := self.(*Class); ok && c.isSubclass(cls) {
+        selfClass = c
+    } else if !selfClass.isSubclass(cls) {
+        return typeError("super(type, obj): obj must be an instance or subtype of type")
+    }
+    return &Super{Class: cls, Self: self, SelfClass: selfClass}
+}
+
+func defaultRepr(obj Object) string {
+    cls := typeOf(obj)
+    name := cls.qualname()
+    if module := cls.module(); module != "builtins" {
+        name = module + "." + name
+    }
+    return fmt.Sprintf("<%s object at %#x>", name, objectID(obj))
+}
+
+func init() {
+    objectType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        if len(args) == 0 {
+            return typeError("object.__new__(): not enough arguments")
+        }
+        cls, ok := args[0].(*Class)
+        if !ok {
+            return typeError("object.__new__(X): X is not a type object (%s)", typeName(args[0]))
+        }
+        if len(args) > 1 || kwargs.Len() > 0 {
+            if _, owner := cls.lookup("__new__"); owner != objectType {
+                return typeError("object.__new__() takes exactly one argument (the type to instantiate)")
+            }
+            if _, owner := cls.lookup("__init__"); owner == objectType {
+                return typeError("%s() takes no arguments", cls.Name)
+            }
+        }
+        return &Instance{Class: cls, Dict: NewDict()}
+    })
+    objectType.define("__init__", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if len(args) > 1 || kwargs.Len() > 0 {
+            cls := typeOf(args[0])
+            if _, owner := cls.lookup("__init__"); owner != objectType {
+                return typeError("object.__init__() takes exactly one argument (the instance to initialize)")
+            }
+            if _, owner := cls.lookup("__new__"); owner == objectType {
+                return typeError("%s() takes no arguments", cls.Name)
+            }
+        }
+        return NULL
+    })
+    objectType.method("__eq__", 1, 1, func(env *Environment, args []Object) Object {
+        if args[0] == args[1] {
+            return TRUE
+        }
+        return NotImplemented
+    })
+    objectType.method("__ne__", 1, 1, func(env *Environment, args []Object) Object {
+        eq := typeOf(args[0]).lookupName("__eq__")
+        result := applyFunction(env, eq, args, nil)
+        if isError(result) || result == NotImplemented {
+            return result
+        }
+        ok, err := truthy(env, result)
+        if err != nil {
+            return err
+        }
+        return nativeBool(!ok)
+    })
+    for _, name := range []string{"__lt__", "__le__", "__gt__", "__ge__"} {
+        objectType.method(name, 1, 1, func(env *Environment, args []Object) Object {
+            return NotImplemented
+        })
+    }
+    objectType.method("__hash__", 0, 0, func(env *Environment, args []Object) Object {
+        return newInt(objectID(args[0]) >> 4)
+    })
+    objectType.method("__repr__", 0, 0, func(env *Environment, args []Object) Object {
+        return &String{Value: defaultRepr(args[0])}
+    })
+    objectType.method("__str__", 0, 0, func(env *Environment, args []Object) Object {
+        return reprOf(env, args[0])
+    })
+    objectType.method("__setattr__", 2, 2, func(env *Environment, args []Object) Object {
+        name, ok := args[1].(*String)
+        if !ok {
+            return typeError("attribute name must be string, not '%s'", typeName(args[1]))
+        }
+        if err := genericSetAttribute(env, args[0], name.Value, args[2]); err != nil {
+            return err
+        }
+        return NULL
+    })
+    objectType.method("__delattr__", 1, 1, func(env *Environment, args []Object) Object {
+        name, ok := args[1].(*String)
+        if !ok {
+            return typeError("attribute name must be string, not '%s'", typeName(args[1]))
+        }
+        if err := genericDelAttribute(env, args[0], name.Value); err != nil {
+            return err
+        }
+        return NULL
+    })
+    objectType.method("__getattribute__", 1, 1, func(env *Environment, args []Object) Object {
+        name, ok := args[1].(*String)
+        if !ok {
+            return typeError("attribute name must be string, not '%s'", typeName(args[1]))
+        }
+        return getAttribute(env, args[0], name.Value)
+    })
+
+    typeType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        if len(args) == 2 && kwargs.Len() == 0 {
+            return typeOf(args[1])
+        }
+        if len(args) != 4 {
+            return typeError("type() takes 1 or 3 arguments")
+        }
+        return typeCall(env, args[1], args[2], args[3])
+    })
+    typeType.method("__repr__", 0, 0, func(env *Environment, args []Object) Object {
+        return &String{Value: args[0].Inspect()}
+    })
+    typeType.method("mro", 0, 0, func(env *Environment, args []Object) Object {
+        cls := args[0].(*Class)
+        mro := make([]Object, len(cls.MRO))
+        for i, c := range cls.MRO {
+            mro[i] = c
+        }
+        return &List{Elements: mro}
+    })
+
+    noneType.method("__repr__", 0, 0, func(env *Environment, args []Object) Object {
+        return &String{Value: "None"}
+    })
+    noneType.method("__bool__", 0, 0, func(env *Environment, args []Object) Object {
+        return FALSE
+    })
+    notImplementedType.method("__repr__", 0, 0, func(env *Environment, args []Object) Object {
+        return &String{Value: "NotImplemented"}
+    })
+
+    for _, cls := range []*Class{functionType, builtinType, methodType, superType} {
+        cls.method("__repr__", 0, 0, func(env *Environment, args []Object) Object {
+            return &String{Value: args[0].Inspect()}
+        })
+    }
+    methodType.method("__eq__", 1, 1, func(env *Environment, args []Object) Object {
+        other, ok := args[1].(*BoundMethod)
+        if !ok {
+            return NotImplemented
+        }
+        self := args[0].(*BoundMethod)
+        return nativeBool(self.Self == other.Self && self.Function == other.Function)
+    })
+    methodType.method("__hash__", 0, 0, func(env *Environment, args []Object) Object {
+        m := args[0].(*BoundMethod)
+        return newInt((objectID(m.Self) ^ objectID(m.Function)) >> 4)
+    })
+}
+
+// attribute exposes a function's special attributes
+func (f *Function) attribute(name string) (Object, bool) {
+    switch name {
+    case "__name__":
+        return &String{Value: f.Name}, true
+    case "__qualname__":
+        return &String{Value: f.Qualname}, true
+    case "__module__":
+        return &String{Value: f.Env.moduleName()}, true
+    case "__doc__":
+        return nilToNone(f.Doc), true
+    case "__defaults__":
+        if len(f.Defaults) == 0 {
+            return NULL, true
+        }
+        return &Tuple{Elements: f.Defaults}, true
+    case "__kwdefaults__":
+        if f.KwDefaults == nil {
+            return NULL, true
+        }
+        return f.KwDefaults, true
+    }
+    return nil, false
+}
+
+func (m *BoundMethod) attribute(name string) (Object, bool) {
+    switch name {
+    case "__self__":
+        return m.Self, true
+    case "__func__":
+        return m.Function, true
+    case "__name__", "__doc__":
+        return getAttribute(nil, m.Function, name), true
+    }
+    return nil, false
+}
+
+func (b *Builtin) attribute(name string) (Object, bool) {
+    if b.Wraps != nil {
+        if name == "__wrapped__" {
+            return b.Wraps, true
+        }
+        return wrappedAttribute(b.Wraps, name)
+    }
+    switch name {
+    case "__name__":
+        return &String{Value: b.Name}, true
+    case "__qualname__":
+        if b.Owner != nil {
+            return &String{Value: b.Owner.Name + "." + b.Name}, true
+        }
+        return &String{Value: b.Name}, true
+    case "__doc__":
+        if b.Doc == "" {
+            return NULL, true
+        }
+        return &String{Value: b.Doc}, true
+    }
+    return nil, false
+}
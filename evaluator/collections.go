@@ -0,0 +1,1854 @@
+// Comments in this file are inspired by Jack Soloff - he counted every vote, and kept a list of who owed him
+
+package evaluator
+
+import (
+    "fmt"
+    "interpreter/parser"
+    "interpreter/token"
+    "strings"
+)
+
+// collections: deque, defaultdict, OrderedDict, Counter, namedtuple and
+// ChainMap. The dict flavours are dict subclasses sharing its payload; a
+// deque is a Go value of its own, the way a range is
+
+var (
+    dequeType         = newBuiltinClass("deque", objectType)
+    dequeIteratorType = newIteratorClass("_deque_iterator")
+    dequeReversedType = newIteratorClass("_deque_reverse_iterator")
+    defaultDictType   = newBuiltinClass("defaultdict", dictType)
+    orderedDictType   = newBuiltinClass("OrderedDict", dictType)
+    counterType       = newBuiltinClass("Counter", dictType)
+    chainMapType      = newBuiltinClass("ChainMap", objectType)
+)
+
+// Deque is a ring buffer - cheap to work from either end
+type Deque struct {
+    ring   []Object
+    head   int
+    size   int
+    maxlen int // -1 when there is no limit
+    state  int // bumped by every append and pop, so iterators notice
+}
+
+func (d *Deque) Type() ObjectType { return DEQUE_OBJ }
+func (d *Deque) Inspect() string {
+    if d.maxlen >= 0 {
+        return fmt.Sprintf("deque([%s], maxlen=%d)", inspectAll(d.elements()), d.maxlen)
+    }
+    return "deque([" + inspectAll(d.elements()) + "])"
+}
+
+func asDeque(obj Object) *Deque { return payload(obj).(*Deque) }
+
+func (d *Deque) at(i int) Object {
+    return d.ring[(d.head+i)%len(d.ring)]
+}
+
+func (d *Deque) setAt(i int, value Object) {
+    d.ring[(d.head+i)%len(d.ring)] = value
+}
+
+func (d *Deque) elements() []Object {
+    elements := make([]Object, d.size)
+    for i := range elements {
+        elements[i] = d.at(i)
+    }
+    return elements
+}
+
+// grow makes room for one more, doubling the ring when it is full
+func (d *Deque) grow() {
+    if d.size < len(d.ring) {
+        return
+    }
+    ring := make([]Object, max(8, 2*len(d.ring)))
+    for i := 0; i < d.size; i++ {
+        ring[i] = d.at(i)
+    }
+    d.ring, d.head = ring, 0
+}
+
+// pushBack appends, pushing the oldest out the other end once maxlen is reached
+func (d *Deque) pushBack(value Object) {
+    d.state++
+    if d.maxlen == 0 {
+        return
+    }
+    if d.size == d.maxlen {
+        d.popFront()
+    }
+    d.grow()
+    d.ring[(d.head+d.size)%len(d.ring)] = value
+    d.size++
+}
+
+func (d *Deque) pushFront(value Object) {
+    d.state++
+    if d.maxlen == 0 {
+        return
+    }
+    if d.size == d.maxlen {
+        d.popBack()
+    }
+    d.grow()
+    d.head = (d.head + len(d.ring) - 1) % len(d.ring)
+    d.ring[d.head] = value
+    d.size++
+}
+
+func (d *Deque) popFront() Object {
+    value := d.ring[d.head]
+    d.ring[d.head] = nil
+    d.head = (d.head + 1) % len(d.ring)
+    d.size--
+    d.state++
+    return value
+}
+
+func (d *Deque) popBack() Object {
+    i := (d.head + d.size - 1) % len(d.ring)
+    value := d.ring[i]
+    d.ring[i] = nil
+    d.size--
+    d.state++
+    return value
+}
+
+// dequeRepeat is d *= n. With a maxlen only the copies that survive get made.
+func dequeRepeat(d *Deque, n int) *Error {
+    elements := d.elements()
+    if d.maxlen >= 0 && len(elements) > 0 && n > d.maxlen/len(elements)+1 {
+        n = d.maxlen/len(elements) + 1
+    }
+    repeated, err := repeatElements(elements, n)
+    if err != nil {
+        return err
+    }
+    d.reset(repeated)
+    return nil
+}
+
+// reset replaces the contents, maxlen permitting
+func (d *Deque) reset(elements []Object) {
+    d.ring, d.head, d.size = nil, 0, 0
+    d.state++
+    for _, element := range elements {
+        d.pushBack(element)
+    }
+}
+
+func (d *Deque) extend(env *Environment, iterable Object, left bool) *Error {
+    push := d.pushBack
+    if left {
+        push = d.pushFront
+    }
+    if payload(iterable) == Object(d) {
+        for _, element := range d.elements() {
+            push(element)
+        }
+        return nil
+    }
+    return iterate(env, iterable, func(item Object) *Error {
+        push(item)
+        return nil
+    })
+}
+
+func (d *Deque) rotate(n int) {
+    if d.size <= 1 {
+        return
+    }
+    n %= d.size
+    if n < 0 {
+        n += d.size
+    }
+    if n > d.size/2 {
+        for i := n; i < d.size; i++ {
+            d.pushBack(d.popFront())
+        }
+        return
+    }
+    for i := 0; i < n; i++ {
+        d.pushFront(d.popBack())
+    }
+}
+
+// find is the position of the first element equal to item between start
+// and stop, or -1; the deque may not change while it looks
+func (d *Deque) find(env *Environment, item Object, start, stop int) (int, *Error) {
+    state := d.state
+    for i := start; i < stop && i < d.size; i++ {
+        eq, err := equals(env, d.at(i), item)
+        if err != nil {
+            return -1, err
+        }
+        if d.state != state {
+            return -1, runtimeError("deque mutated during iteration")
+        }
+        if eq {
+            return i, nil
+        }
+    }
+    return -1, nil
+}
+
+// dequeIndex is the position an integer subscript names
+func dequeIndex(env *Environment, d *Deque, obj Object) (int, *Error) {
+    if _, ok := toBigInt(obj); !ok && typeOf(obj).lookupName("__index__") == nil {
+        return 0, typeError("sequence index must be integer, not '%s'", typeName(obj))
+    }
+    i, err := toIndex(env, obj)
+    if err != nil {
+        return 0, err
+    }
+    if i < 0 {
+        i += d.size
+    }
+    if i < 0 || i >= d.size {
+        return 0, indexError("deque index out of range")
+    }
+    return i, nil
+}
+
+// dequeCopy is copy(self): a subclass is asked to copy itself through its constructor
+func dequeCopy(env *Environment, self Object) Object {
+    d := asDeque(self)
+    if typeOf(self) == dequeType {
+        copied := &Deque{maxlen: d.maxlen}
+        copied.reset(d.elements())
+        return copied
+    }
+    args := []Object{self}
+    if d.maxlen >= 0 {
+        args = append(args, newInt(int64(d.maxlen)))
+    }
+    return applyFunction(env, typeOf(self), args, nil)
+}
+
+func (d *Deque) iterator(class *Class, reversed bool) *Iter {
+    i, state := 0, d.state
+    return newIterator(class, func(env *Environment) (Object, bool) {
+        if d.state != state {
+            state = -1
+            return runtimeError("deque mutated during iteration"), true
+        }
+        if i >= d.size {
+            return nil, false
+        }
+        i++
+        if reversed {
+            return d.at(d.size - i), true
+        }
+        return d.at(i - 1), true
+    })
+}
+
+func init() {
+    registerModule("collections", buildCollections)
+
+    for _, cls := range []*Class{dequeType, defaultDictType, orderedDictType, counterType, chainMapType} {
+        cls.Dict.SetStr("__module__", &String{Value: "collections"})
+    }
+    dequeIteratorType.Dict.SetStr("__module__", &String{Value: "_collections"})
+    dequeReversedType.Dict.SetStr("__module__", &String{Value: "_collections"})
+    initDeque()
+    initDefaultDict()
+    initOrderedDict()
+    initCounter()
+    initChainMap()
+}
+
+func buildCollections(m *Module) {
+    for _, cls := range []*Class{dequeType, defaultDictType, orderedDictType, counterType, chainMapType} {
+        m.Env.Set(cls.Name, cls)
+    }
+    m.function("namedtuple", func(env *Environment, args []Object, kwargs *Dict) Object {
+        return namedTuple(env, args, kwargs)
+    })
+}
+
+func initDeque() {
+    dequeType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        cls, err := newClassArg("deque", args)
+        if err != nil {
+            return err
+        }
+        return wrapBuiltinValue(cls, dequeType, &Deque{maxlen: -1})
+    })
+    dequeType.define("__init__", func(env *Environment, args []Object, kwargs *Dict) Object {
+        values, err := parseArgs("deque", args[1:], kwargs, []string{"iterable", "maxlen"}, 0)
+        if err != nil {
+            return err
+        }
+        d := asDeque(args[0])
+        d.maxlen = -1
+        if values[1] != nil && values[1] != NULL {
+            n, err := toIndex(env, values[1])
+            if err != nil {
+                return err
+            }
+            if n < 0 {
+                return valueError("maxlen must be non-negative")
+            }
+            d.maxlen = n
+        }
+        d.reset(nil)
+        if values[0] != nil {
+            if err := d.extend(env, values[0], false); err != nil {
+                return err
+            }
+        }
+        return NULL
+    })
+    dequeType.Dict.SetStr("__hash__", NULL)
+    dequeType.property("maxlen", func(self Object) Object {
+        if d := asDeque(self); d.maxlen >= 0 {
+            return newInt(int64(d.maxlen))
+        }
+        return NULL
+    })
+    dequeType.method("__len__", 0, 0, func(env *Environment, args []Object) Object {
+        return newInt(int64(asDeque(args[0]).size))
+    })
+    dequeType.method("__iter__", 0, 0, func(env *Environment, args []Object) Object {
+        return asDeque(args[0]).iterator(dequeIteratorType, false)
+    })
+    dequeType.method("__reversed__", 0, 0, func(env *Environment, args []Object) Object {
+        return asDeque(args[0]).iterator(dequeReversedType, true)
+    })
+    dequeType.method("__contains__", 1, 1, func(env *Environment, args []Object) Object {
+        d := asDeque(args[0])
+        i, err := d.find(env, args[1], 0, d.size)
+        if err != nil {
+            return err
+        }
+        return nativeBool(i >= 0)
+    })
+    dequeType.method("__getitem__", 1, 1, func(env *Environment, args []Object) Object {
+        d := asDeque(args[0])
+        i, err := dequeIndex(env, d, args[1])
+        if err != nil {
+            return err
+        }
+        return d.at(i)
+    })
+    dequeType.method("__setitem__", 2, 2, func(env *Environment, args []Object) Object {
+        d := asDeque(args[0])
+        i, err := dequeIndex(env, d, args[1])
+        if err != nil {
+            return err
+        }
+        d.setAt(i, args[2])
+        return NULL
+    })
+    dequeType.method("__delitem__", 1, 1, func(env *Environment, args []Object) Object {
+        d := asDeque(args[0])
+        i, err := dequeIndex(env, d, args[1])
+        if err != nil {
+            return err
+        }
+        elements := d.elements()
+        d.reset(append(elements[:i], elements[i+1:]...))
+        return NULL
+    })
+    dequeType.method("__repr__", 0, 0, func(env *Environment, args []Object) Object {
+        d := asDeque(args[0])
+        s, ok, err := reprJoin(env, args[0], d.elements(), ", ")
+        if err != nil {
+            return err
+        }
+        if !ok {
+            return &String{Value: "[...]"}
+        }
+        if d.maxlen >= 0 {
+            return &String{Value: fmt.Sprintf("%s([%s], maxlen=%d)", typeName(args[0]), s, d.maxlen)}
+        }
+        return &String{Value: typeName(args[0]) + "([" + s + "])"}
+    })
+    for _, operator := range []string{"==", "!=", "<", "<=", ">", ">="} {
+        operator := operator
+        dequeType.method(comparisonOperators[operator][0], 1, 1, func(env *Environment, args []Object) Object {
+            other, ok := payload(args[1]).(*Deque)
+            if !ok {
+                return NotImplemented
+            }
+            return compareSequences(env, operator, asDeque(args[0]).elements(), other.elements())
+        })
+    }
+    dequeType.method("__add__", 1, 1, func(env *Environment, args []Object) Object {
+        other, ok := payload(args[1]).(*Deque)
+        if !ok {
+            return typeError("can only concatenate deque (not \"%s\") to deque", typeName(args[1]))
+        }
+        result := dequeCopy(env, args[0])
+        if isError(result) {
+            return result
+        }
+        for _, element := range other.elements() {
+            asDeque(result).pushBack(element)
+        }
+        return result
+    })
+    dequeType.method("__iadd__", 1, 1, func(env *Environment, args []Object) Object {
+        if err := asDeque(args[0]).extend(env, args[1], false); err != nil {
+            return err
+        }
+        return args[0]
+    })
+    for _, name := range []string{"__mul__", "__rmul__"} {
+        dequeType.method(name, 1, 1, func(env *Environment, args []Object) Object {
+            n, result := repeatCount(env, args[1])
+            if result != nil {
+                return result
+            }
+            copied := dequeCopy(env, args[0])
+            if isError(copied) {
+                return copied
+            }
+            if err := dequeRepeat(asDeque(copied), n); err != nil {
+                return err
+            }
+            return copied
+        })
+    }
+    dequeType.method("__imul__", 1, 1, func(env *Environment, args []Object) Object {
+        n, result := repeatCount(env, args[1])
+        if result != nil {
+            return result
+        }
+        if err := dequeRepeat(asDeque(args[0]), n); err != nil {
+            return err
+        }
+        return args[0]
+    })
+    for _, name := range []string{"copy", "__copy__"} {
+        dequeType.method(name, 0, 0, func(env *Environment, args []Object) Object {
+            return dequeCopy(env, args[0])
+        })
+    }
+    dequeType.method("append", 1, 1, func(env *Environment, args []Object) Object {
+        asDeque(args[0]).pushBack(args[1])
+        return NULL
+    })
+    dequeType.method("appendleft", 1, 1, func(env *Environment, args []Object) Object {
+        asDeque(args[0]).pushFront(args[1])
+        return NULL
+    })
+    dequeType.method("pop", 0, 0, func(env *Environment, args []Object) Object {
+        d := asDeque(args[0])
+        if d.size == 0 {
+            return indexError("pop from an empty deque")
+        }
+        return d.popBack()
+    })
+    dequeType.method("popleft", 0, 0, func(env *Environment, args []Object) Object {
+        d := asDeque(args[0])
+        if d.size == 0 {
+            return indexError("pop from an empty deque")
+        }
+        return d.popFront()
+    })
+    dequeType.method("extend", 1, 1, func(env *Environment, args []Object) Object {
+        if err := asDeque(args[0]).extend(env, args[1], false); err != nil {
+            return err
+        }
+        return NULL
+    })
+    dequeType.method("extendleft", 1, 1, func(env *Environment, args []Object) Object {
+        if err := asDeque(args[0]).extend(env, args[1], true); err != nil {
+            return err
+        }
+        return NULL
+    })
+    dequeType.method("rotate", 0, 1, func(env *Environment, args []Object) Object {
+        n := 1
+        if len(args) == 2 {
+            var err *Error
+            if n, err = toIndex(env, args[1]); err != nil {
+                return err
+            }
+        }
+        asDeque(args[0]).rotate(n)
+        return NULL
+    })
+    dequeType.method("clear", 0, 0, func(env *Environment, args []Object) Object {
+        asDeque(args[0]).reset(nil)
+        return NULL
+    })
+    dequeType.method("reverse", 0, 0, func(env *Environment, args []Object) Object {
+        d := asDeque(args[0])
+        for i, j := 0, d.size-1; i < j; i, j = i+1, j-1 {
+            a, b := d.at(i), d.at(j)
+            d.setAt(i, b)
+            d.setAt(j, a)
+        }
+        return NULL
+    })
+    dequeType.method("count", 1, 1, func(env *Environment, args []Object) Object {
+        d := asDeque(args[0])
+        count := 0
+        for i := 0; ; i++ {
+            found, err := d.find(env, args[1], i, d.size)
+            if err != nil {
+                return err
+            }
+            if found < 0 {
+                return newInt(int64(count))
+            }
+            count++
+            i = found
+        }
+    })
+    dequeType.method("index", 1, 3, func(env *Environment, args []Object) Object {
+        d := asDeque(args[0])
+        bounds := []int{0, d.size}
+        for i, arg := range args[2:] {
+            n, err := sliceIndex(env, arg)
+            if err != nil {
+                return err
+            }
+            if n < 0 {
+                n = max(n+d.size, 0)
+            }
+            bounds[i] = min(n, d.size)
+        }
+        i, err := d.find(env, args[1], bounds[0], bounds[1])
+        if err != nil {
+            return err
+        }
+        if i < 0 {
+            s, err := reprString(env, args[1])
+            if err != nil {
+                return err
+            }
+            return valueError("%s is not in deque", s)
+        }
+        return newInt(int64(i))
+    })
+    dequeType.method("insert", 2, 2, func(env *Environment, args []Object) Object {
+        d := asDeque(args[0])
+        if d.size == d.maxlen {
+            return indexError("deque already at its maximum size")
+        }
+        i, err := toIndex(env, args[1])
+        if err != nil {
+            return err
+        }
+        if i < 0 {
+            i = max(i+d.size, 0)
+        }
+        i = min(i, d.size)
+        elements := d.elements()
+        d.reset(append(elements[:i:i], append([]Object{args[2]}, elements[i:]...)...))
+        return NULL
+    })
+    dequeType.method("remove", 1, 1, func(env *Environment, args []Object) Object {
+        d := asDeque(args[0])
+        i, err := d.find(env, args[1], 0, d.size)
+        if err != nil {
+            return err
+        }
+        if i < 0 {
+            s, err := reprString(env, args[1])
+            if err != nil {
+                return err
+            }
+            return valueError("%s is not in deque", s)
+        }
+        elements := d.elements()
+        d.reset(append(elements[:i], elements[i+1:]...))
+        return NULL
+    })
+}
+
+// defaultdict keeps its factory in the instance dict - every defaultdict is
+// an instance of a dict subclass anyway - with None on the class to fall back on
+func initDefaultDict() {
+    defaultDictType.Dict.SetStr("default_factory", NULL)
+    defaultDictType.define("__init__", func(env *Environment, args []Object, kwargs *Dict) Object {
+        factory, rest := Object(NULL), args[1:]
+        if len(rest) > 0 {
+            factory, rest = rest[0], rest[1:]
+            if factory != NULL && !isCallable(factory) {
+                return typeError("first argument must be callable or None")
+            }
+        }
+        instanceDict(args[0]).SetStr("default_factory", factory)
+        if err := dictUpdate(env, asDict(args[0]), rest, kwargs, "dict"); err != nil {
+            return err
+        }
+        return NULL
+    })
+    defaultDictType.method("__missing__", 1, 1, func(env *Environment, args []Object) Object {
+        factory := getAttribute(env, args[0], "default_factory")
+        if isError(factory) {
+            return factory
+        }
+        if factory == NULL {
+            return keyError(args[1])
+        }
+        value := applyFunction(env, factory, nil, nil)
+        if isError(value) {
+            return value
+        }
+        if err := setItem(env, args[0], args[1], value); err != nil {
+            return err
+        }
+        return value
+    })
+    defaultDictType.method("__repr__", 0, 0, func(env *Environment, args []Object) Object {
+        factory := getAttribute(env, args[0], "default_factory")
+        if isError(factory) {
+            return factory
+        }
+        f, err := reprString(env, factory)
+        if err != nil {
+            return err
+        }
+        d := callMethod(env, dictType.lookupName("__repr__"), args[0])
+        if isError(d) {
+            return d
+        }
+        return &String{Value: fmt.Sprintf("%s(%s, %s)", typeName(args[0]), f, d.(*String).Value)}
+    })
+    for _, name := range []string{"copy", "__copy__"} {
+        defaultDictType.method(name, 0, 0, func(env *Environment, args []Object) Object {
+            factory := getAttribute(env, args[0], "default_factory")
+            if isError(factory) {
+                return factory
+            }
+            return applyFunction(env, typeOf(args[0]), []Object{factory, args[0]}, nil)
+        })
+    }
+    defaultDictType.method("__or__", 1, 1, func(env *Environment, args []Object) Object {
+        other, ok := payload(args[1]).(*Dict)
+        if !ok {
+            return NotImplemented
+        }
+        result := callAttribute(env, args[0], "copy")
+        if isError(result) {
+            return result
+        }
+        if err := mergeInto(env, asDict(result), other); err != nil {
+            return err
+        }
+        return result
+    })
+    defaultDictType.method("__ror__", 1, 1, func(env *Environment, args []Object) Object {
+        if _, ok := payload(args[1]).(*Dict); !ok {
+            return NotImplemented
+        }
+        factory := getAttribute(env, args[0], "default_factory")
+        if isError(factory) {
+            return factory
+        }
+        result := applyFunction(env, typeOf(args[0]), []Object{factory, args[1]}, nil)
+        if isError(result) {
+            return result
+        }
+        if err := mergeInto(env, asDict(result), asDict(args[0])); err != nil {
+            return err
+        }
+        return result
+    })
+}
+
+// OrderedDict has little to add: dicts keep their order already. It is
+// only pickier about order when comparing, and can shuffle keys to the ends.
+func initOrderedDict() {
+    orderedDictType.define("move_to_end", func(env *Environment, args []Object, kwargs *Dict) Object {
+        values, err := parseArgs("move_to_end", args[1:], kwargs, []string{"key", "last"}, 1)
+        if err != nil {
+            return err
+        }
+        last := true
+        if values[1] != nil {
+            if last, err = truthy(env, values[1]); err != nil {
+                return err
+            }
+        }
+        found, err := asDict(args[0]).moveToEnd(env, values[0], last)
+        if err != nil {
+            return err
+        }
+        if !found {
+            return keyError(values[0])
+        }
+        return NULL
+    })
+    orderedDictType.define("popitem", func(env *Environment, args []Object, kwargs *Dict) Object {
+        values, err := parseArgs("popitem", args[1:], kwargs, []string{"last"}, 0)
+        if err != nil {
+            return err
+        }
+        last := true
+        if values[0] != nil {
+            if last, err = truthy(env, values[0]); err != nil {
+                return err
+            }
+        }
+        entries := asDict(args[0]).Entries()
+        if len(entries) == 0 {
+            return newErrorKind(keyErrorType, "dictionary is empty")
+        }
+        entry := entries[0]
+        if last {
+            entry = entries[len(entries)-1]
+        }
+        if _, err := asDict(args[0]).Delete(env, entry.Key); err != nil {
+            return err
+        }
+        return &Tuple{Elements: []Object{entry.Key, entry.Value}}
+    })
+    orderedDictType.method("__eq__", 1, 1, func(env *Environment, args []Object) Object {
+        other, ok := payload(args[1]).(*Dict)
+        if !ok {
+            return NotImplemented
+        }
+        result := dictEquals(env, asDict(args[0]), other)
+        if result != TRUE || !typeOf(args[1]).isSubclass(orderedDictType) {
+            return result
+        }
+        mine, theirs := asDict(args[0]).Entries(), other.Entries()
+        for i := range mine {
+            eq, err := equals(env, mine[i].Key, theirs[i].Key)
+            if err != nil {
+                return err
+            }
+            if !eq {
+                return FALSE
+            }
+        }
+        return TRUE
+    })
+    orderedDictType.method("__repr__", 0, 0, func(env *Environment, args []Object) Object {
+        entries := asDict(args[0]).Entries()
+        if len(entries) == 0 {
+            return &String{Value: typeName(args[0]) + "()"}
+        }
+        items := make([]Object, len(entries))
+        for i, entry := range entries {
+            items[i] = &Tuple{Elements: []Object{entry.Key, entry.Value}}
+        }
+        s, ok, err := reprJoin(env, args[0], items, ", ")
+        if err != nil {
+            return err
+        }
+        if !ok {
+            return &String{Value: "..."}
+        }
+        return &String{Value: typeName(args[0]) + "([" + s + "])"}
+    })
+    for _, name := range []string{"copy", "__copy__"} {
+        orderedDictType.method(name, 0, 0, func(env *Environment, args []Object) Object {
+            return applyFunction(env, typeOf(args[0]), []Object{args[0]}, nil)
+        })
+    }
+    orderedDictType.method("__or__", 1, 1, func(env *Environment, args []Object) Object {
+        other, ok := payload(args[1]).(*Dict)
+        if !ok {
+            return NotImplemented
+        }
+        result := applyFunction(env, typeOf(args[0]), []Object{args[0]}, nil)
+        if isError(result) {
+            return result
+        }
+        if err := mergeInto(env, asDict(result), other); err != nil {
+            return err
+        }
+        return result
+    })
+    orderedDictType.method("__ror__", 1, 1, func(env *Environment, args []Object) Object {
+        if _, ok := payload(args[1]).(*Dict); !ok {
+            return NotImplemented
+        }
+        result := applyFunction(env, typeOf(args[0]), []Object{args[1]}, nil)
+        if isError(result) {
+            return result
+        }
+        if err := mergeInto(env, asDict(result), asDict(args[0])); err != nil {
+            return err
+        }
+        return result
+    })
+}
+
+// Counter is a dict of counts, where a missing key counts as zero
+
+func newCounter() (Object, *Dict) {
+    counts := NewDict()
+    return wrapBuiltinValue(counterType, dictType, counts), counts
+}
+
+func counterGet(env *Environment, counts *Dict, key Object) (Object, *Error) {
+    value, err := counts.Get(env, key)
+    if value == nil && err == nil {
+        value = newInt(0)
+    }
+    return value, err
+}
+
+// compareTo is `value operator other` as a Go bool
+func compareTo(env *Environment, operator string, value, other Object) (bool, *Error) {
+    result := richCompare(env, operator, value, other)
+    if err, ok := result.(*Error); ok {
+        return false, err
+    }
+    return truthy(env, result)
+}
+
+// counterUpdate adds the counts from a mapping, or one for every element of
+// an iterable - or takes them away, for subtract()
+func counterUpdate(env *Environment, counts *Dict, source Object, subtract bool) *Error {
+    add := func(key, count Object) *Error {
+        current, err := counterGet(env, counts, key)
+        if err != nil {
+            return err
+        }
+        var sum Object
+        if subtract {
+            sum = binaryOperation(env, "-", current, count)
+        } else {
+            sum = binaryOperation(env, "+", count, current)
+        }
+        if err, ok := sum.(*Error); ok {
+            return err
+        }
+        return counts.Set(env, key, sum)
+    }
+    if _, ok := payload(source).(*Dict); ok || typeOf(source).lookupName("keys") != nil {
+        if counts.Len() == 0 && !subtract {
+            return mergeInto(env, counts, source)
+        }
+        pairs := NewDict()
+        if err := mergeInto(env, pairs, source); err != nil {
+            return err
+        }
+        for _, entry := range pairs.Entries() {
+            if err := add(entry.Key, entry.Value); err != nil {
+                return err
+            }
+        }
+        return nil
+    }
+    one := newInt(1)
+    return iterate(env, source, func(item Object) *Error {
+        return add(item, one)
+    })
+}
+
+// counterUpdateArgs is the body of update() and subtract(): (iterable=None, /, **kwds)
+func counterUpdateArgs(env *Environment, name string, args []Object, kwargs *Dict, subtract bool) Object {
+    if len(args) > 2 {
+        return typeError("Counter.%s() takes from 1 to 2 positional arguments but %d were given", name, len(args))
+    }
+    counts := asDict(args[0])
+    if len(args) == 2 && args[1] != NULL {
+        if err := counterUpdate(env, counts, args[1], subtract); err != nil {
+            return err
+        }
+    }
+    if kwargs.Len() > 0 {
+        if err := counterUpdate(env, counts, kwargs, subtract); err != nil {
+            return err
+        }
+    }
+    return NULL
+}
+
+// mostCommon is every (element, count) pair, biggest count first; ties keep their order
+func mostCommon(env *Environment, counts *Dict) ([]Object, *Error) {
+    entries := counts.Entries()
+    pairs := make([]Object, len(entries))
+    for i, entry := range entries {
+        pairs[i] = &Tuple{Elements: []Object{entry.Key, entry.Value}}
+    }
+    count := &Builtin{Name: "itemgetter", Fn: func(env *Environment, args []Object, kwargs *Dict) Object {
+        return args[0].(*Tuple).Elements[1]
+    }}
+    return sortObjects(env, pairs, count, true)
+}
+
+// keepCounts builds a new Counter out of the counts keep says yes to
+func keepCounts(env *Environment, counts *Dict, keep func(key, count Object) (Object, *Error)) Object {
+    result, resultCounts := newCounter()
+    for _, entry := range counts.Entries() {
+        value, err := keep(entry.Key, entry.Value)
+        if err != nil {
+            return err
+        }
+        if value != nil {
+            if err := resultCounts.Set(env, entry.Key, value); err != nil {
+                return err
+            }
+        }
+    }
+    return result
+}
+
+// keepPositive drops the counts that are zero or less, in place
+func keepPositive(env *Environment, counts *Dict) *Error {
+    zero := newInt(0)
+    for _, entry := range counts.Entries() {
+        positive, err := compareTo(env, ">", entry.Value, zero)
+        if err != nil {
+            return err
+        }
+        if !positive {
+            if _, err := counts.Delete(env, entry.Key); err != nil {
+                return err
+            }
+        }
+    }
+    return nil
+}
+
+// counterAll is true when operator holds between the counts of every element of either side
+func counterAll(env *Environment, operator string, a, b *Dict) (bool, *Error) {
+    for _, side := range []*Dict{a, b} {
+        for _, entry := range side.Entries() {
+            left, err := counterGet(env, a, entry.Key)
+            if err != nil {
+                return false, err
+            }
+            right, err := counterGet(env, b, entry.Key)
+            if err != nil {
+                return false, err
+            }
+            ok, err := compareTo(env, operator, left, right)
+            if err != nil || !ok {
+                return false, err
+            }
+        }
+    }
+    return true, nil
+}
+
+// counterOperand is the other Counter of a binary operation, or nil when it isn't one
+func counterOperand(obj Object) *Dict {
+    if !typeOf(obj).isSubclass(counterType) {
+        return nil
+    }
+    return asDict(obj)
+}
+
+func initCounter() {
+    counterType.define("__init__", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if len(args) > 2 {
+            return typeError("Counter.__init__() takes from 1 to 2 positional arguments but %d were given", len(args))
+        }
+        return counterUpdateArgs(env, "update", args, kwargs, false)
+    })
+    counterType.define("update", func(env *Environment, args []Object, kwargs *Dict) Object {
+        return counterUpdateArgs(env, "update", args, kwargs, false)
+    })
+    counterType.define("subtract", func(env *Environment, args []Object, kwargs *Dict) Object {
+        return counterUpdateArgs(env, "subtract", args, kwargs, true)
+    })
+    counterType.method("__missing__", 1, 1, func(env *Environment, args []Object) Object {
+        return newInt(0)
+    })
+    counterType.method("__delitem__", 1, 1, func(env *Environment, args []Object) Object {
+        if _, err := asDict(args[0]).Delete(env, args[1]); err != nil {
+            return err
+        }
+        return NULL
+    })
+    counterType.method("total", 0, 0, func(env *Environment, args []Object) Object {
+        var sum Object = newInt(0)
+        for _, entry := range asDict(args[0]).Entries() {
+            if sum = binaryOperation(env, "+", sum, entry.Value); isError(sum) {
+                return sum
+            }
+        }
+        return sum
+    })
+    counterType.define("most_common", func(env *Environment, args []Object, kwargs *Dict) Object {
+        values, err := parseArgs("most_common", args[1:], kwargs, []string{"n"}, 0)
+        if err != nil {
+            return err
+        }
+        pairs, err := mostCommon(env, asDict(args[0]))
+        if err != nil {
+            return err
+        }
+        if values[0] != nil && values[0] != NULL {
+            n, err := toIndex(env, values[0])
+            if err != nil {
+                return err
+            }
+            pairs = pairs[:max(0, min(n, len(pairs)))]
+        }
+        return &List{Elements: pairs}
+    })
+    counterType.method("elements", 0, 0, func(env *Environment, args []Object) Object {
+        entries := asDict(args[0]).Entries()
+        i, left := 0, 0
+        return newIterator(chainType, func(env *Environment) (Object, bool) {
+            for left <= 0 {
+                if i >= len(entries) {
+                    return nil, false
+                }
+                n, err := toIndex(env, entries[i].Value)
+                if err != nil {
+                    i = len(entries)
+                    return err, true
+                }
+                i, left = i+1, n
+            }
+            left--
+            return entries[i-1].Key, true
+        })
+    })
+    for _, name := range []string{"copy", "__copy__"} {
+        counterType.method(name, 0, 0, func(env *Environment, args []Object) Object {
+            return applyFunction(env, typeOf(args[0]), []Object{args[0]}, nil)
+        })
+    }
+    counterType.Dict.SetStr("fromkeys", &ClassMethod{Function: &Builtin{Name: "fromkeys", Fn: func(env *Environment, args []Object, kwargs *Dict) Object {
+        return newErrorKind(notImplementedErrorType, "Counter.fromkeys() is undefined.  Use Counter(iterable) instead.")
+    }}, Dict: NewDict()})
+    counterType.method("__repr__", 0, 0, func(env *Environment, args []Object) Object {
+        counts := asDict(args[0])
+        if counts.Len() == 0 {
+            return &String{Value: typeName(args[0]) + "()"}
+        }
+        ordered := counts
+        if pairs, err := mostCommon(env, counts); err == nil {
+            ordered = NewDict()
+            for _, pair := range pairs {
+                if err := ordered.Set(env, pair.(*Tuple).Elements[0], pair.(*Tuple).Elements[1]); err != nil {
+                    return err
+                }
+            }
+        } else if !err.matches(typeErrorType) {
+            return err
+        }
+        s, err := reprString(env, ordered)
+        if err != nil {
+            return err
+        }
+        return &String{Value: typeName(args[0]) + "(" + s + ")"}
+    })
+
+    // Multiset comparisons: every element's count, missing ones as newInt(0)
+    counterType.method("__eq__", 1, 1, func(env *Environment, args []Object) Object {
+        other := counterOperand(args[1])
+        if other == nil {
+            return NotImplemented
+        }
+        ok, err := counterAll(env, "==", asDict(args[0]), other)
+        if err != nil {
+            return err
+        }
+        return nativeBool(ok)
+    })
+    counterType.method("__ne__", 1, 1, func(env *Environment, args []Object) Object {
+        other := counterOperand(args[1])
+        if other == nil {
+            return NotImplemented
+        }
+        ok, err := counterAll(env, "==", asDict(args[0]), other)
+        if err != nil {
+            return err
+        }
+        return nativeBool(!ok)
+    })
+    for _, operator := range []string{"<=", "<", ">=", ">"} {
+        inclusive := operator[:1] + "="
+        strict := len(operator) == 1
+        counterType.method(comparisonOperators[operator][0], 1, 1, func(env *Environment, args []Object) Object {
+            other := counterOperand(args[1])
+            if other == nil {
+                return NotImplemented
+            }
+            ok, err := counterAll(env, inclusive, asDict(args[0]), other)
+            if err != nil || !ok || !strict {
+                if err != nil {
+                    return err
+                }
+                return nativeBool(ok)
+            }
+            equal, err := counterAll(env, "==", asDict(args[0]), other)
+            if err != nil {
+                return err
+            }
+            return nativeBool(!equal)
+        })
+    }
+
+    // Multiset arithmetic: the results only keep positive counts
+    for _, op := range []struct {
+        name    string
+        combine func(env *Environment, count, other Object) (Object, *Error)
+        rest    func(env *Environment, count Object) (Object, *Error) // for the other side's elements we lack
+    }{
+        {"__add__",
+            func(env *Environment, count, other Object) (Object, *Error) {
+                return errorPair(binaryOperation(env, "+", count, other))
+            },
+            func(env *Environment, count Object) (Object, *Error) { return count, nil }},
+        {"__sub__",
+            func(env *Environment, count, other Object) (Object, *Error) {
+                return errorPair(binaryOperation(env, "-", count, other))
+            },
+            func(env *Environment, count Object) (Object, *Error) {
+                negative, err := compareTo(env, "<", count, newInt(0))
+                if err != nil || !negative {
+                    return nil, err
+                }
+                return errorPair(binaryOperation(env, "-", newInt(0), count))
+            }},
+        {"__or__",
+            func(env *Environment, count, other Object) (Object, *Error) {
+                less, err := compareTo(env, "<", count, other)
+                if less {
+                    return other, err
+                }
+                return count, err
+            },
+            func(env *Environment, count Object) (Object, *Error) { return count, nil }},
+        {"__and__",
+            func(env *Environment, count, other Object) (Object, *Error) {
+                less, err := compareTo(env, "<", count, other)
+                if less {
+                    return count, err
+                }
+                return other, err
+            },
+            nil},
+    } {
+        op := op
+        counterType.method(op.name, 1, 1, func(env *Environment, args []Object) Object {
+            counts, other := asDict(args[0]), counterOperand(args[1])
+            if other == nil {
+                return NotImplemented
+            }
+            positive := func(value Object, err *Error) (Object, *Error) {
+                if err != nil || value == nil {
+                    return nil, err
+                }
+                ok, err := compareTo(env, ">", value, newInt(0))
+                if !ok {
+                    return nil, err
+                }
+                return value, nil
+            }
+            result := keepCounts(env, counts, func(key, count Object) (Object, *Error) {
+                theirs, err := counterGet(env, other, key)
+                if err != nil {
+                    return nil, err
+                }
+                return positive(op.combine(env, count, theirs))
+            })
+            if isError(result) || op.rest == nil {
+                return result
+            }
+            resultCounts := asDict(result)
+            for _, entry := range other.Entries() {
+                mine, err := counts.Get(env, entry.Key)
+                if err != nil {
+                    return err
+                }
+                if mine != nil {
+                    continue
+                }
+                value, err := positive(op.rest(env, entry.Value))
+                if err != nil {
+                    return err
+                }
+                if value != nil {
+                    if err := resultCounts.Set(env, entry.Key, value); err != nil {
+                        return err
+                    }
+                }
+            }
+            return result
+        })
+    }
+    counterType.method("__pos__", 0, 0, func(env *Environment, args []Object) Object {
+        return keepCounts(env, asDict(args[0]), func(key, count Object) (Object, *Error) {
+            ok, err := compareTo(env, ">", count, newInt(0))
+            if !ok {
+                return nil, err
+            }
+            return count, nil
+        })
+    })
+    counterType.method("__neg__", 0, 0, func(env *Environment, args []Object) Object {
+        return keepCounts(env, asDict(args[0]), func(key, count Object) (Object, *Error) {
+            ok, err := compareTo(env, "<", count, newInt(0))
+            if !ok {
+                return nil, err
+            }
+            return errorPair(binaryOperation(env, "-", newInt(0), count))
+        })
+    })
+
+    // The in-place versions change self, then throw out what isn't positive
+    for _, op := range []struct {
+        name   string
+        update func(env *Environment, counts *Dict, key, count Object) *Error
+    }{
+        {"__iadd__", func(env *Environment, counts *Dict, key, count Object) *Error {
+            return addCount(env, counts, "+", key, count)
+        }},
+        {"__isub__", func(env *Environment, counts *Dict, key, count Object) *Error {
+            return addCount(env, counts, "-", key, count)
+        }},
+        {"__ior__", func(env *Environment, counts *Dict, key, count Object) *Error {
+            current, err := counterGet(env, counts, key)
+            if err != nil {
+                return err
+            }
+            if more, err := compareTo(env, ">", count, current); err != nil || !more {
+                return err
+            }
+            return counts.Set(env, key, count)
+        }},
+    } {
+        op := op
+        counterType.method(op.name, 1, 1, func(env *Environment, args []Object) Object {
+            counts, other := asDict(args[0]), counterOperand(args[1])
+            if other == nil {
+                return NotImplemented
+            }
+            for _, entry := range other.Entries() {
+                if err := op.update(env, counts, entry.Key, entry.Value); err != nil {
+                    return err
+                }
+            }
+            if err := keepPositive(env, counts); err != nil {
+                return err
+            }
+            return args[0]
+        })
+    }
+    counterType.method("__iand__", 1, 1, func(env *Environment, args []Object) Object {
+        counts, other := asDict(args[0]), counterOperand(args[1])
+        if other == nil {
+            return NotImplemented
+        }
+        for _, entry := range counts.Entries() {
+            theirs, err := counterGet(env, other, entry.Key)
+            if err != nil {
+                return err
+            }
+            if fewer, err := compareTo(env, "<", theirs, entry.Value); err != nil {
+                return err
+            } else if fewer {
+                if err := counts.Set(env, entry.Key, theirs); err != nil {
+                    return err
+                }
+            }
+        }
+        if err := keepPositive(env, counts); err != nil {
+            return err
+        }
+        return args[0]
+    })
+}
+
+// addCount is counts[key] += count, or -=
+func addCount(env *Environment, counts *Dict, operator string, key, count Object) *Error {
+    current, err := counterGet(env, counts, key)
+    if err != nil {
+        return err
+    }
+    value, err := errorPair(binaryOperation(env, operator, current, count))
+    if err != nil {
+        return err
+    }
+    return counts.Set(env, key, value)
+}
+
+// errorPair splits a result that may be an error into the usual Go pair
+func errorPair(result Object) (Object, *Error) {
+    if err, ok := result.(*Error); ok {
+        return nil, err
+    }
+    return result, nil
+}
+
+// ChainMap looks through a list of mappings in turn; writes only ever go to
+// the first. Like CPython's, it keeps that list in self.maps.
+
+func chainMaps(env *Environment, self Object) ([]Object, *Error) {
+    maps := getAttribute(env, self, "maps")
+    if err, ok := maps.(*Error); ok {
+        return nil, err
+    }
+    if list, ok := payload(maps).(*List); ok {
+        return list.Elements, nil
+    }
+    return iterableToSlice(env, maps)
+}
+
+// firstMap is maps[0], where every change lands
+func firstMap(env *Environment, self Object) (Object, *Error) {
+    maps, err := chainMaps(env, self)
+    if err != nil {
+        return nil, err
+    }
+    if len(maps) == 0 {
+        return nil, indexError("list index out of range")
+    }
+    return maps[0], nil
+}
+
+// chainMapDict flattens the chain into one dict, keys in the order ChainMap
+// iterates them: the last mapping's first
+func chainMapDict(env *Environment, self Object) (*Dict, *Error) {
+    maps, err := chainMaps(env, self)
+    if err != nil {
+        return nil, err
+    }
+    merged := NewDict()
+    for i := len(maps) - 1; i >= 0; i-- {
+        if err := mergeInto(env, merged, maps[i]); err != nil {
+            return nil, err
+        }
+    }
+    return merged, nil
+}
+
+// firstMappingError turns the KeyError of a write to maps[0] into ChainMap's own
+func firstMappingError(result Object, format string, a ...interface{}) Object {
+    if err, ok := result.(*Error); ok && err.matches(keyErrorType) {
+        return keyError(&String{Value: fmt.Sprintf(format, a...)})
+    }
+    return result
+}
+
+func isMapping(obj Object) bool {
+    if _, ok := payload(obj).(*Dict); ok {
+        return true
+    }
+    return typeOf(obj).lookupName("keys") != nil && typeOf(obj).lookupName("__getitem__") != nil
+}
+
+func initChainMap() {
+    chainMapType.define("__init__", func(env *Environment, args []Object, kwargs *Dict) Object {
+        for _, entry := range kwargs.Entries() {
+            return typeError("ChainMap.__init__() got an unexpected keyword argument '%s'", entry.Key.(*String).Value)
+        }
+        maps := append([]Object{}, args[1:]...)
+        if len(maps) == 0 {
+            maps = append(maps, NewDict())
+        }
+        return setField(env, args[0], "maps", &List{Elements: maps})
+    })
+    chainMapType.Dict.SetStr("__hash__", NULL)
+    chainMapType.method("__missing__", 1, 1, func(env *Environment, args []Object) Object {
+        return keyError(args[1])
+    })
+    chainMapType.method("__getitem__", 1, 1, func(env *Environment, args []Object) Object {
+        maps, err := chainMaps(env, args[0])
+        if err != nil {
+            return err
+        }
+        for _, mapping := range maps {
+            value := getItem(env, mapping, args[1])
+            if err, ok := value.(*Error); !ok || !err.matches(keyErrorType) {
+                return value
+            }
+        }
+        return callMethod(env, typeOf(args[0]).lookupName("__missing__"), args[0], args[1])
+    })
+    chainMapType.method("get", 1, 2, func(env *Environment, args []Object) Object {
+        found, err := contains(env, args[0], args[1])
+        if err != nil {
+            return err
+        }
+        if found {
+            return getItem(env, args[0], args[1])
+        }
+        if len(args) == 3 {
+            return args[2]
+        }
+        return NULL
+    })
+    chainMapType.method("__contains__", 1, 1, func(env *Environment, args []Object) Object {
+        maps, err := chainMaps(env, args[0])
+        if err != nil {
+            return err
+        }
+        for _, mapping := range maps {
+            found, err := contains(env, mapping, args[1])
+            if err != nil {
+                return err
+            }
+            if found {
+                return TRUE
+            }
+        }
+        return FALSE
+    })
+    chainMapType.method("__len__", 0, 0, func(env *Environment, args []Object) Object {
+        merged, err := chainMapDict(env, args[0])
+        if err != nil {
+            return err
+        }
+        return newInt(int64(merged.Len()))
+    })
+    chainMapType.method("__bool__", 0, 0, func(env *Environment, args []Object) Object {
+        maps, err := chainMaps(env, args[0])
+        if err != nil {
+            return err
+        }
+        for _, mapping := range maps {
+            nonEmpty, err := truthy(env, mapping)
+            if err != nil {
+                return err
+            }
+            if nonEmpty {
+                return TRUE
+            }
+        }
+        return FALSE
+    })
+    chainMapType.method("__iter__", 0, 0, func(env *Environment, args []Object) Object {
+        merged, err := chainMapDict(env, args[0])
+        if err != nil {
+            return err
+        }
+        return merged.iterator(dictKeyIteratorType, "dictionary", func(entry *dictEntry) Object { return entry.Key })
+    })
+    // The views are of a snapshot - the chain has no single dict to watch
+    for name, class := range map[string]*Class{"keys": dictKeysType, "values": dictValuesType, "items": dictItemsType} {
+        class := class
+        chainMapType.method(name, 0, 0, func(env *Environment, args []Object) Object {
+            merged, err := chainMapDict(env, args[0])
+            if err != nil {
+                return err
+            }
+            return &DictView{class: class, dict: merged}
+        })
+    }
+    chainMapType.method("__eq__", 1, 1, func(env *Environment, args []Object) Object {
+        if !isMapping(args[1]) {
+            return NotImplemented
+        }
+        mine, err := chainMapDict(env, args[0])
+        if err != nil {
+            return err
+        }
+        theirs := NewDict()
+        if err := mergeInto(env, theirs, args[1]); err != nil {
+            return err
+        }
+        return dictEquals(env, mine, theirs)
+    })
+    chainMapType.method("__repr__", 0, 0, func(env *Environment, args []Object) Object {
+        maps, err := chainMaps(env, args[0])
+        if err != nil {
+            return err
+        }
+        s, ok, err := reprJoin(env, args[0], maps, ", ")
+        if err != nil {
+            return err
+        }
+        if !ok {
+            return &String{Value: "..."}
+        }
+        return &String{Value: typeName(args[0]) + "(" + s + ")"}
+    })
+    chainMapType.Dict.SetStr("fromkeys", &ClassMethod{Function: &Builtin{Name: "fromkeys", Fn: func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("fromkeys", args[1:], kwargs, 1, 2); err != nil {
+            return err
+        }
+        keys := dictFromKeys(env, dictType, args[1:])
+        if isError(keys) {
+            return keys
+        }
+        return applyFunction(env, args[0], []Object{keys}, nil)
+    }}, Dict: NewDict()})
+    for _, name := range []string{"copy", "__copy__"} {
+        chainMapType.method(name, 0, 0, func(env *Environment, args []Object) Object {
+            maps, err := chainMaps(env, args[0])
+            if err != nil {
+                return err
+            }
+            if len(maps) == 0 {
+                return indexError("list index out of range")
+            }
+            first := callAttribute(env, maps[0], "copy")
+            if isError(first) {
+                return first
+            }
+            return applyFunction(env, typeOf(args[0]), append([]Object{first}, maps[1:]...), nil)
+        })
+    }
+    chainMapType.define("new_child", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if len(args) > 2 {
+            return typeError("ChainMap.new_child() takes from 1 to 2 positional arguments but %d were given", len(args))
+        }
+        maps, err := chainMaps(env, args[0])
+        if err != nil {
+            return err
+        }
+        var child Object = NULL
+        if len(args) == 2 {
+            child = args[1]
+        }
+        switch {
+        case child == NULL:
+            child = kwargs.Copy()
+            if kwargs == nil {
+                child = NewDict()
+            }
+        case kwargs.Len() > 0:
+            update := getAttribute(env, child, "update")
+            if isError(update) {
+                return update
+            }
+            if result := applyFunction(env, update, nil, kwargs); isError(result) {
+                return result
+            }
+        }
+        return applyFunction(env, typeOf(args[0]), append([]Object{child}, maps...), nil)
+    })
+    chainMapType.Dict.SetStr("parents", &Property{Name: "parents", Getter: &Builtin{Name: "parents", Fn: func(env *Environment, args []Object, kwargs *Dict) Object {
+        maps, err := chainMaps(env, args[0])
+        if err != nil {
+            return err
+        }
+        if len(maps) > 0 {
+            maps = maps[1:]
+        }
+        return applyFunction(env, typeOf(args[0]), maps, nil)
+    }}})
+    chainMapType.method("__setitem__", 2, 2, func(env *Environment, args []Object) Object {
+        first, err := firstMap(env, args[0])
+        if err != nil {
+            return err
+        }
+        if err := setItem(env, first, args[1], args[2]); err != nil {
+            return err
+        }
+        return NULL
+    })
+    chainMapType.method("__delitem__", 1, 1, func(env *Environment, args []Object) Object {
+        first, err := firstMap(env, args[0])
+        if err != nil {
+            return err
+        }
+        if err := delItem(env, first, args[1]); err != nil {
+            key, _ := reprString(env, args[1])
+            return firstMappingError(err, "Key not found in the first mapping: %s", key)
+        }
+        return NULL
+    })
+    chainMapType.method("pop", 1, 2, func(env *Environment, args []Object) Object {
+        first, err := firstMap(env, args[0])
+        if err != nil {
+            return err
+        }
+        result := callAttribute(env, first, "pop", args[1:]...)
+        if isError(result) {
+            key, _ := reprString(env, args[1])
+            return firstMappingError(result, "Key not found in the first mapping: %s", key)
+        }
+        return result
+    })
+    chainMapType.method("popitem", 0, 0, func(env *Environment, args []Object) Object {
+        first, err := firstMap(env, args[0])
+        if err != nil {
+            return err
+        }
+        return firstMappingError(callAttribute(env, first, "popitem"), "No keys found in the first mapping.")
+    })
+    chainMapType.method("clear", 0, 0, func(env *Environment, args []Object) Object {
+        first, err := firstMap(env, args[0])
+        if err != nil {
+            return err
+        }
+        return callAttribute(env, first, "clear")
+    })
+    chainMapType.method("setdefault", 1, 2, func(env *Environment, args []Object) Object {
+        found, err := contains(env, args[0], args[1])
+        if err != nil {
+            return err
+        }
+        if found {
+            return getItem(env, args[0], args[1])
+        }
+        var value Object = NULL
+        if len(args) == 3 {
+            value = args[2]
+        }
+        if err := setItem(env, args[0], args[1], value); err != nil {
+            return err
+        }
+        return value
+    })
+    chainMapType.define("update", func(env *Environment, args []Object, kwargs *Dict) Object {
+        pairs := NewDict()
+        if err := dictUpdate(env, pairs, args[1:], kwargs, "update"); err != nil {
+            return err
+        }
+        for _, entry := range pairs.Entries() {
+            if err := setItem(env, args[0], entry.Key, entry.Value); err != nil {
+                return err
+            }
+        }
+        return NULL
+    })
+    chainMapType.method("__ior__", 1, 1, func(env *Environment, args []Object) Object {
+        first, err := firstMap(env, args[0])
+        if err != nil {
+            return err
+        }
+        if result := callAttribute(env, first, "update", args[1]); isError(result) {
+            return result
+        }
+        return args[0]
+    })
+    chainMapType.method("__or__", 1, 1, func(env *Environment, args []Object) Object {
+        if !isMapping(args[1]) {
+            return NotImplemented
+        }
+        result := callAttribute(env, args[0], "copy")
+        if isError(result) {
+            return result
+        }
+        first, err := firstMap(env, result)
+        if err != nil {
+            return err
+        }
+        if updated := callAttribute(env, first, "update", args[1]); isError(updated) {
+            return updated
+        }
+        return result
+    })
+    chainMapType.method("__ror__", 1, 1, func(env *Environment, args []Object) Object {
+        if !isMapping(args[1]) {
+            return NotImplemented
+        }
+        maps, err := chainMaps(env, args[0])
+        if err != nil {
+            return err
+        }
+        merged := NewDict()
+        for _, mapping := range append([]Object{args[1]}, reverseObjects(maps)...) {
+            if err := mergeInto(env, merged, mapping); err != nil {
+                return err
+            }
+        }
+        return applyFunction(env, typeOf(args[0]), []Object{merged}, nil)
+    })
+}
+
+func reverseObjects(objects []Object) []Object {
+    reversed := make([]Object, len(objects))
+    for i, obj := range objects {
+        reversed[len(objects)-1-i] = obj
+    }
+    return reversed
+}
+
+// namedtuple makes a tuple subclass whose elements have names. CPython
+// writes the class out as source and execs it; here it is put together
+// from Go functions, with __new__ binding its arguments like a def would.
+func namedTuple(env *Environment, args []Object, kwargs *Dict) Object {
+    values, err := parseArgs("namedtuple", args, kwargs, []string{"typename", "field_names", "*rename", "*defaults", "*module"}, 2)
+    if err != nil {
+        return err
+    }
+    typename := strOf(env, values[0])
+    if isError(typename) {
+        return typename
+    }
+    name := payload(typename).(*String).Value
+
+    var fields []string
+    if s, ok := payload(values[1]).(*String); ok {
+        fields = strings.Fields(strings.ReplaceAll(s.Value, ",", " "))
+    } else {
+        err := iterate(env, values[1], func(item Object) *Error {
+            field := strOf(env, item)
+            if err, ok := field.(*Error); ok {
+                return err
+            }
+            fields = append(fields, payload(field).(*String).Value)
+            return nil
+        })
+        if err != nil {
+            return err
+        }
+    }
+
+    rename := false
+    if values[2] != nil {
+        if rename, err = truthy(env, values[2]); err != nil {
+            return err
+        }
+    }
+    isKeyword := func(name string) bool { return token.LookupIdent(name) != token.IDENT }
+    if rename {
+        seen := map[string]bool{}
+        for i, field := range fields {
+            if !isIdentifier(field) || isKeyword(field) || strings.HasPrefix(field, "_") || seen[field] {
+                fields[i] = fmt.Sprintf("_%d", i)
+            }
+            seen[field] = true
+        }
+    }
+    for _, field := range append([]string{name}, fields...) {
+        if !isIdentifier(field) {
+            return valueError("Type names and field names must be valid identifiers: %s", strRepr(field))
+        }
+        if isKeyword(field) {
+            return valueError("Type names and field names cannot be a keyword: %s", strRepr(field))
+        }
+    }
+    seen := map[string]bool{}
+    for _, field := range fields {
+        if strings.HasPrefix(field, "_") && !rename {
+            return valueError("Field names cannot start with an underscore: %s", strRepr(field))
+        }
+        if seen[field] {
+            return valueError("Encountered duplicate field name: %s", strRepr(field))
+        }
+        seen[field] = true
+    }
+
+    var defaults []Object
+    if values[3] != nil && values[3] != NULL {
+        if defaults, err = iterableToSlice(env, values[3]); err != nil {
+            return err
+        }
+        if len(defaults) > len(fields) {
+            return typeError("Got more default values than field names")
+        }
+    }
+    fieldDefaults := NewDict()
+    for i, value := range defaults {
+        fieldDefaults.SetStr(fields[len(fields)-len(defaults)+i], value)
+    }
+
+    module := Object(&String{Value: env.moduleName()})
+    if values[4] != nil && values[4] != NULL {
+        module = values[4]
+    }
+    names := make([]Object, len(fields))
+    for i, field := range fields {
+        names[i] = &String{Value: field}
+    }
+
+    // __new__ is a def in all but body, so arguments bind the way they would to one
+    signature := &Function{
+        Name:      "__new__",
+        Qualname:  name + ".__new__",
+        Signature: &parser.Signature{Parameters: append([]string{"_cls"}, fields...)},
+        Defaults:  defaults,
+        Env:       env,
+        Doc:       &String{Value: "Create new instance of " + name + "(" + strings.Join(fields, ", ") + ")"},
+    }
+    dict := NewDict()
+    dict.SetStr("__module__", module)
+    dict.SetStr("__qualname__", &String{Value: name})
+    dict.SetStr("__doc__", &String{Value: name + "(" + strings.Join(fields, ", ") + ")"})
+    dict.SetStr("_fields", &Tuple{Elements: names})
+    dict.SetStr("_field_defaults", fieldDefaults)
+    dict.SetStr("__match_args__", &Tuple{Elements: names})
+    dict.SetStr("__new__", &Builtin{Name: "__new__", Wraps: signature, Fn: func(env *Environment, args []Object, kwargs *Dict) Object {
+        scope := NewEnclosedEnvironment(env)
+        if err := bindArguments(signature, scope, args, kwargs); err != nil {
+            return err
+        }
+        cls, err := newClassArg("tuple", []Object{scope.store["_cls"]})
+        if err != nil {
+            return err
+        }
+        elements := make([]Object, len(fields))
+        for i, field := range fields {
+            elements[i] = scope.store[field]
+        }
+        return wrapBuiltinValue(cls, tupleType, &Tuple{Elements: elements})
+    }})
+    dict.SetStr("_make", &ClassMethod{Function: &Builtin{Name: "_make", Fn: func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("_make", args[1:], kwargs, 1, 1); err != nil {
+            return err
+        }
+        elements, err := iterableToSlice(env, args[1])
+        if err != nil {
+            return err
+        }
+        if len(elements) != len(fields) {
+            return typeError("Expected %d arguments, got %d", len(fields), len(elements))
+        }
+        cls, err := newClassArg("tuple", args[:1])
+        if err != nil {
+            return err
+        }
+        return wrapBuiltinValue(cls, tupleType, &Tuple{Elements: elements})
+    }}, Dict: NewDict()})
+    for i, field := range fields {
+        i := i
+        dict.SetStr(field, &Property{
+            Getter: &Builtin{Name: field, Fn: func(env *Environment, args []Object, kwargs *Dict) Object {
+                return asTuple(args[0]).Elements[i]
+            }},
+            Doc:  &String{Value: fmt.Sprintf("Alias for field number %d", i)},
+            Name: field,
+        })
+    }
+
+    cls, classErr := newClass(name, []*Class{tupleType}, dict)
+    if classErr != nil {
+        return classErr
+    }
+    cls.method("__repr__", 0, 0, func(env *Environment, args []Object) Object {
+        parts := make([]string, len(fields))
+        for i, value := range asTuple(args[0]).Elements {
+            s, err := reprString(env, value)
+            if err != nil {
+                return err
+            }
+            parts[i] = fields[i] + "=" + s
+        }
+        return &String{Value: typeName(args[0]) + "(" + strings.Join(parts, ", ") + ")"}
+    })
+    cls.define("_replace", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("_replace", args[1:], nil, 0, 0); err != nil {
+            return err
+        }
+        changes := kwargs.Copy()
+        if kwargs == nil {
+            changes = NewDict()
+        }
+        elements := append([]Object{}, asTuple(args[0]).Elements...)
+        for i, field := range fields {
+            if value, ok := changes.GetStr(field); ok {
+                elements[i] = value
+                changes.deleteStr(field)
+            }
+        }
+        if changes.Len() > 0 {
+            unexpected := make([]Object, 0, changes.Len())
+            for _, entry := range changes.Entries() {
+                unexpected = append(unexpected, entry.Key)
+            }
+            return valueError("Got unexpected field names: %s", (&List{Elements: unexpected}).Inspect())
+        }
+        maker := getAttribute(env, args[0], "_make")
+        if isError(maker) {
+            return maker
+        }
+        return applyFunction(env, maker, []Object{&Tuple{Elements: elements}}, nil)
+    })
+    cls.method("_asdict", 0, 0, func(env *Environment, args []Object) Object {
+        result := NewDict()
+        for i, value := range asTuple(args[0]).Elements {
+            result.SetStr(fields[i], value)
+        }
+        return result
+    })
+    cls.method("__getnewargs__", 0, 0, func(env *Environment, args []Object) Object {
+        return &Tuple{Elements: asTuple(args[0]).Elements}
+    })
+    return cls
+}
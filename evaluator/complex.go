@@ -0,0 +1,892 @@
+// Comments in this file are inspired by Daniel Hardman - there is always a part of him you can't see
+
+package evaluator
+
+import (
+    "math"
+    "math/cmplx"
+    "strings"
+)
+
+// Complex is a + bj: one real part, one imaginary part, both floats
+type Complex struct {
+    Value complex128
+}
+
+func (c *Complex) Type() ObjectType { return COMPLEX_OBJ }
+func (c *Complex) Inspect() string  { return complexRepr(c.Value) }
+
+var complexType = newBuiltinClass("complex", objectType)
+
+func asComplex(obj Object) *Complex { return payload(obj).(*Complex) }
+
+// toComplex accepts anything complex arithmetic will take
+func toComplex(obj Object) (complex128, bool, *Error) {
+    if c, ok := payload(obj).(*Complex); ok {
+        return c.Value, true, nil
+    }
+    f, ok, err := toFloat(obj)
+    return complex(f, 0), ok, err
+}
+
+// complexPart is one half of a complex repr: a float repr without the .0
+func complexPart(f float64) string {
+    return strings.TrimSuffix(floatRepr(f), ".0")
+}
+
+// complexRepr leaves out a real part that is a plain zero, like CPython
+func complexRepr(c complex128) string {
+    re, im := real(c), imag(c)
+    if re == 0 && !math.Signbit(re) {
+        return complexPart(im) + "j"
+    }
+    sign := "+"
+    if math.Signbit(im) && !math.IsNaN(im) {
+        sign, im = "-", -im
+    }
+    return "(" + complexPart(re) + sign + complexPart(im) + "j)"
+}
+
+// hashComplex combines the hashes of the parts the way CPython does, so
+// hash(2+0j) == hash(2)
+func hashComplex(c complex128) int64 {
+    h := uint64(hashFloat(real(c))) + 1000003*uint64(hashFloat(imag(c)))
+    if int64(h) == -1 {
+        return -2
+    }
+    return int64(h)
+}
+
+// complexProduct spells out the multiplication so no fused instructions
+// change the last bit
+func complexProduct(a, b complex128) complex128 {
+    return complex(float64(real(a)*real(b))-float64(imag(a)*imag(b)), float64(real(a)*imag(b))+float64(imag(a)*real(b)))
+}
+
+// complexQuotient is CPython's _Py_c_quot, Smith's method. ok is false for
+// division by zero.
+func complexQuotient(a, b complex128) (complex128, bool) {
+    absReal, absImag := math.Abs(real(b)), math.Abs(imag(b))
+    switch {
+    case absReal >= absImag:
+        if absReal == 0 {
+            return 0, false
+        }
+        ratio := imag(b) / real(b)
+        denom := real(b) + imag(b)*ratio
+        return complex((real(a)+imag(a)*ratio)/denom, (imag(a)-real(a)*ratio)/denom), true
+    case absImag >= absReal:
+        ratio := real(b) / imag(b)
+        denom := real(b)*ratio + imag(b)
+        return complex((real(a)*ratio+imag(a))/denom, (imag(a)*ratio-real(a))/denom), true
+    }
+    // at least one of the parts of b is a nan
+    return complex(math.NaN(), math.NaN()), true
+}
+
+// complexPow follows CPython: small whole powers are repeated
+// multiplication, everything else goes through polar form
+func complexPow(a, b complex128) Object {
+    var result complex128
+    switch {
+    case imag(b) == 0 && real(b) == math.Floor(real(b)) && math.Abs(real(b)) <= 100:
+        n := int(real(b))
+        if n < 0 {
+            var ok bool
+            if result, ok = complexQuotient(1, complexPowInt(a, -n)); !ok {
+                return zeroDivisionError("0.0 to a negative or complex power")
+            }
+        } else {
+            result = complexPowInt(a, n)
+        }
+    case b == 0:
+        result = 1
+    case a == 0:
+        if imag(b) != 0 || real(b) < 0 {
+            return zeroDivisionError("0.0 to a negative or complex power")
+        }
+        result = 0
+    default:
+        magnitude := hypot(real(a), imag(a))
+        length := power(magnitude, real(b))
+        angle := arctan2(imag(a), real(a))
+        phase := angle * real(b)
+        if imag(b) != 0 {
+            length /= exponential(angle * imag(b))
+            phase += imag(b) * logarithm(magnitude)
+        }
+        result = complex(length*cosine(phase), length*sine(phase))
+    }
+    if math.IsInf(real(result), 0) || math.IsInf(imag(result), 0) {
+        return overflowError("complex exponentiation")
+    }
+    return &Complex{Value: result}
+}
+
+// complexPowInt is square-and-multiply, CPython's c_powu
+func complexPowInt(x complex128, n int) complex128 {
+    result, power := complex128(1), x
+    for mask := 1; mask > 0 && n >= mask; mask <<= 1 {
+        if n&mask != 0 {
+            result = complexProduct(result, power)
+        }
+        power = complexProduct(power, power)
+    }
+    return result
+}
+
+// complexArithmetic is the native side of every complex binary operator
+func complexArithmetic(operator string, a, b complex128) Object {
+    switch operator {
+    case "+":
+        return &Complex{Value: a + b}
+    case "-":
+        return &Complex{Value: a - b}
+    case "*":
+        return &Complex{Value: complexProduct(a, b)}
+    case "/":
+        quotient, ok := complexQuotient(a, b)
+        if !ok {
+            return zeroDivisionError("complex division by zero")
+        }
+        return &Complex{Value: quotient}
+    case "**":
+        return complexPow(a, b)
+    }
+    return NotImplemented
+}
+
+// scanFloat reads the longest float at the front of s and says how much of
+// s it used; zero means there was no float there
+func scanFloat(s string) (float64, int) {
+    i := 0
+    if i < len(s) && (s[i] == '+' || s[i] == '-') {
+        i++
+    }
+    lower := strings.ToLower(s[i:])
+    for _, word := range []string{"infinity", "inf", "nan"} {
+        if strings.HasPrefix(lower, word) {
+            f, _ := parseFloatString(s[:i+len(word)])
+            return f, i + len(word)
+        }
+    }
+    digits := false
+    for i < len(s) && (isDigit(s[i]) || s[i] == '_') {
+        i, digits = i+1, true
+    }
+    if i < len(s) && s[i] == '.' {
+        i++
+        for i < len(s) && (isDigit(s[i]) || s[i] == '_') {
+            i, digits = i+1, true
+        }
+    }
+    if !digits {
+        return 0, 0
+    }
+    if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+        j := i + 1
+        if j < len(s) && (s[j] == '+' || s[j] == '-') {
+            j++
+        }
+        if j < len(s) && isDigit(s[j]) {
+            for j < len(s) && (isDigit(s[j]) || s[j] == '_') {
+                j++
+            }
+            i = j
+        }
+    }
+    f, ok := parseFloatString(s[:i])
+    if !ok {
+        return 0, 0
+    }
+    return f, i
+}
+
+// parseComplexString is complex(s): a real part, an imaginary part or both,
+// optionally in parentheses
+func parseComplexString(s string) (complex128, bool) {
+    s = strings.TrimSpace(s)
+    if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+        s = strings.TrimSpace(s[1 : len(s)-1])
+    }
+    isJ := func(s string) bool { return s != "" && (s[0] == 'j' || s[0] == 'J') }
+    sign := func(s string) float64 {
+        if s[0] == '-' {
+            return -1
+        }
+        return 1
+    }
+
+    var re, im float64
+    x, n := scanFloat(s)
+    rest := s[n:]
+    switch {
+    case n > 0 && isJ(rest):
+        im, rest = x, rest[1:]
+    case n > 0 && rest != "" && (rest[0] == '+' || rest[0] == '-'):
+        re = x
+        if y, m := scanFloat(rest); m > 0 {
+            im, rest = y, rest[m:]
+        } else {
+            im, rest = sign(rest), rest[1:]
+        }
+        if !isJ(rest) {
+            return 0, false
+        }
+        rest = rest[1:]
+    case n > 0:
+        re = x
+    default:
+        im = 1
+        if rest != "" && (rest[0] == '+' || rest[0] == '-') {
+            im, rest = sign(rest), rest[1:]
+        }
+        if !isJ(rest) {
+            return 0, false
+        }
+        rest = rest[1:]
+    }
+    return complex(re, im), rest == ""
+}
+
+// isNumber is whether complex() and cmath will take obj as a number
+func isNumber(obj Object) bool {
+    switch payload(obj).(type) {
+    case *Integer, *Boolean, *Float, *Complex:
+        return true
+    }
+    cls := typeOf(obj)
+    return cls.lookupName("__complex__") != nil || cls.lookupName("__float__") != nil || cls.lookupName("__index__") != nil
+}
+
+// complexValue reads one number for complex() or cmath, which take
+// anything with __complex__, __float__ or __index__. isComplex tells the
+// constructor whether an imaginary part came along.
+func complexValue(env *Environment, obj Object) (value complex128, isComplex bool, err *Error) {
+    if c, ok := payload(obj).(*Complex); ok {
+        return c.Value, true, nil
+    }
+    cls := typeOf(obj)
+    if method := cls.lookupName("__complex__"); method != nil {
+        result := callMethod(env, method, obj)
+        if isError(result) {
+            return 0, false, result.(*Error)
+        }
+        c, ok := payload(result).(*Complex)
+        if !ok {
+            return 0, false, typeError("__complex__ returned non-complex (type %s)", typeName(result))
+        }
+        return c.Value, true, nil
+    }
+    f := newFloatValue(env, obj)
+    if isError(f) {
+        return 0, false, f.(*Error)
+    }
+    return complex(f.(*Float).Value, 0), false, nil
+}
+
+// newComplexValue is complex(real, imag)
+func newComplexValue(env *Environment, r, i Object) Object {
+    if s, ok := payload(r).(*String); ok {
+        if i != nil {
+            return typeError("complex() can't take second arg if first is a string")
+        }
+        value, ok := parseComplexString(s.Value)
+        if !ok {
+            return valueError("complex() arg is a malformed string")
+        }
+        return &Complex{Value: value}
+    }
+    if i != nil {
+        if _, ok := payload(i).(*String); ok {
+            return typeError("complex() second arg can't be a string")
+        }
+    }
+    if r == nil {
+        r = newInt(0)
+    }
+    if !isNumber(r) {
+        return typeError("complex() first argument must be a string or a number, not '%s'", typeName(r))
+    }
+    if i != nil && !isNumber(i) {
+        return typeError("complex() second argument must be a number, not '%s'", typeName(i))
+    }
+
+    cr, crComplex, err := complexValue(env, r)
+    if err != nil {
+        return err
+    }
+    re, im := real(cr), imag(cr)
+    if i != nil {
+        ci, ciComplex, err := complexValue(env, i)
+        if err != nil {
+            return err
+        }
+        // complex(a, b) is a + b*1j, so an imaginary part in b moves across
+        if ciComplex {
+            re -= imag(ci)
+        }
+        if crComplex {
+            im += real(ci)
+        } else {
+            im = real(ci)
+        }
+    }
+    return &Complex{Value: complex(re, im)}
+}
+
+func init() {
+    complexType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        cls, err := newClassArg("complex", args)
+        if err != nil {
+            return err
+        }
+        params, err := parseArgs("complex", args[1:], kwargs, []string{"real", "imag"}, 0)
+        if err != nil {
+            return err
+        }
+        value := newComplexValue(env, params[0], params[1])
+        if isError(value) {
+            return value
+        }
+        return wrapBuiltinValue(cls, complexType, value)
+    })
+    for _, operator := range []string{"+", "-", "*", "/", "**"} {
+        operator := operator
+        names := binaryOperators[operator]
+        complexType.method(names.method, 1, 1, func(env *Environment, args []Object) Object {
+            a, _, _ := toComplex(args[0])
+            b, ok, err := toComplex(args[1])
+            if !ok {
+                return NotImplemented
+            }
+            if err != nil {
+                return err
+            }
+            return complexArithmetic(operator, a, b)
+        })
+        complexType.method(names.reflected, 1, 1, func(env *Environment, args []Object) Object {
+            a, _, _ := toComplex(args[0])
+            b, ok, err := toComplex(args[1])
+            if !ok {
+                return NotImplemented
+            }
+            if err != nil {
+                return err
+            }
+            return complexArithmetic(operator, b, a)
+        })
+    }
+    for _, operator := range []string{"==", "!="} {
+        operator := operator
+        complexType.method(comparisonOperators[operator][0], 1, 1, func(env *Environment, args []Object) Object {
+            a := asComplex(args[0]).Value
+            var equal bool
+            switch b := payload(args[1]).(type) {
+            case *Complex:
+                equal = a == b.Value
+            case *Float:
+                equal = a == complex(b.Value, 0)
+            default:
+                n, ok := toBigInt(args[1])
+                if !ok {
+                    return NotImplemented
+                }
+                // compare exactly, even past 2**53
+                equal = imag(a) == 0 && compareFloatInt("==", real(a), n) == TRUE
+            }
+            if operator == "!=" {
+                equal = !equal
+            }
+            return nativeBool(equal)
+        })
+    }
+    complexType.method("__neg__", 0, 0, func(env *Environment, args []Object) Object {
+        return &Complex{Value: -asComplex(args[0]).Value}
+    })
+    complexType.method("__pos__", 0, 0, func(env *Environment, args []Object) Object {
+        return &Complex{Value: asComplex(args[0]).Value}
+    })
+    complexType.method("__abs__", 0, 0, func(env *Environment, args []Object) Object {
+        c := asComplex(args[0]).Value
+        magnitude := hypot(real(c), imag(c))
+        if math.IsInf(magnitude, 0) && !cmplx.IsInf(c) {
+            return overflowError("absolute value too large")
+        }
+        return &Float{Value: magnitude}
+    })
+    complexType.method("__bool__", 0, 0, func(env *Environment, args []Object) Object {
+        return nativeBool(asComplex(args[0]).Value != 0)
+    })
+    complexType.method("__hash__", 0, 0, func(env *Environment, args []Object) Object {
+        return newInt(hashComplex(asComplex(args[0]).Value))
+    })
+    complexType.method("__repr__", 0, 0, func(env *Environment, args []Object) Object {
+        return &String{Value: complexRepr(asComplex(args[0]).Value)}
+    })
+    complexType.method("__complex__", 0, 0, func(env *Environment, args []Object) Object {
+        return &Complex{Value: asComplex(args[0]).Value}
+    })
+    complexType.method("conjugate", 0, 0, func(env *Environment, args []Object) Object {
+        return &Complex{Value: cmplx.Conj(asComplex(args[0]).Value)}
+    })
+    complexType.property("real", func(self Object) Object {
+        return &Float{Value: real(asComplex(self).Value)}
+    })
+    complexType.property("imag", func(self Object) Object {
+        return &Float{Value: imag(asComplex(self).Value)}
+    })
+
+    // ints and floats are complex numbers too, with nothing imaginary about them
+    intType.property("real", func(self Object) Object {
+        n, _ := toBigInt(self)
+        return newBigInt(n)
+    })
+    intType.property("imag", func(self Object) Object {
+        return newInt(0)
+    })
+    intType.property("numerator", func(self Object) Object {
+        n, _ := toBigInt(self)
+        return newBigInt(n)
+    })
+    intType.property("denominator", func(self Object) Object {
+        return newInt(1)
+    })
+    intType.method("conjugate", 0, 0, func(env *Environment, args []Object) Object {
+        n, _ := toBigInt(args[0])
+        return newBigInt(n)
+    })
+    floatType.property("real", func(self Object) Object {
+        f, _, _ := toFloat(self)
+        return &Float{Value: f}
+    })
+    floatType.property("imag", func(self Object) Object {
+        return &Float{Value: 0}
+    })
+    floatType.method("conjugate", 0, 0, func(env *Environment, args []Object) Object {
+        f, _, _ := toFloat(args[0])
+        return &Float{Value: f}
+    })
+}
+
+// The cmath kernels below are CPython's, which rescale around overflow and
+// use log1p where the obvious formula cancels. They see finite input only;
+// infinities and nans are left to Go's C99 special cases.
+const (
+    cmLargeDouble     = math.MaxFloat64 / 4
+    cmLogLargeDouble  = 708.3964185322641 // log(cmLargeDouble)
+    cmSqrtLargeDouble = 6.703903964971298e+153
+    cmSqrtDoubleMin   = 1.4916681462400413e-154
+    doubleMin         = 2.2250738585072014e-308
+)
+
+func cmathSqrt(z complex128) complex128 {
+    if z == 0 {
+        return complex(0, imag(z))
+    }
+    ax, ay := math.Abs(real(z)), math.Abs(imag(z))
+    var s float64
+    if ax < doubleMin && ay < doubleMin {
+        // hypot(ax, ay) would be subnormal: scale up first
+        ax = math.Ldexp(ax, 53)
+        s = math.Ldexp(math.Sqrt(ax+hypot(ax, math.Ldexp(ay, 53))), -27)
+    } else {
+        ax /= 8
+        s = 2 * math.Sqrt(ax+hypot(ax, ay/8))
+    }
+    d := ay / (2 * s)
+    if real(z) >= 0 {
+        return complex(s, math.Copysign(d, imag(z)))
+    }
+    return complex(d, math.Copysign(s, imag(z)))
+}
+
+func cmathExp(z complex128) complex128 {
+    if real(z) > cmLogLargeDouble {
+        l := exponential(real(z) - 1)
+        return complex(l*cosine(imag(z))*math.E, l*sine(imag(z))*math.E)
+    }
+    l := exponential(real(z))
+    return complex(l*cosine(imag(z)), l*sine(imag(z)))
+}
+
+func cmathLog(z complex128) complex128 {
+    ax, ay := math.Abs(real(z)), math.Abs(imag(z))
+    var re float64
+    switch {
+    case ax > cmLargeDouble || ay > cmLargeDouble:
+        re = logarithm(hypot(ax/2, ay/2)) + math.Ln2
+    case ax < doubleMin && ay < doubleMin:
+        if ax == 0 && ay == 0 {
+            re = math.Inf(-1)
+        } else {
+            re = logarithm(hypot(math.Ldexp(ax, 53), math.Ldexp(ay, 53))) - 53*math.Ln2
+        }
+    default:
+        h := hypot(ax, ay)
+        if 0.71 <= h && h <= 1.73 {
+            am, an := math.Max(ax, ay), math.Min(ax, ay)
+            re = math.Log1p((am-1)*(am+1)+an*an) / 2
+        } else {
+            re = logarithm(h)
+        }
+    }
+    return complex(re, arctan2(imag(z), real(z)))
+}
+
+func cmathLog10(z complex128) complex128 {
+    l := cmathLog(z)
+    return complex(real(l)/math.Ln10, imag(l)/math.Ln10)
+}
+
+// largeLog is log|z| for a z so big that |z| itself would overflow
+func largeLog(z complex128) float64 {
+    return logarithm(hypot(real(z)/2, imag(z)/2)) + 2*math.Ln2
+}
+
+func cmathAcos(z complex128) complex128 {
+    if math.Abs(real(z)) > cmLargeDouble || math.Abs(imag(z)) > cmLargeDouble {
+        re := arctan2(math.Abs(imag(z)), real(z))
+        if real(z) < 0 {
+            return complex(re, -math.Copysign(largeLog(z), imag(z)))
+        }
+        return complex(re, math.Copysign(largeLog(z), -imag(z)))
+    }
+    s1 := cmathSqrt(complex(1-real(z), -imag(z)))
+    s2 := cmathSqrt(complex(1+real(z), imag(z)))
+    return complex(2*arctan2(real(s1), real(s2)), arcsinh(real(s2)*imag(s1)-imag(s2)*real(s1)))
+}
+
+func cmathAcosh(z complex128) complex128 {
+    if math.Abs(real(z)) > cmLargeDouble || math.Abs(imag(z)) > cmLargeDouble {
+        return complex(largeLog(z), arctan2(imag(z), real(z)))
+    }
+    s1 := cmathSqrt(complex(real(z)-1, imag(z)))
+    s2 := cmathSqrt(complex(real(z)+1, imag(z)))
+    return complex(arcsinh(real(s1)*real(s2)+imag(s1)*imag(s2)), 2*arctan2(imag(s1), real(s2)))
+}
+
+func cmathAsinh(z complex128) complex128 {
+    if math.Abs(real(z)) > cmLargeDouble || math.Abs(imag(z)) > cmLargeDouble {
+        re := math.Copysign(largeLog(z), real(z))
+        if imag(z) < 0 {
+            re = -math.Copysign(largeLog(z), -real(z))
+        }
+        return complex(re, arctan2(imag(z), math.Abs(real(z))))
+    }
+    s1 := cmathSqrt(complex(1+imag(z), -real(z)))
+    s2 := cmathSqrt(complex(1-imag(z), real(z)))
+    return complex(arcsinh(real(s1)*imag(s2)-real(s2)*imag(s1)), arctan2(imag(z), real(s1)*real(s2)-imag(s1)*imag(s2)))
+}
+
+func cmathAtanh(z complex128) complex128 {
+    if real(z) < 0 {
+        return -cmathAtanh(-z)
+    }
+    ay := math.Abs(imag(z))
+    switch {
+    case real(z) > cmSqrtLargeDouble || ay > cmSqrtLargeDouble:
+        h := hypot(real(z)/2, imag(z)/2)
+        return complex(real(z)/4/h/h, -math.Copysign(math.Pi/2, -imag(z)))
+    case real(z) == 1 && ay < cmSqrtDoubleMin:
+        if ay == 0 {
+            return complex(math.Inf(1), imag(z))
+        }
+        return complex(-logarithm(math.Sqrt(ay)/math.Sqrt(hypot(ay, 2))), math.Copysign(arctan2(2, -ay)/2, imag(z)))
+    }
+    re := math.Log1p(4*real(z)/((1-real(z))*(1-real(z))+ay*ay)) / 4
+    return complex(re, -arctan2(-2*imag(z), (1-real(z))*(1+real(z))-ay*ay)/2)
+}
+
+func cmathCosh(z complex128) complex128 {
+    if math.Abs(real(z)) > cmLogLargeDouble {
+        x := real(z) - math.Copysign(1, real(z))
+        return complex(cosine(imag(z))*hyperbolicCosine(x)*math.E, sine(imag(z))*hyperbolicSine(x)*math.E)
+    }
+    return complex(cosine(imag(z))*hyperbolicCosine(real(z)), sine(imag(z))*hyperbolicSine(real(z)))
+}
+
+func cmathSinh(z complex128) complex128 {
+    if math.Abs(real(z)) > cmLogLargeDouble {
+        x := real(z) - math.Copysign(1, real(z))
+        return complex(cosine(imag(z))*hyperbolicSine(x)*math.E, sine(imag(z))*hyperbolicCosine(x)*math.E)
+    }
+    return complex(cosine(imag(z))*hyperbolicSine(real(z)), sine(imag(z))*hyperbolicCosine(real(z)))
+}
+
+func cmathTanh(z complex128) complex128 {
+    if math.Abs(real(z)) > cmLogLargeDouble {
+        return complex(math.Copysign(1, real(z)), 4*sine(imag(z))*cosine(imag(z))*exponential(-2*math.Abs(real(z))))
+    }
+    tx, ty := hyperbolicTangent(real(z)), tangent(imag(z))
+    cx := 1 / hyperbolicCosine(real(z))
+    txty := tx * ty
+    denom := 1 + txty*txty
+    return complex(tx*(1+ty*ty)/denom, ((ty/denom)*cx)*cx)
+}
+
+// rotated turns f on the imaginary axis into its circular cousin:
+// sin(z) = -i sinh(iz), and the same for asin, atan and tan
+func rotated(f func(complex128) complex128) func(complex128) complex128 {
+    return func(z complex128) complex128 {
+        s := f(complex(-imag(z), real(z)))
+        return complex(imag(s), -real(s))
+    }
+}
+
+// finiteOnly runs a kernel on finite input and Go's cmplx everywhere else
+func finiteOnly(kernel, special func(complex128) complex128) func(complex128) complex128 {
+    return func(z complex128) complex128 {
+        if isFiniteComplex(z) {
+            return kernel(z)
+        }
+        return special(z)
+    }
+}
+
+// cmathArgument is how cmath reads a number: anything but a string
+func cmathArgument(env *Environment, obj Object) (complex128, *Error) {
+    if !isNumber(obj) {
+        return 0, typeError("must be real number, not %s", typeName(obj))
+    }
+    value, _, err := complexValue(env, obj)
+    return value, err
+}
+
+// realArgument is a float for the cmath functions that take only reals
+func realArgument(env *Environment, obj Object) (float64, *Error) {
+    if _, ok := payload(obj).(*Complex); ok || !isNumber(obj) {
+        return 0, typeError("must be real number, not %s", typeName(obj))
+    }
+    value, _, err := complexValue(env, obj)
+    return real(value), err
+}
+
+func isFiniteComplex(z complex128) bool {
+    return !cmplx.IsInf(z) && !cmplx.IsNaN(z)
+}
+
+// cmathResult turns trouble from a finite input into Python's errors. A
+// singular function blows up at a point (log(0)), which is a domain error
+// rather than an overflow.
+func cmathResult(z, result complex128, singular bool) Object {
+    if isFiniteComplex(z) {
+        if cmplx.IsInf(result) {
+            if singular {
+                return valueError("math domain error")
+            }
+            return overflowError("math range error")
+        }
+        if cmplx.IsNaN(result) {
+            return valueError("math domain error")
+        }
+    }
+    return &Complex{Value: result}
+}
+
+func buildCmath(m *Module) {
+    unary := func(name string, fn func(complex128) complex128, singular bool) {
+        m.function(name, func(env *Environment, args []Object, kwargs *Dict) Object {
+            if err := checkArgs(name, args, kwargs, 1, 1); err != nil {
+                return err
+            }
+            z, err := cmathArgument(env, args[0])
+            if err != nil {
+                return err
+            }
+            return cmathResult(z, fn(z), singular)
+        })
+    }
+    unary("sqrt", finiteOnly(cmathSqrt, cmplx.Sqrt), false)
+    unary("exp", finiteOnly(cmathExp, cmplx.Exp), false)
+    unary("log10", finiteOnly(cmathLog10, cmplx.Log10), true)
+    unary("sin", finiteOnly(rotated(cmathSinh), cmplx.Sin), false)
+    unary("cos", finiteOnly(func(z complex128) complex128 { return cmathCosh(complex(-imag(z), real(z))) }, cmplx.Cos), false)
+    unary("tan", finiteOnly(rotated(cmathTanh), cmplx.Tan), false)
+    unary("asin", finiteOnly(rotated(cmathAsinh), cmplx.Asin), false)
+    unary("acos", finiteOnly(cmathAcos, cmplx.Acos), false)
+    unary("atan", finiteOnly(rotated(cmathAtanh), cmplx.Atan), true)
+    unary("sinh", finiteOnly(cmathSinh, cmplx.Sinh), false)
+    unary("cosh", finiteOnly(cmathCosh, cmplx.Cosh), false)
+    unary("tanh", finiteOnly(cmathTanh, cmplx.Tanh), false)
+    unary("asinh", finiteOnly(cmathAsinh, cmplx.Asinh), false)
+    unary("acosh", finiteOnly(cmathAcosh, cmplx.Acosh), false)
+    unary("atanh", finiteOnly(cmathAtanh, cmplx.Atanh), true)
+
+    m.function("log", func(env *Environment, args []Object, kwargs *Dict) Object {
+        params, err := parseArgs("log", args, kwargs, []string{"x", "base"}, 1)
+        if err != nil {
+            return err
+        }
+        z, err := cmathArgument(env, params[0])
+        if err != nil {
+            return err
+        }
+        log := finiteOnly(cmathLog, cmplx.Log)
+        result := log(z)
+        if params[1] != nil {
+            base, err := cmathArgument(env, params[1])
+            if err != nil {
+                return err
+            }
+            // a log of 1 in the base leaves nothing to divide by
+            var ok bool
+            if result, ok = complexQuotient(result, log(base)); !ok {
+                return valueError("math domain error")
+            }
+        }
+        return cmathResult(z, result, true)
+    })
+    m.function("phase", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("phase", args, kwargs, 1, 1); err != nil {
+            return err
+        }
+        z, err := cmathArgument(env, args[0])
+        if err != nil {
+            return err
+        }
+        return &Float{Value: arctan2(imag(z), real(z))}
+    })
+    m.function("polar", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("polar", args, kwargs, 1, 1); err != nil {
+            return err
+        }
+        z, err := cmathArgument(env, args[0])
+        if err != nil {
+            return err
+        }
+        r := hypot(real(z), imag(z))
+        if math.IsInf(r, 0) && isFiniteComplex(z) {
+            return overflowError("math range error")
+        }
+        return &Tuple{Elements: []Object{&Float{Value: r}, &Float{Value: arctan2(imag(z), real(z))}}}
+    })
+    m.function("rect", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("rect", args, kwargs, 2, 2); err != nil {
+            return err
+        }
+        r, err := realArgument(env, args[0])
+        if err != nil {
+            return err
+        }
+        phi, err := realArgument(env, args[1])
+        if err != nil {
+            return err
+        }
+        if math.IsInf(phi, 0) && !math.IsNaN(r) && r != 0 {
+            return valueError("math domain error")
+        }
+        // rect(0, anything finite) keeps its zeros exact
+        if r == 0 && !math.IsInf(phi, 0) && !math.IsNaN(phi) {
+            return &Complex{Value: complex(r, r*phi)}
+        }
+        return &Complex{Value: complex(r*cosine(phi), r*sine(phi))}
+    })
+
+    predicate := func(name string, fn func(complex128) bool) {
+        m.function(name, func(env *Environment, args []Object, kwargs *Dict) Object {
+            if err := checkArgs(name, args, kwargs, 1, 1); err != nil {
+                return err
+            }
+            z, err := cmathArgument(env, args[0])
+            if err != nil {
+                return err
+            }
+            return nativeBool(fn(z))
+        })
+    }
+    predicate("isfinite", isFiniteComplex)
+    predicate("isinf", cmplx.IsInf)
+    predicate("isnan", cmplx.IsNaN)
+
+    m.function("isclose", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if len(args) > 2 {
+            return typeError("isclose() takes exactly 2 positional arguments (%d given)", len(args))
+        }
+        params, err := parseArgs("isclose", args, kwargs, []string{"a", "b", "rel_tol", "abs_tol"}, 2)
+        if err != nil {
+            return err
+        }
+        a, err := cmathArgument(env, params[0])
+        if err != nil {
+            return err
+        }
+        b, err := cmathArgument(env, params[1])
+        if err != nil {
+            return err
+        }
+        relTol, absTol := 1e-09, 0.0
+        if params[2] != nil {
+            if relTol, err = realArgument(env, params[2]); err != nil {
+                return err
+            }
+        }
+        if params[3] != nil {
+            if absTol, err = realArgument(env, params[3]); err != nil {
+                return err
+            }
+        }
+        if relTol < 0 || absTol < 0 {
+            return valueError("tolerances must be non-negative")
+        }
+        if a == b {
+            return TRUE
+        }
+        if cmplx.IsInf(a) || cmplx.IsInf(b) {
+            return FALSE
+        }
+        diff := cmplx.Abs(b - a)
+        return nativeBool(diff <= relTol*cmplx.Abs(b) || diff <= relTol*cmplx.Abs(a) || diff <= absTol)
+    })
+
+    inf, nan := math.Inf(1), math.NaN()
+    m.Env.Set("pi", &Float{Value: math.Pi})
+    m.Env.Set("e", &Float{Value: math.E})
+    m.Env.Set("tau", &Float{Value: 2 * math.Pi})
+    m.Env.Set("inf", &Float{Value: inf})
+    m.Env.Set("infj", &Complex{Value: complex(0, inf)})
+    m.Env.Set("nan", &Float{Value: nan})
+    m.Env.Set("nanj", &Complex{Value: complex(0, nan)})
+}
+
+// numbers: the numeric tower as ABCs. Nothing here has behaviour of its
+// own; register() just teaches isinstance who belongs where.
+func buildNumbers(m *Module) {
+    number := m.class("Number")
+    complexABC := m.class("Complex", number)
+    realABC := m.class("Real", complexABC)
+    rational := m.class("Rational", realABC)
+    integral := m.class("Integral", rational)
+
+    // registering with an ABC makes cls a virtual subclass of everything
+    // above it in the tower too
+    register := func(abc, cls *Class) {
+        for _, base := range abc.MRO {
+            if base.isSubclass(number) {
+                base.virtual = append(base.virtual, cls)
+            }
+        }
+    }
+    number.Dict.SetStr("register", &ClassMethod{Function: &Builtin{Name: "register", Fn: func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("register", args, kwargs, 2, 2); err != nil {
+            return err
+        }
+        abc := args[0].(*Class)
+        cls, ok := args[1].(*Class)
+        if !ok {
+            return typeError("Can only register classes")
+        }
+        register(abc, cls)
+        return cls
+    }}, Dict: NewDict()})
+    number.Dict.SetStr("__hash__", NULL)
+
+    register(complexABC, complexType)
+    register(realABC, floatType)
+    register(integral, intType)
+}
+
+func init() {
+    registerModule("cmath", buildCmath)
+    registerModule("numbers", buildNumbers)
+}
@@ -0,0 +1,2015 @@
+// Comments in this file are inspired by Rachel Zane - everything filed, nothing lost
+
+package evaluator
+
+import (
+    "sort"
+    "strings"
+)
+
+// Lists. Mutable, ordered, and always up for more.
+type List struct {
+    Elements []Object
+}
+
+func (l *List) Type() ObjectType { return LIST_OBJ }
+func (l *List) Inspect() string  { return "[" + inspectAll(l.Elements) + "]" }
+
+// Tuples. Once they're signed, they're final.
+type Tuple struct {
+    Elements []Object
+}
+
+func (t *Tuple) Type() ObjectType { return TUPLE_OBJ }
+func (t *Tuple) Inspect() string  {
+    if len(t.Elements) == 1 {
+        return "(" + t.Elements[0].Inspect() + ",)"
+    }
+    return "(" + inspectAll(t.Elements) + ")"
+}
+
+// Dict is an insertion-ordered hash map keyed by Python equality
+type Dict struct {
+    entries []*dictEntry
+    index   map[int64][]int
+    live    int
+
+    namespace *Environment // the scope this is the globals() of, which follows every change
+}
+
+type dictEntry struct {
+    Key     Object
+    Value   Object
+    hash    int64
+    deleted bool
+}
+
+func NewDict() *Dict {
+    return &Dict{index: make(map[int64][]int)}
+}
+
+func (d *Dict) Type() ObjectType { return DICT_OBJ }
+func (d *Dict) Inspect() string  {
+    parts := []string{}
+    for _, entry := range d.Entries() {
+        parts = append(parts, entry.Key.Inspect()+": "+entry.Value.Inspect())
+    }
+    return "{" + strings.Join(parts, ", ") + "}"
+}
+
+// Len is safe on a nil dict, which is what a call without keywords passes
+func (d *Dict) Len() int {
+    if d == nil {
+        return 0
+    }
+    return d.live
+}
+
+func (d *Dict) find(env *Environment, key Object, hash int64) (int, *Error) {
+    for _, i := range d.index[hash] {
+        entry := d.entries[i]
+        if entry.Key == key {
+            return i, nil
+        }
+        eq, err := equals(env, entry.Key, key)
+        if err != nil {
+            return -1, err
+        }
+        if eq {
+            return i, nil
+        }
+    }
+    return -1, nil
+}
+
+// Get returns nil (and no error) when the key is missing
+func (d *Dict) Get(env *Environment, key Object) (Object, *Error) {
+    hash, err := hashOf(env, key)
+    if err != nil {
+        return nil, err
+    }
+    i, err := d.find(env, key, hash)
+    if err != nil || i < 0 {
+        return nil, err
+    }
+    return d.entries[i].Value, nil
+}
+
+func (d *Dict) Set(env *Environment, key, value Object) *Error {
+    hash, err := hashOf(env, key)
+    if err != nil {
+        return err
+    }
+    i, err := d.find(env, key, hash)
+    if err != nil {
+        return err
+    }
+    if i >= 0 {
+        d.entries[i].Value = value
+        d.synced(d.entries[i].Key, value)
+        return nil
+    }
+    d.insert(key, value, hash)
+    return nil
+}
+
+func (d *Dict) insert(key, value Object, hash int64) {
+    d.index[hash] = append(d.index[hash], len(d.entries))
+    d.entries = append(d.entries, &dictEntry{Key: key, Value: value, hash: hash})
+    d.live++
+    d.synced(key, value)
+}
+
+// synced passes a new value on to the scope behind a globals() dict
+func (d *Dict) synced(key, value Object) {
+    if d.namespace != nil {
+        if name, ok := key.(*String); ok {
+            d.namespace.put(name.Value, value)
+        }
+    }
+}
+
+// Delete removes a key, reporting whether it was there
+func (d *Dict) Delete(env *Environment, key Object) (Object, *Error) {
+    hash, err := hashOf(env, key)
+    if err != nil {
+        return nil, err
+    }
+    i, err := d.find(env, key, hash)
+    if err != nil || i < 0 {
+        return nil, err
+    }
+    return d.deleteAt(i), nil
+}
+
+func (d *Dict) deleteAt(i int) Object {
+    entry := d.entries[i]
+    slots := d.index[entry.hash]
+    for j, slot := range slots {
+        if slot == i {
+            slots = append(slots[:j:j], slots[j+1:]...)
+            break
+        }
+    }
+    if len(slots) == 0 {
+        delete(d.index, entry.hash)
+    } else {
+        d.index[entry.hash] = slots
+    }
+    entry.deleted = true
+    d.live--
+    if name, ok := entry.Key.(*String); ok && d.namespace != nil {
+        d.namespace.remove(name.Value)
+    }
+    if len(d.entries) > 16 && d.live < len(d.entries)/2 {
+        d.compact()
+    }
+    return entry.Value
+}
+
+func (d *Dict) compact() {
+    entries := d.Entries()
+    d.entries = nil
+    d.index = make(map[int64][]int)
+    d.live = 0
+    for _, entry := range entries {
+        d.insert(entry.Key, entry.Value, entry.hash)
+    }
+}
+
+// GetStr and SetStr are for string keys, which never need an environment
+func (d *Dict) GetStr(name string) (Object, bool) {
+    for _, i := range d.index[hashString(name)] {
+        if s, ok := d.entries[i].Key.(*String); ok && s.Value == name {
+            return d.entries[i].Value, true
+        }
+    }
+    return nil, false
+}
+
+func (d *Dict) SetStr(name string, value Object) {
+    hash := hashString(name)
+    for _, i := range d.index[hash] {
+        if s, ok := d.entries[i].Key.(*String); ok && s.Value == name {
+            d.entries[i].Value = value
+            d.synced(d.entries[i].Key, value)
+            return
+        }
+    }
+    d.insert(&String{Value: name}, value, hash)
+}
+
+// deleteStr is the string-key Delete
+func (d *Dict) deleteStr(name string) {
+    for _, i := range d.index[hashString(name)] {
+        if s, ok := d.entries[i].Key.(*String); ok && s.Value == name {
+            d.deleteAt(i)
+            return
+        }
+    }
+}
+
+// Entries is a snapshot of the live entries, oldest first
+func (d *Dict) Entries() []*dictEntry {
+    if d == nil {
+        return nil
+    }
+    entries := make([]*dictEntry, 0, d.live)
+    for _, entry := range d.entries {
+        if !entry.deleted {
+            entries = append(entries, entry)
+        }
+    }
+    return entries
+}
+
+// moveToEnd puts a key last - or first - keeping its value. It reports
+// whether the key was there at all.
+func (d *Dict) moveToEnd(env *Environment, key Object, last bool) (bool, *Error) {
+    hash, err := hashOf(env, key)
+    if err != nil {
+        return false, err
+    }
+    i, err := d.find(env, key, hash)
+    if err != nil || i < 0 {
+        return false, err
+    }
+    moved := d.entries[i]
+    if last {
+        d.deleteAt(i)
+        d.insert(moved.Key, moved.Value, moved.hash)
+        return true, nil
+    }
+    entries := d.Entries()
+    d.entries = nil
+    d.index = make(map[int64][]int)
+    d.live = 0
+    d.insert(moved.Key, moved.Value, moved.hash)
+    for _, entry := range entries {
+        if entry != moved {
+            d.insert(entry.Key, entry.Value, entry.hash)
+        }
+    }
+    return true, nil
+}
+
+func (d *Dict) Copy() *Dict {
+    copied := NewDict()
+    for _, entry := range d.Entries() {
+        copied.insert(entry.Key, entry.Value, entry.hash)
+    }
+    return copied
+}
+
+func (d *Dict) clear() {
+    if d.namespace != nil {
+        for _, name := range append([]string{}, d.namespace.names...) {
+            d.namespace.remove(name)
+        }
+    }
+    d.entries = nil
+    d.index = make(map[int64][]int)
+    d.live = 0
+}
+
+// iterator walks the dict and complains if it changes size underneath
+func (d *Dict) iterator(class *Class, what string, item func(entry *dictEntry) Object) *Iter {
+    i, size := 0, d.live
+    return newIterator(class, func(env *Environment) (Object, bool) {
+        if d.live != size {
+            size = -1
+            return runtimeError("%s changed size during iteration", what), true
+        }
+        for i < len(d.entries) {
+            entry := d.entries[i]
+            i++
+            if !entry.deleted {
+                return item(entry), true
+            }
+        }
+        return nil, false
+    })
+}
+
+// reversed is iterator run from the newest entry back to the oldest
+func (d *Dict) reversed(class *Class, what string, item func(entry *dictEntry) Object) *Iter {
+    i, size := len(d.entries), d.live
+    return newIterator(class, func(env *Environment) (Object, bool) {
+        if d.live != size {
+            size = -1
+            return runtimeError("%s changed size during iteration", what), true
+        }
+        for i > 0 && i <= len(d.entries) {
+            i--
+            if entry := d.entries[i]; !entry.deleted {
+                return item(entry), true
+            }
+        }
+        i = 0
+        return nil, false
+    })
+}
+
+// Set and frozenset share a representation: a dict of keys to themselves
+type Set struct {
+    Items  *Dict
+    Frozen bool
+}
+
+func NewSet() *Set {
+    return &Set{Items: NewDict()}
+}
+
+func (s *Set) Type() ObjectType { return SET_OBJ }
+func (s *Set) Inspect() string  {
+    keys := s.elements()
+    if len(keys) == 0 {
+        return typeName(s) + "()"
+    }
+    if s.Frozen {
+        return "frozenset({" + inspectAll(keys) + "})"
+    }
+    return "{" + inspectAll(keys) + "}"
+}
+
+func (s *Set) Add(env *Environment, item Object) *Error {
+    return s.Items.Set(env, item, item)
+}
+
+func (s *Set) Contains(env *Environment, item Object) (bool, *Error) {
+    value, err := s.Items.Get(env, item)
+    return value != nil, err
+}
+
+func (s *Set) elements() []Object {
+    entries := s.Items.Entries()
+    keys := make([]Object, len(entries))
+    for i, entry := range entries {
+        keys[i] = entry.Key
+    }
+    return keys
+}
+
+// Slice is what a:b:c evaluates to inside brackets
+type Slice struct {
+    Start Object
+    Stop  Object
+    Step  Object
+}
+
+func (s *Slice) Type() ObjectType { return SLICE_OBJ }
+func (s *Slice) Inspect() string  {
+    return "slice(" + s.Start.Inspect() + ", " + s.Stop.Inspect() + ", " + s.Step.Inspect() + ")"
+}
+
+func (s *Slice) attribute(name string) (Object, bool) {
+    switch name {
+    case "start":
+        return s.Start, true
+    case "stop":
+        return s.Stop, true
+    case "step":
+        return s.Step, true
+    }
+    return nil, false
+}
+
+// indices resolves the slice against a sequence of the given length, the
+// way PySlice_AdjustIndices does
+func (s *Slice) indices(env *Environment, length int) (start, stop, step int, err *Error) {
+    step = 1
+    if s.Step != NULL {
+        if step, err = sliceIndex(env, s.Step); err != nil {
+            return
+        }
+        if step == 0 {
+            return 0, 0, 0, valueError("slice step cannot be zero")
+        }
+    }
+
+    lower, upper := 0, length
+    if step < 0 {
+        lower, upper = -1, length-1
+    }
+    bound := func(value Object, fallback int) (int, *Error) {
+        if value == NULL {
+            return fallback, nil
+        }
+        i, err := sliceIndex(env, value)
+        if err != nil {
+            return 0, err
+        }
+        if i < 0 {
+            i += length
+            if i < lower {
+                i = lower
+            }
+        } else if i > upper {
+            i = upper
+        }
+        return i, nil
+    }
+
+    if step < 0 {
+        start, err = bound(s.Start, upper)
+    } else {
+        start, err = bound(s.Start, lower)
+    }
+    if err != nil {
+        return
+    }
+    if step < 0 {
+        stop, err = bound(s.Stop, lower)
+    } else {
+        stop, err = bound(s.Stop, upper)
+    }
+    return
+}
+
+// sliceIndex clamps huge slice bounds instead of failing, like CPython
+func sliceIndex(env *Environment, obj Object) (int, *Error) {
+    if _, ok := toBigInt(obj); !ok && typeOf(obj).lookupName("__index__") == nil {
+        return 0, typeError("slice indices must be integers or None or have an __index__ method")
+    }
+    n, err := indexValue(env, obj)
+    if err != nil {
+        return 0, err
+    }
+    switch {
+    case n.IsInt64() && n.Int64() == int64(int(n.Int64())):
+        return int(n.Int64()), nil
+    case n.Sign() < 0:
+        return -int(^uint(0)>>1) - 1, nil
+    }
+    return int(^uint(0) >> 1), nil
+}
+
+// sliceLength is how many items start:stop:step selects
+func sliceLength(start, stop, step int) int {
+    switch {
+    case step > 0 && start < stop:
+        return (stop-start-1)/step + 1
+    case step < 0 && stop < start:
+        return (start-stop-1)/(-step) + 1
+    }
+    return 0
+}
+
+func selectSlice(elements []Object, start, stop, step int) []Object {
+    result := make([]Object, 0, sliceLength(start, stop, step))
+    for i, n := start, sliceLength(start, stop, step); n > 0; i, n = i+step, n-1 {
+        result = append(result, elements[i])
+    }
+    return result
+}
+
+// Iter is a native iterator. next reports false once it is exhausted, and it
+// stays exhausted.
+type Iter struct {
+    class *Class
+    next  func(env *Environment) (Object, bool)
+    repr  func(env *Environment) (string, *Error) // for the few that show their state
+}
+
+func (it *Iter) Type() ObjectType { return ITERATOR_OBJ }
+func (it *Iter) Inspect() string  { return defaultRepr(it) }
+
+func newIterator(class *Class, next func(env *Environment) (Object, bool)) *Iter {
+    done := false
+    return &Iter{class: class, next: func(env *Environment) (Object, bool) {
+        if done {
+            return nil, false
+        }
+        value, ok := next(env)
+        if !ok {
+            done = true
+        }
+        return value, ok
+    }}
+}
+
+// sliceIterator walks a Go slice that may grow while we walk it
+func sliceIterator(class *Class, elements func() []Object) *Iter {
+    i := 0
+    return newIterator(class, func(env *Environment) (Object, bool) {
+        items := elements()
+        if i >= len(items) {
+            return nil, false
+        }
+        i++
+        return items[i-1], true
+    })
+}
+
+// DictView is dict.keys(), dict.values() or dict.items() - live windows onto the dict
+type DictView struct {
+    class *Class
+    dict  *Dict
+}
+
+func (v *DictView) Type() ObjectType { return ITERATOR_OBJ }
+func (v *DictView) Inspect() string  { return v.class.Name + "(" + (&List{Elements: v.items()}).Inspect() + ")" }
+
+func (v *DictView) item(entry *dictEntry) Object {
+    switch v.class {
+    case dictKeysType:
+        return entry.Key
+    case dictValuesType:
+        return entry.Value
+    }
+    return &Tuple{Elements: []Object{entry.Key, entry.Value}}
+}
+
+func (v *DictView) items() []Object {
+    entries := v.dict.Entries()
+    items := make([]Object, len(entries))
+    for i, entry := range entries {
+        items[i] = v.item(entry)
+    }
+    return items
+}
+
+var (
+    listIteratorType     = newIteratorClass("list_iterator")
+    reversedListType     = newIteratorClass("list_reverseiterator")
+    tupleIteratorType    = newIteratorClass("tuple_iterator")
+    dictKeyIteratorType  = newIteratorClass("dict_keyiterator")
+    dictValIteratorType  = newIteratorClass("dict_valueiterator")
+    dictItemIteratorType = newIteratorClass("dict_itemiterator")
+    dictKeyReversedType  = newIteratorClass("dict_reversekeyiterator")
+    dictValReversedType  = newIteratorClass("dict_reversevalueiterator")
+    dictItemReversedType = newIteratorClass("dict_reverseitemiterator")
+    setIteratorType      = newIteratorClass("set_iterator")
+    seqIteratorType      = newIteratorClass("iterator")
+
+    dictKeysType   = newBuiltinClass("dict_keys", objectType)
+    dictValuesType = newBuiltinClass("dict_values", objectType)
+    dictItemsType  = newBuiltinClass("dict_items", objectType)
+)
+
+// iteratorTypes all behave the same; their methods are filled in by init
+var iteratorTypes []*Class
+
+func newIteratorClass(name string) *Class {
+    cls := newBuiltinClass(name, objectType)
+    iteratorTypes = append(iteratorTypes, cls)
+    return cls
+}
+
+func initIterators() {
+    for _, cls := range iteratorTypes {
+        cls.method("__iter__", 0, 0, func(env *Environment, args []Object) Object {
+            return args[0]
+        })
+        cls.method("__next__", 0, 0, func(env *Environment, args []Object) Object {
+            value, ok := iterNext(env, args[0])
+            if !ok {
+                return stopIteration()
+            }
+            return value
+        })
+    }
+}
+
+func inspectAll(elements []Object) string {
+    parts := make([]string, len(elements))
+    for i, element := range elements {
+        parts[i] = element.Inspect()
+    }
+    return strings.Join(parts, ", ")
+}
+
+// reprJoin reprs every element, guarding against self-reference
+func reprJoin(env *Environment, self Object, elements []Object, sep string) (string, bool, *Error) {
+    repring := env.interp.repring
+    if repring[self] {
+        return "", false, nil
+    }
+    repring[self] = true
+    defer delete(repring, self)
+
+    parts := make([]string, len(elements))
+    for i, element := range elements {
+        s, err := reprString(env, element)
+        if err != nil {
+            return "", true, err
+        }
+        parts[i] = s
+    }
+    return strings.Join(parts, sep), true, nil
+}
+
+// compareSequences is lexicographic comparison for lists and tuples
+func compareSequences(env *Environment, operator string, a, b []Object) Object {
+    i := 0
+    for ; i < len(a) && i < len(b); i++ {
+        eq, err := equals(env, a[i], b[i])
+        if err != nil {
+            return err
+        }
+        if !eq {
+            break
+        }
+    }
+    if i >= len(a) || i >= len(b) {
+        return compareResult(operator, len(a)-len(b))
+    }
+    switch operator {
+    case "==":
+        return FALSE
+    case "!=":
+        return TRUE
+    }
+    return richCompare(env, operator, a[i], b[i])
+}
+
+// repeatCount is the n in seq * n; anything that isn't an index is NotImplemented
+func repeatCount(env *Environment, obj Object) (int, Object) {
+    if _, ok := toBigInt(obj); !ok && typeOf(obj).lookupName("__index__") == nil {
+        return 0, NotImplemented
+    }
+    n, err := indexValue(env, obj)
+    if err != nil {
+        return 0, err
+    }
+    if !n.IsInt64() {
+        return 0, newErrorKind(overflowErrorType, "cannot fit '%s' into an index-sized integer", typeName(obj))
+    }
+    return max(int(n.Int64()), 0), nil
+}
+
+// maxAllocation is the most the program can have us allocate in one go.
+// Go's allocator doesn't fail gently - running out of memory ends the
+// process, host and all - so past this the program gets a MemoryError.
+const maxAllocation = 1 << 32
+
+// allocation is count things of size each, or a MemoryError for asking
+func allocation(count, size int) (int, *Error) {
+    if count < 0 || size < 0 || (size > 0 && count > maxAllocation/size) {
+        return 0, newErrorKind(memoryErrorType, "")
+    }
+    return count * size, nil
+}
+
+func repeatElements(elements []Object, n int) ([]Object, *Error) {
+    total, err := allocation(n, len(elements))
+    if err != nil {
+        return nil, err
+    }
+    result := make([]Object, 0, total)
+    for len(result) < total {
+        result = append(result, elements...)
+    }
+    return result, nil
+}
+
+// sequenceIndex turns a possibly negative index into a position in a sequence of length n
+func sequenceIndex(env *Environment, obj Object, n int, what string) (int, *Error) {
+    if _, ok := toBigInt(obj); !ok && typeOf(obj).lookupName("__index__") == nil {
+        return 0, typeError("%s indices must be integers or slices, not %s", what, typeName(obj))
+    }
+    i, err := toIndex(env, obj)
+    if err != nil {
+        return 0, indexError("cannot fit '%s' into an index-sized integer", typeName(obj))
+    }
+    if i < 0 {
+        i += n
+    }
+    if i < 0 || i >= n {
+        return 0, indexError("%s index out of range", what)
+    }
+    return i, nil
+}
+
+// tupleHash is CPython's xxHash-based tuple hash
+func tupleHash(env *Environment, elements []Object) (int64, *Error) {
+    const (
+        prime1 uint64 = 11400714785074694791
+        prime2 uint64 = 14029467366897019727
+        prime5 uint64 = 2870177450012600261
+    )
+    acc := prime5
+    for _, element := range elements {
+        h, err := hashOf(env, element)
+        if err != nil {
+            return 0, err
+        }
+        acc += uint64(h) * prime2
+        acc = acc<<31 | acc>>33
+        acc *= prime1
+    }
+    acc += uint64(len(elements)) ^ (prime5 ^ 3527539)
+    if int64(acc) == -1 {
+        return 1546275796, nil
+    }
+    return int64(acc), nil
+}
+
+// frozensetHash is CPython's order-independent frozenset hash
+func frozensetHash(s *Set) int64 {
+    var hash uint64
+    entries := s.Items.Entries()
+    for _, entry := range entries {
+        h := uint64(entry.hash)
+        hash ^= ((h ^ 89869747) ^ (h << 16)) * 3644798167
+    }
+    hash ^= (uint64(len(entries)) + 1) * 1927868237
+    hash ^= (hash >> 11) ^ (hash >> 25)
+    hash = hash*69069 + 907133923
+    if int64(hash) == -1 {
+        return 590923713
+    }
+    return int64(hash)
+}
+
+// asList, asTuple, asDict and asSet unwrap self for methods of builtin types
+func asList(obj Object) *List   { return payload(obj).(*List) }
+func asTuple(obj Object) *Tuple { return payload(obj).(*Tuple) }
+func asDict(obj Object) *Dict   { return payload(obj).(*Dict) }
+func asSet(obj Object) *Set     { return payload(obj).(*Set) }
+
+func init() {
+    initIterators()
+    initList()
+    initTuple()
+    initDict()
+    initSet()
+    initSlice()
+}
+
+func initList() {
+    listType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        cls, err := newClassArg("list", args)
+        if err != nil {
+            return err
+        }
+        return wrapBuiltinValue(cls, listType, &List{Elements: []Object{}})
+    })
+    listType.define("__init__", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("list", args[1:], kwargs, 0, 1); err != nil {
+            return err
+        }
+        list := asList(args[0])
+        list.Elements = []Object{}
+        if len(args) == 2 {
+            items, err := iterableToSlice(env, args[1])
+            if err != nil {
+                return err
+            }
+            list.Elements = items
+        }
+        return NULL
+    })
+    listType.Dict.SetStr("__hash__", NULL)
+    listType.method("__len__", 0, 0, func(env *Environment, args []Object) Object {
+        return newInt(int64(len(asList(args[0]).Elements)))
+    })
+    listType.method("__iter__", 0, 0, func(env *Environment, args []Object) Object {
+        list := asList(args[0])
+        return sliceIterator(listIteratorType, func() []Object { return list.Elements })
+    })
+    listType.method("__reversed__", 0, 0, func(env *Environment, args []Object) Object {
+        list := asList(args[0])
+        i := len(list.Elements) - 1
+        return newIterator(reversedListType, func(env *Environment) (Object, bool) {
+            if i < 0 || i >= len(list.Elements) {
+                return nil, false
+            }
+            i--
+            return list.Elements[i+1], true
+        })
+    })
+    listType.method("__contains__", 1, 1, func(env *Environment, args []Object) Object {
+        return containsElement(env, asList(args[0]).Elements, args[1])
+    })
+    listType.method("__getitem__", 1, 1, func(env *Environment, args []Object) Object {
+        list := asList(args[0])
+        if s, ok := args[1].(*Slice); ok {
+            start, stop, step, err := s.indices(env, len(list.Elements))
+            if err != nil {
+                return err
+            }
+            return &List{Elements: selectSlice(list.Elements, start, stop, step)}
+        }
+        i, err := sequenceIndex(env, args[1], len(list.Elements), "list")
+        if err != nil {
+            return err
+        }
+        return list.Elements[i]
+    })
+    listType.method("__setitem__", 2, 2, func(env *Environment, args []Object) Object {
+        list := asList(args[0])
+        if s, ok := args[1].(*Slice); ok {
+            return assignSlice(env, list, s, args[2])
+        }
+        i, err := sequenceIndex(env, args[1], len(list.Elements), "list")
+        if err != nil {
+            if err.matches(indexErrorType) {
+                return indexError("list assignment index out of range")
+            }
+            return err
+        }
+        list.Elements[i] = args[2]
+        return NULL
+    })
+    listType.method("__delitem__", 1, 1, func(env *Environment, args []Object) Object {
+        list := asList(args[0])
+        if s, ok := args[1].(*Slice); ok {
+            start, stop, step, err := s.indices(env, len(list.Elements))
+            if err != nil {
+                return err
+            }
+            drop := map[int]bool{}
+            for i, n := start, sliceLength(start, stop, step); n > 0; i, n = i+step, n-1 {
+                drop[i] = true
+            }
+            kept := []Object{}
+            for i, element := range list.Elements {
+                if !drop[i] {
+                    kept = append(kept, element)
+                }
+            }
+            list.Elements = kept
+            return NULL
+        }
+        i, err := sequenceIndex(env, args[1], len(list.Elements), "list")
+        if err != nil {
+            if err.matches(indexErrorType) {
+                return indexError("list assignment index out of range")
+            }
+            return err
+        }
+        list.Elements = append(list.Elements[:i:i], list.Elements[i+1:]...)
+        return NULL
+    })
+    listType.method("__add__", 1, 1, func(env *Environment, args []Object) Object {
+        other, ok := payload(args[1]).(*List)
+        if !ok {
+            return typeError("can only concatenate list (not \"%s\") to list", typeName(args[1]))
+        }
+        elements := append(append([]Object{}, asList(args[0]).Elements...), other.Elements...)
+        return &List{Elements: elements}
+    })
+    listType.method("__iadd__", 1, 1, func(env *Environment, args []Object) Object {
+        items, err := iterableToSlice(env, args[1])
+        if err != nil {
+            return err
+        }
+        list := asList(args[0])
+        list.Elements = append(list.Elements, items...)
+        return args[0]
+    })
+    for _, name := range []string{"__mul__", "__rmul__"} {
+        listType.method(name, 1, 1, func(env *Environment, args []Object) Object {
+            n, result := repeatCount(env, args[1])
+            if result != nil {
+                return result
+            }
+            elements, err := repeatElements(asList(args[0]).Elements, n)
+            if err != nil {
+                return err
+            }
+            return &List{Elements: elements}
+        })
+    }
+    listType.method("__imul__", 1, 1, func(env *Environment, args []Object) Object {
+        n, result := repeatCount(env, args[1])
+        if result != nil {
+            return result
+        }
+        list := asList(args[0])
+        elements, err := repeatElements(list.Elements, n)
+        if err != nil {
+            return err
+        }
+        list.Elements = elements
+        return args[0]
+    })
+    for _, operator := range []string{"==", "!=", "<", "<=", ">", ">="} {
+        operator := operator
+        listType.method(comparisonOperators[operator][0], 1, 1, func(env *Environment, args []Object) Object {
+            other, ok := payload(args[1]).(*List)
+            if !ok {
+                return NotImplemented
+            }
+            return compareSequences(env, operator, asList(args[0]).Elements, other.Elements)
+        })
+    }
+    listType.method("__repr__", 0, 0, func(env *Environment, args []Object) Object {
+        s, ok, err := reprJoin(env, args[0], asList(args[0]).Elements, ", ")
+        if err != nil {
+            return err
+        }
+        if !ok {
+            return &String{Value: "[...]"}
+        }
+        return &String{Value: "[" + s + "]"}
+    })
+    listType.method("append", 1, 1, func(env *Environment, args []Object) Object {
+        list := asList(args[0])
+        list.Elements = append(list.Elements, args[1])
+        return NULL
+    })
+    listType.method("extend", 1, 1, func(env *Environment, args []Object) Object {
+        items, err := iterableToSlice(env, args[1])
+        if err != nil {
+            return err
+        }
+        list := asList(args[0])
+        list.Elements = append(list.Elements, items...)
+        return NULL
+    })
+    listType.method("insert", 2, 2, func(env *Environment, args []Object) Object {
+        list := asList(args[0])
+        i, err := toIndex(env, args[1])
+        if err != nil {
+            return err
+        }
+        n := len(list.Elements)
+        if i < 0 {
+            i = max(i+n, 0)
+        }
+        i = min(i, n)
+        list.Elements = append(list.Elements, nil)
+        copy(list.Elements[i+1:], list.Elements[i:])
+        list.Elements[i] = args[2]
+        return NULL
+    })
+    listType.method("pop", 0, 1, func(env *Environment, args []Object) Object {
+        list := asList(args[0])
+        if len(list.Elements) == 0 {
+            return indexError("pop from empty list")
+        }
+        i := len(list.Elements) - 1
+        if len(args) == 2 {
+            index, err := toIndex(env, args[1])
+            if err != nil {
+                return err
+            }
+            if index < 0 {
+                index += len(list.Elements)
+            }
+            if index < 0 || index >= len(list.Elements) {
+                return indexError("pop index out of range")
+            }
+            i = index
+        }
+        value := list.Elements[i]
+        list.Elements = append(list.Elements[:i:i], list.Elements[i+1:]...)
+        return value
+    })
+    listType.method("remove", 1, 1, func(env *Environment, args []Object) Object {
+        list := asList(args[0])
+        for i, element := range list.Elements {
+            eq, err := equals(env, element, args[1])
+            if err != nil {
+                return err
+            }
+            if eq {
+                list.Elements = append(list.Elements[:i:i], list.Elements[i+1:]...)
+                return NULL
+            }
+        }
+        return valueError("list.remove(x): x not in list")
+    })
+    listType.method("index", 1, 3, func(env *Environment, args []Object) Object {
+        return sequenceFind(env, asList(args[0]).Elements, args[1:], "list")
+    })
+    listType.method("count", 1, 1, func(env *Environment, args []Object) Object {
+        return countElement(env, asList(args[0]).Elements, args[1])
+    })
+    listType.method("clear", 0, 0, func(env *Environment, args []Object) Object {
+        asList(args[0]).Elements = []Object{}
+        return NULL
+    })
+    listType.method("copy", 0, 0, func(env *Environment, args []Object) Object {
+        return &List{Elements: append([]Object{}, asList(args[0]).Elements...)}
+    })
+    listType.method("reverse", 0, 0, func(env *Environment, args []Object) Object {
+        elements := asList(args[0]).Elements
+        for i, j := 0, len(elements)-1; i < j; i, j = i+1, j-1 {
+            elements[i], elements[j] = elements[j], elements[i]
+        }
+        return NULL
+    })
+    listType.define("sort", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if len(args) > 1 {
+            return typeError("sort() takes no positional arguments")
+        }
+        params, err := parseArgs("sort", nil, kwargs, []string{"*key", "*reverse"}, 0)
+        if err != nil {
+            return err
+        }
+        list := asList(args[0])
+        reverse := false
+        if params[1] != nil {
+            if reverse, err = truthy(env, params[1]); err != nil {
+                return err
+            }
+        }
+        sorted, err := sortObjects(env, list.Elements, params[0], reverse)
+        if err != nil {
+            return err
+        }
+        list.Elements = sorted
+        return NULL
+    })
+}
+
+// sortObjects is a stable sort by key, stopping at the first failed comparison
+func sortObjects(env *Environment, elements []Object, key Object, reverse bool) ([]Object, *Error) {
+    keys := append([]Object{}, elements...)
+    if key != nil && key != NULL {
+        for i, element := range elements {
+            keys[i] = applyFunction(env, key, []Object{element}, nil)
+            if err, ok := keys[i].(*Error); ok {
+                return nil, err
+            }
+        }
+    }
+
+    order := make([]int, len(elements))
+    for i := range order {
+        order[i] = i
+    }
+    var failure *Error
+    sort.SliceStable(order, func(i, j int) bool {
+        if failure != nil {
+            return false
+        }
+        a, b := keys[order[i]], keys[order[j]]
+        if reverse {
+            a, b = b, a
+        }
+        less, err := lessThan(env, a, b)
+        if err != nil {
+            failure = err
+        }
+        return less
+    })
+    if failure != nil {
+        return nil, failure
+    }
+
+    sorted := make([]Object, len(elements))
+    for i, index := range order {
+        sorted[i] = elements[index]
+    }
+    return sorted, nil
+}
+
+// assignSlice is list[a:b:c] = iterable
+func assignSlice(env *Environment, list *List, s *Slice, value Object) Object {
+    items, err := iterableToSlice(env, value)
+    if err != nil {
+        if typeOf(value).lookupName("__iter__") == nil {
+            return typeError("must assign iterable to extended slice")
+        }
+        return err
+    }
+    start, stop, step, err := s.indices(env, len(list.Elements))
+    if err != nil {
+        return err
+    }
+
+    if step == 1 {
+        stop = max(stop, start)
+        elements := append([]Object{}, list.Elements[:start]...)
+        elements = append(elements, items...)
+        list.Elements = append(elements, list.Elements[stop:]...)
+        return NULL
+    }
+
+    n := sliceLength(start, stop, step)
+    if len(items) != n {
+        return valueError("attempt to assign sequence of size %d to extended slice of size %d", len(items), n)
+    }
+    for i, k := start, 0; k < n; i, k = i+step, k+1 {
+        list.Elements[i] = items[k]
+    }
+    return NULL
+}
+
+func containsElement(env *Environment, elements []Object, item Object) Object {
+    for _, element := range elements {
+        eq, err := equals(env, element, item)
+        if err != nil {
+            return err
+        }
+        if eq {
+            return TRUE
+        }
+    }
+    return FALSE
+}
+
+func countElement(env *Environment, elements []Object, item Object) Object {
+    count := 0
+    for _, element := range elements {
+        eq, err := equals(env, element, item)
+        if err != nil {
+            return err
+        }
+        if eq {
+            count++
+        }
+    }
+    return newInt(int64(count))
+}
+
+// sequenceFind is list.index and tuple.index with their optional start and stop
+func sequenceFind(env *Environment, elements []Object, args []Object, what string) Object {
+    bounds := &Slice{Start: NULL, Stop: NULL, Step: NULL}
+    if len(args) > 1 {
+        bounds.Start = args[1]
+    }
+    if len(args) > 2 {
+        bounds.Stop = args[2]
+    }
+    start, stop, _, err := bounds.indices(env, len(elements))
+    if err != nil {
+        return err
+    }
+    for i := start; i < stop; i++ {
+        eq, err := equals(env, elements[i], args[0])
+        if err != nil {
+            return err
+        }
+        if eq {
+            return newInt(int64(i))
+        }
+    }
+    if what == "list" {
+        return valueError("list.index(x): x not in list")
+    }
+    return valueError("tuple.index(x): x not in tuple")
+}
+
+func initTuple() {
+    tupleType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        cls, err := newClassArg("tuple", args)
+        if err != nil {
+            return err
+        }
+        if err := checkArgs("tuple", args[1:], kwargs, 0, 1); err != nil {
+            return err
+        }
+        elements := []Object{}
+        if len(args) == 2 {
+            if elements, err = iterableToSlice(env, args[1]); err != nil {
+                return err
+            }
+        }
+        return wrapBuiltinValue(cls, tupleType, &Tuple{Elements: elements})
+    })
+    tupleType.method("__len__", 0, 0, func(env *Environment, args []Object) Object {
+        return newInt(int64(len(asTuple(args[0]).Elements)))
+    })
+    tupleType.method("__iter__", 0, 0, func(env *Environment, args []Object) Object {
+        elements := asTuple(args[0]).Elements
+        return sliceIterator(tupleIteratorType, func() []Object { return elements })
+    })
+    tupleType.method("__contains__", 1, 1, func(env *Environment, args []Object) Object {
+        return containsElement(env, asTuple(args[0]).Elements, args[1])
+    })
+    tupleType.method("__getitem__", 1, 1, func(env *Environment, args []Object) Object {
+        tuple := asTuple(args[0])
+        if s, ok := args[1].(*Slice); ok {
+            start, stop, step, err := s.indices(env, len(tuple.Elements))
+            if err != nil {
+                return err
+            }
+            return &Tuple{Elements: selectSlice(tuple.Elements, start, stop, step)}
+        }
+        i, err := sequenceIndex(env, args[1], len(tuple.Elements), "tuple")
+        if err != nil {
+            return err
+        }
+        return tuple.Elements[i]
+    })
+    tupleType.method("__add__", 1, 1, func(env *Environment, args []Object) Object {
+        other, ok := payload(args[1]).(*Tuple)
+        if !ok {
+            return typeError("can only concatenate tuple (not \"%s\") to tuple", typeName(args[1]))
+        }
+        elements := append(append([]Object{}, asTuple(args[0]).Elements...), other.Elements...)
+        return &Tuple{Elements: elements}
+    })
+    for _, name := range []string{"__mul__", "__rmul__"} {
+        tupleType.method(name, 1, 1, func(env *Environment, args []Object) Object {
+            n, result := repeatCount(env, args[1])
+            if result != nil {
+                return result
+            }
+            elements, err := repeatElements(asTuple(args[0]).Elements, n)
+            if err != nil {
+                return err
+            }
+            return &Tuple{Elements: elements}
+        })
+    }
+    for _, operator := range []string{"==", "!=", "<", "<=", ">", ">="} {
+        operator := operator
+        tupleType.method(comparisonOperators[operator][0], 1, 1, func(env *Environment, args []Object) Object {
+            other, ok := payload(args[1]).(*Tuple)
+            if !ok {
+                return NotImplemented
+            }
+            return compareSequences(env, operator, asTuple(args[0]).Elements, other.Elements)
+        })
+    }
+    tupleType.method("__hash__", 0, 0, func(env *Environment, args []Object) Object {
+        h, err := tupleHash(env, asTuple(args[0]).Elements)
+        if err != nil {
+            return err
+        }
+        return newInt(h)
+    })
+    tupleType.method("__repr__", 0, 0, func(env *Environment, args []Object) Object {
+        elements := asTuple(args[0]).Elements
+        s, ok, err := reprJoin(env, args[0], elements, ", ")
+        if err != nil {
+            return err
+        }
+        switch {
+        case !ok:
+            return &String{Value: "(...)"}
+        case len(elements) == 1:
+            return &String{Value: "(" + s + ",)"}
+        }
+        return &String{Value: "(" + s + ")"}
+    })
+    tupleType.method("index", 1, 3, func(env *Environment, args []Object) Object {
+        return sequenceFind(env, asTuple(args[0]).Elements, args[1:], "tuple")
+    })
+    tupleType.method("count", 1, 1, func(env *Environment, args []Object) Object {
+        return countElement(env, asTuple(args[0]).Elements, args[1])
+    })
+}
+
+// dictUpdate is the shared body of dict(...) and dict.update(...)
+func dictUpdate(env *Environment, dict *Dict, args []Object, kwargs *Dict, name string) *Error {
+    if len(args) > 1 {
+        return typeError("%s expected at most 1 argument, got %d", name, len(args))
+    }
+    if len(args) == 1 {
+        if err := mergeInto(env, dict, args[0]); err != nil {
+            return err
+        }
+    }
+    for _, entry := range kwargs.Entries() {
+        if err := dict.Set(env, entry.Key, entry.Value); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// mergeInto copies a mapping, or an iterable of pairs, into dict
+func mergeInto(env *Environment, dict *Dict, source Object) *Error {
+    if other, ok := payload(source).(*Dict); ok && typeOf(source).lookupName("keys") == dictType.lookupName("keys") {
+        for _, entry := range other.Entries() {
+            if err := dict.Set(env, entry.Key, entry.Value); err != nil {
+                return err
+            }
+        }
+        return nil
+    }
+
+    if typeOf(source).lookupName("keys") != nil {
+        keys := getAttribute(env, source, "keys")
+        if isError(keys) {
+            return keys.(*Error)
+        }
+        keys = applyFunction(env, keys, nil, nil)
+        if isError(keys) {
+            return keys.(*Error)
+        }
+        return iterate(env, keys, func(key Object) *Error {
+            value := getItem(env, source, key)
+            if err, ok := value.(*Error); ok {
+                return err
+            }
+            return dict.Set(env, key, value)
+        })
+    }
+
+    position := 0
+    return iterate(env, source, func(item Object) *Error {
+        pair, err := iterableToSlice(env, item)
+        if err != nil {
+            return typeError("cannot convert dictionary update sequence element #%d to a sequence", position)
+        }
+        if len(pair) != 2 {
+            return valueError("dictionary update sequence element #%d has length %d; 2 is required", position, len(pair))
+        }
+        position++
+        return dict.Set(env, pair[0], pair[1])
+    })
+}
+
+func initDict() {
+    dictType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        cls, err := newClassArg("dict", args)
+        if err != nil {
+            return err
+        }
+        return wrapBuiltinValue(cls, dictType, NewDict())
+    })
+    dictType.define("__init__", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := dictUpdate(env, asDict(args[0]), args[1:], kwargs, "dict"); err != nil {
+            return err
+        }
+        return NULL
+    })
+    dictType.Dict.SetStr("__hash__", NULL)
+    dictType.method("__len__", 0, 0, func(env *Environment, args []Object) Object {
+        return newInt(int64(asDict(args[0]).Len()))
+    })
+    dictType.method("__iter__", 0, 0, func(env *Environment, args []Object) Object {
+        return asDict(args[0]).iterator(dictKeyIteratorType, "dictionary", func(entry *dictEntry) Object { return entry.Key })
+    })
+    dictType.method("__reversed__", 0, 0, func(env *Environment, args []Object) Object {
+        return asDict(args[0]).reversed(dictKeyReversedType, "dictionary", func(entry *dictEntry) Object { return entry.Key })
+    })
+    dictType.method("__contains__", 1, 1, func(env *Environment, args []Object) Object {
+        value, err := asDict(args[0]).Get(env, args[1])
+        if err != nil {
+            return err
+        }
+        return nativeBool(value != nil)
+    })
+    dictType.method("__getitem__", 1, 1, func(env *Environment, args []Object) Object {
+        value, err := asDict(args[0]).Get(env, args[1])
+        if err != nil {
+            return err
+        }
+        if value != nil {
+            return value
+        }
+        if missing := typeOf(args[0]).lookupName("__missing__"); missing != nil {
+            return callMethod(env, missing, args[0], args[1])
+        }
+        return keyError(args[1])
+    })
+    dictType.method("__setitem__", 2, 2, func(env *Environment, args []Object) Object {
+        if err := asDict(args[0]).Set(env, args[1], args[2]); err != nil {
+            return err
+        }
+        return NULL
+    })
+    dictType.method("__delitem__", 1, 1, func(env *Environment, args []Object) Object {
+        value, err := asDict(args[0]).Delete(env, args[1])
+        if err != nil {
+            return err
+        }
+        if value == nil {
+            return keyError(args[1])
+        }
+        return NULL
+    })
+    dictType.method("__eq__", 1, 1, func(env *Environment, args []Object) Object {
+        other, ok := payload(args[1]).(*Dict)
+        if !ok {
+            return NotImplemented
+        }
+        return dictEquals(env, asDict(args[0]), other)
+    })
+    dictType.method("__or__", 1, 1, func(env *Environment, args []Object) Object {
+        other, ok := payload(args[1]).(*Dict)
+        if !ok {
+            return NotImplemented
+        }
+        merged := asDict(args[0]).Copy()
+        if err := mergeInto(env, merged, other); err != nil {
+            return err
+        }
+        return merged
+    })
+    dictType.method("__ior__", 1, 1, func(env *Environment, args []Object) Object {
+        if err := mergeInto(env, asDict(args[0]), args[1]); err != nil {
+            return err
+        }
+        return args[0]
+    })
+    dictType.method("__repr__", 0, 0, func(env *Environment, args []Object) Object {
+        entries := asDict(args[0]).Entries()
+        pairs := make([]Object, 0, 2*len(entries))
+        for _, entry := range entries {
+            pairs = append(pairs, entry.Key, entry.Value)
+        }
+        repring := env.interp.repring
+        if repring[args[0]] {
+            return &String{Value: "{...}"}
+        }
+        repring[args[0]] = true
+        defer delete(repring, args[0])
+
+        parts := make([]string, len(entries))
+        for i := range entries {
+            key, err := reprString(env, pairs[2*i])
+            if err != nil {
+                return err
+            }
+            value, err := reprString(env, pairs[2*i+1])
+            if err != nil {
+                return err
+            }
+            parts[i] = key + ": " + value
+        }
+        return &String{Value: "{" + strings.Join(parts, ", ") + "}"}
+    })
+    dictType.method("get", 1, 2, func(env *Environment, args []Object) Object {
+        value, err := asDict(args[0]).Get(env, args[1])
+        if err != nil {
+            return err
+        }
+        if value != nil {
+            return value
+        }
+        if len(args) == 3 {
+            return args[2]
+        }
+        return NULL
+    })
+    for name, class := range map[string]*Class{"keys": dictKeysType, "values": dictValuesType, "items": dictItemsType} {
+        class := class
+        dictType.method(name, 0, 0, func(env *Environment, args []Object) Object {
+            return &DictView{class: class, dict: asDict(args[0])}
+        })
+    }
+    dictType.method("pop", 1, 2, func(env *Environment, args []Object) Object {
+        value, err := asDict(args[0]).Delete(env, args[1])
+        if err != nil {
+            return err
+        }
+        switch {
+        case value != nil:
+            return value
+        case len(args) == 3:
+            return args[2]
+        }
+        return keyError(args[1])
+    })
+    dictType.method("popitem", 0, 0, func(env *Environment, args []Object) Object {
+        dict := asDict(args[0])
+        for i := len(dict.entries) - 1; i >= 0; i-- {
+            if entry := dict.entries[i]; !entry.deleted {
+                dict.deleteAt(i)
+                return &Tuple{Elements: []Object{entry.Key, entry.Value}}
+            }
+        }
+        return newErrorKind(keyErrorType, "popitem(): dictionary is empty")
+    })
+    dictType.method("setdefault", 1, 2, func(env *Environment, args []Object) Object {
+        dict := asDict(args[0])
+        value, err := dict.Get(env, args[1])
+        if err != nil {
+            return err
+        }
+        if value != nil {
+            return value
+        }
+        value = NULL
+        if len(args) == 3 {
+            value = args[2]
+        }
+        if err := dict.Set(env, args[1], value); err != nil {
+            return err
+        }
+        return value
+    })
+    dictType.define("update", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := dictUpdate(env, asDict(args[0]), args[1:], kwargs, "update"); err != nil {
+            return err
+        }
+        return NULL
+    })
+    dictType.method("copy", 0, 0, func(env *Environment, args []Object) Object {
+        return asDict(args[0]).Copy()
+    })
+    dictType.method("clear", 0, 0, func(env *Environment, args []Object) Object {
+        asDict(args[0]).clear()
+        return NULL
+    })
+    dictType.Dict.SetStr("fromkeys", &ClassMethod{Function: &Builtin{Name: "fromkeys", Fn: func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("fromkeys", args[1:], kwargs, 1, 2); err != nil {
+            return err
+        }
+        return dictFromKeys(env, args[0], args[1:])
+    }}, Dict: NewDict()})
+
+    for _, class := range []*Class{dictKeysType, dictValuesType, dictItemsType} {
+        class := class
+        iteratorClass := map[*Class]*Class{
+            dictKeysType:   dictKeyIteratorType,
+            dictValuesType: dictValIteratorType,
+            dictItemsType:  dictItemIteratorType,
+        }[class]
+        reversedClass := map[*Class]*Class{
+            dictKeysType:   dictKeyReversedType,
+            dictValuesType: dictValReversedType,
+            dictItemsType:  dictItemReversedType,
+        }[class]
+        class.method("__len__", 0, 0, func(env *Environment, args []Object) Object {
+            return newInt(int64(args[0].(*DictView).dict.Len()))
+        })
+        class.method("__iter__", 0, 0, func(env *Environment, args []Object) Object {
+            view := args[0].(*DictView)
+            return view.dict.iterator(iteratorClass, "dictionary", view.item)
+        })
+        class.method("__reversed__", 0, 0, func(env *Environment, args []Object) Object {
+            view := args[0].(*DictView)
+            return view.dict.reversed(reversedClass, "dictionary", view.item)
+        })
+        class.method("__repr__", 0, 0, func(env *Environment, args []Object) Object {
+            view := args[0].(*DictView)
+            s, ok, err := reprJoin(env, view.dict, view.items(), ", ")
+            if err != nil {
+                return err
+            }
+            if !ok {
+                s = "..."
+            }
+            return &String{Value: class.Name + "([" + s + "])"}
+        })
+    }
+    dictValuesType.method("__contains__", 1, 1, func(env *Environment, args []Object) Object {
+        return containsElement(env, args[0].(*DictView).items(), args[1])
+    })
+    dictKeysType.method("__contains__", 1, 1, func(env *Environment, args []Object) Object {
+        value, err := args[0].(*DictView).dict.Get(env, args[1])
+        if err != nil {
+            return err
+        }
+        return nativeBool(value != nil)
+    })
+    dictItemsType.method("__contains__", 1, 1, func(env *Environment, args []Object) Object {
+        pair, ok := args[1].(*Tuple)
+        if !ok || len(pair.Elements) != 2 {
+            return FALSE
+        }
+        value, err := args[0].(*DictView).dict.Get(env, pair.Elements[0])
+        if err != nil || value == nil {
+            return nativeBool(false)
+        }
+        eq, err := equals(env, value, pair.Elements[1])
+        if err != nil {
+            return err
+        }
+        return nativeBool(eq)
+    })
+    // Keys and items views are set-like
+    for _, class := range []*Class{dictKeysType, dictItemsType} {
+        for _, operator := range []string{"&", "|", "-", "^"} {
+            operator := operator
+            class.method(binaryOperators[operator].method, 1, 1, func(env *Environment, args []Object) Object {
+                left, err := setFromIterable(env, args[0], false)
+                if err != nil {
+                    return err
+                }
+                right, err := setFromIterable(env, args[1], false)
+                if err != nil {
+                    return err
+                }
+                return setOperation(env, operator, left, right)
+            })
+        }
+        class.method("__eq__", 1, 1, func(env *Environment, args []Object) Object {
+            if _, ok := args[1].(*DictView); !ok {
+                if _, ok := payload(args[1]).(*Set); !ok {
+                    return NotImplemented
+                }
+            }
+            left, err := setFromIterable(env, args[0], false)
+            if err != nil {
+                return err
+            }
+            right, err := setFromIterable(env, args[1], false)
+            if err != nil {
+                return err
+            }
+            return setCompare(env, "==", left, right)
+        })
+    }
+}
+
+// dictFromKeys is cls.fromkeys(iterable, value=None)
+func dictFromKeys(env *Environment, cls Object, args []Object) Object {
+    var value Object = NULL
+    if len(args) == 2 {
+        value = args[1]
+    }
+    if cls == dictType {
+        dict := NewDict()
+        if err := iterate(env, args[0], func(key Object) *Error { return dict.Set(env, key, value) }); err != nil {
+            return err
+        }
+        return dict
+    }
+    result := applyFunction(env, cls, nil, nil)
+    if isError(result) {
+        return result
+    }
+    if err := iterate(env, args[0], func(key Object) *Error { return setItem(env, result, key, value) }); err != nil {
+        return err
+    }
+    return result
+}
+
+func dictEquals(env *Environment, a, b *Dict) Object {
+    if a.Len() != b.Len() {
+        return FALSE
+    }
+    for _, entry := range a.Entries() {
+        value, err := b.Get(env, entry.Key)
+        if err != nil {
+            return err
+        }
+        if value == nil {
+            return FALSE
+        }
+        eq, err := equals(env, entry.Value, value)
+        if err != nil {
+            return err
+        }
+        if !eq {
+            return FALSE
+        }
+    }
+    return TRUE
+}
+
+func setFromIterable(env *Environment, iterable Object, frozen bool) (*Set, *Error) {
+    set := &Set{Items: NewDict(), Frozen: frozen}
+    err := iterate(env, iterable, func(item Object) *Error {
+        return set.Add(env, item)
+    })
+    return set, err
+}
+
+// setOperation is | & - ^ between two sets; the result takes the left's kind
+func setOperation(env *Environment, operator string, left, right *Set) Object {
+    result := &Set{Items: NewDict(), Frozen: left.Frozen}
+    add := func(items []Object, keep func(Object) (bool, *Error)) *Error {
+        for _, item := range items {
+            ok, err := keep(item)
+            if err != nil {
+                return err
+            }
+            if ok {
+                if err := result.Add(env, item); err != nil {
+                    return err
+                }
+            }
+        }
+        return nil
+    }
+    in := func(set *Set, want bool) func(Object) (bool, *Error) {
+        return func(item Object) (bool, *Error) {
+            ok, err := set.Contains(env, item)
+            return ok == want, err
+        }
+    }
+    always := func(Object) (bool, *Error) { return true, nil }
+
+    var err *Error
+    switch operator {
+    case "|":
+        if err = add(left.elements(), always); err == nil {
+            err = add(right.elements(), always)
+        }
+    case "&":
+        err = add(left.elements(), in(right, true))
+    case "-":
+        err = add(left.elements(), in(right, false))
+    case "^":
+        if err = add(left.elements(), in(right, false)); err == nil {
+            err = add(right.elements(), in(left, false))
+        }
+    }
+    if err != nil {
+        return err
+    }
+    return result
+}
+
+// setCompare is subset and equality between sets
+func setCompare(env *Environment, operator string, left, right *Set) Object {
+    subset := func(a, b *Set) (bool, *Error) {
+        if a.Items.Len() > b.Items.Len() {
+            return false, nil
+        }
+        for _, item := range a.elements() {
+            ok, err := b.Contains(env, item)
+            if err != nil || !ok {
+                return false, err
+            }
+        }
+        return true, nil
+    }
+
+    var result bool
+    var err *Error
+    switch operator {
+    case "==", "!=":
+        result = left.Items.Len() == right.Items.Len()
+        if result {
+            result, err = subset(left, right)
+        }
+        if operator == "!=" {
+            result = !result
+        }
+    case "<=":
+        result, err = subset(left, right)
+    case "<":
+        result, err = subset(left, right)
+        result = result && left.Items.Len() < right.Items.Len()
+    case ">=":
+        result, err = subset(right, left)
+    case ">":
+        result, err = subset(right, left)
+        result = result && left.Items.Len() > right.Items.Len()
+    }
+    if err != nil {
+        return err
+    }
+    return nativeBool(result)
+}
+
+func initSet() {
+    setType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        cls, err := newClassArg("set", args)
+        if err != nil {
+            return err
+        }
+        return wrapBuiltinValue(cls, setType, NewSet())
+    })
+    setType.define("__init__", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("set", args[1:], kwargs, 0, 1); err != nil {
+            return err
+        }
+        set := asSet(args[0])
+        set.Items.clear()
+        if len(args) == 2 {
+            if err := iterate(env, args[1], func(item Object) *Error { return set.Add(env, item) }); err != nil {
+                return err
+            }
+        }
+        return NULL
+    })
+    frozensetType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        cls, err := newClassArg("frozenset", args)
+        if err != nil {
+            return err
+        }
+        if err := checkArgs("frozenset", args[1:], kwargs, 0, 1); err != nil {
+            return err
+        }
+        set := &Set{Items: NewDict(), Frozen: true}
+        if len(args) == 2 {
+            if set, err = setFromIterable(env, args[1], true); err != nil {
+                return err
+            }
+        }
+        return wrapBuiltinValue(cls, frozensetType, set)
+    })
+    setType.Dict.SetStr("__hash__", NULL)
+    frozensetType.method("__hash__", 0, 0, func(env *Environment, args []Object) Object {
+        return newInt(frozensetHash(asSet(args[0])))
+    })
+
+    for _, class := range []*Class{setType, frozensetType} {
+        class := class
+        class.method("__len__", 0, 0, func(env *Environment, args []Object) Object {
+            return newInt(int64(asSet(args[0]).Items.Len()))
+        })
+        class.method("__iter__", 0, 0, func(env *Environment, args []Object) Object {
+            return asSet(args[0]).Items.iterator(setIteratorType, "Set", func(entry *dictEntry) Object { return entry.Key })
+        })
+        class.method("__contains__", 1, 1, func(env *Environment, args []Object) Object {
+            item := args[1]
+            if s, ok := item.(*Set); ok && !s.Frozen {
+                item = &Set{Items: s.Items, Frozen: true}
+            }
+            ok, err := asSet(args[0]).Contains(env, item)
+            if err != nil {
+                return err
+            }
+            return nativeBool(ok)
+        })
+        class.method("__repr__", 0, 0, func(env *Environment, args []Object) Object {
+            set := asSet(args[0])
+            name := typeName(args[0])
+            elements := set.elements()
+            if len(elements) == 0 {
+                return &String{Value: name + "()"}
+            }
+            s, ok, err := reprJoin(env, args[0], elements, ", ")
+            if err != nil {
+                return err
+            }
+            if !ok {
+                return &String{Value: name + "(...)"}
+            }
+            if typeOf(args[0]) == setType {
+                return &String{Value: "{" + s + "}"}
+            }
+            return &String{Value: name + "({" + s + "})"}
+        })
+        for _, operator := range []string{"&", "|", "-", "^"} {
+            operator := operator
+            class.method(binaryOperators[operator].method, 1, 1, func(env *Environment, args []Object) Object {
+                other, ok := payload(args[1]).(*Set)
+                if !ok {
+                    return NotImplemented
+                }
+                return setOperation(env, operator, asSet(args[0]), other)
+            })
+        }
+        for _, operator := range []string{"==", "!=", "<", "<=", ">", ">="} {
+            operator := operator
+            class.method(comparisonOperators[operator][0], 1, 1, func(env *Environment, args []Object) Object {
+                other, ok := payload(args[1]).(*Set)
+                if !ok {
+                    return NotImplemented
+                }
+                return setCompare(env, operator, asSet(args[0]), other)
+            })
+        }
+        for name, operator := range map[string]string{"union": "|", "intersection": "&",
+            "difference": "-", "symmetric_difference": "^"} {
+            operator := operator
+            class.method(name, 0, -1, func(env *Environment, args []Object) Object {
+                result := asSet(args[0])
+                result = &Set{Items: result.Items.Copy(), Frozen: result.Frozen}
+                for _, arg := range args[1:] {
+                    other, err := setFromIterable(env, arg, false)
+                    if err != nil {
+                        return err
+                    }
+                    combined := setOperation(env, operator, result, other)
+                    if isError(combined) {
+                        return combined
+                    }
+                    result = combined.(*Set)
+                }
+                return result
+            })
+        }
+        for name, operator := range map[string]string{"issubset": "<=", "issuperset": ">="} {
+            operator := operator
+            class.method(name, 1, 1, func(env *Environment, args []Object) Object {
+                other, err := setFromIterable(env, args[1], false)
+                if err != nil {
+                    return err
+                }
+                return setCompare(env, operator, asSet(args[0]), other)
+            })
+        }
+        class.method("isdisjoint", 1, 1, func(env *Environment, args []Object) Object {
+            set := asSet(args[0])
+            disjoint := true
+            err := iterate(env, args[1], func(item Object) *Error {
+                ok, err := set.Contains(env, item)
+                if err != nil {
+                    return err
+                }
+                if ok {
+                    disjoint = false
+                    return errStopLoop
+                }
+                return nil
+            })
+            if err != nil {
+                return err
+            }
+            return nativeBool(disjoint)
+        })
+        class.method("copy", 0, 0, func(env *Environment, args []Object) Object {
+            set := asSet(args[0])
+            if set.Frozen && typeOf(args[0]) == frozensetType {
+                return args[0]
+            }
+            return &Set{Items: set.Items.Copy(), Frozen: set.Frozen}
+        })
+    }
+
+    setType.method("add", 1, 1, func(env *Environment, args []Object) Object {
+        if err := asSet(args[0]).Add(env, args[1]); err != nil {
+            return err
+        }
+        return NULL
+    })
+    setType.method("discard", 1, 1, func(env *Environment, args []Object) Object {
+        if _, err := asSet(args[0]).Items.Delete(env, args[1]); err != nil {
+            return err
+        }
+        return NULL
+    })
+    setType.method("remove", 1, 1, func(env *Environment, args []Object) Object {
+        value, err := asSet(args[0]).Items.Delete(env, args[1])
+        if err != nil {
+            return err
+        }
+        if value == nil {
+            return keyError(args[1])
+        }
+        return NULL
+    })
+    setType.method("pop", 0, 0, func(env *Environment, args []Object) Object {
+        items := asSet(args[0]).Items
+        for i, entry := range items.entries {
+            if !entry.deleted {
+                items.deleteAt(i)
+                return entry.Key
+            }
+        }
+        return newErrorKind(keyErrorType, "pop from an empty set")
+    })
+    setType.method("clear", 0, 0, func(env *Environment, args []Object) Object {
+        asSet(args[0]).Items.clear()
+        return NULL
+    })
+    for name, operator := range map[string]string{"update": "|", "intersection_update": "&",
+        "difference_update": "-", "symmetric_difference_update": "^"} {
+        operator := operator
+        setType.method(name, 0, -1, func(env *Environment, args []Object) Object {
+            set := asSet(args[0])
+            for _, arg := range args[1:] {
+                other, err := setFromIterable(env, arg, false)
+                if err != nil {
+                    return err
+                }
+                combined := setOperation(env, operator, set, other)
+                if isError(combined) {
+                    return combined
+                }
+                set.Items = combined.(*Set).Items
+            }
+            return NULL
+        })
+    }
+    for _, operator := range []string{"&", "|", "-", "^"} {
+        operator := operator
+        setType.method(binaryOperators[operator].inplace, 1, 1, func(env *Environment, args []Object) Object {
+            other, ok := payload(args[1]).(*Set)
+            if !ok {
+                return NotImplemented
+            }
+            set := asSet(args[0])
+            combined := setOperation(env, operator, set, other)
+            if isError(combined) {
+                return combined
+            }
+            set.Items = combined.(*Set).Items
+            return args[0]
+        })
+    }
+}
+
+func initSlice() {
+    sliceType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("slice", args[1:], kwargs, 1, 3); err != nil {
+            return err
+        }
+        switch len(args) {
+        case 2:
+            return &Slice{Start: NULL, Stop: args[1], Step: NULL}
+        case 3:
+            return &Slice{Start: args[1], Stop: args[2], Step: NULL}
+        }
+        return &Slice{Start: args[1], Stop: args[2], Step: args[3]}
+    })
+    sliceType.method("__repr__", 0, 0, func(env *Environment, args []Object) Object {
+        s := args[0].(*Slice)
+        text, _, err := reprJoin(env, s, []Object{s.Start, s.Stop, s.Step}, ", ")
+        if err != nil {
+            return err
+        }
+        return &String{Value: "slice(" + text + ")"}
+    })
+    sliceType.method("__eq__", 1, 1, func(env *Environment, args []Object) Object {
+        other, ok := args[1].(*Slice)
+        if !ok {
+            return NotImplemented
+        }
+        s := args[0].(*Slice)
+        return compareSequences(env, "==", []Object{s.Start, s.Stop, s.Step}, []Object{other.Start, other.Stop, other.Step})
+    })
+    sliceType.Dict.SetStr("__hash__", NULL)
+    sliceType.method("indices", 1, 1, func(env *Environment, args []Object) Object {
+        n, err := toIndex(env, args[1])
+        if err != nil {
+            return err
+        }
+        if n < 0 {
+            return valueError("length should not be negative")
+        }
+        start, stop, step, err := args[0].(*Slice).indices(env, n)
+        if err != nil {
+            return err
+        }
+        return &Tuple{Elements: []Object{newInt(int64(start)), newInt(int64(stop)), newInt(int64(step))}}
+    })
+}
@@ -0,0 +1,457 @@
+// Comments in this file are inspired by Sheila Sazs - she decides who gets in, and makes sure everyone leaves properly
+
+package evaluator
+
+// contextlib: the usual ways of writing and combining context managers
+
+func init() {
+    registerModule("contextlib", buildContextlib)
+}
+
+func buildContextlib(m *Module) {
+    abstract := m.class("AbstractContextManager")
+    abstract.method("__enter__", 0, 0, func(env *Environment, args []Object) Object {
+        return args[0]
+    })
+    abstract.define("__exit__", func(env *Environment, args []Object, kwargs *Dict) Object {
+        return NULL
+    })
+
+    // ContextDecorator lets a context manager wrap a whole function call
+    decorator := m.class("ContextDecorator")
+    decorator.method("_recreate_cm", 0, 0, func(env *Environment, args []Object) Object {
+        return args[0]
+    })
+    decorator.method("__call__", 1, 1, func(env *Environment, args []Object) Object {
+        self, fn := args[0], args[1]
+        return wrapFunction(fn, func(env *Environment, args []Object, kwargs *Dict) Object {
+            manager := callAttribute(env, self, "_recreate_cm")
+            if isError(manager) {
+                return manager
+            }
+            return runWith(env, manager, func(Object) Object {
+                return applyFunction(env, fn, args, kwargs)
+            })
+        })
+    })
+
+    generatorManager(m, abstract, decorator)
+
+    suppress := m.class("suppress", abstract)
+    suppress.define("__init__", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if kwargs.Len() > 0 {
+            return typeError("suppress() takes no keyword arguments")
+        }
+        return setField(env, args[0], "_exceptions", &Tuple{Elements: args[1:]})
+    })
+    suppress.method("__exit__", 3, 3, func(env *Environment, args []Object) Object {
+        if args[1] == NULL {
+            return NULL
+        }
+        exceptions := getAttribute(env, args[0], "_exceptions")
+        if isError(exceptions) {
+            return exceptions
+        }
+        cls, ok := args[1].(*Class)
+        if !ok {
+            return FALSE
+        }
+        matched, err := classCheck("issubclass", cls, exceptions)
+        if err != nil {
+            return err
+        }
+        if matched {
+            return TRUE
+        }
+        // Only some of a group may be suppressed; the rest carries on
+        if exc, ok := args[2].(*Exception); ok && isExceptionGroup(exc) {
+            matcher, err := newExceptionMatcher(exceptions)
+            if err != nil {
+                return err
+            }
+            _, rest, err := splitExceptionGroup(env, exc, matcher, true)
+            if err != nil {
+                return err
+            }
+            if rest == nil {
+                return TRUE
+            }
+            return &Error{Exception: rest}
+        }
+        return FALSE
+    })
+
+    closing := m.class("closing", abstract)
+    closing.method("__init__", 1, 1, func(env *Environment, args []Object) Object {
+        return setField(env, args[0], "thing", args[1])
+    })
+    closing.method("__enter__", 0, 0, func(env *Environment, args []Object) Object {
+        return getAttribute(env, args[0], "thing")
+    })
+    closing.define("__exit__", func(env *Environment, args []Object, kwargs *Dict) Object {
+        thing := getAttribute(env, args[0], "thing")
+        if isError(thing) {
+            return thing
+        }
+        if result := callAttribute(env, thing, "close"); isError(result) {
+            return result
+        }
+        return NULL
+    })
+
+    null := m.class("nullcontext", abstract)
+    null.define("__init__", func(env *Environment, args []Object, kwargs *Dict) Object {
+        values, err := parseArgs("nullcontext", args[1:], kwargs, []string{"enter_result"}, 0)
+        if err != nil {
+            return err
+        }
+        return setField(env, args[0], "enter_result", nilToNone(values[0]))
+    })
+    null.method("__enter__", 0, 0, func(env *Environment, args []Object) Object {
+        return getAttribute(env, args[0], "enter_result")
+    })
+
+    // redirect_stdout and redirect_stderr: swap a sys stream for the length of a with
+    for _, stream := range []string{"stdout", "stderr"} {
+        stream := stream
+        redirect := m.class("redirect_"+stream, abstract)
+        redirect.method("__init__", 1, 1, func(env *Environment, args []Object) Object {
+            if err := setAttribute(env, args[0], "_new_target", args[1]); err != nil {
+                return err
+            }
+            return setField(env, args[0], "_old_targets", &List{})
+        })
+        redirect.method("__enter__", 0, 0, func(env *Environment, args []Object) Object {
+            old, ok := getAttribute(env, args[0], "_old_targets").(*List)
+            target := getAttribute(env, args[0], "_new_target")
+            if !ok || isError(target) {
+                return attributeError("'redirect_%s' object is missing its targets", stream)
+            }
+            current, err := env.interp.stream(stream)
+            if err != nil {
+                return err
+            }
+            old.Elements = append(old.Elements, current)
+            env.interp.sys.Env.Set(stream, target)
+            return target
+        })
+        redirect.define("__exit__", func(env *Environment, args []Object, kwargs *Dict) Object {
+            old, ok := getAttribute(env, args[0], "_old_targets").(*List)
+            if !ok || len(old.Elements) == 0 {
+                return indexError("pop from empty list")
+            }
+            last := len(old.Elements) - 1
+            env.interp.sys.Env.Set(stream, old.Elements[last])
+            old.Elements = old.Elements[:last]
+            return NULL
+        })
+    }
+
+    exitStack(m, abstract)
+}
+
+// generatorManager is what @contextmanager turns a generator function into:
+// everything before the yield is __enter__, everything after it __exit__
+func generatorManager(m *Module, abstract, decorator *Class) {
+    cls := m.class("_GeneratorContextManager", abstract, decorator)
+    cls.method("__init__", 3, 3, func(env *Environment, args []Object) Object {
+        self, fn, fnArgs, fnKwargs := args[0], args[1], args[2], args[3]
+        positional, ok := fnArgs.(*Tuple)
+        keywords, isDict := fnKwargs.(*Dict)
+        if !ok || !isDict {
+            return typeError("_GeneratorContextManager() expects a tuple and a dict of arguments")
+        }
+        gen := applyFunction(env, fn, positional.Elements, keywords)
+        if isError(gen) {
+            return gen
+        }
+        for _, field := range []struct {
+            name  string
+            value Object
+        }{{"gen", gen}, {"func", fn}, {"args", fnArgs}, {"kwds", fnKwargs}} {
+            if err := setAttribute(env, self, field.name, field.value); err != nil {
+                return err
+            }
+        }
+        if doc := getAttribute(env, fn, "__doc__"); !isError(doc) {
+            return setField(env, self, "__doc__", doc)
+        }
+        return NULL
+    })
+    // Used as a decorator, each call needs a generator of its own
+    cls.method("_recreate_cm", 0, 0, func(env *Environment, args []Object) Object {
+        fields := make([]Object, 3)
+        for i, name := range []string{"func", "args", "kwds"} {
+            if fields[i] = getAttribute(env, args[0], name); isError(fields[i]) {
+                return fields[i]
+            }
+        }
+        return instantiate(env, typeOf(args[0]), fields, nil)
+    })
+    cls.method("__enter__", 0, 0, func(env *Environment, args []Object) Object {
+        gen := getAttribute(env, args[0], "gen")
+        if isError(gen) {
+            return gen
+        }
+        value := sendInto(env, gen, NULL)
+        if isStopIteration(value) {
+            return runtimeError("generator didn't yield")
+        }
+        return value
+    })
+    cls.method("__exit__", 3, 3, func(env *Environment, args []Object) Object {
+        gen := getAttribute(env, args[0], "gen")
+        if isError(gen) {
+            return gen
+        }
+        typ, value, traceback := args[1], args[2], args[3]
+        if typ == NULL {
+            result := sendInto(env, gen, NULL)
+            if isStopIteration(result) {
+                return FALSE
+            }
+            if isError(result) {
+                return result
+            }
+            return runtimeError("generator didn't stop")
+        }
+
+        if value == NULL {
+            cls, ok := typ.(*Class)
+            if !ok {
+                return typeError("exceptions must derive from BaseException")
+            }
+            value = instantiate(env, cls, nil, nil)
+            if isError(value) {
+                return value
+            }
+        }
+        exc, ok := value.(*Exception)
+        if !ok {
+            return typeError("exceptions must derive from BaseException")
+        }
+        result := throwIntoGenerator(env, gen, exc)
+        if err, ok := result.(*Error); ok {
+            raised := err.Exception
+            switch {
+            case err.matches(stopIterationType):
+                // The generator ate it - unless it was the StopIteration we threw
+                return nativeBool(raised != exc)
+            case raised == exc:
+                restoreTraceback(exc, traceback)
+                return FALSE
+            case err.matches(runtimeErrorType) && exc.Class.isSubclass(stopIterationType) && raised.Cause == exc:
+                // PEP 479 turned our StopIteration into a RuntimeError
+                restoreTraceback(exc, traceback)
+                return FALSE
+            }
+            return err
+        }
+        stop := runtimeError("generator didn't stop after throw()")
+        if closed := closeGenerator(env, gen); isError(closed) {
+            chainContext(closed.(*Error).Exception, stop.Exception)
+            return closed
+        }
+        return stop
+    })
+
+    m.function("contextmanager", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("contextmanager", args, kwargs, 1, 1); err != nil {
+            return err
+        }
+        fn := args[0]
+        return wrapFunction(fn, func(env *Environment, args []Object, kwargs *Dict) Object {
+            if kwargs == nil {
+                kwargs = NewDict()
+            }
+            return instantiate(env, cls, []Object{fn, &Tuple{Elements: args}, kwargs}, nil)
+        })
+    })
+}
+
+// exitStack is ExitStack: a with statement whose managers are decided at run time
+func exitStack(m *Module, abstract *Class) {
+    cls := m.class("ExitStack", abstract)
+    callbacks := func(env *Environment, self Object) (*List, *Error) {
+        list, ok := getAttribute(env, self, "_exit_callbacks").(*List)
+        if !ok {
+            return nil, attributeError("'%s' object has no attribute '_exit_callbacks'", typeName(self))
+        }
+        return list, nil
+    }
+    push := func(env *Environment, self Object, callback Object) Object {
+        list, err := callbacks(env, self)
+        if err != nil {
+            return err
+        }
+        list.Elements = append(list.Elements, callback)
+        return NULL
+    }
+
+    cls.method("__init__", 0, 0, func(env *Environment, args []Object) Object {
+        return setField(env, args[0], "_exit_callbacks", &List{})
+    })
+    cls.method("enter_context", 1, 1, func(env *Environment, args []Object) Object {
+        manager := args[1]
+        managerCls := typeOf(manager)
+        enter, exit := managerCls.lookupName("__enter__"), managerCls.lookupName("__exit__")
+        if enter == nil || exit == nil {
+            return typeError("'%s.%s' object does not support the context manager protocol",
+                managerCls.module(), managerCls.qualname())
+        }
+        result := callMethod(env, enter, manager)
+        if isError(result) {
+            return result
+        }
+        if err := push(env, args[0], bindAttribute(exit, manager)); isError(err) {
+            return err
+        }
+        return result
+    })
+    // push takes a context manager's __exit__, or anything shaped like one
+    cls.method("push", 1, 1, func(env *Environment, args []Object) Object {
+        exit := args[1]
+        callback := exit
+        if method := typeOf(exit).lookupName("__exit__"); method != nil {
+            callback = bindAttribute(method, exit)
+        }
+        if err := push(env, args[0], callback); isError(err) {
+            return err
+        }
+        return exit
+    })
+    cls.define("callback", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if len(args) < 2 {
+            return typeError("ExitStack.callback() missing 1 required positional argument: 'callback'")
+        }
+        fn, fnArgs := args[1], args[2:]
+        wrapper := wrapFunction(fn, func(env *Environment, args []Object, _ *Dict) Object {
+            return applyFunction(env, fn, fnArgs, kwargs)
+        })
+        if err := push(env, args[0], wrapper); isError(err) {
+            return err
+        }
+        return fn
+    })
+    cls.method("pop_all", 0, 0, func(env *Environment, args []Object) Object {
+        list, err := callbacks(env, args[0])
+        if err != nil {
+            return err
+        }
+        stack := instantiate(env, typeOf(args[0]), nil, nil)
+        if isError(stack) {
+            return stack
+        }
+        if err := setAttribute(env, stack, "_exit_callbacks", &List{Elements: list.Elements}); err != nil {
+            return err
+        }
+        list.Elements = nil
+        return stack
+    })
+    cls.method("close", 0, 0, func(env *Environment, args []Object) Object {
+        result := callAttribute(env, args[0], "__exit__", NULL, NULL, NULL)
+        if isError(result) {
+            return result
+        }
+        return NULL
+    })
+
+    // __exit__ unwinds the callbacks, newest first. Each sees the exception
+    // left by the ones before it; one that returns true swallows it.
+    cls.method("__exit__", 3, 3, func(env *Environment, args []Object) Object {
+        list, err := callbacks(env, args[0])
+        if err != nil {
+            return err
+        }
+        details := []Object{args[1], args[2], args[3]}
+        received := args[1] != NULL
+        suppressed := false
+        var pending *Error
+        for len(list.Elements) > 0 {
+            last := len(list.Elements) - 1
+            callback := list.Elements[last]
+            list.Elements = list.Elements[:last]
+
+            current, _ := details[1].(*Exception)
+            if current != nil {
+                env.interp.pushHandled(current)
+            }
+            result := applyFunction(env, callback, details, nil)
+            if current != nil {
+                env.interp.popHandled()
+            }
+
+            if raised, ok := result.(*Error); ok {
+                chainContext(raised.Exception, current)
+                pending = raised
+                details = []Object{raised.Exception.Class, raised.Exception, tracebackOrNone(raised.Exception)}
+                continue
+            }
+            swallow, err := truthy(env, result)
+            if err != nil {
+                return err
+            }
+            if swallow {
+                suppressed, pending = true, nil
+                details = []Object{NULL, NULL, NULL}
+            }
+        }
+        if pending != nil {
+            return pending
+        }
+        return nativeBool(received && suppressed)
+    })
+}
+
+// wrapFunction is a Go function passing itself off as fn, the way functools.wraps would
+func wrapFunction(fn Object, call BuiltinFunction) *Builtin {
+    name := "wrapper"
+    if value, ok := wrappedAttribute(fn, "__name__"); ok {
+        if s, ok := value.(*String); ok {
+            name = s.Value
+        }
+    }
+    return &Builtin{Name: name, Fn: call, Wraps: fn}
+}
+
+// setField is setattr(obj, name, value), as a method's result
+func setField(env *Environment, obj Object, name string, value Object) Object {
+    if err := setAttribute(env, obj, name, value); err != nil {
+        return err
+    }
+    return NULL
+}
+
+// callAttribute is obj.name(*args)
+func callAttribute(env *Environment, obj Object, name string, args ...Object) Object {
+    method := getAttribute(env, obj, name)
+    if isError(method) {
+        return method
+    }
+    return applyFunction(env, method, args, nil)
+}
+
+// throwIntoGenerator is gen.throw(exc)
+func throwIntoGenerator(env *Environment, gen Object, exc *Exception) Object {
+    if g, ok := gen.(*Generator); ok {
+        return g.resume(generatorInput{err: &Error{Exception: exc}})
+    }
+    return callAttribute(env, gen, "throw", exc)
+}
+
+func closeGenerator(env *Environment, gen Object) Object {
+    if g, ok := gen.(*Generator); ok {
+        return g.close()
+    }
+    return callAttribute(env, gen, "close")
+}
+
+// restoreTraceback puts back the traceback an exception had before it went
+// through a generator, which would otherwise show up in it
+func restoreTraceback(exc *Exception, traceback Object) {
+    if tb, ok := traceback.(*Traceback); ok {
+        exc.Traceback = tb
+    } else {
+        exc.Traceback = nil
+    }
+}
@@ -0,0 +1,2409 @@
+// Comments in this file are inspired by Anita Gibbs - she took her time building a case, and it was always airtight
+
+package evaluator
+
+import (
+    "fmt"
+    "io"
+    "io/fs"
+    "math"
+    "math/big"
+    "os"
+    "path/filepath"
+    "strings"
+    "sync"
+    "time"
+)
+
+// datetime: dates, times of day, the spans between them and the zones they
+// happen in, after CPython's _datetimemodule. The values are Go structs, the
+// way a deque is; zoneinfo reads the system tz database through Go's
+// time.LoadLocation.
+
+var (
+    timedeltaType       = newBuiltinClass("timedelta", objectType)
+    dateType            = newBuiltinClass("date", objectType)
+    timeOfDayType       = newBuiltinClass("time", objectType)
+    datetimeType        = newBuiltinClass("datetime", dateType)
+    tzinfoType          = newBuiltinClass("tzinfo", objectType)
+    timezoneType        = newBuiltinClass("timezone", tzinfoType)
+    isoCalendarDateType = newBuiltinClass("IsoCalendarDate", tupleType)
+    zoneInfoType        = newBuiltinClass("ZoneInfo", tzinfoType)
+    zoneNotFoundType    = newBuiltinClass("ZoneInfoNotFoundError", keyErrorType)
+)
+
+const (
+    minYear      = 1
+    maxYear      = 9999
+    maxOrdinal   = 3652059 // 9999-12-31
+    epochOrdinal = 719163  // 1970-01-01
+    maxDeltaDays = 999999999
+)
+
+func init() {
+    registerModule("datetime", buildDatetime)
+    registerModule("zoneinfo", buildZoneInfo)
+
+    for _, cls := range []*Class{timedeltaType, dateType, timeOfDayType, datetimeType, tzinfoType, timezoneType, isoCalendarDateType} {
+        cls.Dict.SetStr("__module__", &String{Value: "datetime"})
+    }
+    for _, cls := range []*Class{zoneInfoType, zoneNotFoundType} {
+        cls.Dict.SetStr("__module__", &String{Value: "zoneinfo"})
+    }
+    initTimedelta()
+    initDate()
+    initTimeOfDay()
+    initDatetime()
+    initTzinfo()
+    initTimezone()
+    initIsoCalendarDate()
+    initZoneInfo()
+}
+
+// TimeDelta is a span, kept the way CPython keeps it: any number of days,
+// then 0 <= seconds < 86400 and 0 <= micros < 1000000 on top
+type TimeDelta struct {
+    days, seconds, micros int64
+}
+
+func (d *TimeDelta) Type() ObjectType { return TIMEDELTA_OBJ }
+func (d *TimeDelta) Inspect() string  { return "datetime.timedelta(" + d.fields() + ")" }
+
+// total is the span in microseconds. Only spans under a few hundred
+// thousand years fit, which covers every offset a zone can have.
+func (d *TimeDelta) total() int64 { return (d.days*86400+d.seconds)*1e6 + d.micros }
+
+func (d *TimeDelta) bigTotal() *big.Int {
+    n := new(big.Int).Mul(big.NewInt(d.days), big.NewInt(86400e6))
+    return n.Add(n, big.NewInt(d.seconds*1e6+d.micros))
+}
+
+func (d *TimeDelta) cmp(other *TimeDelta) int {
+    for _, pair := range [][2]int64{{d.days, other.days}, {d.seconds, other.seconds}, {d.micros, other.micros}} {
+        switch {
+        case pair[0] < pair[1]:
+            return -1
+        case pair[0] > pair[1]:
+            return 1
+        }
+    }
+    return 0
+}
+
+func (d *TimeDelta) isZero() bool { return d.days == 0 && d.seconds == 0 && d.micros == 0 }
+
+// fields is what goes between the parentheses of the repr
+func (d *TimeDelta) fields() string {
+    parts := []string{}
+    if d.days != 0 {
+        parts = append(parts, fmt.Sprintf("days=%d", d.days))
+    }
+    if d.seconds != 0 {
+        parts = append(parts, fmt.Sprintf("seconds=%d", d.seconds))
+    }
+    if d.micros != 0 {
+        parts = append(parts, fmt.Sprintf("microseconds=%d", d.micros))
+    }
+    if len(parts) == 0 {
+        return "0"
+    }
+    return strings.Join(parts, ", ")
+}
+
+// str is "-1 day, 0:00:05.500000"
+func (d *TimeDelta) str() string {
+    s := fmt.Sprintf("%d:%02d:%02d", d.seconds/3600, d.seconds/60%60, d.seconds%60)
+    if d.micros != 0 {
+        s += fmt.Sprintf(".%06d", d.micros)
+    }
+    if d.days != 0 {
+        unit := "days"
+        if d.days == 1 || d.days == -1 {
+            unit = "day"
+        }
+        s = fmt.Sprintf("%d %s, %s", d.days, unit, s)
+    }
+    return s
+}
+
+func timeDeltaOf(micros int64) *TimeDelta {
+    days, rest := micros/86400e6, micros%86400e6
+    if rest < 0 {
+        days, rest = days-1, rest+86400e6
+    }
+    return &TimeDelta{days: days, seconds: rest / 1e6, micros: rest % 1e6}
+}
+
+// timeDeltaFromBig is timeDeltaOf for results that may not fit, and the
+// OverflowError when they don't
+func timeDeltaFromBig(micros *big.Int) (*TimeDelta, *Error) {
+    days, rest := floorDivMod(micros, big.NewInt(86400e6))
+    if days.CmpAbs(big.NewInt(maxDeltaDays)) > 0 {
+        return nil, overflowError("days=%s; must have magnitude <= %d", days, maxDeltaDays)
+    }
+    return &TimeDelta{days: days.Int64(), seconds: rest.Int64() / 1e6, micros: rest.Int64() % 1e6}, nil
+}
+
+// Date is a day in the proleptic Gregorian calendar
+type Date struct {
+    year, month, day int
+}
+
+func (d *Date) Type() ObjectType { return DATE_OBJ }
+func (d *Date) Inspect() string {
+    return fmt.Sprintf("datetime.date(%d, %d, %d)", d.year, d.month, d.day)
+}
+
+func (d *Date) ordinal() int       { return toOrdinal(d.year, d.month, d.day) }
+func (d *Date) isoformat() string { return fmt.Sprintf("%04d-%02d-%02d", d.year, d.month, d.day) }
+
+// TimeOfDay is datetime.time. tzinfo is None for a naive one.
+type TimeOfDay struct {
+    hour, minute, second, micro int
+    tzinfo                      Object
+    fold                        int
+}
+
+func (t *TimeOfDay) Type() ObjectType { return TIME_OBJ }
+func (t *TimeOfDay) Inspect() string  { return "datetime.time(" + t.fields() + ")" }
+
+func (t *TimeOfDay) seconds() int { return t.hour*3600 + t.minute*60 + t.second }
+
+// fields is the repr's numbers, leaving off trailing zero seconds and
+// microseconds
+func (t *TimeOfDay) fields() string {
+    s := fmt.Sprintf("%d, %d", t.hour, t.minute)
+    switch {
+    case t.micro != 0:
+        s += fmt.Sprintf(", %d, %d", t.second, t.micro)
+    case t.second != 0:
+        s += fmt.Sprintf(", %d", t.second)
+    }
+    return s
+}
+
+// isoformat is HH:MM:SS with as much of the rest as timespec asks for
+func (t *TimeOfDay) isoformat(timespec string) (string, *Error) {
+    switch timespec {
+    case "auto":
+        if t.micro != 0 {
+            return t.isoformat("microseconds")
+        }
+        return t.isoformat("seconds")
+    case "hours":
+        return fmt.Sprintf("%02d", t.hour), nil
+    case "minutes":
+        return fmt.Sprintf("%02d:%02d", t.hour, t.minute), nil
+    case "seconds":
+        return fmt.Sprintf("%02d:%02d:%02d", t.hour, t.minute, t.second), nil
+    case "milliseconds":
+        return fmt.Sprintf("%02d:%02d:%02d.%03d", t.hour, t.minute, t.second, t.micro/1000), nil
+    case "microseconds":
+        return fmt.Sprintf("%02d:%02d:%02d.%06d", t.hour, t.minute, t.second, t.micro), nil
+    }
+    return "", valueError("Unknown timespec value")
+}
+
+// DateTime is a Date and a TimeOfDay together
+type DateTime struct {
+    Date
+    TimeOfDay
+}
+
+func (dt *DateTime) Type() ObjectType { return DATETIME_OBJ }
+func (dt *DateTime) Inspect() string {
+    return fmt.Sprintf("datetime.datetime(%d, %d, %d, %s)", dt.year, dt.month, dt.day, dt.TimeOfDay.fields())
+}
+
+// wall is the datetime in microseconds since the start of 0001-01-00,
+// ignoring its zone
+func (dt *DateTime) wall() int64 {
+    return (int64(dt.ordinal())*86400+int64(dt.seconds()))*1e6 + int64(dt.micro)
+}
+
+// unix is wall as seconds since the epoch, as if the wall clock were UTC
+func (dt *DateTime) unix() int64 {
+    return (int64(dt.ordinal()-epochOrdinal)*86400 + int64(dt.seconds()))
+}
+
+// dateTimeOfWall is the other way round from wall
+func dateTimeOfWall(micros int64, tzinfo Object, fold int) (*DateTime, *Error) {
+    days, rest := micros/86400e6, micros%86400e6
+    if rest < 0 {
+        days, rest = days-1, rest+86400e6
+    }
+    if days < 1 || days > maxOrdinal {
+        return nil, overflowError("date value out of range")
+    }
+    year, month, day := fromOrdinal(int(days))
+    seconds := int(rest / 1e6)
+    return &DateTime{
+        Date:      Date{year: year, month: month, day: day},
+        TimeOfDay: TimeOfDay{hour: seconds / 3600, minute: seconds / 60 % 60, second: seconds % 60, micro: int(rest % 1e6), tzinfo: tzinfo, fold: fold},
+    }, nil
+}
+
+// TimeZone is datetime.timezone: a fixed offset, and maybe a name
+type TimeZone struct {
+    offset *TimeDelta
+    name   Object // None when the name comes from the offset
+}
+
+func (z *TimeZone) Type() ObjectType { return TIMEZONE_OBJ }
+func (z *TimeZone) Inspect() string {
+    if z == utcZone {
+        return "datetime.timezone.utc"
+    }
+    if z.name == NULL {
+        return "datetime.timezone(" + z.offset.Inspect() + ")"
+    }
+    return "datetime.timezone(" + z.offset.Inspect() + ", " + z.name.Inspect() + ")"
+}
+
+// tzname is the name given, or UTC+HH:MM made from the offset
+func (z *TimeZone) tzname() string {
+    if s, ok := z.name.(*String); ok {
+        return s.Value
+    }
+    if z.offset.isZero() {
+        return "UTC"
+    }
+    s, _ := formatOffset(nil, wallClock{offset: z.offset}, ":")
+    return "UTC" + s
+}
+
+var utcZone = &TimeZone{offset: &TimeDelta{}, name: NULL}
+
+// ZoneInfo is a zone out of the tz database, one per key
+type ZoneInfo struct {
+    key string
+    loc *time.Location
+}
+
+func (z *ZoneInfo) Type() ObjectType { return ZONEINFO_OBJ }
+func (z *ZoneInfo) Inspect() string  { return "zoneinfo.ZoneInfo(key=" + strRepr(z.key) + ")" }
+
+// The calendar, straight out of CPython's ordinal arithmetic
+
+var daysBeforeMonthTable = [...]int{0, 0, 31, 59, 90, 120, 151, 181, 212, 243, 273, 304, 334, 365}
+
+func isLeap(year int) bool { return year%4 == 0 && (year%100 != 0 || year%400 == 0) }
+
+func daysInMonth(year, month int) int {
+    if month == 2 && isLeap(year) {
+        return 29
+    }
+    return daysBeforeMonthTable[month+1] - daysBeforeMonthTable[month]
+}
+
+// daysBeforeMonth counts the days in the year before month starts; month 13
+// is the whole year
+func daysBeforeMonth(year, month int) int {
+    if month > 2 && isLeap(year) {
+        return daysBeforeMonthTable[month] + 1
+    }
+    return daysBeforeMonthTable[month]
+}
+
+// toOrdinal numbers days from 0001-01-01, which is day 1
+func toOrdinal(year, month, day int) int {
+    y := year - 1
+    return y*365 + y/4 - y/100 + y/400 + daysBeforeMonth(year, month) + day
+}
+
+func fromOrdinal(n int) (year, month, day int) {
+    t := time.Date(1, 1, n, 0, 0, 0, 0, time.UTC)
+    return t.Year(), int(t.Month()), t.Day()
+}
+
+// isoWeek1Monday is the ordinal of the Monday that starts ISO week 1
+func isoWeek1Monday(year int) int {
+    first := toOrdinal(year, 1, 1)
+    weekday := (first + 6) % 7
+    monday := first - weekday
+    if weekday > 3 {
+        monday += 7
+    }
+    return monday
+}
+
+func isoCalendar(d *Date) (year, week, weekday int) {
+    year = d.year
+    ordinal := d.ordinal()
+    monday := isoWeek1Monday(year)
+    if ordinal < monday {
+        year--
+        monday = isoWeek1Monday(year)
+    } else if next := isoWeek1Monday(year + 1); ordinal >= next {
+        year++
+        monday = next
+    }
+    return year, (ordinal-monday)/7 + 1, (ordinal-monday)%7 + 1
+}
+
+// isoWeekToDate is date.fromisocalendar's arithmetic
+func isoWeekToDate(year, week, day int) (*Date, *Error) {
+    if year < minYear || year > maxYear {
+        return nil, valueError("Year is out of range: %d", year)
+    }
+    if week <= 0 || week >= 53 {
+        first := (toOrdinal(year, 1, 1) + 6) % 7
+        if week != 53 || !(first == 3 || first == 2 && isLeap(year)) {
+            return nil, valueError("Invalid week: %d", week)
+        }
+    }
+    if day <= 0 || day >= 8 {
+        return nil, valueError("Invalid weekday: %d (range is [1, 7])", day)
+    }
+    return dateOfOrdinal(isoWeek1Monday(year) + (week-1)*7 + day - 1)
+}
+
+func dateOfOrdinal(n int) (*Date, *Error) {
+    if n < 1 || n > maxOrdinal {
+        return nil, overflowError("date value out of range")
+    }
+    year, month, day := fromOrdinal(n)
+    return &Date{year: year, month: month, day: day}, nil
+}
+
+// clockFor is the broken-down time strftime() and timetuple() want
+func clockFor(d *Date, t *TimeOfDay) wallClock {
+    ordinal := d.ordinal()
+    return wallClock{
+        year: d.year, month: d.month, day: d.day,
+        hour: t.hour, minute: t.minute, second: t.second, microsecond: t.micro,
+        weekday: (ordinal + 6) % 7, yearday: ordinal - toOrdinal(d.year, 1, 1) + 1,
+        isdst: -1, zone: NULL, offset: NULL,
+    }
+}
+
+// Unwrapping, for subclasses as much as for the real thing
+
+func asTimeDelta(obj Object) (*TimeDelta, bool) {
+    d, ok := payload(obj).(*TimeDelta)
+    return d, ok
+}
+
+// asDate accepts a datetime too; it is a date, after all
+func asDate(obj Object) (*Date, bool) {
+    switch d := payload(obj).(type) {
+    case *Date:
+        return d, true
+    case *DateTime:
+        return &d.Date, true
+    }
+    return nil, false
+}
+
+func asTimeOfDay(obj Object) (*TimeOfDay, bool) {
+    t, ok := payload(obj).(*TimeOfDay)
+    return t, ok
+}
+
+func asDateTime(obj Object) (*DateTime, bool) {
+    dt, ok := payload(obj).(*DateTime)
+    return dt, ok
+}
+
+// likeSelf gives value the class self has, so a subclass's arithmetic
+// stays in the subclass
+func likeSelf(self, value Object) Object {
+    if instance, ok := self.(*Instance); ok {
+        return &Instance{Class: instance.Class, Dict: NewDict(), Value: value}
+    }
+    return value
+}
+
+// typeLabel is how reprs and comparison errors name the class: ours say
+// which module they're from, subclasses just give their name
+func typeLabel(obj Object) string {
+    if _, ok := obj.(*Instance); ok {
+        return typeName(obj)
+    }
+    return "datetime." + typeName(obj)
+}
+
+// intArguments reads the ints a constructor or replace() was given; the
+// ones left out keep their defaults
+func intArguments(env *Environment, values []Object, defaults []int) ([]int, *Error) {
+    ints := make([]int, len(defaults))
+    for i, value := range defaults {
+        ints[i] = value
+        if values[i] != nil {
+            n, err := toIndex(env, values[i])
+            if err != nil {
+                return nil, err
+            }
+            ints[i] = n
+        }
+    }
+    return ints, nil
+}
+
+func checkDate(year, month, day int) *Error {
+    switch {
+    case year < minYear || year > maxYear:
+        return valueError("year %d is out of range", year)
+    case month < 1 || month > 12:
+        return valueError("month must be in 1..12")
+    case day < 1 || day > daysInMonth(year, month):
+        return valueError("day is out of range for month")
+    }
+    return nil
+}
+
+func checkTime(hour, minute, second, micro, fold int) *Error {
+    switch {
+    case hour < 0 || hour > 23:
+        return valueError("hour must be in 0..23")
+    case minute < 0 || minute > 59:
+        return valueError("minute must be in 0..59")
+    case second < 0 || second > 59:
+        return valueError("second must be in 0..59")
+    case micro < 0 || micro > 999999:
+        return valueError("microsecond must be in 0..999999")
+    case fold != 0 && fold != 1:
+        return valueError("fold must be either 0 or 1")
+    }
+    return nil
+}
+
+func checkTzinfo(tzinfo Object) *Error {
+    if tzinfo != NULL && !typeOf(tzinfo).isSubclass(tzinfoType) {
+        return typeError("tzinfo argument must be None or of a tzinfo subclass, not type '%s'", typeName(tzinfo))
+    }
+    return nil
+}
+
+// tzOffset asks tzinfo for utcoffset(arg) or dst(arg), holding the answer
+// to what CPython allows: None, or a timedelta of less than a day either way
+func tzOffset(env *Environment, tzinfo Object, name string, arg Object) (*TimeDelta, *Error) {
+    if tzinfo == NULL {
+        return nil, nil
+    }
+    result := callAttribute(env, tzinfo, name, arg)
+    if err, ok := result.(*Error); ok {
+        return nil, err
+    }
+    if result == NULL {
+        return nil, nil
+    }
+    offset, ok := asTimeDelta(result)
+    if !ok {
+        return nil, typeError("tzinfo.%s() must return None or timedelta, not '%s'", name, typeName(result))
+    }
+    if !withinADay(offset) {
+        return nil, valueError("offset must be a timedelta strictly between -timedelta(hours=24) and timedelta(hours=24).")
+    }
+    return offset, nil
+}
+
+func withinADay(d *TimeDelta) bool {
+    return d.days == 0 || d.days == -1 && (d.seconds != 0 || d.micros != 0)
+}
+
+// tzName is tzinfo.tzname(arg), which has to be None or a string
+func tzName(env *Environment, tzinfo Object, arg Object) (Object, *Error) {
+    if tzinfo == NULL {
+        return NULL, nil
+    }
+    result := callAttribute(env, tzinfo, "tzname", arg)
+    if err, ok := result.(*Error); ok {
+        return nil, err
+    }
+    if _, ok := payload(result).(*String); !ok && result != NULL {
+        return nil, typeError("tzinfo.tzname() must return None or a string, not '%s'", typeName(result))
+    }
+    return result, nil
+}
+
+// offsetOrNone is a timedelta that may not be there, for Python
+func offsetOrNone(offset *TimeDelta, err *Error) Object {
+    if err != nil {
+        return err
+    }
+    if offset == nil {
+        return NULL
+    }
+    return offset
+}
+
+// formatWith is strftime() for dates, times and datetimes: %z and %Z ask
+// the tzinfo, and only when the format wants them
+func formatWith(env *Environment, format Object, c wallClock, tzinfo, arg Object) Object {
+    s, ok := payload(format).(*String)
+    if !ok {
+        return typeError("strftime() argument 1 must be str, not %s", typeName(format))
+    }
+    if tzinfo != NULL && (strings.Contains(s.Value, "z") || strings.Contains(s.Value, "Z")) {
+        offset, err := tzOffset(env, tzinfo, "utcoffset", arg)
+        if err != nil {
+            return err
+        }
+        if offset != nil {
+            c.offset = offset
+        }
+        if c.zone, err = tzName(env, tzinfo, arg); err != nil {
+            return err
+        }
+    }
+    text, err := strftime(env, s.Value, c, true)
+    if err != nil {
+        return err
+    }
+    return &String{Value: text}
+}
+
+// isoOffset is the +HH:MM[:SS[.ffffff]] isoformat() puts on the end
+func isoOffset(offset *TimeDelta) string {
+    if offset == nil {
+        return ""
+    }
+    s, _ := formatOffset(nil, wallClock{offset: offset}, ":")
+    return s
+}
+
+// exactNumber is an int or a float as a fraction, for timedelta's arithmetic
+func exactNumber(obj Object) (*big.Rat, bool, *Error) {
+    if n, ok := toBigInt(obj); ok {
+        return new(big.Rat).SetInt(n), true, nil
+    }
+    f, ok := payload(obj).(*Float)
+    if !ok {
+        return nil, false, nil
+    }
+    switch {
+    case math.IsNaN(f.Value):
+        return nil, true, valueError("cannot convert NaN to integer ratio")
+    case math.IsInf(f.Value, 0):
+        return nil, true, overflowError("cannot convert Infinity to integer ratio")
+    }
+    return new(big.Rat).SetFloat64(f.Value), true, nil
+}
+
+func initTimedelta() {
+    names := []string{"days", "seconds", "microseconds", "milliseconds", "minutes", "hours", "weeks"}
+    scales := []int64{86400e6, 1e6, 1, 1000, 60e6, 3600e6, 604800e6}
+    timedeltaType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        cls, err := newClassArg("timedelta", args)
+        if err != nil {
+            return err
+        }
+        values, err := parseArgs("timedelta", args[1:], kwargs, names, 0)
+        if err != nil {
+            return err
+        }
+        // CPython adds the components up smallest first, the whole parts
+        // exactly and the fractions as floats, and rounds what is left over
+        // once at the end; the float arithmetic shows, so it's done its way
+        total, leftover := new(big.Int), 0.0
+        for _, i := range []int{2, 3, 1, 4, 5, 0, 6} {
+            value := values[i]
+            if value == nil {
+                continue
+            }
+            scale := big.NewInt(scales[i])
+            if n, ok := toBigInt(value); ok {
+                total.Add(total, new(big.Int).Mul(n, scale))
+                continue
+            }
+            f, ok := payload(value).(*Float)
+            if !ok {
+                return typeError("unsupported type for timedelta %s component: %s", names[i], typeName(value))
+            }
+            switch {
+            case math.IsNaN(f.Value):
+                return valueError("cannot convert float NaN to integer")
+            case math.IsInf(f.Value, 0):
+                return overflowError("cannot convert float infinity to integer")
+            }
+            whole, fraction := math.Modf(f.Value)
+            n, _ := new(big.Float).SetFloat64(whole).Int(nil)
+            total.Add(total, n.Mul(n, scale))
+            whole, fraction = math.Modf(float64(scales[i]) * fraction)
+            total.Add(total, big.NewInt(int64(whole)))
+            leftover += fraction
+        }
+        if leftover != 0 {
+            rounded := math.Round(leftover)
+            if math.Abs(rounded-leftover) == 0.5 {
+                odd := float64(total.Bit(0))
+                rounded = 2*math.Round((leftover+odd)*0.5) - odd
+            }
+            total.Add(total, big.NewInt(int64(rounded)))
+        }
+        d, err := timeDeltaFromBig(total)
+        if err != nil {
+            return err
+        }
+        return wrapBuiltinValue(cls, timedeltaType, d)
+    })
+
+    timedeltaType.property("days", func(self Object) Object { return newInt(payload(self).(*TimeDelta).days) })
+    timedeltaType.property("seconds", func(self Object) Object { return newInt(payload(self).(*TimeDelta).seconds) })
+    timedeltaType.property("microseconds", func(self Object) Object { return newInt(payload(self).(*TimeDelta).micros) })
+    timedeltaType.method("total_seconds", 0, 0, func(env *Environment, args []Object) Object {
+        seconds, _ := new(big.Rat).SetFrac(payload(args[0]).(*TimeDelta).bigTotal(), big.NewInt(1e6)).Float64()
+        return &Float{Value: seconds}
+    })
+    timedeltaType.method("__repr__", 0, 0, func(env *Environment, args []Object) Object {
+        return &String{Value: typeLabel(args[0]) + "(" + payload(args[0]).(*TimeDelta).fields() + ")"}
+    })
+    timedeltaType.method("__str__", 0, 0, func(env *Environment, args []Object) Object {
+        return &String{Value: payload(args[0]).(*TimeDelta).str()}
+    })
+    timedeltaType.method("__bool__", 0, 0, func(env *Environment, args []Object) Object {
+        return nativeBool(!payload(args[0]).(*TimeDelta).isZero())
+    })
+    timedeltaType.method("__hash__", 0, 0, func(env *Environment, args []Object) Object {
+        return newInt(hashBigInt(payload(args[0]).(*TimeDelta).bigTotal()))
+    })
+    for _, operator := range []string{"==", "!=", "<", "<=", ">", ">="} {
+        operator := operator
+        timedeltaType.method(comparisonOperators[operator][0], 1, 1, func(env *Environment, args []Object) Object {
+            other, ok := asTimeDelta(args[1])
+            if !ok {
+                return NotImplemented
+            }
+            return compareResult(operator, payload(args[0]).(*TimeDelta).cmp(other))
+        })
+    }
+
+    // Everything below works on the total in microseconds; the results are
+    // plain timedeltas, whatever class the operands were
+    fromBig := func(n *big.Int) Object {
+        d, err := timeDeltaFromBig(n)
+        if err != nil {
+            return err
+        }
+        return d
+    }
+    unary := func(name string, fn func(n *big.Int) *big.Int) {
+        timedeltaType.method(name, 0, 0, func(env *Environment, args []Object) Object {
+            return fromBig(fn(payload(args[0]).(*TimeDelta).bigTotal()))
+        })
+    }
+    unary("__neg__", func(n *big.Int) *big.Int { return n.Neg(n) })
+    unary("__pos__", func(n *big.Int) *big.Int { return n })
+    unary("__abs__", func(n *big.Int) *big.Int { return n.Abs(n) })
+
+    binary := func(name string, fn func(a, b *big.Int) *big.Int) {
+        timedeltaType.method(name, 1, 1, func(env *Environment, args []Object) Object {
+            other, ok := asTimeDelta(args[1])
+            if !ok {
+                return NotImplemented
+            }
+            return fromBig(fn(payload(args[0]).(*TimeDelta).bigTotal(), other.bigTotal()))
+        })
+    }
+    binary("__add__", func(a, b *big.Int) *big.Int { return a.Add(a, b) })
+    binary("__radd__", func(a, b *big.Int) *big.Int { return a.Add(a, b) })
+    binary("__sub__", func(a, b *big.Int) *big.Int { return a.Sub(a, b) })
+    binary("__rsub__", func(a, b *big.Int) *big.Int { return a.Sub(b, a) })
+
+    multiply := func(env *Environment, args []Object) Object {
+        factor, ok, err := exactNumber(args[1])
+        if err != nil {
+            return err
+        }
+        if !ok {
+            return NotImplemented
+        }
+        product := new(big.Rat).SetInt(payload(args[0]).(*TimeDelta).bigTotal())
+        return fromBig(roundHalfEven(product.Mul(product, factor)))
+    }
+    timedeltaType.method("__mul__", 1, 1, multiply)
+    timedeltaType.method("__rmul__", 1, 1, multiply)
+
+    timedeltaType.method("__truediv__", 1, 1, func(env *Environment, args []Object) Object {
+        total := payload(args[0]).(*TimeDelta).bigTotal()
+        if other, ok := asTimeDelta(args[1]); ok {
+            if other.isZero() {
+                return zeroDivisionError("integer division or modulo by zero")
+            }
+            f, _ := new(big.Rat).SetFrac(total, other.bigTotal()).Float64()
+            return &Float{Value: f}
+        }
+        divisor, ok, err := exactNumber(args[1])
+        if err != nil {
+            return err
+        }
+        if !ok {
+            return NotImplemented
+        }
+        if divisor.Sign() == 0 {
+            return zeroDivisionError("integer division or modulo by zero")
+        }
+        quotient := new(big.Rat).SetInt(total)
+        return fromBig(roundHalfEven(quotient.Quo(quotient, divisor)))
+    })
+    timedeltaType.method("__floordiv__", 1, 1, func(env *Environment, args []Object) Object {
+        total := payload(args[0]).(*TimeDelta).bigTotal()
+        if other, ok := asTimeDelta(args[1]); ok {
+            if other.isZero() {
+                return zeroDivisionError("integer division or modulo by zero")
+            }
+            q, _ := floorDivMod(total, other.bigTotal())
+            return newBigInt(q)
+        }
+        n, ok := toBigInt(args[1])
+        if !ok {
+            return NotImplemented
+        }
+        if n.Sign() == 0 {
+            return zeroDivisionError("integer division or modulo by zero")
+        }
+        q, _ := floorDivMod(total, n)
+        return fromBig(q)
+    })
+    timedeltaType.method("__mod__", 1, 1, func(env *Environment, args []Object) Object {
+        other, ok := asTimeDelta(args[1])
+        if !ok {
+            return NotImplemented
+        }
+        if other.isZero() {
+            return zeroDivisionError("integer modulo by zero")
+        }
+        _, r := floorDivMod(payload(args[0]).(*TimeDelta).bigTotal(), other.bigTotal())
+        return fromBig(r)
+    })
+    timedeltaType.method("__divmod__", 1, 1, func(env *Environment, args []Object) Object {
+        other, ok := asTimeDelta(args[1])
+        if !ok {
+            return NotImplemented
+        }
+        if other.isZero() {
+            return zeroDivisionError("integer division or modulo by zero")
+        }
+        q, r := floorDivMod(payload(args[0]).(*TimeDelta).bigTotal(), other.bigTotal())
+        return &Tuple{Elements: []Object{newBigInt(q), fromBig(r)}}
+    })
+
+    timedeltaType.Dict.SetStr("min", &TimeDelta{days: -maxDeltaDays})
+    timedeltaType.Dict.SetStr("max", &TimeDelta{days: maxDeltaDays, seconds: 86399, micros: 999999})
+    timedeltaType.Dict.SetStr("resolution", &TimeDelta{micros: 1})
+}
+
+// dateOfClass makes d an instance of cls. The classmethods date lends to
+// datetime land here too, and a datetime made from a date starts at midnight.
+func dateOfClass(cls *Class, d *Date) Object {
+    if cls.isSubclass(datetimeType) {
+        return wrapBuiltinValue(cls, datetimeType, &DateTime{Date: *d, TimeOfDay: TimeOfDay{tzinfo: NULL}})
+    }
+    return wrapBuiltinValue(cls, dateType, d)
+}
+
+// localNow is the clock on the wall, to the microsecond
+func localNow() *DateTime {
+    dt, _ := dateTimeOfTime(time.Now().Local(), NULL)
+    return dt
+}
+
+// dateTimeOfTime takes the wall clock fields of a Go time
+func dateTimeOfTime(t time.Time, tzinfo Object) (*DateTime, *Error) {
+    if t.Year() < minYear || t.Year() > maxYear {
+        return nil, overflowError("date value out of range")
+    }
+    return &DateTime{
+        Date:      Date{year: t.Year(), month: int(t.Month()), day: t.Day()},
+        TimeOfDay: TimeOfDay{hour: t.Hour(), minute: t.Minute(), second: t.Second(), micro: t.Nanosecond() / 1000, tzinfo: tzinfo},
+    }, nil
+}
+
+// timestampMicros reads a POSIX timestamp to the nearest microsecond, ties
+// going to even
+func timestampMicros(obj Object) (int64, *Error) {
+    if n, ok := toBigInt(obj); ok {
+        if !n.IsInt64() || n.Int64() > math.MaxInt64/1000000 || n.Int64() < math.MinInt64/1000000 {
+            return 0, overflowError("timestamp out of range for platform time_t")
+        }
+        return n.Int64() * 1e6, nil
+    }
+    f, ok := payload(obj).(*Float)
+    if !ok {
+        return 0, typeError("'%s' object cannot be interpreted as an integer", typeName(obj))
+    }
+    if math.IsNaN(f.Value) {
+        return 0, valueError("Invalid value NaN (not a number)")
+    }
+    if math.Abs(f.Value) > 1e15 {
+        return 0, overflowError("timestamp out of range for platform time_t")
+    }
+    micros := new(big.Rat).SetFloat64(f.Value)
+    return roundHalfEven(micros.Mul(micros, big.NewRat(1e6, 1))).Int64(), nil
+}
+
+func initDate() {
+    dateType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        cls, err := newClassArg("date", args)
+        if err != nil {
+            return err
+        }
+        values, err := parseArgs("date", args[1:], kwargs, []string{"year", "month", "day"}, 3)
+        if err != nil {
+            return err
+        }
+        ints, err := intArguments(env, values, []int{0, 0, 0})
+        if err != nil {
+            return err
+        }
+        if err := checkDate(ints[0], ints[1], ints[2]); err != nil {
+            return err
+        }
+        return wrapBuiltinValue(cls, dateType, &Date{year: ints[0], month: ints[1], day: ints[2]})
+    })
+
+    dateType.classMethod("today", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("today", args[1:], kwargs, 0, 0); err != nil {
+            return err
+        }
+        return dateOfClass(args[0].(*Class), &localNow().Date)
+    })
+    dateType.classMethod("fromtimestamp", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("fromtimestamp", args[1:], kwargs, 1, 1); err != nil {
+            return err
+        }
+        micros, err := timestampMicros(args[1])
+        if err != nil {
+            return err
+        }
+        dt, err := dateTimeOfTime(time.UnixMicro(micros).Local(), NULL)
+        if err != nil {
+            return err
+        }
+        return dateOfClass(args[0].(*Class), &dt.Date)
+    })
+    dateType.classMethod("fromordinal", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("fromordinal", args[1:], kwargs, 1, 1); err != nil {
+            return err
+        }
+        n, err := toIndex(env, args[1])
+        if err != nil {
+            return err
+        }
+        switch {
+        case n < 1:
+            return valueError("ordinal must be >= 1")
+        case n > maxOrdinal:
+            return valueError("year %d is out of range", maxYear+1)
+        }
+        d, _ := dateOfOrdinal(n)
+        return dateOfClass(args[0].(*Class), d)
+    })
+    dateType.classMethod("fromisoformat", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("fromisoformat", args[1:], kwargs, 1, 1); err != nil {
+            return err
+        }
+        s, ok := payload(args[1]).(*String)
+        if !ok {
+            return typeError("fromisoformat: argument must be str")
+        }
+        n := len(s.Value)
+        if n != 7 && n != 8 && n != 10 {
+            return valueError("Invalid isoformat string: %s", strRepr(s.Value))
+        }
+        year, month, day, ok := parseISODate(s.Value)
+        if !ok {
+            return valueError("Invalid isoformat string: %s", strRepr(s.Value))
+        }
+        if err := checkDate(year, month, day); err != nil {
+            return err
+        }
+        return dateOfClass(args[0].(*Class), &Date{year: year, month: month, day: day})
+    })
+    dateType.classMethod("fromisocalendar", func(env *Environment, args []Object, kwargs *Dict) Object {
+        values, err := parseArgs("fromisocalendar", args[1:], kwargs, []string{"year", "week", "day"}, 3)
+        if err != nil {
+            return err
+        }
+        ints, err := intArguments(env, values, []int{0, 0, 0})
+        if err != nil {
+            return err
+        }
+        d, err := isoWeekToDate(ints[0], ints[1], ints[2])
+        if err != nil {
+            return err
+        }
+        return dateOfClass(args[0].(*Class), d)
+    })
+
+    dateType.property("year", func(self Object) Object { d, _ := asDate(self); return newInt(int64(d.year)) })
+    dateType.property("month", func(self Object) Object { d, _ := asDate(self); return newInt(int64(d.month)) })
+    dateType.property("day", func(self Object) Object { d, _ := asDate(self); return newInt(int64(d.day)) })
+
+    dateType.define("replace", func(env *Environment, args []Object, kwargs *Dict) Object {
+        d, _ := asDate(args[0])
+        values, err := parseArgs("replace", args[1:], kwargs, []string{"year", "month", "day"}, 0)
+        if err != nil {
+            return err
+        }
+        ints, err := intArguments(env, values, []int{d.year, d.month, d.day})
+        if err != nil {
+            return err
+        }
+        if err := checkDate(ints[0], ints[1], ints[2]); err != nil {
+            return err
+        }
+        return likeSelf(args[0], &Date{year: ints[0], month: ints[1], day: ints[2]})
+    })
+    dateType.method("toordinal", 0, 0, func(env *Environment, args []Object) Object {
+        d, _ := asDate(args[0])
+        return newInt(int64(d.ordinal()))
+    })
+    dateType.method("weekday", 0, 0, func(env *Environment, args []Object) Object {
+        d, _ := asDate(args[0])
+        return newInt(int64((d.ordinal() + 6) % 7))
+    })
+    dateType.method("isoweekday", 0, 0, func(env *Environment, args []Object) Object {
+        d, _ := asDate(args[0])
+        return newInt(int64((d.ordinal()+6)%7 + 1))
+    })
+    dateType.method("isocalendar", 0, 0, func(env *Environment, args []Object) Object {
+        d, _ := asDate(args[0])
+        year, week, weekday := isoCalendar(d)
+        elements := []Object{newInt(int64(year)), newInt(int64(week)), newInt(int64(weekday))}
+        return &Instance{Class: isoCalendarDateType, Dict: NewDict(), Value: &Tuple{Elements: elements}}
+    })
+    dateType.method("timetuple", 0, 0, func(env *Environment, args []Object) Object {
+        d, _ := asDate(args[0])
+        return newStructTime(clockFor(d, &TimeOfDay{}))
+    })
+    dateType.method("isoformat", 0, 0, func(env *Environment, args []Object) Object {
+        d, _ := asDate(args[0])
+        return &String{Value: d.isoformat()}
+    })
+    dateType.method("__str__", 0, 0, func(env *Environment, args []Object) Object {
+        return callAttribute(env, args[0], "isoformat")
+    })
+    dateType.method("__repr__", 0, 0, func(env *Environment, args []Object) Object {
+        d, _ := asDate(args[0])
+        return &String{Value: fmt.Sprintf("%s(%d, %d, %d)", typeLabel(args[0]), d.year, d.month, d.day)}
+    })
+    dateType.method("ctime", 0, 0, func(env *Environment, args []Object) Object {
+        d, _ := asDate(args[0])
+        return &String{Value: asctime(clockFor(d, &TimeOfDay{}))}
+    })
+    dateType.method("strftime", 1, 1, func(env *Environment, args []Object) Object {
+        d, _ := asDate(args[0])
+        return formatWith(env, args[1], clockFor(d, &TimeOfDay{}), NULL, NULL)
+    })
+    defineFormat(dateType)
+
+    dateType.method("__hash__", 0, 0, func(env *Environment, args []Object) Object {
+        d, _ := asDate(args[0])
+        return newInt(int64(d.ordinal()))
+    })
+    for _, operator := range []string{"==", "!=", "<", "<=", ">", ">="} {
+        operator := operator
+        dateType.method(comparisonOperators[operator][0], 1, 1, func(env *Environment, args []Object) Object {
+            other, ok := payload(args[1]).(*Date)
+            if !ok {
+                return NotImplemented // a datetime has its own opinion on this
+            }
+            d, _ := asDate(args[0])
+            return compareResult(operator, d.ordinal()-other.ordinal())
+        })
+    }
+
+    add := func(env *Environment, args []Object) Object {
+        delta, ok := asTimeDelta(args[1])
+        if !ok {
+            return NotImplemented
+        }
+        d, _ := asDate(args[0])
+        return shiftDate(args[0], d, delta.days)
+    }
+    dateType.method("__add__", 1, 1, add)
+    dateType.method("__radd__", 1, 1, add)
+    dateType.method("__sub__", 1, 1, func(env *Environment, args []Object) Object {
+        d, _ := asDate(args[0])
+        switch other := payload(args[1]).(type) {
+        case *TimeDelta:
+            return shiftDate(args[0], d, -other.days)
+        case *Date:
+            return timeDeltaOf(int64(d.ordinal()-other.ordinal()) * 86400e6)
+        }
+        return NotImplemented
+    })
+
+    dateType.Dict.SetStr("min", &Date{year: minYear, month: 1, day: 1})
+    dateType.Dict.SetStr("max", &Date{year: maxYear, month: 12, day: 31})
+    dateType.Dict.SetStr("resolution", &TimeDelta{days: 1})
+}
+
+// shiftDate is date + timedelta, which only looks at the days
+func shiftDate(self Object, d *Date, days int64) Object {
+    ordinal := int64(d.ordinal()) + days
+    if ordinal < 1 || ordinal > maxOrdinal {
+        return overflowError("date value out of range")
+    }
+    shifted, _ := dateOfOrdinal(int(ordinal))
+    return likeSelf(self, shifted)
+}
+
+// defineFormat gives cls the __format__ of date, time and datetime: an
+// empty spec is str(), anything else goes to strftime()
+func defineFormat(cls *Class) {
+    cls.method("__format__", 1, 1, func(env *Environment, args []Object) Object {
+        spec, ok := payload(args[1]).(*String)
+        if !ok {
+            return typeError("__format__() argument 1 must be str, not %s", typeName(args[1]))
+        }
+        if spec.Value == "" {
+            return strOf(env, args[0])
+        }
+        return callAttribute(env, args[0], "strftime", args[1])
+    })
+}
+
+// timeOfDayArguments reads the hour..fold parameters time() and datetime()
+// share, starting at values[0]
+func timeOfDayArguments(env *Environment, values []Object, defaults *TimeOfDay) (*TimeOfDay, *Error) {
+    ints, err := intArguments(env, []Object{values[0], values[1], values[2], values[3], values[5]},
+        []int{defaults.hour, defaults.minute, defaults.second, defaults.micro, defaults.fold})
+    if err != nil {
+        return nil, err
+    }
+    if err := checkTime(ints[0], ints[1], ints[2], ints[3], ints[4]); err != nil {
+        return nil, err
+    }
+    tzinfo := defaults.tzinfo
+    if values[4] != nil {
+        tzinfo = values[4]
+    }
+    if err := checkTzinfo(tzinfo); err != nil {
+        return nil, err
+    }
+    return &TimeOfDay{hour: ints[0], minute: ints[1], second: ints[2], micro: ints[3], tzinfo: tzinfo, fold: ints[4]}, nil
+}
+
+var timeOfDayParams = []string{"hour", "minute", "second", "microsecond", "tzinfo", "*fold"}
+
+// reprTail is the ", tzinfo=..., fold=1" a time repr ends with; a
+// datetime says fold first
+func reprTail(env *Environment, t *TimeOfDay, foldFirst bool) (string, *Error) {
+    tz, fold := "", ""
+    if t.tzinfo != NULL {
+        s, err := reprString(env, t.tzinfo)
+        if err != nil {
+            return "", err
+        }
+        tz = ", tzinfo=" + s
+    }
+    if t.fold != 0 {
+        fold = ", fold=1"
+    }
+    if foldFirst {
+        return fold + tz, nil
+    }
+    return tz + fold, nil
+}
+
+// compareAware is how times and datetimes compare: by the clock when they
+// share a tzinfo or neither has an offset, in UTC when both do, and not at
+// all - except for inequality - when only one does
+func compareAware(operator string, what string, a, b int64, offsetA, offsetB *TimeDelta) Object {
+    switch {
+    case offsetA == nil && offsetB == nil:
+    case offsetA == nil || offsetB == nil:
+        switch operator {
+        case "==":
+            return FALSE
+        case "!=":
+            return TRUE
+        }
+        return typeError("can't compare offset-naive and offset-aware %s", what)
+    default:
+        a, b = a-offsetA.total(), b-offsetB.total()
+    }
+    switch {
+    case a < b:
+        return compareResult(operator, -1)
+    case a > b:
+        return compareResult(operator, 1)
+    }
+    return compareResult(operator, 0)
+}
+
+func initTimeOfDay() {
+    timeOfDayType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        cls, err := newClassArg("time", args)
+        if err != nil {
+            return err
+        }
+        values, err := parseArgs("time", args[1:], kwargs, timeOfDayParams, 0)
+        if err != nil {
+            return err
+        }
+        t, err := timeOfDayArguments(env, values, &TimeOfDay{tzinfo: NULL})
+        if err != nil {
+            return err
+        }
+        return wrapBuiltinValue(cls, timeOfDayType, t)
+    })
+    timeOfDayType.classMethod("fromisoformat", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("fromisoformat", args[1:], kwargs, 1, 1); err != nil {
+            return err
+        }
+        s, ok := payload(args[1]).(*String)
+        if !ok {
+            return typeError("fromisoformat: argument must be str")
+        }
+        fields, tzinfo, ok := parseISOTime(strings.TrimPrefix(s.Value, "T"))
+        if !ok {
+            return valueError("Invalid isoformat string: %s", strRepr(s.Value))
+        }
+        if err := checkTime(fields[0], fields[1], fields[2], fields[3], 0); err != nil {
+            return err
+        }
+        t := &TimeOfDay{hour: fields[0], minute: fields[1], second: fields[2], micro: fields[3], tzinfo: tzinfo}
+        return wrapBuiltinValue(args[0].(*Class), timeOfDayType, t)
+    })
+
+    timeOfDayFields(timeOfDayType, func(self Object) *TimeOfDay { t, _ := asTimeOfDay(self); return t })
+
+    timeOfDayType.define("replace", func(env *Environment, args []Object, kwargs *Dict) Object {
+        values, err := parseArgs("replace", args[1:], kwargs, timeOfDayParams, 0)
+        if err != nil {
+            return err
+        }
+        self, _ := asTimeOfDay(args[0])
+        t, err := timeOfDayArguments(env, values, self)
+        if err != nil {
+            return err
+        }
+        return likeSelf(args[0], t)
+    })
+    timeOfDayType.define("isoformat", func(env *Environment, args []Object, kwargs *Dict) Object {
+        values, err := parseArgs("isoformat", args[1:], kwargs, []string{"timespec"}, 0)
+        if err != nil {
+            return err
+        }
+        timespec, err := timespecArgument(values[0])
+        if err != nil {
+            return err
+        }
+        t, _ := asTimeOfDay(args[0])
+        s, err := t.isoformat(timespec)
+        if err != nil {
+            return err
+        }
+        offset, err := tzOffset(env, t.tzinfo, "utcoffset", NULL)
+        if err != nil {
+            return err
+        }
+        return &String{Value: s + isoOffset(offset)}
+    })
+    timeOfDayType.method("__str__", 0, 0, func(env *Environment, args []Object) Object {
+        return callAttribute(env, args[0], "isoformat")
+    })
+    timeOfDayType.method("__repr__", 0, 0, func(env *Environment, args []Object) Object {
+        t, _ := asTimeOfDay(args[0])
+        tail, err := reprTail(env, t, false)
+        if err != nil {
+            return err
+        }
+        return &String{Value: typeLabel(args[0]) + "(" + t.fields() + tail + ")"}
+    })
+    timeOfDayType.method("strftime", 1, 1, func(env *Environment, args []Object) Object {
+        t, _ := asTimeOfDay(args[0])
+        return formatWith(env, args[1], clockFor(&Date{year: 1900, month: 1, day: 1}, t), t.tzinfo, NULL)
+    })
+    defineFormat(timeOfDayType)
+
+    timeOfDayType.method("utcoffset", 0, 0, func(env *Environment, args []Object) Object {
+        t, _ := asTimeOfDay(args[0])
+        return offsetOrNone(tzOffset(env, t.tzinfo, "utcoffset", NULL))
+    })
+    timeOfDayType.method("dst", 0, 0, func(env *Environment, args []Object) Object {
+        t, _ := asTimeOfDay(args[0])
+        return offsetOrNone(tzOffset(env, t.tzinfo, "dst", NULL))
+    })
+    timeOfDayType.method("tzname", 0, 0, func(env *Environment, args []Object) Object {
+        t, _ := asTimeOfDay(args[0])
+        name, err := tzName(env, t.tzinfo, NULL)
+        if err != nil {
+            return err
+        }
+        return name
+    })
+
+    // utcMicros is the time in microseconds, and the offset to take off it;
+    // a shared tzinfo needs no asking
+    utcMicros := func(env *Environment, t, other *TimeOfDay) (int64, *TimeDelta, *Error) {
+        micros := int64(t.seconds())*1e6 + int64(t.micro)
+        if t.tzinfo == other.tzinfo {
+            return micros, nil, nil
+        }
+        offset, err := tzOffset(env, t.tzinfo, "utcoffset", NULL)
+        return micros, offset, err
+    }
+    timeOfDayType.method("__hash__", 0, 0, func(env *Environment, args []Object) Object {
+        t, _ := asTimeOfDay(args[0])
+        micros, offset, err := utcMicros(env, t, &TimeOfDay{})
+        if err != nil {
+            return err
+        }
+        if offset != nil {
+            micros -= offset.total()
+        }
+        return newInt(hashBigInt(big.NewInt(micros)))
+    })
+    for _, operator := range []string{"==", "!=", "<", "<=", ">", ">="} {
+        operator := operator
+        timeOfDayType.method(comparisonOperators[operator][0], 1, 1, func(env *Environment, args []Object) Object {
+            other, ok := asTimeOfDay(args[1])
+            if !ok {
+                return NotImplemented
+            }
+            t, _ := asTimeOfDay(args[0])
+            a, offsetA, err := utcMicros(env, t, other)
+            if err != nil {
+                return err
+            }
+            b, offsetB, err := utcMicros(env, other, t)
+            if err != nil {
+                return err
+            }
+            return compareAware(operator, "times", a, b, offsetA, offsetB)
+        })
+    }
+
+    timeOfDayType.Dict.SetStr("min", &TimeOfDay{tzinfo: NULL})
+    timeOfDayType.Dict.SetStr("max", &TimeOfDay{hour: 23, minute: 59, second: 59, micro: 999999, tzinfo: NULL})
+    timeOfDayType.Dict.SetStr("resolution", &TimeDelta{micros: 1})
+}
+
+// timeOfDayFields gives cls the hour..fold attributes, wherever of self
+// the TimeOfDay lives
+func timeOfDayFields(cls *Class, of func(self Object) *TimeOfDay) {
+    cls.property("hour", func(self Object) Object { return newInt(int64(of(self).hour)) })
+    cls.property("minute", func(self Object) Object { return newInt(int64(of(self).minute)) })
+    cls.property("second", func(self Object) Object { return newInt(int64(of(self).second)) })
+    cls.property("microsecond", func(self Object) Object { return newInt(int64(of(self).micro)) })
+    cls.property("tzinfo", func(self Object) Object { return of(self).tzinfo })
+    cls.property("fold", func(self Object) Object { return newInt(int64(of(self).fold)) })
+}
+
+func timespecArgument(obj Object) (string, *Error) {
+    if obj == nil {
+        return "auto", nil
+    }
+    s, ok := payload(obj).(*String)
+    if !ok {
+        return "", typeError("isoformat() argument 'timespec' must be str, not %s", typeName(obj))
+    }
+    return s.Value, nil
+}
+
+var dateTimeParams = append([]string{"year", "month", "day"}, timeOfDayParams...)
+
+// dateTimeOfClass makes dt an instance of cls
+func dateTimeOfClass(cls *Class, dt *DateTime) Object {
+    return wrapBuiltinValue(cls, datetimeType, dt)
+}
+
+// localOffset is how far the local zone is ahead of UTC at dt's wall time,
+// in seconds, for naive datetimes that have to become timestamps
+func localOffset(dt *DateTime) int64 {
+    return int64(zoneOffset(time.Local, dt.unix(), dt.fold).offset)
+}
+
+// epochMicros is dt as microseconds since the epoch, taking the local
+// zone's word for it when dt is naive
+func epochMicros(env *Environment, self Object, dt *DateTime) (int64, *Error) {
+    micros := dt.wall() - epochOrdinal*86400e6
+    offset, err := tzOffset(env, dt.tzinfo, "utcoffset", self)
+    switch {
+    case err != nil:
+        return 0, err
+    case offset == nil:
+        return micros - localOffset(dt)*1e6, nil
+    }
+    return micros - offset.total(), nil
+}
+
+// shiftDateTime is dt moved along by micros, still in its zone
+func shiftDateTime(self Object, dt *DateTime, micros int64) Object {
+    shifted, err := dateTimeOfWall(dt.wall()+micros, dt.tzinfo, 0)
+    if err != nil {
+        return err
+    }
+    return likeSelf(self, shifted)
+}
+
+// fromUTC turns the UTC time in micros since the epoch into tz's, via its fromutc()
+func fromUTC(env *Environment, cls *Class, micros int64, tz Object) Object {
+    if tz == NULL {
+        dt, err := dateTimeOfTime(time.UnixMicro(micros).Local(), NULL)
+        if err != nil {
+            return err
+        }
+        return dateTimeOfClass(cls, dt)
+    }
+    dt, err := dateTimeOfWall(micros+epochOrdinal*86400e6, tz, 0)
+    if err != nil {
+        return err
+    }
+    return callAttribute(env, tz, "fromutc", dateTimeOfClass(cls, dt))
+}
+
+func initDatetime() {
+    datetimeType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        cls, err := newClassArg("datetime", args)
+        if err != nil {
+            return err
+        }
+        values, err := parseArgs("datetime", args[1:], kwargs, dateTimeParams, 3)
+        if err != nil {
+            return err
+        }
+        ints, err := intArguments(env, values, []int{0, 0, 0})
+        if err != nil {
+            return err
+        }
+        if err := checkDate(ints[0], ints[1], ints[2]); err != nil {
+            return err
+        }
+        t, err := timeOfDayArguments(env, values[3:], &TimeOfDay{tzinfo: NULL})
+        if err != nil {
+            return err
+        }
+        return dateTimeOfClass(cls, &DateTime{Date: Date{year: ints[0], month: ints[1], day: ints[2]}, TimeOfDay: *t})
+    })
+
+    datetimeType.classMethod("today", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("today", args[1:], kwargs, 0, 0); err != nil {
+            return err
+        }
+        return dateTimeOfClass(args[0].(*Class), localNow())
+    })
+    datetimeType.classMethod("now", func(env *Environment, args []Object, kwargs *Dict) Object {
+        values, err := parseArgs("now", args[1:], kwargs, []string{"tz"}, 0)
+        if err != nil {
+            return err
+        }
+        tz := nilToNone(values[0])
+        if err := checkTzinfo(tz); err != nil {
+            return err
+        }
+        return fromUTC(env, args[0].(*Class), time.Now().UnixMicro(), tz)
+    })
+    datetimeType.classMethod("utcnow", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("utcnow", args[1:], kwargs, 0, 0); err != nil {
+            return err
+        }
+        dt, _ := dateTimeOfTime(time.Now().UTC(), NULL)
+        return dateTimeOfClass(args[0].(*Class), dt)
+    })
+    datetimeType.classMethod("fromtimestamp", func(env *Environment, args []Object, kwargs *Dict) Object {
+        values, err := parseArgs("fromtimestamp", args[1:], kwargs, []string{"timestamp", "tz"}, 1)
+        if err != nil {
+            return err
+        }
+        tz := nilToNone(values[1])
+        if err := checkTzinfo(tz); err != nil {
+            return err
+        }
+        micros, err := timestampMicros(values[0])
+        if err != nil {
+            return err
+        }
+        return fromUTC(env, args[0].(*Class), micros, tz)
+    })
+    datetimeType.classMethod("utcfromtimestamp", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("utcfromtimestamp", args[1:], kwargs, 1, 1); err != nil {
+            return err
+        }
+        micros, err := timestampMicros(args[1])
+        if err != nil {
+            return err
+        }
+        dt, err := dateTimeOfWall(micros+epochOrdinal*86400e6, NULL, 0)
+        if err != nil {
+            return err
+        }
+        return dateTimeOfClass(args[0].(*Class), dt)
+    })
+    datetimeType.classMethod("combine", func(env *Environment, args []Object, kwargs *Dict) Object {
+        values, err := parseArgs("combine", args[1:], kwargs, []string{"date", "time", "tzinfo"}, 2)
+        if err != nil {
+            return err
+        }
+        d, ok := asDate(values[0])
+        if !ok {
+            return typeError("combine() argument 1 must be datetime.date, not %s", typeName(values[0]))
+        }
+        t, ok := asTimeOfDay(values[1])
+        if !ok {
+            return typeError("combine() argument 2 must be datetime.time, not %s", typeName(values[1]))
+        }
+        dt := &DateTime{Date: *d, TimeOfDay: *t}
+        if values[2] != nil {
+            if err := checkTzinfo(values[2]); err != nil {
+                return err
+            }
+            dt.tzinfo = values[2]
+        }
+        return dateTimeOfClass(args[0].(*Class), dt)
+    })
+    datetimeType.classMethod("fromisoformat", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("fromisoformat", args[1:], kwargs, 1, 1); err != nil {
+            return err
+        }
+        s, ok := payload(args[1]).(*String)
+        if !ok {
+            return typeError("fromisoformat: argument must be str")
+        }
+        dt, ok := parseISODateTime(s.Value)
+        if !ok {
+            return valueError("Invalid isoformat string: %s", strRepr(s.Value))
+        }
+        if err := checkDate(dt.year, dt.month, dt.day); err != nil {
+            return err
+        }
+        if err := checkTime(dt.hour, dt.minute, dt.second, dt.micro, 0); err != nil {
+            return err
+        }
+        return dateTimeOfClass(args[0].(*Class), dt)
+    })
+    datetimeType.classMethod("strptime", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("strptime", args[1:], kwargs, 2, 2); err != nil {
+            return err
+        }
+        strs := make([]string, 2)
+        for i, arg := range args[1:] {
+            s, ok := payload(arg).(*String)
+            if !ok {
+                return typeError("strptime() argument %d must be str, not %s", i+1, typeName(arg))
+            }
+            strs[i] = s.Value
+        }
+        c, err := strptime(strs[0], strs[1])
+        if err != nil {
+            return err
+        }
+        if err := checkDate(c.year, c.month, c.day); err != nil {
+            return err
+        }
+        dt := &DateTime{
+            Date:      Date{year: c.year, month: c.month, day: c.day},
+            TimeOfDay: TimeOfDay{hour: c.hour, minute: c.minute, second: c.second, micro: c.microsecond, tzinfo: NULL},
+        }
+        if offset, ok := c.offset.(*TimeDelta); ok {
+            dt.tzinfo = newTimeZone(offset, c.zone)
+        }
+        return dateTimeOfClass(args[0].(*Class), dt)
+    })
+
+    timeOfDayFields(datetimeType, func(self Object) *TimeOfDay { dt, _ := asDateTime(self); return &dt.TimeOfDay })
+
+    datetimeType.method("date", 0, 0, func(env *Environment, args []Object) Object {
+        dt, _ := asDateTime(args[0])
+        d := dt.Date
+        return &d
+    })
+    datetimeType.method("time", 0, 0, func(env *Environment, args []Object) Object {
+        dt, _ := asDateTime(args[0])
+        t := dt.TimeOfDay
+        t.tzinfo = NULL
+        return &t
+    })
+    datetimeType.method("timetz", 0, 0, func(env *Environment, args []Object) Object {
+        dt, _ := asDateTime(args[0])
+        t := dt.TimeOfDay
+        return &t
+    })
+    datetimeType.define("replace", func(env *Environment, args []Object, kwargs *Dict) Object {
+        values, err := parseArgs("replace", args[1:], kwargs, dateTimeParams, 0)
+        if err != nil {
+            return err
+        }
+        self, _ := asDateTime(args[0])
+        ints, err := intArguments(env, values, []int{self.year, self.month, self.day})
+        if err != nil {
+            return err
+        }
+        if err := checkDate(ints[0], ints[1], ints[2]); err != nil {
+            return err
+        }
+        t, err := timeOfDayArguments(env, values[3:], &self.TimeOfDay)
+        if err != nil {
+            return err
+        }
+        return likeSelf(args[0], &DateTime{Date: Date{year: ints[0], month: ints[1], day: ints[2]}, TimeOfDay: *t})
+    })
+    datetimeType.define("astimezone", func(env *Environment, args []Object, kwargs *Dict) Object {
+        values, err := parseArgs("astimezone", args[1:], kwargs, []string{"tz"}, 0)
+        if err != nil {
+            return err
+        }
+        tz := nilToNone(values[0])
+        if tz != NULL && !typeOf(tz).isSubclass(tzinfoType) {
+            return typeError("astimezone() argument 1 must be datetime.tzinfo, not %s", typeName(tz))
+        }
+        dt, _ := asDateTime(args[0])
+        if tz != NULL && tz == dt.tzinfo {
+            return args[0]
+        }
+        micros, err := epochMicros(env, args[0], dt)
+        if err != nil {
+            return err
+        }
+        if tz == NULL {
+            t := time.UnixMicro(micros).Local()
+            name, offset := t.Zone()
+            local, err := dateTimeOfTime(t, newTimeZone(timeDeltaOf(int64(offset)*1e6), &String{Value: name}))
+            if err != nil {
+                return err
+            }
+            return likeSelf(args[0], local)
+        }
+        utc, err := dateTimeOfWall(micros+epochOrdinal*86400e6, tz, 0)
+        if err != nil {
+            return err
+        }
+        return callAttribute(env, tz, "fromutc", likeSelf(args[0], utc))
+    })
+    datetimeType.method("utcoffset", 0, 0, func(env *Environment, args []Object) Object {
+        dt, _ := asDateTime(args[0])
+        return offsetOrNone(tzOffset(env, dt.tzinfo, "utcoffset", args[0]))
+    })
+    datetimeType.method("dst", 0, 0, func(env *Environment, args []Object) Object {
+        dt, _ := asDateTime(args[0])
+        return offsetOrNone(tzOffset(env, dt.tzinfo, "dst", args[0]))
+    })
+    datetimeType.method("tzname", 0, 0, func(env *Environment, args []Object) Object {
+        dt, _ := asDateTime(args[0])
+        name, err := tzName(env, dt.tzinfo, args[0])
+        if err != nil {
+            return err
+        }
+        return name
+    })
+    datetimeType.method("timetuple", 0, 0, func(env *Environment, args []Object) Object {
+        dt, _ := asDateTime(args[0])
+        c := clockFor(&dt.Date, &dt.TimeOfDay)
+        dst, err := tzOffset(env, dt.tzinfo, "dst", args[0])
+        switch {
+        case err != nil:
+            return err
+        case dst == nil:
+            c.isdst = -1
+        case dst.isZero():
+            c.isdst = 0
+        default:
+            c.isdst = 1
+        }
+        return newStructTime(c)
+    })
+    datetimeType.method("utctimetuple", 0, 0, func(env *Environment, args []Object) Object {
+        dt, _ := asDateTime(args[0])
+        offset, err := tzOffset(env, dt.tzinfo, "utcoffset", args[0])
+        if err != nil {
+            return err
+        }
+        if offset != nil {
+            if dt, err = dateTimeOfWall(dt.wall()-offset.total(), NULL, 0); err != nil {
+                return err
+            }
+        }
+        c := clockFor(&dt.Date, &dt.TimeOfDay)
+        c.isdst = 0
+        return newStructTime(c)
+    })
+    datetimeType.method("timestamp", 0, 0, func(env *Environment, args []Object) Object {
+        dt, _ := asDateTime(args[0])
+        micros, err := epochMicros(env, args[0], dt)
+        if err != nil {
+            return err
+        }
+        seconds, _ := big.NewRat(micros, 1e6).Float64()
+        return &Float{Value: seconds}
+    })
+    datetimeType.define("isoformat", func(env *Environment, args []Object, kwargs *Dict) Object {
+        values, err := parseArgs("isoformat", args[1:], kwargs, []string{"sep", "timespec"}, 0)
+        if err != nil {
+            return err
+        }
+        sep := "T"
+        if values[0] != nil {
+            s, ok := payload(values[0]).(*String)
+            if !ok || len([]rune(s.Value)) != 1 {
+                return typeError("isoformat() argument 1 must be a unicode character, not %s", typeName(values[0]))
+            }
+            sep = s.Value
+        }
+        timespec, err := timespecArgument(values[1])
+        if err != nil {
+            return err
+        }
+        dt, _ := asDateTime(args[0])
+        t, err := dt.TimeOfDay.isoformat(timespec)
+        if err != nil {
+            return err
+        }
+        offset, err := tzOffset(env, dt.tzinfo, "utcoffset", args[0])
+        if err != nil {
+            return err
+        }
+        return &String{Value: dt.Date.isoformat() + sep + t + isoOffset(offset)}
+    })
+    datetimeType.method("__str__", 0, 0, func(env *Environment, args []Object) Object {
+        return callAttribute(env, args[0], "isoformat", &String{Value: " "})
+    })
+    datetimeType.method("__repr__", 0, 0, func(env *Environment, args []Object) Object {
+        dt, _ := asDateTime(args[0])
+        tail, err := reprTail(env, &dt.TimeOfDay, true)
+        if err != nil {
+            return err
+        }
+        return &String{Value: fmt.Sprintf("%s(%d, %d, %d, %s%s)", typeLabel(args[0]), dt.year, dt.month, dt.day, dt.TimeOfDay.fields(), tail)}
+    })
+    datetimeType.method("ctime", 0, 0, func(env *Environment, args []Object) Object {
+        dt, _ := asDateTime(args[0])
+        return &String{Value: asctime(clockFor(&dt.Date, &dt.TimeOfDay))}
+    })
+    datetimeType.method("strftime", 1, 1, func(env *Environment, args []Object) Object {
+        dt, _ := asDateTime(args[0])
+        return formatWith(env, args[1], clockFor(&dt.Date, &dt.TimeOfDay), dt.tzinfo, args[0])
+    })
+
+    // utcWall is the wall time and the offset to take off it; a shared
+    // tzinfo needs no asking
+    utcWall := func(env *Environment, self Object, dt *DateTime, other *DateTime) (int64, *TimeDelta, *Error) {
+        if other != nil && dt.tzinfo == other.tzinfo {
+            return dt.wall(), nil, nil
+        }
+        offset, err := tzOffset(env, dt.tzinfo, "utcoffset", self)
+        return dt.wall(), offset, err
+    }
+    datetimeType.method("__hash__", 0, 0, func(env *Environment, args []Object) Object {
+        dt, _ := asDateTime(args[0])
+        micros, offset, err := utcWall(env, args[0], dt, nil)
+        if err != nil {
+            return err
+        }
+        if offset != nil {
+            micros -= offset.total()
+        }
+        return newInt(hashBigInt(big.NewInt(micros)))
+    })
+    for _, operator := range []string{"==", "!=", "<", "<=", ">", ">="} {
+        operator := operator
+        datetimeType.method(comparisonOperators[operator][0], 1, 1, func(env *Environment, args []Object) Object {
+            other, ok := asDateTime(args[1])
+            if !ok {
+                if _, ok := asDate(args[1]); !ok {
+                    return NotImplemented
+                }
+                // A date is no datetime, however it was subclassed
+                switch operator {
+                case "==":
+                    return FALSE
+                case "!=":
+                    return TRUE
+                }
+                return typeError("can't compare %s to %s", typeLabel(args[0]), typeLabel(args[1]))
+            }
+            dt, _ := asDateTime(args[0])
+            a, offsetA, err := utcWall(env, args[0], dt, other)
+            if err != nil {
+                return err
+            }
+            b, offsetB, err := utcWall(env, args[1], other, dt)
+            if err != nil {
+                return err
+            }
+            return compareAware(operator, "datetimes", a, b, offsetA, offsetB)
+        })
+    }
+
+    add := func(env *Environment, args []Object) Object {
+        delta, ok := asTimeDelta(args[1])
+        if !ok {
+            return NotImplemented
+        }
+        if delta.days > maxOrdinal || delta.days < -maxOrdinal {
+            return overflowError("date value out of range")
+        }
+        dt, _ := asDateTime(args[0])
+        return shiftDateTime(args[0], dt, delta.total())
+    }
+    datetimeType.method("__add__", 1, 1, add)
+    datetimeType.method("__radd__", 1, 1, add)
+    datetimeType.method("__sub__", 1, 1, func(env *Environment, args []Object) Object {
+        dt, _ := asDateTime(args[0])
+        if delta, ok := asTimeDelta(args[1]); ok {
+            if delta.days > maxOrdinal || delta.days < -maxOrdinal {
+                return overflowError("date value out of range")
+            }
+            return shiftDateTime(args[0], dt, -delta.total())
+        }
+        other, ok := asDateTime(args[1])
+        if !ok {
+            return NotImplemented
+        }
+        a, offsetA, err := utcWall(env, args[0], dt, other)
+        if err != nil {
+            return err
+        }
+        b, offsetB, err := utcWall(env, args[1], other, dt)
+        if err != nil {
+            return err
+        }
+        if (offsetA == nil) != (offsetB == nil) {
+            return typeError("can't subtract offset-naive and offset-aware datetimes")
+        }
+        if offsetA != nil {
+            a, b = a-offsetA.total(), b-offsetB.total()
+        }
+        return timeDeltaOf(a - b)
+    })
+
+    datetimeType.Dict.SetStr("min", &DateTime{Date: Date{year: minYear, month: 1, day: 1}, TimeOfDay: TimeOfDay{tzinfo: NULL}})
+    datetimeType.Dict.SetStr("max", &DateTime{Date: Date{year: maxYear, month: 12, day: 31},
+        TimeOfDay: TimeOfDay{hour: 23, minute: 59, second: 59, micro: 999999, tzinfo: NULL}})
+    datetimeType.Dict.SetStr("resolution", &TimeDelta{micros: 1})
+}
+
+func initTzinfo() {
+    for _, name := range []string{"utcoffset", "dst", "tzname"} {
+        name := name
+        tzinfoType.method(name, 1, 1, func(env *Environment, args []Object) Object {
+            return newErrorKind(notImplementedErrorType, "a tzinfo subclass must implement %s()", name)
+        })
+    }
+    tzinfoType.method("fromutc", 1, 1, func(env *Environment, args []Object) Object {
+        dt, err := fromUTCArgument(args[0], args[1])
+        if err != nil {
+            return err
+        }
+        offset, err := tzOffset(env, args[0], "utcoffset", args[1])
+        if err != nil {
+            return err
+        }
+        if offset == nil {
+            return valueError("fromutc: non-None utcoffset() result required")
+        }
+        dst, err := tzOffset(env, args[0], "dst", args[1])
+        if err != nil {
+            return err
+        }
+        if dst == nil {
+            return valueError("fromutc: non-None dst() result required")
+        }
+        // Standard time first, then whatever daylight saving says about that
+        result := args[1]
+        if delta := offset.total() - dst.total(); delta != 0 {
+            if result = shiftDateTime(args[1], dt, delta); isError(result) {
+                return result
+            }
+            dt, _ = asDateTime(result)
+            if dst, err = tzOffset(env, args[0], "dst", result); err != nil {
+                return err
+            }
+            if dst == nil {
+                return valueError("fromutc: tz.dst() gave inconsistent results; cannot convert")
+            }
+        }
+        return shiftDateTime(result, dt, dst.total())
+    })
+}
+
+// fromUTCArgument is the datetime a fromutc() was handed, which has to be
+// in the zone doing the converting
+func fromUTCArgument(tz, arg Object) (*DateTime, *Error) {
+    dt, ok := asDateTime(arg)
+    if !ok {
+        return nil, typeError("fromutc: argument must be a datetime")
+    }
+    if dt.tzinfo != tz {
+        return nil, valueError("fromutc: dt.tzinfo is not self")
+    }
+    return dt, nil
+}
+
+// newTimeZone is timezone(offset, name); the nameless zero is timezone.utc
+func newTimeZone(offset *TimeDelta, name Object) *TimeZone {
+    if name == NULL && offset.isZero() {
+        return utcZone
+    }
+    return &TimeZone{offset: offset, name: name}
+}
+
+func initTimezone() {
+    timezoneType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        cls, err := newClassArg("timezone", args)
+        if err != nil {
+            return err
+        }
+        values, err := parseArgs("timezone", args[1:], kwargs, []string{"offset", "name"}, 1)
+        if err != nil {
+            return err
+        }
+        offset, ok := asTimeDelta(values[0])
+        if !ok {
+            return typeError("timezone() argument 1 must be datetime.timedelta, not %s", typeName(values[0]))
+        }
+        name := nilToNone(values[1])
+        if _, ok := payload(name).(*String); !ok && values[1] != nil {
+            return typeError("timezone() argument 2 must be str, not %s", typeName(values[1]))
+        }
+        if !withinADay(offset) {
+            return valueError("offset must be a timedelta strictly between -timedelta(hours=24) and timedelta(hours=24), not %s.", offset.Inspect())
+        }
+        return wrapBuiltinValue(cls, timezoneType, newTimeZone(offset, name))
+    })
+
+    // Every question takes a datetime or None, and the answer never depends on it
+    asked := func(name string, answer func(z *TimeZone) Object) {
+        timezoneType.method(name, 1, 1, func(env *Environment, args []Object) Object {
+            if _, ok := asDateTime(args[1]); !ok && args[1] != NULL {
+                return typeError("%s(dt) argument must be a datetime instance or None, not %s", name, typeName(args[1]))
+            }
+            return answer(payload(args[0]).(*TimeZone))
+        })
+    }
+    asked("utcoffset", func(z *TimeZone) Object { return z.offset })
+    asked("dst", func(z *TimeZone) Object { return NULL })
+    asked("tzname", func(z *TimeZone) Object { return &String{Value: z.tzname()} })
+    timezoneType.method("fromutc", 1, 1, func(env *Environment, args []Object) Object {
+        if _, ok := asDateTime(args[1]); !ok {
+            return typeError("fromutc: argument must be a datetime")
+        }
+        dt, err := fromUTCArgument(args[0], args[1])
+        if err != nil {
+            return err
+        }
+        return shiftDateTime(args[1], dt, payload(args[0]).(*TimeZone).offset.total())
+    })
+    timezoneType.method("__repr__", 0, 0, func(env *Environment, args []Object) Object {
+        z := payload(args[0]).(*TimeZone)
+        if z == utcZone {
+            return &String{Value: "datetime.timezone.utc"}
+        }
+        s := typeLabel(args[0]) + "(" + z.offset.Inspect()
+        if z.name != NULL {
+            s += ", " + strRepr(z.name.(*String).Value)
+        }
+        return &String{Value: s + ")"}
+    })
+    timezoneType.method("__str__", 0, 0, func(env *Environment, args []Object) Object {
+        return &String{Value: payload(args[0]).(*TimeZone).tzname()}
+    })
+    timezoneType.method("__eq__", 1, 1, func(env *Environment, args []Object) Object {
+        other, ok := payload(args[1]).(*TimeZone)
+        if !ok {
+            return NotImplemented
+        }
+        return nativeBool(payload(args[0]).(*TimeZone).offset.cmp(other.offset) == 0)
+    })
+    timezoneType.method("__hash__", 0, 0, func(env *Environment, args []Object) Object {
+        return newInt(hashBigInt(payload(args[0]).(*TimeZone).offset.bigTotal()))
+    })
+
+    timezoneType.Dict.SetStr("utc", utcZone)
+    timezoneType.Dict.SetStr("min", &TimeZone{offset: timeDeltaOf(-(23*3600 + 59*60) * 1e6), name: NULL})
+    timezoneType.Dict.SetStr("max", &TimeZone{offset: timeDeltaOf((23*3600 + 59*60) * 1e6), name: NULL})
+}
+
+func initIsoCalendarDate() {
+    fields := []string{"year", "week", "weekday"}
+    for i, name := range fields {
+        i := i
+        isoCalendarDateType.property(name, func(self Object) Object {
+            return payload(self).(*Tuple).Elements[i]
+        })
+    }
+    isoCalendarDateType.method("__repr__", 0, 0, func(env *Environment, args []Object) Object {
+        elements := payload(args[0]).(*Tuple).Elements
+        parts := make([]string, len(fields))
+        for i, name := range fields {
+            parts[i] = name + "=" + elements[i].Inspect()
+        }
+        return &String{Value: "datetime.IsoCalendarDate(" + strings.Join(parts, ", ") + ")"}
+    })
+}
+
+// zoneAt is what a zone says about one instant
+type zoneAt struct {
+    offset int // seconds east of UTC
+    name   string
+    isDST  bool
+}
+
+func zoneAtInstant(loc *time.Location, unix int64) zoneAt {
+    t := time.Unix(unix, 0).In(loc)
+    name, offset := t.Zone()
+    return zoneAt{offset: offset, name: name, isDST: t.IsDST()}
+}
+
+// zoneOffset resolves a wall clock time in loc, given as seconds since the
+// epoch as if it were UTC. Around a transition the wall time either happens
+// twice or not at all, and fold picks a side the way PEP 495 does: 0 for the
+// offset from before the transition, 1 for the one after.
+func zoneOffset(loc *time.Location, wall int64, fold int) zoneAt {
+    before, after := zoneAtInstant(loc, wall-86400), zoneAtInstant(loc, wall+86400)
+    if before.offset == after.offset {
+        return zoneAtInstant(loc, wall-int64(before.offset))
+    }
+    fits := func(z zoneAt) bool { return zoneAtInstant(loc, wall-int64(z.offset)).offset == z.offset }
+    switch fitsBefore, fitsAfter := fits(before), fits(after); {
+    case fitsBefore && !fitsAfter:
+        return zoneAtInstant(loc, wall-int64(before.offset))
+    case fitsAfter && !fitsBefore:
+        return zoneAtInstant(loc, wall-int64(after.offset))
+    case fold == 1:
+        return after
+    }
+    return before
+}
+
+// standardOffset is the offset loc keeps outside daylight saving time,
+// from the nearest month around unix that isn't in it
+func standardOffset(loc *time.Location, unix int64) int {
+    for months := int64(0); months <= 12; months++ {
+        for _, sign := range []int64{-1, 1} {
+            if z := zoneAtInstant(loc, unix+sign*months*30*86400); !z.isDST {
+                return z.offset
+            }
+        }
+    }
+    return zoneAtInstant(loc, unix).offset
+}
+
+// zoneInfoCache makes ZoneInfo(key) is ZoneInfo(key). It is shared by every
+// interpreter in the process, like CPython's is, so it takes a lock.
+var zoneInfoCache = struct {
+    sync.Mutex
+    zones map[string]*ZoneInfo
+}{zones: map[string]*ZoneInfo{}}
+
+// loadZoneInfo reads key out of the tz database
+func loadZoneInfo(key string) (*ZoneInfo, *Error) {
+    notFound := newErrorKind(zoneNotFoundType, "No time zone found with key %s", key)
+    if key == "" || key == "Local" || strings.HasPrefix(key, "/") || strings.Contains(key, "..") {
+        return nil, notFound
+    }
+    loc, err := time.LoadLocation(key)
+    if err != nil {
+        return nil, notFound
+    }
+    return &ZoneInfo{key: key, loc: loc}, nil
+}
+
+func keyArgument(obj Object) (string, *Error) {
+    s, ok := payload(obj).(*String)
+    if !ok {
+        return "", typeError("expected str, bytes or os.PathLike object, not %s", typeName(obj))
+    }
+    return s.Value, nil
+}
+
+func initZoneInfo() {
+    zoneInfoType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        cls, err := newClassArg("ZoneInfo", args)
+        if err != nil {
+            return err
+        }
+        values, err := parseArgs("ZoneInfo", args[1:], kwargs, []string{"key"}, 1)
+        if err != nil {
+            return err
+        }
+        key, err := keyArgument(values[0])
+        if err != nil {
+            return err
+        }
+        zoneInfoCache.Lock()
+        z, ok := zoneInfoCache.zones[key]
+        zoneInfoCache.Unlock()
+        if !ok {
+            if z, err = loadZoneInfo(key); err != nil {
+                return err
+            }
+            zoneInfoCache.Lock()
+            if cached, ok := zoneInfoCache.zones[key]; ok {
+                z = cached // someone else got there first; theirs is the one
+            } else {
+                zoneInfoCache.zones[key] = z
+            }
+            zoneInfoCache.Unlock()
+        }
+        return wrapBuiltinValue(cls, zoneInfoType, z)
+    })
+    zoneInfoType.classMethod("no_cache", func(env *Environment, args []Object, kwargs *Dict) Object {
+        values, err := parseArgs("no_cache", args[1:], kwargs, []string{"key"}, 1)
+        if err != nil {
+            return err
+        }
+        key, err := keyArgument(values[0])
+        if err != nil {
+            return err
+        }
+        z, err := loadZoneInfo(key)
+        if err != nil {
+            return err
+        }
+        return wrapBuiltinValue(args[0].(*Class), zoneInfoType, z)
+    })
+    zoneInfoType.classMethod("clear_cache", func(env *Environment, args []Object, kwargs *Dict) Object {
+        values, err := parseArgs("clear_cache", args[1:], kwargs, []string{"*only_keys"}, 0)
+        if err != nil {
+            return err
+        }
+        if values[0] == nil || values[0] == NULL {
+            zoneInfoCache.Lock()
+            zoneInfoCache.zones = map[string]*ZoneInfo{}
+            zoneInfoCache.Unlock()
+            return NULL
+        }
+        err = iterate(env, values[0], func(item Object) *Error {
+            key, err := keyArgument(item)
+            if err == nil {
+                zoneInfoCache.Lock()
+                delete(zoneInfoCache.zones, key)
+                zoneInfoCache.Unlock()
+            }
+            return err
+        })
+        if err != nil {
+            return err
+        }
+        return NULL
+    })
+    zoneInfoType.property("key", func(self Object) Object {
+        return &String{Value: payload(self).(*ZoneInfo).key}
+    })
+    zoneInfoType.method("__repr__", 0, 0, func(env *Environment, args []Object) Object {
+        label := "zoneinfo.ZoneInfo"
+        if _, ok := args[0].(*Instance); ok {
+            label = typeName(args[0])
+        }
+        return &String{Value: label + "(key=" + strRepr(payload(args[0]).(*ZoneInfo).key) + ")"}
+    })
+    zoneInfoType.method("__str__", 0, 0, func(env *Environment, args []Object) Object {
+        return &String{Value: payload(args[0]).(*ZoneInfo).key}
+    })
+
+    // Every question is about the wall time of a datetime; None has no answer
+    asked := func(name string, answer func(z *ZoneInfo, at zoneAt, wall int64) Object) {
+        zoneInfoType.method(name, 1, 1, func(env *Environment, args []Object) Object {
+            if args[1] == NULL {
+                return NULL
+            }
+            dt, ok := asDateTime(args[1])
+            if !ok {
+                return typeError("%s() argument must be a datetime instance or None, not %s", name, typeName(args[1]))
+            }
+            z := payload(args[0]).(*ZoneInfo)
+            return answer(z, zoneOffset(z.loc, dt.unix(), dt.fold), dt.unix())
+        })
+    }
+    asked("utcoffset", func(z *ZoneInfo, at zoneAt, wall int64) Object {
+        return timeDeltaOf(int64(at.offset) * 1e6)
+    })
+    asked("dst", func(z *ZoneInfo, at zoneAt, wall int64) Object {
+        if !at.isDST {
+            return &TimeDelta{}
+        }
+        return timeDeltaOf(int64(at.offset-standardOffset(z.loc, wall-int64(at.offset))) * 1e6)
+    })
+    asked("tzname", func(z *ZoneInfo, at zoneAt, wall int64) Object {
+        return &String{Value: at.name}
+    })
+    zoneInfoType.method("fromutc", 1, 1, func(env *Environment, args []Object) Object {
+        dt, err := fromUTCArgument(args[0], args[1])
+        if err != nil {
+            return err
+        }
+        loc := payload(args[0]).(*ZoneInfo).loc
+        offset := zoneAtInstant(loc, dt.unix()).offset
+        result := shiftDateTime(args[1], dt, int64(offset)*1e6)
+        if local, ok := asDateTime(result); ok && zoneOffset(loc, local.unix(), 0).offset != offset {
+            local.fold = 1 // the second time round this wall time
+        }
+        return result
+    })
+}
+
+// ISO 8601 parsing, after CPython's fromisoformat: everything here reports
+// failure with ok, and the caller says whose string it was
+
+func isoNumber(s string, pos, n int) (int, bool) {
+    if n == 0 || pos+n > len(s) {
+        return 0, false
+    }
+    value := 0
+    for _, c := range []byte(s[pos : pos+n]) {
+        if c < '0' || c > '9' {
+            return 0, false
+        }
+        value = value*10 + int(c-'0')
+    }
+    return value, true
+}
+
+// isoSeparator finds where the date ends in a datetime string of seven or
+// more characters. Week dates make it a guess, the same guess CPython makes.
+func isoSeparator(s string) int {
+    n := len(s)
+    switch {
+    case n == 7:
+        return 7
+    case s[4] == '-' && s[5] == 'W':
+        if n > 8 && s[8] == '-' {
+            if n == 9 {
+                return -1
+            }
+            if n > 10 && isDigit(s[10]) {
+                return 8
+            }
+            return 10
+        }
+        return 8
+    case s[4] == '-':
+        return 10
+    case s[4] == 'W':
+        i := 7
+        for i < n && isDigit(s[i]) {
+            i++
+        }
+        if i < 9 {
+            return i
+        }
+        if i%2 == 0 {
+            return 7
+        }
+        return 8
+    }
+    return 8
+}
+
+// parseISODate reads YYYY-MM-DD, YYYYMMDD, YYYY-Www[-D] or YYYYWww[D]
+func parseISODate(s string) (year, month, day int, ok bool) {
+    if year, ok = isoNumber(s, 0, 4); !ok || len(s) < 7 {
+        return 0, 0, 0, false
+    }
+    hasSep := s[4] == '-'
+    pos := 4
+    if hasSep {
+        pos++
+    }
+    separated := func() bool {
+        if (pos < len(s) && s[pos] == '-') != hasSep {
+            return false
+        }
+        if hasSep {
+            pos++
+        }
+        return true
+    }
+    if s[pos] == 'W' {
+        week, ok := isoNumber(s, pos+1, 2)
+        if !ok {
+            return 0, 0, 0, false
+        }
+        pos += 3
+        weekday := 1
+        if pos < len(s) {
+            if !separated() {
+                return 0, 0, 0, false
+            }
+            if weekday, ok = isoNumber(s, pos, 1); !ok {
+                return 0, 0, 0, false
+            }
+            pos++
+        }
+        d, err := isoWeekToDate(year, week, weekday)
+        if err != nil || pos != len(s) {
+            return 0, 0, 0, false
+        }
+        return d.year, d.month, d.day, true
+    }
+    if month, ok = isoNumber(s, pos, 2); !ok {
+        return 0, 0, 0, false
+    }
+    pos += 2
+    if !separated() {
+        return 0, 0, 0, false
+    }
+    if day, ok = isoNumber(s, pos, 2); !ok || pos+2 != len(s) {
+        return 0, 0, 0, false
+    }
+    return year, month, day, true
+}
+
+// parseISOClock reads HH[:MM[:SS[.ffffff]]], colons optional but consistent
+func parseISOClock(s string) ([4]int, bool) {
+    var fields [4]int
+    pos, hasSep := 0, false
+    for i := 0; i < 3; i++ {
+        n, ok := isoNumber(s, pos, 2)
+        if !ok {
+            return fields, false
+        }
+        fields[i] = n
+        pos += 2
+        if pos == len(s) || i == 2 {
+            break
+        }
+        if i == 0 {
+            hasSep = s[pos] == ':'
+        }
+        if hasSep {
+            if s[pos] != ':' {
+                return fields, false
+            }
+            pos++
+        }
+    }
+    if pos < len(s) {
+        if s[pos] != '.' && s[pos] != ',' {
+            return fields, false
+        }
+        pos++
+        digits := min(len(s)-pos, 6)
+        n, ok := isoNumber(s, pos, digits)
+        if !ok {
+            return fields, false
+        }
+        for i := digits; i < 6; i++ {
+            n *= 10
+        }
+        fields[3] = n
+        for _, c := range []byte(s[pos+digits:]) {
+            if !isDigit(c) {
+                return fields, false
+            }
+        }
+    }
+    return fields, true
+}
+
+// parseISOTime reads a time and the offset that may follow it
+func parseISOTime(s string) ([4]int, Object, bool) {
+    if len(s) < 2 {
+        return [4]int{}, nil, false
+    }
+    at := strings.IndexByte(s, '-')
+    if at < 0 {
+        at = strings.IndexByte(s, '+')
+    }
+    if at < 0 {
+        at = strings.IndexByte(s, 'Z')
+    }
+    clock := s
+    if at >= 0 {
+        clock = s[:at]
+    }
+    fields, ok := parseISOClock(clock)
+    if !ok {
+        return fields, nil, false
+    }
+    switch {
+    case at < 0:
+        return fields, NULL, true
+    case at == len(s)-1 && s[at] == 'Z':
+        return fields, utcZone, true
+    }
+    zone := s[at+1:]
+    if n := len(zone); n == 0 || n == 1 || n == 3 {
+        return fields, nil, false
+    }
+    offset, ok := parseISOClock(zone)
+    if !ok {
+        return fields, nil, false
+    }
+    micros := int64((offset[0]*60+offset[1])*60+offset[2])*1e6 + int64(offset[3])
+    if s[at] == '-' {
+        micros = -micros
+    }
+    delta := timeDeltaOf(micros)
+    if !withinADay(delta) {
+        return fields, nil, false
+    }
+    return fields, newTimeZone(delta, NULL), true
+}
+
+func parseISODateTime(s string) (*DateTime, bool) {
+    if len(s) < 7 {
+        return nil, false
+    }
+    sep := isoSeparator(s)
+    if sep < 0 || sep > len(s) {
+        return nil, false
+    }
+    year, month, day, ok := parseISODate(s[:sep])
+    if !ok {
+        return nil, false
+    }
+    dt := &DateTime{Date: Date{year: year, month: month, day: day}, TimeOfDay: TimeOfDay{tzinfo: NULL}}
+    if sep+1 < len(s) {
+        fields, tzinfo, ok := parseISOTime(s[sep+1:])
+        if !ok {
+            return nil, false
+        }
+        dt.TimeOfDay = TimeOfDay{hour: fields[0], minute: fields[1], second: fields[2], micro: fields[3], tzinfo: tzinfo}
+    }
+    return dt, true
+}
+
+func buildDatetime(m *Module) {
+    for _, cls := range []*Class{dateType, timeOfDayType, datetimeType, timedeltaType, tzinfoType, timezoneType} {
+        m.Env.Set(cls.Name, cls)
+    }
+    m.Env.Set("MINYEAR", newInt(minYear))
+    m.Env.Set("MAXYEAR", newInt(maxYear))
+    m.Env.Set("UTC", utcZone)
+}
+
+// zoneInfoPath is where zoneinfo looks for the tz database
+var zoneInfoPath = []string{"/usr/share/zoneinfo", "/usr/lib/zoneinfo", "/usr/share/lib/zoneinfo", "/etc/zoneinfo"}
+
+func buildZoneInfo(m *Module) {
+    m.Env.Set("ZoneInfo", zoneInfoType)
+    m.Env.Set("ZoneInfoNotFoundError", zoneNotFoundType)
+    path := make([]Object, len(zoneInfoPath))
+    for i, dir := range zoneInfoPath {
+        path[i] = &String{Value: dir}
+    }
+    m.Env.Set("TZPATH", &Tuple{Elements: path})
+    m.function("available_timezones", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("available_timezones", args, kwargs, 0, 0); err != nil {
+            return err
+        }
+        keys := NewSet()
+        for _, root := range zoneInfoPath {
+            filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+                if err != nil {
+                    return nil
+                }
+                key, _ := filepath.Rel(root, path)
+                if entry.IsDir() {
+                    if key == "right" || key == "posix" {
+                        return filepath.SkipDir
+                    }
+                    return nil
+                }
+                if key != "posixrules" && isTZif(path) {
+                    keys.Add(env, &String{Value: key})
+                }
+                return nil
+            })
+        }
+        return keys
+    })
+}
+
+// isTZif tells a compiled zone from the other files in the database
+func isTZif(path string) bool {
+    f, err := os.Open(path)
+    if err != nil {
+        return false
+    }
+    defer f.Close()
+    magic := make([]byte, 4)
+    _, err = io.ReadFull(f, magic)
+    return err == nil && string(magic) == "TZif"
+}
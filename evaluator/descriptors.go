@@ -0,0 +1,294 @@
+// Comments in this file are inspired by Alex Williams - the one who stands between a client and the firm
+
+package evaluator
+
+import (
+    "fmt"
+)
+
+// Descriptors decide what an attribute found on a class turns into. Functions
+// bind to the instance; anything with __get__ gets asked; the rest comes back
+// as it is. Data descriptors - __set__ or __delete__ - beat the instance dict.
+
+// StaticMethod is staticmethod(fn): on a class or an instance, it is just fn
+type StaticMethod struct {
+    Function Object
+    Dict     *Dict
+}
+
+func (s *StaticMethod) Type() ObjectType { return STATICMETHOD_OBJ }
+func (s *StaticMethod) Inspect() string  { return fmt.Sprintf("<staticmethod(%s)>", inspectCallable(s.Function)) }
+
+// ClassMethod is classmethod(fn): it binds to the class, never the instance
+type ClassMethod struct {
+    Function Object
+    Dict     *Dict
+}
+
+func (c *ClassMethod) Type() ObjectType { return CLASSMETHOD_OBJ }
+func (c *ClassMethod) Inspect() string  { return fmt.Sprintf("<classmethod(%s)>", inspectCallable(c.Function)) }
+
+// Property runs code when an attribute is read, set or deleted
+type Property struct {
+    Getter  Object // nil when missing
+    Setter  Object
+    Deleter Object
+    Doc     Object
+    Name    string // learnt from __set_name__, for the error messages
+
+    getterDoc bool // Doc came from the getter, so a new getter brings its own
+}
+
+func (p *Property) Type() ObjectType { return PROPERTY_OBJ }
+func (p *Property) Inspect() string  { return fmt.Sprintf("<property object at %#x>", objectID(p)) }
+
+var (
+    staticmethodType = newBuiltinClass("staticmethod", objectType)
+    classmethodType  = newBuiltinClass("classmethod", objectType)
+    propertyType     = newBuiltinClass("property", objectType)
+)
+
+func inspectCallable(fn Object) string {
+    if fn == nil {
+        return "None"
+    }
+    return fn.Inspect()
+}
+
+// descriptorGet is what attr, found on owner's MRO, turns into when looked up
+// through instance. instance is nil when the lookup is on the class itself.
+func descriptorGet(env *Environment, attr Object, instance Object, owner *Class) Object {
+    switch attr.(type) {
+    case *Function, *Builtin:
+        if instance == nil {
+            return attr
+        }
+        return bindAttribute(attr, instance)
+    }
+    get := typeOf(attr).lookupName("__get__")
+    if get == nil {
+        return attr
+    }
+    if instance == nil {
+        instance = NULL
+    }
+    return callMethod(env, get, attr, instance, owner)
+}
+
+// isDataDescriptor tells whether attr overrides the instance dict
+func isDataDescriptor(attr Object) bool {
+    switch attr.(type) {
+    case *Function, *Builtin:
+        return false
+    }
+    cls := typeOf(attr)
+    return cls.lookupName("__set__") != nil || cls.lookupName("__delete__") != nil
+}
+
+// setNames tells the descriptors in a new class what they are called
+func setNames(env *Environment, cls *Class) *Error {
+    for _, entry := range cls.Dict.Entries() {
+        setName := typeOf(entry.Value).lookupName("__set_name__")
+        if setName == nil {
+            continue
+        }
+        if err, ok := callMethod(env, setName, entry.Value, cls, entry.Key).(*Error); ok {
+            return err
+        }
+    }
+    return nil
+}
+
+// noneToNil turns an omitted or None argument into nil
+func noneToNil(obj Object) Object {
+    if obj == NULL {
+        return nil
+    }
+    return obj
+}
+
+func nilToNone(obj Object) Object {
+    if obj == nil {
+        return NULL
+    }
+    return obj
+}
+
+func (s *StaticMethod) attribute(name string) (Object, bool) {
+    return wrappedAttribute(s.Function, name)
+}
+
+func (c *ClassMethod) attribute(name string) (Object, bool) {
+    return wrappedAttribute(c.Function, name)
+}
+
+// wrappedName is one of the names of a wrapped function, or fallback
+func wrappedName(fn Object, name string, fallback string) string {
+    if value, ok := wrappedAttribute(fn, name); ok {
+        if s, ok := value.(*String); ok {
+            return s.Value
+        }
+    }
+    return fallback
+}
+
+// wrappedAttribute forwards the function's names, the way the wrappers copy them
+func wrappedAttribute(fn Object, name string) (Object, bool) {
+    switch name {
+    case "__func__", "__wrapped__":
+        return nilToNone(fn), true
+    case "__isabstractmethod__":
+        return FALSE, true
+    case "__name__", "__qualname__", "__doc__", "__module__":
+        if fn == nil {
+            return nil, false
+        }
+        value := getAttribute(nil, fn, name)
+        return value, !isError(value)
+    }
+    return nil, false
+}
+
+func (p *Property) attribute(name string) (Object, bool) {
+    switch name {
+    case "fget":
+        return nilToNone(p.Getter), true
+    case "fset":
+        return nilToNone(p.Setter), true
+    case "fdel":
+        return nilToNone(p.Deleter), true
+    case "__doc__":
+        return nilToNone(p.Doc), true
+    case "__isabstractmethod__":
+        return FALSE, true
+    }
+    return nil, false
+}
+
+// missing words the complaint about a property without a getter, setter or deleter
+func (p *Property) missing(instance Object, what string) *Error {
+    if p.Name == "" {
+        return attributeError("property of '%s' object has no %s", typeName(instance), what)
+    }
+    return attributeError("property '%s' of '%s' object has no %s", p.Name, typeName(instance), what)
+}
+
+func asProperty(obj Object) *Property { return payload(obj).(*Property) }
+
+func init() {
+    for _, wrapper := range []struct {
+        cls  *Class
+        make func() Object
+        set  func(obj, fn Object)
+    }{
+        {staticmethodType, func() Object { return &StaticMethod{Dict: NewDict()} },
+            func(obj, fn Object) { payload(obj).(*StaticMethod).Function = fn }},
+        {classmethodType, func() Object { return &ClassMethod{Dict: NewDict()} },
+            func(obj, fn Object) { payload(obj).(*ClassMethod).Function = fn }},
+    } {
+        cls, newValue, set := wrapper.cls, wrapper.make, wrapper.set
+        cls.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+            newCls, err := newClassArg(cls.Name, args)
+            if err != nil {
+                return err
+            }
+            return wrapBuiltinValue(newCls, cls, newValue())
+        })
+        cls.define("__init__", func(env *Environment, args []Object, kwargs *Dict) Object {
+            if kwargs.Len() > 0 {
+                return typeError("%s() takes no keyword arguments", cls.Name)
+            }
+            if len(args) != 2 {
+                return typeError("%s expected 1 argument, got %d", cls.Name, len(args)-1)
+            }
+            set(args[0], args[1])
+            return NULL
+        })
+        cls.method("__repr__", 0, 0, func(env *Environment, args []Object) Object {
+            return &String{Value: payload(args[0]).Inspect()}
+        })
+    }
+
+    staticmethodType.method("__get__", 1, 2, func(env *Environment, args []Object) Object {
+        return payload(args[0]).(*StaticMethod).Function
+    })
+    staticmethodType.define("__call__", func(env *Environment, args []Object, kwargs *Dict) Object {
+        return applyFunction(env, payload(args[0]).(*StaticMethod).Function, args[1:], kwargs)
+    })
+    classmethodType.method("__get__", 1, 2, func(env *Environment, args []Object) Object {
+        owner := typeOf(args[1])
+        if len(args) == 3 {
+            if cls, ok := args[2].(*Class); ok {
+                owner = cls
+            }
+        }
+        return &BoundMethod{Self: owner, Function: payload(args[0]).(*ClassMethod).Function}
+    })
+
+    propertyType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        cls, err := newClassArg("property", args)
+        if err != nil {
+            return err
+        }
+        return wrapBuiltinValue(cls, propertyType, &Property{})
+    })
+    propertyType.define("__init__", func(env *Environment, args []Object, kwargs *Dict) Object {
+        values, err := parseArgs("property", args[1:], kwargs, []string{"fget", "fset", "fdel", "doc"}, 0)
+        if err != nil {
+            return err
+        }
+        p := asProperty(args[0])
+        p.Getter, p.Setter, p.Deleter = noneToNil(values[0]), noneToNil(values[1]), noneToNil(values[2])
+        p.Doc, p.getterDoc = noneToNil(values[3]), false
+        if p.Doc == nil && p.Getter != nil {
+            if doc := getAttribute(env, p.Getter, "__doc__"); !isError(doc) {
+                p.Doc, p.getterDoc = noneToNil(doc), true
+            }
+        }
+        return NULL
+    })
+    propertyType.method("__get__", 1, 2, func(env *Environment, args []Object) Object {
+        p, instance := asProperty(args[0]), args[1]
+        if instance == NULL {
+            return args[0]
+        }
+        if p.Getter == nil {
+            return p.missing(instance, "getter")
+        }
+        return applyFunction(env, p.Getter, []Object{instance}, nil)
+    })
+    propertyType.method("__set__", 2, 2, func(env *Environment, args []Object) Object {
+        p, instance := asProperty(args[0]), args[1]
+        if p.Setter == nil {
+            return p.missing(instance, "setter")
+        }
+        return applyFunction(env, p.Setter, []Object{instance, args[2]}, nil)
+    })
+    propertyType.method("__delete__", 1, 1, func(env *Environment, args []Object) Object {
+        p, instance := asProperty(args[0]), args[1]
+        if p.Deleter == nil {
+            return p.missing(instance, "deleter")
+        }
+        return applyFunction(env, p.Deleter, []Object{instance}, nil)
+    })
+    propertyType.method("__set_name__", 2, 2, func(env *Environment, args []Object) Object {
+        if name, ok := args[2].(*String); ok {
+            asProperty(args[0]).Name = name.Value
+        }
+        return NULL
+    })
+
+    // getter, setter and deleter copy the property with one accessor swapped
+    for i, name := range []string{"getter", "setter", "deleter"} {
+        i := i
+        propertyType.method(name, 1, 1, func(env *Environment, args []Object) Object {
+            p := asProperty(args[0])
+            accessors := []Object{nilToNone(p.Getter), nilToNone(p.Setter), nilToNone(p.Deleter), nilToNone(p.Doc)}
+            if p.getterDoc {
+                accessors[3] = NULL
+            }
+            accessors[i] = args[1]
+            return instantiate(env, typeOf(args[0]), accessors, nil)
+        })
+    }
+}
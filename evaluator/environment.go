@@ -2,17 +2,123 @@
 
 package evaluator
 
+import (
+    "bufio"
+    "context"
+    "interpreter/parser"
+    "io"
+    "path/filepath"
+    "sort"
+    "strings"
+    "sync"
+    "sync/atomic"
+)
+
 // Environment holds variables like I keep track of Harvey's schedule
 type Environment struct {
     store map[string]Object  // My filing cabinet
+    names []string           // ...and the order things went into it
     outer *Environment       // Louis's files when I need them
+
+    fn         *Function       // The function whose call opened this scope
+    classScope bool            // Class bodies don't leak into their methods
+    scope      *parser.Scope   // Which names are whose, worked out before the code ran
+    class      *Class          // What a class body turned into, once it has - super() needs it
+    interp     *interpreter    // The whole firm, shared by every scope
+    frame      *Frame          // The call this scope's code is running in
+    gen        *generatorState // The generator whose body this is, if any
+    namespace  *Dict           // globals(), once asked for - kept in step with store
+}
+
+// interpreter is the state one running program shares across all its scopes
+type interpreter struct {
+    handling []*Exception        // Exceptions whose handlers are running, innermost last
+    depth    int                 // Frames deep, so runaway recursion gets stopped
+    sys      *Module             // sys itself, where the standard streams live
+    modules  *Dict               // sys.modules: every module loaded so far, by name
+    path     *List               // sys.path: the directories imports search, in order
+    optimize bool                // python -O: assert statements are skipped
+    builtins map[string]Object   // what the host registered, on top of the shared builtins
+    ctx      context.Context     // RunContext's; loops and time.sleep stop once it is done
+    repring  map[Object]bool     // containers being repr'd right now, so one holding itself prints [...]
+    sources  map[string][]string // our linecache: the text of every file a traceback may quote
+
+    // Generators the collector found unreachable, waiting for a safe point
+    // to close them. Finalizers run on a goroutine of their own, hence the lock.
+    abandonMu sync.Mutex
+    abandoned []*Generator
+    pending   atomic.Int32 // len(abandoned), for a lock-free peek every loop iteration
+    released  bool         // the host dropped its Interpreter; nothing will run here again
 }
 
+// NewEnvironment is a fresh top-level scope for code typed at the REPL
 func NewEnvironment() *Environment {
-    return &Environment{
-        store: make(map[string]Object),
-        outer: nil,
+    return NewFileEnvironment("<stdin>")
+}
+
+// NewFileEnvironment is a top-level scope for code read from filename. It
+// runs as __main__, and imports look next to it first.
+func NewFileEnvironment(filename string) *Environment {
+    env := &Environment{
+        store:  make(map[string]Object),
+        outer:  nil,
+        interp: newInterpreter(),
+        frame:  &Frame{Name: "<module>", Filename: filename},
+    }
+    main := &Module{Name: "__main__", Env: env}
+    env.Set("__name__", &String{Value: "__main__"})
+    env.Set("__doc__", NULL)
+    env.Set("__package__", NULL)
+    dir := ""
+    if !strings.HasPrefix(filename, "<") {
+        main.File = filename
+        env.Set("__file__", &String{Value: filename})
+        dir = filepath.Dir(filename)
     }
+    env.interp.modules.SetStr("__main__", main)
+    env.interp.sys, _ = loadNativeModule(env, "sys")
+    env.interp.path.Elements = append(env.interp.path.Elements, &String{Value: dir})
+    return env
+}
+
+func newInterpreter() *interpreter {
+    return &interpreter{
+        depth:   1, // the module is the first frame
+        modules: NewDict(),
+        path:    &List{},
+        repring: map[Object]bool{},
+        sources: map[string][]string{},
+    }
+}
+
+// AddSearchPath appends directories to sys.path, where imports look for modules
+func (e *Environment) AddSearchPath(dirs ...string) {
+    for _, dir := range dirs {
+        if dir != "" {
+            e.interp.path.Elements = append(e.interp.path.Elements, &String{Value: dir})
+        }
+    }
+}
+
+// SetOptimize is python -O: when on, assert statements are skipped
+func (e *Environment) SetOptimize(optimize bool) {
+    e.interp.optimize = optimize
+}
+
+// SetStdout points sys.stdout - and sys.__stdout__ - at w, so a host can
+// catch everything the program prints
+func (e *Environment) SetStdout(w io.Writer) {
+    e.interp.setStandardStream("stdout", standardStream("<stdout>", nil, w, "surrogateescape"))
+}
+
+// SetStderr is SetStdout for sys.stderr
+func (e *Environment) SetStderr(w io.Writer) {
+    e.interp.setStandardStream("stderr", standardStream("<stderr>", nil, w, "backslashreplace"))
+}
+
+// SetStdin gives input() and sys.stdin their lines from r
+func (e *Environment) SetStdin(r io.Reader) {
+    e.interp.setStandardStream("stdin", standardStream("<stdin>", bufio.NewReader(r), nil, "surrogateescape"))
 }
 
 // Get finds variables faster than I find dirt on clients
@@ -26,13 +132,239 @@ func (e *Environment) Get(name string) (Object, bool) {
 
 // Set stores variables - consider it done
 func (e *Environment) Set(name string, val Object) Object {
-    e.store[name] = val
+    e.put(name, val)
+    if e.namespace != nil {
+        e.namespace.SetStr(name, val)
+    }
     return val
 }
 
+// put is Set without telling globals() - for when globals() is the one telling
+func (e *Environment) put(name string, val Object) {
+    if _, ok := e.store[name]; !ok {
+        e.names = append(e.names, name)
+    }
+    e.store[name] = val
+}
+
+// Delete shreds a file. There are no copies.
+func (e *Environment) Delete(name string) bool {
+    if !e.remove(name) {
+        return false
+    }
+    if e.namespace != nil {
+        e.namespace.deleteStr(name)
+    }
+    return true
+}
+
+// remove is Delete's quiet half, the way put is Set's
+func (e *Environment) remove(name string) bool {
+    if _, ok := e.store[name]; !ok {
+        return false
+    }
+    delete(e.store, name)
+    for i, n := range e.names {
+        if n == name {
+            e.names = append(e.names[:i], e.names[i+1:]...)
+            break
+        }
+    }
+    return true
+}
+
+// Names lists what's on my desk, oldest first
+func (e *Environment) Names() []string {
+    return e.names
+}
+
+// namespaceDict is globals(): a real dict, and writing to it writes the scope
+func (e *Environment) namespaceDict() *Dict {
+    if e.namespace == nil {
+        dict := NewDict()
+        for _, name := range e.names {
+            dict.SetStr(name, e.store[name])
+        }
+        dict.namespace = e
+        e.namespace = dict
+    }
+    return e.namespace
+}
+
+// locals is locals(): the module's own dict at the top, a snapshot inside a
+// function - free variables included, like CPython
+func (e *Environment) locals() *Dict {
+    if e.outer == nil {
+        return e.namespaceDict()
+    }
+    dict := NewDict()
+    for _, name := range e.names {
+        dict.SetStr(name, e.store[name])
+    }
+    if e.scope != nil {
+        free := []string{}
+        for name, kind := range e.scope.Symbols {
+            if kind == parser.Free {
+                free = append(free, name)
+            }
+        }
+        sort.Strings(free)
+        for _, name := range free {
+            if owner := e.enclosing(name); owner != nil {
+                if value, ok := owner.store[name]; ok {
+                    dict.SetStr(name, value)
+                }
+            }
+        }
+    }
+    return dict
+}
+
 // Creates a nested scope - like when I pretend to work for Louis
 func NewEnclosedEnvironment(outer *Environment) *Environment {
-    env := NewEnvironment()
-    env.outer = outer
-    return env
-}
\ No newline at end of file
+    return &Environment{
+        store:  make(map[string]Object),
+        outer:  outer,
+        interp: outer.interp,
+        frame:  outer.frame,
+    }
+}
+
+// globals is the module scope at the bottom of the pile
+func (e *Environment) globals() *Environment {
+    for e.outer != nil {
+        e = e.outer
+    }
+    return e
+}
+
+// moduleName is the __name__ of the module this scope's code belongs to
+func (e *Environment) moduleName() string {
+    if name, ok := e.globals().store["__name__"].(*String); ok {
+        return name.Value
+    }
+    return "__main__"
+}
+
+// lookup finds a name where the scope analysis put it. A local that isn't
+// set yet is an UnboundLocalError, not a reason to look further out.
+func (e *Environment) lookup(name string) (Object, *Error) {
+    if e.scope == nil {
+        // Module level - or code that was never analyzed, which gets the old chain
+        if value, ok := e.Get(name); ok {
+            return value, nil
+        }
+    }
+    switch e.scope.Kind(name) {
+    case parser.Local, parser.Cell:
+        if value, ok := e.store[name]; ok {
+            return value, nil
+        }
+        if !e.classScope {
+            return nil, unboundLocal(name)
+        }
+        // A class body looks past its own names to the globals
+    case parser.Free:
+        if owner := e.enclosing(name); owner != nil {
+            if value, ok := owner.store[name]; ok {
+                return value, nil
+            }
+        }
+        return nil, nameError("cannot access free variable '%s' where it is not associated with a value in enclosing scope", name)
+    }
+    if value, ok := e.globals().store[name]; ok {
+        return value, nil
+    }
+    if builtin, ok := e.interp.builtin(name); ok {
+        return builtin, nil
+    }
+    return nil, nameError("name '%s' is not defined", name)
+}
+
+// bind is assignment: global and nonlocal names go where they were declared
+func (e *Environment) bind(name string, value Object) {
+    switch e.scope.Kind(name) {
+    case parser.GlobalExplicit:
+        e.globals().Set(name, value)
+        return
+    case parser.Free:
+        if owner := e.enclosing(name); owner != nil {
+            owner.Set(name, value)
+            return
+        }
+    }
+    e.Set(name, value)
+}
+
+// unbind is `del name`
+func (e *Environment) unbind(name string) *Error {
+    target := e
+    switch e.scope.Kind(name) {
+    case parser.GlobalExplicit:
+        target = e.globals()
+    case parser.Free:
+        if target = e.enclosing(name); target == nil || !target.Delete(name) {
+            return nameError("cannot access free variable '%s' where it is not associated with a value in enclosing scope", name)
+        }
+        return nil
+    }
+    if target.Delete(name) {
+        return nil
+    }
+    if kind := target.scope.Kind(name); (kind == parser.Local || kind == parser.Cell) && !target.classScope {
+        return unboundLocal(name)
+    }
+    return nameError("name '%s' is not defined", name)
+}
+
+// enclosing finds the def around this scope that owns a free name
+func (e *Environment) enclosing(name string) *Environment {
+    for env := e.outer; env != nil && env.outer != nil; env = env.outer {
+        if env.classScope {
+            continue
+        }
+        if kind := env.scope.Kind(name); kind == parser.Local || kind == parser.Cell {
+            return env
+        }
+    }
+    return nil
+}
+
+func unboundLocal(name string) *Error {
+    return newErrorKind(unboundLocalErrorType, "cannot access local variable '%s' where it is not associated with a value", name)
+}
+
+// function finds the call this scope belongs to - I always know who I work for
+func (e *Environment) function() (*Function, *Environment) {
+    for env := e; env != nil; env = env.outer {
+        if env.fn != nil {
+            return env.fn, env
+        }
+    }
+    return nil, nil
+}
+
+// handled is the exception whose handler is running right now, if any
+// builtin looks past the globals: the host's functions, then everyone's
+func (i *interpreter) builtin(name string) (Object, bool) {
+    if builtin, ok := i.builtins[name]; ok {
+        return builtin, true
+    }
+    builtin, ok := builtins[name]
+    return builtin, ok
+}
+
+func (i *interpreter) handled() *Exception {
+    if len(i.handling) == 0 {
+        return nil
+    }
+    return i.handling[len(i.handling)-1]
+}
+
+func (i *interpreter) pushHandled(e *Exception) {
+    i.handling = append(i.handling, e)
+}
+
+func (i *interpreter) popHandled() {
+    i.handling = i.handling[:len(i.handling)-1]
+}
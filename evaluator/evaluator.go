@@ -1,23 +1,67 @@
-// The comments in this file are inspired by Harvey Specter - my fav character from suits 
+// The comments in this file are inspired by Harvey Specter - my fav character from suits
 
 package evaluator
 
 import (
     "fmt"
     "interpreter/parser"
+    "math/big"
     "strconv"
+    "strings"
+    "sync/atomic"
+    "unicode/utf8"
 )
 
 // Look, we need types to represent different kinds of data. This isn't a democracy.
 type ObjectType string
 
 const (
-    INTEGER_OBJ  = "INTEGER"
-    STRING_OBJ   = "STRING"
-    BOOLEAN_OBJ  = "BOOLEAN"
-    NULL_OBJ     = "NULL"
-    ERROR_OBJ    = "ERROR"
-    BUILTIN_OBJ  = "BUILTIN"
+    INTEGER_OBJ         = "INTEGER"
+    FLOAT_OBJ           = "FLOAT"
+    STRING_OBJ          = "STRING"
+    BOOLEAN_OBJ         = "BOOLEAN"
+    NULL_OBJ            = "NULL"
+    ERROR_OBJ           = "ERROR"
+    BUILTIN_OBJ         = "BUILTIN"
+    FUNCTION_OBJ        = "FUNCTION"
+    BOUND_METHOD_OBJ    = "BOUND_METHOD"
+    CLASS_OBJ           = "CLASS"
+    INSTANCE_OBJ        = "INSTANCE"
+    SUPER_OBJ           = "SUPER"
+    LIST_OBJ            = "LIST"
+    TUPLE_OBJ           = "TUPLE"
+    DICT_OBJ            = "DICT"
+    SET_OBJ             = "SET"
+    SLICE_OBJ           = "SLICE"
+    ITERATOR_OBJ        = "ITERATOR"
+    NOT_IMPLEMENTED_OBJ = "NOT_IMPLEMENTED"
+    RETURN_VALUE_OBJ    = "RETURN_VALUE"
+    LOOP_CONTROL_OBJ    = "LOOP_CONTROL"
+    EXCEPTION_OBJ       = "EXCEPTION"
+    TRACEBACK_OBJ       = "TRACEBACK"
+    FRAME_OBJ           = "FRAME"
+    GENERATOR_OBJ       = "GENERATOR"
+    STATICMETHOD_OBJ    = "STATICMETHOD"
+    CLASSMETHOD_OBJ     = "CLASSMETHOD"
+    PROPERTY_OBJ        = "PROPERTY"
+    MODULE_OBJ          = "MODULE"
+    FILE_OBJ            = "FILE"
+    BYTES_OBJ           = "BYTES"
+    BYTEARRAY_OBJ       = "BYTEARRAY"
+    MEMORYVIEW_OBJ      = "MEMORYVIEW"
+    COMPLEX_OBJ         = "COMPLEX"
+    RANGE_OBJ           = "RANGE"
+    PATTERN_OBJ         = "PATTERN"
+    MATCH_OBJ           = "MATCH"
+    DEQUE_OBJ           = "DEQUE"
+    PARTIAL_OBJ         = "PARTIAL"
+    TIMEDELTA_OBJ       = "TIMEDELTA"
+    DATE_OBJ            = "DATE"
+    TIME_OBJ            = "TIME"
+    DATETIME_OBJ        = "DATETIME"
+    TIMEZONE_OBJ        = "TIMEZONE"
+    ZONEINFO_OBJ        = "ZONEINFO"
+    RANDOM_OBJ          = "RANDOM"
 )
 
 // Everything's an Object. Deal with it.
@@ -32,23 +76,32 @@ var NULL = &NullObject{}
 type NullObject struct{}
 
 func (n *NullObject) Type() ObjectType { return NULL_OBJ }
-func (n *NullObject) Inspect() string  { return "null" }
+func (n *NullObject) Inspect() string  { return "None" }
 
-// Integers. They're simple. I like simple.
+// Integers. They're simple. I like simple. And they never overflow.
 type Integer struct {
-    Value string
+    Value *big.Int
 }
 
 func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
-func (i *Integer) Inspect() string  { return i.Value }
+func (i *Integer) Inspect() string  { return i.Value.String() }
+
+// Floats. Precise enough for anyone who isn't an accountant.
+type Float struct {
+    Value float64
+}
+
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+func (f *Float) Inspect() string  { return floatRepr(f.Value) }
 
 // Strings. For when numbers aren't enough.
 type String struct {
     Value string
+    index atomic.Pointer[stringIndex] // decoded on first subscript; see (*String).codePoints
 }
 
 func (s *String) Type() ObjectType { return STRING_OBJ }
-func (s *String) Inspect() string  { return s.Value }
+func (s *String) Inspect() string  { return strRepr(s.Value) }
 
 func (s *String) QuotedValue() string {
     return fmt.Sprintf("\"%s\"", s.Value)
@@ -59,91 +112,527 @@ type Boolean struct {
     Value bool
 }
 
+var (
+    TRUE  = &Boolean{Value: true}
+    FALSE = &Boolean{Value: false}
+)
+
 func (b *Boolean) Type() ObjectType { return BOOLEAN_OBJ }
 func (b *Boolean) Inspect() string  {
     if b.Value {
-        return "true"
+        return "True"
     }
-    return "false"
+    return "False"
 }
 
-// Errors. They happen. I fix them.
+// NotImplemented. What a method says when it wants the other side to handle it.
+var NotImplemented = &NotImplementedObject{}
+
+type NotImplementedObject struct{}
+
+func (n *NotImplementedObject) Type() ObjectType { return NOT_IMPLEMENTED_OBJ }
+func (n *NotImplementedObject) Inspect() string  { return "NotImplemented" }
+
+// Errors. They happen. I fix them. An Error is an exception on its way up the stack.
 type Error struct {
-    Message string
+    Exception *Exception
+    traced    bool // the current frame is already on the exception's traceback
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
-func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
+func (e *Error) Inspect() string  {
+    name := exceptionName(e.Exception.Class)
+    if message := e.Exception.message(); message != "" {
+        return name + ": " + message
+    }
+    return name
+}
+
+// matches is what an except clause asks: are you one of mine?
+func (e *Error) matches(cls *Class) bool {
+    return e.Exception != nil && e.Exception.Class.isSubclass(cls)
+}
 
 // Functions that are built-in. Like my charm.
-type BuiltinFunction func(args ...Object) Object
+type BuiltinFunction func(env *Environment, args []Object, kwargs *Dict) Object
 
 type Builtin struct {
-    Fn BuiltinFunction
+    Name  string
+    Fn    BuiltinFunction
+    Owner *Class // set for methods of builtin types; they bind like a def would
+    Wraps Object // set when it stands in for a Python function, which it then passes for
+    Doc   string // __doc__, for the ones a host registered with one
+    Dict  *Dict  // attributes set on it, for the wrappers functools makes
 }
 
 func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
-func (b *Builtin) Inspect() string  { return "builtin function" }
+func (b *Builtin) Inspect() string  {
+    if b.Wraps != nil {
+        return fmt.Sprintf("<function %s at %#x>", wrappedName(b.Wraps, "__qualname__", b.Name), objectID(b))
+    }
+    if b.Owner != nil {
+        return fmt.Sprintf("<method '%s' of '%s' objects>", b.Name, b.Owner.Name)
+    }
+    return fmt.Sprintf("<built-in function %s>", b.Name)
+}
+
+// Return values. I always know when to walk away.
+type ReturnValue struct {
+    Value Object
+}
+
+func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
+func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
+
+// break and continue. Knowing when to stop is half the job.
+type LoopControl struct {
+    Break bool
+}
+
+var (
+    BREAK    = &LoopControl{Break: true}
+    CONTINUE = &LoopControl{Break: false}
+)
+
+func (lc *LoopControl) Type() ObjectType { return LOOP_CONTROL_OBJ }
+func (lc *LoopControl) Inspect() string  {
+    if lc.Break {
+        return "break"
+    }
+    return "continue"
+}
 
 // Built-ins. They're not up for negotiation.
-var builtins = map[string]*Builtin{
-    "print": {
-        Fn: func(args ...Object) Object {
-            for _, arg := range args {
-                fmt.Println(arg.Inspect())
+var builtins = map[string]Object{}
+
+func init() {
+    registerBuiltin("print", func(env *Environment, args []Object, kwargs *Dict) Object {
+        params, err := parseArgs("print", nil, kwargs, []string{"sep", "end", "file", "flush"}, 0)
+        if err != nil {
+            return err
+        }
+        sep, end := " ", "\n"
+        for i, name := range []string{"sep", "end"} {
+            if params[i] == nil {
+                continue
+            }
+            switch value := payload(params[i]).(type) {
+            case *NullObject:
+            case *String:
+                if i == 0 {
+                    sep = value.Value
+                } else {
+                    end = value.Value
+                }
+            default:
+                return typeError("%s must be None or a string, not %s", name, typeName(params[i]))
+            }
+        }
+        file := params[2]
+        if file == nil || file == NULL {
+            if file, err = env.interp.stream("stdout"); err != nil {
+                return err
+            }
+            if file == NULL {
+                return NULL // no sys.stdout, nowhere to print to - not an error
             }
-            return NULL
-        },
-    },
+        }
+        // one write per piece, the way CPython calls file.write
+        for i, arg := range args {
+            if i > 0 {
+                if err := writeStream(env, file, sep); err != nil {
+                    return err
+                }
+            }
+            str := strOf(env, arg)
+            if isError(str) {
+                return str
+            }
+            if err := writeStream(env, file, payload(str).(*String).Value); err != nil {
+                return err
+            }
+        }
+        if err := writeStream(env, file, end); err != nil {
+            return err
+        }
+        if params[3] != nil {
+            flush, err := truthy(env, params[3])
+            if err != nil {
+                return err
+            }
+            if flush {
+                if err := flushStream(env, file); err != nil {
+                    return err
+                }
+            }
+        }
+        return NULL
+    })
+    registerBuiltin("len", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("len", args, kwargs, 1, 1); err != nil {
+            return err
+        }
+        n, err := length(env, args[0])
+        if err != nil {
+            return err
+        }
+        return newInt(int64(n))
+    })
+    registerBuiltin("repr", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("repr", args, kwargs, 1, 1); err != nil {
+            return err
+        }
+        return reprOf(env, args[0])
+    })
+    registerBuiltin("hash", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("hash", args, kwargs, 1, 1); err != nil {
+            return err
+        }
+        h, err := hashOf(env, args[0])
+        if err != nil {
+            return err
+        }
+        return newInt(h)
+    })
+    registerBuiltin("abs", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("abs", args, kwargs, 1, 1); err != nil {
+            return err
+        }
+        method := typeOf(args[0]).lookupName("__abs__")
+        if method == nil {
+            return typeError("bad operand type for abs(): '%s'", typeName(args[0]))
+        }
+        return callMethod(env, method, args[0])
+    })
+    registerBuiltin("iter", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("iter", args, kwargs, 1, 2); err != nil {
+            return err
+        }
+        if len(args) == 2 {
+            return callIterator(env, args[0], args[1])
+        }
+        return getIter(env, args[0])
+    })
+    registerBuiltin("next", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("next", args, kwargs, 1, 2); err != nil {
+            return err
+        }
+        if !isIterator(args[0]) {
+            return typeError("'%s' object is not an iterator", typeName(args[0]))
+        }
+        value := sendInto(env, args[0], NULL) // keeps a generator's return value
+        if len(args) == 2 && isStopIteration(value) {
+            return args[1]
+        }
+        return value
+    })
+    registerBuiltin("isinstance", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("isinstance", args, kwargs, 2, 2); err != nil {
+            return err
+        }
+        ok, err := classCheck("isinstance", typeOf(args[0]), args[1])
+        if err != nil {
+            return err
+        }
+        return nativeBool(ok)
+    })
+    registerBuiltin("issubclass", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("issubclass", args, kwargs, 2, 2); err != nil {
+            return err
+        }
+        cls, ok := args[0].(*Class)
+        if !ok {
+            return typeError("issubclass() arg 1 must be a class")
+        }
+        ok, err := classCheck("issubclass", cls, args[1])
+        if err != nil {
+            return err
+        }
+        return nativeBool(ok)
+    })
+    registerBuiltin("super", builtinSuper)
+
+    for _, cls := range []*Class{objectType, typeType, intType, boolType, floatType, strType,
+        listType, tupleType, dictType, setType, frozensetType, staticmethodType, classmethodType, propertyType,
+        bytesType, bytearrayType, memoryviewType, complexType} {
+        builtins[cls.Name] = cls
+    }
+    builtins["NotImplemented"] = NotImplemented
     // When I need more, I'll add them. And they'll be spectacular.
 }
 
-// Eval - the closer. It handles every case and never loses.
+func registerBuiltin(name string, fn BuiltinFunction) {
+    builtins[name] = &Builtin{Name: name, Fn: fn}
+}
+
+// Eval - the closer. It handles every case and never loses. When it does,
+// the record shows exactly where.
 func Eval(node interface{}, env *Environment) Object {
+    result := evalNode(node, env)
+    if err, ok := result.(*Error); ok && !err.traced {
+        if located, ok := node.(parser.Located); ok {
+            err.trace(env, node, *located.Span())
+        }
+    }
+    return result
+}
+
+func evalNode(node interface{}, env *Environment) Object {
     switch node := node.(type) {
     case *parser.Program:
         return evalProgram(node, env)
-        
+
     case *parser.ExpressionStatement:
         return Eval(node.Expression, env)
-        
+
+    case *parser.AssignmentStatement:
+        val := Eval(node.Value, env)
+        if isError(val) {
+            return val
+        }
+        for _, target := range node.Targets {
+            if err := assign(target, val, env); err != nil {
+                return err
+            }
+        }
+        return NULL
+
+    case *parser.FunctionDefinition:
+        decorators := evalExpressions(node.Decorators, env)
+        if len(decorators) == 1 && isError(decorators[0]) {
+            return decorators[0]
+        }
+        fn := newFunction(env, node.Name, &node.Signature, node.Body, node.Generator, node.Scope)
+        if isError(fn) {
+            return fn
+        }
+        fn = decorate(env, node.Decorators, decorators, fn)
+        if isError(fn) {
+            return fn
+        }
+        env.bind(node.Name, fn)
+        return NULL
+
+    case *parser.AugmentedAssignStatement:
+        return evalAugmentedAssignment(node, env)
+
+    case *parser.NamedExpression:
+        value := Eval(node.Value, env)
+        if isError(value) {
+            return value
+        }
+        env.bind(node.Target.Value, value)
+        return value
+
+    case *parser.AssertStatement:
+        return evalAssertStatement(node, env)
+
+    case *parser.GlobalStatement, *parser.NonlocalStatement:
+        return NULL // the scope analysis has already taken them into account
+
+    case *parser.DeleteStatement:
+        for _, target := range node.Targets {
+            if err := deleteTarget(target, env); err != nil {
+                return err
+            }
+        }
+        return NULL
+
+    case *parser.LambdaExpression:
+        // the body runs as a return statement, so tracebacks point at the expression
+        body := []parser.Statement{&parser.ReturnStatement{Position: *node.Body.(parser.Located).Span(), Value: node.Body}}
+        return newFunction(env, "<lambda>", &node.Signature, body, node.Generator, node.Scope)
+
+    case *parser.ConditionalExpression:
+        condition := Eval(node.Condition, env)
+        if isError(condition) {
+            return condition
+        }
+        truth, err := truthy(env, condition)
+        if err != nil {
+            return err
+        }
+        if truth {
+            return Eval(node.Consequence, env)
+        }
+        return Eval(node.Alternative, env)
+
+    case *parser.StarredExpression:
+        return syntaxError("can't use starred expression here")
+
+    case *parser.ClassDefinition:
+        return evalClassDefinition(node, env)
+
+    case *parser.IfStatement:
+        return evalIfStatement(node, env)
+
+    case *parser.WhileStatement:
+        return evalWhileStatement(node, env)
+
+    case *parser.ForStatement:
+        return evalForStatement(node, env)
+
+    case *parser.TryStatement:
+        return evalTryStatement(node, env)
+
+    case *parser.WithStatement:
+        return evalWithItems(node, node.Items, env)
+
+    case *parser.MatchStatement:
+        return evalMatchStatement(node, env)
+
+    case *parser.ImportStatement:
+        return evalImportStatement(node, env)
+
+    case *parser.FromImportStatement:
+        return evalFromImportStatement(node, env)
+
+    case *parser.RaiseStatement:
+        return evalRaiseStatement(node, env)
+
+    case *parser.ReturnStatement:
+        if node.Value == nil {
+            return &ReturnValue{Value: NULL}
+        }
+        val := Eval(node.Value, env)
+        if isError(val) {
+            return val
+        }
+        return &ReturnValue{Value: val}
+
+    case *parser.PassStatement:
+        return NULL
+
+    case *parser.BreakStatement:
+        return BREAK
+
+    case *parser.ContinueStatement:
+        return CONTINUE
+
     case *parser.IntegerLiteral:
-        return &Integer{Value: node.Value}
-        
+        return evalIntegerLiteral(node)
+
+    case *parser.FloatLiteral:
+        value, err := strconv.ParseFloat(strings.ReplaceAll(node.Value, "_", ""), 64)
+        if err != nil && value == 0 {
+            return valueError("invalid float literal: %s", node.Value)
+        }
+        return &Float{Value: value}
+
+    case *parser.ImaginaryLiteral:
+        digits := strings.ReplaceAll(node.Value[:len(node.Value)-1], "_", "")
+        value, err := strconv.ParseFloat(digits, 64)
+        if err != nil && value == 0 {
+            return valueError("invalid imaginary literal: %s", node.Value)
+        }
+        return &Complex{Value: complex(0, value)}
+
     case *parser.StringLiteral:
         return &String{Value: node.Value}
-        
+
+    case *parser.BytesLiteral:
+        return &Bytes{Value: []byte(node.Value)}
+
+    case *parser.BooleanLiteral:
+        return nativeBool(node.Value)
+
+    case *parser.NoneLiteral:
+        return NULL
+
+    case *parser.ListLiteral:
+        elements := evalExpressions(node.Elements, env)
+        if len(elements) == 1 && isError(elements[0]) {
+            return elements[0]
+        }
+        return &List{Elements: elements}
+
+    case *parser.TupleLiteral:
+        elements := evalExpressions(node.Elements, env)
+        if len(elements) == 1 && isError(elements[0]) {
+            return elements[0]
+        }
+        return &Tuple{Elements: elements}
+
+    case *parser.SetLiteral:
+        elements := evalExpressions(node.Elements, env)
+        if len(elements) == 1 && isError(elements[0]) {
+            return elements[0]
+        }
+        set := NewSet()
+        for _, element := range elements {
+            if err := set.Add(env, element); err != nil {
+                return err
+            }
+        }
+        return set
+
+    case *parser.DictLiteral:
+        return evalDictLiteral(node, env)
+
+    case *parser.PrefixExpression:
+        right := Eval(node.Right, env)
+        if isError(right) {
+            return right
+        }
+        return evalPrefixExpression(node.Operator, right, env)
+
     case *parser.InfixExpression:
         left := Eval(node.Left, env)
         if isError(left) {
             return left
         }
+        if node.Operator == "and" || node.Operator == "or" {
+            return evalBooleanOperation(node, left, env)
+        }
         right := Eval(node.Right, env)
         if isError(right) {
             return right
         }
-        return evalInfixExpression(node.Operator, left, right)
-        
+        return evalInfixExpression(node.Operator, left, right, env)
+
+    case *parser.ComparisonExpression:
+        return evalComparisonExpression(node, env)
+
     case *parser.Identifier:
         return evalIdentifier(node, env)
-        
-    case *parser.AssignmentStatement:
-        val := Eval(node.Value, env)
-        if isError(val) {
-            return val
+
+    case *parser.AttributeExpression:
+        obj := Eval(node.Object, env)
+        if isError(obj) {
+            return obj
+        }
+        return getAttribute(env, obj, node.Name)
+
+    case *parser.IndexExpression:
+        left := Eval(node.Left, env)
+        if isError(left) {
+            return left
         }
-        return env.Set(node.Name.Value, val)
+        index := Eval(node.Index, env)
+        if isError(index) {
+            return index
+        }
+        return getItem(env, left, index)
+
+    case *parser.SliceExpression:
+        return evalSliceExpression(node, env)
+
+    case *parser.YieldExpression:
+        return evalYieldExpression(node, env)
+
+    case *parser.GeneratorExpression:
+        return evalGeneratorExpression(node, env)
 
     case *parser.CallExpression:
         function := Eval(node.Function, env)
         if isError(function) {
             return function
         }
-        args := evalExpressions(node.Arguments, env)
-        if len(args) == 1 && isError(args[0]) {
-            return args[0]
+        args, kwargs, err := evalCallArguments(node, function, env)
+        if err != nil {
+            return err
         }
-        return applyFunction(function, args)
+        return applyFunction(env, function, args, kwargs)
     default:
         return newError("unknown node type")
     }
@@ -152,118 +641,1328 @@ func Eval(node interface{}, env *Environment) Object {
 // Programs are just a series of statements. Like my winning streak.
 func evalProgram(program *parser.Program, env *Environment) Object {
     var result Object = NULL
-    
+
     for _, statement := range program.Statements {
+        env.interp.closeAbandoned(env)
         result = Eval(statement, env)
-        
-        if isError(result) {
+
+        switch result := result.(type) {
+        case *Error:
             return result
+        case *ReturnValue:
+            return syntaxError("'return' outside function")
+        case *LoopControl:
+            return syntaxError("'%s' outside loop", result.Inspect())
         }
     }
-    
+
     return result
 }
 
-// Look up identifiers. I always know who I'm dealing with.
-func evalIdentifier(node *parser.Identifier, env *Environment) Object {
-    if builtin, ok := builtins[node.Value]; ok {
-        return builtin
+// Blocks run until something tells them to stop. Returns, errors, breaks - I respect all three.
+func evalBlock(statements []parser.Statement, env *Environment) Object {
+    for _, statement := range statements {
+        result := Eval(statement, env)
+        switch result.(type) {
+        case *Error, *ReturnValue, *LoopControl:
+            return result
+        }
+    }
+    return NULL
+}
+
+func evalIfStatement(node *parser.IfStatement, env *Environment) Object {
+    condition := Eval(node.Condition, env)
+    if isError(condition) {
+        return condition
     }
-    
-    if val, ok := env.Get(node.Value); ok {
-        return val
+    ok, err := truthy(env, condition)
+    if err != nil {
+        return err
     }
+    if ok {
+        return evalBlock(node.Consequence, env)
+    }
+    return evalBlock(node.Alternative, env)
+}
+
+func evalWhileStatement(node *parser.WhileStatement, env *Environment) Object {
+    for {
+        if err := env.safePoint(); err != nil {
+            return err
+        }
+        condition := Eval(node.Condition, env)
+        if isError(condition) {
+            return condition
+        }
+        ok, err := truthy(env, condition)
+        if err != nil {
+            return err
+        }
+        if !ok {
+            break
+        }
 
-    return newError("identifier not found: %s", node.Value)
+        result := evalBlock(node.Body, env)
+        switch result := result.(type) {
+        case *Error, *ReturnValue:
+            return result
+        case *LoopControl:
+            if result.Break {
+                return NULL
+            }
+        }
+    }
+    return evalBlock(node.Else, env)
 }
 
-// Evaluate expressions. I do this with witnesses all the time.
-func evalExpressions(exps []parser.Expression, env *Environment) []Object {
-    var result []Object
-    
-    for _, e := range exps {
-        evaluated := Eval(e, env)
-        if isError(evaluated) {
-            return []Object{evaluated}
+func evalForStatement(node *parser.ForStatement, env *Environment) Object {
+    iterable := Eval(node.Iterable, env)
+    if isError(iterable) {
+        return iterable
+    }
+    iterator := getIter(env, iterable)
+    if isError(iterator) {
+        return iterator
+    }
+
+    for {
+        if err := env.safePoint(); err != nil {
+            return err
+        }
+        item, ok := iterNext(env, iterator)
+        if !ok {
+            break
+        }
+        if isError(item) {
+            return item
+        }
+        if err := assign(node.Target, item, env); err != nil {
+            return err
+        }
+
+        result := evalBlock(node.Body, env)
+        switch result := result.(type) {
+        case *Error, *ReturnValue:
+            return result
+        case *LoopControl:
+            if result.Break {
+                return NULL
+            }
         }
-        result = append(result, evaluated)
     }
-    
-    return result
+    return evalBlock(node.Else, env)
 }
 
-// Apply functions. Like applying pressure to get what I want.
-func applyFunction(fn Object, args []Object) Object {
-    switch fn := fn.(type) {
-    case *Builtin:
-        return fn.Fn(args...)
-    default:
-        return newError("not a function: %s", fn.Type())
+// evalWithItems runs a with statement: the first item's manager wraps the rest
+func evalWithItems(node *parser.WithStatement, items []*parser.WithItem, env *Environment) Object {
+    if len(items) == 0 {
+        return evalBlock(node.Body, env)
+    }
+    item := items[0]
+    manager := Eval(item.Context, env)
+    if isError(manager) {
+        return manager
     }
+    return runWith(env, manager, func(value Object) Object {
+        if item.Target != nil {
+            if err := assign(item.Target, value, env); err != nil {
+                return err
+            }
+        }
+        return evalWithItems(node, items[1:], env)
+    })
 }
 
-// Is it an error? I need to know before I proceed.
-func isError(obj Object) bool {
-    if obj != nil && obj.Type() == ERROR_OBJ {
-        return true
+// runWith is the context manager protocol around body: __enter__, then body
+// with what it returned, then __exit__ - which gets to swallow an exception
+func runWith(env *Environment, manager Object, body func(value Object) Object) Object {
+    cls := typeOf(manager)
+    enter := cls.lookupName("__enter__")
+    if enter == nil {
+        return typeError("'%s' object does not support the context manager protocol", cls.Name)
     }
-    return false
+    exit := cls.lookupName("__exit__")
+    if exit == nil {
+        return typeError("'%s' object does not support the context manager protocol (missed __exit__ method)", cls.Name)
+    }
+    value := callMethod(env, enter, manager)
+    if isError(value) {
+        return value
+    }
+    if exit = descriptorGet(env, exit, manager, cls); isError(exit) {
+        return exit
+    }
+
+    result := body(value)
+    err, ok := result.(*Error)
+    if !ok {
+        if exited := applyFunction(env, exit, []Object{NULL, NULL, NULL}, nil); isError(exited) {
+            return exited
+        }
+        return result
+    }
+
+    exc := err.Exception
+    env.interp.pushHandled(exc)
+    suppress := applyFunction(env, exit, []Object{exc.Class, exc, tracebackOrNone(exc)}, nil)
+    env.interp.popHandled()
+    if exitErr, ok := suppress.(*Error); ok {
+        chainContext(exitErr.Exception, exc)
+        return exitErr
+    }
+    swallowed, truthErr := truthy(env, suppress)
+    if truthErr != nil {
+        return truthErr
+    }
+    if swallowed {
+        return NULL
+    }
+    return err
 }
 
-// Evaluate operations between values. Math never lies.
-func evalInfixExpression(operator string, left Object, right Object) Object {
-    switch {
-    case left.Type() == INTEGER_OBJ && right.Type() == INTEGER_OBJ:
-        return evalIntegerInfixExpression(operator, left.(*Integer), right.(*Integer))
-    case left.Type() == STRING_OBJ && right.Type() == STRING_OBJ:
-        return evalStringInfixExpression(operator, left.(*String), right.(*String))
-    default:
-        return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+func tracebackOrNone(e *Exception) Object {
+    if e.Traceback == nil {
+        return NULL
+    }
+    return e.Traceback
+}
+
+// evalImportStatement binds `import a.b` to a, and `import a.b as c` to a.b itself
+func evalImportStatement(node *parser.ImportStatement, env *Environment) Object {
+    for _, alias := range node.Names {
+        if _, err := importModule(env, alias.Name); err != nil {
+            return err
+        }
+        parts := strings.Split(alias.Name, ".")
+        top, _ := env.interp.modules.GetStr(parts[0])
+        if alias.Alias == "" {
+            env.bind(parts[0], top)
+            continue
+        }
+        module := top
+        for _, part := range parts[1:] {
+            if module = importFrom(env, module, part); isError(module) {
+                return module
+            }
+        }
+        env.bind(alias.Alias, module)
     }
+    return NULL
 }
 
-// Integer operations. Clean, precise, and final. Like my arguments in court.
-func evalIntegerInfixExpression(operator string, left *Integer, right *Integer) Object {
-    leftVal := left.Value
-    rightVal := right.Value
+// evalFromImportStatement binds names out of a module, loading the
+// submodules among them
+func evalFromImportStatement(node *parser.FromImportStatement, env *Environment) Object {
+    fullname, err := resolveName(env, node.Module, node.Level)
+    if err != nil {
+        return err
+    }
+    module, err := importModule(env, fullname)
+    if err != nil {
+        return err
+    }
 
-    leftInt, leftErr := strconv.Atoi(leftVal)
-    rightInt, rightErr := strconv.Atoi(rightVal)
-    
-    if leftErr != nil || rightErr != nil {
-        return newError("invalid integer operation: %s %s %s", leftVal, operator, rightVal)
+    if node.Names == nil {
+        names, err := publicNames(env, module)
+        if err != nil {
+            return err
+        }
+        for _, name := range names {
+            value := getAttribute(env, module, name)
+            if isError(value) {
+                return value
+            }
+            env.bind(name, value)
+        }
+        return NULL
     }
 
-    var result int
-    switch operator {
-    case "+":
-        result = leftInt + rightInt
-    case "-":
-        result = leftInt - rightInt
-    case "*":
-        result = leftInt * rightInt
-    case "/":
-        if rightInt == 0 {
-            return newError("division by zero")
+    names := make([]string, len(node.Names))
+    for i, alias := range node.Names {
+        names[i] = alias.Name
+    }
+    if err := importSubmodules(env, module, fullname, names); err != nil {
+        return err
+    }
+    for _, alias := range node.Names {
+        value := importFrom(env, module, alias.Name)
+        if isError(value) {
+            return value
+        }
+        if alias.Alias != "" {
+            env.bind(alias.Alias, value)
+        } else {
+            env.bind(alias.Name, value)
         }
-        result = leftInt / rightInt
-    default:
-        return newError("unknown operator: %s", operator)
     }
-    
-    return &Integer{Value: strconv.Itoa(result)}
+    return NULL
 }
 
-// String operations. Sometimes words are more powerful than numbers.
-func evalStringInfixExpression(operator string, left *String, right *String) Object {
-    if operator != "+" {
-        return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+// writeStdout sends text to sys.stdout, whatever it has been swapped for
+func writeStdout(env *Environment, text string) *Error {
+    stdout, err := env.interp.stream("stdout")
+    if err != nil {
+        return err
     }
-    
-    return &String{Value: left.Value + right.Value}
+    return writeStream(env, stdout, text)
 }
 
-// Create errors with style and precision.
-func newError(format string, a ...interface{}) *Error {
-    return &Error{Message: fmt.Sprintf(format, a...)}
-}
\ No newline at end of file
+// writeStream is stream.write(text), cutting straight through for our own files
+func writeStream(env *Environment, stream Object, text string) *Error {
+    switch stream := stream.(type) {
+    case *File:
+        if err := stream.check(false, true); err != nil {
+            return err
+        }
+        return stream.writeText(text)
+    case *NullObject:
+        return nil
+    }
+    write := getAttribute(env, stream, "write")
+    if err, ok := write.(*Error); ok {
+        return err
+    }
+    if err, ok := applyFunction(env, write, []Object{&String{Value: text}}, nil).(*Error); ok {
+        return err
+    }
+    return nil
+}
+
+// flushStream is stream.flush(), for print(flush=True) and input()
+func flushStream(env *Environment, stream Object) *Error {
+    if stream == NULL {
+        return nil
+    }
+    flush := getAttribute(env, stream, "flush")
+    if err, ok := flush.(*Error); ok {
+        return err
+    }
+    if err, ok := applyFunction(env, flush, nil, nil).(*Error); ok {
+        return err
+    }
+    return nil
+}
+
+// evalTryStatement - I don't get caught. But when my clients do, I have a plan.
+func evalTryStatement(node *parser.TryStatement, env *Environment) Object {
+    result := evalBlock(node.Body, env)
+    if err, ok := result.(*Error); ok {
+        chainContext(err.Exception, env.interp.handled())
+        if node.Star {
+            result = evalExceptStarHandlers(node, err, env)
+        } else {
+            result = evalExceptHandlers(node, err, env)
+        }
+    } else if result == NULL {
+        result = evalBlock(node.Else, env)
+    }
+
+    if node.Finally != nil {
+        var pending *Exception
+        if err, ok := result.(*Error); ok {
+            pending = err.Exception
+            env.interp.pushHandled(pending)
+        }
+        final := evalBlock(node.Finally, env)
+        if pending != nil {
+            env.interp.popHandled()
+        }
+        // A finally that raises, returns or jumps overrides whatever was going on
+        if final != NULL {
+            if err, ok := final.(*Error); ok {
+                chainContext(err.Exception, pending)
+            }
+            return final
+        }
+    }
+    return result
+}
+
+func evalExceptHandlers(node *parser.TryStatement, err *Error, env *Environment) Object {
+    for _, handler := range node.Handlers {
+        if handler.Type != nil {
+            types, typeErr := evalExceptType(handler.Type, false, env)
+            if typeErr != nil {
+                chainContext(typeErr.Exception, err.Exception)
+                return typeErr
+            }
+            if ok, _ := classCheck("except", err.Exception.Class, types); !ok {
+                continue
+            }
+        }
+        return runExceptHandler(handler, err.Exception, env)
+    }
+    return err
+}
+
+// except* clauses each take their share of an exception group; whatever
+// they raise, and whatever nobody handled, goes back up together
+func evalExceptStarHandlers(node *parser.TryStatement, err *Error, env *Environment) Object {
+    orig := err.Exception
+    rest := orig
+    raised := []*Exception{}
+    for _, handler := range node.Handlers {
+        types, typeErr := evalExceptType(handler.Type, true, env)
+        if typeErr != nil {
+            chainContext(typeErr.Exception, orig)
+            return typeErr
+        }
+        var match *Exception
+        match, rest, typeErr = exceptStarMatch(env, rest, types)
+        if typeErr != nil {
+            return typeErr
+        }
+        if match == nil {
+            continue
+        }
+        if result, ok := runExceptHandler(handler, match, env).(*Error); ok {
+            raised = append(raised, result.Exception)
+        }
+    }
+    if rest != nil {
+        raised = append(raised, rest)
+    }
+
+    exc, reraiseErr := reraiseStar(env, orig, raised)
+    if reraiseErr != nil {
+        return reraiseErr
+    }
+    if exc == nil {
+        return NULL
+    }
+    return &Error{Exception: exc, traced: true}
+}
+
+// evalExceptType checks what an except clause names is something you can catch
+func evalExceptType(expr parser.Expression, star bool, env *Environment) (Object, *Error) {
+    types := Eval(expr, env)
+    if err, ok := types.(*Error); ok {
+        return nil, err
+    }
+    classes := []Object{types}
+    if tuple, ok := types.(*Tuple); ok {
+        classes = tuple.Elements
+    }
+    for _, cls := range classes {
+        if !isExceptionClass(cls) {
+            return nil, typeError("catching classes that do not inherit from BaseException is not allowed")
+        }
+        if star && cls.(*Class).isSubclass(baseExceptionGroupType) {
+            return nil, typeError("catching ExceptionGroup with except* is not allowed. Use except instead.")
+        }
+    }
+    return types, nil
+}
+
+func runExceptHandler(handler *parser.ExceptHandler, exc *Exception, env *Environment) Object {
+    if handler.Name != "" {
+        env.bind(handler.Name, exc)
+    }
+    env.interp.pushHandled(exc)
+    result := evalBlock(handler.Body, env)
+    env.interp.popHandled()
+    if err, ok := result.(*Error); ok {
+        chainContext(err.Exception, exc)
+    }
+    if handler.Name != "" {
+        env.unbind(handler.Name) // the traceback would keep the whole frame alive
+    }
+    return result
+}
+
+// evalRaiseStatement - when I make a move, everybody knows about it
+func evalRaiseStatement(node *parser.RaiseStatement, env *Environment) Object {
+    if node.Exception == nil {
+        if exc := env.interp.handled(); exc != nil {
+            return &Error{Exception: exc, traced: true}
+        }
+        return runtimeError("No active exception to reraise")
+    }
+
+    value := Eval(node.Exception, env)
+    if isError(value) {
+        return value
+    }
+    exc, err := exceptionFromRaise(value, env)
+    if err != nil {
+        return err
+    }
+
+    if node.Cause != nil {
+        value := Eval(node.Cause, env)
+        if isError(value) {
+            return value
+        }
+        if value == NULL {
+            exc.Cause = nil
+        } else {
+            cause, err := exceptionFromRaise(value, env)
+            if err != nil {
+                if err.matches(typeErrorType) && !isExceptionClass(value) {
+                    return typeError("exception causes must derive from BaseException")
+                }
+                return err
+            }
+            exc.Cause = cause
+        }
+        exc.SuppressContext = true
+    }
+
+    setContext(exc, env.interp.handled())
+    return &Error{Exception: exc}
+}
+
+// exceptionFromRaise turns what follows raise into an exception, instantiating classes
+func exceptionFromRaise(value Object, env *Environment) (*Exception, *Error) {
+    if isExceptionClass(value) {
+        instance := applyFunction(env, value, nil, nil)
+        if err, ok := instance.(*Error); ok {
+            return nil, err
+        }
+        exc, ok := instance.(*Exception)
+        if !ok {
+            return nil, typeError("calling %s should have returned an instance of BaseException, not %s",
+                value.(*Class).Name, typeName(instance))
+        }
+        return exc, nil
+    }
+    if exc, ok := value.(*Exception); ok {
+        return exc, nil
+    }
+    return nil, typeError("exceptions must derive from BaseException")
+}
+
+// assign binds a value to a target: a name, attribute, subscript or a tuple to unpack
+func assign(target parser.Expression, value Object, env *Environment) *Error {
+    if err := assignTo(target, value, env); err != nil {
+        locate(err, env, target)
+        return err
+    }
+    return nil
+}
+
+func assignTo(target parser.Expression, value Object, env *Environment) *Error {
+    switch target := target.(type) {
+    case *parser.Identifier:
+        env.bind(target.Value, value)
+        return nil
+    case *parser.AttributeExpression:
+        obj := Eval(target.Object, env)
+        if err, ok := obj.(*Error); ok {
+            return err
+        }
+        return setAttribute(env, obj, target.Name, value)
+    case *parser.IndexExpression:
+        obj := Eval(target.Left, env)
+        if err, ok := obj.(*Error); ok {
+            return err
+        }
+        index := Eval(target.Index, env)
+        if err, ok := index.(*Error); ok {
+            return err
+        }
+        return setItem(env, obj, index, value)
+    case *parser.TupleLiteral:
+        return unpackInto(target.Elements, value, env)
+    case *parser.ListLiteral:
+        return unpackInto(target.Elements, value, env)
+    }
+    return syntaxError("cannot assign to expression")
+}
+
+// evalAugmentedAssignment is target op= value. The target's parts are
+// evaluated once, and __iop__ gets the first word, so a list grows in place.
+func evalAugmentedAssignment(node *parser.AugmentedAssignStatement, env *Environment) Object {
+    var current Object
+    var store func(result Object) *Error
+    switch target := node.Target.(type) {
+    case *parser.Identifier:
+        current = evalIdentifier(target, env)
+        store = func(result Object) *Error {
+            env.bind(target.Value, result)
+            return nil
+        }
+    case *parser.AttributeExpression:
+        obj := Eval(target.Object, env)
+        if isError(obj) {
+            return obj
+        }
+        current = getAttribute(env, obj, target.Name)
+        store = func(result Object) *Error { return setAttribute(env, obj, target.Name, result) }
+    case *parser.IndexExpression:
+        obj := Eval(target.Left, env)
+        if isError(obj) {
+            return obj
+        }
+        index := Eval(target.Index, env)
+        if isError(index) {
+            return index
+        }
+        current = getItem(env, obj, index)
+        store = func(result Object) *Error { return setItem(env, obj, index, result) }
+    default:
+        return syntaxError("illegal expression for augmented assignment")
+    }
+    if isError(current) {
+        return locate(current, env, node.Target)
+    }
+
+    value := Eval(node.Value, env)
+    if isError(value) {
+        return value
+    }
+    result := inplaceOperation(env, node.Operator, current, value)
+    if isError(result) {
+        return result
+    }
+    if err := store(result); err != nil {
+        return locate(err, env, node.Target)
+    }
+    return NULL
+}
+
+// evalAssertStatement raises AssertionError when the test fails. Running
+// optimized, the test isn't even evaluated.
+func evalAssertStatement(node *parser.AssertStatement, env *Environment) Object {
+    if env.interp.optimize {
+        return NULL
+    }
+    test := Eval(node.Test, env)
+    if isError(test) {
+        return test
+    }
+    ok, err := truthy(env, test)
+    if err != nil {
+        return err
+    }
+    if ok {
+        return NULL
+    }
+
+    args := []Object{}
+    if node.Message != nil {
+        message := Eval(node.Message, env)
+        if isError(message) {
+            return message
+        }
+        args = append(args, message)
+    }
+    exc := newException(assertionErrorType, args...)
+    setContext(exc, env.interp.handled())
+    return &Error{Exception: exc}
+}
+
+// deleteTarget is del target: names, attributes, items and slices, and
+// tuples or lists of them
+func deleteTarget(target parser.Expression, env *Environment) *Error {
+    var err *Error
+    switch target := target.(type) {
+    case *parser.Identifier:
+        err = env.unbind(target.Value)
+    case *parser.AttributeExpression:
+        obj := Eval(target.Object, env)
+        if isError(obj) {
+            return obj.(*Error)
+        }
+        err = delAttribute(env, obj, target.Name)
+    case *parser.IndexExpression:
+        obj := Eval(target.Left, env)
+        if isError(obj) {
+            return obj.(*Error)
+        }
+        index := Eval(target.Index, env)
+        if isError(index) {
+            return index.(*Error)
+        }
+        err = delItem(env, obj, index)
+    case *parser.TupleLiteral:
+        for _, element := range target.Elements {
+            if err := deleteTarget(element, env); err != nil {
+                return err
+            }
+        }
+    case *parser.ListLiteral:
+        for _, element := range target.Elements {
+            if err := deleteTarget(element, env); err != nil {
+                return err
+            }
+        }
+    default:
+        err = syntaxError("cannot delete expression")
+    }
+    if err != nil {
+        locate(err, env, target)
+    }
+    return err
+}
+
+func unpackInto(targets []parser.Expression, value Object, env *Environment) *Error {
+    items, err := iterableToSlice(env, value)
+    if err != nil {
+        if typeOf(value).lookupName("__iter__") == nil {
+            return typeError("cannot unpack non-iterable %s object", typeName(value))
+        }
+        return err
+    }
+    if len(items) < len(targets) {
+        return valueError("not enough values to unpack (expected %d, got %d)", len(targets), len(items))
+    }
+    if len(items) > len(targets) {
+        return valueError("too many values to unpack (expected %d)", len(targets))
+    }
+    for i, target := range targets {
+        if err := assign(target, items[i], env); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// Look up identifiers. I always know who I'm dealing with.
+func evalIdentifier(node *parser.Identifier, env *Environment) Object {
+    value, err := env.lookup(node.Value)
+    if err != nil {
+        return err
+    }
+    return value
+}
+
+// Evaluate expressions. I do this with witnesses all the time.
+func evalExpressions(exps []parser.Expression, env *Environment) []Object {
+    result := []Object{}
+
+    for _, e := range exps {
+        evaluated := Eval(e, env)
+        if isError(evaluated) {
+            return []Object{evaluated}
+        }
+        result = append(result, evaluated)
+    }
+
+    return result
+}
+
+func evalIntegerLiteral(node *parser.IntegerLiteral) Object {
+    value, ok := new(big.Int).SetString(node.Value, 0)
+    if !ok {
+        return syntaxError("invalid integer literal: %s", node.Value)
+    }
+    return &Integer{Value: value}
+}
+
+func evalDictLiteral(node *parser.DictLiteral, env *Environment) Object {
+    dict := NewDict()
+    for i, keyNode := range node.Keys {
+        key := Eval(keyNode, env)
+        if isError(key) {
+            return key
+        }
+        value := Eval(node.Values[i], env)
+        if isError(value) {
+            return value
+        }
+        if err := dict.Set(env, key, value); err != nil {
+            return err
+        }
+    }
+    return dict
+}
+
+func evalSliceExpression(node *parser.SliceExpression, env *Environment) Object {
+    parts := []Object{NULL, NULL, NULL}
+    for i, part := range []parser.Expression{node.Lower, node.Upper, node.Step} {
+        if part == nil {
+            continue
+        }
+        parts[i] = Eval(part, env)
+        if isError(parts[i]) {
+            return parts[i]
+        }
+    }
+    return &Slice{Start: parts[0], Stop: parts[1], Step: parts[2]}
+}
+
+// Apply functions. Like applying pressure to get what I want.
+func applyFunction(env *Environment, fn Object, args []Object, kwargs *Dict) Object {
+    switch fn := fn.(type) {
+    case *Builtin:
+        if fn.Owner != nil {
+            if len(args) == 0 {
+                return typeError("unbound method %s.%s() needs an argument", fn.Owner.Name, fn.Name)
+            }
+            if !typeOf(args[0]).isSubclass(fn.Owner) {
+                return typeError("descriptor '%s' for '%s' objects doesn't apply to a '%s' object",
+                    fn.Name, fn.Owner.Name, typeName(args[0]))
+            }
+        }
+        return fn.Fn(env, args, kwargs)
+    case *Function:
+        return callFunction(fn, args, kwargs)
+    case *BoundMethod:
+        return applyFunction(env, fn.Function, append([]Object{fn.Self}, args...), kwargs)
+    case *Class:
+        return instantiate(env, fn, args, kwargs)
+    default:
+        call := typeOf(fn).lookupName("__call__")
+        if call == nil {
+            return typeError("'%s' object is not callable", typeName(fn))
+        }
+        return applyFunction(env, call, append([]Object{fn}, args...), kwargs)
+    }
+}
+
+// callFunction runs a def. New scope, new rules, same winner.
+func callFunction(fn *Function, args []Object, kwargs *Dict) Object {
+    env := NewEnclosedEnvironment(fn.Env)
+    env.fn = fn
+    env.scope = fn.Scope
+    if err := bindArguments(fn, env, args, kwargs); err != nil {
+        return err
+    }
+    if fn.Generator {
+        return newGenerator(env, fn.Name, fn.Qualname, func() Object { return evalBlock(fn.Body, env) })
+    }
+
+    result := runFrame(env, fn.Name, func() Object { return evalBlock(fn.Body, env) })
+    switch result := result.(type) {
+    case *ReturnValue:
+        return result.Value
+    case *Error:
+        return result
+    }
+    return NULL
+}
+
+// bindArguments matches positional and keyword arguments to the parameters,
+// checking them in the same order CPython does so the complaints agree
+func bindArguments(fn *Function, env *Environment, args []Object, kwargs *Dict) *Error {
+    sig := fn.Signature
+    names := append(append([]string{}, sig.Parameters...), sig.KeywordOnly...)
+    bound := make([]Object, len(names))
+    copy(bound, args[:min(len(args), len(sig.Parameters))])
+
+    if sig.VarArgs != "" {
+        rest := []Object{}
+        if len(args) > len(sig.Parameters) {
+            rest = append(rest, args[len(sig.Parameters):]...)
+        }
+        env.Set(sig.VarArgs, &Tuple{Elements: rest})
+    }
+    var extra *Dict
+    if sig.KwArgs != "" {
+        extra = NewDict()
+    }
+
+    if kwargs != nil {
+        for _, entry := range kwargs.Entries() {
+            name := entry.Key.(*String).Value
+            index := indexOfName(names[sig.PositionalOnly:], name)
+            if index < 0 {
+                if extra != nil {
+                    extra.SetStr(name, entry.Value)
+                    continue
+                }
+                if passed := positionalOnlyPassed(sig, kwargs); len(passed) > 0 {
+                    return typeError("%s() got some positional-only arguments passed as keyword arguments: '%s'",
+                        fn.Qualname, strings.Join(passed, ", "))
+                }
+                return typeError("%s() got an unexpected keyword argument '%s'", fn.Qualname, name)
+            }
+            index += sig.PositionalOnly
+            if bound[index] != nil {
+                return typeError("%s() got multiple values for argument '%s'", fn.Qualname, name)
+            }
+            bound[index] = entry.Value
+        }
+    }
+
+    if sig.VarArgs == "" && len(args) > len(sig.Parameters) {
+        keywordOnly := 0
+        for _, value := range bound[len(sig.Parameters):] {
+            if value != nil {
+                keywordOnly++
+            }
+        }
+        return tooManyPositional(fn, len(args), keywordOnly)
+    }
+
+    missing := []string{}
+    firstDefault := len(sig.Parameters) - len(fn.Defaults)
+    for i, param := range sig.Parameters {
+        if bound[i] != nil {
+            continue
+        }
+        if i >= firstDefault {
+            bound[i] = fn.Defaults[i-firstDefault]
+        } else {
+            missing = append(missing, "'"+param+"'")
+        }
+    }
+    if len(missing) > 0 {
+        return typeError("%s() missing %d required positional argument%s: %s",
+            fn.Qualname, len(missing), plural(len(missing)), joinNames(missing))
+    }
+
+    for i, param := range sig.KeywordOnly {
+        index := len(sig.Parameters) + i
+        if bound[index] != nil {
+            continue
+        }
+        if fn.KwDefaults != nil {
+            if value, ok := fn.KwDefaults.GetStr(param); ok {
+                bound[index] = value
+                continue
+            }
+        }
+        missing = append(missing, "'"+param+"'")
+    }
+    if len(missing) > 0 {
+        return typeError("%s() missing %d required keyword-only argument%s: %s",
+            fn.Qualname, len(missing), plural(len(missing)), joinNames(missing))
+    }
+
+    for i, name := range names {
+        env.Set(name, bound[i])
+    }
+    if extra != nil {
+        env.Set(sig.KwArgs, extra)
+    }
+    return nil
+}
+
+func indexOfName(names []string, name string) int {
+    for i, n := range names {
+        if n == name {
+            return i
+        }
+    }
+    return -1
+}
+
+// positionalOnlyPassed lists the positional-only parameters given by keyword
+func positionalOnlyPassed(sig *parser.Signature, kwargs *Dict) []string {
+    passed := []string{}
+    for _, name := range sig.Parameters[:sig.PositionalOnly] {
+        if _, ok := kwargs.GetStr(name); ok {
+            passed = append(passed, name)
+        }
+    }
+    return passed
+}
+
+// tooManyPositional words the complaint like "takes from 1 to 2 positional arguments"
+func tooManyPositional(fn *Function, given int, keywordOnly int) *Error {
+    count, defaults := len(fn.Signature.Parameters), len(fn.Defaults)
+    takes, takesPlural := fmt.Sprint(count), plural(count)
+    if defaults > 0 {
+        takes, takesPlural = fmt.Sprintf("from %d to %d", count-defaults, count), "s"
+    }
+    givenText, verb := fmt.Sprint(given), wasWere(given)
+    if keywordOnly > 0 {
+        givenText += fmt.Sprintf(" positional argument%s (and %d keyword-only argument%s)",
+            plural(given), keywordOnly, plural(keywordOnly))
+        verb = "were"
+    }
+    return typeError("%s() takes %s positional argument%s but %s %s given",
+        fn.Qualname, takes, takesPlural, givenText, verb)
+}
+
+// newFunction makes a def or lambda, evaluating its defaults there and then
+func newFunction(env *Environment, name string, sig *parser.Signature, body []parser.Statement, generator bool, scope *parser.Scope) Object {
+    fn := &Function{
+        Name:      name,
+        Qualname:  qualifiedName(name, env),
+        Signature: sig,
+        Body:      body,
+        Env:       closureEnvironment(env),
+        Scope:     scope,
+        Dict:      NewDict(),
+        Generator: generator,
+        Doc:       docstring(body),
+    }
+    if env.classScope {
+        fn.Owner = env
+    } else if outer, _ := env.function(); outer != nil {
+        fn.Owner = outer.Owner
+    }
+    for _, node := range sig.Defaults {
+        value := Eval(node, env)
+        if isError(value) {
+            return value
+        }
+        fn.Defaults = append(fn.Defaults, value)
+    }
+    for i, node := range sig.KwDefaults {
+        if node == nil {
+            continue
+        }
+        value := Eval(node, env)
+        if isError(value) {
+            return value
+        }
+        if fn.KwDefaults == nil {
+            fn.KwDefaults = NewDict()
+        }
+        fn.KwDefaults.SetStr(sig.KeywordOnly[i], value)
+    }
+    return fn
+}
+
+// docstring is the string literal a def or class body opens with, if it
+// does. A lambda's body is a return statement, so it never has one.
+func docstring(body []parser.Statement) Object {
+    if len(body) == 0 {
+        return nil
+    }
+    if stmt, ok := body[0].(*parser.ExpressionStatement); ok {
+        if s, ok := stmt.Expression.(*parser.StringLiteral); ok {
+            return &String{Value: s.Value}
+        }
+    }
+    return nil
+}
+
+// decorate applies the decorators bottom-up, the one nearest the def first
+func decorate(env *Environment, nodes []parser.Expression, decorators []Object, obj Object) Object {
+    for i := len(decorators) - 1; i >= 0; i-- {
+        obj = locate(applyFunction(env, decorators[i], []Object{obj}, nil), env, nodes[i])
+        if isError(obj) {
+            return obj
+        }
+    }
+    return obj
+}
+
+// evalCallArguments evaluates a call's arguments, unpacking *iterables and **mappings
+func evalCallArguments(node *parser.CallExpression, function Object, env *Environment) ([]Object, *Dict, *Error) {
+    args := []Object{}
+    for _, argNode := range node.Arguments {
+        starred, ok := argNode.(*parser.StarredExpression)
+        if !ok {
+            arg := Eval(argNode, env)
+            if err, ok := arg.(*Error); ok {
+                return nil, nil, err
+            }
+            args = append(args, arg)
+            continue
+        }
+        iterable := Eval(starred.Value, env)
+        if err, ok := iterable.(*Error); ok {
+            return nil, nil, err
+        }
+        if cls := typeOf(iterable); cls.lookupName("__iter__") == nil && cls.lookupName("__getitem__") == nil {
+            return nil, nil, typeError("%s argument after * must be an iterable, not %s",
+                functionString(function), typeName(iterable))
+        }
+        err := iterate(env, iterable, func(item Object) *Error {
+            args = append(args, item)
+            return nil
+        })
+        if err != nil {
+            return nil, nil, err
+        }
+    }
+
+    if len(node.Keywords) == 0 {
+        return args, nil, nil
+    }
+    kwargs := NewDict()
+    for _, kw := range node.Keywords {
+        value := Eval(kw.Value, env)
+        if err, ok := value.(*Error); ok {
+            return nil, nil, err
+        }
+        if kw.Name != "" {
+            if _, ok := kwargs.GetStr(kw.Name); ok {
+                return nil, nil, syntaxError("keyword argument repeated: %s", kw.Name)
+            }
+            kwargs.SetStr(kw.Name, value)
+            continue
+        }
+        if err := mergeKeywords(env, kwargs, value, function); err != nil {
+            return nil, nil, err
+        }
+    }
+    return args, kwargs, nil
+}
+
+// mergeKeywords adds the entries of a **mapping to kwargs
+func mergeKeywords(env *Environment, kwargs *Dict, mapping Object, function Object) *Error {
+    var keys []Object
+    if dict, ok := mapping.(*Dict); ok {
+        for _, entry := range dict.Entries() {
+            keys = append(keys, entry.Key)
+        }
+    } else {
+        method := typeOf(mapping).lookupName("keys")
+        if method == nil {
+            return typeError("%s argument after ** must be a mapping, not %s",
+                functionString(function), typeName(mapping))
+        }
+        result := callMethod(env, method, mapping)
+        if err, ok := result.(*Error); ok {
+            return err
+        }
+        err := iterate(env, result, func(key Object) *Error {
+            keys = append(keys, key)
+            return nil
+        })
+        if err != nil {
+            return err
+        }
+    }
+    for _, key := range keys {
+        name, ok := key.(*String)
+        if !ok {
+            return typeError("keywords must be strings")
+        }
+        if _, ok := kwargs.GetStr(name.Value); ok {
+            return typeError("%s got multiple values for keyword argument '%s'", functionString(function), name.Value)
+        }
+        value := getItem(env, mapping, key)
+        if err, ok := value.(*Error); ok {
+            return err
+        }
+        kwargs.SetStr(name.Value, value)
+    }
+    return nil
+}
+
+// functionString names a callable in argument errors: __main__.f(), print() or str.join()
+func functionString(function Object) string {
+    switch fn := function.(type) {
+    case *Function:
+        return fn.Env.moduleName() + "." + fn.Qualname + "()"
+    case *Builtin:
+        if fn.Owner != nil {
+            return fn.Owner.Name + "." + fn.Name + "()"
+        }
+        return fn.Name + "()"
+    case *BoundMethod:
+        return functionString(fn.Function)
+    case *Class:
+        if fn.module() == "builtins" {
+            return fn.qualname() + "()"
+        }
+        return fn.module() + "." + fn.qualname() + "()"
+    }
+    return typeName(function) + " object"
+}
+
+// closureEnvironment is the scope a new def closes over. Class bodies are skipped, like Python does.
+func closureEnvironment(env *Environment) *Environment {
+    for env.classScope {
+        env = env.outer
+    }
+    return env
+}
+
+// qualifiedName builds names like Outer.method or f.<locals>.g
+func qualifiedName(name string, env *Environment) string {
+    if env.classScope {
+        if qualname, ok := env.store["__qualname__"].(*String); ok {
+            return qualname.Value + "." + name
+        }
+    }
+    if fn, _ := env.function(); fn != nil && !env.classScope {
+        return fn.Qualname + ".<locals>." + name
+    }
+    return name
+}
+
+// Evaluate operations between values. Math never lies.
+func evalInfixExpression(operator string, left Object, right Object, env *Environment) Object {
+    return binaryOperation(env, operator, left, right)
+}
+
+// and/or short-circuit and hand back the deciding operand, not a bool
+func evalBooleanOperation(node *parser.InfixExpression, left Object, env *Environment) Object {
+    ok, err := truthy(env, left)
+    if err != nil {
+        return err
+    }
+    if (node.Operator == "and" && !ok) || (node.Operator == "or" && ok) {
+        return left
+    }
+    return Eval(node.Right, env)
+}
+
+func evalPrefixExpression(operator string, right Object, env *Environment) Object {
+    if operator == "not" {
+        ok, err := truthy(env, right)
+        if err != nil {
+            return err
+        }
+        return nativeBool(!ok)
+    }
+    return unaryOperation(env, operator, right)
+}
+
+// Chains like a < b < c evaluate b once and stop at the first false link
+func evalComparisonExpression(node *parser.ComparisonExpression, env *Environment) Object {
+    left := Eval(node.Left, env)
+    if isError(left) {
+        return left
+    }
+
+    var result Object = TRUE
+    for i, operator := range node.Operators {
+        right := Eval(node.Comparators[i], env)
+        if isError(right) {
+            return right
+        }
+        result = compareOperation(env, operator, left, right)
+        if isError(result) || i == len(node.Operators)-1 {
+            return result
+        }
+        ok, err := truthy(env, result)
+        if err != nil {
+            return err
+        }
+        if !ok {
+            return result
+        }
+        left = right
+    }
+    return result
+}
+
+// Is it an error? I need to know before I proceed.
+func isError(obj Object) bool {
+    if obj != nil && obj.Type() == ERROR_OBJ {
+        return true
+    }
+    return false
+}
+
+// Create errors with style and precision.
+func newError(format string, a ...interface{}) *Error {
+    return newErrorKind(systemErrorType, format, a...)
+}
+
+func newErrorKind(cls *Class, format string, a ...interface{}) *Error {
+    message := fmt.Sprintf(format, a...)
+    if message == "" {
+        return &Error{Exception: newException(cls)}
+    }
+    return &Error{Exception: newException(cls, &String{Value: message})}
+}
+
+func typeError(format string, a ...interface{}) *Error {
+    return newErrorKind(typeErrorType, format, a...)
+}
+
+func valueError(format string, a ...interface{}) *Error {
+    return newErrorKind(valueErrorType, format, a...)
+}
+
+func attributeError(format string, a ...interface{}) *Error {
+    return newErrorKind(attributeErrorType, format, a...)
+}
+
+func nameError(format string, a ...interface{}) *Error {
+    return newErrorKind(nameErrorType, format, a...)
+}
+
+func indexError(format string, a ...interface{}) *Error {
+    return newErrorKind(indexErrorType, format, a...)
+}
+
+func zeroDivisionError(format string, a ...interface{}) *Error {
+    return newErrorKind(zeroDivisionErrorType, format, a...)
+}
+
+func overflowError(format string, a ...interface{}) *Error {
+    return newErrorKind(overflowErrorType, format, a...)
+}
+
+func runtimeError(format string, a ...interface{}) *Error {
+    return newErrorKind(runtimeErrorType, format, a...)
+}
+
+func syntaxError(format string, a ...interface{}) *Error {
+    err := newErrorKind(syntaxErrorType, format, a...)
+    err.Exception.Fields["msg"] = &String{Value: fmt.Sprintf(format, a...)}
+    return err
+}
+
+// ParseError is the parser's first complaint as a SyntaxError that knows the
+// file, the line and the columns it was about, so the traceback can put a
+// caret under them
+func ParseError(p *parser.Parser, filename, source string) *Error {
+    err := syntaxError("%s", p.Errors()[0])
+    fields := err.Exception.Fields
+    fields["filename"] = &String{Value: filename}
+    at := p.ErrorPositions()[0]
+    lines := strings.Split(source, "\n")
+    if at.Line < 1 || at.Line > len(lines) {
+        return err
+    }
+    text := lines[at.Line-1]
+    fields["lineno"] = newInt(int64(at.Line))
+    if strings.TrimSpace(text) == "" {
+        return err
+    }
+    column := func(byteOffset int) int64 {
+        return int64(utf8.RuneCountInString(text[:min(max(byteOffset, 0), len(text))]) + 1)
+    }
+    fields["offset"] = newInt(column(at.Column))
+    fields["text"] = &String{Value: text + "\n"}
+    if at.EndLine == at.Line && at.EndColumn > at.Column {
+        fields["end_lineno"] = newInt(int64(at.EndLine))
+        fields["end_offset"] = newInt(column(at.EndColumn))
+    }
+    return err
+}
+
+// keyError carries the missing key itself; str() shows its repr, like Python
+func keyError(key Object) *Error {
+    return &Error{Exception: newException(keyErrorType, key)}
+}
+
+func stopIteration() *Error {
+    return &Error{Exception: newException(stopIterationType)}
+}
+
+func isStopIteration(obj Object) bool {
+    err, ok := obj.(*Error)
+    return ok && err.matches(stopIterationType)
+}
+
+func plural(n int) string {
+    if n == 1 {
+        return ""
+    }
+    return "s"
+}
+
+func wasWere(n int) string {
+    if n == 1 {
+        return "was"
+    }
+    return "were"
+}
+
+// joinNames formats 'a', 'b' and 'c' the way Python's argument errors do
+func joinNames(names []string) string {
+    switch len(names) {
+    case 1:
+        return names[0]
+    case 2:
+        return names[0] + " and " + names[1]
+    }
+    return strings.Join(names[:len(names)-1], ", ") + ", and " + names[len(names)-1]
+}
+
+// Repr renders an object the way the REPL shows it, calling __repr__ where needed
+func Repr(obj Object, env *Environment) string {
+    if err, ok := obj.(*Error); ok {
+        return formatException(env, err.Exception)
+    }
+    repr := reprOf(env, obj)
+    if err, ok := repr.(*Error); ok {
+        return formatException(env, err.Exception)
+    }
+    return repr.(*String).Value
+}
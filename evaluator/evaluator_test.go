@@ -0,0 +1,1458 @@
+package evaluator
+
+import (
+    "context"
+    "fmt"
+    "interpreter/lexer"
+    "interpreter/parser"
+    "os"
+    "path/filepath"
+    "runtime"
+    "strconv"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+)
+
+// testEval runs a program and returns the repr of its last expression
+func testEval(t *testing.T, input string) string {
+    t.Helper()
+    return testEvalIn(t, NewEnvironment(), input)
+}
+
+func testEvalIn(t *testing.T, env *Environment, input string) string {
+    t.Helper()
+    p := parser.New(lexer.New(input))
+    program := p.ParseProgram()
+    if len(p.Errors()) != 0 {
+        t.Fatalf("parser errors for %q: %v", input, p.Errors())
+    }
+    return Repr(Eval(program, env), env)
+}
+
+type evalTest struct {
+    input    string
+    expected string
+}
+
+func runEvalTests(t *testing.T, tests []evalTest) {
+    t.Helper()
+    for _, tt := range tests {
+        if got := testEval(t, tt.input); got != tt.expected {
+            t.Errorf("eval(%q) wrong.\nexpected=%s\ngot=%s", tt.input, tt.expected, got)
+        }
+    }
+}
+
+const vector = `
+class V:
+    def __init__(self, x):
+        self.x = x
+    def __add__(self, other):
+        if isinstance(other, V):
+            return V(self.x + other.x)
+        return NotImplemented
+    def __radd__(self, other):
+        return V(self.x + other)
+    def __eq__(self, other):
+        return isinstance(other, V) and self.x == other.x
+    def __lt__(self, other):
+        return self.x < other.x
+    def __repr__(self):
+        return "V(" + repr(self.x) + ")"
+`
+
+func TestBinaryOperatorDispatch(t *testing.T) {
+    runEvalTests(t, []evalTest{
+        {vector + "V(1) + V(2)", "V(3)"},
+        {vector + "3 + V(2)", "V(5)"},
+        {vector + "V(2) + 3", "TypeError: unsupported operand type(s) for +: 'V' and 'int'"},
+        {vector + "class W(V):\n    def __radd__(self, other):\n        return 'W wins'\nV(1) + W(2)", "'W wins'"},
+        {"1 + 'a'", "TypeError: unsupported operand type(s) for +: 'int' and 'str'"},
+        {"-'a'", "TypeError: bad operand type for unary -: 'str'"},
+        {"7 // -2, 7 % -2, -7.5 // 2, 2 ** -1, 2 ** 100", "(-4, -1, -4.0, 0.5, 1267650600228229401496703205376)"},
+        {"True + True, 1 + 2.5, 10 / 4", "(2, 3.5, 2.5)"},
+        {"1 / 0", "ZeroDivisionError: division by zero"},
+        {"[1] * 3 + [2]", "[1, 1, 1, 2]"},
+        {"[0] * 10**10", "MemoryError"},
+        {"l = [1]\ntry:\n    l *= 10**10\nexcept MemoryError:\n    pass\nl, [] * 10**10, (1,) * -1, 'ab' * 2", "([1], [], (), 'abab')"},
+        {"(1,) * 10**10", "MemoryError"},
+        {"[] * 10**20", "OverflowError: cannot fit 'int' into an index-sized integer"},
+    })
+}
+
+func TestRichComparisons(t *testing.T) {
+    runEvalTests(t, []evalTest{
+        {vector + "V(1) == V(1), V(1) != V(2), V(1) < V(2), V(3) > V(2)", "(True, True, True, True)"},
+        {vector + "V(1) in [V(0), V(1)]", "True"},
+        {"1 == 1.0, 1 < 1.5 < 2, (1, 2) < (1, 3), [] == []", "(True, True, True, True)"},
+        {"object() < object()", "TypeError: '<' not supported between instances of 'object' and 'object'"},
+        {"class A:\n    pass\na = A()\na == a, a == A()", "(True, False)"},
+    })
+}
+
+func TestProtocolBuiltins(t *testing.T) {
+    runEvalTests(t, []evalTest{
+        {"class S:\n    def __len__(self):\n        return 3\nlen(S()), not S()", "(3, False)"},
+        {"class S:\n    def __len__(self):\n        return -1\nlen(S())", "ValueError: __len__() should return >= 0"},
+        {"class B:\n    def __bool__(self):\n        return 1\nnot B()", "TypeError: __bool__ should return bool, returned int"},
+        {"class G:\n    def __getitem__(self, i):\n        if i > 2:\n            return [][0]\n        return i * 10\nx = []\nfor v in G():\n    x.append(v)\nx", "[0, 10, 20]"},
+        {"class C:\n    def __contains__(self, x):\n        return x == 3\n3 in C(), 4 not in C()", "(True, True)"},
+        {"class F:\n    def __call__(self, a):\n        return a * 2\nF()(21)", "42"},
+        {"class R:\n    def __str__(self):\n        return 'str!'\nstr(R())", "'str!'"},
+        {"class H:\n    def __hash__(self):\n        return 7\nhash(H()), hash(-1)", "(7, -2)"},
+        {"class E:\n    def __eq__(self, other):\n        return True\nhash(E())", "TypeError: unhashable type: 'E'"},
+        {"hash((1, 2)), hash(1.5), hash(2 ** 70)", "(-3550055125485641917, 1152921504606846977, 512)"},
+    })
+}
+
+func TestIteratorProtocol(t *testing.T) {
+    input := `
+class Countdown:
+    def __init__(self, n):
+        self.n = n
+    def __iter__(self):
+        return self
+    def __next__(self):
+        if self.n == 0:
+            return next(iter([]))
+        self.n = self.n - 1
+        return self.n
+result = []
+for i in Countdown(3):
+    result.append(i)
+it = iter([1])
+result, next(it), next(it, 'done')
+`
+    runEvalTests(t, []evalTest{
+        {input, "([2, 1, 0], 1, 'done')"},
+        {"iter(5)", "TypeError: 'int' object is not iterable"},
+        {"a, b = [1]", "ValueError: not enough values to unpack (expected 2, got 1)"},
+    })
+}
+
+func TestContainerBuiltins(t *testing.T) {
+    runEvalTests(t, []evalTest{
+        {"{1: 'a', 1.0: 'b', True: 'c'}", "{1: 'c'}"},
+        {"x = [3, 1, 2]\nx.sort()\nx, x[::-1], x[5:]", "([1, 2, 3], [3, 2, 1], [])"},
+        {"{1, 2} | {3}, {1, 2} & {2}", "({1, 2, 3}, {2})"},
+        {"x = []\nx.append(x)\nx", "[[...]]"},
+        {"{}[1]", "KeyError: 1"},
+        {"[1][5]", "IndexError: list index out of range"},
+        {"hash([])", "TypeError: unhashable type: 'list'"},
+    })
+}
+
+func TestExceptions(t *testing.T) {
+    handled := `
+log = []
+try:
+    1 / 0
+except ZeroDivisionError as e:
+    log.append(repr(e))
+else:
+    log.append('else')
+finally:
+    log.append('finally')
+log
+`
+    subclass := `
+class MyError(ValueError):
+    def __init__(self, message, code):
+        super().__init__(message)
+        self.code = code
+try:
+    raise MyError('boom', 3)
+except (TypeError, LookupError):
+    result = 'wrong'
+except Exception as e:
+    result = (type(e).__name__, str(e), e.args, e.code)
+result
+`
+    chained := `
+try:
+    try:
+        {}['k']
+    except KeyError as k:
+        raise RuntimeError('wrapped') from k
+except RuntimeError as r:
+    result = (r.__cause__, r.__context__ is r.__cause__, r.__suppress_context__)
+result
+`
+    context := `
+try:
+    try:
+        1 / 0
+    except:
+        [][1]
+except IndexError as i:
+    result = (i.__context__, i.__cause__)
+result
+`
+    finally := `
+def f():
+    try:
+        return 'body'
+    finally:
+        log.append('cleanup')
+log = []
+f(), log
+`
+    runEvalTests(t, []evalTest{
+        {handled, "[\"ZeroDivisionError('division by zero')\", 'finally']"},
+        {subclass, "('MyError', 'boom', ('boom',), 3)"},
+        {chained, "(KeyError('k'), True, True)"},
+        {context, "(ZeroDivisionError('division by zero'), None)"},
+        {finally, "('body', ['cleanup'])"},
+        {"try:\n    x = 1\nexcept:\n    x = 2\nelse:\n    x = 3\nx", "3"},
+        {"try:\n    raise KeyError('a')\nexcept KeyError as e:\n    pass\ne", "NameError: name 'e' is not defined"},
+        {"raise", "RuntimeError: No active exception to reraise"},
+        {"raise 5", "TypeError: exceptions must derive from BaseException"},
+        {"raise ValueError from 3", "TypeError: exception causes must derive from BaseException"},
+        {"try:\n    1 / 0\nexcept 3:\n    pass", "TypeError: catching classes that do not inherit from BaseException is not allowed"},
+        {"try:\n    1 / 0\nfinally:\n    x = 1", "ZeroDivisionError: division by zero"},
+        {"class E(Exception):\n    pass\nraise E('custom')", "E: custom"},
+        {"str(KeyError('a')), str(OSError(2, 'No such file', 'x.txt')), type(OSError(2, 'x')).__name__",
+            "(\"'a'\", \"[Errno 2] No such file: 'x.txt'\", 'FileNotFoundError')"},
+        {"StopIteration(5).value, SystemExit(1, 2).code, issubclass(KeyError, LookupError)", "(5, (1, 2), True)"},
+        {"ValueError(x=1)", "TypeError: ValueError() takes no keyword arguments"},
+    })
+}
+
+func TestExceptionGroups(t *testing.T) {
+    group := "eg = ExceptionGroup('many', [ValueError(1), TypeError(2), ExceptionGroup('inner', [ValueError(3)])])\n"
+    star := group + `
+log = []
+try:
+    raise eg
+except* ValueError as e:
+    log.append(e)
+except* TypeError as e:
+    log.append(e)
+log
+`
+    rest := group + `
+try:
+    try:
+        raise eg
+    except* ValueError:
+        raise KeyError('new')
+except ExceptionGroup as x:
+    result = x
+result
+`
+    naked := `
+try:
+    try:
+        raise ValueError('naked')
+    except* ValueError:
+        raise
+except ExceptionGroup as x:
+    result = x
+result
+`
+    runEvalTests(t, []evalTest{
+        {group + "str(eg), eg.split(ValueError)",
+            "('many (3 sub-exceptions)', (ExceptionGroup('many', [ValueError(1), ExceptionGroup('inner', [ValueError(3)])]), " +
+                "ExceptionGroup('many', [TypeError(2)])))"},
+        {group + "eg.subgroup(KeyError), eg.subgroup(TypeError)", "(None, ExceptionGroup('many', [TypeError(2)]))"},
+        {star, "[ExceptionGroup('many', [ValueError(1), ExceptionGroup('inner', [ValueError(3)])]), ExceptionGroup('many', [TypeError(2)])]"},
+        {rest, "ExceptionGroup('', [KeyError('new'), ExceptionGroup('many', [TypeError(2)])])"},
+        {naked, "ExceptionGroup('', (ValueError('naked'),))"},
+        {"type(BaseExceptionGroup('b', [ValueError()])), type(BaseExceptionGroup('b', [KeyboardInterrupt()]))",
+            "(<class 'ExceptionGroup'>, <class 'BaseExceptionGroup'>)"},
+        {"ExceptionGroup('x', [KeyboardInterrupt()])", "TypeError: Cannot nest BaseExceptions in an ExceptionGroup"},
+        {"ExceptionGroup('x', [])", "ValueError: second argument (exceptions) must be a non-empty sequence"},
+        {"try:\n    pass\nexcept* ExceptionGroup:\n    pass\n1", "1"},
+        {"try:\n    1 / 0\nexcept* ExceptionGroup:\n    pass",
+            "TypeError: catching ExceptionGroup with except* is not allowed. Use except instead."},
+    })
+}
+
+func TestGenerators(t *testing.T) {
+    counter := `
+def count(n):
+    i = 0
+    while i < n:
+        yield i
+        i = i + 1
+    return 'done'
+`
+    echo := `
+def echo():
+    received = yield 'ready'
+    while True:
+        received = yield repr(received)
+e = echo()
+next(e), e.send(1), e.send('x')
+`
+    cleanup := `
+log = []
+def guarded():
+    try:
+        yield 1
+        yield 2
+    except ValueError as v:
+        yield 'caught ' + str(v)
+    finally:
+        log.append('cleanup')
+g = guarded()
+result = [next(g), g.throw(ValueError('boom'))]
+g.close()
+result, log, g.gi_frame
+`
+    delegation := `
+def inner():
+    x = yield 1
+    yield x
+    return 'inner result'
+def outer():
+    r = yield from inner()
+    yield r
+    yield from [10, 20]
+o = outer()
+[next(o), o.send('sent')] + list(o)
+`
+    runEvalTests(t, []evalTest{
+        {counter + "list(count(3))", "[0, 1, 2]"},
+        {counter + "g = count(1)\nnext(g)\ntry:\n    next(g)\nexcept StopIteration as e:\n    result = e.value\nresult", "'done'"},
+        {echo, "('ready', '1', \"'x'\")"},
+        {cleanup, "([1, 'caught boom'], ['cleanup'], None)"},
+        {delegation, "[1, 'sent', 'inner result', 10, 20]"},
+        {"list((a, b) for a in [1, 2] for b in [3, 4] if a != b - 3)", "[(1, 3), (2, 3), (2, 4)]"},
+        {"g = (x for x in [1])\ng.__name__, type(g).__name__, list(g), list(g)", "('<genexpr>', 'generator', [1], [])"},
+        {"def f():\n    yield 1\n    raise StopIteration\nlist(f())", "RuntimeError: generator raised StopIteration"},
+        {"def f():\n    try:\n        yield 1\n    except GeneratorExit:\n        yield 2\ng = f()\nnext(g)\ng.close()",
+            "RuntimeError: generator ignored GeneratorExit"},
+        {"def f():\n    yield next(g)\ng = f()\nnext(g)", "ValueError: generator already executing"},
+        {"def f():\n    yield 1\nf().send(2)", "TypeError: can't send non-None value to a just-started generator"},
+        {"(x for x in 5)", "TypeError: 'int' object is not iterable"},
+    })
+}
+
+func TestLambdasAndConditionals(t *testing.T) {
+    signature := `
+def f(a, b=1, /, c=2, *rest, d, e=7, **kw):
+    return (a, b, c, rest, d, e, kw)
+`
+    runEvalTests(t, []evalTest{
+        {"add = lambda x, y=2: x + y\n(add(1), add(1, y=5), add.__name__)", "(3, 6, '<lambda>')"},
+        {"def outer():\n    return lambda: 0\nouter().__qualname__", "'outer.<locals>.<lambda>'"},
+        {"'a' if 0 else 'b' if 0 else 'c'", "'c'"},
+        {"list(v if v % 2 else -v for v in [1, 2, 3, 4] if v > 1)", "[-2, 3, -4]"},
+        {"compose = lambda f, g: lambda x: f(g(x))\ncompose(lambda x: x + 1, lambda x: x * 2)(5)", "11"},
+        {"list((lambda: (yield 1))())", "[1]"},
+        {signature + "f(1, d=4)", "(1, 1, 2, (), 4, 7, {})"},
+        {signature + "f(1, 2, 3, 4, 5, d=6, z=9)", "(1, 2, 3, (4, 5), 6, 7, {'z': 9})"},
+        {signature + "f.__defaults__, f.__kwdefaults__", "((1, 2), {'e': 7})"},
+        {"f = lambda *args, **kw: (args, kw)\nf(*[1, 2], *(3,), **{'b': 4}, c=5)", "((1, 2, 3), {'b': 4, 'c': 5})"},
+        {"def p(a, /, **kw):\n    return a, kw\np(1, a=2)", "(1, {'a': 2})"},
+        {"def d(x=[]):\n    x.append(1)\n    return len(x)\nd()\nd()", "2"},
+        {"def h(a, b=2): pass\nh(1, 2, 3)", "TypeError: h() takes from 1 to 2 positional arguments but 3 were given"},
+        {"def k(*, c): pass\nk(1, c=2)",
+            "TypeError: k() takes 0 positional arguments but 1 positional argument (and 1 keyword-only argument) were given"},
+        {"def k(*, c): pass\nk()", "TypeError: k() missing 1 required keyword-only argument: 'c'"},
+        {"def g(a, /, b): pass\ng(a=1, b=2)",
+            "TypeError: g() got some positional-only arguments passed as keyword arguments: 'a'"},
+        {"def f(a): pass\nf(*1)", "TypeError: __main__.f() argument after * must be an iterable, not int"},
+        {"def f(a): pass\nf(**1)", "TypeError: __main__.f() argument after ** must be a mapping, not int"},
+        {"def f(**k): pass\nf(c=1, **{'c': 2})", "TypeError: __main__.f() got multiple values for keyword argument 'c'"},
+        {"def f(**k): pass\nf(**{1: 2})", "TypeError: keywords must be strings"},
+    })
+}
+
+func TestDecoratorsAndDescriptors(t *testing.T) {
+    order := `
+order = []
+def mark(name):
+    order.append('eval ' + name)
+    def deco(fn):
+        order.append('apply ' + name)
+        return fn
+    return deco
+@mark('a')
+@mark('b')
+def f():
+    pass
+order
+`
+    account := `
+class Account:
+    def __init__(self, balance):
+        self._balance = balance
+    @property
+    def balance(self):
+        return self._balance
+    @balance.setter
+    def balance(self, value):
+        self._balance = value if value > 0 else 0
+    @staticmethod
+    def fee(amount):
+        return amount // 10
+    @classmethod
+    def empty(cls):
+        return cls(0)
+class Savings(Account):
+    @classmethod
+    def empty(cls):
+        account = super().empty()
+        account._balance = 1
+        return account
+    @property
+    def balance(self):
+        return super().balance * 2
+`
+    descriptor := `
+class Field:
+    def __set_name__(self, owner, name):
+        self.name = name
+    def __get__(self, obj, owner):
+        return self if obj is None else obj.__dict__.get(self.name, 0)
+    def __set__(self, obj, value):
+        obj.__dict__[self.name] = value * 2
+class Point:
+    x = Field()
+p = Point()
+p.x = 5
+`
+    runEvalTests(t, []evalTest{
+        {order, "['eval a', 'eval b', 'apply b', 'apply a']"},
+        {"def twice(fn):\n    return lambda *a: fn(fn(*a))\n@twice\ndef inc(x):\n    return x + 1\ninc(1)", "3"},
+        {"@(lambda cls: cls.__name__)\nclass A:\n    pass\nA", "'A'"},
+        {account + "a = Account(5)\na.balance = -3\na.balance, Account.fee(25), a.fee(50)", "(0, 2, 5)"},
+        {account + "type(Account.empty()).__name__, type(Savings.empty()).__name__, Savings.empty().balance", "('Account', 'Savings', 2)"},
+        {account + "Savings(1).balance = 2", "AttributeError: property 'balance' of 'Savings' object has no setter"},
+        {account + "type(Account.__dict__['fee']).__name__, type(Account.balance).__name__", "('staticmethod', 'property')"},
+        {descriptor + "p.x, p.__dict__, Point.x.name", "(10, {'x': 10}, 'x')"},
+        {"class N:\n    def __get__(self, obj, owner):\n        return 'class'\nclass H:\n    n = N()\nh = H()\nh.n = 'instance'\nh.n, H.n",
+            "('instance', 'class')"},
+        {"staticmethod()", "TypeError: staticmethod expected 1 argument, got 0"},
+        {"property(None).__get__(1)", "AttributeError: property of 'int' object has no getter"},
+    })
+}
+
+func TestAbandonedGeneratorsStop(t *testing.T) {
+    before := runtime.NumGoroutine()
+    // Nothing runs on this interpreter again, so once the host lets go of it
+    // the generators can't wait for a safe point and get stopped outright
+    testEvalIn(t, NewInterpreter().Environment, `
+def forever():
+    while True:
+        yield 1
+n = 0
+while n < 50:
+    g = forever()
+    next(g)
+    n = n + 1
+`)
+    deadline := time.Now().Add(5 * time.Second)
+    for runtime.NumGoroutine() > before+1 && time.Now().Before(deadline) {
+        runtime.GC()
+        time.Sleep(10 * time.Millisecond)
+    }
+    if n := runtime.NumGoroutine(); n > before+1 {
+        t.Errorf("abandoned generators left %d goroutines running", n-before)
+    }
+}
+
+func TestAbandonedGeneratorsClose(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "data.txt")
+    if err := os.WriteFile(path, []byte("x"), 0o644); err != nil {
+        t.Fatal(err)
+    }
+    env := NewEnvironment()
+    testEvalIn(t, env, fmt.Sprintf(`
+log = []
+def g():
+    try:
+        yield 1
+    finally:
+        log.append('cleanup')
+def lines(path):
+    with open(path) as f:
+        yield f
+x = g()
+next(x)
+del x
+y = lines(%q)
+fh = next(y)
+del y
+`, path))
+    want := "(['cleanup'], True)"
+    got := ""
+    deadline := time.Now().Add(5 * time.Second)
+    for got != want && time.Now().Before(deadline) {
+        runtime.GC()
+        time.Sleep(10 * time.Millisecond)
+        // every top-level statement is a safe point where they get closed
+        got = testEvalIn(t, env, "log, fh.closed")
+    }
+    if got != want {
+        t.Errorf("abandoned generators were not closed: got %s", got)
+    }
+}
+
+// testTraceback runs a program as a file and returns what Python would print for it
+func testTraceback(t *testing.T, input string) string {
+    t.Helper()
+    env := NewFileEnvironment("test.py")
+    env.RegisterSource("test.py", input)
+    p := parser.New(lexer.New(input))
+    program := p.ParseProgram()
+    if len(p.Errors()) != 0 {
+        return FormatTraceback(ParseError(p, "test.py", input), env)
+    }
+    return FormatTraceback(Eval(program, env), env)
+}
+
+func TestTracebacks(t *testing.T) {
+    nested := `def inner(x):
+    return x / 0
+
+def outer():
+    data = {'a': 1}
+    return [inner(data['a'])]
+
+outer()
+`
+    chained := `def get(d, k):
+    return d[k] * 2
+try:
+    get({}, 'x')
+except KeyError as e:
+    raise ValueError('bad key') from e
+`
+    recursion := `def f(n):
+    return f(n + 1)
+f(0)
+`
+    group := `try:
+    raise ValueError(1)
+except ValueError as e:
+    first = e
+e = ExceptionGroup('many', [first, TypeError(2)])
+e.add_note('see above')
+raise e
+`
+    tests := []evalTest{
+        {nested, `Traceback (most recent call last):
+  File "test.py", line 8, in <module>
+    outer()
+  File "test.py", line 6, in outer
+    return [inner(data['a'])]
+            ^^^^^^^^^^^^^^^^
+  File "test.py", line 2, in inner
+    return x / 0
+           ~~^~~
+ZeroDivisionError: division by zero
+`},
+        {chained, `Traceback (most recent call last):
+  File "test.py", line 4, in <module>
+    get({}, 'x')
+  File "test.py", line 2, in get
+    return d[k] * 2
+           ~^^^
+KeyError: 'x'
+
+The above exception was the direct cause of the following exception:
+
+Traceback (most recent call last):
+  File "test.py", line 6, in <module>
+    raise ValueError('bad key') from e
+ValueError: bad key
+`},
+        {recursion, `Traceback (most recent call last):
+  File "test.py", line 3, in <module>
+    f(0)
+  File "test.py", line 2, in f
+    return f(n + 1)
+           ^^^^^^^^
+  File "test.py", line 2, in f
+    return f(n + 1)
+           ^^^^^^^^
+  File "test.py", line 2, in f
+    return f(n + 1)
+           ^^^^^^^^
+  [Previous line repeated 996 more times]
+RecursionError: maximum recursion depth exceeded
+`},
+        {group, `  + Exception Group Traceback (most recent call last):
+  |   File "test.py", line 7, in <module>
+  |     raise e
+  | ExceptionGroup: many (2 sub-exceptions)
+  | see above
+  +-+---------------- 1 ----------------
+    | Traceback (most recent call last):
+    |   File "test.py", line 2, in <module>
+    |     raise ValueError(1)
+    | ValueError: 1
+    +---------------- 2 ----------------
+    | TypeError: 2
+    +------------------------------------
+`},
+        {"x = 1\nx = x + 1", ""},
+        {"x = 1\ns = 'é' + )\n", `  File "test.py", line 2
+    s = 'é' + )
+              ^
+SyntaxError: unexpected token: )
+`},
+        {"print(1) print(2)", `  File "test.py", line 1
+    print(1) print(2)
+             ^^^^^
+SyntaxError: invalid syntax: unexpected IDENT
+`},
+        {"if True\n    pass\n", `  File "test.py", line 1
+    if True
+           ^
+SyntaxError: expected next token to be :, got INDENT instead
+`},
+        {"def f():\n    def g():\n        nonlocal q\n", `  File "test.py", line 3
+    nonlocal q
+    ^^^^^^^^^^
+SyntaxError: no binding for nonlocal 'q' found
+`},
+    }
+    for _, tt := range tests {
+        if got := testTraceback(t, tt.input); got != tt.expected {
+            t.Errorf("traceback for %q wrong.\nexpected=\n%s\ngot=\n%s", tt.input, tt.expected, got)
+        }
+    }
+}
+
+func TestExitStatus(t *testing.T) {
+    tests := []struct {
+        input  string
+        status int
+        ok     bool
+        stderr string
+    }{
+        {"raise SystemExit(3)", 3, true, ""},
+        {"raise SystemExit", 0, true, ""},
+        {"raise SystemExit(None)", 0, true, ""},
+        {"raise SystemExit('bye')", 1, true, "bye\n"},
+        {"raise SystemExit(1, 2)", 1, true, "(1, 2)\n"},
+        {"class Quit(SystemExit):\n    pass\ntry:\n    raise Quit(True)\nfinally:\n    print('cleanup')", 1, true, ""},
+        {"raise ValueError(3)", 0, false, ""},
+        {"x = 1", 0, false, ""},
+    }
+    for _, tt := range tests {
+        var stdout, stderr strings.Builder
+        env := NewEnvironment()
+        env.SetStdout(&stdout)
+        env.SetStderr(&stderr)
+        program := parser.New(lexer.New(tt.input)).ParseProgram()
+        status, ok := ExitStatus(Eval(program, env), env)
+        if status != tt.status || ok != tt.ok || stderr.String() != tt.stderr {
+            t.Errorf("exit status for %q = %d, %t with stderr %q, want %d, %t with %q", tt.input, status, ok, stderr.String(), tt.status, tt.ok, tt.stderr)
+        }
+    }
+}
+
+func TestWithStatement(t *testing.T) {
+    manager := `
+log = []
+class CM:
+    def __init__(self, name, swallow=False):
+        self.name = name
+        self.swallow = swallow
+    def __enter__(self):
+        log.append('enter ' + self.name)
+        return self.name
+    def __exit__(self, typ, value, tb):
+        log.append('exit ' + self.name + ' ' + (typ.__name__ if typ else 'None'))
+        return self.swallow
+`
+    runEvalTests(t, []evalTest{
+        {manager + "with CM('a') as x, CM('b') as y:\n    log.append(x + y)\nlog",
+            "['enter a', 'enter b', 'ab', 'exit b None', 'exit a None']"},
+        {manager + "with CM('out', True):\n    with CM('in'):\n        1 / 0\nlog",
+            "['enter out', 'enter in', 'exit in ZeroDivisionError', 'exit out ZeroDivisionError']"},
+        {manager + "with (CM('p') as p,\n      CM('q') as q,):\n    pass\np + q", "'pq'"},
+        {manager + "def f():\n    with CM('r'):\n        return 1\nf(), log", "(1, ['enter r', 'exit r None'])"},
+        {"with 5:\n    pass", "TypeError: 'int' object does not support the context manager protocol"},
+        {"class A:\n    def __enter__(self):\n        pass\nwith A():\n    pass",
+            "TypeError: 'A' object does not support the context manager protocol (missed __exit__ method)"},
+        {"class A:\n    def __enter__(self):\n        pass\n    def __exit__(self, *a):\n        raise KeyError(1)\n" +
+            "try:\n    with A():\n        raise ValueError(2)\nexcept KeyError as e:\n    r = e.__context__\nr", "ValueError(2)"},
+    })
+}
+
+func TestContextlib(t *testing.T) {
+    tag := `
+import contextlib
+log = []
+@contextlib.contextmanager
+def tag(name):
+    log.append('<' + name + '>')
+    try:
+        yield name
+    finally:
+        log.append('</' + name + '>')
+`
+    tests := []evalTest{
+        {tag + "with tag('a') as t:\n    log.append(t)\nlog", "['<a>', 'a', '</a>']"},
+        {tag + "try:\n    with tag('b'):\n        raise ValueError('x')\nexcept ValueError as e:\n    log.append(e.args[0])\nlog",
+            "['<b>', '</b>', 'x']"},
+        {tag + "@tag('d')\ndef f(x):\n    log.append(x)\n    return x * 2\n[f(1), f(2), log, f.__name__]",
+            "[2, 4, ['<d>', 1, '</d>', '<d>', 2, '</d>'], 'f']"},
+        {"@contextlib.contextmanager\ndef g():\n    if False:\n        yield\nwith g():\n    pass",
+            "RuntimeError: generator didn't yield"},
+        {"@contextlib.contextmanager\ndef g():\n    yield\n    yield\nwith g():\n    pass",
+            "RuntimeError: generator didn't stop"},
+        {"@contextlib.contextmanager\ndef g():\n    try:\n        yield\n    except KeyError:\n        pass\nwith g():\n    {}[1]\n'swallowed'",
+            "'swallowed'"},
+        {"with contextlib.suppress(KeyError, IndexError):\n    [][0]\n'ok'", "'ok'"},
+        {"with contextlib.suppress(KeyError):\n    1 / 0", "ZeroDivisionError: division by zero"},
+        {"class T:\n    def close(self):\n        self.closed = True\nwith contextlib.closing(T()) as t:\n    pass\nt.closed", "True"},
+        {"class Buf:\n    def __init__(self):\n        self.parts = []\n    def write(self, s):\n        self.parts.append(s)\n" +
+            "b = Buf()\nwith contextlib.redirect_stdout(b):\n    print('hidden')\nb.parts", "['hidden', '\\n']"},
+        {"log = []\nwith contextlib.ExitStack() as stack:\n    stack.callback(log.append, 1)\n    stack.callback(log.append, 2)\nlog", "[2, 1]"},
+        {"class S:\n    def __enter__(self):\n        return 'in'\n    def __exit__(self, *a):\n        return True\n" +
+            "with contextlib.ExitStack() as stack:\n    x = stack.enter_context(S())\n    1 / 0\nx", "'in'"},
+        {"log = []\nstack = contextlib.ExitStack()\nstack.callback(log.append, 1)\nother = stack.pop_all()\nstack.close()\n" +
+            "before = list(log)\nother.close()\n(before, log)", "([], [1])"},
+        {"contextlib.ExitStack().enter_context(5)", "TypeError: 'builtins.int' object does not support the context manager protocol"},
+        {"with contextlib.nullcontext(7) as n:\n    pass\nn", "7"},
+    }
+    for i := range tests {
+        if !strings.HasPrefix(tests[i].input, tag) {
+            tests[i].input = "import contextlib\n" + tests[i].input
+        }
+    }
+    runEvalTests(t, tests)
+}
+
+func TestImports(t *testing.T) {
+    dir := t.TempDir()
+    files := map[string]string{
+        "pkg/__init__.py":      "VERSION = 1\nfrom .helpers import helper\n",
+        "pkg/helpers.py":       "def helper():\n    return 'helped'\n_hidden = 1\nshown = 2\n",
+        "pkg/sub/__init__.py":  "from .. import VERSION\n__all__ = ['deep']\ndef deep():\n    return 'deep'\n",
+        "pkg/sub/leaf.py":      "from . import deep\nvalue = deep() + '!'\n",
+        "first.py":             "import second\ndef f():\n    return 'f' + second.g()\n",
+        "second.py":            "import first\ndef g():\n    return 'g'\ntry:\n    from first import f\nexcept ImportError as e:\n    early = e.args[0]\n",
+        "broken.py":            "raise ValueError('broken')\n",
+    }
+    for name, source := range files {
+        path := filepath.Join(dir, name)
+        if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+            t.Fatal(err)
+        }
+        if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+            t.Fatal(err)
+        }
+    }
+    initPath := filepath.Join(dir, "pkg", "__init__.py")
+
+    tests := []evalTest{
+        {"import pkg\npkg.VERSION, pkg.helper()", "(1, 'helped')"},
+        {"import pkg.sub.leaf\npkg.sub.leaf.value", "'deep!'"},
+        {"import pkg.sub.leaf as leaf\nleaf.value", "'deep!'"},
+        {"from pkg.helpers import helper as h, shown\nh(), shown", "('helped', 2)"},
+        {"from pkg.helpers import *\nshown", "2"},
+        {"from pkg.helpers import *\n_hidden", "NameError: name '_hidden' is not defined"},
+        {"from pkg.sub import *\ndeep()", "'deep'"},
+        {"from pkg import sub\nsub.deep.__module__", "'pkg.sub'"},
+        {"import sys, pkg\nsys.modules['pkg'] is pkg", "True"},
+        {"def f():\n    import pkg\n    return pkg.VERSION\nf()", "1"},
+        {"import first\nfirst.f()", "'fg'"},
+        {"import first\nfirst.second.early", "\"cannot import name 'f' from partially initialized module 'first' " +
+            "(most likely due to a circular import) (" + filepath.Join(dir, "first.py") + ")\""},
+        {"import nosuch", "ModuleNotFoundError: No module named 'nosuch'"},
+        {"import pkg.helpers.x", "ModuleNotFoundError: No module named 'pkg.helpers.x'; 'pkg.helpers' is not a package"},
+        {"from pkg import nothing", "ImportError: cannot import name 'nothing' from 'pkg' (" + initPath + ")"},
+        {"from . import x", "ImportError: attempted relative import with no known parent package"},
+        {"try:\n    import broken\nexcept ValueError:\n    pass\nimport sys\n'broken' in sys.modules", "False"},
+        {"import pkg\npkg.missing", "AttributeError: module 'pkg' has no attribute 'missing'"},
+        {"import sys\nsys.modules['fake'] = None\nimport fake", "ModuleNotFoundError: import of fake halted; None in sys.modules"},
+        {"__import__('pkg.sub').__name__", "'pkg'"},
+        {"__import__('pkg.sub', fromlist=['leaf']).leaf.value", "'deep!'"},
+        {"from sys import nope", "ImportError: cannot import name 'nope' from 'sys' (unknown location)"},
+    }
+    for _, tt := range tests {
+        env := NewEnvironment()
+        env.AddSearchPath(dir)
+        if got := testEvalIn(t, env, tt.input); got != tt.expected {
+            t.Errorf("eval(%q) wrong.\nexpected=%s\ngot=%s", tt.input, tt.expected, got)
+        }
+    }    // PYTHONPATH is for the command line to pass on, not read behind an embedder's back
+    t.Setenv("PYTHONPATH", dir)
+    if got := testEvalIn(t, NewFileEnvironment("<test>"), "import pkg"); got != "ModuleNotFoundError: No module named 'pkg'" {
+        t.Errorf("PYTHONPATH should not reach a new environment. got=%s", got)
+    }
+}
+
+func TestScopesAndDel(t *testing.T) {
+    runEvalTests(t, []evalTest{
+        {"x = 1\ndef f():\n    y = x\n    x = 2\nf()",
+            "UnboundLocalError: cannot access local variable 'x' where it is not associated with a value"},
+        {"x = 1\ndef f():\n    global x\n    x = 2\nf()\nx", "2"},
+        {"def counter():\n    n = 0\n    def inc():\n        nonlocal n\n        n = n + 1\n        return n\n    return inc\n" +
+            "c = counter()\nc()\nc()", "2"},
+        {"def f():\n    a = 1\n    def g():\n        def h():\n            return a\n        return h\n    return g()()\nf()", "1"},
+        {"def f():\n    def g():\n        return v\n    try:\n        g()\n    except NameError as e:\n        return e.args[0]\n    v = 1\nf()",
+            "\"cannot access free variable 'v' where it is not associated with a value in enclosing scope\""},
+        {"x = 'global'\nclass A:\n    y = x\n    x = 'class'\n    def m(self):\n        return x\n(A.y, A.x, A().m())",
+            "('global', 'class', 'global')"},
+        {"def f():\n    x = 1\n    class C:\n        nonlocal x\n        x = 2\n    return x\nf()", "2"},
+        {"def f():\n    try:\n        1 / 0\n    except ZeroDivisionError as e:\n        pass\n    return e\nf()",
+            "UnboundLocalError: cannot access local variable 'e' where it is not associated with a value"},
+        {"x = 1\ndel x\nx", "NameError: name 'x' is not defined"},
+        {"del nothing", "NameError: name 'nothing' is not defined"},
+        {"def f():\n    del q\nf()", "UnboundLocalError: cannot access local variable 'q' where it is not associated with a value"},
+        {"a = [0, 1, 2, 3, 4, 5]\ndel a[0], a[1:3]\na", "[1, 4, 5]"},
+        {"d = {'a': 1, 'b': 2}\ndel d['a']\nd", "{'b': 2}"},
+        {"d = {}\ndel d['a']", "KeyError: 'a'"},
+        {"del (1, 2)[0]", "TypeError: 'tuple' object doesn't support item deletion"},
+        {"a, b = 1, 2\ndel (a, b)\na", "NameError: name 'a' is not defined"},
+        {"class P:\n    pass\np = P()\np.a = 1\ndel p.a\ndel p.a", "AttributeError: 'P' object has no attribute 'a'"},
+        {"class Q:\n    k = 1\ndel Q.k\nQ.k", "AttributeError: type object 'Q' has no attribute 'k'"},
+        {"del int.real", "TypeError: cannot set 'real' attribute of immutable type 'int'"},
+        {"import collections\ncollections.deque.leak = 1", "TypeError: cannot set 'leak' attribute of immutable type 'collections.deque'"},
+        {"import collections\ndel collections.OrderedDict.popitem", "TypeError: cannot set 'popitem' attribute of immutable type 'collections.OrderedDict'"},
+        {"import datetime\ndatetime.date.today = None", "TypeError: cannot set 'today' attribute of immutable type 'datetime.date'"},
+        {"import functools\nfunctools.partial.leak = 1", "TypeError: cannot set 'leak' attribute of immutable type 'functools.partial'"},
+        {"import collections, contextlib\nclass D(collections.deque):\n    pass\nD.leak = contextlib.suppress.leak = 1\nD.leak, contextlib.suppress.leak", "(1, 1)"},
+        {"import random\nrandom.Random.leak = 1", "TypeError: cannot set 'leak' attribute of immutable type 'random.Random'"},
+        {"class R:\n    def __delattr__(self, name):\n        self.log = name\nr = R()\ndel r.x\nr.log", "'x'"},
+    })
+}
+
+func TestMatchStatement(t *testing.T) {
+    point := "class Point:\n    __match_args__ = ('x', 'y')\n    def __init__(self, x, y):\n        self.x = x\n        self.y = y\n"
+    runEvalTests(t, []evalTest{
+        {"match 3:\n    case 1 | 2:\n        r = 'low'\n    case 3:\n        r = 'three'\nr", "'three'"},
+        {"match [1, 2, 3, 4]:\n    case [first, *middle, last]:\n        r = (first, middle, last)\nr", "(1, [2, 3], 4)"},
+        {"match 'ab':\n    case [a, b]:\n        r = 'seq'\n    case str():\n        r = 'str'\nr", "'str'"},
+        {"match {'kind': 'sq', 'side': 2, 'x': 1}:\n    case {'kind': 'sq', **rest}:\n        r = rest\nr", "{'side': 2, 'x': 1}"},
+        {"match (0, None):\n    case (0, False):\n        r = 'false'\n    case (0, None):\n        r = 'none'\nr", "'none'"},
+        {point + "match Point(1, 1):\n    case Point(x, y) if x != y:\n        r = 'off'\n    case Point(x, y=1) as p:\n        r = (x, p.y)\nr", "(1, 1)"},
+        {"class Color:\n    RED = 1\nmatch 1:\n    case Color.RED:\n        r = 'red'\nr", "'red'"},
+        {"match 5:\n    case int(n) if n > 3:\n        r = n\nr", "5"},
+        {"match 1:\n    case 2:\n        pass\nmatch = 7\nmatch", "7"},
+        {"match [1, 2]:\n    case [a, b] if False:\n        pass\na + b", "3"},
+        {"match 1:\n    case len():\n        pass", "TypeError: called match pattern must be a type"},
+        {point + "match Point(1, 2):\n    case Point(1, 2, 3):\n        pass", "TypeError: Point() accepts 2 positional sub-patterns (3 given)"},
+        {point + "match Point(1, 2):\n    case Point(1, x=2):\n        pass", "TypeError: Point() got multiple sub-patterns for attribute 'x'"},
+        {"class Q:\n    __match_args__ = ['a']\nmatch Q():\n    case Q(1):\n        pass", "TypeError: Q.__match_args__ must be a tuple (got list)"},
+    })
+}
+
+func TestWalrusAssertAndAugmented(t *testing.T) {
+    runEvalTests(t, []evalTest{
+        {"xs = [1, 2, 3]\nif (n := len(xs)) > 2:\n    r = n\nr", "3"},
+        {"i = 0\nwhile (i := i + 1) < 5:\n    pass\ni", "5"},
+        {"list((last := v) for v in [1, 2])\nlast", "2"},
+        {"def f():\n    total = 0\n    list((total := total + v) for v in [1, 2, 3])\n    return total\nf()", "6"},
+        {"x = 1\nx += 2\nx *= 5\nx //= 2\nx **= 2\nx", "49"},
+        {"a = [1]\nb = a\na += [2]\na is b, b", "(True, [1, 2])"},
+        {"t = (1,)\nu = t\nt += (2,)\nt is u, u", "(False, (1,))"},
+        {"d = {'k': 1}\nd['k'] += 1\nd", "{'k': 2}"},
+        {"class P:\n    n = 1\np = P()\np.n -= 3\np.n, P.n", "(-2, 1)"},
+        {"class V:\n    def __iadd__(self, other):\n        return 'iadd'\nv = V()\nv += 1\nv", "'iadd'"},
+        {"def f():\n    u += 1\nf()", "UnboundLocalError: cannot access local variable 'u' where it is not associated with a value"},
+        {"x = 1\nx += 'a'", "TypeError: unsupported operand type(s) for +=: 'int' and 'str'"},
+        {"assert 1 < 2\n'ok'", "'ok'"},
+        {"assert 1 > 2, 'nope'", "AssertionError: nope"},
+        {"try:\n    assert [], [1]\nexcept AssertionError as e:\n    r = e.args\nr", "([1],)"},
+    })
+
+    env := NewEnvironment()
+    env.SetOptimize(true)
+    if result := testEvalIn(t, env, "assert False\n'skipped'"); result != "'skipped'" {
+        t.Errorf("assert should be skipped when optimized. got=%s", result)
+    }
+    // PYTHONOPTIMIZE is the command line's business, not an embedder's
+    t.Setenv("PYTHONOPTIMIZE", "1")
+    if result := testEvalIn(t, NewFileEnvironment("test.py"), "assert False"); result != "AssertionError" {
+        t.Errorf("PYTHONOPTIMIZE should not reach a new environment. got=%s", result)
+    }
+}
+
+func TestStringMethods(t *testing.T) {
+    runEvalTests(t, []evalTest{
+        {"s = 'héllo'\ns[1], s[-1], s[1:4], s[::-1], len(s)", "('é', 'o', 'éll', 'olléh', 5)"},
+        {"'ab' * 3, 2 * 'x', 'b' in 'abc', 'é' > 'z'", "('ababab', 'xx', True, True)"},
+        {"list('añ')", "['a', 'ñ']"},
+        {"'  a b   c '.split(), 'a,b,,c'.split(','), 'a b c'.rsplit(None, 1)", "(['a', 'b', 'c'], ['a', 'b', '', 'c'], ['a b', 'c'])"},
+        {"'a,b,c'.split(',', maxsplit=1), '-'.join(['x', 'y'])", "(['a', 'b,c'], 'x-y')"},
+        {"'xxhixx'.strip('x'), ' a '.lstrip(), 'ab'.replace('', '-')", "('hi', 'a ', '-a-b-')"},
+        {"'hello'.find('l'), 'hello'.rfind('l'), 'hello'.find('', 6), 'aaaa'.count('aa')", "(2, 3, -1, 2)"},
+        {"'hello'.startswith(('x', 'he')), 'hello'.endswith('l', 0, 4)", "(True, True)"},
+        {"'straße'.upper(), 'ΟΔΟΣ'.lower(), \"they're\".title(), 'Straße'.casefold()", "('STRASSE', 'οδος', \"They'Re\", 'strasse')"},
+        {"'a=b=c'.partition('='), 'a=b=c'.rpartition('x')", "(('a', '=', 'b=c'), ('', '', 'a=b=c'))"},
+        {"'a\\nb\\r\\nc'.splitlines(), 'a\\nb'.splitlines(True)", "(['a', 'b', 'c'], ['a\\n', 'b'])"},
+        {"'-42'.zfill(5), 'ab'.center(7, '*'), 'a\\tb'.expandtabs(4)", "('-0042', '***ab**', 'a   b')"},
+        {"'x'.ljust(10**10)", "MemoryError"},
+        {"'x'.zfill(10**10)", "MemoryError"},
+        {"'a\\tb'.expandtabs(10**10)", "OverflowError: Python int too large to convert to C int"},
+        {"'²'.isdigit(), '²'.isdecimal(), '½'.isnumeric(), 'Hello World'.istitle(), ''.isascii()", "(True, False, True, True, True)"},
+        {"'héllo'.encode(), 'aé'.encode('ascii', 'replace'), 'é'.encode().decode()", "(b'h\\xc3\\xa9llo', b'a?', 'é')"},
+        {"'abcd'.translate(str.maketrans('ab', 'xy', 'd'))", "'xyc'"},
+        {"class S(str):\n    pass\nS('abc').upper(), S('abc')[1]", "('ABC', 'b')"},
+        {"1 in 'a'", "TypeError: 'in <string>' requires string as left operand, not int"},
+        {"'a'[5]", "IndexError: string index out of range"},
+        {"'a'.join([1])", "TypeError: sequence item 0: expected str instance, int found"},
+        {"'a'.index('z')", "ValueError: substring not found"},
+        {"'a' * 1.5", "TypeError: can't multiply sequence by non-int of type 'float'"},
+        {"'aé'.encode('ascii')", "UnicodeEncodeError: 'ascii' codec can't encode character '\\xe9' in position 1: ordinal not in range(128)"},
+    })
+}
+
+func TestStringIndexingIsConstantTime(t *testing.T) {
+    // s[i] used to decode the whole string every time, so this loop was quadratic
+    start := time.Now()
+    got := testEval(t, `
+s = 'é' * 50000 + 'xyz'
+n = 0
+for i in range(len(s)):
+    if s[i] == 'é':
+        n += 1
+a = 'ab' * 50000
+for i in range(len(a)):
+    c = a[i]
+n, s[-2], s[49998:50002], a[::25000], 'héllo'[::-2], c`)
+    if want := "(50000, 'y', 'ééxy', 'aaaa', 'olh', 'b')"; got != want {
+        t.Errorf("got %s, want %s", got, want)
+    }
+    if elapsed := time.Since(start); elapsed > 3*time.Second {
+        t.Errorf("indexing 150000 characters took %v", elapsed)
+    }
+}
+
+func TestStringFormatting(t *testing.T) {
+    runEvalTests(t, []evalTest{
+        {"'%s %5.2f' % ('a', 3.14159)", "'a  3.14'"},
+        {"'%#x %-4d| %+d %c %%' % (255, 7, 5, 65)", "'0xff 7   | +5 A %'"},
+        {"'%(a)s %(b)r' % {'a': 1, 'b': 'x'}", "\"1 'x'\""},
+        {"'%*d|%.3d' % (4, 1, 5)", "'   1|005'"},
+        {"'{:>{}}'.format('a', 4), '{:{}}|{}'.format(1, 5, 'z'), '{:{}{}}'.format(1, '>', 3), '{!r:>{}}'.format('a', 5)", "('   a', '    1|z', '  1', \"  'a'\")"},
+        {"'{:{}{}}'.format(1, 2, 3)", "'                      1'"},
+        {"format(1234567.891, ',.2f'), format(255, '#010x'), format(1234, '08,')", "('1,234,567.89', '0x000000ff', '0,001,234')"},
+        {"format(123.0, '.3'), format(1e16), format(1000000.0, 'g'), format(-0.0, 'z.1f')", "('1.23e+02', '1e+16', '1e+06', '0.0')"},
+        {"format('abc', '*^7'), format('abc', '.2'), format(True), format(0.25, '.0%')", "('**abc**', 'ab', 'True', '25%')"},
+        {"'{} {}'.format(1, 2), '{1}{0}'.format('a', 'b'), '{x!r:>5}'.format(x='y')", "('1 2', 'ba', \"  'y'\")"},
+        {"'{0[k]} {0[1]} {1.__name__}'.format({'k': 'v', 1: 'one'}, int)", "'v one int'"},
+        {"'{:>{w}.{p}f}|{{}}'.format(3.14159, w=7, p=2)", "'   3.14|{}'"},
+        {"'{n}'.format_map({'n': 1})", "'1'"},
+        {"class P:\n    def __format__(self, spec):\n        return 'P' + spec\n'{:xy}'.format(P())", "'Pxy'"},
+        {"'%d' % 'x'", "TypeError: %d format: a real number is required, not str"},
+        {"'%s %s' % (1,)", "TypeError: not enough arguments for format string"},
+        {"'%s' % (1, 2)", "TypeError: not all arguments converted during string formatting"},
+        {"'%z' % 1", "ValueError: unsupported format character 'z' (0x7a) at index 1"},
+        {"format(1, '.2d')", "ValueError: Precision not allowed in integer format specifier"},
+        {"format('a', '+')", "ValueError: Sign not allowed in string format specifier"},
+        {"format(object(), 'x')", "TypeError: unsupported format string passed to object.__format__"},
+        {"'{} {0}'.format(1, 2)", "ValueError: cannot switch from automatic field numbering to manual field specification"},
+        {"'{} {}'.format(1)", "IndexError: Replacement index 1 out of range for positional args tuple"},
+        {"'{0:{1:{2}}}'.format(1, 2, 3)", "ValueError: Max string recursion exceeded"},
+        {"'%*d' % (10**10, 1)", "MemoryError"},
+        {"'%10000000000d' % 1", "MemoryError"},
+        {"'{:10000000000}'.format(1)", "MemoryError"},
+        {"'%99999999999999999999d' % 1", "ValueError: width too big"},
+        {"'%.*f' % (10**10, 1.5)", "OverflowError: Python int too large to convert to C int"},
+        {"format(1.5, '.10000000000f')", "ValueError: precision too big"},
+    })
+}
+
+func TestBytes(t *testing.T) {
+    runEvalTests(t, []evalTest{
+        {"b = b'ab\\x00\\xff'\nb, len(b), b[1], b[-1], b[1:3]", "(b'ab\\x00\\xff', 4, 98, 255, b'b\\x00')"},
+        {"bytes(2), bytes([104, 105]), bytes('é', 'utf-8'), b'a' b'b', rb'\\n'", "(b'\\x00\\x00', b'hi', b'\\xc3\\xa9', b'ab', b'\\\\n')"},
+        {"b'abc' == bytearray(b'abc'), 98 in b'abc', b'bc' in b'abc', list(b'hi')", "(True, True, True, [104, 105])"},
+        {"bytes(10**10)", "MemoryError"},
+        {"bytearray(10**10)", "MemoryError"},
+        {"bytes(-10**20)", "OverflowError: cannot fit 'int' into an index-sized integer"},
+        {"b'a,b,,c'.split(b','), b'  a b '.split(), b'xxhixx'.strip(b'x'), b'Hi'.upper()", "([b'a', b'b', b'', b'c'], [b'a', b'b'], b'hi', b'HI')"},
+        {"b'\\x01\\x02\\x03'.hex(), b'\\x01\\x02\\x03'.hex(':', 2), bytes.fromhex('de ad'), bytearray.fromhex('0a')", "('010203', '01:0203', b'\\xde\\xad', bytearray(b'\\n'))"},
+        {"ba = bytearray(b'hello')\nba[0] = 72\nba.append(33)\nba[1:3] = b'EYY'\ndel ba[-1]\nba, ba.pop(), len(ba)", "(bytearray(b'HEYYl'), 111, 5)"},
+        {"'café'.encode('ascii', 'replace'), b'caf\\xc3\\xa9'.decode(), str(b'\\xe9', 'latin-1')", "(b'caf?', 'café', 'é')"},
+        {"s = b'a\\xff'.decode('utf-8', 'surrogateescape')\ns, len(s), s.encode('utf-8', 'surrogateescape')", "('a\\udcff', 2, b'a\\xff')"},
+        {"ba = bytearray(b'abc')\nm = memoryview(ba)\nm[0] = 65\nba[2] = 67\nview = m[1:]\nview[0] = 66\nba, m.tobytes(), view.tolist(), m.readonly", "(bytearray(b'ABC'), b'ABC', [66, 67], False)"},
+        {"m = memoryview(b'xyz')\nm[::2].tobytes(), m == b'xyz', hash(m) == hash(b'xyz')", "(b'xz', True, True)"},
+        {"b'abc'[3]", "IndexError: index out of range"},
+        {"bytes('abc')", "TypeError: string argument without an encoding"},
+        {"bytes([256])", "ValueError: bytes must be in range(0, 256)"},
+        {"b'a' + 'b'", "TypeError: can't concat str to bytes"},
+        {"bytes.fromhex('0 1')", "ValueError: non-hexadecimal number found in fromhex() arg at position 1"},
+        {"b'\\xe2\\x28\\xa1'.decode()", "UnicodeDecodeError: 'utf-8' codec can't decode byte 0xe2 in position 0: invalid continuation byte"},
+        {"'\\udcff'.encode()", "UnicodeEncodeError: 'utf-8' codec can't encode character '\\udcff' in position 0: surrogates not allowed"},
+        {"memoryview(b'abc')[0] = 1", "TypeError: cannot modify read-only memory"},
+        {"m = memoryview(bytearray(b'ab'))\nm[0:2] = b'x'", "ValueError: memoryview assignment: lvalue and rvalue have different structures"},
+        {"m = memoryview(b'ab')\nm.release()\nlen(m)", "ValueError: operation forbidden on released memoryview object"},
+        {"hash(bytearray())", "TypeError: unhashable type: 'bytearray'"},
+    })
+}
+
+func TestComplex(t *testing.T) {
+    runEvalTests(t, []evalTest{
+        {"1j, 2+3j, -1j, 1.5-2j, complex(-0.0, 0), 1e20j", "(1j, (2+3j), (-0-1j), (1.5-2j), (-0+0j), 1e+20j)"},
+        {"(1+2j)*(3-4j), (1+2j)/(3-4j), (1+2j)**2, 2**(1+1j), 1 + 1j, 3 / 2j", "((11+2j), (-0.2+0.4j), (-3+4j), (1.5384778027279442+1.2779225526272695j), (1+1j), -1.5j)"},
+        {"(-8) ** (1/3), 8 ** (1/3), abs(3+4j), abs(-5), (1+2j).conjugate()", "((1.0000000000000002+1.7320508075688772j), 2.0, 5.0, 5, (1-2j))"},
+        {"(3+4j).real, (3+4j).imag, (5).imag, (2.5).real, (7).denominator, True.real", "(3.0, 4.0, 0, 2.5, 1, 1)"},
+        {"complex('1+2j'), complex(' (-1.5e3-2_0J) '), complex('-j'), complex(1j, 1j), complex(2.0, -0.0)", "((1+2j), (-1500-20j), -1j, (-1+1j), (2-0j))"},
+        {"1+0j == 1, 2j != 2, hash(2+0j) == hash(2), hash(1+1j), bool(0j)", "(True, True, True, 1000004, False)"},
+        {"class C:\n    def __complex__(self):\n        return 3+1j\ncomplex(C())", "(3+1j)"},
+        {"match -1-2j:\n    case 1+2j:\n        r = 'a'\n    case -1-2j:\n        r = 'b'\nr", "'b'"},
+        {"import cmath\ncmath.sqrt(-1), cmath.exp(1j*cmath.pi), cmath.log(8, 2), cmath.polar(1j), cmath.acos(2)", "(1j, (-1+1.2246467991473532e-16j), (3+0j), (1.0, 1.5707963267948966), -1.3169578969248166j)"},
+        {"import cmath\ncmath.isinf(cmath.infj), cmath.isnan(cmath.nanj), cmath.isclose(1+1j, 1+1.0000000001j)", "(True, True, True)"},
+        {"import numbers\nisinstance(True, numbers.Number), isinstance(1.5, numbers.Integral), isinstance(1j, numbers.Complex), issubclass(int, numbers.Rational)", "(True, False, True, True)"},
+        {"import numbers\n@numbers.Real.register\nclass N:\n    pass\nisinstance(N(), numbers.Complex), isinstance(N(), numbers.Integral)", "(True, False)"},
+        {"complex('1+')", "ValueError: complex() arg is a malformed string"},
+        {"complex('1', 2)", "TypeError: complex() can't take second arg if first is a string"},
+        {"complex([])", "TypeError: complex() first argument must be a string or a number, not 'list'"},
+        {"1j < 2j", "TypeError: '<' not supported between instances of 'complex' and 'complex'"},
+        {"1/0j", "ZeroDivisionError: complex division by zero"},
+        {"0j ** -1", "ZeroDivisionError: 0.0 to a negative or complex power"},
+        {"abs('x')", "TypeError: bad operand type for abs(): 'str'"},
+        {"import cmath\ncmath.log(0)", "ValueError: math domain error"},
+        {"import cmath\ncmath.exp(1000)", "OverflowError: math range error"},
+        {"import cmath\ncmath.sqrt('x')", "TypeError: must be real number, not str"},
+        {"format(1+2j, '.2f'), '{:>20}'.format(1.5-2j), format(3j, '^10'), format(-0.0-2j, '')", "('1.00+2.00j', '            (1.5-2j)', '    3j    ', '(-0-2j)')"},
+        {"format(1234567.891+0.5j, ',.2f'), format(1e20+1e-5j, 'g'), format(1+2j, '+.1e'), format(2j, '.3'), format(1.5+0j, '.3')", "('1,234,567.89+0.50j', '1e+20+1e-05j', '+1.0e+00+2.0e+00j', '2j', '(1.5+0j)')"},
+        {"format(complex(float('inf'), float('nan')), 'E'), format(-0.0+0j, 'z.1f'), format(1+2j, '#.0f'), format(2.5-1j, 'n')", "('INF+NANj', '0.0+0.0j', '1.+2.j', '2.5-1j')"},
+        {"format(1+2j, '010')", "ValueError: Zero padding is not allowed in complex format specifier"},
+        {"format(1+2j, '=10')", "ValueError: '=' alignment flag is not allowed in complex format specifier"},
+        {"format(1+2j, '%')", "ValueError: Unknown format code '%' for object of type 'complex'"},
+    })
+}
+
+func TestMath(t *testing.T) {
+    runEvalTests(t, []evalTest{
+        {"import math\nmath.pi, math.tau, math.inf, math.sqrt(2), math.exp(1), math.log(2**2000, 2), math.log10(10**400), math.log2(1024)", "(3.141592653589793, 6.283185307179586, inf, 1.4142135623730951, 2.718281828459045, 2000.0000000000002, 400.0, 10.0)"},
+        {"import math\nmath.sin(math.pi/6), math.acos(0.5), math.atan2(1, 1), math.cosh(1), math.gamma(0.5), math.lgamma(10), math.hypot(1, 2, 2), math.dist([1, 2, 3], [4, 6, 15])", "(0.49999999999999994, 1.0471975511965979, 0.7853981633974483, 1.5430806348152437, 1.7724538509055159, 12.801827480081467, 3.0, 13.0)"},
+        {"import math\nmath.floor(-2.5), math.ceil(2.1), math.trunc(-2.7), math.floor(10**30), math.fabs(-3), math.fmod(-7, 3), math.copysign(1, -0.0), math.frexp(8), math.ldexp(3, 4), math.modf(-3.25)", "(-3, 3, -2, 1000000000000000000000000000000, 3.0, -1.0, -1.0, (0.5, 4), 48.0, (-0.25, -3.0))"},
+        {"import math\nmath.gcd(12, 18, -24), math.lcm(4, -6), math.isqrt(10**50), math.comb(10, 3), math.perm(5), math.factorial(25), math.comb(10**20, 2)", "(6, 12, 10000000000000000000000000, 120, 120, 15511210043330985984000000, 4999999999999999999950000000000000000000)"},
+        {"import math\nmath.fsum([0.1]*10), sum([0.1]*10), math.prod([1, 2, 3, 4]), math.prod([1, 2], start=1.5), math.isclose(1.0, 1.0 + 1e-10), math.isclose(0, 1e-10, abs_tol=1e-9)", "(1.0, 0.9999999999999999, 24, 3.0, True, True)"},
+        {"import math\nclass F:\n    def __floor__(self):\n        return 'floored'\n    def __float__(self):\n        return 2.5\nmath.floor(F()), math.ceil(F())", "('floored', 3)"},
+        {"import math\nmath.sqrt(-1)", "ValueError: math domain error"},
+        {"import math\nmath.exp(1000)", "OverflowError: math range error"},
+        {"import math\nmath.log(10, 1)", "ZeroDivisionError: float division by zero"},
+        {"import math\nmath.fsum([1e308, 1e308, -1e308])", "OverflowError: intermediate overflow in fsum"},
+        {"import math\nmath.factorial(-1)", "ValueError: factorial() not defined for negative values"},
+        {"import math\nmath.comb(10**30, 10**20)", "OverflowError: min(n - k, k) must not exceed 9223372036854775807"},
+        {"import math\nmath.gcd(1.5)", "TypeError: 'float' object cannot be interpreted as an integer"},
+        {"import math\nmath.trunc('x')", "TypeError: type str doesn't define __trunc__ method"},
+        {"import math\nmath.sqrt()", "TypeError: math.sqrt() takes exactly one argument (0 given)"},
+        {"import math\nmath.dist([0, 0], [3])", "ValueError: both points must have the same number of dimensions"},
+    })
+}
+
+func TestJSON(t *testing.T) {
+    runEvalTests(t, []evalTest{
+        {"import json\njson.loads('{\"a\": [1, 2.5, null, true], \"b\": {\"c\": \"\\\\u00e9\"}, \"n\": -Infinity}')", "{'a': [1, 2.5, None, True], 'b': {'c': 'é'}, 'n': -inf}"},
+        {"import json\njson.dumps({'a': [1, 2.5, None, True], 3: 'é', None: (1,)}), json.dumps('é', ensure_ascii=False)", "('{\"a\": [1, 2.5, null, true], \"3\": \"\\\\u00e9\", \"null\": [1]}', '\"é\"')"},
+        {"import json\njson.dumps({'b': 1, 'a': [1, {}]}, indent=2, sort_keys=True)", "'{\\n  \"a\": [\\n    1,\\n    {}\\n  ],\\n  \"b\": 1\\n}'"},
+        {"import json\njson.dumps([1], indent=10**10)", "MemoryError"},
+        {"import json\njson.dumps([1], indent=10**20)", "OverflowError: cannot fit 'int' into an index-sized integer"},
+        {"import json\njson.dumps([1, {'a': 2}], separators=(',', ':')), json.dumps(float('nan')), json.dumps({1j: 1}, skipkeys=True)", "('[1,{\"a\":2}]', 'NaN', '{}')"},
+        {"import json\njson.dumps({1, 2}, default=sorted)", "'[1, 2]'"},
+        {"import json\nclass E(json.JSONEncoder):\n    def default(self, o):\n        return [o.real, o.imag]\njson.dumps([1j], cls=E)", "'[[0.0, 1.0]]'"},
+        {"import json\njson.loads('{\"a\": 1, \"b\": 2.5}', object_pairs_hook=list, parse_float=str)", "[('a', 1), ('b', '2.5')]"},
+        {"import json\njson.loads('{\"a\": {\"b\": 1}}', object_hook=len), json.loads(b'[1]'), json.JSONDecoder().raw_decode('[1] x')", "(1, [1], ([1], 3))"},
+        {"import json\ntry:\n    json.loads('[1,\\n 2,,]')\nexcept json.JSONDecodeError as e:\n    r = (e.msg, e.pos, e.lineno, e.colno)\nr", "('Expecting value', 7, 2, 4)"},
+        {"import json\njson.loads('\"\\\\ud800\"'), json.loads('\"\\\\udc00x\"'), json.loads('[\"\\\\ud83d\\\\ude00\", \"\\\\ud800\\\\u0041\", NaN]')", "('\\ud800', '\\udc00x', ['😀', '\\ud800A', nan])"},
+        {"import json\njson.loads('{\"\\\\udfff\": 1}'), json.dumps(json.loads('\"\\\\ud800\"'))", "({'\\udfff': 1}, '\"\\\\ud800\"')"},
+        {"import json\njson.loads('\"\\\\ud800\\\\uzzzz\"')", "json.decoder.JSONDecodeError: Invalid \\uXXXX escape: line 1 column 9 (char 8)"},
+        {"import json\njson.loads('{\"a\" 1}')", "json.decoder.JSONDecodeError: Expecting ':' delimiter: line 1 column 6 (char 5)"},
+        {"import json\njson.loads('\"abc')", "json.decoder.JSONDecodeError: Unterminated string starting at: line 1 column 1 (char 0)"},
+        {"import json\njson.loads('[1] x')", "json.decoder.JSONDecodeError: Extra data: line 1 column 5 (char 4)"},
+        {"import json\njson.loads(5)", "TypeError: the JSON object must be str, bytes or bytearray, not int"},
+        {"import json\njson.dumps(object())", "TypeError: Object of type object is not JSON serializable"},
+        {"import json\nl = []\nl.append(l)\njson.dumps(l)", "ValueError: Circular reference detected"},
+        {"import json\njson.dumps(float('inf'), allow_nan=False)", "ValueError: Out of range float values are not JSON compliant"},
+    })
+}
+
+func TestRe(t *testing.T) {
+    runEvalTests(t, []evalTest{
+        {"import re\nm = re.search(r'(?P<y>\\d{4})-(\\d\\d)', 'on 2024-05-17')\nm.group(0), m['y'], m.groups(), m.span(2), m.groupdict(), m.lastgroup", "('2024-05', '2024', ('2024', '05'), (8, 10), {'y': '2024'}, None)"},
+        {"import re\nre.findall(r'(\\w)(\\d)', 'a1 b2 c'), re.split(r'[,;]\\s*', 'a, b;c', maxsplit=1), re.escape('a.b*c')", "([('a', '1'), ('b', '2')], ['a', 'b;c'], 'a\\\\.b\\\\*c')"},
+        {"import re\nre.sub(r'(\\w+)@(\\w+)', r'\\2 at \\g<1>', 'joe@home'), re.subn(r'\\d', lambda m: str(int(m.group()) * 2), 'a1b2')", "('home at joe', ('a2b4', 2))"},
+        {"import re\nre.compile('a.c', re.I | re.S), re.I | re.M, ~re.I & re.X, re.match('x*', 'xxy')", "(re.compile('a.c', re.IGNORECASE|re.DOTALL), re.IGNORECASE|re.MULTILINE, re.VERBOSE, <re.Match object; span=(0, 2), match='xx'>)"},
+        {"import re\nre.findall(r'(?<=\\$)\\d+(?!\\d|%)', '$12 $5% $7'), re.fullmatch(r'(a|b)\\1+', 'aaa') is not None, re.findall(r'(?i)straße', 'STRASSE Straße')", "(['12', '7'], True, ['Straße'])"},
+        {"import re\nre.findall(rb'\\d+', b'a12b3'), list(m.span() for m in re.finditer(r'x*', 'axb'))", "([b'12', b'3'], [(0, 0), (1, 2), (2, 2), (3, 3)])"},
+        {"import re\ntry:\n    re.compile('ab\\n(c')\nexcept re.error as e:\n    r = (e.msg, e.pos, e.lineno, e.colno)\nr", "('missing ), unterminated subpattern', 3, 2, 1)"},
+        {"import re\nre.compile('a(b')", "re.error: missing ), unterminated subpattern at position 1"},
+        {"import re\nre.search('a', b'a')", "TypeError: cannot use a string pattern on a bytes-like object"},
+        {"import re\nre.compile('(?<=a+)b')", "re.error: look-behind requires fixed-width pattern"},
+        {"import re\nre.findall(r'^\\w+$', 'ab\\ncd\\n', re.M), re.findall(r'^\\w+$', 'ab\\ncd'), re.search('x$', 'x\\ny', re.MULTILINE).span()", "(['ab', 'cd'], [], (0, 1))"},
+        {"import re\nre.match('a.b', 'a\\nb'), re.match('a.b', 'a\\nb', re.S).group(), re.findall('(?s).+', 'a\\nb')", "(None, 'a\\nb', ['a\\nb'])"},
+        {"import re\nre.match('(\\\\d+)  # digits\\n  \\\\s* [.]  # a dot\\n  (\\\\d*)', '12 .5', re.X).groups(), re.match('a b # c', 'ab', re.VERBOSE).group()", "(('12', '5'), 'ab')"},
+        {"import re\nre.findall('[a-c]+', 'xAbCy', re.I), re.findall('[^a-z]+', 'abCD12', re.I), re.fullmatch('[à-ÿ]+', 'Àé', re.I) is not None", "(['AbC'], ['12'], True)"},
+        {"import re\nre.sub(r'\\d+', lambda m: str(int(m.group()) * 2), 'a1b22c'), re.subn(r'(?P<w>\\w)(\\w*)', lambda m: m['w'].upper() + m.group(2), 'hello big world')", "('a2b44c', ('Hello Big World', 3))"},
+        {"import re\nre.split('x*', 'axbc'), re.split('', 'abc'), re.split('(?=b)', 'abab')", "(['', 'a', '', 'b', 'c', ''], ['', 'a', 'b', 'c', ''], ['a', 'ba', 'b'])"},
+        {"import re\nre.sub('x*', '-', 'abxd'), re.sub('', '-', 'abc'), re.subn('a|', 'X', 'bab')", "('-a-b--d-', '-a-b-c-', ('XbXXbX', 4))"},
+        {"import re\nre.findall(r'(<)?\\w+(?(1)>)', '<a> b <c d>'), list(re.fullmatch(r'(?P<q>\")?\\w+(?(q)\"|!)', s) is not None for s in ['\"ab\"', 'ab!', '\"ab!', 'ab\"'])", "(['<', '', '', ''], [True, True, False, False])"},
+        {"import re\nre.match('(a+)+b', 'a' * 16 + 'c'), re.match('(a|aa)*$', 'a' * 20000).end(), re.search('.*x', 'y' * 3000), len(re.match('(?:ab|a)*c', 'ab' * 200000 + 'c').group())", "(None, 20000, None, 400001)"},
+    })
+}
+
+func TestCollections(t *testing.T) {
+    runEvalTests(t, []evalTest{
+        {"from collections import deque\nd = deque([1, 2, 3], maxlen=4)\nd.appendleft(0)\nd.append(4)\nd.rotate(2)\nd, d.popleft(), d.pop(), d[1], len(d), 2 in d", "(deque([4, 1], maxlen=4), 3, 2, 1, 2, False)"},
+        {"from collections import deque\nd = deque('abc')\nd.extendleft('xy')\nd.insert(2, '!')\nd.remove('a')\nd.reverse()\nd, d.index('x'), d.count('b'), list(reversed(d)), d + deque('z'), deque([1]) * 2", "(deque(['c', 'b', '!', 'x', 'y']), 3, 1, ['y', 'x', '!', 'b', 'c'], deque(['c', 'b', '!', 'x', 'y', 'z']), deque([1, 1]))"},
+        {"from collections import deque\ndeque().pop()", "IndexError: pop from an empty deque"},
+        {"from collections import deque\nd = deque([1, 2])\nfor x in d:\n    d.append(x)", "RuntimeError: deque mutated during iteration"},
+        {"from collections import defaultdict\nd = defaultdict(list)\nd['a'].append(1)\nd['b']\nd, d.default_factory, defaultdict(int, a=1) | {'b': 2}", "(defaultdict(<class 'list'>, {'a': [1], 'b': []}), <class 'list'>, defaultdict(<class 'int'>, {'a': 1, 'b': 2}))"},
+        {"from collections import defaultdict\ndefaultdict(None)['x']", "KeyError: 'x'"},
+        {"from collections import OrderedDict\no = OrderedDict(a=1, b=2, c=3)\no.move_to_end('a')\no.move_to_end('c', last=False)\no, o.popitem(last=False), o == OrderedDict(a=1, b=2), OrderedDict(a=1, b=2) == OrderedDict(b=2, a=1), OrderedDict.fromkeys('xy', 0)", "(OrderedDict([('b', 2), ('a', 1)]), ('c', 3), False, False, OrderedDict([('x', 0), ('y', 0)]))"},
+        {"from collections import Counter\nc = Counter('abracadabra')\nc.update({'z': 2})\nc.subtract('aaaa')\nc, c['q'], c.most_common(2), c.total(), sorted(c.elements())", "(Counter({'b': 2, 'r': 2, 'z': 2, 'a': 1, 'c': 1, 'd': 1}), 0, [('b', 2), ('r', 2)], 9, ['a', 'b', 'b', 'c', 'd', 'r', 'r', 'z', 'z'])"},
+        {"from collections import Counter\na, b = Counter(a=3, b=1), Counter(a=1, b=2, c=-1)\na + b, a - b, a | b, a & b, -b, Counter(a=1) <= a, Counter(x=0) == Counter()", "(Counter({'a': 4, 'b': 3}), Counter({'a': 2, 'c': 1}), Counter({'a': 3, 'b': 2}), Counter({'a': 1, 'b': 1}), Counter({'c': 1}), True, True)"},
+        {"from collections import namedtuple\nP = namedtuple('P', 'x y', defaults=[0])\np = P(1)\np, p.x, p[1], p._asdict(), p._replace(y=5), P._make([3, 4]), P._fields, P._field_defaults, p == (1, 0)", "(P(x=1, y=0), 1, 0, {'x': 1, 'y': 0}, P(x=1, y=5), P(x=3, y=4), ('x', 'y'), {'y': 0}, True)"},
+        {"from collections import namedtuple\nclass V(namedtuple('V', 'x y')):\n    def norm(self):\n        return self.x * self.x + self.y * self.y\nmatch V(3, 4):\n    case V(x, y):\n        r = (x, y)\nV(3, 4), V(3, 4).norm(), r", "(V(x=3, y=4), 25, (3, 4))"},
+        {"from collections import namedtuple\nP = namedtuple('P', 'x y')\nP(1, 2, 3)", "TypeError: P.__new__() takes 3 positional arguments but 4 were given"},
+        {"from collections import namedtuple\nP = namedtuple('P', 'x y')\nrepr(P.__new__).startswith('<function P.__new__ at'), repr(P(1, 2).__new__) == repr(P.__new__), P.__new__.__qualname__, P.__new__.__doc__, P.__new__(P, 3, 4)", "(True, True, 'P.__new__', 'Create new instance of P(x, y)', P(x=3, y=4))"},
+        {"from collections import namedtuple\nnamedtuple('P', 'x _y')", "ValueError: Field names cannot start with an underscore: '_y'"},
+        {"from collections import namedtuple\nnamedtuple('R', 'a, def, _c, a', rename=True)._fields", "('a', '_1', '_2', '_3')"},
+        {"from collections import ChainMap\nc = ChainMap({'a': 1}, {'a': 2, 'b': 3})\nc['z'] = 0\nn = c.new_child({'b': 4})\nc, c['a'], c['b'], len(c), list(c), n['b'], n.parents == c, dict(n)", "(ChainMap({'a': 1, 'z': 0}, {'a': 2, 'b': 3}), 1, 3, 3, ['a', 'b', 'z'], 4, True, {'a': 1, 'b': 4, 'z': 0})"},
+        {"from collections import ChainMap\ndel ChainMap({}, {'b': 1})['b']", "KeyError: \"Key not found in the first mapping: 'b'\""},
+    })
+}
+
+func TestItertools(t *testing.T) {
+    runEvalTests(t, []evalTest{
+        {"from itertools import count, cycle, repeat, islice\nlist(islice(count(10, 5), 3)), list(islice(cycle('ab'), 5)), list(repeat('x', 3)), list(islice(count(), 1, 10, 3))", "([10, 15, 20], ['a', 'b', 'a', 'b', 'a'], ['x', 'x', 'x'], [1, 4, 7])"},
+        {"from itertools import count, repeat\nc = count(5)\nnext(c)\nr = repeat('a', 2)\nnext(r)\nc, count(), count(1.5, 2), count(3, 1.0), r, repeat(1, -5), repeat([1])", "(count(6), count(0), count(1.5, 2), count(3, 1.0), repeat('a', 1), repeat(1, 0), repeat([1]))"},
+        {"from itertools import accumulate, chain, compress, starmap, pairwise\nlist(accumulate([1, 2, 3, 4])), list(accumulate([2, 3], lambda a, b: a * b, initial=10)), list(chain('ab', [1])), list(chain.from_iterable(['xy', 'z'])), list(compress('abcd', [1, 0, 1, 1])), list(starmap(pow, [(2, 3), (3, 2)])), list(pairwise('abc'))", "([1, 3, 6, 10], [10, 20, 60], ['a', 'b', 1], ['x', 'y', 'z'], ['a', 'c', 'd'], [8, 9], [('a', 'b'), ('b', 'c')])"},
+        {"from itertools import dropwhile, takewhile, filterfalse\nlist(dropwhile(lambda x: x < 3, [1, 4, 2])), list(takewhile(lambda x: x < 3, [1, 4, 2])), list(filterfalse(None, [0, 1, '', 'a']))", "([4, 2], [1], [0, ''])"},
+        {"from itertools import groupby\nlist((k, list(g)) for k, g in groupby('aabbbac')), list(k for k, g in groupby([1, 3, 2, 4], key=lambda x: x % 2))", "([('a', ['a', 'a']), ('b', ['b', 'b', 'b']), ('a', ['a']), ('c', ['c'])], [1, 0])"},
+        {"from itertools import groupby\ngroups = list(groupby('aab'))\nlist((k, list(g)) for k, g in groups)", "[('a', []), ('b', [])]"},
+        {"from itertools import islice\nislice('abc', -1)", "ValueError: Stop argument for islice() must be None or an integer: 0 <= x <= sys.maxsize."},
+        {"from itertools import tee, zip_longest\na, b = tee(iter([1, 2, 3]))\nnext(a), list(b), list(a), list(zip_longest('abc', 'x', fillvalue='-'))", "(1, [1, 2, 3], [2, 3], [('a', 'x'), ('b', '-'), ('c', '-')])"},
+        {"from itertools import product, permutations\nlist(product('ab', repeat=2)), list(permutations(range(3), 2)), list(product()), list(permutations('ab', 3))", "([('a', 'a'), ('a', 'b'), ('b', 'a'), ('b', 'b')], [(0, 1), (0, 2), (1, 0), (1, 2), (2, 0), (2, 1)], [()], [])"},
+        {"from itertools import combinations, combinations_with_replacement\nlist(combinations('abcd', 3)), list(combinations_with_replacement('ab', 2)), list(combinations('a', 0))", "([('a', 'b', 'c'), ('a', 'b', 'd'), ('a', 'c', 'd'), ('b', 'c', 'd')], [('a', 'a'), ('a', 'b'), ('b', 'b')], [()])"},
+        {"from itertools import batched\nlist(batched('abcde', 2))", "[('a', 'b'), ('c', 'd'), ('e',)]"},
+        {"from itertools import combinations\ncombinations('abc', -1)", "ValueError: r must be non-negative"},
+    })
+}
+
+func TestRandom(t *testing.T) {
+    runEvalTests(t, []evalTest{
+        {"import random\nrandom.seed(42)\nrandom.random(), random.random(), random.getrandbits(32), random.getrandbits(100), random.getrandbits(0)", "(0.6394267984578837, 0.025010755222666936, 1181241943, 882565121070403957190206503824, 0)"},
+        {"import random\nrandom.seed(-12345678901234567890123)\na = random.random()\nrandom.seed('hello')\nb = random.random()\nrandom.seed(b'hello')\na, b, random.random()", "(0.15803717805068784, 0.3537754404730722, 0.3537754404730722)"},
+        {"import random\nrandom.seed(3.5)\na = random.random()\nrandom.seed('hello', version=1)\na, random.random()", "(0.3039190124834461, 0.8180391270568783)"},
+        {"import random\nrandom.seed((1, 2))", "TypeError: The only supported seed types are: None,\nint, float, str, bytes, and bytearray."},
+        {"import random\nrandom.seed(7)\nlist(random.randint(1, 6) for i in range(12)), random.randrange(0, 100, 7), random.randrange(10, 0, -3), random.randrange(2**80)", "([3, 2, 4, 6, 1, 1, 5, 1, 3, 5, 1, 5], 21, 10, 1011074042031020278063962)"},
+        {"import random\nrandom.randrange(5, 5)", "ValueError: empty range for randrange() (5, 5, 0)"},
+        {"import random\nrandom.randrange(1.5)", "ValueError: non-integer arg 1 for randrange()"},
+        {"import random\nrandom.seed(1)\nx = list(range(10))\nrandom.shuffle(x)\nx, random.choice('abcdef'), random.sample(range(100), 5), random.sample(range(10**6), 3), random.sample(['red', 'blue'], counts=[4, 2], k=5)", "([6, 8, 9, 7, 5, 3, 0, 4, 1, 2], 'b', [12, 62, 3, 49, 55], [636944, 799308, 804423], ['red', 'red', 'red', 'blue', 'blue'])"},
+        {"import random\nrandom.choice([])", "IndexError: Cannot choose from an empty sequence"},
+        {"import random\nrandom.sample({1, 2}, 1)", "TypeError: Population must be a sequence.  For dicts or sets, use sorted(d)."},
+        {"import random\nrandom.seed(2)\nrandom.choices('abc', k=5), random.choices('abc', [1, 0, 5], k=6), random.choices('abc', cum_weights=[1, 1, 10], k=4)", "(['c', 'c', 'a', 'a', 'c'], ['c', 'c', 'c', 'c', 'c', 'c'], ['c', 'c', 'c', 'c'])"},
+        {"import random\nrandom.choices('abc', 3)", "TypeError: The number of choices must be a keyword argument: k=3"},
+        {"import random\nrandom.choices('abc', [0, 0, 0])", "ValueError: Total of weights must be greater than zero"},
+        {"import random\nrandom.seed(3)\nrandom.uniform(1, 10), random.gauss(), random.gauss(10, 2), random.randbytes(5)", "(3.141681643827022, -0.9243337520999115, 9.47260750643444, b'\\xfd\\x80\\x9a\\x9ay')"},
+        {"import random\nr = random.Random(99)\ns = r.getstate()\na = list(r.random() for i in range(3))\nr.setstate(s)\na == list(r.random() for i in range(3)), s[0], len(s[1]), s[1][-1], random.Random(99).random() == a[0]", "(True, 3, 625, 624, True)"},
+        {"import random\nrandom.Random().setstate((3, (1, 2), None))", "ValueError: state vector is the wrong size"},
+        {"import random\nrandom.getrandbits(-1)", "ValueError: number of bits must be non-negative"},
+        {"import random\nrandom.randbytes(10**9)", "OverflowError: Python int too large to convert to C int"},
+        {"import random\nrandom.SystemRandom().randbytes(10**10)", "MemoryError"},
+        {"import random\nrandom.SystemRandom().getrandbits(10**20)", "OverflowError: Python int too large to convert to C ssize_t"},
+        {"import random\nclass Half(random.Random):\n    def random(self):\n        return 0.5\nh = Half(3)\nh.randrange(10), h.choice('abcd'), h.uniform(0, 2), h.randint(1, 3)", "(6, 'a', 1.0, 2)"},
+        {"import random\nsr = random.SystemRandom()\nv = sr.random()\n0 <= v < 1, 1 <= sr.randint(1, 6) <= 6, len(sr.randbytes(4)), sr.getrandbits(70) < 2**70, isinstance(sr, random.Random), sr.seed(1)", "(True, True, 4, True, True, None)"},
+        {"import random\nrandom.SystemRandom().getstate()", "NotImplementedError: System entropy source does not have state."},
+    })
+}
+
+func TestFunctools(t *testing.T) {
+    runEvalTests(t, []evalTest{
+        {"from functools import reduce, partial\nreduce(lambda a, b: a * b, [1, 2, 3, 4]), reduce(max, [], 0), partial(int, base=2)('110'), partial(partial(pow, 2), 3).args", "(24, 0, 6, (2, 3))"},
+        {"from functools import reduce\nreduce(lambda a, b: a, [])", "TypeError: reduce() of empty iterable with no initial value"},
+        {"from functools import partial\npartial(max, 1, key=abs)", "functools.partial(<built-in function max>, 1, key=<built-in function abs>)"},
+        {"from functools import wraps\ndef shout(f):\n    @wraps(f)\n    def wrapper(*args):\n        return f(*args).upper()\n    return wrapper\n@shout\ndef greet(name):\n    return 'hi ' + name\ngreet('bob'), greet.__name__, greet.__wrapped__('al')", "('HI BOB', 'greet', 'hi al')"},
+        {"from functools import wraps\ndef shout(f):\n    @wraps(f)\n    def wrapper(*args):\n        return f(*args).upper()\n    return wrapper\n@shout\ndef greet(name):\n    'Say hi to name.'\n    return 'hi ' + name\ngreet.__doc__, greet.__wrapped__.__doc__, greet('al')", "('Say hi to name.', 'Say hi to name.', 'HI AL')"},
+        {"class A:\n    'A doc'\n    def m(self):\n        'm doc'\nclass B(A):\n    pass\ndef f():\n    x = 1\n    'not a docstring'\nA.__doc__, A().m.__doc__, B.__doc__, f.__doc__, (lambda: 'no').__doc__", "('A doc', 'm doc', None, None, None)"},
+        {"from functools import lru_cache\ncalls = []\n@lru_cache(maxsize=2)\ndef sq(x):\n    calls.append(x)\n    return x * x\nsq(1), sq(2), sq(1), sq(3), sq(2), calls, sq.cache_info()", "(1, 4, 1, 9, 4, [1, 2, 3, 2], CacheInfo(hits=1, misses=4, maxsize=2, currsize=2))"},
+        {"from functools import cache\n@cache\ndef fib(n):\n    return n if n < 2 else fib(n - 1) + fib(n - 2)\nfib(90), fib.cache_info().misses", "(2880067194370816120, 91)"},
+        {"from functools import lru_cache\nlru_cache('big')", "TypeError: Expected first argument to be an integer, a callable, or None"},
+        {"from functools import cached_property\nclass C:\n    runs = 0\n    @cached_property\n    def value(self):\n        C.runs += 1\n        return 42\nc = C()\nc.value, c.value, C.runs, c.__dict__", "(42, 42, 1, {'value': 42})"},
+        {"from functools import total_ordering\n@total_ordering\nclass V:\n    def __init__(self, v):\n        self.v = v\n    def __eq__(self, other):\n        return self.v == other.v\n    def __lt__(self, other):\n        return self.v < other.v\nV(1) <= V(1), V(2) > V(1), V(1) >= V(2)", "(True, True, False)"},
+        {"from functools import total_ordering\n@total_ordering\nclass Nothing:\n    pass", "ValueError: must define at least one ordering operation: < > <= >="},
+        {"from functools import singledispatch\n@singledispatch\ndef kind(x):\n    return 'thing'\n@kind.register(int)\ndef _(x):\n    return 'int'\nkind.register(list, lambda x: 'list')\nkind(1), kind(True), kind([]), kind('s')", "('int', 'int', 'list', 'thing')"},
+        {"from functools import cmp_to_key\nsorted(['bb', 'a', 'ccc'], key=cmp_to_key(lambda a, b: len(b) - len(a)))", "['ccc', 'bb', 'a']"},
+    })
+}
+
+func TestTime(t *testing.T) {
+    runEvalTests(t, []evalTest{
+        {"import time\ntime.gmtime(0)", "time.struct_time(tm_year=1970, tm_mon=1, tm_mday=1, tm_hour=0, tm_min=0, tm_sec=0, tm_wday=3, tm_yday=1, tm_isdst=0)"},
+        {"import time\nt = time.gmtime(86400 * 365 + 3661)\nt.tm_year, t.tm_yday, t[3], t.tm_zone, len(t)", "(1971, 1, 1, 'GMT', 9)"},
+        {"import time\ntime.strftime('%Y-%m-%d %H:%M:%S %j %a %b %%', time.gmtime(1234567890))", "'2009-02-13 23:31:30 044 Fri Feb %'"},
+        {"import time\ntime.asctime(time.gmtime(0))", "'Thu Jan  1 00:00:00 1970'"},
+        {"import time\ntime.strptime('2020 10 3', '%Y %W %w')", "time.struct_time(tm_year=2020, tm_mon=3, tm_mday=11, tm_hour=0, tm_min=0, tm_sec=0, tm_wday=2, tm_yday=71, tm_isdst=-1)"},
+        {"import time\ntime.strptime('12 March 2021', '%d %B %Y')[:3]", "(2021, 3, 12)"},
+        {"import time\ntime.strptime('2021', '%d')", "ValueError: unconverted data remains: 21"},
+        {"import time\na = time.monotonic()\ntime.sleep(0.01)\ntime.monotonic() - a >= 0.01, type(time.perf_counter_ns()), time.time() > 1600000000", "(True, <class 'int'>, True)"},
+        {"import time\ntime.sleep(-1)", "ValueError: sleep length must be non-negative"},
+        {"import time\ntime.sleep(1e19)", "OverflowError: timestamp out of range for platform time_t"},
+        {"import time\ntime.sleep(float('inf'))", "OverflowError: timestamp out of range for platform time_t"},
+        {"import time\ntime.sleep(10**30)", "OverflowError: timestamp too large to convert to C _PyTime_t"},
+        {"import time\ntime.sleep(float('nan'))", "ValueError: Invalid value NaN (not a number)"},
+    })
+}
+
+func TestDatetime(t *testing.T) {
+    runEvalTests(t, []evalTest{
+        {"from datetime import timedelta\ntimedelta(days=-1, seconds=5.5), str(timedelta(days=-1, seconds=5.5)), timedelta(weeks=1, hours=-1)", "(datetime.timedelta(days=-1, seconds=5, microseconds=500000), '-1 day, 0:00:05.500000', datetime.timedelta(days=6, seconds=82800))"},
+        {"from datetime import timedelta\nd = timedelta(hours=25, microseconds=7)\nd.days, d.seconds, d.microseconds, d.total_seconds(), str(d)", "(1, 3600, 7, 90000.000007, '1 day, 1:00:00.000007')"},
+        {"from datetime import timedelta\ntimedelta(seconds=2.5e-6), timedelta(minutes=1) * 1.5, timedelta(hours=1) / timedelta(minutes=7), timedelta(hours=1) // 7, -timedelta(1), abs(timedelta(-1))", "(datetime.timedelta(microseconds=2), datetime.timedelta(seconds=90), 8.571428571428571, datetime.timedelta(seconds=514, microseconds=285714), datetime.timedelta(days=-1), datetime.timedelta(days=1))"},
+        {"from datetime import timedelta\ntimedelta(days=1000000000)", "OverflowError: days=1000000000; must have magnitude <= 999999999"},
+        {"from datetime import timedelta\ntimedelta(1) < timedelta(2), timedelta(0) == 0, bool(timedelta(0)), hash(timedelta(1)) == hash(timedelta(hours=24))", "(True, False, False, True)"},
+        {"from datetime import date\nd = date(2020, 2, 29)\nd.isoformat(), d.weekday(), d.isoweekday(), d.toordinal(), d.isocalendar(), d.replace(year=2024), d.ctime()", "('2020-02-29', 5, 6, 737484, datetime.IsoCalendarDate(year=2020, week=9, weekday=6), datetime.date(2024, 2, 29), 'Sat Feb 29 00:00:00 2020')"},
+        {"from datetime import date, timedelta\ndate(2020, 12, 31) + timedelta(1), date(2021, 3, 1) - date(2020, 3, 1), date.fromordinal(1), date.fromisocalendar(2020, 53, 7), date.fromisoformat('2021-06-15')", "(datetime.date(2021, 1, 1), datetime.timedelta(days=365), datetime.date(1, 1, 1), datetime.date(2021, 1, 3), datetime.date(2021, 6, 15))"},
+        {"from datetime import date\ndate(0, 1, 1)", "ValueError: year 0 is out of range"},
+        {"from datetime import date\ndate(2021, 2, 29)", "ValueError: day is out of range for month"},
+        {"from datetime import date\ndate(2020, 1, 1).strftime('%A %d %B %Y week %W'), format(date(2020, 1, 1), '%y')", "('Wednesday 01 January 2020 week 00', '20')"},
+        {"from datetime import time\ntime(13, 5, 7, 1200), time(1, 2).isoformat(), time(1, 2, 3, 4).isoformat('milliseconds'), time.fromisoformat('04:05:06.789'), str(time(23, 59, 59, 999999))", "(datetime.time(13, 5, 7, 1200), '01:02:00', '01:02:03.000', datetime.time(4, 5, 6, 789000), '23:59:59.999999')"},
+        {"from datetime import time\ntime(24)", "ValueError: hour must be in 0..23"},
+        {"from datetime import datetime, timezone, timedelta\ndt = datetime(2020, 1, 2, 3, 4, 5, 123000, tzinfo=timezone.utc)\ndt, str(dt), dt.isoformat(timespec='seconds'), dt.timestamp(), dt.utcoffset(), dt.tzname()", "(datetime.datetime(2020, 1, 2, 3, 4, 5, 123000, tzinfo=datetime.timezone.utc), '2020-01-02 03:04:05.123000+00:00', '2020-01-02T03:04:05+00:00', 1577934245.123, datetime.timedelta(0), 'UTC')"},
+        {"from datetime import datetime\ndatetime.fromisoformat('20200102T030405.123Z')", "datetime.datetime(2020, 1, 2, 3, 4, 5, 123000, tzinfo=datetime.timezone.utc)"},
+        {"from datetime import datetime, timezone, timedelta\ntz = timezone(timedelta(hours=5, minutes=30), 'IST')\ndt = datetime(2021, 1, 1, tzinfo=timezone.utc).astimezone(tz)\ndt, dt.strftime('%H:%M %z %Z'), tz, timezone(timedelta(hours=-3))", "(datetime.datetime(2021, 1, 1, 5, 30, tzinfo=datetime.timezone(datetime.timedelta(seconds=19800), 'IST')), '05:30 +0530 IST', datetime.timezone(datetime.timedelta(seconds=19800), 'IST'), datetime.timezone(datetime.timedelta(days=-1, seconds=75600)))"},
+        {"from datetime import datetime, timezone\ndatetime.fromtimestamp(1234567890, timezone.utc), datetime(1970, 1, 1) + (datetime(2000, 1, 1) - datetime(1999, 12, 31))", "(datetime.datetime(2009, 2, 13, 23, 31, 30, tzinfo=datetime.timezone.utc), datetime.datetime(1970, 1, 2, 0, 0))"},
+        {"from datetime import datetime, timezone\ndatetime(2020, 1, 1) < datetime(2020, 1, 1, tzinfo=timezone.utc)", "TypeError: can't compare offset-naive and offset-aware datetimes"},
+        {"from datetime import datetime, timezone\ndatetime(2020, 1, 1) == datetime(2020, 1, 1, tzinfo=timezone.utc), datetime(2020, 1, 1, 5, tzinfo=timezone.utc) - datetime(2020, 1, 1, tzinfo=timezone.utc)", "(False, datetime.timedelta(seconds=18000))"},
+        {"from datetime import datetime, date\ndatetime(2020, 1, 1) < date(2020, 1, 1)", "TypeError: can't compare datetime.datetime to datetime.date"},
+        {"from datetime import datetime\ndatetime.strptime('2021-03-04 05:06 +0100', '%Y-%m-%d %H:%M %z')", "datetime.datetime(2021, 3, 4, 5, 6, tzinfo=datetime.timezone(datetime.timedelta(seconds=3600)))"},
+        {"from datetime import datetime\nd = datetime(2020, 5, 17, 8, 30)\nd.date(), d.time(), d.replace(minute=0), d.timetuple()[:6], datetime.combine(d.date(), d.time()) == d", "(datetime.date(2020, 5, 17), datetime.time(8, 30), datetime.datetime(2020, 5, 17, 8, 0), (2020, 5, 17, 8, 30, 0), True)"},
+        {"from datetime import datetime\nfrom zoneinfo import ZoneInfo\np = ZoneInfo('Europe/Paris')\na = datetime(2020, 10, 25, 1, 30, tzinfo=ZoneInfo('UTC')).astimezone(p)\na, a.utcoffset(), a.tzname(), datetime(2020, 7, 1, 12, tzinfo=p).dst(), p is ZoneInfo('Europe/Paris')", "(datetime.datetime(2020, 10, 25, 2, 30, fold=1, tzinfo=zoneinfo.ZoneInfo(key='Europe/Paris')), datetime.timedelta(seconds=3600), 'CET', datetime.timedelta(seconds=3600), True)"},
+        {"from zoneinfo import ZoneInfo\nZoneInfo('Nowhere/Special')", "zoneinfo.ZoneInfoNotFoundError: 'No time zone found with key Nowhere/Special'"},
+    })
+}
+
+func TestBuiltins(t *testing.T) {
+    runEvalTests(t, []evalTest{
+        {"r = range(1, 20, 3)\nr, len(r), r[2], r[-1], r[1:4], 7 in r, 8 in r, r.index(10), list(reversed(range(3)))", "(range(1, 20, 3), 7, 7, 19, range(4, 13, 3), True, False, 3, [2, 1, 0])"},
+        {"range(0) == range(5, 2), range(0, 3, 2) == range(0, 4, 2), hash(range(0)) == hash(range(3, 3)), bool(range(1))", "(True, True, True, True)"},
+        {"list(enumerate('ab', start=5)), list(zip('abc', [1, 2])), list(map(pow, [2, 3], [3, 2])), list(filter(None, [0, 1, '', 'x']))", "([(5, 'a'), (6, 'b')], [('a', 1), ('b', 2)], [8, 9], [1, 'x'])"},
+        {"sorted([3, 1, 2], reverse=True), min([3, 1, 2]), max('abc', key=ord), min([], default=None), max(1, 5, 3), sum([1, 2], 10)", "([3, 2, 1], 1, 'c', None, 5, 13)"},
+        {"any([0, '']), all([]), abs(-3.5), round(2.5), round(-15, -1), round(1.2345, 2), divmod(-7, 2), divmod(7.5, 2), pow(3, 4, 5), pow(3, -1, 7)", "(False, True, 3.5, 2, -20, 1.23, (-4, 1), (3.0, 1.5), 1, 5)"},
+        {"ascii('héllo'), chr(233), ord('é'), ord(b'a'), bin(-5), oct(8), hex(255)", "(\"'h\\\\xe9llo'\", 'é', 233, 97, '-0b101', '0o10', '0xff')"},
+        {"it = iter([1, 2, 3, 0, 4])\nlist(iter(lambda: next(it), 0)), list(reversed({'a': 1, 'b': 2}))", "([1, 2, 3], ['b', 'a'])"},
+        {"class A:\n    x = 1\na = A()\nsetattr(a, 'y', 2)\ngetattr(a, 'z', 0), hasattr(a, 'y'), vars(a), callable(A), 'x' in dir(a), 'y' in dir(a)", "(0, True, {'y': 2}, True, True, True)"},
+        {"x = 1\nglobals()['y'] = 2\ndef f(a):\n    b = 3\n    return locals()\ny, f(0), 'x' in dir()", "(2, {'a': 0, 'b': 3}, True)"},
+        {"X = type('X', (object,), {'v': 5})\nX.v, X.__name__, type(X())", "(5, 'X', <class '__main__.X'>)"},
+        {"range(1, 2, 0)", "ValueError: range() arg 3 must not be zero"},
+        {"range(3)[3]", "IndexError: range object index out of range"},
+        {"range(3).index(5)", "ValueError: 5 is not in range"},
+        {"list(zip([1], [2, 3], strict=True))", "ValueError: zip() argument 2 is longer than argument 1"},
+        {"map(len)", "TypeError: map() must have at least two arguments."},
+        {"reversed(5)", "TypeError: 'int' object is not reversible"},
+        {"max()", "TypeError: max expected at least 1 argument, got 0"},
+        {"max([])", "ValueError: max() arg is an empty sequence"},
+        {"min(1, 2, default=0)", "TypeError: Cannot specify a default for min() with multiple positional arguments"},
+        {"sum(['a'], '')", "TypeError: sum() can't sum strings [use ''.join(seq) instead]"},
+        {"pow(2.0, 3, 5)", "TypeError: pow() 3rd argument not allowed unless all arguments are integers"},
+        {"divmod('a', 1)", "TypeError: unsupported operand type(s) for divmod(): 'str' and 'int'"},
+        {"round('x')", "TypeError: type str doesn't define __round__ method"},
+        {"chr(0x110000)", "ValueError: chr() arg not in range(0x110000)"},
+        {"ord('ab')", "TypeError: ord() expected a character, but string of length 2 found"},
+        {"getattr(1, 2)", "TypeError: attribute name must be string, not 'int'"},
+        {"vars(1)", "TypeError: vars() argument must have __dict__ attribute"},
+        {"iter(1, 2)", "TypeError: iter(v, w): v must be callable"},
+        {"type(1, 2)", "TypeError: type() takes 1 or 3 arguments"},
+        {"hex(1.5)", "TypeError: 'float' object cannot be interpreted as an integer"},
+        {"filter(None)", "TypeError: filter expected 2 arguments, got 1"},
+    })
+}
+
+func TestFileIO(t *testing.T) {
+    dir := t.TempDir()
+    path := func(name string) string { return "'" + filepath.Join(dir, name) + "'" }
+
+    runEvalTests(t, []evalTest{
+        {"with open(" + path("a.txt") + ", 'w') as f:\n    n = f.write('héllo\\nworld\\n')\nn, f.closed, open(" + path("a.txt") + ").readlines()", "(12, True, ['héllo\\n', 'world\\n'])"},
+        {"f = open(" + path("a.txt") + ")\nf.read(3), f.readline(), f.tell(), list(f)", "('hél', 'lo\\n', 7, ['world\\n'])"},
+        {"with open(" + path("b.bin") + ", 'wb') as f:\n    f.write(b'ab\\r\\ncd')\nopen(" + path("b.bin") + ", 'rb').read(), open(" + path("b.bin") + ").read(), open(" + path("b.bin") + ", newline='').read()", "(b'ab\\r\\ncd', 'ab\\ncd', 'ab\\r\\ncd')"},
+        {"f = open(" + path("b.bin") + ", 'r+b')\nf.seek(-2, 2), f.read(), f.mode, f", "(4, b'cd', 'rb+', <_io.BufferedRandom name=" + path("b.bin") + ">)"},
+        {"open(" + path("missing.txt") + ")", "FileNotFoundError: [Errno 2] No such file or directory: " + path("missing.txt")},
+        {"open(" + path("a.txt") + ", 'rw')", "ValueError: must have exactly one of create/read/write/append mode"},
+        {"open(" + path("a.txt") + ", 'wb', encoding='utf-8')", "ValueError: binary mode doesn't take an encoding argument"},
+        {"open(" + path("a.txt") + ").write('x')", "io.UnsupportedOperation: not writable"},
+        {"f = open(" + path("a.txt") + ")\nf.close()\nf.read()", "ValueError: I/O operation on closed file."},
+        {"open(" + path("a.txt") + ", 'x')", "FileExistsError: [Errno 17] File exists: " + path("a.txt")},
+    })
+}
+
+func TestPrintAndStreams(t *testing.T) {
+    tests := []struct {
+        input  string
+        stdin  string
+        stdout string
+        stderr string
+        result string
+    }{
+        {"print(1, 'a', [2])\nprint(1, 2, sep='', end='.')", "", "1 a [2]\n12.", "", "None"},
+        {"print('a', 'b', sep=None, end=None)\nprint()", "", "a b\n\n", "", "None"},
+        {"import sys\nprint('oops', file=sys.stderr, flush=True)", "", "", "oops\n", "None"},
+        {"class W:\n    def __init__(self):\n        self.parts = []\n    def write(self, s):\n        self.parts.append(s)\n    def flush(self):\n        self.parts.append('!')\nw = W()\nprint(1, 2, file=w, flush=True)\nw.parts", "", "", "", "['1', ' ', '2', '\\n', '!']"},
+        {"import sys\nold = sys.stdout\nsys.stdout = None\nprint('gone')\nsys.stdout = old\nprint('back')", "", "back\n", "", "None"},
+        {"name = input('who? ')\nline = __import__('sys').stdin.readline()\nname, line", "ann\nbob\n", "who? ", "", "('ann', 'bob\\n')"},
+        {"input()", "", "", "", "EOFError: EOF when reading a line"},
+        {"print(1, sep=5)", "", "", "", "TypeError: sep must be None or a string, not int"},
+        {"print(1, foo=2)", "", "", "", "TypeError: 'foo' is an invalid keyword argument for print()"},
+        {"import sys\ndel sys.stdout\nprint(1)", "", "", "", "RuntimeError: lost sys.stdout"},
+    }
+    for _, tt := range tests {
+        var stdout, stderr strings.Builder
+        env := NewEnvironment()
+        env.SetStdout(&stdout)
+        env.SetStderr(&stderr)
+        env.SetStdin(strings.NewReader(tt.stdin))
+        if got := testEvalIn(t, env, tt.input); got != tt.result {
+            t.Errorf("eval(%q) wrong.\nexpected=%s\ngot=%s", tt.input, tt.result, got)
+        }
+        if stdout.String() != tt.stdout || stderr.String() != tt.stderr {
+            t.Errorf("eval(%q) wrote stdout=%q stderr=%q, want %q and %q", tt.input, stdout.String(), stderr.String(), tt.stdout, tt.stderr)
+        }
+    }
+}
+
+func TestInterpreterRegister(t *testing.T) {
+    in := NewInterpreter()
+    register := func(g GoFunc) {
+        if err := in.Register(g); err != nil {
+            t.Fatal(err)
+        }
+    }
+    register(GoFunc{Name: "greet", Doc: "Say hello n times.", Params: []string{"name", "n"}, Defaults: []interface{}{1},
+        Fn: func(name string, n int) (string, error) {
+            if n < 0 {
+                return "", &PythonError{Kind: "ValueError", Message: "n must not be negative"}
+            }
+            return strings.Repeat("hi "+name+"! ", n), nil
+        }})
+    register(GoFunc{Name: "total", Fn: func(xs ...float64) float64 {
+        sum := 0.0
+        for _, x := range xs {
+            sum += x
+        }
+        return sum
+    }})
+    register(GoFunc{Name: "counts", Fn: func(words []string) map[string]int {
+        counts := map[string]int{}
+        for _, w := range words {
+            counts[w]++
+        }
+        return counts
+    }})
+    register(GoFunc{Name: "describe", Fn: func(v interface{}) string { return fmt.Sprintf("%T", v) }})
+    register(GoFunc{Name: "stat", Fn: func(path string) error { _, err := os.Stat(path); return err }})
+    register(GoFunc{Name: "parse", Fn: func(s string) (int64, error) { return strconv.ParseInt(s, 10, 64) }})
+    register(GoFunc{Name: "boom", Fn: func() { panic("oh no") }})
+    register(GoFunc{Name: "apply", Fn: func(env *Environment, fn Object) Object { return applyFunction(env, fn, []Object{newInt(20)}, nil) }})
+
+    tests := []evalTest{
+        {"greet('ann'), greet('bob', n=2), greet.__doc__, greet.__name__", "('hi ann! ', 'hi bob! hi bob! ', 'Say hello n times.', 'greet')"},
+        {"total(), total(1, 2.5, True), counts(['a', 'b', 'a'])", "(0.0, 4.5, {'a': 2, 'b': 1})"},
+        {"describe(1), describe('x'), describe([1, None]), describe({'k': 1.5}), describe(None)", "('int64', 'string', '[]interface {}', 'map[string]interface {}', '<nil>')"},
+        {"apply(lambda x: x + 1)", "21"},
+        {"greet('ann', -1)", "ValueError: n must not be negative"},
+        {"greet(5)", "TypeError: greet() argument 'name' must be str, not int"},
+        {"greet()", "TypeError: greet() missing required argument 'name' (pos 1)"},
+        {"greet('a', m=1)", "TypeError: 'm' is an invalid keyword argument for greet()"},
+        {"total('x')", "TypeError: total() argument 1 must be real number, not str"},
+        {"counts(1)", "TypeError: 'int' object is not iterable"},
+        {"stat('/no/such/file')", "FileNotFoundError: [Errno 2] No such file or directory: '/no/such/file'"},
+        {"parse('12x')", "ValueError: strconv.ParseInt: parsing \"12x\": invalid syntax"},
+        {"parse('99999999999999999999')", "OverflowError: strconv.ParseInt: parsing \"99999999999999999999\": value out of range"},
+        {"boom()", "SystemError: boom() panicked: oh no"},
+        {"describe(1, 2)", "TypeError: describe() takes exactly one argument (2 given)"},
+    }
+    for _, tt := range tests {
+        if got := testEvalIn(t, in.Environment, tt.input); got != tt.expected {
+            t.Errorf("eval(%q) wrong.\nexpected=%s\ngot=%s", tt.input, tt.expected, got)
+        }
+    }
+
+    if got := testEvalIn(t, NewEnvironment(), "greet"); got != "NameError: name 'greet' is not defined" {
+        t.Errorf("greet leaked into another interpreter: %s", got)
+    }
+    if err := in.RegisterFunc("bad", func(ch chan int) {}); err == nil {
+        t.Errorf("registering a func taking a chan should fail")
+    }
+    if _, err := in.Run("greet('x', 'y')"); err == nil || err.Error() != "TypeError: 'str' object cannot be interpreted as an integer" {
+        t.Errorf("Run gave %v", err)
+    }
+    if result, err := in.Run("total(1, 2)"); err != nil || result.Inspect() != "3.0" {
+        t.Errorf("Run gave %v, %v", result, err)
+    }
+    // Message is str() of the exception, like the last line of the traceback
+    for src, expected := range map[string]string{
+        "stat('/no/such/file')": "FileNotFoundError: [Errno 2] No such file or directory: '/no/such/file'",
+        "class E(Exception):\n    def __str__(self):\n        return 'custom'\nraise E(1, 2)": "E: custom",
+        "raise KeyError('k')": "KeyError: 'k'",
+    } {
+        if _, err := in.Run(src); err == nil || err.Error() != expected {
+            t.Errorf("Run(%q) gave %v, want %s", src, err, expected)
+        }
+    }
+}
+
+func TestRunContext(t *testing.T) {
+    for _, src := range []string{"while True:\n    pass", "for i in iter(int, 1):\n    pass", "import time\ntime.sleep(30)"} {
+        ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+        start := time.Now()
+        _, err := NewInterpreter().RunContext(ctx, src)
+        cancel()
+        if err == nil || !strings.HasPrefix(err.Error(), "KeyboardInterrupt") {
+            t.Errorf("RunContext(%q) gave %v", src, err)
+        }
+        if time.Since(start) > 5*time.Second {
+            t.Errorf("RunContext(%q) took %v to stop", src, time.Since(start))
+        }
+    }
+    try := "import time\ntry:\n    time.sleep(30)\nexcept KeyboardInterrupt:\n    r = 'caught'\nr"
+    ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+    defer cancel()
+    if result, err := NewInterpreter().RunContext(ctx, try); err != nil || result.Inspect() != "'caught'" {
+        t.Errorf("RunContext gave %v, %v", result, err)
+    }
+}
+
+// Two interpreters never share a repr guard, a linecache or an unlocked map;
+// go test -race is the one that would notice
+func TestParallelInterpreters(t *testing.T) {
+    src := `
+import zoneinfo
+a = [1]
+a.append(a)
+d = {'k': [2]}
+d['d'] = d
+def fail():
+    raise ValueError('no')
+for i in range(50):
+    r = repr(a) + repr(d) + repr(zoneinfo.ZoneInfo('UTC'))
+    zoneinfo.ZoneInfo.clear_cache(only_keys=['UTC'])
+r`
+    want := `"[1, [...]]{'k': [2], 'd': {...}}zoneinfo.ZoneInfo(key='UTC')"`
+    var wg sync.WaitGroup
+    for n := 0; n < 4; n++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            in := NewInterpreter()
+            in.RegisterSource("<stdin>", src)
+            if result, err := in.Run(src); err != nil || result.Inspect() != want {
+                t.Errorf("Run gave %v, %v", result, err)
+            }
+            _, err := in.Run("fail()")
+            if err == nil || !strings.Contains(err.(*PythonError).Traceback, "raise ValueError('no')") {
+                t.Errorf("Run gave %v", err)
+            }
+            // a class one program may change is its own; the rest don't change
+            write := fmt.Sprintf("import contextlib\nfor i in range(50):\n    contextlib.suppress.tag = %d\ncontextlib.suppress.tag", n)
+            if result, err := in.Run(write); err != nil || result.Inspect() != fmt.Sprint(n) {
+                t.Errorf("Run gave %v, %v", result, err)
+            }
+            if _, err := in.Run("import random\nrandom.Random.tag = 1"); err == nil || !strings.HasPrefix(err.Error(), "TypeError") {
+                t.Errorf("Run gave %v", err)
+            }
+        }()
+    }
+    wg.Wait()
+    if result, err := NewInterpreter().Run("import contextlib\nhasattr(contextlib.suppress, 'tag')"); err != nil || result != FALSE {
+        t.Errorf("another interpreter's class attribute leaked: %v, %v", result, err)
+    }
+}
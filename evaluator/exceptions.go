@@ -0,0 +1,896 @@
+// Comments in this file are inspired by Robert Zane - when things go wrong, he's already in the room
+
+package evaluator
+
+import (
+    "fmt"
+    "path/filepath"
+    "strings"
+)
+
+// Exception is a Python exception instance. Raising one wraps it in an *Error.
+type Exception struct {
+    Class           *Class
+    Dict            *Dict
+    Args            *Tuple
+    Cause           *Exception
+    Context         *Exception
+    SuppressContext bool
+    Traceback       *Traceback
+    Fields          map[string]Object // per-class attributes like StopIteration.value
+}
+
+func (e *Exception) Type() ObjectType { return EXCEPTION_OBJ }
+func (e *Exception) Inspect() string  { return e.Class.Name + e.Args.Inspect() }
+
+// message is the Go-side text of an exception, for when there's no env to call __str__
+func (e *Exception) message() string {
+    switch len(e.Args.Elements) {
+    case 0:
+        return ""
+    case 1:
+        if s, ok := e.Args.Elements[0].(*String); ok && !e.Class.isSubclass(keyErrorType) {
+            return s.Value
+        }
+        return e.Args.Elements[0].Inspect()
+    }
+    return e.Args.Inspect()
+}
+
+var exceptionClasses = map[string]*Class{}
+
+func newExceptionClass(name string, bases ...*Class) *Class {
+    cls := newBuiltinClass(name, bases...)
+    exceptionClasses[name] = cls
+    return cls
+}
+
+// The hierarchy, straight out of the CPython docs. I don't improvise on precedent.
+var (
+    baseExceptionType       = newExceptionClass("BaseException", objectType)
+    baseExceptionGroupType  = newExceptionClass("BaseExceptionGroup", baseExceptionType)
+    generatorExitType       = newExceptionClass("GeneratorExit", baseExceptionType)
+    keyboardInterruptType   = newExceptionClass("KeyboardInterrupt", baseExceptionType)
+    systemExitType          = newExceptionClass("SystemExit", baseExceptionType)
+    exceptionType           = newExceptionClass("Exception", baseExceptionType)
+    arithmeticErrorType     = newExceptionClass("ArithmeticError", exceptionType)
+    overflowErrorType       = newExceptionClass("OverflowError", arithmeticErrorType)
+    zeroDivisionErrorType   = newExceptionClass("ZeroDivisionError", arithmeticErrorType)
+    assertionErrorType      = newExceptionClass("AssertionError", exceptionType)
+    attributeErrorType      = newExceptionClass("AttributeError", exceptionType)
+    exceptionGroupType      = newExceptionClass("ExceptionGroup", baseExceptionGroupType, exceptionType)
+    importErrorType         = newExceptionClass("ImportError", exceptionType)
+    moduleNotFoundErrorType = newExceptionClass("ModuleNotFoundError", importErrorType)
+    lookupErrorType         = newExceptionClass("LookupError", exceptionType)
+    indexErrorType          = newExceptionClass("IndexError", lookupErrorType)
+    keyErrorType            = newExceptionClass("KeyError", lookupErrorType)
+    memoryErrorType         = newExceptionClass("MemoryError", exceptionType)
+    nameErrorType           = newExceptionClass("NameError", exceptionType)
+    unboundLocalErrorType   = newExceptionClass("UnboundLocalError", nameErrorType)
+    osErrorType             = newExceptionClass("OSError", exceptionType)
+    runtimeErrorType        = newExceptionClass("RuntimeError", exceptionType)
+    notImplementedErrorType = newExceptionClass("NotImplementedError", runtimeErrorType)
+    recursionErrorType      = newExceptionClass("RecursionError", runtimeErrorType)
+    stopIterationType       = newExceptionClass("StopIteration", exceptionType)
+    syntaxErrorType         = newExceptionClass("SyntaxError", exceptionType)
+    systemErrorType         = newExceptionClass("SystemError", exceptionType)
+    typeErrorType           = newExceptionClass("TypeError", exceptionType)
+    valueErrorType          = newExceptionClass("ValueError", exceptionType)
+    unicodeErrorType        = newExceptionClass("UnicodeError", valueErrorType)
+    warningType             = newExceptionClass("Warning", exceptionType)
+)
+
+// exceptionFields are the extra attributes each class carries, None until set
+var exceptionFields = map[*Class][]string{}
+
+func init() {
+    for cls, fields := range map[*Class][]string{
+        stopIterationType:      {"value"},
+        systemExitType:         {"code"},
+        importErrorType:        {"msg", "name", "path"},
+        nameErrorType:          {"name"},
+        attributeErrorType:     {"name", "obj"},
+        osErrorType:            {"errno", "strerror", "filename", "filename2"},
+        syntaxErrorType:        {"msg", "filename", "lineno", "offset", "text", "end_lineno", "end_offset"},
+        baseExceptionGroupType: {"message", "exceptions"},
+    } {
+        exceptionFields[cls] = fields
+    }
+}
+
+// osErrorSubclasses maps errno values to the OSError subclass OSError() picks
+var osErrorSubclasses = map[int64]string{}
+
+func init() {
+    newExceptionClass("FloatingPointError", arithmeticErrorType)
+    newExceptionClass("BufferError", exceptionType)
+    newExceptionClass("EOFError", exceptionType)
+    newExceptionClass("ReferenceError", exceptionType)
+    newExceptionClass("StopAsyncIteration", exceptionType)
+    indentationError := newExceptionClass("IndentationError", syntaxErrorType)
+    newExceptionClass("TabError", indentationError)
+    for _, name := range []string{"UnicodeDecodeError", "UnicodeEncodeError", "UnicodeTranslateError"} {
+        newExceptionClass(name, unicodeErrorType)
+    }
+    for _, name := range []string{"UserWarning", "DeprecationWarning", "PendingDeprecationWarning",
+        "SyntaxWarning", "RuntimeWarning", "FutureWarning", "ImportWarning", "UnicodeWarning",
+        "BytesWarning", "ResourceWarning", "EncodingWarning"} {
+        newExceptionClass(name, warningType)
+    }
+
+    connectionError := newExceptionClass("ConnectionError", osErrorType)
+    for name, errnos := range map[string][]int64{
+        "BlockingIOError":        {11, 114, 115},
+        "ChildProcessError":      {10},
+        "FileExistsError":        {17},
+        "FileNotFoundError":      {2},
+        "InterruptedError":       {4},
+        "IsADirectoryError":      {21},
+        "NotADirectoryError":     {20},
+        "PermissionError":        {1, 13},
+        "ProcessLookupError":     {3},
+        "TimeoutError":           {110},
+        "BrokenPipeError":        {32, 108},
+        "ConnectionAbortedError": {103},
+        "ConnectionRefusedError": {111},
+        "ConnectionResetError":   {104},
+    } {
+        base := osErrorType
+        if strings.HasPrefix(name, "Connection") || name == "BrokenPipeError" {
+            base = connectionError
+        }
+        newExceptionClass(name, base)
+        for _, errno := range errnos {
+            osErrorSubclasses[errno] = name
+        }
+    }
+
+    for name, cls := range exceptionClasses {
+        builtins[name] = cls
+    }
+    builtins["EnvironmentError"] = osErrorType
+    builtins["IOError"] = osErrorType
+
+    initBaseException()
+    initExceptionSubclasses()
+    initExceptionGroups()
+}
+
+// newException builds an exception without running any Python code
+func newException(cls *Class, args ...Object) *Exception {
+    e := &Exception{Class: cls, Dict: NewDict(), Args: &Tuple{Elements: args}, Fields: map[string]Object{}}
+    for _, c := range cls.MRO {
+        for _, name := range exceptionFields[c] {
+            e.Fields[name] = NULL
+        }
+    }
+    return e
+}
+
+func isExceptionClass(obj Object) bool {
+    cls, ok := obj.(*Class)
+    return ok && cls.isSubclass(baseExceptionType)
+}
+
+func isExceptionGroup(e *Exception) bool {
+    return e.Class.isSubclass(baseExceptionGroupType)
+}
+
+// exceptionName is how tracebacks name a class: builtins and __main__ go unqualified
+func exceptionName(cls *Class) string {
+    if module := cls.module(); module != "builtins" && module != "__main__" {
+        return module + "." + cls.qualname()
+    }
+    return cls.qualname()
+}
+
+// formatException is the last line of a traceback: "ValueError: bad value"
+func formatException(env *Environment, e *Exception) string {
+    name := exceptionName(e.Class)
+    if text := exceptionText(env, e); text != "" {
+        return name + ": " + text
+    }
+    return name
+}
+
+// exceptionText is str(e), __str__ overrides and all
+func exceptionText(env *Environment, e *Exception) string {
+    str := strOf(env, e)
+    if isError(str) {
+        return "<exception str() failed>"
+    }
+    return payload(str).(*String).Value
+}
+
+// setContext records the exception that was being handled when e was raised,
+// cutting the chain if that would make a cycle
+func setContext(e, context *Exception) {
+    if e == nil || context == nil || e == context {
+        return
+    }
+    seen := map[*Exception]bool{}
+    for o := context; o.Context != nil && !seen[o]; o = o.Context {
+        seen[o] = true
+        if o.Context == e {
+            o.Context = nil
+            break
+        }
+    }
+    e.Context = context
+}
+
+// chainContext is setContext for errors raised from Go code, which only find
+// out what was being handled once they reach a try statement
+func chainContext(e, context *Exception) {
+    if e != nil && e.Context == nil {
+        setContext(e, context)
+    }
+}
+
+// attribute exposes the exception's slots
+func (e *Exception) attribute(name string) (Object, bool) {
+    switch name {
+    case "args":
+        return e.Args, true
+    case "__cause__":
+        return exceptionOrNone(e.Cause), true
+    case "__context__":
+        return exceptionOrNone(e.Context), true
+    case "__suppress_context__":
+        return nativeBool(e.SuppressContext), true
+    case "__traceback__":
+        if e.Traceback == nil {
+            return NULL, true
+        }
+        return e.Traceback, true
+    }
+    value, ok := e.Fields[name]
+    return value, ok
+}
+
+func (e *Exception) setAttribute(env *Environment, name string, value Object) (bool, *Error) {
+    switch name {
+    case "args":
+        items, err := iterableToSlice(env, value)
+        if err != nil {
+            return true, err
+        }
+        e.Args = &Tuple{Elements: items}
+    case "__cause__":
+        cause, err := exceptionArgument(value, "exception cause must be None or derive from BaseException")
+        if err != nil {
+            return true, err
+        }
+        e.Cause = cause
+        e.SuppressContext = true
+    case "__context__":
+        context, err := exceptionArgument(value, "exception context must be None or derive from BaseException")
+        if err != nil {
+            return true, err
+        }
+        e.Context = context
+    case "__suppress_context__":
+        ok, err := truthy(env, value)
+        if err != nil {
+            return true, err
+        }
+        e.SuppressContext = ok
+    case "__traceback__":
+        switch value := value.(type) {
+        case *Traceback:
+            e.Traceback = value
+        case *NullObject:
+            e.Traceback = nil
+        default:
+            return true, typeError("__traceback__ must be a traceback or None")
+        }
+    case "message", "exceptions":
+        if isExceptionGroup(e) {
+            return true, attributeError("readonly attribute")
+        }
+        return false, nil
+    default:
+        if _, ok := e.Fields[name]; !ok {
+            return false, nil
+        }
+        e.Fields[name] = value
+    }
+    return true, nil
+}
+
+func exceptionOrNone(e *Exception) Object {
+    if e == nil {
+        return NULL
+    }
+    return e
+}
+
+func exceptionArgument(value Object, message string) (*Exception, *Error) {
+    if value == NULL {
+        return nil, nil
+    }
+    e, ok := value.(*Exception)
+    if !ok {
+        return nil, typeError("%s", message)
+    }
+    return e, nil
+}
+
+func asException(obj Object) *Exception { return obj.(*Exception) }
+
+// exceptionKeywords binds the keyword arguments an exception class accepts to its fields
+func exceptionKeywords(e *Exception, kwargs *Dict, allowed ...string) *Error {
+    if kwargs == nil {
+        return nil
+    }
+    for _, entry := range kwargs.Entries() {
+        key := entry.Key.(*String).Value
+        found := false
+        for _, name := range allowed {
+            if name == key {
+                found = true
+            }
+        }
+        if !found {
+            return typeError("'%s' is an invalid keyword argument for %s()", key, e.Class.Name)
+        }
+        e.Fields[key] = entry.Value
+    }
+    return nil
+}
+
+func initBaseException() {
+    baseExceptionType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        if len(args) == 0 {
+            return typeError("BaseException.__new__(): not enough arguments")
+        }
+        cls, ok := args[0].(*Class)
+        if !ok {
+            return typeError("BaseException.__new__(X): X is not a type object (%s)", typeName(args[0]))
+        }
+        if !cls.isSubclass(baseExceptionType) {
+            return typeError("BaseException.__new__(%s): %s is not a subtype of BaseException", cls.Name, cls.Name)
+        }
+        return newException(cls, args[1:]...)
+    })
+    baseExceptionType.define("__init__", func(env *Environment, args []Object, kwargs *Dict) Object {
+        e := asException(args[0])
+        if kwargs.Len() > 0 {
+            return typeError("%s() takes no keyword arguments", e.Class.Name)
+        }
+        e.Args = &Tuple{Elements: append([]Object{}, args[1:]...)}
+        return NULL
+    })
+    baseExceptionType.method("__str__", 0, 0, func(env *Environment, args []Object) Object {
+        e := asException(args[0])
+        switch len(e.Args.Elements) {
+        case 0:
+            return &String{Value: ""}
+        case 1:
+            return strOf(env, e.Args.Elements[0])
+        }
+        return strOf(env, e.Args)
+    })
+    baseExceptionType.method("__repr__", 0, 0, func(env *Environment, args []Object) Object {
+        e := asException(args[0])
+        var inner Object
+        if len(e.Args.Elements) == 1 {
+            inner = reprOf(env, e.Args.Elements[0])
+        } else {
+            inner = reprOf(env, e.Args)
+        }
+        if isError(inner) {
+            return inner
+        }
+        if len(e.Args.Elements) == 1 {
+            return &String{Value: e.Class.Name + "(" + inner.(*String).Value + ")"}
+        }
+        return &String{Value: e.Class.Name + inner.(*String).Value}
+    })
+    baseExceptionType.method("with_traceback", 1, 1, func(env *Environment, args []Object) Object {
+        if _, err := asException(args[0]).setAttribute(env, "__traceback__", args[1]); err != nil {
+            return err
+        }
+        return args[0]
+    })
+    baseExceptionType.method("add_note", 1, 1, func(env *Environment, args []Object) Object {
+        e := asException(args[0])
+        note, ok := args[1].(*String)
+        if !ok {
+            return typeError("note must be a str, not '%s'", typeName(args[1]))
+        }
+        notes, ok := e.Dict.GetStr("__notes__")
+        if !ok {
+            notes = &List{}
+            e.Dict.SetStr("__notes__", notes)
+        }
+        list, ok := notes.(*List)
+        if !ok {
+            return typeError("Cannot add note: __notes__ is not a list")
+        }
+        list.Elements = append(list.Elements, note)
+        return NULL
+    })
+}
+
+func initExceptionSubclasses() {
+    stopIterationType.define("__init__", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if result := applyFunction(env, baseExceptionType.lookupName("__init__"), args, kwargs); isError(result) {
+            return result
+        }
+        if len(args) > 1 {
+            asException(args[0]).Fields["value"] = args[1]
+        }
+        return NULL
+    })
+    systemExitType.define("__init__", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if result := applyFunction(env, baseExceptionType.lookupName("__init__"), args, kwargs); isError(result) {
+            return result
+        }
+        e := asException(args[0])
+        switch len(args) {
+        case 1:
+        case 2:
+            e.Fields["code"] = args[1]
+        default:
+            e.Fields["code"] = e.Args
+        }
+        return NULL
+    })
+
+    keywordInit := func(cls *Class, allowed ...string) {
+        cls.define("__init__", func(env *Environment, args []Object, kwargs *Dict) Object {
+            e := asException(args[0])
+            if err := exceptionKeywords(e, kwargs, allowed...); err != nil {
+                return err
+            }
+            e.Args = &Tuple{Elements: append([]Object{}, args[1:]...)}
+            if cls == importErrorType && len(args) == 2 {
+                e.Fields["msg"] = args[1]
+            }
+            return NULL
+        })
+    }
+    keywordInit(importErrorType, "name", "path")
+    keywordInit(nameErrorType, "name")
+    keywordInit(attributeErrorType, "name", "obj")
+    importErrorType.method("__str__", 0, 0, func(env *Environment, args []Object) Object {
+        if msg := asException(args[0]).Fields["msg"]; msg != NULL {
+            return strOf(env, msg)
+        }
+        return callMethod(env, baseExceptionType.lookupName("__str__"), args[0])
+    })
+
+    keyErrorType.method("__str__", 0, 0, func(env *Environment, args []Object) Object {
+        e := asException(args[0])
+        if len(e.Args.Elements) == 1 {
+            return reprOf(env, e.Args.Elements[0])
+        }
+        return callMethod(env, baseExceptionType.lookupName("__str__"), args[0])
+    })
+
+    // OSError(errno, strerror) picks the subclass that errno belongs to
+    osErrorType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        cls, err := newClassArg("OSError", args)
+        if err != nil {
+            return err
+        }
+        if cls == osErrorType && len(args) >= 3 && len(args) <= 6 {
+            if errno, ok := args[1].(*Integer); ok && errno.Value.IsInt64() {
+                if name, ok := osErrorSubclasses[errno.Value.Int64()]; ok {
+                    cls = exceptionClasses[name]
+                }
+            }
+        }
+        return newException(cls, args[1:]...)
+    })
+    osErrorType.define("__init__", func(env *Environment, args []Object, kwargs *Dict) Object {
+        e := asException(args[0])
+        if kwargs.Len() > 0 {
+            return typeError("%s() takes no keyword arguments", e.Class.Name)
+        }
+        e.Args = &Tuple{Elements: append([]Object{}, args[1:]...)}
+        if n := len(args) - 1; n >= 2 && n <= 5 {
+            e.Fields["errno"], e.Fields["strerror"] = args[1], args[2]
+            if n >= 3 {
+                e.Fields["filename"] = args[3]
+                if n == 5 {
+                    e.Fields["filename2"] = args[5]
+                }
+                if args[3] != NULL {
+                    e.Args = &Tuple{Elements: args[1:3]}
+                }
+            }
+        }
+        return NULL
+    })
+    osErrorType.method("__str__", 0, 0, func(env *Environment, args []Object) Object {
+        e := asException(args[0])
+        errno, strerror := e.Fields["errno"], e.Fields["strerror"]
+        if errno == NULL || strerror == NULL {
+            return callMethod(env, baseExceptionType.lookupName("__str__"), args[0])
+        }
+        parts := []Object{errno, strerror}
+        format := "[Errno %s] %s"
+        for _, field := range []string{"filename", "filename2"} {
+            if e.Fields[field] == NULL {
+                break
+            }
+            repr := reprOf(env, e.Fields[field])
+            if isError(repr) {
+                return repr
+            }
+            parts = append(parts, repr)
+            if field == "filename" {
+                format += ": %s"
+            } else {
+                format += " -> %s"
+            }
+        }
+        texts := make([]interface{}, len(parts))
+        for i, part := range parts {
+            if i < 2 {
+                part = strOf(env, part)
+                if isError(part) {
+                    return part
+                }
+            }
+            texts[i] = part.(*String).Value
+        }
+        return &String{Value: fmt.Sprintf(format, texts...)}
+    })
+
+    syntaxErrorType.define("__init__", func(env *Environment, args []Object, kwargs *Dict) Object {
+        e := asException(args[0])
+        if kwargs.Len() > 0 {
+            return typeError("%s() takes no keyword arguments", e.Class.Name)
+        }
+        e.Args = &Tuple{Elements: append([]Object{}, args[1:]...)}
+        if len(args) > 1 {
+            e.Fields["msg"] = args[1]
+        }
+        if len(args) == 3 {
+            info, err := iterableToSlice(env, args[2])
+            if err != nil {
+                return err
+            }
+            if len(info) < 4 || len(info) > 6 {
+                return typeError("function takes at least 4 arguments (%d given)", len(info))
+            }
+            for i, name := range []string{"filename", "lineno", "offset", "text", "end_lineno", "end_offset"}[:len(info)] {
+                e.Fields[name] = info[i]
+            }
+        }
+        return NULL
+    })
+    syntaxErrorType.method("__str__", 0, 0, func(env *Environment, args []Object) Object {
+        e := asException(args[0])
+        msg := strOf(env, e.Fields["msg"])
+        if isError(msg) {
+            return msg
+        }
+        text := msg.(*String).Value
+        filename, hasFile := e.Fields["filename"].(*String)
+        lineno, hasLine := e.Fields["lineno"].(*Integer)
+        switch {
+        case hasFile && hasLine:
+            text = fmt.Sprintf("%s (%s, line %s)", text, filepath.Base(filename.Value), lineno.Value)
+        case hasFile:
+            text = fmt.Sprintf("%s (%s)", text, filepath.Base(filename.Value))
+        case hasLine:
+            text = fmt.Sprintf("%s (line %s)", text, lineno.Value)
+        }
+        return &String{Value: text}
+    })
+}
+
+// Exception groups. One deal, many problems - and you settle them one at a time.
+func initExceptionGroups() {
+    baseExceptionGroupType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        cls, err := newClassArg("BaseExceptionGroup", args)
+        if err != nil {
+            return err
+        }
+        if kwargs.Len() > 0 {
+            return typeError("%s() takes no keyword arguments", cls.Name)
+        }
+        if len(args) != 3 {
+            return typeError("BaseExceptionGroup.__new__() takes exactly 2 arguments (%d given)", len(args)-1)
+        }
+        message, ok := args[1].(*String)
+        if !ok {
+            return typeError("argument 1 must be str, not %s", typeName(args[1]))
+        }
+        var items []Object
+        switch seq := payload(args[2]).(type) {
+        case *List:
+            items = seq.Elements
+        case *Tuple:
+            items = seq.Elements
+        default:
+            return typeError("second argument (exceptions) must be a sequence")
+        }
+        if len(items) == 0 {
+            return valueError("second argument (exceptions) must be a non-empty sequence")
+        }
+
+        nestedBase := false
+        excs := make([]Object, len(items))
+        for i, item := range items {
+            e, ok := item.(*Exception)
+            if !ok {
+                return valueError("Item %d of second argument (exceptions) is not an exception", i)
+            }
+            if !e.Class.isSubclass(exceptionType) {
+                nestedBase = true
+            }
+            excs[i] = e
+        }
+        switch {
+        case cls == baseExceptionGroupType:
+            if !nestedBase {
+                cls = exceptionGroupType
+            }
+        case cls == exceptionGroupType:
+            if nestedBase {
+                return typeError("Cannot nest BaseExceptions in an ExceptionGroup")
+            }
+        case nestedBase && cls.isSubclass(exceptionType):
+            return typeError("Cannot nest BaseExceptions in '%s'", cls.Name)
+        }
+
+        e := newException(cls, args[1:]...)
+        e.Fields["message"] = message
+        e.Fields["exceptions"] = &Tuple{Elements: excs}
+        return e
+    })
+    baseExceptionGroupType.define("__init__", func(env *Environment, args []Object, kwargs *Dict) Object {
+        return applyFunction(env, baseExceptionType.lookupName("__init__"), args, kwargs)
+    })
+    baseExceptionGroupType.method("__str__", 0, 0, func(env *Environment, args []Object) Object {
+        e := asException(args[0])
+        n := len(e.Fields["exceptions"].(*Tuple).Elements)
+        message := strOf(env, e.Fields["message"])
+        if isError(message) {
+            return message
+        }
+        return &String{Value: fmt.Sprintf("%s (%d sub-exception%s)", message.(*String).Value, n, plural(n))}
+    })
+    baseExceptionGroupType.method("derive", 1, 1, func(env *Environment, args []Object) Object {
+        e := asException(args[0])
+        return applyFunction(env, baseExceptionGroupType, []Object{e.Fields["message"], args[1]}, nil)
+    })
+    baseExceptionGroupType.method("split", 1, 1, func(env *Environment, args []Object) Object {
+        matcher, err := newExceptionMatcher(args[1])
+        if err != nil {
+            return err
+        }
+        match, rest, err := splitExceptionGroup(env, asException(args[0]), matcher, true)
+        if err != nil {
+            return err
+        }
+        return &Tuple{Elements: []Object{exceptionOrNone(match), exceptionOrNone(rest)}}
+    })
+    baseExceptionGroupType.method("subgroup", 1, 1, func(env *Environment, args []Object) Object {
+        matcher, err := newExceptionMatcher(args[1])
+        if err != nil {
+            return err
+        }
+        match, _, err := splitExceptionGroup(env, asException(args[0]), matcher, false)
+        if err != nil {
+            return err
+        }
+        return exceptionOrNone(match)
+    })
+}
+
+func groupExceptions(e *Exception) []Object {
+    return e.Fields["exceptions"].(*Tuple).Elements
+}
+
+// exceptionMatcher is the condition split() and subgroup() filter on: a type,
+// a tuple of types, a predicate, or the identities of leaf exceptions
+type exceptionMatcher struct {
+    types     Object
+    predicate Object
+    leaves    map[*Exception]bool
+}
+
+func newExceptionMatcher(value Object) (*exceptionMatcher, *Error) {
+    if isExceptionClass(value) {
+        return &exceptionMatcher{types: value}, nil
+    }
+    if tuple, ok := value.(*Tuple); ok {
+        for _, item := range tuple.Elements {
+            if !isExceptionClass(item) {
+                return nil, typeError("expected an exception type, a tuple of exception types, or a callable (other than a class)")
+            }
+        }
+        return &exceptionMatcher{types: value}, nil
+    }
+    if _, isClass := value.(*Class); !isClass && isCallable(value) {
+        return &exceptionMatcher{predicate: value}, nil
+    }
+    return nil, typeError("expected an exception type, a tuple of exception types, or a callable (other than a class)")
+}
+
+func (m *exceptionMatcher) match(env *Environment, e *Exception) (bool, *Error) {
+    switch {
+    case m.types != nil:
+        return classCheck("except", e.Class, m.types)
+    case m.predicate != nil:
+        result := applyFunction(env, m.predicate, []Object{e}, nil)
+        if err, ok := result.(*Error); ok {
+            return false, err
+        }
+        return truthy(env, result)
+    }
+    return m.leaves[e], nil
+}
+
+// splitExceptionGroup divides a group into the part that matches and the rest,
+// keeping the nesting and metadata of the original
+func splitExceptionGroup(env *Environment, e *Exception, m *exceptionMatcher, withRest bool) (match, rest *Exception, err *Error) {
+    ok, err := m.match(env, e)
+    if err != nil || ok {
+        return e, nil, err
+    }
+    if !isExceptionGroup(e) {
+        if withRest {
+            rest = e
+        }
+        return nil, rest, nil
+    }
+
+    matches, rests := []*Exception{}, []*Exception{}
+    for _, item := range groupExceptions(e) {
+        itemMatch, itemRest, err := splitExceptionGroup(env, item.(*Exception), m, withRest)
+        if err != nil {
+            return nil, nil, err
+        }
+        if itemMatch != nil {
+            matches = append(matches, itemMatch)
+        }
+        if itemRest != nil {
+            rests = append(rests, itemRest)
+        }
+    }
+    if match, err = exceptionSubset(env, e, matches); err != nil {
+        return nil, nil, err
+    }
+    if withRest {
+        if rest, err = exceptionSubset(env, e, rests); err != nil {
+            return nil, nil, err
+        }
+    }
+    return match, rest, nil
+}
+
+// exceptionSubset derives a new group holding excs and the original's metadata
+func exceptionSubset(env *Environment, orig *Exception, excs []*Exception) (*Exception, *Error) {
+    if len(excs) == 0 {
+        return nil, nil
+    }
+    items := make([]Object, len(excs))
+    for i, e := range excs {
+        items[i] = e
+    }
+    derive := getAttribute(env, orig, "derive")
+    if err, ok := derive.(*Error); ok {
+        return nil, err
+    }
+    derived := applyFunction(env, derive, []Object{&List{Elements: items}}, nil)
+    if err, ok := derived.(*Error); ok {
+        return nil, err
+    }
+    eg, ok := derived.(*Exception)
+    if !ok || !isExceptionGroup(eg) {
+        return nil, typeError("derive must return an instance of BaseExceptionGroup")
+    }
+    eg.Traceback = orig.Traceback
+    eg.Cause, eg.SuppressContext = orig.Cause, true
+    eg.Context = orig.Context
+    if notes, ok := orig.Dict.GetStr("__notes__"); ok {
+        if items, err := iterableToSlice(env, notes); err == nil {
+            eg.Dict.SetStr("__notes__", &List{Elements: items})
+        }
+    }
+    return eg, nil
+}
+
+// exceptStarMatch is what one except* clause takes out of the exception in flight
+func exceptStarMatch(env *Environment, e *Exception, types Object) (match, rest *Exception, err *Error) {
+    if e == nil {
+        return nil, nil, nil
+    }
+    ok, err := classCheck("except", e.Class, types)
+    if err != nil {
+        return nil, nil, err
+    }
+    if ok {
+        if isExceptionGroup(e) {
+            return e, nil, nil
+        }
+        wrapped := newExceptionGroup(&Tuple{Elements: []Object{e}})
+        wrapped.Traceback = e.Traceback
+        return wrapped, nil, nil
+    }
+    if isExceptionGroup(e) {
+        return splitExceptionGroup(env, e, &exceptionMatcher{types: types}, true)
+    }
+    return nil, e, nil
+}
+
+// newExceptionGroup is ExceptionGroup("", excs), for the groups the interpreter makes itself
+func newExceptionGroup(excs Object) *Exception {
+    e := newException(exceptionGroupType, &String{Value: ""}, excs)
+    e.Fields["message"] = &String{Value: ""}
+    e.Fields["exceptions"] = &Tuple{Elements: iterableElements(excs)}
+    return e
+}
+
+func iterableElements(obj Object) []Object {
+    switch obj := obj.(type) {
+    case *List:
+        return append([]Object{}, obj.Elements...)
+    case *Tuple:
+        return obj.Elements
+    }
+    return nil
+}
+
+// reraiseStar works out what leaves a try/except* statement: whatever the
+// handlers raised, plus the unhandled rest, re-raised exceptions kept in the
+// shape of the original group
+func reraiseStar(env *Environment, orig *Exception, excs []*Exception) (*Exception, *Error) {
+    if len(excs) == 0 {
+        return nil, nil
+    }
+    if !isExceptionGroup(orig) {
+        return excs[0], nil
+    }
+
+    raised, reraised := []Object{}, []*Exception{}
+    for _, e := range excs {
+        if sameExceptionMetadata(e, orig) {
+            reraised = append(reraised, e)
+        } else {
+            raised = append(raised, e)
+        }
+    }
+
+    leaves := map[*Exception]bool{}
+    for _, e := range reraised {
+        collectLeaves(e, leaves)
+    }
+    projected, _, err := splitExceptionGroup(env, orig, &exceptionMatcher{leaves: leaves}, false)
+    if err != nil {
+        return nil, err
+    }
+    if len(raised) == 0 {
+        return projected, nil
+    }
+    if projected != nil {
+        raised = append(raised, projected)
+    }
+    if len(raised) == 1 {
+        return raised[0].(*Exception), nil
+    }
+    return newExceptionGroup(&List{Elements: raised}), nil
+}
+
+func sameExceptionMetadata(a, b *Exception) bool {
+    notesA, _ := a.Dict.GetStr("__notes__")
+    notesB, _ := b.Dict.GetStr("__notes__")
+    return notesA == notesB && a.Traceback == b.Traceback && a.Cause == b.Cause && a.Context == b.Context
+}
+
+func collectLeaves(e *Exception, leaves map[*Exception]bool) {
+    if !isExceptionGroup(e) {
+        leaves[e] = true
+        return
+    }
+    for _, item := range groupExceptions(e) {
+        collectLeaves(item.(*Exception), leaves)
+    }
+}
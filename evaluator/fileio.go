@@ -0,0 +1,915 @@
+// Comments in this file are inspired by Ray - Harvey's driver, who gets everything in and out of the building
+
+package evaluator
+
+import (
+    "bufio"
+    "bytes"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "strings"
+    "syscall"
+)
+
+// File is what open() hands back, and what sys.stdout is: text or bytes on
+// their way to or from somewhere outside
+type File struct {
+    class    *Class
+    name     Object
+    mode     string
+    file     *os.File      // nil for a stand-in like sys.stdout
+    source   *bufio.Reader // where a stand-in's reads come from, a line at a time
+    writer   io.Writer     // where writes go - the file itself, unless it's a stand-in
+    reading  bool
+    writing  bool
+    binary   bool
+    encoding string
+    errors   string
+    newline  Object // NULL is universal newlines; otherwise the str the program asked for
+    closefd  bool
+    pending  []byte // read off the disk but not yet by the program
+    closed   bool
+}
+
+func (f *File) Type() ObjectType { return FILE_OBJ }
+func (f *File) Inspect() string {
+    name := f.name.Inspect()
+    switch f.class {
+    case streamType:
+        return fmt.Sprintf("<_io.TextIOWrapper name=%s mode='%s' encoding='%s'>", name, f.mode, f.encoding)
+    case fileIOType:
+        closefd := "False"
+        if f.closefd {
+            closefd = "True"
+        }
+        return fmt.Sprintf("<_io.FileIO name=%s mode='%s' closefd=%s>", name, f.mode, closefd)
+    }
+    return fmt.Sprintf("<_io.%s name=%s>", f.class.Name, name)
+}
+
+var (
+    ioBaseType         = newBuiltinClass("_IOBase", objectType)
+    streamType         = newBuiltinClass("TextIOWrapper", ioBaseType)
+    bufferedReaderType = newBuiltinClass("BufferedReader", ioBaseType)
+    bufferedWriterType = newBuiltinClass("BufferedWriter", ioBaseType)
+    bufferedRandomType = newBuiltinClass("BufferedRandom", ioBaseType)
+    fileIOType         = newBuiltinClass("FileIO", ioBaseType)
+
+    // io.UnsupportedOperation: asking a file for something its mode rules out
+    unsupportedOperationType = newBuiltinClass("UnsupportedOperation", osErrorType, valueErrorType)
+
+)
+
+// standardStream is a sys.stdin, sys.stdout or sys.stderr over whatever the
+// host hands us: source to read from, or w to write to
+func standardStream(name string, source *bufio.Reader, w io.Writer, errors string) *File {
+    f := &File{class: streamType, name: &String{Value: name}, source: source, writer: w,
+        encoding: "utf-8", errors: errors, newline: NULL, closefd: true}
+    f.reading, f.writing = source != nil, w != nil
+    f.mode = "w"
+    if f.reading {
+        f.mode = "r"
+    }
+    return f
+}
+
+func unsupported(operation string) *Error {
+    return newErrorKind(unsupportedOperationType, "%s", operation)
+}
+
+// osError turns what the OS said into the OSError subclass Python would raise
+func osError(err error, filename Object) *Error {
+    var errno syscall.Errno
+    if !errors.As(err, &errno) {
+        return newErrorKind(osErrorType, "%s", err.Error())
+    }
+    cls := osErrorType
+    if name, ok := osErrorSubclasses[int64(errno)]; ok {
+        cls = exceptionClasses[name]
+    }
+    message := errno.Error()
+    if message != "" {
+        message = strings.ToUpper(message[:1]) + message[1:]
+    }
+    code, strerror := newInt(int64(errno)), &String{Value: message}
+    exc := newException(cls, code, strerror)
+    exc.Fields["errno"], exc.Fields["strerror"] = code, strerror
+    if filename != nil {
+        exc.Fields["filename"] = filename
+    }
+    return &Error{Exception: exc}
+}
+
+// openFile is open(): it checks the arguments in the order CPython does, so
+// the first complaint is the same one
+func openFile(env *Environment, args []Object, kwargs *Dict) Object {
+    params, err := parseArgs("open", args, kwargs, []string{"file", "mode", "buffering", "encoding", "errors", "newline", "closefd", "opener"}, 1)
+    if err != nil {
+        return err
+    }
+    mode := "r"
+    if params[1] != nil {
+        s, ok := payload(params[1]).(*String)
+        if !ok {
+            return typeError("open() argument 'mode' must be str, not %s", typeName(params[1]))
+        }
+        mode = s.Value
+    }
+    buffering := -1
+    if params[2] != nil {
+        if buffering, err = toIndex(env, params[2]); err != nil {
+            return err
+        }
+    }
+    options := [3]Object{NULL, NULL, NULL}
+    for i, name := range []string{"encoding", "errors", "newline"} {
+        value := params[3+i]
+        if value == nil || value == NULL {
+            continue
+        }
+        if _, ok := payload(value).(*String); !ok {
+            return typeError("open() argument '%s' must be str or None, not %s", name, typeName(value))
+        }
+        options[i] = value
+    }
+    closefd := true
+    if params[6] != nil {
+        if closefd, err = truthy(env, params[6]); err != nil {
+            return err
+        }
+    }
+
+    seen := map[rune]bool{}
+    for _, c := range mode {
+        if !strings.ContainsRune("rwxabt+", c) || seen[c] {
+            return valueError("invalid mode: %s", strRepr(mode))
+        }
+        seen[c] = true
+    }
+    kinds := 0
+    for _, c := range "rwxa" {
+        if seen[c] {
+            kinds++
+        }
+    }
+    binary := seen['b']
+    switch {
+    case seen['t'] && binary:
+        return valueError("can't have text and binary mode at once")
+    case kinds > 1:
+        return valueError("must have exactly one of create/read/write/append mode")
+    case binary && options[0] != NULL:
+        return valueError("binary mode doesn't take an encoding argument")
+    case binary && options[1] != NULL:
+        return valueError("binary mode doesn't take an errors argument")
+    case binary && options[2] != NULL:
+        return valueError("binary mode doesn't take a newline argument")
+    case !binary && buffering == 0:
+        return valueError("can't have unbuffered text I/O")
+    case kinds == 0:
+        return valueError("Must have exactly one of create/read/write/append mode and at most one plus")
+    }
+
+    f := &File{name: params[0], mode: mode, binary: binary, closefd: closefd, newline: options[2]}
+    flags, raw := 0, ""
+    switch {
+    case seen['r']:
+        f.reading, raw = true, "rb"
+    case seen['w']:
+        f.writing, raw, flags = true, "wb", os.O_CREATE|os.O_TRUNC
+    case seen['a']:
+        f.writing, raw, flags = true, "ab", os.O_CREATE|os.O_APPEND
+    case seen['x']:
+        f.writing, raw, flags = true, "xb", os.O_CREATE|os.O_EXCL
+    }
+    switch {
+    case seen['+']:
+        f.reading, f.writing = true, true
+        flags |= os.O_RDWR
+        if raw == "wb" {
+            raw = "rb"
+        }
+        raw += "+"
+    case f.reading:
+        flags |= os.O_RDONLY
+    default:
+        flags |= os.O_WRONLY
+    }
+
+    if !binary {
+        f.class, f.encoding, f.errors = streamType, "utf-8", "strict"
+        if options[0] != NULL {
+            f.encoding = asString(options[0]).Value
+            if _, ok := normalizeEncoding(f.encoding); !ok {
+                return newErrorKind(lookupErrorType, "unknown encoding: %s", f.encoding)
+            }
+        }
+        if options[1] != NULL {
+            f.errors = asString(options[1]).Value
+        }
+        if options[2] != NULL {
+            switch newline := asString(options[2]).Value; newline {
+            case "", "\n", "\r", "\r\n":
+            default:
+                return valueError("illegal newline value: %s", newline)
+            }
+        }
+    } else {
+        f.mode = raw
+        switch {
+        case buffering == 0:
+            f.class = fileIOType
+        case seen['+']:
+            f.class = bufferedRandomType
+        case f.reading:
+            f.class = bufferedReaderType
+        default:
+            f.class = bufferedWriterType
+        }
+    }
+
+    if err := f.open(env, flags, params[7]); err != nil {
+        return err
+    }
+    return f
+}
+
+// open gets hold of the file itself: a path, or a descriptor someone already has
+func (f *File) open(env *Environment, flags int, opener Object) *Error {
+    path := f.name
+    if fspath := typeOf(path).lookupName("__fspath__"); fspath != nil {
+        if _, isStr := path.(*String); !isStr {
+            path = callMethod(env, fspath, path)
+            if err, ok := path.(*Error); ok {
+                return err
+            }
+        }
+    }
+
+    var fd int
+    switch value := payload(path).(type) {
+    case *String, *Bytes:
+        if !f.closefd {
+            return valueError("Cannot use closefd=False with file name")
+        }
+        name := ""
+        if s, ok := value.(*String); ok {
+            name = s.Value
+        } else {
+            name = string(value.(*Bytes).Value)
+        }
+        if opener != nil && opener != NULL {
+            result := applyFunction(env, opener, []Object{path, newInt(int64(flags | syscall.O_CLOEXEC))}, nil)
+            if err, ok := result.(*Error); ok {
+                return err
+            }
+            n, ok := toBigInt(result)
+            if !ok {
+                return typeError("expected an integer from opener")
+            }
+            if fd = int(n.Int64()); fd < 0 {
+                return valueError("opener returned %d", fd)
+            }
+            f.file = os.NewFile(uintptr(fd), name)
+            break
+        }
+        file, err := os.OpenFile(name, flags, 0666)
+        if err != nil {
+            return osError(err, f.name)
+        }
+        f.file = file
+    default:
+        n, ok := toBigInt(path)
+        if !ok {
+            return typeError("expected str, bytes or os.PathLike object, not %s", typeName(path))
+        }
+        if fd = int(n.Int64()); !n.IsInt64() || fd < 0 {
+            return valueError("negative file descriptor")
+        }
+        if !f.closefd {
+            // a copy of the descriptor, so the one we were lent never gets closed
+            if fd, err := syscall.Dup(fd); err == nil {
+                f.file = os.NewFile(uintptr(fd), "")
+                break
+            }
+        }
+        f.file = os.NewFile(uintptr(fd), "")
+    }
+
+    info, err := f.file.Stat()
+    if err != nil {
+        f.file.Close()
+        return osError(err, nil)
+    }
+    if info.IsDir() {
+        f.file.Close()
+        return osError(syscall.EISDIR, f.name)
+    }
+    if flags&os.O_APPEND != 0 {
+        f.file.Seek(0, io.SeekEnd)
+    }
+    f.writer = f.file
+    return nil
+}
+
+// check is the guard every operation starts with: open, and allowed to
+func (f *File) check(reading, writing bool) *Error {
+    if f.closed {
+        switch {
+        case f.binary && reading:
+            return valueError("read of closed file")
+        case f.binary && writing:
+            return valueError("write to closed file")
+        }
+        return valueError("I/O operation on closed file.")
+    }
+    switch {
+    case reading && !f.reading:
+        if f.binary {
+            return unsupported("read")
+        }
+        return unsupported("not readable")
+    case writing && !f.writing:
+        if f.binary {
+            return unsupported("write")
+        }
+        return unsupported("not writable")
+    }
+    return nil
+}
+
+// fill reads another chunk into pending, reporting false at the end of the file
+func (f *File) fill() (bool, *Error) {
+    if f.file == nil {
+        if f.source == nil {
+            return false, nil
+        }
+        // a stand-in's source may be shared with the host, so never take more than a line
+        line, err := f.source.ReadBytes('\n')
+        f.pending = append(f.pending, line...)
+        if err != nil && err != io.EOF {
+            return len(line) > 0, osError(err, nil)
+        }
+        return len(line) > 0, nil
+    }
+    chunk := make([]byte, 8192)
+    n, err := f.file.Read(chunk)
+    f.pending = append(f.pending, chunk[:n]...)
+    if n > 0 {
+        return true, nil
+    }
+    if err != nil && err != io.EOF {
+        return false, osError(err, nil)
+    }
+    return false, nil
+}
+
+// rest is everything from here to the end of the file
+func (f *File) rest() ([]byte, *Error) {
+    for {
+        more, err := f.fill()
+        if err != nil {
+            return nil, err
+        }
+        if !more {
+            break
+        }
+    }
+    data := f.pending
+    f.pending = nil
+    return data, nil
+}
+
+// unread puts bytes back in front of what's still to come
+func (f *File) unread(data []byte) {
+    f.pending = append(append([]byte{}, data...), f.pending...)
+}
+
+// lineEnd finds where the first line in data stops, and whether that is
+// certain - a \r at the very end might still have a \n coming
+func (f *File) lineEnd(data []byte) (int, bool) {
+    newline := "\n"
+    if !f.binary {
+        if s, ok := f.newline.(*String); ok && s.Value != "" {
+            newline = s.Value
+        } else {
+            // universal newlines: \n, \r and \r\n all end a line
+            i := bytes.IndexAny(data, "\r\n")
+            switch {
+            case i < 0:
+                return -1, false
+            case data[i] == '\n':
+                return i + 1, true
+            case i+1 < len(data):
+                if data[i+1] == '\n' {
+                    return i + 2, true
+                }
+                return i + 1, true
+            }
+            return i + 1, false
+        }
+    }
+    if i := bytes.Index(data, []byte(newline)); i >= 0 {
+        return i + len(newline), true
+    }
+    return -1, false
+}
+
+// rawLine takes the next line off the file, ending and all, as it is on disk
+func (f *File) rawLine() ([]byte, *Error) {
+    for {
+        end, sure := f.lineEnd(f.pending)
+        if end >= 0 && sure {
+            line := f.pending[:end:end]
+            f.pending = f.pending[end:]
+            return line, nil
+        }
+        more, err := f.fill()
+        if err != nil {
+            return nil, err
+        }
+        if !more {
+            if end < 0 {
+                end = len(f.pending)
+            }
+            line := f.pending[:end:end]
+            f.pending = f.pending[end:]
+            return line, nil
+        }
+    }
+}
+
+// decode turns bytes from the file into text, line endings translated
+func (f *File) decode(data []byte) (string, *Error) {
+    text := decodeBytes(data, f.encoding, f.errors)
+    if err, ok := text.(*Error); ok {
+        return "", err
+    }
+    s := text.(*String).Value
+    if f.newline == NULL && strings.Contains(s, "\r") {
+        s = strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\r", "\n")
+    }
+    return s, nil
+}
+
+// readText is read(size) on a text file: size counts characters, not bytes
+func (f *File) readText(size int) (string, *Error) {
+    if size < 0 {
+        data, err := f.rest()
+        if err != nil {
+            return "", err
+        }
+        return f.decode(data)
+    }
+    var b strings.Builder
+    for size > 0 {
+        line, err := f.textLine(size)
+        if err != nil {
+            return "", err
+        }
+        if line == "" {
+            break
+        }
+        b.WriteString(line)
+        size -= codePointCount(line)
+    }
+    return b.String(), nil
+}
+
+// textLine reads a line of at most limit characters (any length if negative);
+// whatever doesn't fit goes back for next time
+func (f *File) textLine(limit int) (string, *Error) {
+    raw, err := f.rawLine()
+    if err != nil {
+        return "", err
+    }
+    line, err := f.decode(raw)
+    if err != nil || limit < 0 {
+        return line, err
+    }
+    text := codePoints(line)
+    if len(text) <= limit {
+        return line, nil
+    }
+    // Only the line's ending gets translated, and it is past the cut
+    kept := encodeString(fromCodePoints(text[:limit]), f.encoding, f.errors)
+    if err, ok := kept.(*Error); ok {
+        return "", err
+    }
+    f.unread(raw[min(len(kept.(*Bytes).Value), len(raw)):])
+    return fromCodePoints(text[:limit]), nil
+}
+
+// readBytes is read(size) on a binary file
+func (f *File) readBytes(size int) ([]byte, *Error) {
+    if size < 0 {
+        return f.rest()
+    }
+    for len(f.pending) < size {
+        more, err := f.fill()
+        if err != nil {
+            return nil, err
+        }
+        if !more {
+            break
+        }
+    }
+    n := min(size, len(f.pending))
+    data := f.pending[:n:n]
+    f.pending = f.pending[n:]
+    return data, nil
+}
+
+// readLine is readline(size) for either kind of file, ready to hand back
+func (f *File) readLine(size int) Object {
+    if !f.binary {
+        line, err := f.textLine(size)
+        if err != nil {
+            return err
+        }
+        return &String{Value: line}
+    }
+    line, err := f.rawLine()
+    if err != nil {
+        return err
+    }
+    if size >= 0 && len(line) > size {
+        f.unread(line[size:])
+        line = line[:size]
+    }
+    return &Bytes{Value: line}
+}
+
+// writeText encodes and writes a str, the way a text file does
+func (f *File) writeText(text string) *Error {
+    if s, ok := f.newline.(*String); ok && (s.Value == "\r" || s.Value == "\r\n") {
+        text = strings.ReplaceAll(text, "\n", s.Value)
+    }
+    data := encodeString(text, f.encoding, f.errors)
+    if err, ok := data.(*Error); ok {
+        return err
+    }
+    // a text stream reads ahead in whole chunks and just forgets them on a
+    // write, so the write lands after the chunk - CPython does the same
+    f.pending = nil
+    return f.writeBytes(data.(*Bytes).Value)
+}
+
+func (f *File) writeBytes(data []byte) *Error {
+    if f.closed {
+        return valueError("I/O operation on closed file.")
+    }
+    if len(f.pending) > 0 {
+        // what we read ahead isn't where the program thinks it is
+        f.file.Seek(-int64(len(f.pending)), io.SeekCurrent)
+        f.pending = nil
+    }
+    if _, err := f.writer.Write(data); err != nil {
+        return osError(err, nil)
+    }
+    return nil
+}
+
+func (f *File) tell() (int64, *Error) {
+    if f.file == nil {
+        return 0, osError(syscall.ESPIPE, nil)
+    }
+    pos, err := f.file.Seek(0, io.SeekCurrent)
+    if err != nil {
+        return 0, osError(err, nil)
+    }
+    return pos - int64(len(f.pending)), nil
+}
+
+func (f *File) close() *Error {
+    if f.closed {
+        return nil
+    }
+    f.closed = true
+    f.pending = nil
+    if f.file != nil {
+        if err := f.file.Close(); err != nil {
+            return osError(err, nil)
+        }
+    }
+    return nil
+}
+
+// sizeArgument reads the size that read() and friends take: an int, or None for all of it
+func sizeArgument(env *Environment, args []Object) (int, *Error) {
+    if len(args) < 2 || args[1] == NULL {
+        return -1, nil
+    }
+    if _, ok := toBigInt(args[1]); !ok && typeOf(args[1]).lookupName("__index__") == nil {
+        return 0, typeError("argument should be integer or None, not '%s'", typeName(args[1]))
+    }
+    return toIndex(env, args[1])
+}
+
+func asFile(obj Object) *File { return obj.(*File) }
+
+func init() {
+    registerBuiltin("open", openFile)
+
+    unsupportedOperationType.Dict.SetStr("__module__", &String{Value: "io"})
+    for _, cls := range []*Class{ioBaseType, streamType, bufferedReaderType, bufferedWriterType, bufferedRandomType, fileIOType} {
+        cls.Dict.SetStr("__module__", &String{Value: "_io"})
+    }
+
+    ioBaseType.method("__repr__", 0, 0, func(env *Environment, args []Object) Object {
+        return &String{Value: args[0].Inspect()}
+    })
+    ioBaseType.method("read", 0, 1, func(env *Environment, args []Object) Object {
+        f := asFile(args[0])
+        if err := f.check(true, false); err != nil {
+            return err
+        }
+        size, err := sizeArgument(env, args)
+        if err != nil {
+            return err
+        }
+        if !f.binary {
+            text, err := f.readText(size)
+            if err != nil {
+                return err
+            }
+            return &String{Value: text}
+        }
+        if size < -1 {
+            return valueError("read length must be non-negative or -1")
+        }
+        data, err := f.readBytes(size)
+        if err != nil {
+            return err
+        }
+        return &Bytes{Value: data}
+    })
+    ioBaseType.method("readline", 0, 1, func(env *Environment, args []Object) Object {
+        f := asFile(args[0])
+        if err := f.check(true, false); err != nil {
+            return err
+        }
+        size, err := sizeArgument(env, args)
+        if err != nil {
+            return err
+        }
+        return f.readLine(size)
+    })
+    ioBaseType.method("readlines", 0, 1, func(env *Environment, args []Object) Object {
+        f := asFile(args[0])
+        if err := f.check(true, false); err != nil {
+            return err
+        }
+        hint, err := sizeArgument(env, args)
+        if err != nil {
+            return err
+        }
+        lines, total := &List{}, 0
+        for {
+            line := f.readLine(-1)
+            if isError(line) {
+                return line
+            }
+            n, _ := length(env, line)
+            if n == 0 {
+                return lines
+            }
+            lines.Elements = append(lines.Elements, line)
+            if total += n; hint > 0 && total >= hint {
+                return lines
+            }
+        }
+    })
+    ioBaseType.method("write", 1, 1, func(env *Environment, args []Object) Object {
+        f := asFile(args[0])
+        if err := f.check(false, true); err != nil {
+            return err
+        }
+        if !f.binary {
+            s, ok := payload(args[1]).(*String)
+            if !ok {
+                return typeError("write() argument must be str, not %s", typeName(args[1]))
+            }
+            if err := f.writeText(s.Value); err != nil {
+                return err
+            }
+            return newInt(int64(codePointCount(s.Value)))
+        }
+        data, ok, err := bytesLike(args[1])
+        if err != nil {
+            return err
+        }
+        if !ok {
+            return typeError("a bytes-like object is required, not '%s'", typeName(args[1]))
+        }
+        if err := f.writeBytes(data); err != nil {
+            return err
+        }
+        return newInt(int64(len(data)))
+    })
+    ioBaseType.method("writelines", 1, 1, func(env *Environment, args []Object) Object {
+        f := asFile(args[0])
+        if err := f.check(false, true); err != nil {
+            return err
+        }
+        write := ioBaseType.lookupName("write")
+        if err := iterate(env, args[1], func(line Object) *Error {
+            if err, ok := callMethod(env, write, f, line).(*Error); ok {
+                return err
+            }
+            return nil
+        }); err != nil {
+            return err
+        }
+        return NULL
+    })
+    ioBaseType.method("seek", 1, 2, func(env *Environment, args []Object) Object {
+        f := asFile(args[0])
+        if err := f.check(false, false); err != nil {
+            return err
+        }
+        offset, err := indexValue(env, args[1])
+        if err != nil {
+            return err
+        }
+        whence := 0
+        if len(args) == 3 {
+            if whence, err = toIndex(env, args[2]); err != nil {
+                return err
+            }
+        }
+        switch {
+        case whence < 0 || whence > 2:
+            if f.binary {
+                return valueError("whence value %d unsupported", whence)
+            }
+            return valueError("invalid whence (%d, should be 0, 1 or 2)", whence)
+        case !f.binary && whence == 1 && offset.Sign() != 0:
+            return unsupported("can't do nonzero cur-relative seeks")
+        case !f.binary && whence == 2 && offset.Sign() != 0:
+            return unsupported("can't do nonzero end-relative seeks")
+        case whence == 0 && offset.Sign() < 0:
+            if f.binary {
+                return valueError("negative seek value %s", offset)
+            }
+            return valueError("negative seek position %s", offset)
+        case !offset.IsInt64():
+            return overflowError("Python int too large to convert to C long")
+        }
+        if f.file == nil {
+            return unsupported("underlying stream is not seekable")
+        }
+        delta := offset.Int64()
+        if whence == 1 {
+            delta -= int64(len(f.pending))
+        }
+        f.pending = nil
+        pos, serr := f.file.Seek(delta, whence)
+        if serr != nil {
+            return osError(serr, nil)
+        }
+        return newInt(pos)
+    })
+    ioBaseType.method("tell", 0, 0, func(env *Environment, args []Object) Object {
+        f := asFile(args[0])
+        if err := f.check(false, false); err != nil {
+            return err
+        }
+        pos, err := f.tell()
+        if err != nil {
+            return err
+        }
+        return newInt(pos)
+    })
+    ioBaseType.method("truncate", 0, 1, func(env *Environment, args []Object) Object {
+        f := asFile(args[0])
+        if err := f.check(false, false); err != nil {
+            return err
+        }
+        if !f.writing || f.file == nil {
+            return unsupported("File not open for writing")
+        }
+        size, err := f.tell()
+        if err != nil {
+            return err
+        }
+        if len(args) == 2 && args[1] != NULL {
+            n, err := indexValue(env, args[1])
+            if err != nil {
+                return err
+            }
+            size = n.Int64()
+        }
+        if err := f.file.Truncate(size); err != nil {
+            return osError(err, nil)
+        }
+        return newInt(size)
+    })
+    ioBaseType.method("flush", 0, 0, func(env *Environment, args []Object) Object {
+        f := asFile(args[0])
+        if err := f.check(false, false); err != nil {
+            return err
+        }
+        // writes go straight through, but a host's writer may hold some back
+        if flusher, ok := f.writer.(interface{ Flush() error }); ok && f.file == nil {
+            if err := flusher.Flush(); err != nil {
+                return osError(err, nil)
+            }
+        }
+        return NULL
+    })
+    ioBaseType.method("close", 0, 0, func(env *Environment, args []Object) Object {
+        if err := asFile(args[0]).close(); err != nil {
+            return err
+        }
+        return NULL
+    })
+    ioBaseType.property("closed", func(self Object) Object {
+        return nativeBool(asFile(self).closed)
+    })
+    ioBaseType.property("name", func(self Object) Object {
+        return asFile(self).name
+    })
+    ioBaseType.property("mode", func(self Object) Object {
+        return &String{Value: asFile(self).mode}
+    })
+    ioBaseType.method("fileno", 0, 0, func(env *Environment, args []Object) Object {
+        f := asFile(args[0])
+        if err := f.check(false, false); err != nil {
+            return err
+        }
+        if f.file == nil {
+            return unsupported("fileno")
+        }
+        return newInt(int64(f.file.Fd()))
+    })
+    ioBaseType.method("isatty", 0, 0, func(env *Environment, args []Object) Object {
+        f := asFile(args[0])
+        if err := f.check(false, false); err != nil {
+            return err
+        }
+        if f.file == nil {
+            return FALSE
+        }
+        info, err := f.file.Stat()
+        return nativeBool(err == nil && info.Mode()&os.ModeCharDevice != 0)
+    })
+    for _, name := range []string{"readable", "writable", "seekable"} {
+        name := name
+        ioBaseType.method(name, 0, 0, func(env *Environment, args []Object) Object {
+            f := asFile(args[0])
+            if err := f.check(false, false); err != nil {
+                return err
+            }
+            switch name {
+            case "readable":
+                return nativeBool(f.reading)
+            case "writable":
+                return nativeBool(f.writing)
+            }
+            if f.file == nil {
+                return FALSE
+            }
+            _, err := f.file.Seek(0, io.SeekCurrent)
+            return nativeBool(err == nil)
+        })
+    }
+    ioBaseType.method("__enter__", 0, 0, func(env *Environment, args []Object) Object {
+        if err := asFile(args[0]).check(false, false); err != nil {
+            return err
+        }
+        return args[0]
+    })
+    ioBaseType.define("__exit__", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := asFile(args[0]).close(); err != nil {
+            return err
+        }
+        return NULL
+    })
+    ioBaseType.method("__iter__", 0, 0, func(env *Environment, args []Object) Object {
+        if err := asFile(args[0]).check(false, false); err != nil {
+            return err
+        }
+        return args[0]
+    })
+    ioBaseType.method("__next__", 0, 0, func(env *Environment, args []Object) Object {
+        f := asFile(args[0])
+        if err := f.check(true, false); err != nil {
+            return err
+        }
+        line := f.readLine(-1)
+        if n, _ := length(env, line); n == 0 && !isError(line) {
+            return stopIteration()
+        }
+        return line
+    })
+
+    streamType.property("encoding", func(self Object) Object {
+        return &String{Value: asFile(self).encoding}
+    })
+    streamType.property("errors", func(self Object) Object {
+        return &String{Value: asFile(self).errors}
+    })
+}
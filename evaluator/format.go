@@ -0,0 +1,1112 @@
+// Comments in this file are inspired by Travis Tanner - it's not what you say, it's how you present it
+
+package evaluator
+
+import (
+    "math"
+    "math/big"
+    "strconv"
+    "strings"
+    "unicode/utf8"
+)
+
+// formatSpec is a parsed format specification:
+// [[fill]align][sign][z][#][0][width][grouping][.precision][type]
+type formatSpec struct {
+    fill      rune // 0 when not given
+    align     byte // 0 when not given
+    sign      byte
+    noNegZero bool
+    alternate bool
+    width     int // -1 when not given
+    grouping  byte
+    precision int // -1 when not given
+    kind      byte
+}
+
+func isAlignment(r rune) bool {
+    return r == '<' || r == '>' || r == '^' || r == '='
+}
+
+// parseFormatSpec reads spec for a value of the named type. defaultAlign is
+// what the '0' flag keeps for strings: it only switches numbers to '='.
+func parseFormatSpec(spec, typeName string, defaultAlign byte) (*formatSpec, *Error) {
+    s := &formatSpec{width: -1, precision: -1}
+    text := []rune(spec)
+    i := 0
+    switch {
+    case len(text) >= 2 && isAlignment(text[1]):
+        s.fill, s.align, i = text[0], byte(text[1]), 2
+    case len(text) >= 1 && isAlignment(text[0]):
+        s.align, i = byte(text[0]), 1
+    }
+    if i < len(text) && (text[i] == '+' || text[i] == '-' || text[i] == ' ') {
+        s.sign = byte(text[i])
+        i++
+    }
+    if i < len(text) && text[i] == 'z' {
+        s.noNegZero = true
+        i++
+    }
+    if i < len(text) && text[i] == '#' {
+        s.alternate = true
+        i++
+    }
+    if s.fill == 0 && i < len(text) && text[i] == '0' {
+        s.fill = '0'
+        if s.align == 0 && defaultAlign == '>' {
+            s.align = '='
+        }
+        i++
+    }
+
+    digits := func() (int, bool, *Error) {
+        start := i
+        for i < len(text) && text[i] >= '0' && text[i] <= '9' {
+            i++
+        }
+        if i == start {
+            return 0, false, nil
+        }
+        n, err := strconv.Atoi(string(text[start:i]))
+        if err != nil {
+            return 0, false, valueError("Too many decimal digits in format string")
+        }
+        return n, true, nil
+    }
+    width, ok, err := digits()
+    if err != nil {
+        return nil, err
+    }
+    if ok {
+        s.width = width
+    }
+    if i < len(text) && (text[i] == ',' || text[i] == '_') {
+        s.grouping = byte(text[i])
+        i++
+        if i < len(text) && (text[i] == ',' || text[i] == '_') {
+            return nil, valueError("Cannot specify both ',' and '_'.")
+        }
+    }
+    if i < len(text) && text[i] == '.' {
+        i++
+        precision, ok, err := digits()
+        if err != nil {
+            return nil, err
+        }
+        if !ok {
+            return nil, valueError("Format specifier missing precision")
+        }
+        s.precision = precision
+    }
+
+    switch rest := text[i:]; {
+    case len(rest) > 1 || len(rest) == 1 && rest[0] >= utf8.RuneSelf:
+        return nil, valueError("Invalid format specifier '%s' for object of type '%s'", spec, typeName)
+    case len(rest) == 1:
+        s.kind = byte(rest[0])
+    }
+
+    if s.grouping != 0 {
+        switch s.kind {
+        case 'd', 'e', 'f', 'g', 'E', 'G', '%', 'F', 0:
+        case 'b', 'o', 'x', 'X':
+            if s.grouping == '_' {
+                break
+            }
+            fallthrough
+        default:
+            return nil, valueError("Cannot specify '%c' with '%c'.", s.grouping, s.kind)
+        }
+    }
+    return s, nil
+}
+
+// groupDigits puts a separator between every `every` digits, from the right
+func groupDigits(digits string, separator byte, every int) string {
+    if separator == 0 || len(digits) <= every {
+        return digits
+    }
+    var b strings.Builder
+    first := len(digits) % every
+    if first == 0 {
+        first = every
+    }
+    b.WriteString(digits[:first])
+    for i := first; i < len(digits); i += every {
+        b.WriteByte(separator)
+        b.WriteString(digits[i : i+every])
+    }
+    return b.String()
+}
+
+// pad fits prefix and body into the width, the fill going where the
+// alignment says; '=' puts it between the sign and the digits
+func (s *formatSpec) pad(prefix, body string, defaultAlign byte) (string, *Error) {
+    n := s.width - utf8.RuneCountInString(prefix) - utf8.RuneCountInString(body)
+    if n <= 0 {
+        return prefix + body, nil
+    }
+    fill := " "
+    if s.fill != 0 {
+        fill = string(s.fill)
+    }
+    if _, err := allocation(n, len(fill)); err != nil {
+        return "", err
+    }
+    align := s.align
+    if align == 0 {
+        align = defaultAlign
+    }
+    switch align {
+    case '<':
+        return prefix + body + strings.Repeat(fill, n), nil
+    case '^':
+        return strings.Repeat(fill, n/2) + prefix + body + strings.Repeat(fill, n-n/2), nil
+    case '=':
+        return prefix + strings.Repeat(fill, n) + body, nil
+    }
+    return strings.Repeat(fill, n) + prefix + body, nil
+}
+
+// layoutNumber assembles a number from its sign and prefix, its integer
+// digits and whatever follows them. Zero padding is grouped like the digits.
+func (s *formatSpec) layoutNumber(prefix, digits, rest string, every int) (string, *Error) {
+    if s.fill == '0' && s.align == '=' && s.grouping != 0 {
+        // the fewest digits that, separators and all, fill the width
+        target := s.width - len(prefix) - utf8.RuneCountInString(rest)
+        n := max(len(digits), target*every/(every+1))
+        for n+(n-1)/every < target {
+            n++
+        }
+        if _, err := allocation(n, 1); err != nil {
+            return "", err
+        }
+        digits = strings.Repeat("0", n-len(digits)) + digits
+    }
+    return s.pad(prefix, groupDigits(digits, s.grouping, every)+rest, '>')
+}
+
+func (s *formatSpec) signOf(negative bool) string {
+    switch {
+    case negative:
+        return "-"
+    case s.sign == '+':
+        return "+"
+    case s.sign == ' ':
+        return " "
+    }
+    return ""
+}
+
+func isFloatKind(kind byte) bool {
+    return strings.IndexByte("eEfFgGn%", kind) >= 0 && kind != 0
+}
+
+// formatInt is int.__format__ once the spec is parsed
+func formatInt(n *big.Int, s *formatSpec) (string, *Error) {
+    if isFloatKind(s.kind) && s.kind != 'n' {
+        f, err := intToFloat(n)
+        if err != nil {
+            return "", err
+        }
+        return formatFloat(f, s, "int")
+    }
+    if s.noNegZero {
+        return "", valueError("Negative zero coercion (z) not allowed in integer format specifier")
+    }
+    if s.precision >= 0 {
+        return "", valueError("Precision not allowed in integer format specifier")
+    }
+
+    base, prefix := 10, ""
+    switch s.kind {
+    case 0, 'd', 'n':
+    case 'b':
+        base, prefix = 2, "0b"
+    case 'o':
+        base, prefix = 8, "0o"
+    case 'x':
+        base, prefix = 16, "0x"
+    case 'X':
+        base, prefix = 16, "0X"
+    case 'c':
+        if s.sign != 0 {
+            return "", valueError("Sign not allowed with integer format specifier 'c'")
+        }
+        if s.alternate {
+            return "", valueError("Alternate form (#) not allowed with integer format specifier 'c'")
+        }
+        if !n.IsInt64() || n.Int64() < 0 || n.Int64() > 0x10ffff {
+            return "", overflowError("%%c arg not in range(0x110000)")
+        }
+        return s.pad("", string(rune(n.Int64())), '>')
+    default:
+        return "", valueError("Unknown format code '%c' for object of type 'int'", s.kind)
+    }
+    if !s.alternate {
+        prefix = ""
+    }
+    digits := new(big.Int).Abs(n).Text(base)
+    if s.kind == 'X' {
+        digits = strings.ToUpper(digits)
+    }
+    every := 3
+    if base != 10 {
+        every = 4
+    }
+    return s.layoutNumber(s.signOf(n.Sign() < 0)+prefix, digits, "", every)
+}
+
+// formatGeneral is the 'g' presentation: p significant digits, fixed or
+// scientific depending on the exponent. dotZero is what an empty type does
+// with a precision - always a digit after the point, and exponents sooner.
+func formatGeneral(f float64, p int, alternate, dotZero bool) string {
+    if p == 0 {
+        p = 1
+    }
+    scientific := strconv.FormatFloat(f, 'e', p-1, 64)
+    _, exponent, _ := strings.Cut(scientific, "e")
+    exp, _ := strconv.Atoi(exponent)
+    limit := p
+    if dotZero {
+        limit = p - 1
+    }
+
+    text := scientific
+    if exp >= -4 && exp < limit {
+        text = strconv.FormatFloat(f, 'f', p-1-exp, 64)
+    }
+    mantissa, suffix, hasExp := strings.Cut(text, "e")
+    if !alternate && strings.Contains(mantissa, ".") {
+        mantissa = strings.TrimRight(strings.TrimRight(mantissa, "0"), ".")
+    }
+    switch {
+    case alternate && !strings.Contains(mantissa, "."):
+        mantissa += "."
+    case dotZero && !hasExp && !strings.Contains(mantissa, "."):
+        mantissa += ".0"
+    }
+    if hasExp {
+        return mantissa + "e" + suffix
+    }
+    return mantissa
+}
+
+// formatFloat is float.__format__ once the spec is parsed
+func formatFloat(f float64, s *formatSpec, typeName string) (string, *Error) {
+    switch s.kind {
+    case 0, 'e', 'E', 'f', 'F', 'g', 'G', 'n', '%':
+    default:
+        return "", valueError("Unknown format code '%c' for object of type '%s'", s.kind, typeName)
+    }
+    precision := s.precision
+    if precision < 0 && s.kind != 0 {
+        precision = 6
+    }
+    if precision > math.MaxInt32 {
+        return "", valueError("precision too big")
+    }
+
+    negative := math.Signbit(f) && !math.IsNaN(f)
+    a := math.Abs(f)
+    suffix := ""
+    if s.kind == '%' {
+        a *= 100
+        suffix = "%"
+    }
+
+    var body string
+    switch {
+    case math.IsInf(a, 0):
+        body = "inf"
+    case math.IsNaN(a):
+        body = "nan"
+    case s.kind == 'f' || s.kind == 'F' || s.kind == '%':
+        body = strconv.FormatFloat(a, 'f', precision, 64)
+        if s.alternate && precision == 0 {
+            body += "."
+        }
+    case s.kind == 'e' || s.kind == 'E':
+        body = strconv.FormatFloat(a, 'e', precision, 64)
+        if s.alternate && precision == 0 {
+            body = strings.Replace(body, "e", ".e", 1)
+        }
+    case s.kind == 0 && precision < 0:
+        body = floatRepr(a)
+    default:
+        body = formatGeneral(a, precision, s.alternate, s.kind == 0)
+    }
+    if strings.IndexByte("EFG", s.kind) >= 0 {
+        body = strings.ToUpper(body)
+    }
+    if negative && s.noNegZero && strings.Trim(body, "0.") == "" {
+        negative = false
+    }
+
+    digits := body
+    rest := suffix
+    if end := strings.IndexFunc(body, func(r rune) bool { return r < '0' || r > '9' }); end >= 0 {
+        digits, rest = body[:end], body[end:]+suffix
+    }
+    if s.kind == 'n' {
+        s.grouping = 0
+    }
+    return s.layoutNumber(s.signOf(negative), digits, rest, 3)
+}
+
+// formatComplex is complex.__format__ once the spec is parsed. Each part
+// is formatted like a float, the imaginary one always with a sign, and the
+// width applies to the pair. With no type it is str() with a precision: a
+// plain zero real part is left out, otherwise the pair goes in parentheses.
+func formatComplex(c complex128, s *formatSpec) (string, *Error) {
+    if s.fill == '0' {
+        return "", valueError("Zero padding is not allowed in complex format specifier")
+    }
+    if s.align == '=' {
+        return "", valueError("'=' alignment flag is not allowed in complex format specifier")
+    }
+    part := *s
+    part.fill, part.align, part.width = 0, 0, -1
+    shortest, skipReal, parens := false, false, false
+    switch s.kind {
+    case 0:
+        if s.precision < 0 {
+            shortest = true
+        } else {
+            part.kind = 'g'
+        }
+        skipReal = real(c) == 0 && !math.Signbit(real(c))
+        parens = !skipReal
+    case 'n':
+        part.kind = 'g'
+    case 'e', 'E', 'f', 'F', 'g', 'G':
+    default:
+        return "", valueError("Unknown format code '%c' for object of type 'complex'", s.kind)
+    }
+
+    format := func(f float64, spec formatSpec) (string, *Error) {
+        text, err := formatFloat(f, &spec, "complex")
+        if shortest {
+            // str(complex) writes 2j, not 2.0j
+            text = strings.TrimSuffix(text, ".0")
+        }
+        return text, err
+    }
+    body := ""
+    if !skipReal {
+        re, err := format(real(c), part)
+        if err != nil {
+            return "", err
+        }
+        body = re
+        part.sign = '+'
+    }
+    im, err := format(imag(c), part)
+    if err != nil {
+        return "", err
+    }
+    body += im + "j"
+    if parens {
+        body = "(" + body + ")"
+    }
+    return s.pad("", body, '>')
+}
+
+// formatString is str.__format__ once the spec is parsed
+func formatString(text string, s *formatSpec) (string, *Error) {
+    switch {
+    case s.kind != 0 && s.kind != 's':
+        return "", valueError("Unknown format code '%c' for object of type 'str'", s.kind)
+    case s.sign != 0:
+        return "", valueError("Sign not allowed in string format specifier")
+    case s.noNegZero:
+        return "", valueError("Negative zero coercion (z) not allowed in string format specifier")
+    case s.alternate:
+        return "", valueError("Alternate form (#) not allowed in string format specifier")
+    case s.align == '=':
+        return "", valueError("'=' alignment not allowed in string format specifier")
+    case s.grouping != 0:
+        return "", valueError("Cannot specify '%c' with 's'.", s.grouping)
+    }
+    if s.precision >= 0 {
+        if runes := []rune(text); len(runes) > s.precision {
+            text = string(runes[:s.precision])
+        }
+    }
+    return s.pad("", text, '<')
+}
+
+// formatValue is format(value, spec): whatever the type's __format__ says
+func formatValue(env *Environment, value Object, spec string) Object {
+    method := typeOf(value).lookupName("__format__")
+    result := callMethod(env, method, value, &String{Value: spec})
+    if isError(result) {
+        return result
+    }
+    if _, ok := payload(result).(*String); !ok {
+        return typeError("__format__ must return a str, not %s", typeName(result))
+    }
+    return result
+}
+
+// formatSpecArgument is the spec handed to a __format__ method
+func formatSpecArgument(obj Object) (string, *Error) {
+    s, ok := payload(obj).(*String)
+    if !ok {
+        return "", typeError("__format__() argument must be str, not %s", typeName(obj))
+    }
+    return s.Value, nil
+}
+
+// asciiEscape is what ascii() does to a repr: everything past ASCII escaped
+func asciiEscape(s string) string {
+    var b strings.Builder
+    for _, r := range s {
+        if r < utf8.RuneSelf {
+            b.WriteRune(r)
+        } else {
+            b.WriteString(escapeCodePoint(r))
+        }
+    }
+    return b.String()
+}
+
+func asciiOf(env *Environment, obj Object) (string, *Error) {
+    s, err := reprString(env, obj)
+    if err != nil {
+        return "", err
+    }
+    return asciiEscape(s), nil
+}
+
+// convertField is the !s, !r and !a of a replacement field, and %s, %r
+// and %a
+func convertField(env *Environment, obj Object, conversion rune) (string, *Error) {
+    switch conversion {
+    case 'r':
+        return reprString(env, obj)
+    case 'a':
+        return asciiOf(env, obj)
+    }
+    s := strOf(env, obj)
+    if err, ok := s.(*Error); ok {
+        return "", err
+    }
+    return payload(s).(*String).Value, nil
+}
+
+// formatter is the state of one str.format call: the arguments and how
+// far automatic field numbering has got
+type formatter struct {
+    env      *Environment
+    args     []Object
+    kwargs   Object // a mapping; nil when there are no keywords at all
+    next     int
+    numbered int // 0 undecided, 1 automatic, 2 manual
+}
+
+func (f *formatter) format(text string, depth int) (string, *Error) {
+    if depth <= 0 {
+        return "", valueError("Max string recursion exceeded")
+    }
+    runes := []rune(text)
+    var b strings.Builder
+    for i := 0; i < len(runes); i++ {
+        c := runes[i]
+        if c == '}' {
+            if i+1 < len(runes) && runes[i+1] == '}' {
+                b.WriteRune('}')
+                i++
+                continue
+            }
+            return "", valueError("Single '}' encountered in format string")
+        }
+        if c != '{' {
+            b.WriteRune(c)
+            continue
+        }
+        if i+1 == len(runes) {
+            return "", valueError("Single '{' encountered in format string")
+        }
+        if runes[i+1] == '{' {
+            b.WriteRune('{')
+            i++
+            continue
+        }
+
+        // braces only nest inside the spec; in the name, [...] hides them
+        nesting, end, inSpec := 1, i+1, false
+    scan:
+        for ; end < len(runes); end++ {
+            switch runes[end] {
+            case ':':
+                inSpec = true
+            case '[':
+                for !inSpec && end < len(runes)-1 && runes[end] != ']' {
+                    end++
+                }
+            case '{':
+                if inSpec {
+                    nesting++
+                }
+            case '}':
+                if nesting--; nesting == 0 {
+                    break scan
+                }
+            }
+        }
+        if end == len(runes) {
+            return "", valueError("expected '}' before end of string")
+        }
+        s, err := f.field(runes[i+1:end], depth)
+        if err != nil {
+            return "", err
+        }
+        b.WriteString(s)
+        i = end
+    }
+    return b.String(), nil
+}
+
+// field renders one replacement field: name, then !conversion, then :spec
+func (f *formatter) field(field []rune, depth int) (string, *Error) {
+    i := 0
+scan:
+    for ; i < len(field); i++ {
+        switch field[i] {
+        case '{':
+            return "", valueError("unexpected '{' in field name")
+        case '[':
+            for i < len(field) && field[i] != ']' {
+                i++
+            }
+            if i == len(field) {
+                break scan
+            }
+        case '!', ':':
+            break scan
+        }
+    }
+    name := field[:i]
+
+    conversion := rune(0)
+    if i < len(field) && field[i] == '!' {
+        if i+1 == len(field) {
+            return "", valueError("unmatched '{' in format spec")
+        }
+        conversion = field[i+1]
+        i += 2
+        if i < len(field) && field[i] != ':' {
+            return "", valueError("expected ':' after conversion specifier")
+        }
+    }
+    spec := ""
+    if i < len(field) {
+        spec = string(field[i+1:])
+    }
+
+    // the field takes its automatic number before any nested in its spec
+    value, err := f.lookup(name)
+    if err != nil {
+        return "", err
+    }
+    if strings.ContainsRune(spec, '{') {
+        expanded, err := f.format(spec, depth-1)
+        if err != nil {
+            return "", err
+        }
+        spec = expanded
+    }
+    if conversion != 0 {
+        if conversion != 'r' && conversion != 's' && conversion != 'a' {
+            return "", valueError("Unknown conversion specifier %c", conversion)
+        }
+        s, err := convertField(f.env, value, conversion)
+        if err != nil {
+            return "", err
+        }
+        value = &String{Value: s}
+    }
+    result := formatValue(f.env, value, spec)
+    if err, ok := result.(*Error); ok {
+        return "", err
+    }
+    return payload(result).(*String).Value, nil
+}
+
+// lookup finds the object a field name refers to: an argument, then any
+// number of .attribute and [index] steps
+func (f *formatter) lookup(name []rune) (Object, *Error) {
+    i := 0
+    for i < len(name) && name[i] != '.' && name[i] != '[' {
+        i++
+    }
+    first := string(name[:i])
+
+    var value Object
+    index, isIndex := -1, first == ""
+    if n, err := strconv.Atoi(first); err == nil && first[0] != '+' && first[0] != '-' {
+        index, isIndex = n, true
+    }
+    switch {
+    case first == "":
+        if f.numbered == 2 {
+            return nil, valueError("cannot switch from manual field specification to automatic field numbering")
+        }
+        f.numbered = 1
+        index = f.next
+        f.next++
+    case isIndex:
+        if f.numbered == 1 {
+            return nil, valueError("cannot switch from automatic field numbering to manual field specification")
+        }
+        f.numbered = 2
+    }
+    if isIndex {
+        if index >= len(f.args) {
+            return nil, indexError("Replacement index %d out of range for positional args tuple", index)
+        }
+        value = f.args[index]
+    } else {
+        key := &String{Value: first}
+        if f.kwargs == nil {
+            return nil, keyError(key)
+        }
+        value = getItem(f.env, f.kwargs, key)
+        if err, ok := value.(*Error); ok {
+            return nil, err
+        }
+    }
+
+    for i < len(name) {
+        if name[i] == '.' {
+            start := i + 1
+            for i = start; i < len(name) && name[i] != '.' && name[i] != '['; i++ {
+            }
+            attr := string(name[start:i])
+            if attr == "" {
+                return nil, valueError("Empty attribute in format string")
+            }
+            value = getAttribute(f.env, value, attr)
+        } else {
+            start := i + 1
+            for i = start; i < len(name) && name[i] != ']'; i++ {
+            }
+            if i == len(name) {
+                return nil, valueError("Missing ']' in format string")
+            }
+            text := string(name[start:i])
+            if text == "" {
+                return nil, valueError("Empty attribute in format string")
+            }
+            i++
+            if i < len(name) && name[i] != '.' && name[i] != '[' {
+                return nil, valueError("Only '.' or '[' may follow ']' in format field specifier")
+            }
+            var key Object = &String{Value: text}
+            if n, err := strconv.Atoi(text); err == nil && text[0] != '+' && text[0] != '-' {
+                key = newInt(int64(n))
+            }
+            value = getItem(f.env, value, key)
+        }
+        if err, ok := value.(*Error); ok {
+            return nil, err
+        }
+    }
+    return value, nil
+}
+
+// percentFormat is format % args, printf-style
+func percentFormat(env *Environment, format string, args Object) Object {
+    items := []Object{args}
+    if tuple, ok := payload(args).(*Tuple); ok {
+        items = tuple.Elements
+    }
+    // like CPython, anything subscriptable that isn't a tuple or a str can
+    // feed %(name)s - and then spare arguments go unremarked
+    var mapping Object
+    if _, isTuple := payload(args).(*Tuple); !isTuple {
+        if _, isStr := payload(args).(*String); !isStr && typeOf(args).lookupName("__getitem__") != nil {
+            mapping = args
+        }
+    }
+
+    next := 0
+    nextArg := func() (Object, *Error) {
+        if next >= len(items) {
+            return nil, typeError("not enough arguments for format string")
+        }
+        next++
+        return items[next-1], nil
+    }
+
+    runes := []rune(format)
+    var b strings.Builder
+    for i := 0; i < len(runes); i++ {
+        if runes[i] != '%' {
+            b.WriteRune(runes[i])
+            continue
+        }
+        i++
+        if i == len(runes) {
+            return valueError("incomplete format")
+        }
+
+        var arg Object
+        if runes[i] == '(' {
+            if mapping == nil {
+                return typeError("format requires a mapping")
+            }
+            depth, start := 1, i+1
+            for i++; i < len(runes) && depth > 0; i++ {
+                switch runes[i] {
+                case '(':
+                    depth++
+                case ')':
+                    depth--
+                }
+            }
+            if depth > 0 {
+                return valueError("incomplete format key")
+            }
+            arg = getItem(env, mapping, &String{Value: string(runes[start : i-1])})
+            if isError(arg) {
+                return arg
+            }
+        }
+
+        spec := &formatSpec{width: -1, precision: -1, align: '>'}
+        zero := false
+    flags:
+        for ; i < len(runes); i++ {
+            switch runes[i] {
+            case '-':
+                spec.align = '<'
+            case '+':
+                spec.sign = '+'
+            case ' ':
+                if spec.sign == 0 {
+                    spec.sign = ' '
+                }
+            case '#':
+                spec.alternate = true
+            case '0':
+                zero = true
+            default:
+                break flags
+            }
+        }
+        // number is a width or precision: digits, or * to take it from the
+        // arguments. Either way it has to fit the C type CPython keeps it in.
+        number := func(what string, limit int64, ctype string) (int, bool, *Error) {
+            if i < len(runes) && runes[i] == '*' {
+                i++
+                value, err := nextArg()
+                if err != nil {
+                    return 0, false, err
+                }
+                n, ok := toBigInt(value)
+                if !ok {
+                    return 0, false, typeError("* wants int")
+                }
+                if !n.IsInt64() || n.Int64() > limit || n.Int64() < -limit {
+                    return 0, false, overflowError("Python int too large to convert to C %s", ctype)
+                }
+                return int(n.Int64()), true, nil
+            }
+            n, given := 0, false
+            for ; i < len(runes) && runes[i] >= '0' && runes[i] <= '9'; i++ {
+                if int64(n) > (limit-int64(runes[i]-'0'))/10 {
+                    return 0, false, valueError("%s too big", what)
+                }
+                n, given = n*10+int(runes[i]-'0'), true
+            }
+            return n, given, nil
+        }
+        width, given, err := number("width", math.MaxInt64, "ssize_t")
+        if err != nil {
+            return err
+        }
+        if width < 0 {
+            spec.align, width = '<', -width
+        }
+        if given {
+            spec.width = width
+        }
+        if i < len(runes) && runes[i] == '.' {
+            i++
+            precision, _, err := number("precision", math.MaxInt32, "int")
+            if err != nil {
+                return err
+            }
+            spec.precision = max(precision, 0)
+        }
+        for i < len(runes) && (runes[i] == 'h' || runes[i] == 'l' || runes[i] == 'L') {
+            i++
+        }
+        if i == len(runes) {
+            return valueError("incomplete format")
+        }
+        if zero && spec.align != '<' {
+            spec.fill, spec.align = '0', '='
+        }
+
+        conversion := runes[i]
+        if conversion == '%' {
+            b.WriteRune('%')
+            continue
+        }
+        if arg == nil {
+            if arg, err = nextArg(); err != nil {
+                return err
+            }
+        }
+        s, err := percentConvert(env, conversion, arg, spec)
+        if err != nil {
+            if err == errUnsupportedConversion {
+                c := conversion
+                if c >= utf8.RuneSelf {
+                    c = '?'
+                }
+                return valueError("unsupported format character '%c' (0x%x) at index %d", c, conversion, i)
+            }
+            return err
+        }
+        b.WriteString(s)
+    }
+    if next < len(items) && mapping == nil {
+        return typeError("not all arguments converted during string formatting")
+    }
+    return &String{Value: b.String()}
+}
+
+var errUnsupportedConversion = &Error{}
+
+// percentConvert renders one %-conversion of arg
+func percentConvert(env *Environment, conversion rune, arg Object, spec *formatSpec) (string, *Error) {
+    switch conversion {
+    case 's', 'r', 'a':
+        s, err := convertField(env, arg, conversion)
+        if err != nil {
+            return "", err
+        }
+        if spec.precision >= 0 {
+            if runes := []rune(s); len(runes) > spec.precision {
+                s = string(runes[:spec.precision])
+            }
+        }
+        spec.fill = ' '
+        if spec.align == '=' {
+            spec.align = '>'
+        }
+        return spec.pad("", s, '>')
+
+    case 'c':
+        var r rune
+        if s, ok := payload(arg).(*String); ok && utf8.RuneCountInString(s.Value) == 1 {
+            r, _ = utf8.DecodeRuneInString(s.Value)
+        } else if n, ok := toBigInt(arg); ok {
+            if !n.IsInt64() || n.Int64() < 0 || n.Int64() > 0x10ffff {
+                return "", overflowError("%%c arg not in range(0x110000)")
+            }
+            r = rune(n.Int64())
+        } else {
+            return "", typeError("%%c requires int or char")
+        }
+        spec.fill = ' '
+        if spec.align == '=' {
+            spec.align = '>'
+        }
+        return spec.pad("", string(r), '>')
+
+    case 'd', 'i', 'u', 'x', 'X', 'o':
+        n, ok := toBigInt(arg)
+        if !ok {
+            cls := typeOf(arg)
+            var value Object
+            switch {
+            case conversion == 'x' || conversion == 'X' || conversion == 'o':
+                if cls.lookupName("__index__") == nil {
+                    return "", typeError("%%%c format: an integer is required, not %s", conversion, cls.Name)
+                }
+                i, err := indexValue(env, arg)
+                if err != nil {
+                    return "", err
+                }
+                value = newBigInt(i)
+            case cls.lookupName("__int__") != nil || cls.lookupName("__index__") != nil || cls.lookupName("__float__") != nil:
+                value = newIntValue(env, arg, nil)
+            default:
+                return "", typeError("%%%c format: a real number is required, not %s", conversion, cls.Name)
+            }
+            if err, ok := value.(*Error); ok {
+                return "", err
+            }
+            n, _ = toBigInt(value)
+        }
+        base, prefix := 10, ""
+        switch conversion {
+        case 'x':
+            base, prefix = 16, "0x"
+        case 'X':
+            base, prefix = 16, "0X"
+        case 'o':
+            base, prefix = 8, "0o"
+        }
+        if !spec.alternate {
+            prefix = ""
+        }
+        digits := new(big.Int).Abs(n).Text(base)
+        if conversion == 'X' {
+            digits = strings.ToUpper(digits)
+        }
+        if len(digits) < spec.precision {
+            if _, err := allocation(spec.precision, 1); err != nil {
+                return "", err
+            }
+            digits = strings.Repeat("0", spec.precision-len(digits)) + digits
+        }
+        return spec.pad(spec.signOf(n.Sign() < 0)+prefix, digits, '>')
+
+    case 'e', 'E', 'f', 'F', 'g', 'G':
+        f, ok, err := toFloat(arg)
+        if err != nil {
+            return "", err
+        }
+        if !ok {
+            if typeOf(arg).lookupName("__float__") == nil {
+                return "", typeError("must be real number, not %s", typeName(arg))
+            }
+            value := newFloatValue(env, arg)
+            if err, ok := value.(*Error); ok {
+                return "", err
+            }
+            f = value.(*Float).Value
+        }
+        spec.kind = byte(conversion)
+        return formatFloat(f, spec, "float")
+    }
+    return "", errUnsupportedConversion
+}
+
+func init() {
+    objectType.method("__format__", 1, 1, func(env *Environment, args []Object) Object {
+        spec, err := formatSpecArgument(args[1])
+        if err != nil {
+            return err
+        }
+        if spec != "" {
+            return typeError("unsupported format string passed to %s.__format__", typeName(args[0]))
+        }
+        return strOf(env, args[0])
+    })
+    intType.method("__format__", 1, 1, func(env *Environment, args []Object) Object {
+        spec, err := formatSpecArgument(args[1])
+        if err != nil {
+            return err
+        }
+        if spec == "" {
+            return strOf(env, args[0])
+        }
+        s, err := parseFormatSpec(spec, typeName(args[0]), '>')
+        if err != nil {
+            return err
+        }
+        n, _ := toBigInt(args[0])
+        text, err := formatInt(n, s)
+        if err != nil {
+            return err
+        }
+        return &String{Value: text}
+    })
+    floatType.method("__format__", 1, 1, func(env *Environment, args []Object) Object {
+        spec, err := formatSpecArgument(args[1])
+        if err != nil {
+            return err
+        }
+        if spec == "" {
+            return strOf(env, args[0])
+        }
+        s, err := parseFormatSpec(spec, typeName(args[0]), '>')
+        if err != nil {
+            return err
+        }
+        text, err := formatFloat(payload(args[0]).(*Float).Value, s, typeName(args[0]))
+        if err != nil {
+            return err
+        }
+        return &String{Value: text}
+    })
+    complexType.method("__format__", 1, 1, func(env *Environment, args []Object) Object {
+        spec, err := formatSpecArgument(args[1])
+        if err != nil {
+            return err
+        }
+        if spec == "" {
+            return strOf(env, args[0])
+        }
+        s, err := parseFormatSpec(spec, typeName(args[0]), '>')
+        if err != nil {
+            return err
+        }
+        text, err := formatComplex(asComplex(args[0]).Value, s)
+        if err != nil {
+            return err
+        }
+        return &String{Value: text}
+    })
+    strType.method("__format__", 1, 1, func(env *Environment, args []Object) Object {
+        spec, err := formatSpecArgument(args[1])
+        if err != nil {
+            return err
+        }
+        s, err := parseFormatSpec(spec, typeName(args[0]), '<')
+        if err != nil {
+            return err
+        }
+        text, err := formatString(asString(args[0]).Value, s)
+        if err != nil {
+            return err
+        }
+        return &String{Value: text}
+    })
+
+    strType.method("__mod__", 1, 1, func(env *Environment, args []Object) Object {
+        return percentFormat(env, asString(args[0]).Value, args[1])
+    })
+    strType.define("format", func(env *Environment, args []Object, kwargs *Dict) Object {
+        f := &formatter{env: env, args: args[1:]}
+        if kwargs != nil {
+            f.kwargs = kwargs
+        }
+        s, err := f.format(asString(args[0]).Value, 2)
+        if err != nil {
+            return err
+        }
+        return &String{Value: s}
+    })
+    strType.method("format_map", 1, 1, func(env *Environment, args []Object) Object {
+        f := &formatter{env: env, kwargs: args[1]}
+        s, err := f.format(asString(args[0]).Value, 2)
+        if err != nil {
+            return err
+        }
+        return &String{Value: s}
+    })
+
+    registerBuiltin("format", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("format", args, kwargs, 1, 2); err != nil {
+            return err
+        }
+        spec := ""
+        if len(args) == 2 {
+            s, ok := payload(args[1]).(*String)
+            if !ok {
+                return typeError("format() argument 2 must be str, not %s", typeName(args[1]))
+            }
+            spec = s.Value
+        }
+        return formatValue(env, args[0], spec)
+    })
+}
@@ -0,0 +1,691 @@
+// Comments in this file are inspired by Esther Litt - she wraps every deal in someone else's name, and remembers what it cost last time
+
+package evaluator
+
+import (
+    "fmt"
+    "strings"
+)
+
+// functools: reduce, partial, the wrapper helpers, lru_cache and friends.
+// partial is a Go value of its own, the way a deque is; the caching and
+// dispatching wrappers are builtins that pass for the function they wrap
+
+var partialType = newBuiltinClass("partial", objectType)
+
+// Partial is a callable with some of its arguments already filled in
+type Partial struct {
+    Fn       Object
+    Args     []Object
+    Keywords *Dict
+    Dict     *Dict
+}
+
+func (p *Partial) Type() ObjectType { return PARTIAL_OBJ }
+func (p *Partial) Inspect() string {
+    parts := []string{p.Fn.Inspect()}
+    for _, arg := range p.Args {
+        parts = append(parts, arg.Inspect())
+    }
+    for _, entry := range p.Keywords.Entries() {
+        parts = append(parts, fmt.Sprintf("%s=%s", entry.Key.(*String).Value, entry.Value.Inspect()))
+    }
+    return "functools.partial(" + strings.Join(parts, ", ") + ")"
+}
+
+func asPartial(obj Object) *Partial { return payload(obj).(*Partial) }
+
+// the marker that keeps lru_cache's positional and keyword arguments apart
+var keywordMark = &Instance{Class: objectType, Dict: NewDict()}
+
+func init() {
+    registerModule("functools", buildFunctools)
+
+    partialType.Dict.SetStr("__module__", &String{Value: "functools"})
+    initPartial()
+}
+
+func buildFunctools(m *Module) {
+    assignments := &Tuple{Elements: []Object{&String{Value: "__module__"}, &String{Value: "__name__"}, &String{Value: "__qualname__"}, &String{Value: "__doc__"}, &String{Value: "__annotations__"}}}
+    updates := &Tuple{Elements: []Object{&String{Value: "__dict__"}}}
+    m.Env.Set("WRAPPER_ASSIGNMENTS", assignments)
+    m.Env.Set("WRAPPER_UPDATES", updates)
+    m.Env.Set("partial", partialType)
+
+    m.function("reduce", reduce)
+
+    updater := &Builtin{Name: "update_wrapper", Fn: func(env *Environment, args []Object, kwargs *Dict) Object {
+        values, err := parseArgs("update_wrapper", args, kwargs, []string{"wrapper", "wrapped", "assigned", "updated"}, 2)
+        if err != nil {
+            return err
+        }
+        if values[2] == nil {
+            values[2] = assignments
+        }
+        if values[3] == nil {
+            values[3] = updates
+        }
+        return updateWrapper(env, values[0], values[1], values[2], values[3])
+    }}
+    m.Env.Set("update_wrapper", updater)
+    m.function("wraps", func(env *Environment, args []Object, kwargs *Dict) Object {
+        values, err := parseArgs("wraps", args, kwargs, []string{"wrapped", "assigned", "updated"}, 1)
+        if err != nil {
+            return err
+        }
+        keywords := NewDict()
+        keywords.SetStr("wrapped", values[0])
+        keywords.SetStr("assigned", orDefault(values[1], assignments))
+        keywords.SetStr("updated", orDefault(values[2], updates))
+        return &Partial{Fn: updater, Keywords: keywords, Dict: NewDict()}
+    })
+
+    cacheInfo := namedTuple(m.Env, []Object{&String{Value: "CacheInfo"}, &String{Value: "hits misses maxsize currsize"}}, nil)
+    if err, ok := cacheInfo.(*Error); ok {
+        panic(err.Inspect())
+    }
+    cacheInfo.(*Class).Dict.SetStr("__module__", &String{Value: "functools"})
+    m.Env.Set("_CacheInfo", cacheInfo)
+    m.function("lru_cache", func(env *Environment, args []Object, kwargs *Dict) Object {
+        values, err := parseArgs("lru_cache", args, kwargs, []string{"maxsize", "typed"}, 0)
+        if err != nil {
+            return err
+        }
+        maxsize, typed := 128, false
+        if values[1] != nil {
+            if typed, err = truthy(env, values[1]); err != nil {
+                return err
+            }
+        }
+        switch arg := orDefault(values[0], newInt(128)); {
+        case arg == NULL:
+            maxsize = -1
+        case isInt(arg):
+            if maxsize, err = toIndex(env, arg); err != nil {
+                return err
+            }
+            maxsize = max(maxsize, 0)
+        case isCallable(arg):
+            if _, ok := orDefault(values[1], FALSE).(*Boolean); ok {
+                return lruWrapper(env, arg, 128, typed, cacheInfo, assignments, updates)
+            }
+            fallthrough
+        default:
+            return typeError("Expected first argument to be an integer, a callable, or None")
+        }
+        return &Builtin{Name: "decorating_function", Fn: func(env *Environment, args []Object, kwargs *Dict) Object {
+            if err := argumentCount("decorating_function", args, kwargs, 1); err != nil {
+                return err
+            }
+            return lruWrapper(env, args[0], maxsize, typed, cacheInfo, assignments, updates)
+        }}
+    })
+    m.function("cache", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := argumentCount("cache", args, kwargs, 1); err != nil {
+            return err
+        }
+        return lruWrapper(env, args[0], -1, false, cacheInfo, assignments, updates)
+    })
+
+    initCachedProperty(m.class("cached_property"))
+    m.function("total_ordering", totalOrdering)
+    m.function("singledispatch", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := argumentCount("singledispatch", args, kwargs, 1); err != nil {
+            return err
+        }
+        return singleDispatch(env, args[0], assignments, updates)
+    })
+    m.function("cmp_to_key", func(env *Environment, args []Object, kwargs *Dict) Object {
+        values, err := parseArgs("cmp_to_key", args, kwargs, []string{"mycmp"}, 1)
+        if err != nil {
+            return err
+        }
+        return cmpToKey(values[0])
+    })
+}
+
+func orDefault(obj, fallback Object) Object {
+    if obj == nil {
+        return fallback
+    }
+    return obj
+}
+
+func isInt(obj Object) bool {
+    switch payload(obj).(type) {
+    case *Integer, *Boolean:
+        return true
+    }
+    return false
+}
+
+func reduce(env *Environment, args []Object, kwargs *Dict) Object {
+    if err := checkArgs("reduce", args, kwargs, 2, 3); err != nil {
+        return err
+    }
+    var total Object
+    if len(args) == 3 {
+        total = args[2]
+    }
+    err := iterate(env, args[1], func(item Object) *Error {
+        if total == nil {
+            total = item
+            return nil
+        }
+        total = applyFunction(env, args[0], []Object{total, item}, nil)
+        if err, ok := total.(*Error); ok {
+            return err
+        }
+        return nil
+    })
+    if err != nil {
+        return err
+    }
+    if total == nil {
+        return typeError("reduce() of empty iterable with no initial value")
+    }
+    return total
+}
+
+func initPartial() {
+    partialType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        cls, err := newClassArg("partial", args)
+        if err != nil {
+            return err
+        }
+        if len(args) < 2 {
+            return typeError("type 'partial' takes at least one argument")
+        }
+        fn := args[1]
+        if !isCallable(fn) {
+            return typeError("the first argument must be callable")
+        }
+        p := &Partial{Fn: fn, Args: append([]Object{}, args[2:]...), Keywords: NewDict(), Dict: NewDict()}
+        // a partial of a plain partial is just a longer partial
+        if inner, ok := fn.(*Partial); ok && inner.Dict.Len() == 0 {
+            p.Fn = inner.Fn
+            p.Args = append(append([]Object{}, inner.Args...), p.Args...)
+            p.Keywords = inner.Keywords.Copy()
+        }
+        for _, entry := range kwargs.Entries() {
+            p.Keywords.SetStr(entry.Key.(*String).Value, entry.Value)
+        }
+        return wrapBuiltinValue(cls, partialType, p)
+    })
+    partialType.define("__call__", func(env *Environment, args []Object, kwargs *Dict) Object {
+        p := asPartial(args[0])
+        keywords := p.Keywords.Copy()
+        for _, entry := range kwargs.Entries() {
+            keywords.SetStr(entry.Key.(*String).Value, entry.Value)
+        }
+        return applyFunction(env, p.Fn, append(append([]Object{}, p.Args...), args[1:]...), keywords)
+    })
+    partialType.method("__repr__", 0, 0, func(env *Environment, args []Object) Object {
+        p := asPartial(args[0])
+        name := "functools.partial"
+        if cls := typeOf(args[0]); cls != partialType {
+            name = cls.qualname()
+        }
+        parts := []string{}
+        for _, arg := range append([]Object{p.Fn}, p.Args...) {
+            s, err := reprString(env, arg)
+            if err != nil {
+                return err
+            }
+            parts = append(parts, s)
+        }
+        for _, entry := range p.Keywords.Entries() {
+            s, err := reprString(env, entry.Value)
+            if err != nil {
+                return err
+            }
+            parts = append(parts, entry.Key.(*String).Value+"="+s)
+        }
+        return &String{Value: name + "(" + strings.Join(parts, ", ") + ")"}
+    })
+    partialType.property("func", func(self Object) Object { return asPartial(self).Fn })
+    partialType.property("args", func(self Object) Object {
+        return &Tuple{Elements: append([]Object{}, asPartial(self).Args...)}
+    })
+    partialType.property("keywords", func(self Object) Object { return asPartial(self).Keywords })
+}
+
+// updateWrapper dresses wrapper up as wrapped: the names are copied over,
+// the dicts merged, and __wrapped__ points back at the original
+func updateWrapper(env *Environment, wrapper, wrapped, assigned, updated Object) Object {
+    err := iterate(env, assigned, func(name Object) *Error {
+        s, ok := payload(name).(*String)
+        if !ok {
+            return typeError("attribute name must be string, not '%s'", typeOf(name).Name)
+        }
+        value := getAttribute(env, wrapped, s.Value)
+        if err, ok := value.(*Error); ok {
+            if err.matches(attributeErrorType) {
+                return nil
+            }
+            return err
+        }
+        return setAttribute(env, wrapper, s.Value, value)
+    })
+    if err != nil {
+        return err
+    }
+    err = iterate(env, updated, func(name Object) *Error {
+        s, ok := payload(name).(*String)
+        if !ok {
+            return typeError("attribute name must be string, not '%s'", typeOf(name).Name)
+        }
+        target := getAttribute(env, wrapper, s.Value)
+        if err, ok := target.(*Error); ok {
+            return err
+        }
+        var source Object = NewDict()
+        if value := getAttribute(env, wrapped, s.Value); !isError(value) {
+            source = value
+        }
+        if result := callAttribute(env, target, "update", source); isError(result) {
+            return result.(*Error)
+        }
+        return nil
+    })
+    if err != nil {
+        return err
+    }
+    if err := setAttribute(env, wrapper, "__wrapped__", wrapped); err != nil {
+        return err
+    }
+    return wrapper
+}
+
+// lruCache is what an lru_cache wrapper remembers
+type lruCache struct {
+    fn      Object
+    maxsize int // -1 when there is no limit
+    typed   bool
+    entries *Dict
+    hits    int
+    misses  int
+}
+
+// key is what a call is filed under: the arguments, the keywords after a
+// marker, and with typed=True their types as well
+func (c *lruCache) key(args []Object, kwargs *Dict) *Tuple {
+    key := append([]Object{}, args...)
+    if kwargs.Len() > 0 {
+        key = append(key, keywordMark)
+        for _, entry := range kwargs.Entries() {
+            key = append(key, entry.Key, entry.Value)
+        }
+    }
+    if c.typed {
+        for _, arg := range args {
+            key = append(key, typeOf(arg))
+        }
+        for _, entry := range kwargs.Entries() {
+            key = append(key, typeOf(entry.Value))
+        }
+    }
+    return &Tuple{Elements: key}
+}
+
+func (c *lruCache) call(env *Environment, args []Object, kwargs *Dict) Object {
+    if c.maxsize == 0 {
+        c.misses++
+        return applyFunction(env, c.fn, args, kwargs)
+    }
+    key := c.key(args, kwargs)
+    cached, err := c.entries.Get(env, key)
+    if err != nil {
+        return err
+    }
+    if cached != nil {
+        c.hits++
+        if c.maxsize > 0 {
+            c.entries.moveToEnd(env, key, true)
+        }
+        return cached
+    }
+    c.misses++
+    result := applyFunction(env, c.fn, args, kwargs)
+    if isError(result) {
+        return result
+    }
+    // a recursive call may have filed this one already
+    if found, err := c.entries.Get(env, key); err != nil {
+        return err
+    } else if found != nil {
+        return result
+    }
+    if err := c.entries.Set(env, key, result); err != nil {
+        return err
+    }
+    if c.maxsize > 0 && c.entries.Len() > c.maxsize {
+        if _, err := c.entries.Delete(env, c.entries.Entries()[0].Key); err != nil {
+            return err
+        }
+    }
+    return result
+}
+
+func lruWrapper(env *Environment, fn Object, maxsize int, typed bool, cacheInfo Object, assigned, updated Object) Object {
+    c := &lruCache{fn: fn, maxsize: maxsize, typed: typed, entries: NewDict()}
+    wrapper := &Builtin{Name: wrappedName(fn, "__name__", "_lru_cache_wrapper"), Fn: c.call, Wraps: fn, Dict: NewDict()}
+    if result := updateWrapper(env, wrapper, fn, assigned, updated); isError(result) {
+        return result
+    }
+    limit := func() Object {
+        if c.maxsize < 0 {
+            return NULL
+        }
+        return newInt(int64(c.maxsize))
+    }
+    wrapper.Dict.SetStr("cache_info", &Builtin{Name: "cache_info", Fn: func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("cache_info", args, kwargs, 0, 0); err != nil {
+            return err
+        }
+        return applyFunction(env, cacheInfo, []Object{newInt(int64(c.hits)), newInt(int64(c.misses)), limit(), newInt(int64(c.entries.Len()))}, nil)
+    }})
+    wrapper.Dict.SetStr("cache_clear", &Builtin{Name: "cache_clear", Fn: func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("cache_clear", args, kwargs, 0, 0); err != nil {
+            return err
+        }
+        c.entries.clear()
+        c.hits, c.misses = 0, 0
+        return NULL
+    }})
+    wrapper.Dict.SetStr("cache_parameters", &Builtin{Name: "cache_parameters", Fn: func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("cache_parameters", args, kwargs, 0, 0); err != nil {
+            return err
+        }
+        parameters := NewDict()
+        parameters.SetStr("maxsize", limit())
+        parameters.SetStr("typed", nativeBool(c.typed))
+        return parameters
+    }})
+    return wrapper
+}
+
+// cached_property works the once, then leaves its answer in the instance
+// dict, where it is found before the property ever gets asked again
+func initCachedProperty(cls *Class) {
+    cls.method("__init__", 1, 1, func(env *Environment, args []Object) Object {
+        self, fn := args[0], args[1]
+        dict := instanceDict(self)
+        dict.SetStr("func", fn)
+        dict.SetStr("attrname", NULL)
+        doc := getAttribute(env, fn, "__doc__")
+        if isError(doc) {
+            doc = NULL
+        }
+        dict.SetStr("__doc__", doc)
+        return NULL
+    })
+    cls.method("__set_name__", 2, 2, func(env *Environment, args []Object) Object {
+        dict := instanceDict(args[0])
+        attrname, _ := dict.GetStr("attrname")
+        if attrname == nil || attrname == NULL {
+            dict.SetStr("attrname", args[2])
+            return NULL
+        }
+        same, err := equals(env, attrname, args[2])
+        if err != nil {
+            return err
+        }
+        if !same {
+            return typeError("Cannot assign the same cached_property to two different names (%s and %s).", attrname.Inspect(), args[2].Inspect())
+        }
+        return NULL
+    })
+    cls.method("__get__", 1, 2, func(env *Environment, args []Object) Object {
+        self, instance := args[0], args[1]
+        if instance == NULL {
+            return self
+        }
+        dict := instanceDict(self)
+        attrname, _ := dict.GetStr("attrname")
+        if attrname == nil || attrname == NULL {
+            return typeError("Cannot use cached_property instance without calling __set_name__ on it.")
+        }
+        cache := instanceDict(instance)
+        if cache == nil {
+            return typeError("No '__dict__' attribute on '%s' instance to cache %s property.", typeOf(instance).Name, attrname.Inspect())
+        }
+        value, err := cache.Get(env, attrname)
+        if err != nil {
+            return err
+        }
+        if value != nil {
+            return value
+        }
+        fn, _ := dict.GetStr("func")
+        value = applyFunction(env, fn, []Object{instance}, nil)
+        if isError(value) {
+            return value
+        }
+        if err := cache.Set(env, attrname, value); err != nil {
+            return err
+        }
+        return value
+    })
+}
+
+// orderingRule derives one comparison from another. After CPython's
+// _convert table: the base's answer is kept, negated, or checked
+// against == or != depending on which pair it is
+type orderingRule struct {
+    name, base string
+    derive     func(env *Environment, result, self, other Object) Object
+}
+
+func notResult(env *Environment, result, self, other Object) Object {
+    truth, err := truthy(env, result)
+    if err != nil {
+        return err
+    }
+    return nativeBool(!truth)
+}
+
+func orEqual(env *Environment, result, self, other Object) Object {
+    if truth, err := truthy(env, result); err != nil {
+        return err
+    } else if truth {
+        return result
+    }
+    return richCompare(env, "==", self, other)
+}
+
+func andNotEqual(env *Environment, result, self, other Object) Object {
+    if truth, err := truthy(env, result); err != nil {
+        return err
+    } else if !truth {
+        return result
+    }
+    return richCompare(env, "!=", self, other)
+}
+
+func notAndNotEqual(env *Environment, result, self, other Object) Object {
+    if truth, err := truthy(env, result); err != nil {
+        return err
+    } else if truth {
+        return FALSE
+    }
+    return richCompare(env, "!=", self, other)
+}
+
+func notOrEqual(env *Environment, result, self, other Object) Object {
+    if truth, err := truthy(env, result); err != nil {
+        return err
+    } else if !truth {
+        return TRUE
+    }
+    return richCompare(env, "==", self, other)
+}
+
+var orderingRules = map[string][]orderingRule{
+    "__lt__": {{"__gt__", "__lt__", notAndNotEqual}, {"__le__", "__lt__", orEqual}, {"__ge__", "__lt__", notResult}},
+    "__le__": {{"__ge__", "__le__", notOrEqual}, {"__lt__", "__le__", andNotEqual}, {"__gt__", "__le__", notResult}},
+    "__gt__": {{"__lt__", "__gt__", notAndNotEqual}, {"__ge__", "__gt__", orEqual}, {"__le__", "__gt__", notResult}},
+    "__ge__": {{"__le__", "__ge__", notOrEqual}, {"__gt__", "__ge__", andNotEqual}, {"__lt__", "__ge__", notResult}},
+}
+
+func totalOrdering(env *Environment, args []Object, kwargs *Dict) Object {
+    if err := argumentCount("total_ordering", args, kwargs, 1); err != nil {
+        return err
+    }
+    cls, ok := args[0].(*Class)
+    if !ok {
+        return args[0]
+    }
+    roots := map[string]bool{}
+    root := ""
+    // __lt__ is preferred, then __le__, __gt__ and __ge__
+    for _, name := range []string{"__ge__", "__gt__", "__le__", "__lt__"} {
+        if attr, owner := cls.lookup(name); attr != nil && owner != objectType {
+            roots[name], root = true, name
+        }
+    }
+    if root == "" {
+        return valueError("must define at least one ordering operation: < > <= >=")
+    }
+    for _, rule := range orderingRules[root] {
+        if roots[rule.name] {
+            continue
+        }
+        rule := rule
+        cls.define(rule.name, func(env *Environment, args []Object, kwargs *Dict) Object {
+            if err := argumentCount(rule.name, args[1:], kwargs, 1); err != nil {
+                return err
+            }
+            self, other := args[0], args[1]
+            result := callMethod(env, typeOf(self).lookupName(rule.base), self, other)
+            if isError(result) || result == NotImplemented {
+                return result
+            }
+            return rule.derive(env, result, self, other)
+        })
+    }
+    return cls
+}
+
+// singleDispatch picks an implementation by the class of the first argument,
+// the nearest registered one along its MRO
+func singleDispatch(env *Environment, fn Object, assigned, updated Object) Object {
+    registry := NewDict()
+    registry.Set(env, objectType, fn)
+    name := wrappedName(fn, "__name__", "singledispatch function")
+
+    dispatch := func(env *Environment, cls *Class) (Object, *Error) {
+        for _, base := range cls.MRO {
+            if base == objectType {
+                break
+            }
+            if impl, err := registry.Get(env, base); impl != nil || err != nil {
+                return impl, err
+            }
+        }
+        // then the ones it only claims to be, like the abcs
+        for _, entry := range registry.Entries() {
+            if base := entry.Key.(*Class); base != objectType {
+                if ok, err := classCheck("issubclass", cls, base); err != nil || ok {
+                    return entry.Value, err
+                }
+            }
+        }
+        impl, err := registry.Get(env, objectType)
+        return impl, err
+    }
+
+    wrapper := &Builtin{Name: name, Wraps: fn, Dict: NewDict()}
+    wrapper.Fn = func(env *Environment, args []Object, kwargs *Dict) Object {
+        if len(args) == 0 {
+            return typeError("%s requires at least 1 positional argument", name)
+        }
+        impl, err := dispatch(env, typeOf(args[0]))
+        if err != nil {
+            return err
+        }
+        return applyFunction(env, impl, args, kwargs)
+    }
+    register := func(env *Environment, args []Object, kwargs *Dict) Object {
+        values, err := parseArgs("register", args, kwargs, []string{"cls", "func"}, 1)
+        if err != nil {
+            return err
+        }
+        cls, ok := values[0].(*Class)
+        if !ok {
+            s, err := reprString(env, values[0])
+            if err != nil {
+                return err
+            }
+            return typeError("Invalid first argument to `register()`: %s. Use either `@register(some_class)` or plain `@register` on an annotated function.", s)
+        }
+        if values[1] == nil || values[1] == NULL {
+            return &Builtin{Name: "<lambda>", Fn: func(env *Environment, args []Object, kwargs *Dict) Object {
+                if err := argumentCount("<lambda>", args, kwargs, 1); err != nil {
+                    return err
+                }
+                if err := registry.Set(env, cls, args[0]); err != nil {
+                    return err
+                }
+                return args[0]
+            }}
+        }
+        if err := registry.Set(env, cls, values[1]); err != nil {
+            return err
+        }
+        return values[1]
+    }
+
+    if result := updateWrapper(env, wrapper, fn, assigned, updated); isError(result) {
+        return result
+    }
+    wrapper.Dict.SetStr("register", &Builtin{Name: "register", Fn: register})
+    wrapper.Dict.SetStr("dispatch", &Builtin{Name: "dispatch", Fn: func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := argumentCount("dispatch", args, kwargs, 1); err != nil {
+            return err
+        }
+        cls, ok := args[0].(*Class)
+        if !ok {
+            return typeError("dispatch() argument must be a class")
+        }
+        impl, err := dispatch(env, cls)
+        if err != nil {
+            return err
+        }
+        return impl
+    }})
+    wrapper.Dict.SetStr("registry", registry)
+    return wrapper
+}
+
+// cmpToKey turns an old-style comparison function into a key class whose
+// instances compare by asking it
+func cmpToKey(mycmp Object) Object {
+    dict := NewDict()
+    dict.SetStr("__module__", &String{Value: "functools"})
+    dict.SetStr("__qualname__", &String{Value: "cmp_to_key.<locals>.K"})
+    dict.SetStr("__hash__", NULL)
+    cls, err := newClass("K", nil, dict)
+    if err != nil {
+        return err
+    }
+    cls.method("__init__", 1, 1, func(env *Environment, args []Object) Object {
+        return setField(env, args[0], "obj", args[1])
+    })
+    for _, op := range []string{"<", ">", "==", "<=", ">="} {
+        op := op
+        cls.method(comparisonOperators[op][0], 1, 1, func(env *Environment, args []Object) Object {
+            other := getAttribute(env, args[1], "obj")
+            if isError(other) {
+                return other
+            }
+            self := getAttribute(env, args[0], "obj")
+            result := applyFunction(env, mycmp, []Object{self, other}, nil)
+            if isError(result) {
+                return result
+            }
+            return richCompare(env, op, result, newInt(0))
+        })
+    }
+    return cls
+}
@@ -0,0 +1,537 @@
+// Comments in this file are inspired by Samantha Wheeler - she never shows her whole hand at once
+
+package evaluator
+
+import (
+    "fmt"
+    "interpreter/parser"
+    "runtime"
+)
+
+// Generator is a function call that can stop halfway and pick up later. Its
+// body runs on a goroutine of its own, but only while the caller waits for
+// it, so the two never run at the same time.
+type Generator struct {
+    Name     string
+    Qualname string
+    state    *generatorState
+}
+
+func (g *Generator) Type() ObjectType { return GENERATOR_OBJ }
+func (g *Generator) Inspect() string {
+    return fmt.Sprintf("<generator object %s at %#x>", g.Qualname, objectID(g))
+}
+
+// generatorState is what the body's goroutine shares with its callers. The
+// goroutine never holds the Generator itself, so an abandoned generator can
+// be collected and its finalizer can stop the goroutine.
+type generatorState struct {
+    body     func() Object
+    frame    *Frame
+    interp   *interpreter
+    resume   chan generatorInput
+    yield    chan generatorOutput
+    started  bool
+    running  bool
+    finished bool
+    handling []*Exception // handlers running in the body while it is suspended
+    delegate Object       // the iterator a `yield from` is waiting on
+}
+
+// generatorInput is how a generator gets resumed
+type generatorInput struct {
+    value Object // sent by next() or send()
+    err   *Error // thrown in by throw() or close()
+    kill  bool   // abandoned: stop without running any more Python code
+}
+
+// generatorOutput is what the body hands back: a yielded value, or once
+// done, whatever evalBlock returned
+type generatorOutput struct {
+    value Object
+    done  bool
+}
+
+var generatorType = newBuiltinClass("generator", objectType)
+
+// newGenerator turns a body about to run in env into a generator
+func newGenerator(env *Environment, name, qualname string, body func() Object) *Generator {
+    state := &generatorState{
+        body:   body,
+        frame:  &Frame{Name: name, Filename: env.frame.Filename},
+        interp: env.interp,
+        resume: make(chan generatorInput, 1),
+        yield:  make(chan generatorOutput),
+    }
+    env.frame = state.frame
+    env.gen = state
+    gen := &Generator{Name: name, Qualname: qualname, state: state}
+    runtime.SetFinalizer(gen, (*Generator).abandon)
+    return gen
+}
+
+// abandon is the finalizer of a generator nobody can resume any more. Its
+// finally blocks still deserve to run, but not on the finalizer's goroutine,
+// so it waits on its interpreter for the next safe point. An interpreter that
+// is never going to run again just gets the goroutine stopped.
+func (g *Generator) abandon() {
+    s := g.state
+    if !s.started || s.finished {
+        return
+    }
+    interp := s.interp
+    interp.abandonMu.Lock()
+    released := interp.released
+    if !released {
+        interp.abandoned = append(interp.abandoned, g)
+        interp.pending.Add(1)
+    }
+    interp.abandonMu.Unlock()
+    if released {
+        s.resume <- generatorInput{kill: true}
+    }
+}
+
+// closeAbandoned closes the generators abandon queued. Like CPython, an
+// exception escaping close() is reported and otherwise ignored.
+func (i *interpreter) closeAbandoned(env *Environment) {
+    if i.pending.Load() == 0 {
+        return
+    }
+    i.abandonMu.Lock()
+    queued := i.abandoned
+    i.abandoned = nil
+    i.pending.Store(0)
+    i.abandonMu.Unlock()
+    for _, g := range queued {
+        if err, ok := g.close().(*Error); ok {
+            reportUnraisable(env, err, g)
+        }
+    }
+}
+
+// release is for an Interpreter the host has let go of: the generators
+// still parked on it are stopped without running any more Python code
+func (i *interpreter) release() {
+    i.abandonMu.Lock()
+    queued := i.abandoned
+    i.abandoned = nil
+    i.pending.Store(0)
+    i.released = true
+    i.abandonMu.Unlock()
+    for _, g := range queued {
+        g.state.resume <- generatorInput{kill: true}
+    }
+}
+
+// reportUnraisable writes an exception nobody can catch to sys.stderr
+func reportUnraisable(env *Environment, err *Error, where Object) {
+    stream, serr := env.interp.stream("stderr")
+    if serr != nil {
+        return
+    }
+    text, rerr := reprString(env, where)
+    if rerr != nil {
+        text = typeName(where)
+    }
+    writeStream(env, stream, "Exception ignored in: "+text+"\n"+FormatTraceback(err, env))
+}
+
+func (s *generatorState) run() {
+    result := s.body()
+    s.yield <- generatorOutput{value: result, done: true}
+}
+
+// suspend hands value to whoever resumed the generator, then waits to be
+// resumed. It returns the value sent in, or the *Error thrown in.
+func (s *generatorState) suspend(value Object) Object {
+    s.yield <- generatorOutput{value: value}
+    input := <-s.resume
+    if input.kill {
+        runtime.Goexit()
+    }
+    if input.err != nil {
+        return input.err
+    }
+    return input.value
+}
+
+// resume runs the body until its next yield, or until it finishes
+func (g *Generator) resume(input generatorInput) Object {
+    s := g.state
+    if s.running {
+        return valueError("generator already executing")
+    }
+    if s.finished {
+        if input.err != nil {
+            return input.err
+        }
+        return stopIteration()
+    }
+    if !s.started && input.err != nil {
+        s.finished = true
+        return input.err
+    }
+
+    interp := s.interp
+    if interp.depth >= recursionLimit {
+        return newErrorKind(recursionErrorType, "maximum recursion depth exceeded")
+    }
+    // the body sees the caller's exceptions under its own, like sys.exc_info() does
+    caller := interp.handling
+    interp.handling = append(caller[:len(caller):len(caller)], s.handling...)
+    interp.depth++
+    s.running = true
+    if s.started {
+        s.resume <- input
+    } else {
+        s.started = true
+        go s.run()
+    }
+    output := <-s.yield
+    s.running = false
+    interp.depth--
+    s.handling = append([]*Exception(nil), interp.handling[len(caller):]...)
+    interp.handling = caller
+
+    if !output.done {
+        return output.value
+    }
+    s.finished = true
+    return finishGenerator(output.value)
+}
+
+// finishGenerator turns what the body returned into what its caller sees:
+// a return value travels in StopIteration, and a StopIteration escaping the
+// body becomes a RuntimeError so it can't silently end an outer loop (PEP 479)
+func finishGenerator(result Object) Object {
+    switch result := result.(type) {
+    case *ReturnValue:
+        if result.Value != NULL {
+            return stopIterationWith(result.Value)
+        }
+    case *Error:
+        result.traced = false
+        if result.matches(stopIterationType) {
+            err := newErrorKind(runtimeErrorType, "generator raised StopIteration")
+            err.Exception.Cause = result.Exception
+            err.Exception.Context = result.Exception
+            err.Exception.SuppressContext = true
+            return err
+        }
+        return result
+    }
+    return stopIteration()
+}
+
+// stopIterationWith is StopIteration(value), value attribute included
+func stopIterationWith(value Object) *Error {
+    e := newException(stopIterationType, value)
+    e.Fields["value"] = value
+    return &Error{Exception: e}
+}
+
+func (g *Generator) send(value Object) Object {
+    if !g.state.started && value != NULL {
+        return typeError("can't send non-None value to a just-started generator")
+    }
+    return g.resume(generatorInput{value: value})
+}
+
+// close throws GeneratorExit in so the body's finally blocks get to run
+func (g *Generator) close() Object {
+    s := g.state
+    if !s.started || s.finished {
+        s.finished = true
+        return NULL
+    }
+    result := g.resume(generatorInput{err: newErrorKind(generatorExitType, "")})
+    err, ok := result.(*Error)
+    if !ok {
+        return newErrorKind(runtimeErrorType, "generator ignored GeneratorExit")
+    }
+    if err.matches(generatorExitType) || err.matches(stopIterationType) {
+        return NULL
+    }
+    return err
+}
+
+func (g *Generator) attribute(name string) (Object, bool) {
+    s := g.state
+    switch name {
+    case "__name__":
+        return &String{Value: g.Name}, true
+    case "__qualname__":
+        return &String{Value: g.Qualname}, true
+    case "gi_running":
+        return nativeBool(s.running), true
+    case "gi_suspended":
+        return nativeBool(s.started && !s.running && !s.finished), true
+    case "gi_frame":
+        if s.finished {
+            return NULL, true
+        }
+        return s.frame, true
+    case "gi_yieldfrom":
+        if s.delegate == nil {
+            return NULL, true
+        }
+        return s.delegate, true
+    }
+    return nil, false
+}
+
+// generator finds the generator whose body this scope belongs to
+func (e *Environment) generator() *generatorState {
+    for env := e; env != nil; env = env.outer {
+        if env.gen != nil {
+            return env.gen
+        }
+    }
+    return nil
+}
+
+func evalYieldExpression(node *parser.YieldExpression, env *Environment) Object {
+    var value Object = NULL
+    if node.Value != nil {
+        if value = Eval(node.Value, env); isError(value) {
+            return value
+        }
+    }
+    gen := env.generator()
+    if gen == nil {
+        return syntaxError("'yield' outside function")
+    }
+    if node.From {
+        return yieldFrom(env, gen, value)
+    }
+    return gen.suspend(value)
+}
+
+// yieldFrom hands everything over to a subiterator until it is exhausted:
+// values it yields go out, values sent and exceptions thrown go straight
+// in (PEP 380). The result is the subiterator's return value.
+func yieldFrom(env *Environment, gen *generatorState, iterable Object) Object {
+    iterator := getIter(env, iterable)
+    if isError(iterator) {
+        return iterator
+    }
+    gen.delegate = iterator
+    defer func() { gen.delegate = nil }()
+
+    input := generatorInput{value: NULL}
+    for {
+        var value Object
+        if input.err != nil {
+            value = throwInto(env, iterator, input.err)
+        } else {
+            value = sendInto(env, iterator, input.value)
+        }
+        if err, ok := value.(*Error); ok {
+            if err.matches(stopIterationType) {
+                return err.Exception.Fields["value"]
+            }
+            return err
+        }
+
+        received := gen.suspend(value)
+        if err, ok := received.(*Error); ok {
+            input = generatorInput{err: err}
+        } else {
+            input = generatorInput{value: received}
+        }
+    }
+}
+
+// sendInto is next(iterator), or iterator.send(value) for anything but None.
+// Exhaustion comes back as the StopIteration itself so its value survives.
+func sendInto(env *Environment, iterator Object, value Object) Object {
+    if gen, ok := iterator.(*Generator); ok {
+        return gen.send(value)
+    }
+    if value != NULL {
+        send := getAttribute(env, iterator, "send")
+        if isError(send) {
+            return send
+        }
+        return applyFunction(env, send, []Object{value}, nil)
+    }
+    if it, ok := iterator.(*Iter); ok {
+        item, ok := it.next(env)
+        if !ok {
+            return stopIteration()
+        }
+        return item
+    }
+    next := typeOf(iterator).lookupName("__next__")
+    if next == nil {
+        return typeError("'%s' object is not an iterator", typeName(iterator))
+    }
+    return callMethod(env, next, iterator)
+}
+
+// throwInto passes an exception thrown at a delegating generator on to its
+// subiterator. GeneratorExit closes the subiterator instead.
+func throwInto(env *Environment, iterator Object, err *Error) Object {
+    gen, isGenerator := iterator.(*Generator)
+    if err.matches(generatorExitType) {
+        var result Object = NULL
+        if isGenerator {
+            result = gen.close()
+        } else if closer := getAttribute(env, iterator, "close"); !isError(closer) {
+            result = applyFunction(env, closer, nil, nil)
+        }
+        if isError(result) {
+            return result
+        }
+        return err
+    }
+    if isGenerator {
+        return gen.resume(generatorInput{err: err})
+    }
+    throw := getAttribute(env, iterator, "throw")
+    if isError(throw) {
+        return err
+    }
+    return applyFunction(env, throw, []Object{err.Exception}, nil)
+}
+
+// thrownException builds what generator.throw(type[, value[, traceback]]) raises
+func thrownException(env *Environment, args []Object) (*Exception, *Error) {
+    typ := args[0]
+    var value Object = NULL
+    if len(args) > 1 {
+        value = args[1]
+    }
+    var exc *Exception
+    switch {
+    case isExceptionClass(typ):
+        if instance, ok := value.(*Exception); ok && instance.Class.isSubclass(typ.(*Class)) {
+            exc = instance
+            break
+        }
+        var arguments []Object
+        switch value := value.(type) {
+        case *NullObject:
+        case *Tuple:
+            arguments = value.Elements
+        default:
+            arguments = []Object{value}
+        }
+        instance := applyFunction(env, typ, arguments, nil)
+        if err, ok := instance.(*Error); ok {
+            return nil, err
+        }
+        created, ok := instance.(*Exception)
+        if !ok {
+            return nil, typeError("calling %s should have returned an instance of BaseException, not %s",
+                typ.(*Class).Name, typeName(instance))
+        }
+        exc = created
+    default:
+        instance, ok := typ.(*Exception)
+        if !ok {
+            return nil, typeError("exceptions must be classes or instances deriving from BaseException, not %s", typeName(typ))
+        }
+        if value != NULL {
+            return nil, typeError("instance exception may not have a separate value")
+        }
+        exc = instance
+    }
+
+    if len(args) > 2 && args[2] != NULL {
+        tb, ok := args[2].(*Traceback)
+        if !ok {
+            return nil, typeError("throw() third argument must be a traceback object")
+        }
+        exc.Traceback = tb
+    }
+    return exc, nil
+}
+
+func evalGeneratorExpression(node *parser.GeneratorExpression, env *Environment) Object {
+    // the first iterable is evaluated straight away, in the enclosing scope
+    first := node.Clauses[0]
+    iterable := Eval(first.Iterable, env)
+    if isError(iterable) {
+        return iterable
+    }
+    iterator := getIter(env, iterable)
+    if isError(iterator) {
+        return locate(iterator, env, first.Iterable)
+    }
+
+    scope := NewEnclosedEnvironment(closureEnvironment(env))
+    scope.scope = node.Scope
+    name := "<genexpr>"
+    return newGenerator(scope, name, qualifiedName(name, env), func() Object {
+        err := comprehension(scope, node.Clauses, iterator, func() *Error {
+            value := Eval(node.Element, scope)
+            if err, ok := value.(*Error); ok {
+                return err
+            }
+            if sent := scope.gen.suspend(value); isError(sent) {
+                return sent.(*Error)
+            }
+            return nil
+        })
+        if err != nil {
+            return locate(err, scope, node)
+        }
+        return NULL
+    })
+}
+
+// comprehension runs body once for every item the for clauses produce and the
+// if clauses let through. The first clause's iterable is already an iterator.
+func comprehension(env *Environment, clauses []*parser.ComprehensionClause, iterable Object, body func() *Error) *Error {
+    clause := clauses[0]
+    return iterate(env, iterable, func(item Object) *Error {
+        if err := assign(clause.Target, item, env); err != nil {
+            return err
+        }
+        for _, condition := range clause.Conditions {
+            value := Eval(condition, env)
+            if err, ok := value.(*Error); ok {
+                return err
+            }
+            ok, err := truthy(env, value)
+            if err != nil {
+                return err
+            }
+            if !ok {
+                return nil
+            }
+        }
+        if len(clauses) == 1 {
+            return body()
+        }
+        next := Eval(clauses[1].Iterable, env)
+        if err, ok := next.(*Error); ok {
+            return err
+        }
+        return comprehension(env, clauses[1:], next, body)
+    })
+}
+
+func init() {
+    generatorType.method("__iter__", 0, 0, func(env *Environment, args []Object) Object {
+        return args[0]
+    })
+    generatorType.method("__next__", 0, 0, func(env *Environment, args []Object) Object {
+        return args[0].(*Generator).send(NULL)
+    })
+    generatorType.method("send", 1, 1, func(env *Environment, args []Object) Object {
+        return args[0].(*Generator).send(args[1])
+    })
+    generatorType.method("throw", 1, 3, func(env *Environment, args []Object) Object {
+        exc, err := thrownException(env, args[1:])
+        if err != nil {
+            return err
+        }
+        return args[0].(*Generator).resume(generatorInput{err: &Error{Exception: exc}})
+    })
+    generatorType.method("close", 0, 0, func(env *Environment, args []Object) Object {
+        return args[0].(*Generator).close()
+    })
+}
@@ -0,0 +1,618 @@
+// Comments in this file are inspired by Jeff Malone - the man Jessica brought in from outside, and made fit
+
+package evaluator
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "interpreter/lexer"
+    "interpreter/parser"
+    "io"
+    "io/fs"
+    "math/big"
+    "reflect"
+    "runtime"
+    "sort"
+    "strconv"
+    "syscall"
+)
+
+// Interpreter is the evaluator as a Go program embedding it sees it: a
+// __main__ to run code in, and builtins of its own to add to
+type Interpreter struct {
+    *Environment
+}
+
+// NewInterpreter starts a program of its own, with nothing run yet
+func NewInterpreter() *Interpreter {
+    in := &Interpreter{Environment: NewEnvironment()}
+    runtime.AddCleanup(in, (*interpreter).release, in.interp)
+    return in
+}
+
+// Run runs source in __main__. What the last line evaluates to comes back;
+// a SyntaxError or an uncaught exception comes back as a *PythonError.
+func (in *Interpreter) Run(source string) (Object, error) {
+    p := parser.New(lexer.New(source))
+    program := p.ParseProgram()
+    if len(p.Errors()) != 0 {
+        return nil, &PythonError{
+            Kind:      "SyntaxError",
+            Message:   p.Errors()[0],
+            Traceback: FormatTraceback(ParseError(p, "<string>", source), in.Environment),
+        }
+    }
+    result := Eval(program, in.Environment)
+    if err, ok := result.(*Error); ok {
+        return nil, &PythonError{
+            Kind:      exceptionName(err.Exception.Class),
+            Message:   exceptionText(in.Environment, err.Exception),
+            Traceback: FormatTraceback(err, in.Environment),
+        }
+    }
+    return result, nil
+}
+
+// RunContext is Run with a way to stop it: once ctx is done, the program
+// gets a KeyboardInterrupt at its next loop iteration, or wakes up with one
+// from time.sleep
+func (in *Interpreter) RunContext(ctx context.Context, source string) (Object, error) {
+    in.interp.ctx = ctx
+    defer func() { in.interp.ctx = nil }()
+    return in.Run(source)
+}
+
+// safePoint sits between loop iterations, where it is fine for other Python
+// code to run: abandoned generators get closed here, and RunContext's
+// cancellation turns up. Top-level statements only do the closing.
+func (e *Environment) safePoint() *Error {
+    e.interp.closeAbandoned(e)
+    return e.interp.interrupted()
+}
+
+// interrupted is the KeyboardInterrupt owed once RunContext's context is done
+func (i *interpreter) interrupted() *Error {
+    if i.ctx == nil {
+        return nil
+    }
+    select {
+    case <-i.ctx.Done():
+        return newErrorKind(keyboardInterruptType, "%s", i.ctx.Err().Error())
+    default:
+        return nil
+    }
+}
+
+// PythonError is a Python exception on the Go side. A registered function
+// returns one to raise Kind - any builtin exception's name - and Run hands
+// one back when the program raised.
+type PythonError struct {
+    Kind      string
+    Message   string
+    Traceback string // only Run fills this in
+}
+
+func (e *PythonError) Error() string {
+    if e.Message == "" {
+        return e.Kind
+    }
+    return e.Kind + ": " + e.Message
+}
+
+// GoFunc is a Go function dressed for Python. Params name its arguments so
+// they can be passed by keyword - leave it empty for positional only - and
+// Defaults fill in the trailing ones left out. Fn may take an *Environment
+// first, and may return a value, an error, or a value and an error.
+type GoFunc struct {
+    Name     string
+    Doc      string
+    Params   []string
+    Defaults []interface{}
+    Fn       interface{}
+}
+
+// RegisterFunc adds fn to this interpreter's builtins under name
+func (in *Interpreter) RegisterFunc(name string, fn interface{}) error {
+    return in.Register(GoFunc{Name: name, Fn: fn})
+}
+
+// Register adds a Go function to this interpreter's builtins. Anything it
+// can't convert is a mistake in the host, so it's reported here rather
+// than when Python calls it.
+func (in *Interpreter) Register(g GoFunc) error {
+    builtin, err := g.builtin()
+    if err != nil {
+        return err
+    }
+    if in.interp.builtins == nil {
+        in.interp.builtins = map[string]Object{}
+    }
+    in.interp.builtins[g.Name] = builtin
+    return nil
+}
+
+var (
+    objectInterface = reflect.TypeOf((*Object)(nil)).Elem()
+    errorInterface  = reflect.TypeOf((*error)(nil)).Elem()
+    environmentType = reflect.TypeOf((*Environment)(nil))
+    bigIntType      = reflect.TypeOf((*big.Int)(nil))
+)
+
+// argumentConverter turns one Python argument into what the Go parameter
+// wants; where is how to name the argument when it won't go
+type argumentConverter func(env *Environment, where string, obj Object) (reflect.Value, *Error)
+
+// builtin checks the function over once and wraps it as a Builtin
+func (g GoFunc) builtin() (*Builtin, error) {
+    fn := reflect.ValueOf(g.Fn)
+    if fn.Kind() != reflect.Func {
+        return nil, fmt.Errorf("%s: want a func, got %T", g.Name, g.Fn)
+    }
+    t := fn.Type()
+
+    params := make([]reflect.Type, t.NumIn())
+    for i := range params {
+        params[i] = t.In(i)
+    }
+    wantsEnv := len(params) > 0 && params[0] == environmentType
+    if wantsEnv {
+        params = params[1:]
+    }
+    var variadic argumentConverter
+    if t.IsVariadic() {
+        elem := params[len(params)-1].Elem()
+        if variadic = converterFor(elem); variadic == nil {
+            return nil, fmt.Errorf("%s: can't convert Python arguments to %s", g.Name, elem)
+        }
+        params = params[:len(params)-1]
+    }
+    converters := make([]argumentConverter, len(params))
+    for i, param := range params {
+        if converters[i] = converterFor(param); converters[i] == nil {
+            return nil, fmt.Errorf("%s: can't convert Python arguments to %s", g.Name, param)
+        }
+    }
+    if len(g.Params) > 0 && len(g.Params) != len(params) {
+        return nil, fmt.Errorf("%s: %d parameter names for %d parameters", g.Name, len(g.Params), len(params))
+    }
+    if len(g.Defaults) > len(params) {
+        return nil, fmt.Errorf("%s: %d defaults for %d parameters", g.Name, len(g.Defaults), len(params))
+    }
+    defaults := make([]reflect.Value, len(g.Defaults))
+    for i, value := range g.Defaults {
+        param := params[len(params)-len(defaults)+i]
+        if value == nil {
+            defaults[i] = reflect.Zero(param)
+            continue
+        }
+        v := reflect.ValueOf(value)
+        if !v.Type().ConvertibleTo(param) {
+            return nil, fmt.Errorf("%s: default %v doesn't fit a %s", g.Name, value, param)
+        }
+        defaults[i] = v.Convert(param)
+    }
+
+    results := t.NumOut()
+    returnsError := results > 0 && t.Out(results-1) == errorInterface
+    if results > 2 || results == 2 && !returnsError {
+        return nil, fmt.Errorf("%s: want a value, an error or both as results", g.Name)
+    }
+    required := len(params) - len(defaults)
+
+    name := g.Name
+    call := func(env *Environment, args []Object, kwargs *Dict) (result Object) {
+        fixed, extra := args, []Object(nil)
+        if variadic != nil && len(args) > len(params) {
+            fixed, extra = args[:len(params)], args[len(params):]
+        }
+        var bound []Object
+        if len(g.Params) == 0 {
+            max := len(params)
+            if variadic != nil {
+                max = -1
+            }
+            if err := checkArgs(name, args, kwargs, required, max); err != nil {
+                return err
+            }
+            bound = make([]Object, len(params))
+            copy(bound, fixed)
+        } else {
+            var err *Error
+            if bound, err = parseArgs(name, fixed, kwargs, g.Params, required); err != nil {
+                return err
+            }
+        }
+
+        in := []reflect.Value{}
+        if wantsEnv {
+            in = append(in, reflect.ValueOf(env))
+        }
+        for i, obj := range bound {
+            if obj == nil {
+                in = append(in, defaults[i-required])
+                continue
+            }
+            where := fmt.Sprintf("%s() argument %d", name, i+1)
+            if len(g.Params) > 0 {
+                where = fmt.Sprintf("%s() argument '%s'", name, g.Params[i])
+            }
+            value, err := converters[i](env, where, obj)
+            if err != nil {
+                return err
+            }
+            in = append(in, value)
+        }
+        for i, obj := range extra {
+            value, err := variadic(env, fmt.Sprintf("%s() argument %d", name, len(params)+i+1), obj)
+            if err != nil {
+                return err
+            }
+            in = append(in, value)
+        }
+
+        // a panicking host function shouldn't take the whole program with it
+        defer func() {
+            if r := recover(); r != nil {
+                result = newErrorKind(systemErrorType, "%s() panicked: %v", name, r)
+            }
+        }()
+        out := fn.Call(in)
+        if returnsError {
+            if err, _ := out[len(out)-1].Interface().(error); err != nil {
+                return goError(err)
+            }
+            out = out[:len(out)-1]
+        }
+        if len(out) == 0 {
+            return NULL
+        }
+        return fromGo(env, out[0])
+    }
+    return &Builtin{Name: name, Fn: call, Doc: g.Doc}, nil
+}
+
+// converterFor is how an argument becomes a t, or nil if it can't
+func converterFor(t reflect.Type) argumentConverter {
+    switch {
+    case t == objectInterface:
+        return func(env *Environment, where string, obj Object) (reflect.Value, *Error) {
+            return reflect.ValueOf(&obj).Elem(), nil
+        }
+    case t == bigIntType:
+        return func(env *Environment, where string, obj Object) (reflect.Value, *Error) {
+            n, err := indexValue(env, obj)
+            if err != nil {
+                return reflect.Value{}, err
+            }
+            return reflect.ValueOf(new(big.Int).Set(n)), nil
+        }
+    case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+        return func(env *Environment, where string, obj Object) (reflect.Value, *Error) {
+            data, ok, err := bytesLike(obj)
+            if err != nil {
+                return reflect.Value{}, err
+            }
+            if !ok {
+                return reflect.Value{}, typeError("a bytes-like object is required, not '%s'", typeName(obj))
+            }
+            return reflect.ValueOf(append([]byte(nil), data...)).Convert(t), nil
+        }
+    }
+
+    switch t.Kind() {
+    case reflect.Bool:
+        return func(env *Environment, where string, obj Object) (reflect.Value, *Error) {
+            ok, err := truthy(env, obj)
+            return reflect.ValueOf(ok).Convert(t), err
+        }
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        return func(env *Environment, where string, obj Object) (reflect.Value, *Error) {
+            n, err := indexValue(env, obj)
+            if err != nil {
+                return reflect.Value{}, err
+            }
+            v := reflect.New(t).Elem()
+            if !n.IsInt64() || v.OverflowInt(n.Int64()) {
+                if t.Bits() == 64 {
+                    return reflect.Value{}, overflowError("Python int too large to convert to C long")
+                }
+                return reflect.Value{}, overflowError("Python int too large to convert to C int")
+            }
+            v.SetInt(n.Int64())
+            return v, nil
+        }
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+        return func(env *Environment, where string, obj Object) (reflect.Value, *Error) {
+            n, err := indexValue(env, obj)
+            if err != nil {
+                return reflect.Value{}, err
+            }
+            if n.Sign() < 0 {
+                return reflect.Value{}, overflowError("can't convert negative int to unsigned")
+            }
+            v := reflect.New(t).Elem()
+            if !n.IsUint64() || v.OverflowUint(n.Uint64()) {
+                return reflect.Value{}, overflowError("Python int too large to convert to C unsigned long")
+            }
+            v.SetUint(n.Uint64())
+            return v, nil
+        }
+    case reflect.Float32, reflect.Float64:
+        return func(env *Environment, where string, obj Object) (reflect.Value, *Error) {
+            if !isNumber(obj) {
+                return reflect.Value{}, typeError("%s must be real number, not %s", where, typeName(obj))
+            }
+            f, err := realArgument(env, obj)
+            return reflect.ValueOf(f).Convert(t), err
+        }
+    case reflect.Complex64, reflect.Complex128:
+        return func(env *Environment, where string, obj Object) (reflect.Value, *Error) {
+            if !isNumber(obj) {
+                return reflect.Value{}, typeError("%s must be complex number, not %s", where, typeName(obj))
+            }
+            z, err := cmathArgument(env, obj)
+            return reflect.ValueOf(z).Convert(t), err
+        }
+    case reflect.String:
+        return func(env *Environment, where string, obj Object) (reflect.Value, *Error) {
+            s, ok := payload(obj).(*String)
+            if !ok {
+                return reflect.Value{}, typeError("%s must be str, not %s", where, typeName(obj))
+            }
+            return reflect.ValueOf(s.Value).Convert(t), nil
+        }
+    case reflect.Slice:
+        elem := converterFor(t.Elem())
+        if elem == nil {
+            return nil
+        }
+        return func(env *Environment, where string, obj Object) (reflect.Value, *Error) {
+            if _, ok := payload(obj).(*String); ok {
+                return reflect.Value{}, typeError("%s must be a sequence, not str", where)
+            }
+            items, err := iterableToSlice(env, obj)
+            if err != nil {
+                return reflect.Value{}, err
+            }
+            v := reflect.MakeSlice(t, len(items), len(items))
+            for i, item := range items {
+                value, err := elem(env, where, item)
+                if err != nil {
+                    return reflect.Value{}, err
+                }
+                v.Index(i).Set(value)
+            }
+            return v, nil
+        }
+    case reflect.Map:
+        key, value := converterFor(t.Key()), converterFor(t.Elem())
+        if key == nil || value == nil {
+            return nil
+        }
+        return func(env *Environment, where string, obj Object) (reflect.Value, *Error) {
+            dict, ok := payload(obj).(*Dict)
+            if !ok {
+                return reflect.Value{}, typeError("%s must be dict, not %s", where, typeName(obj))
+            }
+            v := reflect.MakeMapWithSize(t, dict.Len())
+            for _, entry := range dict.Entries() {
+                k, err := key(env, where, entry.Key)
+                if err != nil {
+                    return reflect.Value{}, err
+                }
+                e, err := value(env, where, entry.Value)
+                if err != nil {
+                    return reflect.Value{}, err
+                }
+                v.SetMapIndex(k, e)
+            }
+            return v, nil
+        }
+    case reflect.Interface:
+        if t.NumMethod() != 0 {
+            return nil
+        }
+        return func(env *Environment, where string, obj Object) (reflect.Value, *Error) {
+            v := reflect.New(t).Elem()
+            if value := toGo(obj); value != nil {
+                v.Set(reflect.ValueOf(value))
+            }
+            return v, nil
+        }
+    }
+    return nil
+}
+
+// toGo is the plain Go value for an argument a host took as interface{}:
+// the scalars and containers it would expect, or the Object itself
+func toGo(obj Object) interface{} {
+    switch value := payload(obj).(type) {
+    case *NullObject:
+        return nil
+    case *Boolean:
+        return value.Value
+    case *Integer:
+        n, _ := toBigInt(value)
+        if n.IsInt64() {
+            return n.Int64()
+        }
+        return new(big.Int).Set(n)
+    case *Float:
+        return value.Value
+    case *Complex:
+        return value.Value
+    case *String:
+        return value.Value
+    case *Bytes:
+        return append([]byte(nil), value.Value...)
+    case *List:
+        return toGoSlice(value.Elements)
+    case *Tuple:
+        return toGoSlice(value.Elements)
+    case *Dict:
+        m := make(map[string]interface{}, value.Len())
+        for _, entry := range value.Entries() {
+            key, ok := payload(entry.Key).(*String)
+            if !ok {
+                return obj // keys Go can't index by: hand over the dict as it is
+            }
+            m[key.Value] = toGo(entry.Value)
+        }
+        return m
+    }
+    return obj
+}
+
+func toGoSlice(items []Object) []interface{} {
+    result := make([]interface{}, len(items))
+    for i, item := range items {
+        result[i] = toGo(item)
+    }
+    return result
+}
+
+// fromGo is a Go result as the Python value it stands for
+func fromGo(env *Environment, v reflect.Value) Object {
+    if !v.IsValid() {
+        return NULL
+    }
+    if v.Type().Implements(objectInterface) {
+        if v.Kind() == reflect.Ptr && v.IsNil() {
+            return NULL
+        }
+        return v.Interface().(Object)
+    }
+    if n, ok := v.Interface().(*big.Int); ok {
+        if n == nil {
+            return NULL
+        }
+        return newBigInt(new(big.Int).Set(n))
+    }
+    switch v.Kind() {
+    case reflect.Interface, reflect.Ptr:
+        if v.IsNil() {
+            return NULL
+        }
+        if v.Kind() == reflect.Interface {
+            return fromGo(env, v.Elem())
+        }
+    case reflect.Bool:
+        return nativeBool(v.Bool())
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        return newInt(v.Int())
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+        return newBigInt(new(big.Int).SetUint64(v.Uint()))
+    case reflect.Float32, reflect.Float64:
+        return &Float{Value: v.Float()}
+    case reflect.Complex64, reflect.Complex128:
+        return &Complex{Value: v.Complex()}
+    case reflect.String:
+        return &String{Value: v.String()}
+    case reflect.Slice, reflect.Array:
+        if v.Kind() == reflect.Slice && v.IsNil() {
+            return NULL
+        }
+        if v.Type().Elem().Kind() == reflect.Uint8 {
+            data := make([]byte, v.Len())
+            reflect.Copy(reflect.ValueOf(data), v)
+            return &Bytes{Value: data}
+        }
+        items := make([]Object, v.Len())
+        for i := range items {
+            if items[i] = fromGo(env, v.Index(i)); isError(items[i]) {
+                return items[i]
+            }
+        }
+        if v.Kind() == reflect.Array {
+            return &Tuple{Elements: items}
+        }
+        return &List{Elements: items}
+    case reflect.Map:
+        if v.IsNil() {
+            return NULL
+        }
+        dict := NewDict()
+        for _, k := range sortedMapKeys(v) {
+            key := fromGo(env, k)
+            if isError(key) {
+                return key
+            }
+            value := fromGo(env, v.MapIndex(k))
+            if isError(value) {
+                return value
+            }
+            if err := dict.Set(env, key, value); err != nil {
+                return err
+            }
+        }
+        return dict
+    }
+    return typeError("can't convert a Go %s to a Python object", v.Type())
+}
+
+// sortedMapKeys puts a map's keys in order, as fmt prints them, so the dict
+// made from it comes out the same every time
+func sortedMapKeys(v reflect.Value) []reflect.Value {
+    keys := v.MapKeys()
+    sort.SliceStable(keys, func(i, j int) bool {
+        a, b := keys[i], keys[j]
+        switch a.Kind() {
+        case reflect.String:
+            return a.String() < b.String()
+        case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+            return a.Int() < b.Int()
+        case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+            return a.Uint() < b.Uint()
+        case reflect.Float32, reflect.Float64:
+            return a.Float() < b.Float()
+        case reflect.Bool:
+            return !a.Bool() && b.Bool()
+        }
+        return false
+    })
+    return keys
+}
+
+// goError raises a Go error as the Python exception closest to it
+func goError(err error) *Error {
+    var pyErr *PythonError
+    var numErr *strconv.NumError
+    var pathErr *fs.PathError
+    var errno syscall.Errno
+    switch {
+    case errors.As(err, &pyErr):
+        cls, ok := exceptionClasses[pyErr.Kind]
+        if !ok {
+            return runtimeError("%s", pyErr.Error())
+        }
+        if pyErr.Message == "" {
+            return &Error{Exception: newException(cls)}
+        }
+        return newErrorKind(cls, "%s", pyErr.Message)
+    case errors.As(err, &errno):
+        var filename Object
+        if errors.As(err, &pathErr) {
+            filename = &String{Value: pathErr.Path}
+        }
+        return osError(errno, filename)
+    case errors.Is(err, fs.ErrNotExist):
+        return newErrorKind(exceptionClasses["FileNotFoundError"], "%s", err.Error())
+    case errors.Is(err, fs.ErrExist):
+        return newErrorKind(exceptionClasses["FileExistsError"], "%s", err.Error())
+    case errors.Is(err, fs.ErrPermission):
+        return newErrorKind(exceptionClasses["PermissionError"], "%s", err.Error())
+    case errors.As(err, &numErr):
+        if numErr.Err == strconv.ErrRange {
+            return overflowError("%s", err.Error())
+        }
+        return valueError("%s", err.Error())
+    case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+        return newErrorKind(exceptionClasses["EOFError"], "%s", err.Error())
+    case errors.Is(err, context.DeadlineExceeded):
+        return newErrorKind(exceptionClasses["TimeoutError"], "%s", err.Error())
+    case errors.Is(err, errors.ErrUnsupported):
+        return newErrorKind(notImplementedErrorType, "%s", err.Error())
+    }
+    return runtimeError("%s", err.Error())
+}
@@ -0,0 +1,874 @@
+// Comments in this file are inspired by Charles Forstman - he always has another deal lined up, and hands them over one at a time
+
+package evaluator
+
+import "strings"
+
+// itertools: lazy iterators built out of other iterators. Like map and zip,
+// each is a class whose instances are native iterators.
+
+var (
+    countType         = newIteratorClass("count")
+    cycleType         = newIteratorClass("cycle")
+    repeatType        = newIteratorClass("repeat")
+    accumulateType    = newIteratorClass("accumulate")
+    chainType         = newIteratorClass("chain")
+    compressType      = newIteratorClass("compress")
+    dropwhileType     = newIteratorClass("dropwhile")
+    takewhileType     = newIteratorClass("takewhile")
+    filterfalseType   = newIteratorClass("filterfalse")
+    groupbyType       = newIteratorClass("groupby")
+    grouperType       = newIteratorClass("_grouper")
+    isliceType        = newIteratorClass("islice")
+    pairwiseType      = newIteratorClass("pairwise")
+    starmapType       = newIteratorClass("starmap")
+    teeType           = newIteratorClass("_tee")
+    zipLongestType    = newIteratorClass("zip_longest")
+    productType       = newIteratorClass("product")
+    permutationsType  = newIteratorClass("permutations")
+    combinationsType  = newIteratorClass("combinations")
+    replacementsType  = newIteratorClass("combinations_with_replacement")
+    batchedType       = newIteratorClass("batched")
+    itertoolsTypes    = []*Class{countType, cycleType, repeatType, accumulateType, chainType, compressType, dropwhileType, takewhileType, filterfalseType, groupbyType, grouperType, isliceType, pairwiseType, starmapType, teeType, zipLongestType, productType, permutationsType, combinationsType, replacementsType, batchedType}
+)
+
+func init() {
+    registerModule("itertools", buildItertools)
+
+    for _, cls := range itertoolsTypes {
+        cls.Dict.SetStr("__module__", &String{Value: "itertools"})
+    }
+    initInfiniteIterators()
+    initFilteringIterators()
+    initCombinatorics()
+}
+
+func buildItertools(m *Module) {
+    for _, cls := range itertoolsTypes {
+        if cls != grouperType && cls != teeType {
+            m.Env.Set(cls.Name, cls)
+        }
+    }
+    m.function("tee", tee)
+}
+
+// iteratorRepr is name(a, b) with the items' reprs
+func iteratorRepr(env *Environment, name string, items []Object) (string, *Error) {
+    parts := make([]string, len(items))
+    for i, item := range items {
+        text, err := reprString(env, item)
+        if err != nil {
+            return "", err
+        }
+        parts[i] = text
+    }
+    return name + "(" + strings.Join(parts, ", ") + ")", nil
+}
+
+func initInfiniteIterators() {
+    for _, cls := range []*Class{countType, repeatType} {
+        cls.method("__repr__", 0, 0, func(env *Environment, args []Object) Object {
+            text, err := payload(args[0]).(*Iter).repr(env)
+            if err != nil {
+                return err
+            }
+            return &String{Value: text}
+        })
+    }
+    countType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        params, err := parseArgs("count", args[1:], kwargs, []string{"start", "step"}, 0)
+        if err != nil {
+            return err
+        }
+        var current, step Object = newInt(0), newInt(1)
+        if params[0] != nil {
+            current = params[0]
+        }
+        if params[1] != nil {
+            step = params[1]
+        }
+        if !isNumber(current) || !isNumber(step) {
+            return typeError("a number is required")
+        }
+        it := newIterator(countType, func(env *Environment) (Object, bool) {
+            value := current
+            next := binaryOperation(env, "+", current, step)
+            if isError(next) {
+                return next, true
+            }
+            current = next
+            return value, true
+        })
+        // count(5) rather than count(5, 1): a step of int 1 goes unsaid
+        it.repr = func(env *Environment) (string, *Error) {
+            items := []Object{current}
+            if n, ok := toBigInt(step); !ok || !n.IsInt64() || n.Int64() != 1 {
+                items = append(items, step)
+            }
+            return iteratorRepr(env, "count", items)
+        }
+        return it
+    })
+
+    cycleType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := argumentCount("cycle", args[1:], kwargs, 1); err != nil {
+            return err
+        }
+        iterator := getIter(env, args[1])
+        if isError(iterator) {
+            return iterator
+        }
+        saved := []Object{}
+        i := -1 // walking the source until it runs dry, then saved
+        return newIterator(cycleType, func(env *Environment) (Object, bool) {
+            if i < 0 {
+                item, ok := iterNext(env, iterator)
+                if ok {
+                    if !isError(item) {
+                        saved = append(saved, item)
+                    }
+                    return item, true
+                }
+                i = 0
+            }
+            if len(saved) == 0 {
+                return nil, false
+            }
+            i++
+            return saved[(i-1)%len(saved)], true
+        })
+    })
+
+    repeatType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        params, err := parseArgs("repeat", args[1:], kwargs, []string{"object", "times"}, 1)
+        if err != nil {
+            return err
+        }
+        times := -1
+        if params[1] != nil {
+            if times, err = toIndex(env, params[1]); err != nil {
+                return err
+            }
+            times = max(times, 0)
+        }
+        it := newIterator(repeatType, func(env *Environment) (Object, bool) {
+            if times == 0 {
+                return nil, false
+            }
+            if times > 0 {
+                times--
+            }
+            return params[0], true
+        })
+        // the times still to go, which is none for a negative count
+        it.repr = func(env *Environment) (string, *Error) {
+            items := []Object{params[0]}
+            if times >= 0 {
+                items = append(items, newInt(int64(times)))
+            }
+            return iteratorRepr(env, "repeat", items)
+        }
+        return it
+    })
+
+    accumulateType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        params, err := parseArgs("accumulate", args[1:], kwargs, []string{"iterable", "func", "*initial"}, 1)
+        if err != nil {
+            return err
+        }
+        iterator := getIter(env, params[0])
+        if isError(iterator) {
+            return iterator
+        }
+        fn, total := noneToNil(params[1]), noneToNil(params[2])
+        initial := total != nil
+        return newIterator(accumulateType, func(env *Environment) (Object, bool) {
+            if initial {
+                initial = false
+                return total, true
+            }
+            item, ok := iterNext(env, iterator)
+            if !ok || isError(item) {
+                return item, ok
+            }
+            switch {
+            case total == nil:
+                total = item
+            case fn == nil:
+                total = binaryOperation(env, "+", total, item)
+            default:
+                total = applyFunction(env, fn, []Object{total, item}, nil)
+            }
+            return total, true
+        })
+    })
+
+    chainType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        if kwargs.Len() > 0 {
+            return typeError("chain() takes no keyword arguments")
+        }
+        return chainIterables(sliceIterator(tupleIteratorType, func() []Object { return args[1:] }))
+    })
+    chainType.Dict.SetStr("from_iterable", &ClassMethod{Function: &Builtin{Name: "from_iterable", Fn: func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("from_iterable", args[1:], kwargs, 1, 1); err != nil {
+            return err
+        }
+        iterables := getIter(env, args[1])
+        if isError(iterables) {
+            return iterables
+        }
+        return chainIterables(iterables)
+    }}, Dict: NewDict()})
+}
+
+// chainIterables runs through each iterable that iterables produces, in turn
+func chainIterables(iterables Object) *Iter {
+    var current Object
+    return newIterator(chainType, func(env *Environment) (Object, bool) {
+        for {
+            if current == nil {
+                iterable, ok := iterNext(env, iterables)
+                if !ok || isError(iterable) {
+                    return iterable, ok
+                }
+                if current = getIter(env, iterable); isError(current) {
+                    err := current
+                    current = nil
+                    return err, true
+                }
+            }
+            item, ok := iterNext(env, current)
+            if ok {
+                return item, true
+            }
+            current = nil
+        }
+    })
+}
+
+// predicateIterator is the shape dropwhile, takewhile and filterfalse share:
+// each item goes past a verdict, and decide says what to do with it
+func predicateIterator(env *Environment, cls *Class, args []Object, kwargs *Dict, decide func(item Object, verdict bool) (keep, stop bool)) Object {
+    if err := argumentCount(cls.Name, args, kwargs, 2); err != nil {
+        return err
+    }
+    fn := args[0]
+    iterator := getIter(env, args[1])
+    if isError(iterator) {
+        return iterator
+    }
+    return newIterator(cls, func(env *Environment) (Object, bool) {
+        for {
+            item, ok := iterNext(env, iterator)
+            if !ok || isError(item) {
+                return item, ok
+            }
+            verdict := item
+            if fn != NULL || cls != filterfalseType {
+                if verdict = applyFunction(env, fn, []Object{item}, nil); isError(verdict) {
+                    return verdict, true
+                }
+            }
+            truth, err := truthy(env, verdict)
+            if err != nil {
+                return err, true
+            }
+            keep, stop := decide(item, truth)
+            if stop {
+                return nil, false
+            }
+            if keep {
+                return item, true
+            }
+        }
+    })
+}
+
+func initFilteringIterators() {
+    compressType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        params, err := parseArgs("compress", args[1:], kwargs, []string{"data", "selectors"}, 2)
+        if err != nil {
+            return err
+        }
+        sources, err := iterators(env, params)
+        if err != nil {
+            return err
+        }
+        return newIterator(compressType, func(env *Environment) (Object, bool) {
+            for {
+                item, ok := iterNext(env, sources[0])
+                if !ok || isError(item) {
+                    return item, ok
+                }
+                selector, ok := iterNext(env, sources[1])
+                if !ok || isError(selector) {
+                    return selector, ok
+                }
+                keep, err := truthy(env, selector)
+                if err != nil {
+                    return err, true
+                }
+                if keep {
+                    return item, true
+                }
+            }
+        })
+    })
+
+    dropwhileType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        dropping := true
+        return predicateIterator(env, dropwhileType, args[1:], kwargs, func(item Object, verdict bool) (bool, bool) {
+            dropping = dropping && verdict
+            return !dropping, false
+        })
+    })
+    takewhileType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        return predicateIterator(env, takewhileType, args[1:], kwargs, func(item Object, verdict bool) (bool, bool) {
+            return verdict, !verdict
+        })
+    })
+    filterfalseType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        return predicateIterator(env, filterfalseType, args[1:], kwargs, func(item Object, verdict bool) (bool, bool) {
+            return !verdict, false
+        })
+    })
+
+    groupbyType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        params, err := parseArgs("groupby", args[1:], kwargs, []string{"iterable", "key"}, 1)
+        if err != nil {
+            return err
+        }
+        iterator := getIter(env, params[0])
+        if isError(iterator) {
+            return iterator
+        }
+        return groupBy(iterator, noneToNil(params[1]))
+    })
+
+    isliceType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        if kwargs.Len() > 0 {
+            return typeError("islice() takes no keyword arguments")
+        }
+        if err := checkArgs("islice", args[1:], nil, 2, 4); err != nil {
+            return err
+        }
+        start, stop, step := 0, -1, 1
+        bound := func(obj Object, what string) (int, *Error) {
+            if obj == NULL {
+                return -1, nil
+            }
+            n, err := toIndex(env, obj)
+            if err != nil || n < 0 {
+                return 0, valueError("%s for islice() must be None or an integer: 0 <= x <= sys.maxsize.", what)
+            }
+            return n, nil
+        }
+        var err *Error
+        if len(args) == 3 {
+            if stop, err = bound(args[2], "Stop argument"); err != nil {
+                return err
+            }
+        } else {
+            if start, err = bound(args[2], "Indices"); err != nil {
+                return err
+            }
+            if stop, err = bound(args[3], "Stop argument"); err != nil {
+                return err
+            }
+            start = max(start, 0)
+            if len(args) == 5 && args[4] != NULL {
+                if step, err = toIndex(env, args[4]); err != nil || step < 1 {
+                    return valueError("Step for islice() must be a positive integer or None.")
+                }
+            }
+        }
+        iterator := getIter(env, args[1])
+        if isError(iterator) {
+            return iterator
+        }
+        // After CPython: count what has been read, and where the next one to keep is
+        next, count := start, 0
+        return newIterator(isliceType, func(env *Environment) (Object, bool) {
+            for count < next {
+                item, ok := iterNext(env, iterator)
+                if !ok || isError(item) {
+                    return item, ok
+                }
+                count++
+            }
+            if stop != -1 && count >= stop {
+                return nil, false
+            }
+            item, ok := iterNext(env, iterator)
+            if !ok || isError(item) {
+                return item, ok
+            }
+            count++
+            next += step
+            if stop != -1 && next > stop {
+                next = stop
+            }
+            return item, true
+        })
+    })
+
+    pairwiseType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := argumentCount("pairwise", args[1:], kwargs, 1); err != nil {
+            return err
+        }
+        iterator := getIter(env, args[1])
+        if isError(iterator) {
+            return iterator
+        }
+        var previous Object
+        return newIterator(pairwiseType, func(env *Environment) (Object, bool) {
+            if previous == nil {
+                item, ok := iterNext(env, iterator)
+                if !ok || isError(item) {
+                    return item, ok
+                }
+                previous = item
+            }
+            item, ok := iterNext(env, iterator)
+            if !ok || isError(item) {
+                return item, ok
+            }
+            pair := &Tuple{Elements: []Object{previous, item}}
+            previous = item
+            return pair, true
+        })
+    })
+
+    starmapType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := argumentCount("starmap", args[1:], kwargs, 2); err != nil {
+            return err
+        }
+        fn := args[1]
+        iterator := getIter(env, args[2])
+        if isError(iterator) {
+            return iterator
+        }
+        return newIterator(starmapType, func(env *Environment) (Object, bool) {
+            item, ok := iterNext(env, iterator)
+            if !ok || isError(item) {
+                return item, ok
+            }
+            arguments, err := iterableToSlice(env, item)
+            if err != nil {
+                return err, true
+            }
+            return applyFunction(env, fn, arguments, nil), true
+        })
+    })
+
+    zipLongestType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        var fill Object = NULL
+        for _, entry := range kwargs.Entries() {
+            if name := entry.Key.(*String).Value; name != "fillvalue" {
+                return typeError("zip_longest() got an unexpected keyword argument '%s'", name)
+            }
+            fill = entry.Value
+        }
+        sources, err := iterators(env, args[1:])
+        if err != nil {
+            return err
+        }
+        active := len(sources)
+        return newIterator(zipLongestType, func(env *Environment) (Object, bool) {
+            if active == 0 {
+                return nil, false
+            }
+            items := make([]Object, len(sources))
+            for i, source := range sources {
+                if source == nil {
+                    items[i] = fill
+                    continue
+                }
+                item, ok := iterNext(env, source)
+                if isError(item) {
+                    return item, true
+                }
+                if !ok {
+                    if active--; active == 0 {
+                        return nil, false
+                    }
+                    sources[i], item = nil, fill
+                }
+                items[i] = item
+            }
+            return &Tuple{Elements: items}, true
+        })
+    })
+
+    batchedType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        params, err := parseArgs("batched", args[1:], kwargs, []string{"iterable", "n"}, 2)
+        if err != nil {
+            return err
+        }
+        n, err := toIndex(env, params[1])
+        if err != nil {
+            return err
+        }
+        if n < 1 {
+            return valueError("n must be at least one")
+        }
+        iterator := getIter(env, params[0])
+        if isError(iterator) {
+            return iterator
+        }
+        return newIterator(batchedType, func(env *Environment) (Object, bool) {
+            batch := make([]Object, 0, n)
+            for len(batch) < n {
+                item, ok := iterNext(env, iterator)
+                if isError(item) {
+                    return item, true
+                }
+                if !ok {
+                    break
+                }
+                batch = append(batch, item)
+            }
+            if len(batch) == 0 {
+                return nil, false
+            }
+            return &Tuple{Elements: batch}, true
+        })
+    })
+}
+
+// groupBy hands out (key, group) pairs. The groups share the one underlying
+// iterator: moving on to the next key leaves the last group empty-handed.
+func groupBy(iterator Object, keyFn Object) *Iter {
+    var key, value, target Object // the latest item read and its key; the key of the current group
+    var current *Iter             // the only group still allowed to produce
+    step := func(env *Environment) (Object, bool) {
+        item, ok := iterNext(env, iterator)
+        if !ok || isError(item) {
+            return item, ok
+        }
+        newKey := item
+        if keyFn != nil {
+            if newKey = applyFunction(env, keyFn, []Object{item}, nil); isError(newKey) {
+                return newKey, true
+            }
+        }
+        key, value = newKey, item
+        return nil, true
+    }
+    return newIterator(groupbyType, func(env *Environment) (Object, bool) {
+        current = nil
+        for key == nil || target != nil {
+            if key != nil {
+                same, err := equals(env, target, key)
+                if err != nil {
+                    return err, true
+                }
+                if !same {
+                    break
+                }
+            }
+            if err, ok := step(env); !ok || err != nil {
+                return err, ok
+            }
+        }
+        target = key
+        groupKey := target
+        var group *Iter
+        group = newIterator(grouperType, func(env *Environment) (Object, bool) {
+            if current != group {
+                return nil, false
+            }
+            if value == nil {
+                if err, ok := step(env); !ok || err != nil {
+                    return err, ok
+                }
+            }
+            same, err := equals(env, groupKey, key)
+            if err != nil {
+                return err, true
+            }
+            if !same {
+                return nil, false
+            }
+            item := value
+            value = nil
+            return item, true
+        })
+        current = group
+        return &Tuple{Elements: []Object{key, group}}, true
+    })
+}
+
+// teeBuffer is what the iterators tee() hands out share: everything read from
+// the source that one of them has yet to see
+type teeBuffer struct {
+    source    Object
+    items     []Object
+    offset    int    // how far into the source items[0] is
+    positions []*int // how far each of the tees has got
+}
+
+func (b *teeBuffer) iterator(position int) *Iter {
+    b.positions = append(b.positions, &position)
+    it := newIterator(teeType, func(env *Environment) (Object, bool) {
+        if position-b.offset == len(b.items) {
+            item, ok := iterNext(env, b.source)
+            if !ok || isError(item) {
+                return item, ok
+            }
+            b.items = append(b.items, item)
+        }
+        item := b.items[position-b.offset]
+        position++
+        b.trim()
+        return item, true
+    })
+    return it
+}
+
+// trim forgets the items every tee has seen
+func (b *teeBuffer) trim() {
+    least := b.offset + len(b.items)
+    for _, position := range b.positions {
+        least = min(least, *position)
+    }
+    if drop := least - b.offset; drop > 32 && drop*2 > len(b.items) {
+        b.items = append([]Object{}, b.items[drop:]...)
+        b.offset = least
+    }
+}
+
+func tee(env *Environment, args []Object, kwargs *Dict) Object {
+    if kwargs.Len() > 0 {
+        return typeError("tee() takes no keyword arguments")
+    }
+    if err := checkArgs("tee", args, nil, 1, 2); err != nil {
+        return err
+    }
+    n := 2
+    if len(args) == 2 {
+        var err *Error
+        if n, err = toIndex(env, args[1]); err != nil {
+            return err
+        }
+        if n < 0 {
+            return valueError("n must be >= 0")
+        }
+    }
+    iterator := getIter(env, args[0])
+    if isError(iterator) {
+        return iterator
+    }
+    buffer := &teeBuffer{source: iterator}
+    tees := make([]Object, n)
+    for i := range tees {
+        tees[i] = buffer.iterator(0)
+    }
+    return &Tuple{Elements: tees}
+}
+
+// The combinatoric iterators read their inputs up front and then walk the
+// index tuples the way the itertools documentation's recipes do
+
+func initCombinatorics() {
+    productType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        repeat := 1
+        for _, entry := range kwargs.Entries() {
+            if name := entry.Key.(*String).Value; name != "repeat" {
+                return typeError("product() got an unexpected keyword argument '%s'", name)
+            }
+            var err *Error
+            if repeat, err = toIndex(env, entry.Value); err != nil {
+                return err
+            }
+            if repeat < 0 {
+                return valueError("repeat argument cannot be negative")
+            }
+        }
+        pools := [][]Object{}
+        for _, arg := range args[1:] {
+            pool, err := iterableToSlice(env, arg)
+            if err != nil {
+                return err
+            }
+            pools = append(pools, pool)
+        }
+        all := [][]Object{}
+        for i := 0; i < repeat; i++ {
+            all = append(all, pools...)
+        }
+        indices := make([]int, len(all))
+        done, first := false, true
+        for _, pool := range all {
+            done = done || len(pool) == 0
+        }
+        return newIterator(productType, func(env *Environment) (Object, bool) {
+            if done {
+                return nil, false
+            }
+            if !first {
+                i := len(all) - 1
+                for ; i >= 0; i-- {
+                    if indices[i]++; indices[i] < len(all[i]) {
+                        break
+                    }
+                    indices[i] = 0
+                }
+                if i < 0 {
+                    done = true
+                    return nil, false
+                }
+            }
+            first = false
+            items := make([]Object, len(all))
+            for i, pool := range all {
+                items[i] = pool[indices[i]]
+            }
+            return &Tuple{Elements: items}, true
+        })
+    })
+
+    permutationsType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        pool, r, err := combinatoricArgs(env, "permutations", args[1:], kwargs, false)
+        if err != nil {
+            return err
+        }
+        n := len(pool)
+        indices := make([]int, n)
+        for i := range indices {
+            indices[i] = i
+        }
+        cycles := make([]int, r)
+        for i := range cycles {
+            cycles[i] = n - i
+        }
+        done, first := r > n, true
+        return newIterator(permutationsType, func(env *Environment) (Object, bool) {
+            if done {
+                return nil, false
+            }
+            if !first {
+                i := r - 1
+                for ; i >= 0; i-- {
+                    cycles[i]--
+                    if cycles[i] == 0 {
+                        moved := indices[i]
+                        copy(indices[i:], indices[i+1:])
+                        indices[n-1] = moved
+                        cycles[i] = n - i
+                        continue
+                    }
+                    j := n - cycles[i]
+                    indices[i], indices[j] = indices[j], indices[i]
+                    break
+                }
+                if i < 0 {
+                    done = true
+                    return nil, false
+                }
+            }
+            first = false
+            return pickIndices(pool, indices[:r]), true
+        })
+    })
+
+    combinationsType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        pool, r, err := combinatoricArgs(env, "combinations", args[1:], kwargs, true)
+        if err != nil {
+            return err
+        }
+        n := len(pool)
+        indices := make([]int, r)
+        for i := range indices {
+            indices[i] = i
+        }
+        done, first := r > n, true
+        return newIterator(combinationsType, func(env *Environment) (Object, bool) {
+            if done {
+                return nil, false
+            }
+            if !first {
+                i := r - 1
+                for i >= 0 && indices[i] == i+n-r {
+                    i--
+                }
+                if i < 0 {
+                    done = true
+                    return nil, false
+                }
+                indices[i]++
+                for j := i + 1; j < r; j++ {
+                    indices[j] = indices[j-1] + 1
+                }
+            }
+            first = false
+            return pickIndices(pool, indices), true
+        })
+    })
+
+    replacementsType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        pool, r, err := combinatoricArgs(env, "combinations_with_replacement", args[1:], kwargs, true)
+        if err != nil {
+            return err
+        }
+        n := len(pool)
+        indices := make([]int, r)
+        done, first := n == 0 && r > 0, true
+        return newIterator(replacementsType, func(env *Environment) (Object, bool) {
+            if done {
+                return nil, false
+            }
+            if !first {
+                i := r - 1
+                for i >= 0 && indices[i] == n-1 {
+                    i--
+                }
+                if i < 0 {
+                    done = true
+                    return nil, false
+                }
+                indices[i]++
+                for j := i + 1; j < r; j++ {
+                    indices[j] = indices[i]
+                }
+            }
+            first = false
+            return pickIndices(pool, indices), true
+        })
+    })
+}
+
+// combinatoricArgs reads (iterable, r); r is optional for permutations only,
+// where it defaults to everything
+func combinatoricArgs(env *Environment, name string, args []Object, kwargs *Dict, needR bool) ([]Object, int, *Error) {
+    required := 1
+    if needR {
+        required = 2
+    }
+    params, err := parseArgs(name, args, kwargs, []string{"iterable", "r"}, required)
+    if err != nil {
+        return nil, 0, err
+    }
+    pool, err := iterableToSlice(env, params[0])
+    if err != nil {
+        return nil, 0, err
+    }
+    r := len(pool)
+    if params[1] != nil && params[1] != NULL {
+        if r, err = toIndex(env, params[1]); err != nil {
+            return nil, 0, err
+        }
+        if r < 0 {
+            return nil, 0, valueError("r must be non-negative")
+        }
+    }
+    return pool, r, nil
+}
+
+func pickIndices(pool []Object, indices []int) *Tuple {
+    items := make([]Object, len(indices))
+    for i, index := range indices {
+        items[i] = pool[index]
+    }
+    return &Tuple{Elements: items}
+}
@@ -0,0 +1,1059 @@
+// Comments in this file are inspired by Oliver Grady - he turns what people say into what the law can read, and back
+
+package evaluator
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "math"
+    "math/big"
+    "strconv"
+    "strings"
+    "unicode/utf16"
+)
+
+// json: dumps and loads. Reading rides on encoding/json's tokenizer, which is
+// quick about big documents; when it balks, a scanner that knows CPython's
+// grammar - NaN and Infinity included - finds out what really went wrong
+
+var jsonDecodeErrorType = newBuiltinClass("JSONDecodeError", valueErrorType)
+
+func init() {
+    registerModule("json", buildJSON)
+
+    jsonDecodeErrorType.Dict.SetStr("__module__", &String{Value: "json.decoder"})
+    jsonDecodeErrorType.method("__init__", 3, 3, func(env *Environment, args []Object) Object {
+        msg, err := strArgument(args[1])
+        if err != nil {
+            return err
+        }
+        doc, err := strArgument(args[2])
+        if err != nil {
+            return err
+        }
+        pos, err := toIndex(env, args[3])
+        if err != nil {
+            return err
+        }
+        fillJSONDecodeError(asException(args[0]), msg, doc, pos)
+        return NULL
+    })
+}
+
+// fillJSONDecodeError works out the line and column of pos, a character index
+func fillJSONDecodeError(e *Exception, msg, doc string, pos int) {
+    prefix := doc[:jsonByteOffset(doc, pos)]
+    lineno := strings.Count(prefix, "\n") + 1
+    colno := pos + 1
+    if last := strings.LastIndexByte(prefix, '\n'); last >= 0 {
+        colno = pos - codePointCount(prefix[:last])
+    }
+    e.Args = &Tuple{Elements: []Object{&String{Value: fmt.Sprintf("%s: line %d column %d (char %d)", msg, lineno, colno, pos)}}}
+    e.Fields["msg"], e.Fields["doc"], e.Fields["pos"] = &String{Value: msg}, &String{Value: doc}, newInt(int64(pos))
+    e.Fields["lineno"], e.Fields["colno"] = newInt(int64(lineno)), newInt(int64(colno))
+}
+
+// jsonError is a JSONDecodeError at byte offset at
+func jsonError(msg, doc string, at int) *Error {
+    e := newException(jsonDecodeErrorType)
+    fillJSONDecodeError(e, msg, doc, codePointCount(doc[:at]))
+    return &Error{Exception: e}
+}
+
+// jsonByteOffset is where character index i of s starts
+func jsonByteOffset(s string, i int) int {
+    offset := 0
+    for ; i > 0 && offset < len(s); i-- {
+        _, size := decodeCodePoint(s[offset:])
+        offset += size
+    }
+    return offset
+}
+
+func isJSONSpace(c byte) bool { return c == ' ' || c == '\t' || c == '\n' || c == '\r' }
+
+func skipJSONSpace(doc string, i int) int {
+    for i < len(doc) && isJSONSpace(doc[i]) {
+        i++
+    }
+    return i
+}
+
+// jsonConstant is NaN, Infinity or -Infinity, which encoding/json never heard of
+type jsonConstant string
+
+// jsonTokens reads the one value doc starts with, and says where it ended.
+// standIns maps the end offsets of the tokens that stand in for one
+// encoding/json can't produce - a jsonConstant, or a string it would mangle.
+func jsonTokens(doc string, standIns map[int64]json.Token) ([]json.Token, int, error) {
+    dec := json.NewDecoder(strings.NewReader(doc))
+    dec.UseNumber()
+    tokens, depth := []json.Token{}, 0
+    for {
+        tok, err := dec.Token()
+        if err != nil {
+            return nil, 0, err
+        }
+        switch tok {
+        case json.Delim('{'), json.Delim('['):
+            depth++
+        case json.Delim('}'), json.Delim(']'):
+            depth--
+        }
+        if standIn, ok := standIns[dec.InputOffset()]; ok {
+            tok = standIn
+        }
+        tokens = append(tokens, tok)
+        if depth == 0 {
+            return tokens, int(dec.InputOffset()), nil
+        }
+    }
+}
+
+// jsonSyntaxError is what the scanner found wrong, at a byte offset
+type jsonSyntaxError struct {
+    msg string
+    at  int
+}
+
+// jsonPatch replaces doc[start:end] with something encoding/json can read.
+// token, if any, is what the replacement really means.
+type jsonPatch struct {
+    start, end  int
+    replacement string
+    token       json.Token
+}
+
+// jsonScanner walks a document the way CPython's scanner does. It only
+// checks: building the values is still the tokenizer's job, once the
+// patches have made the document into JSON it will accept.
+type jsonScanner struct {
+    doc     string
+    strict  bool
+    patches []jsonPatch
+}
+
+func (s *jsonScanner) fail(msg string, at int) (int, *jsonSyntaxError) {
+    return 0, &jsonSyntaxError{msg, at}
+}
+
+func (s *jsonScanner) value(i int) (int, *jsonSyntaxError) {
+    if i >= len(s.doc) {
+        return s.fail("Expecting value", i)
+    }
+    switch s.doc[i] {
+    case '"':
+        return s.string(i + 1)
+    case '{':
+        return s.object(i + 1)
+    case '[':
+        return s.array(i + 1)
+    }
+    for _, literal := range []string{"null", "true", "false"} {
+        if strings.HasPrefix(s.doc[i:], literal) {
+            return i + len(literal), nil
+        }
+    }
+    for _, constant := range []string{"NaN", "Infinity", "-Infinity"} {
+        if strings.HasPrefix(s.doc[i:], constant) {
+            s.patches = append(s.patches, jsonPatch{start: i, end: i + len(constant), replacement: "0", token: jsonConstant(constant)})
+            return i + len(constant), nil
+        }
+    }
+    return s.number(i)
+}
+
+// number is -?(0|[1-9]\d*)(\.\d+)?([eE][-+]?\d+)?, each optional part only
+// taken when it's all there
+func (s *jsonScanner) number(start int) (int, *jsonSyntaxError) {
+    digits := func(i int) int {
+        for i < len(s.doc) && s.doc[i] >= '0' && s.doc[i] <= '9' {
+            i++
+        }
+        return i
+    }
+    i := start
+    if i < len(s.doc) && s.doc[i] == '-' {
+        i++
+    }
+    switch {
+    case i < len(s.doc) && s.doc[i] == '0':
+        i++
+    case digits(i) > i:
+        i = digits(i)
+    default:
+        return s.fail("Expecting value", start)
+    }
+    if i+1 < len(s.doc) && s.doc[i] == '.' && digits(i+1) > i+1 {
+        i = digits(i + 1)
+    }
+    if i < len(s.doc) && (s.doc[i] == 'e' || s.doc[i] == 'E') {
+        j := i + 1
+        if j < len(s.doc) && (s.doc[j] == '+' || s.doc[j] == '-') {
+            j++
+        }
+        if digits(j) > j {
+            i = digits(j)
+        }
+    }
+    return i, nil
+}
+
+// string starts just past the opening quote
+func (s *jsonScanner) string(begin int) (int, *jsonSyntaxError) {
+    isHex := func(i int) bool {
+        if i+4 > len(s.doc) {
+            return false
+        }
+        _, err := strconv.ParseUint(s.doc[i:i+4], 16, 16)
+        return err == nil
+    }
+    patches, lone := len(s.patches), false
+    for i := begin; i < len(s.doc); i++ {
+        switch c := s.doc[i]; {
+        case c == '"':
+            if lone {
+                // encoding/json turns a lone surrogate into U+FFFD, so this
+                // string is ours to decode
+                s.patches = append(s.patches[:patches], jsonPatch{start: begin - 1, end: i + 1, replacement: `""`, token: jsonUnescape(s.doc[begin:i])})
+            }
+            return i + 1, nil
+        case c < 0x20:
+            if s.strict {
+                return s.fail("Invalid control character at", i)
+            }
+            s.patches = append(s.patches, jsonPatch{start: i, end: i + 1, replacement: fmt.Sprintf(`\u%04x`, c)})
+        case c == '\\':
+            i++
+            if i >= len(s.doc) {
+                break
+            }
+            if s.doc[i] != 'u' {
+                if !strings.ContainsRune(`"\/bfnrt`, rune(s.doc[i])) {
+                    return s.fail("Invalid \\escape", i-1)
+                }
+                continue
+            }
+            if !isHex(i + 1) {
+                return s.fail("Invalid \\uXXXX escape", i)
+            }
+            // a high surrogate's partner has to be a proper escape too
+            code, _ := strconv.ParseUint(s.doc[i+1:i+5], 16, 16)
+            if code >= 0xd800 && code <= 0xdbff && strings.HasPrefix(s.doc[i+5:], `\u`) && i+11 <= len(s.doc) && !isHex(i+7) {
+                return s.fail("Invalid \\uXXXX escape", i+6)
+            }
+            if code >= 0xd800 && code <= 0xdbff {
+                low, _ := strconv.ParseUint(s.doc[min(i+7, len(s.doc)):min(i+11, len(s.doc))], 16, 16)
+                if !strings.HasPrefix(s.doc[i+5:], `\u`) || low < 0xdc00 || low > 0xdfff {
+                    lone = true
+                } else {
+                    i += 6 // the pair is encoding/json's to join
+                }
+            } else if code >= 0xdc00 && code <= 0xdfff {
+                lone = true
+            }
+            i += 4
+        }
+    }
+    return s.fail("Unterminated string starting at", begin-1)
+}
+
+func (s *jsonScanner) object(i int) (int, *jsonSyntaxError) {
+    i = skipJSONSpace(s.doc, i)
+    if i < len(s.doc) && s.doc[i] == '}' {
+        return i + 1, nil
+    }
+    var err *jsonSyntaxError
+    for {
+        if i >= len(s.doc) || s.doc[i] != '"' {
+            return s.fail("Expecting property name enclosed in double quotes", i)
+        }
+        if i, err = s.string(i + 1); err != nil {
+            return 0, err
+        }
+        i = skipJSONSpace(s.doc, i)
+        if i >= len(s.doc) || s.doc[i] != ':' {
+            return s.fail("Expecting ':' delimiter", i)
+        }
+        if i, err = s.value(skipJSONSpace(s.doc, i+1)); err != nil {
+            return 0, err
+        }
+        i = skipJSONSpace(s.doc, i)
+        if i < len(s.doc) && s.doc[i] == '}' {
+            return i + 1, nil
+        }
+        if i >= len(s.doc) || s.doc[i] != ',' {
+            return s.fail("Expecting ',' delimiter", i)
+        }
+        i = skipJSONSpace(s.doc, i+1)
+    }
+}
+
+func (s *jsonScanner) array(i int) (int, *jsonSyntaxError) {
+    i = skipJSONSpace(s.doc, i)
+    if i < len(s.doc) && s.doc[i] == ']' {
+        return i + 1, nil
+    }
+    var err *jsonSyntaxError
+    for {
+        if i, err = s.value(i); err != nil {
+            return 0, err
+        }
+        i = skipJSONSpace(s.doc, i)
+        if i < len(s.doc) && s.doc[i] == ']' {
+            return i + 1, nil
+        }
+        if i >= len(s.doc) || s.doc[i] != ',' {
+            return s.fail("Expecting ',' delimiter", i)
+        }
+        i = skipJSONSpace(s.doc, i+1)
+    }
+}
+
+// rewrite applies the patches to doc[start:end], noting where each stand-in
+// ends
+func (s *jsonScanner) rewrite(start, end int) (string, map[int64]json.Token) {
+    var b strings.Builder
+    standIns := map[int64]json.Token{}
+    at := start
+    for _, patch := range s.patches {
+        b.WriteString(s.doc[at:patch.start])
+        b.WriteString(patch.replacement)
+        if patch.token != nil {
+            standIns[int64(b.Len())] = patch.token
+        }
+        at = patch.end
+    }
+    b.WriteString(s.doc[at:end])
+    return b.String(), standIns
+}
+
+// hasSurrogateEscape is whether doc might hold a \uXXXX escape for a
+// surrogate - one encoding/json may have quietly replaced
+func hasSurrogateEscape(doc string) bool {
+    for i := strings.Index(doc, `\u`); i >= 0 && i+3 < len(doc); i = strings.Index(doc, `\u`) {
+        if (doc[i+2] == 'd' || doc[i+2] == 'D') && strings.IndexByte("89abcdefABCDEF", doc[i+3]) >= 0 {
+            return true
+        }
+        doc = doc[i+2:]
+    }
+    return false
+}
+
+// jsonUnescape decodes the inside of a string the scanner has already
+// checked, keeping the surrogates that don't pair up
+func jsonUnescape(body string) string {
+    var b strings.Builder
+    for i := 0; i < len(body); i++ {
+        if body[i] != '\\' {
+            b.WriteByte(body[i])
+            continue
+        }
+        i++
+        switch body[i] {
+        case 'b':
+            b.WriteByte('\b')
+            continue
+        case 'f':
+            b.WriteByte('\f')
+            continue
+        case 'n':
+            b.WriteByte('\n')
+            continue
+        case 'r':
+            b.WriteByte('\r')
+            continue
+        case 't':
+            b.WriteByte('\t')
+            continue
+        case 'u':
+        default:
+            b.WriteByte(body[i]) // " \ or /
+            continue
+        }
+        code, _ := strconv.ParseUint(body[i+1:i+5], 16, 16)
+        i += 4
+        r := rune(code)
+        if r >= 0xd800 && r <= 0xdbff && strings.HasPrefix(body[i+1:], `\u`) && i+7 <= len(body) {
+            if low, err := strconv.ParseUint(body[i+3:i+7], 16, 16); err == nil && low >= 0xdc00 && low <= 0xdfff {
+                r = utf16.DecodeRune(r, rune(low))
+                i += 6
+            }
+        }
+        writeCodePoint(&b, r)
+    }
+    return b.String()
+}
+
+// jsonDecoder is a JSONDecoder's settings; a nil hook is one left as None
+type jsonDecoder struct {
+    env                                 *Environment
+    objectHook, pairsHook               Object
+    parseFloat, parseInt, parseConstant Object
+    strict                              bool
+    tokens                              []json.Token
+}
+
+func newJSONDecoder(env *Environment, self Object) (*jsonDecoder, *Error) {
+    d := &jsonDecoder{env: env}
+    hooks := map[string]*Object{"object_hook": &d.objectHook, "object_pairs_hook": &d.pairsHook,
+        "parse_float": &d.parseFloat, "parse_int": &d.parseInt, "parse_constant": &d.parseConstant}
+    for name, hook := range hooks {
+        value := getAttribute(env, self, name)
+        if err, ok := value.(*Error); ok {
+            return nil, err
+        }
+        if value != NULL {
+            *hook = value
+        }
+    }
+    strict := getAttribute(env, self, "strict")
+    if err, ok := strict.(*Error); ok {
+        return nil, err
+    }
+    var err *Error
+    d.strict, err = truthy(env, strict)
+    return d, err
+}
+
+// decode reads the value starting at byte offset start, and where it ends
+func (d *jsonDecoder) decode(doc string, start int) (Object, int, *Error) {
+    if start >= len(doc) || isJSONSpace(doc[start]) {
+        return nil, 0, jsonError("Expecting value", doc, start)
+    }
+    tokens, end, err := jsonTokens(doc[start:], nil)
+    end += start
+    if err != nil || hasSurrogateEscape(doc[start:end]) {
+        scanner := &jsonScanner{doc: doc, strict: d.strict}
+        var syntaxErr *jsonSyntaxError
+        if end, syntaxErr = scanner.value(start); syntaxErr != nil {
+            return nil, 0, jsonError(syntaxErr.msg, doc, syntaxErr.at)
+        }
+        // valid, just not to encoding/json: NaN, Infinity, a raw control
+        // character or a lone surrogate - or nesting deeper than it will go
+        if tokens, _, err = jsonTokens(scanner.rewrite(start, end)); err != nil {
+            return nil, 0, newErrorKind(recursionErrorType, "maximum recursion depth exceeded while decoding a JSON document")
+        }
+    }
+    d.tokens = tokens
+    value := d.value()
+    if err, ok := value.(*Error); ok {
+        return nil, 0, err
+    }
+    return value, end, nil
+}
+
+func (d *jsonDecoder) call(hook Object, arg Object) Object {
+    return applyFunction(d.env, hook, []Object{arg}, nil)
+}
+
+// value builds the value at the front of the tokens, and takes it off
+func (d *jsonDecoder) value() Object {
+    tok := d.tokens[0]
+    d.tokens = d.tokens[1:]
+    switch tok := tok.(type) {
+    case json.Delim:
+        if tok == '[' {
+            return d.array()
+        }
+        return d.object()
+    case string:
+        return &String{Value: tok}
+    case json.Number:
+        return d.number(string(tok))
+    case bool:
+        return nativeBool(tok)
+    case jsonConstant:
+        if d.parseConstant != nil {
+            return d.call(d.parseConstant, &String{Value: string(tok)})
+        }
+        switch tok {
+        case "NaN":
+            return &Float{Value: math.NaN()}
+        case "Infinity":
+            return &Float{Value: math.Inf(1)}
+        }
+        return &Float{Value: math.Inf(-1)}
+    }
+    return NULL
+}
+
+func (d *jsonDecoder) number(text string) Object {
+    if strings.ContainsAny(text, ".eE") {
+        if d.parseFloat != nil {
+            return d.call(d.parseFloat, &String{Value: text})
+        }
+        // out of range is still a float: inf, or 0
+        f, _ := strconv.ParseFloat(text, 64)
+        return &Float{Value: f}
+    }
+    if d.parseInt != nil {
+        return d.call(d.parseInt, &String{Value: text})
+    }
+    n, _ := new(big.Int).SetString(text, 10)
+    return newBigInt(n)
+}
+
+func (d *jsonDecoder) array() Object {
+    list := &List{Elements: []Object{}}
+    for d.tokens[0] != json.Delim(']') {
+        item := d.value()
+        if isError(item) {
+            return item
+        }
+        list.Elements = append(list.Elements, item)
+    }
+    d.tokens = d.tokens[1:]
+    return list
+}
+
+func (d *jsonDecoder) object() Object {
+    pairs := []Object{}
+    for d.tokens[0] != json.Delim('}') {
+        key := d.value()
+        value := d.value()
+        if isError(value) {
+            return value
+        }
+        pairs = append(pairs, &Tuple{Elements: []Object{key, value}})
+    }
+    d.tokens = d.tokens[1:]
+    if d.pairsHook != nil {
+        return d.call(d.pairsHook, &List{Elements: pairs})
+    }
+    dict := NewDict()
+    for _, pair := range pairs {
+        if err := dict.Set(d.env, pair.(*Tuple).Elements[0], pair.(*Tuple).Elements[1]); err != nil {
+            return err
+        }
+    }
+    if d.objectHook != nil {
+        return d.call(d.objectHook, dict)
+    }
+    return dict
+}
+
+// jsonEncoder is a JSONEncoder's settings, and the text so far
+type jsonEncoder struct {
+    env                                   *Environment
+    self                                  Object // whose default() gets whatever we can't write
+    skipKeys, ensureASCII, allowNaN, sort bool
+    markers                               map[Object]bool // nil when check_circular is off
+    indent                                *string
+    itemSeparator, keySeparator           string
+    out                                   strings.Builder
+}
+
+func newJSONEncoder(env *Environment, self Object) (*jsonEncoder, *Error) {
+    e := &jsonEncoder{env: env, self: self}
+    attribute := func(name string) (Object, *Error) {
+        value := getAttribute(env, self, name)
+        if err, ok := value.(*Error); ok {
+            return nil, err
+        }
+        return value, nil
+    }
+    flags := map[string]*bool{"skipkeys": &e.skipKeys, "ensure_ascii": &e.ensureASCII, "allow_nan": &e.allowNaN, "sort_keys": &e.sort}
+    for name, flag := range flags {
+        value, err := attribute(name)
+        if err != nil {
+            return nil, err
+        }
+        if *flag, err = truthy(env, value); err != nil {
+            return nil, err
+        }
+    }
+    value, err := attribute("check_circular")
+    if err != nil {
+        return nil, err
+    }
+    if circular, err := truthy(env, value); err != nil {
+        return nil, err
+    } else if circular {
+        e.markers = map[Object]bool{}
+    }
+    if value, err = attribute("indent"); err != nil {
+        return nil, err
+    }
+    if value != NULL {
+        indent, ok := payload(value).(*String)
+        if !ok {
+            n, err := indexValue(env, value)
+            if err != nil {
+                return nil, err
+            }
+            if !n.IsInt64() {
+                return nil, overflowError("cannot fit 'int' into an index-sized integer")
+            }
+            width, err := allocation(max(int(n.Int64()), 0), 1)
+            if err != nil {
+                return nil, err
+            }
+            indent = &String{Value: strings.Repeat(" ", width)}
+        }
+        e.indent = &indent.Value
+    }
+    for name, separator := range map[string]*string{"item_separator": &e.itemSeparator, "key_separator": &e.keySeparator} {
+        if value, err = attribute(name); err != nil {
+            return nil, err
+        }
+        if *separator, err = strArgument(value); err != nil {
+            return nil, err
+        }
+    }
+    return e, nil
+}
+
+// jsonString quotes s, escaping everything past '~' too when ascii is set
+func jsonString(s string, ascii bool) string {
+    var b strings.Builder
+    b.WriteByte('"')
+    for len(s) > 0 {
+        r, size := decodeCodePoint(s)
+        switch {
+        case r == '"':
+            b.WriteString(`\"`)
+        case r == '\\':
+            b.WriteString(`\\`)
+        case r == '\n':
+            b.WriteString(`\n`)
+        case r == '\r':
+            b.WriteString(`\r`)
+        case r == '\t':
+            b.WriteString(`\t`)
+        case r == '\b':
+            b.WriteString(`\b`)
+        case r == '\f':
+            b.WriteString(`\f`)
+        case r < 0x20 || ascii && r > '~' && r < 0x10000:
+            fmt.Fprintf(&b, `\u%04x`, r)
+        case ascii && r > '~':
+            r -= 0x10000
+            fmt.Fprintf(&b, `\u%04x\u%04x`, 0xd800|r>>10, 0xdc00|r&0x3ff)
+        default:
+            b.WriteString(s[:size])
+        }
+        s = s[size:]
+    }
+    b.WriteByte('"')
+    return b.String()
+}
+
+func (e *jsonEncoder) float(f float64) (string, *Error) {
+    text := floatRepr(f)
+    switch {
+    case math.IsNaN(f):
+        text = "NaN"
+    case math.IsInf(f, 1):
+        text = "Infinity"
+    case math.IsInf(f, -1):
+        text = "-Infinity"
+    default:
+        return text, nil
+    }
+    if !e.allowNaN {
+        return "", valueError("Out of range float values are not JSON compliant")
+    }
+    return text, nil
+}
+
+// mark and unmark keep a container from turning up inside itself
+func (e *jsonEncoder) mark(obj Object) *Error {
+    if e.markers == nil {
+        return nil
+    }
+    if e.markers[obj] {
+        return valueError("Circular reference detected")
+    }
+    e.markers[obj] = true
+    return nil
+}
+
+func (e *jsonEncoder) unmark(obj Object) {
+    delete(e.markers, obj)
+}
+
+// newline starts the next line at the given depth, when there's an indent
+func (e *jsonEncoder) newline(level int) {
+    if e.indent != nil {
+        e.out.WriteString("\n" + strings.Repeat(*e.indent, level))
+    }
+}
+
+func (e *jsonEncoder) encode(obj Object, level int) *Error {
+    switch value := payload(obj).(type) {
+    case *NullObject:
+        e.out.WriteString("null")
+    case *Boolean:
+        e.out.WriteString(strconv.FormatBool(value.Value))
+    case *String:
+        e.out.WriteString(jsonString(value.Value, e.ensureASCII))
+    case *Integer:
+        e.out.WriteString(value.Value.String())
+    case *Float:
+        text, err := e.float(value.Value)
+        if err != nil {
+            return err
+        }
+        e.out.WriteString(text)
+    case *List:
+        return e.array(obj, value.Elements, level)
+    case *Tuple:
+        return e.array(obj, value.Elements, level)
+    case *Dict:
+        return e.object(obj, value, level)
+    default:
+        if err := e.mark(obj); err != nil {
+            return err
+        }
+        converted := callAttribute(e.env, e.self, "default", obj)
+        if err, ok := converted.(*Error); ok {
+            return err
+        }
+        if err := e.encode(converted, level); err != nil {
+            return err
+        }
+        e.unmark(obj)
+    }
+    return nil
+}
+
+func (e *jsonEncoder) array(obj Object, items []Object, level int) *Error {
+    if len(items) == 0 {
+        e.out.WriteString("[]")
+        return nil
+    }
+    if err := e.mark(obj); err != nil {
+        return err
+    }
+    e.out.WriteByte('[')
+    for i, item := range items {
+        if i > 0 {
+            e.out.WriteString(e.itemSeparator)
+        }
+        e.newline(level + 1)
+        if err := e.encode(item, level+1); err != nil {
+            return err
+        }
+    }
+    e.newline(level)
+    e.out.WriteByte(']')
+    e.unmark(obj)
+    return nil
+}
+
+// key is what a dict key becomes, or "" with skip set to leave it out
+func (e *jsonEncoder) key(key Object) (text string, skip bool, err *Error) {
+    switch k := payload(key).(type) {
+    case *String:
+        return k.Value, false, nil
+    case *Float:
+        text, err := e.float(k.Value)
+        return text, false, err
+    case *Boolean:
+        return strconv.FormatBool(k.Value), false, nil
+    case *NullObject:
+        return "null", false, nil
+    case *Integer:
+        return k.Value.String(), false, nil
+    }
+    if e.skipKeys {
+        return "", true, nil
+    }
+    return "", false, typeError("keys must be str, int, float, bool or None, not %s", typeName(key))
+}
+
+func (e *jsonEncoder) object(obj Object, dict *Dict, level int) *Error {
+    if dict.Len() == 0 {
+        e.out.WriteString("{}")
+        return nil
+    }
+    if err := e.mark(obj); err != nil {
+        return err
+    }
+    items := []Object{}
+    for _, entry := range dict.Entries() {
+        items = append(items, &Tuple{Elements: []Object{entry.Key, entry.Value}})
+    }
+    if e.sort {
+        var err *Error
+        if items, err = sortObjects(e.env, items, nil, false); err != nil {
+            return err
+        }
+    }
+    e.out.WriteByte('{')
+    first := true
+    for _, item := range items {
+        pair := item.(*Tuple).Elements
+        key, skip, err := e.key(pair[0])
+        if err != nil {
+            return err
+        }
+        if skip {
+            continue
+        }
+        if !first {
+            e.out.WriteString(e.itemSeparator)
+        }
+        first = false
+        e.newline(level + 1)
+        e.out.WriteString(jsonString(key, e.ensureASCII) + e.keySeparator)
+        if err := e.encode(pair[1], level+1); err != nil {
+            return err
+        }
+    }
+    e.newline(level)
+    e.out.WriteByte('}')
+    e.unmark(obj)
+    return nil
+}
+
+// jsonInstance is cls(**kwargs), cls being the one passed in kwargs, if any
+func jsonInstance(env *Environment, kwargs *Dict, fallback *Class) Object {
+    var cls Object = fallback
+    options := NewDict()
+    for _, entry := range kwargs.Entries() {
+        if name, ok := entry.Key.(*String); ok && name.Value == "cls" {
+            if entry.Value != NULL {
+                cls = entry.Value
+            }
+            continue
+        }
+        options.SetStr(entry.Key.(*String).Value, entry.Value)
+    }
+    return applyFunction(env, cls, nil, options)
+}
+
+// jsonPositional checks the positional arguments of dump(), dumps(), load() and loads()
+func jsonPositional(name string, args []Object, params ...string) *Error {
+    switch {
+    case len(args) > len(params):
+        plural := "s"
+        if len(params) == 1 {
+            plural = ""
+        }
+        return typeError("%s() takes %d positional argument%s but %d were given", name, len(params), plural, len(args))
+    case len(args) < len(params):
+        missing := params[len(args):]
+        if len(missing) == 1 {
+            return typeError("%s() missing 1 required positional argument: '%s'", name, missing[0])
+        }
+        return typeError("%s() missing %d required positional arguments: '%s'", name, len(missing), strings.Join(missing, "' and '"))
+    }
+    return nil
+}
+
+func buildJSON(m *Module) {
+    m.Env.Set("JSONDecodeError", jsonDecodeErrorType)
+
+    encoder := m.class("JSONEncoder")
+    encoder.Dict.SetStr("item_separator", &String{Value: ", "})
+    encoder.Dict.SetStr("key_separator", &String{Value: ": "})
+    encoder.define("__init__", func(env *Environment, args []Object, kwargs *Dict) Object {
+        names := []string{"skipkeys", "ensure_ascii", "check_circular", "allow_nan", "sort_keys", "indent", "separators", "default"}
+        defaults := []Object{FALSE, TRUE, TRUE, TRUE, FALSE, NULL, NULL, NULL}
+        params := make([]string, len(names))
+        for i, name := range names {
+            params[i] = "*" + name
+        }
+        values, err := parseArgs("JSONEncoder.__init__", args[1:], kwargs, params, 0)
+        if err != nil {
+            return err
+        }
+        self := args[0]
+        for i, name := range names[:6] {
+            if values[i] == nil {
+                values[i] = defaults[i]
+            }
+            if err := setAttribute(env, self, name, values[i]); err != nil {
+                return err
+            }
+        }
+        if separators := values[6]; separators != nil && separators != NULL {
+            pair, err := iterableToSlice(env, separators)
+            if err != nil {
+                return err
+            }
+            switch {
+            case len(pair) > 2:
+                return valueError("too many values to unpack (expected 2)")
+            case len(pair) < 2:
+                return valueError("not enough values to unpack (expected 2, got %d)", len(pair))
+            }
+            if err := setAttribute(env, self, "item_separator", pair[0]); err != nil {
+                return err
+            }
+            if err := setAttribute(env, self, "key_separator", pair[1]); err != nil {
+                return err
+            }
+        } else if values[5] != nil && values[5] != NULL {
+            if err := setAttribute(env, self, "item_separator", &String{Value: ","}); err != nil {
+                return err
+            }
+        }
+        if values[7] != nil && values[7] != NULL {
+            return setField(env, self, "default", values[7])
+        }
+        return NULL
+    })
+    encoder.method("default", 1, 1, func(env *Environment, args []Object) Object {
+        return typeError("Object of type %s is not JSON serializable", typeName(args[1]))
+    })
+    encoder.method("encode", 1, 1, func(env *Environment, args []Object) Object {
+        e, err := newJSONEncoder(env, args[0])
+        if err != nil {
+            return err
+        }
+        if err := e.encode(args[1], 0); err != nil {
+            return err
+        }
+        return &String{Value: e.out.String()}
+    })
+    encoder.method("iterencode", 1, 2, func(env *Environment, args []Object) Object {
+        text := callAttribute(env, args[0], "encode", args[1])
+        if isError(text) {
+            return text
+        }
+        return getIter(env, &List{Elements: []Object{text}})
+    })
+
+    decoder := m.class("JSONDecoder")
+    decoder.define("__init__", func(env *Environment, args []Object, kwargs *Dict) Object {
+        names := []string{"object_hook", "parse_float", "parse_int", "parse_constant", "strict", "object_pairs_hook"}
+        params := make([]string, len(names))
+        for i, name := range names {
+            params[i] = "*" + name
+        }
+        values, err := parseArgs("JSONDecoder.__init__", args[1:], kwargs, params, 0)
+        if err != nil {
+            return err
+        }
+        for i, name := range names {
+            value := values[i]
+            switch {
+            case value != nil:
+            case name == "strict":
+                value = TRUE
+            default:
+                value = NULL
+            }
+            if err := setAttribute(env, args[0], name, value); err != nil {
+                return err
+            }
+        }
+        return NULL
+    })
+    decoder.method("raw_decode", 1, 2, func(env *Environment, args []Object) Object {
+        doc, ok := payload(args[1]).(*String)
+        if !ok {
+            return typeError("first argument must be a string, not %s", typeName(args[1]))
+        }
+        idx := 0
+        if len(args) == 3 {
+            var err *Error
+            if idx, err = toIndex(env, args[2]); err != nil {
+                return err
+            }
+        }
+        if idx < 0 {
+            return valueError("idx cannot be negative")
+        }
+        d, err := newJSONDecoder(env, args[0])
+        if err != nil {
+            return err
+        }
+        value, end, err := d.decode(doc.Value, jsonByteOffset(doc.Value, idx))
+        if err != nil {
+            return err
+        }
+        return &Tuple{Elements: []Object{value, newInt(int64(codePointCount(doc.Value[:end])))}}
+    })
+    decoder.method("decode", 1, 1, func(env *Environment, args []Object) Object {
+        doc, ok := payload(args[1]).(*String)
+        if !ok {
+            return typeError("expected string or bytes-like object")
+        }
+        d, err := newJSONDecoder(env, args[0])
+        if err != nil {
+            return err
+        }
+        value, end, err := d.decode(doc.Value, skipJSONSpace(doc.Value, 0))
+        if err != nil {
+            return err
+        }
+        if end = skipJSONSpace(doc.Value, end); end != len(doc.Value) {
+            return jsonError("Extra data", doc.Value, end)
+        }
+        return value
+    })
+
+    dumps := func(env *Environment, obj Object, kwargs *Dict) Object {
+        encoder := jsonInstance(env, kwargs, encoder)
+        if isError(encoder) {
+            return encoder
+        }
+        return callAttribute(env, encoder, "encode", obj)
+    }
+    m.function("dumps", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := jsonPositional("dumps", args, "obj"); err != nil {
+            return err
+        }
+        return dumps(env, args[0], kwargs)
+    })
+    m.function("dump", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := jsonPositional("dump", args, "obj", "fp"); err != nil {
+            return err
+        }
+        text := dumps(env, args[0], kwargs)
+        if isError(text) {
+            return text
+        }
+        if written := callAttribute(env, args[1], "write", text); isError(written) {
+            return written
+        }
+        return NULL
+    })
+
+    loads := func(env *Environment, s Object, kwargs *Dict) Object {
+        var data []byte
+        switch doc := payload(s).(type) {
+        case *String:
+            if strings.HasPrefix(doc.Value, "\ufeff") {
+                return jsonError("Unexpected UTF-8 BOM (decode using utf-8-sig)", doc.Value, 0)
+            }
+        case *Bytes:
+            data = doc.Value
+        case *ByteArray:
+            data = doc.Value
+        default:
+            return typeError("the JSON object must be str, bytes or bytearray, not %s", typeName(s))
+        }
+        if _, isStr := payload(s).(*String); !isStr {
+            if s = decodeBytes(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), "utf-8", "strict"); isError(s) {
+                return s
+            }
+        }
+        decoder := jsonInstance(env, kwargs, decoder)
+        if isError(decoder) {
+            return decoder
+        }
+        return callAttribute(env, decoder, "decode", s)
+    }
+    m.function("loads", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := jsonPositional("loads", args, "s"); err != nil {
+            return err
+        }
+        return loads(env, args[0], kwargs)
+    })
+    m.function("load", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := jsonPositional("load", args, "fp"); err != nil {
+            return err
+        }
+        text := callAttribute(env, args[0], "read")
+        if isError(text) {
+            return text
+        }
+        return loads(env, text, kwargs)
+    })
+}
@@ -0,0 +1,389 @@
+// Comments in this file are inspired by Stu Buzzini - the accountant Louis trusts to get the last digit right
+
+package evaluator
+
+import "math"
+
+// Go's math package is good to an ulp or so. CPython gets its floats from
+// the C library, which nearly always rounds correctly, and Python programs
+// print every digit. So the functions here do their sums in double-double
+// and round once at the end.
+
+// doubleDouble is an unevaluated sum hi+lo, about 106 bits of precision
+type doubleDouble struct{ hi, lo float64 }
+
+func dd(x float64) doubleDouble { return doubleDouble{x, 0} }
+
+// ln2 is log(2) to 106 bits
+var ln2 = doubleDouble{6.93147180559945286227e-01, 2.31904681384629955842e-17}
+
+func (a doubleDouble) add(b doubleDouble) doubleDouble {
+    s := a.hi + b.hi
+    v := s - a.hi
+    e := (a.hi - (s - v)) + (b.hi - v) + a.lo + b.lo
+    hi := s + e
+    return doubleDouble{hi, e - (hi - s)}
+}
+
+func (a doubleDouble) neg() doubleDouble { return doubleDouble{-a.hi, -a.lo} }
+
+func (a doubleDouble) sub(b doubleDouble) doubleDouble { return a.add(b.neg()) }
+
+func (a doubleDouble) mul(b doubleDouble) doubleDouble {
+    p := a.hi * b.hi
+    e := math.FMA(a.hi, b.hi, -p) + a.hi*b.lo + a.lo*b.hi
+    hi := p + e
+    return doubleDouble{hi, e - (hi - p)}
+}
+
+func (a doubleDouble) div(b doubleDouble) doubleDouble {
+    q := a.hi / b.hi
+    rest := a.sub(b.mul(dd(q))).hi / b.hi
+    hi := q + rest
+    return doubleDouble{hi, rest - (hi - q)}
+}
+
+// scale multiplies by 2**e, which is exact
+func (a doubleDouble) scale(e int) doubleDouble {
+    return doubleDouble{math.Ldexp(a.hi, e), math.Ldexp(a.lo, e)}
+}
+
+func (a doubleDouble) sqrt() doubleDouble {
+    s := math.Sqrt(a.hi)
+    if s == 0 {
+        return doubleDouble{}
+    }
+    c := a.sub(dd(s).mul(dd(s))).hi / (2 * s)
+    hi := s + c
+    return doubleDouble{hi, c - (hi - s)}
+}
+
+// exp reduces by ln2 and then by 2**10, sums the series and squares back up
+func (a doubleDouble) exp() doubleDouble {
+    switch {
+    case a.hi > 710:
+        return dd(math.Inf(1))
+    case a.hi < -746:
+        return doubleDouble{}
+    }
+    k := math.Round(a.hi / ln2.hi)
+    r := a.sub(ln2.mul(dd(k))).scale(-10)
+    term := r
+    sum := dd(1).add(term)
+    for n := 2; n <= 11; n++ {
+        term = term.mul(r).div(dd(float64(n)))
+        sum = sum.add(term)
+    }
+    for i := 0; i < 10; i++ {
+        sum = sum.mul(sum)
+    }
+    return sum.scale(int(k))
+}
+
+// log1pSeries is log(1+u) for |u| < 1e-3, where the series converges fast
+// and nothing cancels
+func log1pSeries(u doubleDouble) doubleDouble {
+    sum, power := u, u
+    for n := 2; ; n++ {
+        power = power.mul(u).neg()
+        term := power.div(dd(float64(n)))
+        if math.Abs(term.hi) <= math.Abs(u.hi)*1e-34 {
+            return sum
+        }
+        sum = sum.add(term)
+    }
+}
+
+// log of a positive finite double-double: split off the power of two, then
+// either the series or one Newton step on Go's log of the mantissa
+func (a doubleDouble) log() doubleDouble {
+    _, e := math.Frexp(a.hi)
+    m := a.scale(-e)
+    if m.hi < math.Sqrt2/2 {
+        m, e = m.scale(1), e-1
+    }
+    u := m.sub(dd(1))
+    var result doubleDouble
+    if math.Abs(u.hi) < 1e-3 {
+        result = log1pSeries(u)
+    } else {
+        l := math.Log(m.hi)
+        result = dd(l).add(dd(-l).exp().mul(m).sub(dd(1)))
+    }
+    return result.add(ln2.mul(dd(float64(e))))
+}
+
+func exponential(x float64) float64 {
+    if math.IsNaN(x) || math.IsInf(x, 0) {
+        return math.Exp(x)
+    }
+    return dd(x).exp().hi
+}
+
+func logarithm(x float64) float64 {
+    if !(x > 0) || math.IsInf(x, 0) {
+        return math.Log(x)
+    }
+    return dd(x).log().hi
+}
+
+func power(x, y float64) float64 {
+    special := func(f float64) bool { return math.IsInf(f, 0) || math.IsNaN(f) }
+    switch {
+    case x < 0 && y == math.Trunc(y) && !special(y):
+        p := power(-x, y)
+        if math.Mod(y, 2) != 0 {
+            return -p
+        }
+        return p
+    case x <= 0 || y == 0 || x == 1 || special(x) || special(y):
+        // zeros, infinities, nans and the rest of the edges are math.Pow's
+        return math.Pow(x, y)
+    }
+    return dd(x).log().mul(dd(y)).exp().hi
+}
+
+// hypot is sqrt(x*x + y*y) with Borges' correction step
+func hypot(x, y float64) float64 {
+    x, y = math.Abs(x), math.Abs(y)
+    switch {
+    case math.IsInf(x, 0) || math.IsInf(y, 0):
+        return math.Inf(1)
+    case math.IsNaN(x) || math.IsNaN(y):
+        return math.NaN()
+    }
+    if x < y {
+        x, y = y, x
+    }
+    if y == 0 {
+        return x
+    }
+    e := math.Ilogb(x)
+    x, y = math.Ldexp(x, -e), math.Ldexp(y, -e)
+    h := math.Sqrt(math.FMA(x, x, y*y))
+    hsq, xsq := h*h, x*x
+    residual := math.FMA(-y, y, hsq-xsq) + math.FMA(h, h, -hsq) - math.FMA(x, x, -xsq)
+    h -= residual / (2 * h)
+    return math.Ldexp(h, e)
+}
+
+// reduceHalfPi writes x as n*pi/2 + y, with pi/2 carried to 118 bits so the
+// cancellation near a multiple of pi/2 leaves real digits behind. This is
+// fdlibm's medium-size reduction; huge and non-finite x are refused.
+func reduceHalfPi(x float64) (int, doubleDouble, bool) {
+    const (
+        invHalfPi = 6.36619772367581382433e-01
+        pio2_1    = 1.57079632673412561417e+00
+        pio2_1t   = 6.07710050650619224932e-11
+        pio2_2    = 6.07710050630396597660e-11
+        pio2_2t   = 2.02226624879595063154e-21
+        pio2_3    = 2.02226624871116645580e-21
+        pio2_3t   = 8.47842766036889956997e-32
+    )
+    t := math.Abs(x)
+    if t <= math.Pi/4 {
+        return 0, dd(x), true
+    }
+    if !(t < 1<<19*math.Pi/2) {
+        return 0, doubleDouble{}, false
+    }
+    exponent := func(f float64) int { return int(math.Float64bits(f)>>52) & 0x7ff }
+    n := int(t*invHalfPi + 0.5)
+    fn := float64(n)
+    r := t - fn*pio2_1
+    w := fn * pio2_1t
+    y0 := r - w
+    if exponent(t)-exponent(y0) > 16 {
+        // lost too much to cancellation: bring in the next 33 bits
+        u := r
+        w = fn * pio2_2
+        r = u - w
+        w = fn*pio2_2t - ((u - r) - w)
+        y0 = r - w
+        if exponent(t)-exponent(y0) > 49 {
+            u = r
+            w = fn * pio2_3
+            r = u - w
+            w = fn*pio2_3t - ((u - r) - w)
+            y0 = r - w
+        }
+    }
+    y := doubleDouble{y0, (r - y0) - w}
+    if x < 0 {
+        return -n, y.neg(), true
+    }
+    return n, y, true
+}
+
+// trigSeries sums the Taylor series of sin (first = 1) or cos (first = 0)
+// at an argument of at most about pi/4
+func trigSeries(arg doubleDouble, first int) doubleDouble {
+    square := arg.mul(arg)
+    term := dd(1)
+    if first == 1 {
+        term = arg
+    }
+    sum := term
+    for k := first + 1; math.Abs(term.hi) > 1e-40; k += 2 {
+        term = term.mul(square).div(dd(-float64(k * (k + 1))))
+        sum = sum.add(term)
+    }
+    return sum
+}
+
+// sinCos is sin(x) and cos(x) together; ok is false when x is too big or
+// not finite to reduce here
+func sinCos(x float64) (sin, cos doubleDouble, ok bool) {
+    n, y, ok := reduceHalfPi(x)
+    if !ok {
+        return
+    }
+    s, c := trigSeries(y, 1), trigSeries(y, 0)
+    switch n & 3 {
+    case 0:
+        return s, c, true
+    case 1:
+        return c, s.neg(), true
+    case 2:
+        return s.neg(), c.neg(), true
+    }
+    return c.neg(), s, true
+}
+
+func sine(x float64) float64 {
+    if math.Abs(x) < 0x1p-27 {
+        return x
+    }
+    s, _, ok := sinCos(x)
+    if !ok {
+        return math.Sin(x)
+    }
+    return s.hi
+}
+
+func cosine(x float64) float64 {
+    _, c, ok := sinCos(x)
+    if !ok {
+        return math.Cos(x)
+    }
+    return c.hi
+}
+
+func tangent(x float64) float64 {
+    if math.Abs(x) < 0x1p-27 {
+        return x
+    }
+    s, c, ok := sinCos(x)
+    if !ok {
+        return math.Tan(x)
+    }
+    return s.div(c).hi
+}
+
+// arctan2 corrects Go's answer a by tan(theta - a), which is tiny enough to
+// be its own arctangent
+func arctan2(y, x float64) float64 {
+    if x == 0 || y == 0 || math.IsInf(x, 0) || math.IsInf(y, 0) || math.IsNaN(x) || math.IsNaN(y) {
+        return math.Atan2(y, x)
+    }
+    return arctanRatio(dd(y), dd(x))
+}
+
+// arctanRatio is arctan2 for a point off the origin, given to 106 bits
+func arctanRatio(y, x doubleDouble) float64 {
+    e := max(math.Ilogb(x.hi), math.Ilogb(y.hi))
+    x, y = x.scale(-e), y.scale(-e)
+    a := math.Atan2(y.hi, x.hi)
+    s, c, _ := sinCos(a)
+    num := c.mul(y).sub(s.mul(x))
+    den := c.mul(x).add(s.mul(y))
+    return dd(a).add(num.div(den)).hi
+}
+
+func arctan(x float64) float64 {
+    if math.Abs(x) < 0x1p-27 || math.IsInf(x, 0) || math.IsNaN(x) {
+        return math.Atan(x)
+    }
+    return arctan2(x, 1)
+}
+
+// The hyperbolics and arcsinh are fdlibm's, as the C library has them -
+// which is not always correctly rounded, so rounding better would print
+// different digits. Go's Expm1 and Log1p are the same fdlibm code already.
+
+func arcsinh(x float64) float64 {
+    ax := math.Abs(x)
+    var w float64
+    switch {
+    case ax < 0x1p-28 || math.IsInf(x, 0) || math.IsNaN(x):
+        return x
+    case ax > 0x1p28:
+        w = logarithm(ax) + ln2.hi
+    case ax > 2:
+        w = logarithm(2*ax + 1/(math.Sqrt(x*x+1)+ax))
+    default:
+        t := x * x
+        w = math.Log1p(ax + t/(1+math.Sqrt(1+t)))
+    }
+    return math.Copysign(w, x)
+}
+
+func hyperbolicSine(x float64) float64 {
+    ax := math.Abs(x)
+    h := math.Copysign(0.5, x)
+    switch {
+    case ax < 0x1p-28 || math.IsInf(x, 0) || math.IsNaN(x):
+        return x
+    case ax < 1:
+        t := math.Expm1(ax)
+        return h * (2*t - t*t/(t+1))
+    case ax < 22:
+        t := math.Expm1(ax)
+        return h * (t + t/(t+1))
+    case ax < 709.782712893384:
+        return h * exponential(ax)
+    }
+    // e**x overflows a little before sinh does
+    w := exponential(0.5 * ax)
+    return h * w * w
+}
+
+func hyperbolicCosine(x float64) float64 {
+    ax := math.Abs(x)
+    switch {
+    case math.IsInf(x, 0) || math.IsNaN(x):
+        return math.Cosh(x)
+    case ax < 0x1p-55:
+        return 1
+    case ax < 0.5*math.Ln2:
+        t := math.Expm1(ax)
+        w := 1 + t
+        return 1 + (t*t)/(w+w)
+    case ax < 22:
+        t := exponential(ax)
+        return 0.5*t + 0.5/t
+    case ax < 709.782712893384:
+        return 0.5 * exponential(ax)
+    }
+    w := exponential(0.5 * ax)
+    return 0.5 * w * w
+}
+
+func hyperbolicTangent(x float64) float64 {
+    ax := math.Abs(x)
+    var z float64
+    switch {
+    case math.IsNaN(x):
+        return x
+    case ax >= 22:
+        z = 1
+    case ax < 0x1p-55:
+        return x * (1 + x)
+    case ax >= 1:
+        z = 1 - 2/(math.Expm1(2*ax)+2)
+    default:
+        t := math.Expm1(-2 * ax)
+        z = -t / (t + 2)
+    }
+    return math.Copysign(z, x)
+}
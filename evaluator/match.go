@@ -0,0 +1,288 @@
+// Comments in this file are inspired by Sean Cahill - give him anything and he'll find the pattern in it
+
+package evaluator
+
+import (
+    "interpreter/parser"
+)
+
+// A match statement tries its cases in order against one subject. A pattern
+// either matches, binding its captures, or it doesn't and binds nothing.
+
+// matchSelf are the builtin classes whose single positional sub-pattern
+// matches the whole subject, like int(0)
+var matchSelf = []*Class{boolType, intType, floatType, strType, listType, tupleType, dictType, setType, frozensetType}
+
+// capture is a name a pattern binds once the whole of it has matched
+type capture struct {
+    name  string
+    value Object
+}
+
+func evalMatchStatement(node *parser.MatchStatement, env *Environment) Object {
+    subject := Eval(node.Subject, env)
+    if isError(subject) {
+        return subject
+    }
+
+    for _, matchCase := range node.Cases {
+        captures := []capture{}
+        ok, err := matchPattern(env, matchCase.Pattern, subject, &captures)
+        if err != nil {
+            return locate(err, env, matchCase.Pattern)
+        }
+        if !ok {
+            continue
+        }
+        for _, captured := range captures {
+            env.bind(captured.name, captured.value)
+        }
+        if matchCase.Guard != nil {
+            guard := Eval(matchCase.Guard, env)
+            if isError(guard) {
+                return guard
+            }
+            if ok, err := truthy(env, guard); err != nil || !ok {
+                if err != nil {
+                    return err
+                }
+                continue
+            }
+        }
+        return evalBlock(matchCase.Body, env)
+    }
+    return NULL
+}
+
+// matchPattern tells whether subject fits pattern, adding what it captures
+func matchPattern(env *Environment, pattern parser.Pattern, subject Object, captures *[]capture) (bool, *Error) {
+    switch pattern := pattern.(type) {
+    case *parser.MatchValue:
+        value := Eval(pattern.Value, env)
+        if err, ok := value.(*Error); ok {
+            return false, err
+        }
+        return equals(env, subject, value)
+
+    case *parser.MatchSingleton:
+        return subject == Eval(pattern.Value, env), nil
+
+    case *parser.MatchAs:
+        if pattern.Pattern != nil {
+            if ok, err := matchPattern(env, pattern.Pattern, subject, captures); !ok || err != nil {
+                return false, err
+            }
+        }
+        if pattern.Name != "" {
+            *captures = append(*captures, capture{pattern.Name, subject})
+        }
+        return true, nil
+
+    case *parser.MatchOr:
+        for _, alternative := range pattern.Patterns {
+            alternativeCaptures := []capture{}
+            ok, err := matchPattern(env, alternative, subject, &alternativeCaptures)
+            if err != nil {
+                return false, err
+            }
+            if ok {
+                *captures = append(*captures, alternativeCaptures...)
+                return true, nil
+            }
+        }
+        return false, nil
+
+    case *parser.MatchSequence:
+        return matchSequence(env, pattern, subject, captures)
+
+    case *parser.MatchMapping:
+        return matchMapping(env, pattern, subject, captures)
+
+    case *parser.MatchClass:
+        return matchClass(env, pattern, subject, captures)
+    }
+    return false, syntaxError("invalid pattern")
+}
+
+// matchSequence takes lists, tuples and their subclasses - but never strings,
+// however much they look like sequences
+func matchSequence(env *Environment, pattern *parser.MatchSequence, subject Object, captures *[]capture) (bool, *Error) {
+    var items []Object
+    switch value := payload(subject).(type) {
+    case *List:
+        items = append([]Object{}, value.Elements...)
+    case *Tuple:
+        items = value.Elements
+    default:
+        return false, nil
+    }
+
+    star := -1
+    for i, element := range pattern.Patterns {
+        if _, ok := element.(*parser.MatchStar); ok {
+            star = i
+        }
+    }
+    if star < 0 && len(items) != len(pattern.Patterns) || star >= 0 && len(items) < len(pattern.Patterns)-1 {
+        return false, nil
+    }
+
+    for i, element := range pattern.Patterns {
+        item := i
+        switch {
+        case i == star:
+            rest := items[star : len(items)-(len(pattern.Patterns)-1-star)]
+            if name := element.(*parser.MatchStar).Name; name != "" {
+                *captures = append(*captures, capture{name, &List{Elements: append([]Object{}, rest...)}})
+            }
+            continue
+        case star >= 0 && i > star:
+            item = len(items) - (len(pattern.Patterns) - i)
+        }
+        if ok, err := matchPattern(env, element, items[item], captures); !ok || err != nil {
+            return false, err
+        }
+    }
+    return true, nil
+}
+
+// matchMapping looks the keys up with get(), so a missing one is no match
+// rather than a KeyError
+func matchMapping(env *Environment, pattern *parser.MatchMapping, subject Object, captures *[]capture) (bool, *Error) {
+    if !typeOf(subject).isSubclass(dictType) {
+        return false, nil
+    }
+    dict := payload(subject).(*Dict)
+    if dict.Len() < len(pattern.Keys) {
+        return false, nil
+    }
+
+    seen := NewDict()
+    missing := &Instance{Class: objectType, Dict: NewDict()}
+    for i, keyNode := range pattern.Keys {
+        key := Eval(keyNode, env)
+        if err, ok := key.(*Error); ok {
+            return false, err
+        }
+        if found, err := seen.Get(env, key); err != nil || found != nil {
+            if err != nil {
+                return false, err
+            }
+            return false, valueError("mapping pattern checks duplicate key (%s)", key.Inspect())
+        }
+        if err := seen.Set(env, key, TRUE); err != nil {
+            return false, err
+        }
+
+        var value Object
+        if exact, ok := subject.(*Dict); ok {
+            found, err := exact.Get(env, key)
+            if err != nil {
+                return false, err
+            }
+            value = found
+        } else {
+            value = callAttribute(env, subject, "get", key, missing)
+            if err, ok := value.(*Error); ok {
+                return false, err
+            }
+            if value == missing {
+                value = nil
+            }
+        }
+        if value == nil {
+            return false, nil
+        }
+        if ok, err := matchPattern(env, pattern.Patterns[i], value, captures); !ok || err != nil {
+            return false, err
+        }
+    }
+
+    if pattern.Rest != "" {
+        rest := dict.Copy()
+        for _, entry := range seen.Entries() {
+            if _, err := rest.Delete(env, entry.Key); err != nil {
+                return false, err
+            }
+        }
+        *captures = append(*captures, capture{pattern.Rest, rest})
+    }
+    return true, nil
+}
+
+// matchClass is an isinstance check, then the sub-patterns against attributes.
+// Positional ones are named by the class's __match_args__.
+func matchClass(env *Environment, pattern *parser.MatchClass, subject Object, captures *[]capture) (bool, *Error) {
+    value := Eval(pattern.Class, env)
+    if err, ok := value.(*Error); ok {
+        return false, err
+    }
+    cls, ok := value.(*Class)
+    if !ok {
+        return false, typeError("called match pattern must be a type")
+    }
+    if !typeOf(subject).isSubclass(cls) {
+        return false, nil
+    }
+
+    attrs := []string{}
+    self := false
+    if matchArgs, _ := cls.lookup("__match_args__"); matchArgs != nil {
+        tuple, ok := matchArgs.(*Tuple)
+        if !ok {
+            return false, typeError("%s.__match_args__ must be a tuple (got %s)", cls.Name, typeName(matchArgs))
+        }
+        for _, arg := range tuple.Elements {
+            name, ok := arg.(*String)
+            if !ok {
+                return false, typeError("__match_args__ elements must be strings (got %s)", typeName(arg))
+            }
+            attrs = append(attrs, name.Value)
+        }
+    } else {
+        for _, builtin := range matchSelf {
+            self = self || cls.isSubclass(builtin)
+        }
+    }
+
+    allowed := len(attrs)
+    if self {
+        allowed = 1
+    }
+    if len(pattern.Patterns) > allowed {
+        plural := "s"
+        if allowed == 1 {
+            plural = ""
+        }
+        return false, typeError("%s() accepts %d positional sub-pattern%s (%d given)", cls.Name, allowed, plural, len(pattern.Patterns))
+    }
+
+    positional := pattern.Patterns
+    if self && len(positional) == 1 {
+        // int(x) matches the int itself
+        if ok, err := matchPattern(env, positional[0], subject, captures); !ok || err != nil {
+            return false, err
+        }
+        positional = nil
+    }
+    names := append(attrs[:len(positional):len(positional)], pattern.KwdAttrs...)
+    patterns := append(append([]parser.Pattern{}, positional...), pattern.KwdPatterns...)
+    for i, name := range names {
+        for _, earlier := range names[:i] {
+            if earlier == name {
+                return false, typeError("%s() got multiple sub-patterns for attribute '%s'", cls.Name, name)
+            }
+        }
+        attr := getAttribute(env, subject, name)
+        if err, ok := attr.(*Error); ok {
+            if err.matches(attributeErrorType) {
+                return false, nil
+            }
+            return false, err
+        }
+        if ok, err := matchPattern(env, patterns[i], attr, captures); !ok || err != nil {
+            return false, err
+        }
+    }
+    return true, nil
+}
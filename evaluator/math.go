@@ -0,0 +1,798 @@
+// Comments in this file are inspired by Nigel Nesbitt - Darby's man, precise to the last decimal place
+
+package evaluator
+
+import (
+    "math"
+    "math/big"
+)
+
+// math: the float functions rounded with the care libm.go takes, and the
+// integer ones done in big.Int, so no int is too big for them
+
+// mathResult is CPython's verdict on what a float function gave back for x:
+// a nan out of a number is a domain error, and an infinity out of a finite
+// number overflowed - unless the function can't overflow, so it's the domain again
+func mathResult(x, result float64, canOverflow bool) Object {
+    switch {
+    case math.IsNaN(result) && !math.IsNaN(x):
+        return valueError("math domain error")
+    case math.IsInf(result, 0) && !math.IsInf(x, 0) && !math.IsNaN(x):
+        if canOverflow {
+            return overflowError("math range error")
+        }
+        return valueError("math domain error")
+    }
+    return &Float{Value: result}
+}
+
+// gamma and lgamma are CPython's own, not the C library's: a Lanczos sum
+// with g = 6.02468, and exact factorials for the integers it can hold
+const lanczosG, lanczosGMinusHalf = 6.024680040776729583740234375, 5.524680040776729583740234375
+
+var lanczosNumerator = [...]float64{
+    23531376880.410759688572007674451636754734846804940,
+    42919803642.649098768957899047001988850926355848959,
+    35711959237.355668049440185451547166705960488635843,
+    17921034426.037209699919755754458931112671403265390,
+    6039542586.3520280050642916443072979210699388420708,
+    1439720407.3117216736632230727949123939715485786772,
+    248874557.86205415651146038641322942321632125127801,
+    31426415.585400194380614231628318205362874684987640,
+    2876370.6289353724412254090516208496135991145378768,
+    186056.26539522349504029498971604569928220784236328,
+    8071.6720023658162106380029022722506138218516325024,
+    210.82427775157934587250973392071336271166969580291,
+    2.5066282746310002701649081771338373386264310793408,
+}
+
+var lanczosDenominator = [...]float64{
+    0.0, 39916800.0, 120543840.0, 150917976.0, 105258076.0, 45995730.0,
+    13339535.0, 2637558.0, 357423.0, 32670.0, 1925.0, 66.0, 1.0,
+}
+
+func lanczosSum(x float64) float64 {
+    num, den := 0.0, 0.0
+    if x < 5 {
+        for i := len(lanczosNumerator) - 1; i >= 0; i-- {
+            num = num*x + lanczosNumerator[i]
+            den = den*x + lanczosDenominator[i]
+        }
+    } else {
+        for i := range lanczosNumerator {
+            num = num/x + lanczosNumerator[i]
+            den = den/x + lanczosDenominator[i]
+        }
+    }
+    return num / den
+}
+
+// sinPi is sin(pi*x), reduced before pi gets a chance to round
+func sinPi(x float64) float64 {
+    y := math.Mod(math.Abs(x), 2)
+    var r float64
+    switch int(math.Round(2 * y)) {
+    case 0:
+        r = sine(math.Pi * y)
+    case 1:
+        r = cosine(math.Pi * (y - 0.5))
+    case 2:
+        r = sine(math.Pi * (1 - y))
+    case 3:
+        r = -cosine(math.Pi * (y - 1.5))
+    default:
+        r = sine(math.Pi * (y - 2))
+    }
+    return math.Copysign(1, x) * r
+}
+
+// nan is the answer at gamma's poles, which mathResult calls a domain error
+func gamma(x float64) float64 {
+    switch {
+    case math.IsNaN(x) || math.IsInf(x, 1):
+        return x
+    case math.IsInf(x, -1) || x == 0 || x == math.Floor(x) && x < 0:
+        return math.NaN()
+    case x == math.Floor(x) && x <= 23:
+        exact, _ := new(big.Float).SetInt(factorial(int64(x) - 1)).Float64()
+        return exact
+    }
+    absx := math.Abs(x)
+    switch {
+    case absx < 1e-20:
+        return 1 / x
+    case absx > 200 && x < 0:
+        return 0 / sinPi(x)
+    case absx > 200:
+        return math.Inf(1)
+    }
+    y := absx + lanczosGMinusHalf
+    var z float64
+    if absx > lanczosGMinusHalf {
+        z = (y - absx) - lanczosGMinusHalf
+    } else {
+        z = (y - lanczosGMinusHalf) - absx
+    }
+    z = z * lanczosG / y
+    var r float64
+    if x < 0 {
+        r = -math.Pi / sinPi(absx) / absx * exponential(y) / lanczosSum(absx)
+        r -= z * r
+        if absx < 140 {
+            r /= power(y, absx-0.5)
+        } else {
+            root := power(y, absx/2-0.25)
+            r /= root
+            r /= root
+        }
+    } else {
+        r = lanczosSum(absx) / exponential(y)
+        r += z * r
+        if absx < 140 {
+            r *= power(y, absx-0.5)
+        } else {
+            root := power(y, absx/2-0.25)
+            r *= root
+            r *= root
+        }
+    }
+    return r
+}
+
+func logGamma(x float64) float64 {
+    switch {
+    case math.IsNaN(x):
+        return x
+    case math.IsInf(x, 0):
+        return math.Inf(1)
+    case x == math.Floor(x) && x <= 0:
+        return math.NaN()
+    case x == math.Floor(x) && x <= 2:
+        return 0
+    }
+    absx := math.Abs(x)
+    if absx < 1e-20 {
+        return -logarithm(absx)
+    }
+    r := logarithm(lanczosSum(absx)) - lanczosG
+    r += (absx - 0.5) * (logarithm(absx+lanczosG-0.5) - 1)
+    if x < 0 {
+        r = logarithm(math.Pi) - logarithm(math.Abs(sinPi(absx))) - logarithm(absx) - r
+    }
+    return r
+}
+
+// cosineOf is sqrt(1-x*x) for |x| <= 1, the other leg of asin and acos
+func cosineOf(x float64) doubleDouble {
+    return dd(1).sub(dd(x)).mul(dd(1).add(dd(x))).sqrt()
+}
+
+func arcsine(x float64) float64 {
+    switch {
+    case math.Abs(x) > 1 || math.IsNaN(x):
+        return math.NaN()
+    case math.Abs(x) < 0x1p-27:
+        return x
+    }
+    return arctanRatio(dd(x), cosineOf(x))
+}
+
+func arccosine(x float64) float64 {
+    if math.Abs(x) > 1 || math.IsNaN(x) {
+        return math.NaN()
+    }
+    return arctanRatio(cosineOf(x), dd(x))
+}
+
+// log2 is log(x)/log(2) in double-double, so exact powers come out exact
+func log2(x float64) float64 {
+    if !(x > 0) || math.IsInf(x, 0) {
+        return math.Log(x)
+    }
+    return dd(x).log().div(ln2).hi
+}
+
+// log10 is fdlibm's, as the C library has it: log(m)/ln(10) plus k*log10(2)
+// for x = m*2**k, with log10(2) in two pieces so 10**n lands on n
+func log10(x float64) float64 {
+    const (
+        ivln10    = 4.34294481903251816668e-01
+        log10_2hi = 3.01029995663611771306e-01
+        log10_2lo = 3.69423907715893078616e-13
+    )
+    if !(x > 0) || math.IsInf(x, 0) {
+        return math.Log(x)
+    }
+    m, k := math.Frexp(x)
+    if k > 0 {
+        m, k = m*2, k-1
+    }
+    y := float64(k)
+    return y*log10_2lo + ivln10*logarithm(m) + y*log10_2hi
+}
+
+// mathLog is the log functions' reading of x: ints too big for a float are
+// split into a mantissa and a power of two first, as CPython does
+func mathLog(env *Environment, obj Object, log func(float64) float64) (float64, *Error) {
+    if n, ok := toBigInt(obj); ok {
+        if n.Sign() <= 0 {
+            return 0, valueError("math domain error")
+        }
+        if f, err := intToFloat(n); err == nil {
+            return log(f), nil
+        }
+        mant := new(big.Float)
+        e := new(big.Float).SetInt(n).MantExp(mant)
+        m, _ := mant.Float64()
+        return log(m) + log(2)*float64(e), nil
+    }
+    x, err := realArgument(env, obj)
+    if err != nil {
+        return 0, err
+    }
+    result := mathResult(x, log(x), false)
+    if err, ok := result.(*Error); ok {
+        return 0, err
+    }
+    if x == 0 {
+        return 0, valueError("math domain error")
+    }
+    return result.(*Float).Value, nil
+}
+
+// vectorNorm is the length of a vector of non-negative coordinates, summed
+// in double-double once they're scaled next to 1
+func vectorNorm(coords []float64) float64 {
+    largest, sawNaN := 0.0, false
+    for _, x := range coords {
+        switch {
+        case math.IsInf(x, 0):
+            return math.Inf(1)
+        case math.IsNaN(x):
+            sawNaN = true
+        case x > largest:
+            largest = x
+        }
+    }
+    if sawNaN {
+        return math.NaN()
+    }
+    if largest == 0 || len(coords) == 1 {
+        return largest
+    }
+    if len(coords) == 2 {
+        return hypot(coords[0], coords[1])
+    }
+    e := math.Ilogb(largest)
+    sum := doubleDouble{}
+    for _, x := range coords {
+        x = math.Ldexp(x, -e)
+        sum = sum.add(dd(x).mul(dd(x)))
+    }
+    return math.Ldexp(sum.sqrt().hi, e)
+}
+
+// realArguments reads every argument as a float, for hypot and dist
+func realArguments(env *Environment, args []Object) ([]float64, *Error) {
+    values := make([]float64, len(args))
+    for i, arg := range args {
+        x, err := realArgument(env, arg)
+        if err != nil {
+            return nil, err
+        }
+        values[i] = x
+    }
+    return values, nil
+}
+
+// nonNegative reads one of comb() and perm()'s arguments
+func nonNegative(env *Environment, obj Object, name string) (*big.Int, *Error) {
+    n, err := indexValue(env, obj)
+    if err != nil {
+        return nil, err
+    }
+    if n.Sign() < 0 {
+        return nil, valueError("%s must be a non-negative integer", name)
+    }
+    return n, nil
+}
+
+// fallingFactorial is n*(n-1)*...*(n-k+1), k terms
+func fallingFactorial(n *big.Int, k int64) *big.Int {
+    if n.IsInt64() {
+        return new(big.Int).MulRange(n.Int64()-k+1, n.Int64())
+    }
+    result, term := big.NewInt(1), new(big.Int).Set(n)
+    for i := int64(0); i < k; i++ {
+        result.Mul(result, term)
+        term.Sub(term, big.NewInt(1))
+    }
+    return result
+}
+
+// factorial is n! for an n already checked to fit
+func factorial(n int64) *big.Int {
+    if n < 2 {
+        return big.NewInt(1)
+    }
+    return new(big.Int).MulRange(1, n)
+}
+
+// combinatoric reads n and k for comb() and perm(), giving back k capped at
+// n-k where the answer is symmetric - or a nil n when k > n makes it 0
+func combinatoric(env *Environment, args []Object) (n, k *big.Int, err *Error) {
+    if n, err = nonNegative(env, args[0], "n"); err != nil {
+        return nil, nil, err
+    }
+    if k, err = nonNegative(env, args[1], "k"); err != nil {
+        return nil, nil, err
+    }
+    if k.Cmp(n) > 0 {
+        return nil, nil, nil
+    }
+    return n, k, nil
+}
+
+func buildMath(m *Module) {
+    unary := func(name string, fn func(float64) float64, canOverflow bool) {
+        m.function(name, func(env *Environment, args []Object, kwargs *Dict) Object {
+            if err := checkArgs("math."+name, args, kwargs, 1, 1); err != nil {
+                return err
+            }
+            x, err := realArgument(env, args[0])
+            if err != nil {
+                return err
+            }
+            return mathResult(x, fn(x), canOverflow)
+        })
+    }
+    unary("sqrt", math.Sqrt, false)
+    unary("cbrt", math.Cbrt, false)
+    unary("exp", exponential, true)
+    unary("exp2", func(x float64) float64 { return power(2, x) }, true)
+    unary("expm1", math.Expm1, true)
+    unary("log1p", math.Log1p, false)
+    unary("fabs", math.Abs, false)
+    unary("sin", sine, false)
+    unary("cos", cosine, false)
+    unary("tan", tangent, false)
+    unary("asin", arcsine, false)
+    unary("acos", arccosine, false)
+    unary("atan", arctan, false)
+    unary("sinh", hyperbolicSine, true)
+    unary("cosh", hyperbolicCosine, true)
+    unary("tanh", hyperbolicTangent, false)
+    unary("asinh", arcsinh, false)
+    unary("acosh", math.Acosh, false)
+    unary("atanh", math.Atanh, false)
+    unary("erf", math.Erf, false)
+    unary("erfc", math.Erfc, false)
+    unary("gamma", gamma, true)
+    unary("lgamma", logGamma, true)
+    unary("degrees", func(x float64) float64 { return x * (180 / math.Pi) }, true)
+    unary("radians", func(x float64) float64 { return x * (math.Pi / 180) }, false)
+    unary("ulp", func(x float64) float64 {
+        switch {
+        case math.IsNaN(x) || math.IsInf(x, 0):
+            return math.Abs(x)
+        case x == math.MaxFloat64 || x == -math.MaxFloat64:
+            x = math.Abs(x)
+            return x - math.Nextafter(x, 0)
+        }
+        x = math.Abs(x)
+        return math.Nextafter(x, math.Inf(1)) - x
+    }, false)
+
+    predicate := func(name string, fn func(float64) bool) {
+        m.function(name, func(env *Environment, args []Object, kwargs *Dict) Object {
+            if err := checkArgs("math."+name, args, kwargs, 1, 1); err != nil {
+                return err
+            }
+            x, err := realArgument(env, args[0])
+            if err != nil {
+                return err
+            }
+            return nativeBool(fn(x))
+        })
+    }
+    predicate("isfinite", func(x float64) bool { return !math.IsInf(x, 0) && !math.IsNaN(x) })
+    predicate("isinf", func(x float64) bool { return math.IsInf(x, 0) })
+    predicate("isnan", math.IsNaN)
+
+    binary := func(name string, fn func(x, y float64) Object) {
+        m.function(name, func(env *Environment, args []Object, kwargs *Dict) Object {
+            if err := checkArgs(name, args, kwargs, 2, 2); err != nil {
+                return err
+            }
+            values, err := realArguments(env, args)
+            if err != nil {
+                return err
+            }
+            return fn(values[0], values[1])
+        })
+    }
+    finite := func(x float64) bool { return !math.IsInf(x, 0) && !math.IsNaN(x) }
+    binary("atan2", func(y, x float64) Object { return &Float{Value: arctan2(y, x)} })
+    binary("copysign", func(x, y float64) Object { return &Float{Value: math.Copysign(x, y)} })
+    binary("nextafter", func(x, y float64) Object { return &Float{Value: math.Nextafter(x, y)} })
+    binary("fmod", func(x, y float64) Object {
+        if math.IsInf(y, 0) && finite(x) {
+            return &Float{Value: x}
+        }
+        return mathResult(x, math.Mod(x, y), false)
+    })
+    binary("remainder", func(x, y float64) Object {
+        if math.IsNaN(x) || math.IsNaN(y) {
+            return &Float{Value: math.NaN()}
+        }
+        return mathResult(x, math.Remainder(x, y), false)
+    })
+    binary("pow", func(x, y float64) Object {
+        r := power(x, y)
+        if finite(x) && finite(y) {
+            switch {
+            case math.IsNaN(r):
+                return valueError("math domain error")
+            case math.IsInf(r, 0):
+                if x == 0 {
+                    return valueError("math domain error")
+                }
+                return overflowError("math range error")
+            }
+        }
+        return &Float{Value: r}
+    })
+
+    m.function("ldexp", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("ldexp", args, kwargs, 2, 2); err != nil {
+            return err
+        }
+        x, err := realArgument(env, args[0])
+        if err != nil {
+            return err
+        }
+        i, ok := toBigInt(args[1])
+        if !ok {
+            return typeError("Expected an int as second argument to ldexp.")
+        }
+        if x == 0 || !finite(x) {
+            return &Float{Value: x}
+        }
+        // past these, the answer is an overflow or a zero whatever x is
+        exp := int(max(min(i.Int64(), 1<<20), -(1 << 20)))
+        if !i.IsInt64() {
+            exp = 1 << 20 * i.Sign()
+        }
+        return mathResult(x, math.Ldexp(x, exp), true)
+    })
+    m.function("frexp", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("math.frexp", args, kwargs, 1, 1); err != nil {
+            return err
+        }
+        x, err := realArgument(env, args[0])
+        if err != nil {
+            return err
+        }
+        frac, exp := math.Frexp(x)
+        return &Tuple{Elements: []Object{&Float{Value: frac}, newInt(int64(exp))}}
+    })
+    m.function("modf", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("math.modf", args, kwargs, 1, 1); err != nil {
+            return err
+        }
+        x, err := realArgument(env, args[0])
+        if err != nil {
+            return err
+        }
+        whole, frac := math.Modf(x)
+        if math.IsInf(x, 0) {
+            frac = math.Copysign(0, x)
+        }
+        return &Tuple{Elements: []Object{&Float{Value: frac}, &Float{Value: whole}}}
+    })
+
+    // floor, ceil and trunc hand back ints, and ask the type first
+    for name, round := range map[string]func(float64) float64{"floor": math.Floor, "ceil": math.Ceil} {
+        name, round := name, round
+        m.function(name, func(env *Environment, args []Object, kwargs *Dict) Object {
+            if err := checkArgs("math."+name, args, kwargs, 1, 1); err != nil {
+                return err
+            }
+            if _, isFloat := args[0].(*Float); !isFloat {
+                if method := typeOf(args[0]).lookupName("__" + name + "__"); method != nil {
+                    return callMethod(env, method, args[0])
+                }
+            }
+            x, err := realArgument(env, args[0])
+            if err != nil {
+                return err
+            }
+            return floatToInt(round(x))
+        })
+    }
+    m.function("trunc", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("math.trunc", args, kwargs, 1, 1); err != nil {
+            return err
+        }
+        method := typeOf(args[0]).lookupName("__trunc__")
+        if method == nil {
+            return typeError("type %s doesn't define __trunc__ method", typeName(args[0]))
+        }
+        return callMethod(env, method, args[0])
+    })
+
+    m.function("log", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if kwargs.Len() > 0 {
+            return typeError("log() takes no keyword arguments")
+        }
+        if len(args) < 1 || len(args) > 2 {
+            return typeError("math.log requires 1 to 2 arguments")
+        }
+        x, err := mathLog(env, args[0], logarithm)
+        if err != nil {
+            return err
+        }
+        if len(args) == 2 {
+            base, err := mathLog(env, args[1], logarithm)
+            if err != nil {
+                return err
+            }
+            if base == 0 {
+                return newErrorKind(zeroDivisionErrorType, "float division by zero")
+            }
+            x /= base
+        }
+        return &Float{Value: x}
+    })
+    for name, log := range map[string]func(float64) float64{"log2": log2, "log10": log10} {
+        name, log := name, log
+        m.function(name, func(env *Environment, args []Object, kwargs *Dict) Object {
+            if err := checkArgs("math."+name, args, kwargs, 1, 1); err != nil {
+                return err
+            }
+            x, err := mathLog(env, args[0], log)
+            if err != nil {
+                return err
+            }
+            return &Float{Value: x}
+        })
+    }
+
+    m.function("hypot", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if kwargs.Len() > 0 {
+            return typeError("hypot() takes no keyword arguments")
+        }
+        coords, err := realArguments(env, args)
+        if err != nil {
+            return err
+        }
+        for i, x := range coords {
+            coords[i] = math.Abs(x)
+        }
+        return &Float{Value: vectorNorm(coords)}
+    })
+    m.function("dist", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("dist", args, kwargs, 2, 2); err != nil {
+            return err
+        }
+        points := [2][]float64{}
+        for i, arg := range args {
+            items, err := iterableToSlice(env, arg)
+            if err != nil {
+                return err
+            }
+            if points[i], err = realArguments(env, items); err != nil {
+                return err
+            }
+        }
+        if len(points[0]) != len(points[1]) {
+            return valueError("both points must have the same number of dimensions")
+        }
+        diffs := make([]float64, len(points[0]))
+        for i := range diffs {
+            diffs[i] = math.Abs(points[0][i] - points[1][i])
+        }
+        return &Float{Value: vectorNorm(diffs)}
+    })
+
+    m.function("fsum", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("math.fsum", args, kwargs, 1, 1); err != nil {
+            return err
+        }
+        // every double is a fraction, so the sum is exact and rounds once
+        sum, special, infinities := new(big.Rat), 0.0, 0.0
+        if err := iterate(env, args[0], func(item Object) *Error {
+            x, err := realArgument(env, item)
+            if err != nil {
+                return err
+            }
+            if !finite(x) {
+                if math.IsInf(x, 0) {
+                    infinities += x
+                }
+                special += x
+                return nil
+            }
+            sum.Add(sum, new(big.Rat).SetFloat64(x))
+            if f, _ := sum.Float64(); math.IsInf(f, 0) {
+                return overflowError("intermediate overflow in fsum")
+            }
+            return nil
+        }); err != nil {
+            return err
+        }
+        if special != 0 || math.IsNaN(special) {
+            if math.IsNaN(infinities) {
+                return valueError("-inf + inf in fsum")
+            }
+            return &Float{Value: special}
+        }
+        f, _ := sum.Float64()
+        return &Float{Value: f}
+    })
+    m.function("prod", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if len(args) != 1 {
+            return typeError("prod() takes exactly 1 positional argument (%d given)", len(args))
+        }
+        params, err := parseArgs("prod", nil, kwargs, []string{"*start"}, 0)
+        if err != nil {
+            return err
+        }
+        var product Object = newInt(1)
+        if params[0] != nil {
+            product = params[0]
+        }
+        if err := iterate(env, args[0], func(item Object) *Error {
+            product = binaryOperation(env, "*", product, item)
+            if err, ok := product.(*Error); ok {
+                return err
+            }
+            return nil
+        }); err != nil {
+            return err
+        }
+        return product
+    })
+    m.function("isclose", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if len(args) > 2 {
+            return typeError("isclose() takes exactly 2 positional arguments (%d given)", len(args))
+        }
+        params, err := parseArgs("isclose", args, kwargs, []string{"a", "b", "*rel_tol", "*abs_tol"}, 2)
+        if err != nil {
+            return err
+        }
+        tolerances := []float64{1e-09, 0}
+        for i, param := range params[2:] {
+            if param != nil {
+                if tolerances[i], err = realArgument(env, param); err != nil {
+                    return err
+                }
+            }
+        }
+        values, err := realArguments(env, params[:2])
+        if err != nil {
+            return err
+        }
+        a, b, relTol, absTol := values[0], values[1], tolerances[0], tolerances[1]
+        if relTol < 0 || absTol < 0 {
+            return valueError("tolerances must be non-negative")
+        }
+        if a == b {
+            return TRUE
+        }
+        if math.IsInf(a, 0) || math.IsInf(b, 0) {
+            return FALSE
+        }
+        diff := math.Abs(b - a)
+        return nativeBool(diff <= math.Abs(relTol*b) || diff <= math.Abs(relTol*a) || diff <= absTol)
+    })
+
+    // the integer functions
+    for _, name := range []string{"gcd", "lcm"} {
+        name := name
+        m.function(name, func(env *Environment, args []Object, kwargs *Dict) Object {
+            if kwargs.Len() > 0 {
+                return typeError("%s() takes no keyword arguments", name)
+            }
+            result := big.NewInt(0)
+            if name == "lcm" {
+                result.SetInt64(1)
+            }
+            for _, arg := range args {
+                n, err := indexValue(env, arg)
+                if err != nil {
+                    return err
+                }
+                n = new(big.Int).Abs(n)
+                switch {
+                case name == "gcd":
+                    result.GCD(nil, nil, result, n)
+                case n.Sign() == 0 || result.Sign() == 0:
+                    result.SetInt64(0)
+                default:
+                    g := new(big.Int).GCD(nil, nil, result, n)
+                    result.Mul(result.Quo(result, g), n)
+                }
+            }
+            return newBigInt(result)
+        })
+    }
+    m.function("isqrt", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("math.isqrt", args, kwargs, 1, 1); err != nil {
+            return err
+        }
+        n, err := indexValue(env, args[0])
+        if err != nil {
+            return err
+        }
+        if n.Sign() < 0 {
+            return valueError("isqrt() argument must be nonnegative")
+        }
+        return newBigInt(new(big.Int).Sqrt(n))
+    })
+    m.function("factorial", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("math.factorial", args, kwargs, 1, 1); err != nil {
+            return err
+        }
+        n, err := indexValue(env, args[0])
+        if err != nil {
+            return err
+        }
+        switch {
+        case !n.IsInt64():
+            return overflowError("factorial() argument should not exceed %d", int64(math.MaxInt64))
+        case n.Sign() < 0:
+            return valueError("factorial() not defined for negative values")
+        }
+        return newBigInt(factorial(n.Int64()))
+    })
+    m.function("comb", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("comb", args, kwargs, 2, 2); err != nil {
+            return err
+        }
+        n, k, err := combinatoric(env, args)
+        if err != nil {
+            return err
+        }
+        if n == nil {
+            return newInt(0)
+        }
+        if rest := new(big.Int).Sub(n, k); rest.Cmp(k) < 0 {
+            k = rest
+        }
+        if !k.IsInt64() {
+            return overflowError("min(n - k, k) must not exceed %d", int64(math.MaxInt64))
+        }
+        result := fallingFactorial(n, k.Int64())
+        return newBigInt(result.Quo(result, factorial(k.Int64())))
+    })
+    m.function("perm", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("perm", args, kwargs, 1, 2); err != nil {
+            return err
+        }
+        if len(args) == 1 || args[1] == NULL {
+            args = []Object{args[0], args[0]}
+        }
+        n, k, err := combinatoric(env, args)
+        if err != nil {
+            return err
+        }
+        if n == nil {
+            return newInt(0)
+        }
+        if !k.IsInt64() {
+            return overflowError("k must not exceed %d", int64(math.MaxInt64))
+        }
+        return newBigInt(fallingFactorial(n, k.Int64()))
+    })
+
+    m.Env.Set("pi", &Float{Value: math.Pi})
+    m.Env.Set("e", &Float{Value: math.E})
+    m.Env.Set("tau", &Float{Value: 2 * math.Pi})
+    m.Env.Set("inf", &Float{Value: math.Inf(1)})
+    m.Env.Set("nan", &Float{Value: math.NaN()})
+}
+
+func init() {
+    registerModule("math", buildMath)
+}
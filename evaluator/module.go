@@ -0,0 +1,465 @@
+// Comments in this file are inspired by Dana Scott (Scottie) - she ran the London office, a firm of her own
+
+package evaluator
+
+import (
+    "fmt"
+    "interpreter/lexer"
+    "interpreter/parser"
+    "os"
+    "path/filepath"
+    "strings"
+)
+
+// Module is a namespace of its own, with globals nobody else can see
+type Module struct {
+    Name string
+    File string // empty for the ones built into the interpreter
+    Env  *Environment
+
+    initializing bool // its code is still running - someone imported it back halfway
+}
+
+func (m *Module) Type() ObjectType { return MODULE_OBJ }
+func (m *Module) Inspect() string {
+    if m.File == "" {
+        return fmt.Sprintf("<module '%s' (built-in)>", m.Name)
+    }
+    return fmt.Sprintf("<module '%s' from '%s'>", m.Name, m.File)
+}
+
+// stdoutWriter looks os.Stdout up on every write, so swapping it - as tests do - works
+type stdoutWriter struct{}
+
+func (stdoutWriter) Write(p []byte) (int, error) { return os.Stdout.Write(p) }
+
+// stderrWriter is the same for os.Stderr
+type stderrWriter struct{}
+
+func (stderrWriter) Write(p []byte) (int, error) { return os.Stderr.Write(p) }
+
+// stdinReader is their twin on the way in, for input()
+type stdinReader struct{}
+
+func (stdinReader) Read(p []byte) (int, error) { return os.Stdin.Read(p) }
+
+var moduleType = newBuiltinClass("module", objectType)
+
+// nativeModules are the modules written in Go, by name; each builds its contents
+var nativeModules = map[string]func(m *Module){}
+
+func registerModule(name string, build func(m *Module)) {
+    nativeModules[name] = build
+}
+
+// newModule opens a module sharing the interpreter that env runs in
+func newModule(env *Environment, name string, file string) *Module {
+    filename := file
+    if filename == "" {
+        filename = "<" + name + ">"
+    }
+    m := &Module{
+        Name: name,
+        File: file,
+        Env: &Environment{
+            store:  make(map[string]Object),
+            interp: env.interp,
+            frame:  &Frame{Name: "<module>", Filename: filename},
+        },
+    }
+    m.Env.Set("__name__", &String{Value: name})
+    m.Env.Set("__doc__", NULL)
+    return m
+}
+
+// loadNativeModule builds a Go module the first time it is asked for
+func loadNativeModule(env *Environment, name string) (*Module, bool) {
+    if m, ok := env.interp.modules.GetStr(name); ok {
+        if m, ok := m.(*Module); ok {
+            return m, true
+        }
+    }
+    build, ok := nativeModules[name]
+    if !ok {
+        return nil, false
+    }
+    m := newModule(env, name, "")
+    env.interp.modules.SetStr(name, m)
+    build(m)
+    return m, true
+}
+
+// function adds a Go function to the module
+func (m *Module) function(name string, fn BuiltinFunction) {
+    m.Env.Set(name, &Builtin{Name: name, Fn: fn})
+}
+
+// class makes a class that says it lives in this module, the way a class
+// statement in its source would. Python code can still change it: each
+// interpreter builds its own modules, so nobody else sees the change.
+func (m *Module) class(name string, bases ...*Class) *Class {
+    if len(bases) == 0 {
+        bases = []*Class{objectType}
+    }
+    cls := newBuiltinClass(name, bases...)
+    cls.native = false
+    cls.Dict.SetStr("__module__", &String{Value: m.Name})
+    m.Env.Set(name, cls)
+    return cls
+}
+
+func moduleAttribute(env *Environment, m *Module, name string) Object {
+    if value, ok := m.Env.store[name]; ok {
+        return value
+    }
+    switch name {
+    case "__class__":
+        return moduleType
+    case "__dict__":
+        return m.Env.namespaceDict()
+    case "__file__":
+        if m.File != "" {
+            return &String{Value: m.File}
+        }
+    }
+    if attr := moduleType.lookupName(name); attr != nil {
+        return descriptorGet(env, attr, m, moduleType)
+    }
+    if getattr, ok := m.Env.store["__getattr__"]; ok {
+        return applyFunction(env, getattr, []Object{&String{Value: name}}, nil)
+    }
+    if m.initializing {
+        return attributeError("partially initialized module '%s' has no attribute '%s' (most likely due to a circular import)", m.Name, name)
+    }
+    return attributeError("module '%s' has no attribute '%s'", m.Name, name)
+}
+
+// The import system, after importlib: sys.modules first, then the modules
+// written in Go, then a package or a .py file on the search path.
+
+// importError is ImportError - or a subclass - with its name and path filled in
+func importError(cls *Class, name, path string, format string, a ...interface{}) *Error {
+    err := newErrorKind(cls, format, a...)
+    err.Exception.Fields["msg"] = err.Exception.Args.Elements[0]
+    if name != "" {
+        err.Exception.Fields["name"] = &String{Value: name}
+    }
+    if path != "" {
+        err.Exception.Fields["path"] = &String{Value: path}
+    }
+    return err
+}
+
+// resolveName turns a relative module name into an absolute one, going level
+// packages up from the one env's code lives in
+func resolveName(env *Environment, name string, level int) (string, *Error) {
+    if level == 0 {
+        if name == "" {
+            return "", valueError("Empty module name")
+        }
+        return name, nil
+    }
+    pkg := packageName(env.globals())
+    if pkg == "" {
+        return "", importError(importErrorType, "", "", "attempted relative import with no known parent package")
+    }
+    bits := strings.Split(pkg, ".")
+    if len(bits) < level {
+        return "", importError(importErrorType, "", "", "attempted relative import beyond top-level package")
+    }
+    base := strings.Join(bits[:len(bits)-level+1], ".")
+    if name == "" {
+        return base, nil
+    }
+    return base + "." + name, nil
+}
+
+// packageName is the package a module's relative imports start from
+func packageName(globals *Environment) string {
+    if pkg, ok := globals.store["__package__"].(*String); ok {
+        return pkg.Value
+    }
+    name, _ := globals.store["__name__"].(*String)
+    if name == nil {
+        return ""
+    }
+    if _, isPackage := globals.store["__path__"]; isPackage {
+        return name.Value
+    }
+    if i := strings.LastIndex(name.Value, "."); i >= 0 {
+        return name.Value[:i]
+    }
+    return ""
+}
+
+// importModule loads the module called fullname, and its parent packages
+// before it, unless sys.modules already has it
+func importModule(env *Environment, fullname string) (Object, *Error) {
+    modules := env.interp.modules
+    if m, ok := modules.GetStr(fullname); ok {
+        if m == NULL {
+            return nil, importError(moduleNotFoundErrorType, fullname, "", "import of %s halted; None in sys.modules", fullname)
+        }
+        return m, nil
+    }
+
+    var parent Object
+    dirs := env.interp.path.Elements
+    name := fullname
+    if i := strings.LastIndex(fullname, "."); i >= 0 {
+        parentName := fullname[:i]
+        var err *Error
+        if parent, err = importModule(env, parentName); err != nil {
+            return nil, err
+        }
+        // Running the parent's __init__ may have imported this one too
+        if m, ok := modules.GetStr(fullname); ok {
+            return m, nil
+        }
+        path, ok := getAttribute(env, parent, "__path__").(*List)
+        if !ok {
+            return nil, importError(moduleNotFoundErrorType, fullname, "",
+                "No module named '%s'; '%s' is not a package", fullname, parentName)
+        }
+        dirs, name = path.Elements, fullname[i+1:]
+    } else if m, ok := loadNativeModule(env, fullname); ok {
+        return m, nil
+    }
+
+    file, isPackage := findModule(dirs, name)
+    if file == "" {
+        return nil, importError(moduleNotFoundErrorType, fullname, "", "No module named '%s'", fullname)
+    }
+    m, err := execModule(env, fullname, file, isPackage)
+    if err != nil {
+        return nil, err
+    }
+    if parent != nil {
+        if err := setAttribute(env, parent, name, m); err != nil {
+            return nil, err
+        }
+    }
+    return m, nil
+}
+
+// findModule looks through dirs for a package directory or a .py file called name
+func findModule(dirs []Object, name string) (file string, isPackage bool) {
+    for _, dir := range dirs {
+        d, ok := dir.(*String)
+        if !ok {
+            continue
+        }
+        base := filepath.Join(d.Value, name)
+        if info, err := os.Stat(filepath.Join(base, "__init__.py")); err == nil && !info.IsDir() {
+            return filepath.Join(base, "__init__.py"), true
+        }
+        if info, err := os.Stat(base + ".py"); err == nil && !info.IsDir() {
+            return base + ".py", false
+        }
+    }
+    return "", false
+}
+
+// execModule runs a module's file in a namespace of its own. It goes into
+// sys.modules before its code runs, so an import cycle finds it half done
+// rather than starting it all over again.
+func execModule(env *Environment, fullname, file string, isPackage bool) (Object, *Error) {
+    source, readErr := os.ReadFile(file)
+    if readErr != nil {
+        return nil, importError(importErrorType, fullname, file, "%s", readErr.Error())
+    }
+    if path, err := filepath.Abs(file); err == nil {
+        file = path
+    }
+    env.RegisterSource(file, string(source))
+    p := parser.New(lexer.New(string(source)))
+    program := p.ParseProgram()
+    if len(p.Errors()) != 0 {
+        return nil, ParseError(p, file, string(source))
+    }
+
+    m := newModule(env, fullname, file)
+    m.Env.Set("__file__", &String{Value: file})
+    if isPackage {
+        m.Env.Set("__package__", &String{Value: fullname})
+        m.Env.Set("__path__", &List{Elements: []Object{&String{Value: filepath.Dir(file)}}})
+    } else {
+        pkg := ""
+        if i := strings.LastIndex(fullname, "."); i >= 0 {
+            pkg = fullname[:i]
+        }
+        m.Env.Set("__package__", &String{Value: pkg})
+    }
+
+    modules := env.interp.modules
+    modules.SetStr(fullname, m)
+    m.initializing = true
+    result := Eval(program, m.Env)
+    m.initializing = false
+    if err, ok := result.(*Error); ok {
+        modules.Delete(nil, &String{Value: fullname})
+        err.traced = false // the import statement's line goes on next
+        return nil, err
+    }
+    // A module may put something else in sys.modules in its place
+    if replaced, ok := modules.GetStr(fullname); ok {
+        return replaced, nil
+    }
+    return m, nil
+}
+
+// importFrom is the `from module import name` lookup, which falls back on
+// sys.modules for a submodule that isn't an attribute yet
+func importFrom(env *Environment, module Object, name string) Object {
+    value := getAttribute(env, module, name)
+    err, ok := value.(*Error)
+    if !ok || !err.matches(attributeErrorType) {
+        return value
+    }
+    moduleName, file := "<unknown module name>", ""
+    m, isModule := module.(*Module)
+    if isModule {
+        moduleName, file = m.Name, m.File
+    }
+    if sub, ok := env.interp.modules.GetStr(moduleName + "." + name); ok {
+        return sub
+    }
+    location := "unknown location"
+    if file != "" {
+        location = file
+    }
+    if isModule && m.initializing {
+        return importError(importErrorType, moduleName, file,
+            "cannot import name '%s' from partially initialized module '%s' (most likely due to a circular import) (%s)",
+            name, moduleName, location)
+    }
+    return importError(importErrorType, moduleName, file, "cannot import name '%s' from '%s' (%s)", name, moduleName, location)
+}
+
+// importSubmodules is importlib's _handle_fromlist: the names a package
+// doesn't have yet may be submodules waiting to be loaded
+func importSubmodules(env *Environment, module Object, fullname string, names []string) *Error {
+    if _, ok := getAttribute(env, module, "__path__").(*List); !ok {
+        return nil
+    }
+    for _, name := range names {
+        if name == "*" || !isError(getAttribute(env, module, name)) {
+            continue
+        }
+        sub := fullname + "." + name
+        if _, err := importModule(env, sub); err != nil {
+            if missing, ok := err.Exception.Fields["name"].(*String); ok && err.matches(moduleNotFoundErrorType) && missing.Value == sub {
+                continue
+            }
+            return err
+        }
+    }
+    return nil
+}
+
+// publicNames is what `from module import *` binds: __all__, or every
+// global not starting with an underscore
+func publicNames(env *Environment, module Object) ([]string, *Error) {
+    all := getAttribute(env, module, "__all__")
+    if err, ok := all.(*Error); ok {
+        if !err.matches(attributeErrorType) {
+            return nil, err
+        }
+        m, ok := module.(*Module)
+        if !ok {
+            return nil, nil
+        }
+        names := []string{}
+        for _, name := range m.Env.Names() {
+            if !strings.HasPrefix(name, "_") {
+                names = append(names, name)
+            }
+        }
+        return names, nil
+    }
+    var names []string
+    err := iterate(env, all, func(item Object) *Error {
+        name, ok := item.(*String)
+        if !ok {
+            return typeError("Item in %s.__all__ must be str, not %s", moduleNameOf(module), typeName(item))
+        }
+        names = append(names, name.Value)
+        return nil
+    })
+    return names, err
+}
+
+func moduleNameOf(module Object) string {
+    if m, ok := module.(*Module); ok {
+        return m.Name
+    }
+    return "module"
+}
+
+func init() {
+    registerBuiltin("__import__", func(env *Environment, args []Object, kwargs *Dict) Object {
+        values, err := parseArgs("__import__", args, kwargs, []string{"name", "globals", "locals", "fromlist", "level"}, 1)
+        if err != nil {
+            return err
+        }
+        name, ok := values[0].(*String)
+        if !ok {
+            return typeError("module name must be str, not %s", typeName(values[0]))
+        }
+        level := 0
+        if values[4] != nil {
+            if level, err = toIndex(env, values[4]); err != nil {
+                return err
+            }
+            if level < 0 {
+                return valueError("level must be >= 0")
+            }
+        }
+        fullname, err := resolveName(env, name.Value, level)
+        if err != nil {
+            return err
+        }
+        module, err := importModule(env, fullname)
+        if err != nil {
+            return err
+        }
+
+        var fromlist []string
+        if values[3] != nil && values[3] != NULL {
+            if err := iterate(env, values[3], func(item Object) *Error {
+                s, ok := item.(*String)
+                if !ok {
+                    return typeError("Item in from list must be str, not %s", typeName(item))
+                }
+                fromlist = append(fromlist, s.Value)
+                return nil
+            }); err != nil {
+                return err
+            }
+        }
+        if len(fromlist) > 0 {
+            if err := importSubmodules(env, module, fullname, fromlist); err != nil {
+                return err
+            }
+            return module
+        }
+        // Without a fromlist the caller wants the package it names first
+        first := strings.SplitN(name.Value, ".", 2)[0]
+        top, _ := env.interp.modules.GetStr(fullname[:len(fullname)-len(name.Value)+len(first)])
+        if top == nil {
+            return module
+        }
+        return top
+    })
+
+    moduleType.method("__repr__", 0, 0, func(env *Environment, args []Object) Object {
+        return &String{Value: args[0].Inspect()}
+    })
+    moduleType.method("__dir__", 0, 0, func(env *Environment, args []Object) Object {
+        m := args[0].(*Module)
+        if dir, ok := m.Env.store["__dir__"]; ok {
+            return applyFunction(env, dir, nil, nil)
+        }
+        return stringList(append([]string{}, m.Env.names...))
+    })
+}
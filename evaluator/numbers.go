@@ -0,0 +1,1048 @@
+// Comments in this file are inspired by Louis Litt - nobody counts money like Louis
+
+package evaluator
+
+import (
+    "math"
+    "math/big"
+    "strconv"
+    "strings"
+)
+
+// Small ints are shared, the way CPython shares -5..256
+var smallInts [262]*Integer
+
+func init() {
+    for i := range smallInts {
+        smallInts[i] = &Integer{Value: big.NewInt(int64(i) - 5)}
+    }
+}
+
+func newInt(value int64) *Integer {
+    if value >= -5 && value <= 256 {
+        return smallInts[value+5]
+    }
+    return &Integer{Value: big.NewInt(value)}
+}
+
+// newBigInt wraps a result. The big.Int must never be mutated afterwards.
+func newBigInt(value *big.Int) *Integer {
+    if value.IsInt64() {
+        if v := value.Int64(); v >= -5 && v <= 256 {
+            return smallInts[v+5]
+        }
+    }
+    return &Integer{Value: value}
+}
+
+// toBigInt accepts ints, bools and instances of int subclasses
+func toBigInt(obj Object) (*big.Int, bool) {
+    switch obj := payload(obj).(type) {
+    case *Integer:
+        return obj.Value, true
+    case *Boolean:
+        if obj.Value {
+            return big.NewInt(1), true
+        }
+        return big.NewInt(0), true
+    }
+    return nil, false
+}
+
+// toFloat accepts anything float arithmetic will take. Ints too big for a
+// float are an OverflowError, not a silent infinity.
+func toFloat(obj Object) (float64, bool, *Error) {
+    if f, ok := payload(obj).(*Float); ok {
+        return f.Value, true, nil
+    }
+    n, ok := toBigInt(obj)
+    if !ok {
+        return 0, false, nil
+    }
+    f, err := intToFloat(n)
+    return f, true, err
+}
+
+func intToFloat(n *big.Int) (float64, *Error) {
+    if n.IsInt64() && n.Int64() <= 1<<53 && n.Int64() >= -(1<<53) {
+        return float64(n.Int64()), nil
+    }
+    f, _ := new(big.Float).SetInt(n).Float64()
+    if math.IsInf(f, 0) {
+        return 0, overflowError("int too large to convert to float")
+    }
+    return f, nil
+}
+
+// floorDivMod is Python's // and %: the remainder takes the divisor's sign
+func floorDivMod(a, b *big.Int) (*big.Int, *big.Int) {
+    q, r := new(big.Int).QuoRem(a, b, new(big.Int))
+    if r.Sign() != 0 && r.Sign() != b.Sign() {
+        q.Sub(q, big.NewInt(1))
+        r.Add(r, b)
+    }
+    return q, r
+}
+
+// trueDivide is int / int, correctly rounded even when the ints are huge
+func trueDivide(a, b *big.Int) Object {
+    if b.Sign() == 0 {
+        return zeroDivisionError("division by zero")
+    }
+    const exact = 1 << 53
+    if a.IsInt64() && b.IsInt64() && a.CmpAbs(big.NewInt(exact)) <= 0 && b.CmpAbs(big.NewInt(exact)) <= 0 {
+        return &Float{Value: float64(a.Int64()) / float64(b.Int64())}
+    }
+    f, _ := new(big.Rat).SetFrac(a, b).Float64()
+    if math.IsInf(f, 0) {
+        return overflowError("integer division result too large for a float")
+    }
+    return &Float{Value: f}
+}
+
+func intPow(env *Environment, base, exp *big.Int, mod Object) Object {
+    if mod != nil && mod != NULL {
+        m, ok := toBigInt(mod)
+        if !ok {
+            return NotImplemented
+        }
+        if m.Sign() == 0 {
+            return valueError("pow() 3rd argument cannot be 0")
+        }
+        abs := new(big.Int).Abs(m)
+        b := new(big.Int).Mod(base, abs)
+        e := exp
+        if exp.Sign() < 0 {
+            b = new(big.Int).ModInverse(b, abs)
+            if b == nil {
+                return valueError("base is not invertible for the given modulus")
+            }
+            e = new(big.Int).Neg(exp)
+        }
+        result := new(big.Int).Exp(b, e, abs)
+        if m.Sign() < 0 && result.Sign() != 0 {
+            result.Add(result, m)
+        }
+        return newBigInt(result)
+    }
+    if exp.Sign() < 0 {
+        x, err := intToFloat(base)
+        if err != nil {
+            return err
+        }
+        y, err := intToFloat(exp)
+        if err != nil {
+            return err
+        }
+        return floatPow(x, y)
+    }
+    return newBigInt(new(big.Int).Exp(base, exp, nil))
+}
+
+// intArithmetic is the native side of every int binary operator
+func intArithmetic(env *Environment, operator string, a, b *big.Int) Object {
+    switch operator {
+    case "+":
+        return newBigInt(new(big.Int).Add(a, b))
+    case "-":
+        return newBigInt(new(big.Int).Sub(a, b))
+    case "*":
+        return newBigInt(new(big.Int).Mul(a, b))
+    case "/":
+        return trueDivide(a, b)
+    case "//":
+        if b.Sign() == 0 {
+            return zeroDivisionError("integer division or modulo by zero")
+        }
+        q, _ := floorDivMod(a, b)
+        return newBigInt(q)
+    case "%":
+        if b.Sign() == 0 {
+            return zeroDivisionError("integer modulo by zero")
+        }
+        _, r := floorDivMod(a, b)
+        return newBigInt(r)
+    case "**":
+        return intPow(env, a, b, nil)
+    case "&":
+        return newBigInt(new(big.Int).And(a, b))
+    case "|":
+        return newBigInt(new(big.Int).Or(a, b))
+    case "^":
+        return newBigInt(new(big.Int).Xor(a, b))
+    case "<<", ">>":
+        if b.Sign() < 0 {
+            return valueError("negative shift count")
+        }
+        if !b.IsInt64() || b.Int64() > math.MaxInt32 {
+            if operator == ">>" {
+                if a.Sign() < 0 {
+                    return newInt(-1)
+                }
+                return newInt(0)
+            }
+            if a.Sign() == 0 {
+                return newInt(0)
+            }
+            return overflowError("too many digits in integer")
+        }
+        if operator == "<<" {
+            return newBigInt(new(big.Int).Lsh(a, uint(b.Int64())))
+        }
+        return newBigInt(new(big.Int).Rsh(a, uint(b.Int64())))
+    }
+    return NotImplemented
+}
+
+// floatArithmetic is the native side of every float binary operator
+func floatArithmetic(operator string, a, b float64) Object {
+    switch operator {
+    case "+":
+        return &Float{Value: a + b}
+    case "-":
+        return &Float{Value: a - b}
+    case "*":
+        return &Float{Value: a * b}
+    case "/":
+        if b == 0 {
+            return zeroDivisionError("float division by zero")
+        }
+        return &Float{Value: a / b}
+    case "//":
+        if b == 0 {
+            return zeroDivisionError("float floor division by zero")
+        }
+        div, _ := floatDivMod(a, b)
+        return &Float{Value: div}
+    case "%":
+        if b == 0 {
+            return zeroDivisionError("float modulo by zero")
+        }
+        _, mod := floatDivMod(a, b)
+        return &Float{Value: mod}
+    case "**":
+        return floatPow(a, b)
+    }
+    return NotImplemented
+}
+
+// floatDivMod follows CPython's float_divmod to the letter
+// roundHalfEven is the integer nearest r, ties going to the even one
+func roundHalfEven(r *big.Rat) *big.Int {
+    q, m := floorDivMod(r.Num(), r.Denom())
+    switch twice := new(big.Int).Lsh(m, 1); twice.Cmp(r.Denom()) {
+    case 1:
+        q.Add(q, big.NewInt(1))
+    case 0:
+        if q.Bit(0) == 1 {
+            q.Add(q, big.NewInt(1))
+        }
+    }
+    return q
+}
+
+// floatRound is round(x, ndigits): the decimal nearest x's exact binary
+// value, ties to even, read back as the nearest float - what CPython gets
+// out of dtoa
+func floatRound(x float64, ndigits *big.Int) Object {
+    const most, least = 323, -308 // past these, every float rounds to itself or to zero
+    switch {
+    case math.IsInf(x, 0) || math.IsNaN(x) || x == 0 || ndigits.Cmp(big.NewInt(most)) > 0:
+        return &Float{Value: x}
+    case ndigits.Cmp(big.NewInt(least)) < 0:
+        return &Float{Value: math.Copysign(0, x)}
+    }
+    n := ndigits.Int64()
+    scale := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(max(n, -n)), nil))
+    if n < 0 {
+        scale.Inv(scale)
+    }
+    exact := new(big.Rat).Mul(new(big.Rat).SetFloat64(x), scale)
+    rounded := new(big.Rat).SetInt(roundHalfEven(exact))
+    f, _ := rounded.Quo(rounded, scale).Float64()
+    if math.IsInf(f, 0) {
+        return overflowError("rounded value too large to represent")
+    }
+    if f == 0 {
+        f = math.Copysign(0, x)
+    }
+    return &Float{Value: f}
+}
+
+func floatDivMod(a, b float64) (float64, float64) {
+    mod := math.Mod(a, b)
+    div := (a - mod) / b
+    if mod != 0 {
+        if (b < 0) != (mod < 0) {
+            mod += b
+            div -= 1.0
+        }
+    } else {
+        mod = math.Copysign(0, b)
+    }
+    if div != 0 {
+        floor := math.Floor(div)
+        if div-floor > 0.5 {
+            floor += 1.0
+        }
+        div = floor
+    } else {
+        div = math.Copysign(0, a/b)
+    }
+    return div, mod
+}
+
+func floatPow(a, b float64) Object {
+    if a == 0 && b < 0 {
+        return zeroDivisionError("0.0 cannot be raised to a negative power")
+    }
+    if a < 0 && b != math.Trunc(b) && !math.IsInf(b, 0) {
+        // a negative number to a fractional power leaves the real line
+        return complexPow(complex(a, 0), complex(b, 0))
+    }
+    result := power(a, b)
+    if math.IsInf(result, 0) && !math.IsInf(a, 0) && !math.IsInf(b, 0) {
+        return overflowError("(34, 'Numerical result out of range')")
+    }
+    return &Float{Value: result}
+}
+
+// fastBinaryOperation skips method lookup when both sides are plain numbers.
+// nil means "go through the dunders".
+func fastBinaryOperation(operator string, left, right Object) Object {
+    switch l := left.(type) {
+    case *Integer:
+        switch r := right.(type) {
+        case *Integer:
+            return intArithmetic(nil, operator, l.Value, r.Value)
+        }
+    case *Float:
+        switch r := right.(type) {
+        case *Float:
+            if operator == "+" || operator == "-" || operator == "*" || operator == "/" ||
+                operator == "//" || operator == "%" || operator == "**" {
+                return floatArithmetic(operator, l.Value, r.Value)
+            }
+        }
+    }
+    return nil
+}
+
+// fastCompare does the same for comparisons of like builtins
+func fastCompare(operator string, left, right Object) Object {
+    switch l := left.(type) {
+    case *Integer:
+        if r, ok := right.(*Integer); ok {
+            return compareResult(operator, l.Value.Cmp(r.Value))
+        }
+    case *Float:
+        if r, ok := right.(*Float); ok {
+            return compareFloats(operator, l.Value, r.Value)
+        }
+    case *String:
+        if r, ok := right.(*String); ok {
+            return compareResult(operator, strings.Compare(l.Value, r.Value))
+        }
+    }
+    return nil
+}
+
+// compareResult turns a three-way comparison into the operator's answer
+func compareResult(operator string, cmp int) *Boolean {
+    switch operator {
+    case "==":
+        return nativeBool(cmp == 0)
+    case "!=":
+        return nativeBool(cmp != 0)
+    case "<":
+        return nativeBool(cmp < 0)
+    case "<=":
+        return nativeBool(cmp <= 0)
+    case ">":
+        return nativeBool(cmp > 0)
+    }
+    return nativeBool(cmp >= 0)
+}
+
+func compareFloats(operator string, a, b float64) *Boolean {
+    if math.IsNaN(a) || math.IsNaN(b) {
+        return nativeBool(operator == "!=")
+    }
+    switch {
+    case a < b:
+        return compareResult(operator, -1)
+    case a > b:
+        return compareResult(operator, 1)
+    }
+    return compareResult(operator, 0)
+}
+
+// compareFloatInt compares exactly - no rounding the int into a float first
+func compareFloatInt(operator string, f float64, n *big.Int) *Boolean {
+    if math.IsNaN(f) {
+        return nativeBool(operator == "!=")
+    }
+    return compareResult(operator, big.NewFloat(f).Cmp(new(big.Float).SetInt(n)))
+}
+
+const hashModulus = (1 << 61) - 1
+
+// hashBigInt reduces an int modulo 2**61 - 1, so equal numbers hash alike
+func hashBigInt(n *big.Int) int64 {
+    if n.IsInt64() {
+        v := n.Int64()
+        if v >= 0 && v < hashModulus {
+            return v
+        }
+        if v < 0 && v > -hashModulus {
+            if v == -1 {
+                return -2
+            }
+            return v
+        }
+    }
+    h := new(big.Int).Mod(new(big.Int).Abs(n), big.NewInt(hashModulus)).Int64()
+    if n.Sign() < 0 {
+        h = -h
+    }
+    if h == -1 {
+        h = -2
+    }
+    return h
+}
+
+// hashFloat is CPython's _Py_HashDouble
+func hashFloat(f float64) int64 {
+    switch {
+    case math.IsInf(f, 1):
+        return 314159
+    case math.IsInf(f, -1):
+        return -314159
+    case math.IsNaN(f):
+        return 0
+    }
+
+    sign := int64(1)
+    if f < 0 {
+        sign = -1
+        f = -f
+    }
+    m, e := math.Frexp(f)
+    x := uint64(0)
+    for m != 0 {
+        x = ((x << 28) & hashModulus) | x>>(61-28)
+        m *= 268435456.0
+        e -= 28
+        y := uint64(m)
+        m -= float64(y)
+        x += y
+        if x >= hashModulus {
+            x -= hashModulus
+        }
+    }
+    if e >= 0 {
+        e %= 61
+    } else {
+        e = 61 - 1 - ((-1 - e) % 61)
+    }
+    x = ((x << uint(e)) & hashModulus) | x>>uint(61-e)
+
+    h := int64(x) * sign
+    if h == -1 {
+        h = -2
+    }
+    return h
+}
+
+// floatRepr is repr(float): the shortest string that reads back the same
+func floatRepr(f float64) string {
+    switch {
+    case math.IsInf(f, 1):
+        return "inf"
+    case math.IsInf(f, -1):
+        return "-inf"
+    case math.IsNaN(f):
+        return "nan"
+    }
+
+    s := strconv.FormatFloat(f, 'e', -1, 64)
+    mantissa, exponent, _ := strings.Cut(s, "e")
+    exp, _ := strconv.Atoi(exponent)
+    sign := ""
+    if strings.HasPrefix(mantissa, "-") {
+        sign, mantissa = "-", mantissa[1:]
+    }
+    digits := strings.Replace(mantissa, ".", "", 1)
+
+    if exp < -4 || exp >= 16 {
+        if len(digits) > 1 {
+            mantissa = digits[:1] + "." + digits[1:]
+        } else {
+            mantissa = digits
+        }
+        expSign := "+"
+        if exp < 0 {
+            expSign, exp = "-", -exp
+        }
+        e := strconv.Itoa(exp)
+        if len(e) < 2 {
+            e = "0" + e
+        }
+        return sign + mantissa + "e" + expSign + e
+    }
+
+    point := exp + 1
+    switch {
+    case point <= 0:
+        return sign + "0." + strings.Repeat("0", -point) + digits
+    case point >= len(digits):
+        return sign + digits + strings.Repeat("0", point-len(digits)) + ".0"
+    }
+    return sign + digits[:point] + "." + digits[point:]
+}
+
+// parseIntString is int(s, base): whitespace, a sign, an optional prefix and
+// single underscores between digits
+func parseIntString(s string, base int) (*big.Int, bool) {
+    s = strings.TrimSpace(s)
+    negative := false
+    if strings.HasPrefix(s, "+") || strings.HasPrefix(s, "-") {
+        negative = s[0] == '-'
+        s = s[1:]
+    }
+
+    lower := strings.ToLower(s)
+    prefixed := false
+    for prefix, prefixBase := range map[string]int{"0x": 16, "0o": 8, "0b": 2} {
+        if strings.HasPrefix(lower, prefix) && (base == 0 || base == prefixBase) {
+            s, base, prefixed = s[2:], prefixBase, true
+            break
+        }
+    }
+    if base == 0 {
+        base = 10
+        if len(s) > 1 && s[0] == '0' && strings.Trim(s, "0_") != "" {
+            return nil, false // 010 is ambiguous, and Python refuses it
+        }
+    }
+
+    if prefixed {
+        s = strings.TrimPrefix(s, "_")
+    }
+    if s == "" || strings.HasPrefix(s, "_") || strings.HasSuffix(s, "_") || strings.Contains(s, "__") {
+        return nil, false
+    }
+    n, ok := new(big.Int).SetString(strings.ReplaceAll(s, "_", ""), base)
+    if !ok {
+        return nil, false
+    }
+    if negative {
+        n.Neg(n)
+    }
+    return n, true
+}
+
+// parseFloatString is float(s), which knows about inf and nan but not hex
+func parseFloatString(s string) (float64, bool) {
+    s = strings.TrimSpace(s)
+    body := strings.ToLower(strings.TrimLeft(s, "+-"))
+    if len(s)-len(body) > 1 {
+        return 0, false
+    }
+    switch body {
+    case "inf", "infinity":
+        return math.Inf(1 - 2*strings.Count(s[:len(s)-len(body)], "-")), true
+    case "nan":
+        return math.NaN(), true
+    }
+    if body == "" || strings.ContainsAny(body, "xpn") || strings.HasPrefix(body, "_") ||
+        strings.HasSuffix(body, "_") || strings.Contains(body, "__") {
+        return 0, false
+    }
+    for i := 0; i < len(body); i++ {
+        if body[i] == '_' && (!isDigit(body[i-1]) || !isDigit(body[i+1])) {
+            return 0, false
+        }
+    }
+    value, err := strconv.ParseFloat(strings.ReplaceAll(s, "_", ""), 64)
+    if err != nil && !math.IsInf(value, 0) {
+        return 0, false
+    }
+    return value, true
+}
+
+func isDigit(c byte) bool {
+    return '0' <= c && c <= '9'
+}
+
+// wrapBuiltinValue builds the result of a builtin type's __new__, which is an
+// instance carrying the payload when cls is a user subclass
+func wrapBuiltinValue(cls, builtin *Class, value Object) Object {
+    if cls == builtin {
+        return value
+    }
+    return &Instance{Class: cls, Dict: NewDict(), Value: value}
+}
+
+func newClassArg(name string, args []Object) (*Class, *Error) {
+    if len(args) == 0 {
+        return nil, typeError("%s.__new__(): not enough arguments", name)
+    }
+    cls, ok := args[0].(*Class)
+    if !ok {
+        return nil, typeError("%s.__new__(X): X is not a type object (%s)", name, typeName(args[0]))
+    }
+    return cls, nil
+}
+
+func init() {
+    intType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        cls, err := newClassArg("int", args)
+        if err != nil {
+            return err
+        }
+        params, err := parseArgs("int", args[1:], kwargs, []string{"x", "base"}, 0)
+        if err != nil {
+            return err
+        }
+        value := newIntValue(env, params[0], params[1])
+        if isError(value) {
+            return value
+        }
+        return wrapBuiltinValue(cls, intType, value)
+    })
+
+    intOperators := []string{"+", "-", "*", "/", "//", "%", "&", "|", "^", "<<", ">>"}
+    for _, operator := range intOperators {
+        operator := operator
+        names := binaryOperators[operator]
+        intType.method(names.method, 1, 1, func(env *Environment, args []Object) Object {
+            a, _ := toBigInt(args[0])
+            b, ok := toBigInt(args[1])
+            if !ok {
+                return NotImplemented
+            }
+            return intArithmetic(env, operator, a, b)
+        })
+        intType.method(names.reflected, 1, 1, func(env *Environment, args []Object) Object {
+            a, _ := toBigInt(args[0])
+            b, ok := toBigInt(args[1])
+            if !ok {
+                return NotImplemented
+            }
+            return intArithmetic(env, operator, b, a)
+        })
+    }
+    intType.method("__pow__", 1, 2, func(env *Environment, args []Object) Object {
+        a, _ := toBigInt(args[0])
+        b, ok := toBigInt(args[1])
+        if !ok {
+            return NotImplemented
+        }
+        var mod Object
+        if len(args) == 3 {
+            mod = args[2]
+        }
+        return intPow(env, a, b, mod)
+    })
+    intType.method("__rpow__", 1, 2, func(env *Environment, args []Object) Object {
+        a, _ := toBigInt(args[0])
+        b, ok := toBigInt(args[1])
+        if !ok {
+            return NotImplemented
+        }
+        var mod Object
+        if len(args) == 3 {
+            mod = args[2]
+        }
+        return intPow(env, b, a, mod)
+    })
+    intDivMod := func(a, b *big.Int) Object {
+        if b.Sign() == 0 {
+            return zeroDivisionError("integer division or modulo by zero")
+        }
+        q, r := floorDivMod(a, b)
+        return &Tuple{Elements: []Object{newBigInt(q), newBigInt(r)}}
+    }
+    intType.method("__divmod__", 1, 1, func(env *Environment, args []Object) Object {
+        a, _ := toBigInt(args[0])
+        b, ok := toBigInt(args[1])
+        if !ok {
+            return NotImplemented
+        }
+        return intDivMod(a, b)
+    })
+    intType.method("__rdivmod__", 1, 1, func(env *Environment, args []Object) Object {
+        a, _ := toBigInt(args[0])
+        b, ok := toBigInt(args[1])
+        if !ok {
+            return NotImplemented
+        }
+        return intDivMod(b, a)
+    })
+    intType.method("__round__", 0, 1, func(env *Environment, args []Object) Object {
+        a, _ := toBigInt(args[0])
+        if len(args) == 1 || args[1] == NULL {
+            return newBigInt(a)
+        }
+        ndigits, err := indexValue(env, args[1])
+        if err != nil {
+            return err
+        }
+        if ndigits.Sign() >= 0 {
+            return newBigInt(a)
+        }
+        scale := new(big.Int).Exp(big.NewInt(10), new(big.Int).Neg(ndigits), nil)
+        return newBigInt(new(big.Int).Mul(roundHalfEven(new(big.Rat).SetFrac(a, scale)), scale))
+    })
+    intType.method("__neg__", 0, 0, func(env *Environment, args []Object) Object {
+        a, _ := toBigInt(args[0])
+        return newBigInt(new(big.Int).Neg(a))
+    })
+    intType.method("__pos__", 0, 0, func(env *Environment, args []Object) Object {
+        a, _ := toBigInt(args[0])
+        return newBigInt(a)
+    })
+    intType.method("__abs__", 0, 0, func(env *Environment, args []Object) Object {
+        a, _ := toBigInt(args[0])
+        return newBigInt(new(big.Int).Abs(a))
+    })
+    intType.method("__invert__", 0, 0, func(env *Environment, args []Object) Object {
+        a, _ := toBigInt(args[0])
+        return newBigInt(new(big.Int).Not(a))
+    })
+    for _, operator := range []string{"==", "!=", "<", "<=", ">", ">="} {
+        operator := operator
+        intType.method(comparisonOperators[operator][0], 1, 1, func(env *Environment, args []Object) Object {
+            a, _ := toBigInt(args[0])
+            b, ok := toBigInt(args[1])
+            if !ok {
+                return NotImplemented
+            }
+            return compareResult(operator, a.Cmp(b))
+        })
+    }
+    intType.method("__hash__", 0, 0, func(env *Environment, args []Object) Object {
+        a, _ := toBigInt(args[0])
+        return newInt(hashBigInt(a))
+    })
+    intType.method("__bool__", 0, 0, func(env *Environment, args []Object) Object {
+        a, _ := toBigInt(args[0])
+        return nativeBool(a.Sign() != 0)
+    })
+    intType.method("__repr__", 0, 0, func(env *Environment, args []Object) Object {
+        a, _ := toBigInt(args[0])
+        return &String{Value: a.String()}
+    })
+    for _, name := range []string{"__int__", "__index__", "__trunc__", "__floor__", "__ceil__"} {
+        intType.method(name, 0, 0, func(env *Environment, args []Object) Object {
+            a, _ := toBigInt(args[0])
+            return newBigInt(a)
+        })
+    }
+    intType.method("__float__", 0, 0, func(env *Environment, args []Object) Object {
+        a, _ := toBigInt(args[0])
+        f, err := intToFloat(a)
+        if err != nil {
+            return err
+        }
+        return &Float{Value: f}
+    })
+    intType.method("bit_length", 0, 0, func(env *Environment, args []Object) Object {
+        a, _ := toBigInt(args[0])
+        return newInt(int64(a.BitLen()))
+    })
+
+    boolType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("bool", args[1:], kwargs, 0, 1); err != nil {
+            return err
+        }
+        if len(args) == 1 {
+            return FALSE
+        }
+        ok, err := truthy(env, args[1])
+        if err != nil {
+            return err
+        }
+        return nativeBool(ok)
+    })
+    boolType.method("__repr__", 0, 0, func(env *Environment, args []Object) Object {
+        return &String{Value: args[0].Inspect()}
+    })
+    for _, operator := range []string{"&", "|", "^"} {
+        operator := operator
+        names := binaryOperators[operator]
+        logical := func(env *Environment, args []Object) Object {
+            a, aok := args[0].(*Boolean)
+            b, bok := args[1].(*Boolean)
+            if !aok || !bok {
+                x, _ := toBigInt(args[0])
+                y, ok := toBigInt(args[1])
+                if !ok {
+                    return NotImplemented
+                }
+                return intArithmetic(env, operator, x, y)
+            }
+            switch operator {
+            case "&":
+                return nativeBool(a.Value && b.Value)
+            case "|":
+                return nativeBool(a.Value || b.Value)
+            }
+            return nativeBool(a.Value != b.Value)
+        }
+        boolType.method(names.method, 1, 1, logical)
+        boolType.method(names.reflected, 1, 1, logical)
+    }
+
+    floatType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        cls, err := newClassArg("float", args)
+        if err != nil {
+            return err
+        }
+        if err := checkArgs("float", args[1:], kwargs, 0, 1); err != nil {
+            return err
+        }
+        var value Object = &Float{Value: 0}
+        if len(args) == 2 {
+            value = newFloatValue(env, args[1])
+        }
+        if isError(value) {
+            return value
+        }
+        return wrapBuiltinValue(cls, floatType, value)
+    })
+    for _, operator := range []string{"+", "-", "*", "/", "//", "%", "**"} {
+        operator := operator
+        names := binaryOperators[operator]
+        floatType.method(names.method, 1, 1, func(env *Environment, args []Object) Object {
+            a, _, _ := toFloat(args[0])
+            b, ok, err := toFloat(args[1])
+            if !ok {
+                return NotImplemented
+            }
+            if err != nil {
+                return err
+            }
+            return floatArithmetic(operator, a, b)
+        })
+        floatType.method(names.reflected, 1, 1, func(env *Environment, args []Object) Object {
+            a, _, _ := toFloat(args[0])
+            b, ok, err := toFloat(args[1])
+            if !ok {
+                return NotImplemented
+            }
+            if err != nil {
+                return err
+            }
+            return floatArithmetic(operator, b, a)
+        })
+    }
+    for _, name := range []string{"__divmod__", "__rdivmod__"} {
+        reflected := name == "__rdivmod__"
+        floatType.method(name, 1, 1, func(env *Environment, args []Object) Object {
+            a, _, _ := toFloat(args[0])
+            b, ok, err := toFloat(args[1])
+            if !ok {
+                return NotImplemented
+            }
+            if err != nil {
+                return err
+            }
+            if reflected {
+                a, b = b, a
+            }
+            if b == 0 {
+                return zeroDivisionError("float divmod()")
+            }
+            div, mod := floatDivMod(a, b)
+            return &Tuple{Elements: []Object{&Float{Value: div}, &Float{Value: mod}}}
+        })
+    }
+    floatType.method("__round__", 0, 1, func(env *Environment, args []Object) Object {
+        a, _, _ := toFloat(args[0])
+        if len(args) == 1 || args[1] == NULL {
+            return floatToInt(math.RoundToEven(a))
+        }
+        ndigits, err := indexValue(env, args[1])
+        if err != nil {
+            return err
+        }
+        return floatRound(a, ndigits)
+    })
+    floatType.method("__neg__", 0, 0, func(env *Environment, args []Object) Object {
+        a, _, _ := toFloat(args[0])
+        return &Float{Value: -a}
+    })
+    floatType.method("__pos__", 0, 0, func(env *Environment, args []Object) Object {
+        a, _, _ := toFloat(args[0])
+        return &Float{Value: a}
+    })
+    floatType.method("__abs__", 0, 0, func(env *Environment, args []Object) Object {
+        a, _, _ := toFloat(args[0])
+        return &Float{Value: math.Abs(a)}
+    })
+    for _, operator := range []string{"==", "!=", "<", "<=", ">", ">="} {
+        operator := operator
+        floatType.method(comparisonOperators[operator][0], 1, 1, func(env *Environment, args []Object) Object {
+            a, _, _ := toFloat(args[0])
+            if n, ok := toBigInt(args[1]); ok {
+                return compareFloatInt(operator, a, n)
+            }
+            b, ok := payload(args[1]).(*Float)
+            if !ok {
+                return NotImplemented
+            }
+            return compareFloats(operator, a, b.Value)
+        })
+    }
+    floatType.method("__hash__", 0, 0, func(env *Environment, args []Object) Object {
+        a, _, _ := toFloat(args[0])
+        return newInt(hashFloat(a))
+    })
+    floatType.method("__bool__", 0, 0, func(env *Environment, args []Object) Object {
+        a, _, _ := toFloat(args[0])
+        return nativeBool(a != 0)
+    })
+    floatType.method("__repr__", 0, 0, func(env *Environment, args []Object) Object {
+        a, _, _ := toFloat(args[0])
+        return &String{Value: floatRepr(a)}
+    })
+    floatType.method("__float__", 0, 0, func(env *Environment, args []Object) Object {
+        a, _, _ := toFloat(args[0])
+        return &Float{Value: a}
+    })
+    for name, round := range map[string]func(float64) float64{
+        "__int__": math.Trunc, "__trunc__": math.Trunc, "__floor__": math.Floor, "__ceil__": math.Ceil,
+    } {
+        round := round
+        floatType.method(name, 0, 0, func(env *Environment, args []Object) Object {
+            a, _, _ := toFloat(args[0])
+            return floatToInt(round(a))
+        })
+    }
+    floatType.method("is_integer", 0, 0, func(env *Environment, args []Object) Object {
+        a, _, _ := toFloat(args[0])
+        return nativeBool(!math.IsInf(a, 0) && a == math.Trunc(a))
+    })
+}
+
+func floatToInt(f float64) Object {
+    switch {
+    case math.IsInf(f, 0):
+        return overflowError("cannot convert float infinity to integer")
+    case math.IsNaN(f):
+        return valueError("cannot convert float NaN to integer")
+    }
+    n, _ := big.NewFloat(math.Trunc(f)).Int(nil)
+    return newBigInt(n)
+}
+
+// newIntValue is the heart of int(x) and int(s, base)
+func newIntValue(env *Environment, x, base Object) Object {
+    if x == nil {
+        if base != nil {
+            return typeError("int() missing string argument")
+        }
+        return newInt(0)
+    }
+
+    if base != nil {
+        s, ok := payload(x).(*String)
+        if !ok {
+            return typeError("int() can't convert non-string with explicit base")
+        }
+        b, err := toIndex(env, base)
+        if err != nil {
+            return err
+        }
+        if b != 0 && (b < 2 || b > 36) {
+            return valueError("int() base must be >= 2 and <= 36, or 0")
+        }
+        return intFromString(s.Value, b)
+    }
+
+    switch value := payload(x).(type) {
+    case *Integer:
+        return newBigInt(value.Value)
+    case *Boolean:
+        n, _ := toBigInt(value)
+        return newBigInt(n)
+    case *String:
+        return intFromString(value.Value, 10)
+    case *Float:
+        return floatToInt(value.Value)
+    }
+
+    cls := typeOf(x)
+    for _, name := range []string{"__int__", "__index__", "__trunc__"} {
+        method := cls.lookupName(name)
+        if method == nil {
+            continue
+        }
+        result := callMethod(env, method, x)
+        if isError(result) {
+            return result
+        }
+        n, ok := toBigInt(result)
+        if !ok {
+            return typeError("%s returned non-int (type %s)", name, typeName(result))
+        }
+        return newBigInt(n)
+    }
+    return typeError("int() argument must be a string, a bytes-like object or a real number, not '%s'", cls.Name)
+}
+
+func intFromString(s string, base int) Object {
+    n, ok := parseIntString(s, base)
+    if !ok {
+        return valueError("invalid literal for int() with base %d: %s", base, strRepr(s))
+    }
+    return newBigInt(n)
+}
+
+// newFloatValue is float(x)
+func newFloatValue(env *Environment, x Object) Object {
+    switch value := payload(x).(type) {
+    case *Float:
+        return &Float{Value: value.Value}
+    case *String:
+        f, ok := parseFloatString(value.Value)
+        if !ok {
+            return valueError("could not convert string to float: %s", strRepr(value.Value))
+        }
+        return &Float{Value: f}
+    }
+    if n, ok := toBigInt(x); ok {
+        f, err := intToFloat(n)
+        if err != nil {
+            return err
+        }
+        return &Float{Value: f}
+    }
+
+    cls := typeOf(x)
+    if method := cls.lookupName("__float__"); method != nil {
+        result := callMethod(env, method, x)
+        if isError(result) {
+            return result
+        }
+        f, ok := payload(result).(*Float)
+        if !ok {
+            return typeError("%s.__float__ returned non-float (type %s)", cls.Name, typeName(result))
+        }
+        return &Float{Value: f.Value}
+    }
+    if cls.lookupName("__index__") != nil {
+        n, err := indexValue(env, x)
+        if err != nil {
+            return err
+        }
+        f, ferr := intToFloat(n)
+        if ferr != nil {
+            return ferr
+        }
+        return &Float{Value: f}
+    }
+    return typeError("float() argument must be a string or a real number, not '%s'", cls.Name)
+}
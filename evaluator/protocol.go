@@ -0,0 +1,623 @@
+// Comments in this file are inspired by Mike Ross - he knows every rule by heart
+
+package evaluator
+
+import (
+    "math/big"
+)
+
+// Python's data model: every operator is a dunder method looked up on the type.
+// Builtin types register native dunders on their classes, so user classes and
+// builtins go through exactly the same doors.
+
+type binaryDunders struct {
+    method    string
+    reflected string
+    inplace   string
+}
+
+var binaryOperators = map[string]binaryDunders{
+    "+":  {"__add__", "__radd__", "__iadd__"},
+    "-":  {"__sub__", "__rsub__", "__isub__"},
+    "*":  {"__mul__", "__rmul__", "__imul__"},
+    "/":  {"__truediv__", "__rtruediv__", "__itruediv__"},
+    "//": {"__floordiv__", "__rfloordiv__", "__ifloordiv__"},
+    "%":  {"__mod__", "__rmod__", "__imod__"},
+    "**": {"__pow__", "__rpow__", "__ipow__"},
+    "@":  {"__matmul__", "__rmatmul__", "__imatmul__"},
+    "&":  {"__and__", "__rand__", "__iand__"},
+    "|":  {"__or__", "__ror__", "__ior__"},
+    "^":  {"__xor__", "__rxor__", "__ixor__"},
+    "<<": {"__lshift__", "__rlshift__", "__ilshift__"},
+    ">>": {"__rshift__", "__rrshift__", "__irshift__"},
+
+    "divmod": {"__divmod__", "__rdivmod__", ""}, // not an operator, but it dispatches like one
+}
+
+var unaryOperators = map[string]string{
+    "-": "__neg__",
+    "+": "__pos__",
+    "~": "__invert__",
+}
+
+// Rich comparisons and what each one turns into when the operands swap sides
+var comparisonOperators = map[string][2]string{
+    "==": {"__eq__", "__eq__"},
+    "!=": {"__ne__", "__ne__"},
+    "<":  {"__lt__", "__gt__"},
+    "<=": {"__le__", "__ge__"},
+    ">":  {"__gt__", "__lt__"},
+    ">=": {"__ge__", "__le__"},
+}
+
+// callMethod invokes a method found on the type with self in front.
+// staticmethods and other descriptors decide for themselves what to bind.
+func callMethod(env *Environment, method Object, self Object, args ...Object) Object {
+    switch method.(type) {
+    case *Function, *Builtin:
+        return applyFunction(env, method, append([]Object{self}, args...), nil)
+    }
+    bound := descriptorGet(env, method, self, typeOf(self))
+    if isError(bound) {
+        return bound
+    }
+    return applyFunction(env, bound, args, nil)
+}
+
+// binaryOperation tries left.__op__(right), then right.__rop__(left). If the
+// right operand's type is a subclass that overrides the reflected method, it
+// gets the first word - exactly like CPython.
+func binaryOperation(env *Environment, operator string, left, right Object) Object {
+    if result := fastBinaryOperation(operator, left, right); result != nil {
+        return result
+    }
+    result := tryBinaryOperation(env, operator, left, right)
+    if result == NotImplemented {
+        return unsupportedOperands(operator, operator, left, right)
+    }
+    return result
+}
+
+// unsupportedOperands is the TypeError for operands that both said
+// NotImplemented. A sequence asked to repeat gets its own wording.
+func unsupportedOperands(operator, spelling string, left, right Object) *Error {
+    if operator == "*" {
+        for _, pair := range [][2]Object{{left, right}, {right, left}} {
+            switch payload(pair[0]).(type) {
+            case *String, *List, *Tuple, *Bytes, *ByteArray:
+                return typeError("can't multiply sequence by non-int of type '%s'", typeName(pair[1]))
+            }
+        }
+    }
+    return typeError("unsupported operand type(s) for %s: '%s' and '%s'", operatorName(spelling), typeName(left), typeName(right))
+}
+
+// operatorName is how type errors spell an operator; ** also answers to pow()
+func operatorName(operator string) string {
+    switch operator {
+    case "**", "**=":
+        return operator + " or pow()"
+    case "divmod":
+        return "divmod()"
+    }
+    return operator
+}
+
+func tryBinaryOperation(env *Environment, operator string, left, right Object) Object {
+    names, ok := binaryOperators[operator]
+    if !ok {
+        return newError("unknown operator: %s", operator)
+    }
+
+    leftType, rightType := typeOf(left), typeOf(right)
+    method := leftType.lookupName(names.method)
+    var reflected Object
+    if rightType != leftType {
+        reflected = rightType.lookupName(names.reflected)
+        if reflected != nil && reflected == leftType.lookupName(names.reflected) {
+            reflected = nil // same implementation - the left side already had its chance
+        }
+    }
+
+    if reflected != nil && rightType.isSubclass(leftType) {
+        result := callMethod(env, reflected, right, left)
+        if result != NotImplemented {
+            return result
+        }
+        reflected = nil
+    }
+    if method != nil {
+        result := callMethod(env, method, left, right)
+        if result != NotImplemented {
+            return result
+        }
+    }
+    if reflected != nil {
+        return callMethod(env, reflected, right, left)
+    }
+    return NotImplemented
+}
+
+// inplaceOperation is x op= y: __iop__ first, then the plain binary operation
+func inplaceOperation(env *Environment, operator string, left, right Object) Object {
+    names, ok := binaryOperators[operator]
+    if !ok {
+        return newError("unknown operator: %s=", operator)
+    }
+    if method := typeOf(left).lookupName(names.inplace); method != nil {
+        result := callMethod(env, method, left, right)
+        if result != NotImplemented {
+            return result
+        }
+    }
+    if result := fastBinaryOperation(operator, left, right); result != nil {
+        return result
+    }
+    result := tryBinaryOperation(env, operator, left, right)
+    if result == NotImplemented {
+        return unsupportedOperands(operator, operator+"=", left, right)
+    }
+    return result
+}
+
+func unaryOperation(env *Environment, operator string, operand Object) Object {
+    name := unaryOperators[operator]
+    method := typeOf(operand).lookupName(name)
+    if method == nil {
+        return typeError("bad operand type for unary %s: '%s'", operator, typeName(operand))
+    }
+    return callMethod(env, method, operand)
+}
+
+// compareOperation handles every operator that can appear in a comparison chain
+func compareOperation(env *Environment, operator string, left, right Object) Object {
+    switch operator {
+    case "is":
+        return nativeBool(left == right)
+    case "is not":
+        return nativeBool(left != right)
+    case "in", "not in":
+        ok, err := contains(env, right, left)
+        if err != nil {
+            return err
+        }
+        return nativeBool(ok == (operator == "in"))
+    }
+    return richCompare(env, operator, left, right)
+}
+
+// richCompare follows CPython's do_richcompare: maybe the reflected method of a
+// subclass first, then the left operand, then the reflected one. == and != fall
+// back to identity; ordering comparisons give up with a TypeError.
+func richCompare(env *Environment, operator string, left, right Object) Object {
+    if result := fastCompare(operator, left, right); result != nil {
+        return result
+    }
+
+    names := comparisonOperators[operator]
+    leftType, rightType := typeOf(left), typeOf(right)
+    checkedReflected := false
+
+    if leftType != rightType && rightType.isSubclass(leftType) {
+        if reflected := rightType.lookupName(names[1]); reflected != nil {
+            checkedReflected = true
+            result := callMethod(env, reflected, right, left)
+            if result != NotImplemented {
+                return result
+            }
+        }
+    }
+    if method := leftType.lookupName(names[0]); method != nil {
+        result := callMethod(env, method, left, right)
+        if result != NotImplemented {
+            return result
+        }
+    }
+    if !checkedReflected {
+        if reflected := rightType.lookupName(names[1]); reflected != nil {
+            result := callMethod(env, reflected, right, left)
+            if result != NotImplemented {
+                return result
+            }
+        }
+    }
+
+    switch operator {
+    case "==":
+        return nativeBool(left == right)
+    case "!=":
+        return nativeBool(left != right)
+    }
+    return typeError("'%s' not supported between instances of '%s' and '%s'", operator, typeName(left), typeName(right))
+}
+
+// equals is == as a Go bool; identical objects are always equal, like in containers
+func equals(env *Environment, left, right Object) (bool, *Error) {
+    if left == right {
+        return true, nil
+    }
+    result := richCompare(env, "==", left, right)
+    if err, ok := result.(*Error); ok {
+        return false, err
+    }
+    return truthy(env, result)
+}
+
+// lessThan is < as a Go bool, for sorting and min/max
+func lessThan(env *Environment, left, right Object) (bool, *Error) {
+    result := richCompare(env, "<", left, right)
+    if err, ok := result.(*Error); ok {
+        return false, err
+    }
+    return truthy(env, result)
+}
+
+// truthy - __bool__ first, then __len__, otherwise everything is true
+func truthy(env *Environment, obj Object) (bool, *Error) {
+    switch obj := obj.(type) {
+    case *Boolean:
+        return obj.Value, nil
+    case *NullObject:
+        return false, nil
+    case *Integer:
+        return obj.Value.Sign() != 0, nil
+    case *Float:
+        return obj.Value != 0, nil
+    case *String:
+        return obj.Value != "", nil
+    case *List:
+        return len(obj.Elements) > 0, nil
+    case *Tuple:
+        return len(obj.Elements) > 0, nil
+    case *Dict:
+        return obj.Len() > 0, nil
+    }
+
+    cls := typeOf(obj)
+    if method := cls.lookupName("__bool__"); method != nil {
+        result := callMethod(env, method, obj)
+        if err, ok := result.(*Error); ok {
+            return false, err
+        }
+        b, ok := result.(*Boolean)
+        if !ok {
+            return false, typeError("__bool__ should return bool, returned %s", typeName(result))
+        }
+        return b.Value, nil
+    }
+    if cls.lookupName("__len__") != nil {
+        n, err := length(env, obj)
+        return n > 0, err
+    }
+    return true, nil
+}
+
+// length is len(obj) through __len__
+func length(env *Environment, obj Object) (int, *Error) {
+    switch obj := obj.(type) {
+    case *List:
+        return len(obj.Elements), nil
+    case *Tuple:
+        return len(obj.Elements), nil
+    case *Dict:
+        return obj.Len(), nil
+    }
+
+    method := typeOf(obj).lookupName("__len__")
+    if method == nil {
+        return 0, typeError("object of type '%s' has no len()", typeName(obj))
+    }
+    result := callMethod(env, method, obj)
+    if err, ok := result.(*Error); ok {
+        return 0, err
+    }
+    if _, ok := payload(result).(*Integer); !ok {
+        if _, ok := result.(*Boolean); !ok {
+            return 0, typeError("'%s' object cannot be interpreted as an integer", typeName(result))
+        }
+    }
+    n, _ := toBigInt(result)
+    if n.Sign() < 0 {
+        return 0, valueError("__len__() should return >= 0")
+    }
+    if !n.IsInt64() || n.Int64() != int64(int(n.Int64())) {
+        return 0, overflowError("cannot fit 'int' into an index-sized integer")
+    }
+    return int(n.Int64()), nil
+}
+
+// getItem is obj[key]
+func getItem(env *Environment, obj, key Object) Object {
+    method := typeOf(obj).lookupName("__getitem__")
+    if method == nil {
+        if cls, ok := obj.(*Class); ok {
+            if classGetItem := cls.lookupName("__class_getitem__"); classGetItem != nil {
+                return applyFunction(env, classGetItem, []Object{cls, key}, nil)
+            }
+            return typeError("type '%s' is not subscriptable", cls.Name)
+        }
+        return typeError("'%s' object is not subscriptable", typeName(obj))
+    }
+    return callMethod(env, method, obj, key)
+}
+
+// setItem is obj[key] = value
+func setItem(env *Environment, obj, key, value Object) *Error {
+    method := typeOf(obj).lookupName("__setitem__")
+    if method == nil {
+        return typeError("'%s' object does not support item assignment", typeName(obj))
+    }
+    if err, ok := callMethod(env, method, obj, key, value).(*Error); ok {
+        return err
+    }
+    return nil
+}
+
+// delItem is del obj[key]
+func delItem(env *Environment, obj, key Object) *Error {
+    method := typeOf(obj).lookupName("__delitem__")
+    if method == nil {
+        if typeOf(obj).lookupName("__getitem__") == nil {
+            return typeError("'%s' object does not support item deletion", typeName(obj))
+        }
+        return typeError("'%s' object doesn't support item deletion", typeName(obj))
+    }
+    if err, ok := callMethod(env, method, obj, key).(*Error); ok {
+        return err
+    }
+    return nil
+}
+
+// contains is `item in container`: __contains__, else a walk through __iter__
+func contains(env *Environment, container, item Object) (bool, *Error) {
+    cls := typeOf(container)
+    if method := cls.lookupName("__contains__"); method != nil {
+        result := callMethod(env, method, container, item)
+        if err, ok := result.(*Error); ok {
+            return false, err
+        }
+        return truthy(env, result)
+    }
+    if cls.lookupName("__iter__") == nil && cls.lookupName("__getitem__") == nil {
+        return false, typeError("argument of type '%s' is not iterable", cls.Name)
+    }
+
+    found := false
+    err := iterate(env, container, func(element Object) *Error {
+        eq, err := equals(env, element, item)
+        if err != nil {
+            return err
+        }
+        if eq {
+            found = true
+            return errStopLoop
+        }
+        return nil
+    })
+    return found, err
+}
+
+// getIter is iter(obj): __iter__, or the old __getitem__ sequence protocol
+func getIter(env *Environment, obj Object) Object {
+    cls := typeOf(obj)
+    if method := cls.lookupName("__iter__"); method != nil {
+        iterator := callMethod(env, method, obj)
+        if isError(iterator) {
+            return iterator
+        }
+        if !isIterator(iterator) {
+            return typeError("iter() returned non-iterator of type '%s'", typeName(iterator))
+        }
+        return iterator
+    }
+    if method := cls.lookupName("__getitem__"); method != nil {
+        index := 0
+        return newIterator(seqIteratorType, func(env *Environment) (Object, bool) {
+            item := callMethod(env, method, obj, newInt(int64(index)))
+            if err, ok := item.(*Error); ok && (err.matches(indexErrorType) || err.matches(stopIterationType)) {
+                return nil, false
+            }
+            index++
+            return item, true
+        })
+    }
+    return typeError("'%s' object is not iterable", cls.Name)
+}
+
+// isCallable is callable(obj)
+func isCallable(obj Object) bool {
+    switch obj.(type) {
+    case *Builtin, *Function, *BoundMethod, *Class:
+        return true
+    }
+    return typeOf(obj).lookupName("__call__") != nil
+}
+
+func isIterator(obj Object) bool {
+    if _, ok := obj.(*Iter); ok {
+        return true
+    }
+    return typeOf(obj).lookupName("__next__") != nil
+}
+
+// iterNext advances an iterator. ok is false once it is exhausted; otherwise
+// the value may be an *Error the caller has to pass on.
+func iterNext(env *Environment, iterator Object) (Object, bool) {
+    if it, ok := iterator.(*Iter); ok {
+        return it.next(env)
+    }
+    method := typeOf(iterator).lookupName("__next__")
+    if method == nil {
+        return typeError("'%s' object is not an iterator", typeName(iterator)), true
+    }
+    value := callMethod(env, method, iterator)
+    if isStopIteration(value) {
+        return nil, false
+    }
+    return value, true
+}
+
+// errStopLoop lets an iterate callback finish early without it being an error
+var errStopLoop = &Error{}
+
+// iterate runs fn for every item of an iterable
+func iterate(env *Environment, obj Object, fn func(item Object) *Error) *Error {
+    switch obj := obj.(type) {
+    case *List:
+        for i := 0; i < len(obj.Elements); i++ {
+            if err := fn(obj.Elements[i]); err != nil {
+                return stopLoopIsFine(err)
+            }
+        }
+        return nil
+    case *Tuple:
+        for _, element := range obj.Elements {
+            if err := fn(element); err != nil {
+                return stopLoopIsFine(err)
+            }
+        }
+        return nil
+    }
+
+    iterator := getIter(env, obj)
+    if err, ok := iterator.(*Error); ok {
+        return err
+    }
+    for {
+        item, ok := iterNext(env, iterator)
+        if !ok {
+            return nil
+        }
+        if err, ok := item.(*Error); ok {
+            return err
+        }
+        if err := fn(item); err != nil {
+            return stopLoopIsFine(err)
+        }
+    }
+}
+
+func stopLoopIsFine(err *Error) *Error {
+    if err == errStopLoop {
+        return nil
+    }
+    return err
+}
+
+// iterableToSlice drains any iterable into a Go slice
+func iterableToSlice(env *Environment, obj Object) ([]Object, *Error) {
+    switch obj := obj.(type) {
+    case *List:
+        return append([]Object{}, obj.Elements...), nil
+    case *Tuple:
+        return append([]Object{}, obj.Elements...), nil
+    }
+    items := []Object{}
+    err := iterate(env, obj, func(item Object) *Error {
+        items = append(items, item)
+        return nil
+    })
+    return items, err
+}
+
+// strOf is str(obj)
+func strOf(env *Environment, obj Object) Object {
+    if s, ok := obj.(*String); ok {
+        return s
+    }
+    method := typeOf(obj).lookupName("__str__")
+    result := callMethod(env, method, obj)
+    if isError(result) {
+        return result
+    }
+    if _, ok := payload(result).(*String); !ok {
+        return typeError("__str__ returned non-string (type %s)", typeName(result))
+    }
+    return result
+}
+
+// reprOf is repr(obj)
+func reprOf(env *Environment, obj Object) Object {
+    method := typeOf(obj).lookupName("__repr__")
+    result := callMethod(env, method, obj)
+    if isError(result) {
+        return result
+    }
+    if _, ok := payload(result).(*String); !ok {
+        return typeError("__repr__ returned non-string (type %s)", typeName(result))
+    }
+    return result
+}
+
+// reprString is reprOf for Go callers that just want the text
+func reprString(env *Environment, obj Object) (string, *Error) {
+    result := reprOf(env, obj)
+    if err, ok := result.(*Error); ok {
+        return "", err
+    }
+    return payload(result).(*String).Value, nil
+}
+
+// hashOf is hash(obj). A __hash__ of None means unhashable.
+func hashOf(env *Environment, obj Object) (int64, *Error) {
+    switch obj := obj.(type) {
+    case *String:
+        return hashString(obj.Value), nil
+    case *Integer:
+        return hashBigInt(obj.Value), nil
+    case *Boolean:
+        if obj.Value {
+            return 1, nil
+        }
+        return 0, nil
+    }
+
+    method := typeOf(obj).lookupName("__hash__")
+    if method == nil || method == NULL {
+        return 0, typeError("unhashable type: '%s'", typeName(obj))
+    }
+    result := callMethod(env, method, obj)
+    if err, ok := result.(*Error); ok {
+        return 0, err
+    }
+    n, ok := toBigInt(result)
+    if !ok {
+        return 0, typeError("__hash__ method should return an integer")
+    }
+    // Values that fit are used as they are; bigger ones are hashed like ints
+    h := hashBigInt(n)
+    if n.IsInt64() {
+        h = n.Int64()
+    }
+    if h == -1 {
+        h = -2
+    }
+    return h, nil
+}
+
+// indexValue is operator.index(obj): ints, bools and anything with __index__
+func indexValue(env *Environment, obj Object) (*big.Int, *Error) {
+    if n, ok := toBigInt(obj); ok {
+        return n, nil
+    }
+    method := typeOf(obj).lookupName("__index__")
+    if method == nil {
+        return nil, typeError("'%s' object cannot be interpreted as an integer", typeName(obj))
+    }
+    result := callMethod(env, method, obj)
+    if err, ok := result.(*Error); ok {
+        return nil, err
+    }
+    n, ok := toBigInt(result)
+    if !ok {
+        return nil, typeError("__index__ returned non-int (type %s)", typeName(result))
+    }
+    return n, nil
+}
+
+func nativeBool(value bool) *Boolean {
+    if value {
+        return TRUE
+    }
+    return FALSE
+}
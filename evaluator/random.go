@@ -0,0 +1,1057 @@
+// Comments in this file are inspired by Trevor Evans - Mike's oldest friend, and proof that luck is never on your side twice
+
+package evaluator
+
+import (
+    "crypto/rand"
+    "crypto/sha512"
+    "encoding/binary"
+    "math"
+    "math/big"
+)
+
+// random: CPython's Mersenne Twister, word for word, so a seeded program
+// draws the same numbers here as it does there. The methods on top are
+// ports of Lib/random.py, which consume the generator in a particular
+// order - that order is part of the contract too.
+
+var (
+    randomType       = newBuiltinClass("Random", objectType)
+    systemRandomType = newBuiltinClass("SystemRandom", randomType)
+)
+
+const (
+    mtSize   = 624
+    mtShift  = 397
+    mtUpper  = 0x80000000
+    mtLower  = 0x7fffffff
+    mtMatrix = 0x9908b0df
+)
+
+// Random is a generator. The system flavour ignores the twister and reads
+// crypto/rand instead.
+type Random struct {
+    mt        [mtSize]uint32
+    index     int
+    gaussNext Object // the spare from the last gauss(), or NULL
+    system    bool
+}
+
+func (r *Random) Type() ObjectType { return RANDOM_OBJ }
+func (r *Random) Inspect() string  { return defaultRepr(r) }
+
+func asRandom(obj Object) *Random { return payload(obj).(*Random) }
+
+// initGenrand is init_genrand from mt19937ar.c
+func (r *Random) initGenrand(s uint32) {
+    r.mt[0] = s
+    for i := 1; i < mtSize; i++ {
+        r.mt[i] = 1812433253*(r.mt[i-1]^(r.mt[i-1]>>30)) + uint32(i)
+    }
+    r.index = mtSize
+}
+
+// initByArray is init_by_array, how every seed gets in
+func (r *Random) initByArray(key []uint32) {
+    r.initGenrand(19650218)
+    i, j := 1, 0
+    k := mtSize
+    if len(key) > k {
+        k = len(key)
+    }
+    for ; k > 0; k-- {
+        r.mt[i] = (r.mt[i] ^ ((r.mt[i-1] ^ (r.mt[i-1] >> 30)) * 1664525)) + key[j] + uint32(j)
+        i++
+        j++
+        if i >= mtSize {
+            r.mt[0] = r.mt[mtSize-1]
+            i = 1
+        }
+        if j >= len(key) {
+            j = 0
+        }
+    }
+    for k = mtSize - 1; k > 0; k-- {
+        r.mt[i] = (r.mt[i] ^ ((r.mt[i-1] ^ (r.mt[i-1] >> 30)) * 1566083941)) - uint32(i)
+        i++
+        if i >= mtSize {
+            r.mt[0] = r.mt[mtSize-1]
+            i = 1
+        }
+    }
+    r.mt[0] = 0x80000000
+}
+
+// next is genrand_uint32: regenerate the whole block when it runs dry,
+// then temper one word
+func (r *Random) next() uint32 {
+    mag := [2]uint32{0, mtMatrix}
+    if r.index >= mtSize {
+        kk := 0
+        for ; kk < mtSize-mtShift; kk++ {
+            y := (r.mt[kk] & mtUpper) | (r.mt[kk+1] & mtLower)
+            r.mt[kk] = r.mt[kk+mtShift] ^ (y >> 1) ^ mag[y&1]
+        }
+        for ; kk < mtSize-1; kk++ {
+            y := (r.mt[kk] & mtUpper) | (r.mt[kk+1] & mtLower)
+            r.mt[kk] = r.mt[kk+(mtShift-mtSize)] ^ (y >> 1) ^ mag[y&1]
+        }
+        y := (r.mt[mtSize-1] & mtUpper) | (r.mt[0] & mtLower)
+        r.mt[mtSize-1] = r.mt[mtShift-1] ^ (y >> 1) ^ mag[y&1]
+        r.index = 0
+    }
+    y := r.mt[r.index]
+    r.index++
+    y ^= y >> 11
+    y ^= (y << 7) & 0x9d2c5680
+    y ^= (y << 15) & 0xefc60000
+    return y ^ (y >> 18)
+}
+
+// float is random(): 53 bits from two words, 27 and 26 at a time
+func (r *Random) float() float64 {
+    if r.system {
+        var b [8]byte
+        rand.Read(b[1:])
+        return float64(binary.BigEndian.Uint64(b[:])>>3) * (1.0 / 9007199254740992.0)
+    }
+    a, b := r.next()>>5, r.next()>>6
+    return (float64(a)*67108864.0 + float64(b)) * (1.0 / 9007199254740992.0)
+}
+
+// bits is getrandbits(k) for k >= 0. The twister fills 32-bit words from
+// the least significant end and trims the last one from the top.
+func (r *Random) bits(k int) *big.Int {
+    if r.system {
+        buf := make([]byte, (k+7)/8)
+        rand.Read(buf)
+        n := new(big.Int).SetBytes(buf)
+        return n.Rsh(n, uint(len(buf)*8-k))
+    }
+    if k <= 32 {
+        if k == 0 {
+            return big.NewInt(0)
+        }
+        return big.NewInt(int64(r.next() >> uint(32-k)))
+    }
+    words := (k-1)/32 + 1
+    buf := make([]byte, words*4)
+    for i := 0; i < words; i, k = i+1, k-32 {
+        w := r.next()
+        if k < 32 {
+            w >>= uint(32 - k)
+        }
+        // big.Int wants big-endian bytes, so the first word goes last
+        binary.BigEndian.PutUint32(buf[(words-1-i)*4:], w)
+    }
+    return new(big.Int).SetBytes(buf)
+}
+
+// seedInt is random_seed for an int: abs(n) cut into 32-bit words,
+// least significant first
+func (r *Random) seedInt(n *big.Int) {
+    raw := new(big.Int).Abs(n).Bytes()
+    padded := make([]byte, (len(raw)+3)/4*4)
+    copy(padded[len(padded)-len(raw):], raw)
+    key := []uint32{0}
+    if len(padded) > 0 {
+        key = make([]uint32, len(padded)/4)
+        for i := range key {
+            key[i] = binary.BigEndian.Uint32(padded[len(padded)-4*(i+1):])
+        }
+    }
+    r.initByArray(key)
+}
+
+// seedRandomly is seed(None): a full key of fresh entropy
+func (r *Random) seedRandomly() {
+    var buf [mtSize * 4]byte
+    rand.Read(buf[:])
+    key := make([]uint32, mtSize)
+    for i := range key {
+        key[i] = binary.LittleEndian.Uint32(buf[i*4:])
+    }
+    r.initByArray(key)
+}
+
+// seed is Random.seed: strs and bytes go through sha512 (version 2) or
+// the old string hash (version 1), other hashables through hash()
+func (r *Random) seed(env *Environment, a, version Object) *Error {
+    r.gaussNext = NULL
+    if r.system || a == NULL {
+        if !r.system {
+            r.seedRandomly()
+        }
+        return nil
+    }
+    v := int64(2)
+    if version != nil {
+        n, ok := toBigInt(version)
+        if !ok || !n.IsInt64() {
+            v = 0
+        } else {
+            v = n.Int64()
+        }
+    }
+    var data []byte
+    text, isText := payload(a).(*String)
+    switch value := payload(a).(type) {
+    case *String:
+        data = []byte(value.Value)
+    case *Bytes:
+        data = value.Value
+    case *ByteArray:
+        data = value.Value
+    }
+    switch {
+    case v == 1 && (isText || data != nil) && !isByteArray(a):
+        var chars []rune
+        if isText {
+            chars = []rune(text.Value)
+        } else {
+            for _, b := range data {
+                chars = append(chars, rune(b))
+            }
+        }
+        var x uint64
+        if len(chars) > 0 {
+            x = uint64(chars[0]) << 7
+        }
+        for _, c := range chars {
+            x = (1000003 * x) ^ uint64(c)
+        }
+        x ^= uint64(len(chars))
+        r.seedInt(new(big.Int).SetUint64(x))
+    case v == 2 && (isText || data != nil):
+        sum := sha512.Sum512(data)
+        r.seedInt(new(big.Int).SetBytes(append(append([]byte{}, data...), sum[:]...)))
+    default:
+        if n, ok := toBigInt(a); ok {
+            r.seedInt(n)
+            return nil
+        }
+        switch payload(a).(type) {
+        case *Float, *String, *Bytes, *ByteArray:
+        default:
+            return typeError("The only supported seed types are: None,\nint, float, str, bytes, and bytearray.")
+        }
+        h, err := hashOf(env, a)
+        if err != nil {
+            return err
+        }
+        r.seedInt(new(big.Int).SetUint64(uint64(h)))
+    }
+    return nil
+}
+
+func isByteArray(obj Object) bool {
+    _, ok := payload(obj).(*ByteArray)
+    return ok
+}
+
+// overridden reports whether a subclass replaced one of our methods, in
+// which case everything built on it has to go through the replacement
+func overridden(self Object, name string) bool {
+    found := typeOf(self).lookupName(name)
+    mine, _ := randomType.Dict.GetStr(name)
+    system, _ := systemRandomType.Dict.GetStr(name)
+    return found != mine && found != system
+}
+
+// randomFloat is self.random()
+func randomFloat(env *Environment, self Object) (float64, *Error) {
+    if !overridden(self, "random") {
+        return asRandom(self).float(), nil
+    }
+    result := callAttribute(env, self, "random")
+    if err, ok := result.(*Error); ok {
+        return 0, err
+    }
+    f, ok, err := toFloat(result)
+    if err != nil {
+        return 0, err
+    }
+    if !ok {
+        return 0, typeError("must be real number, not %s", typeName(result))
+    }
+    return f, nil
+}
+
+// randomCount is the k of getrandbits or the n of randbytes. The twister
+// wants a C int; SystemRandom hands it to os.urandom as a Py_ssize_t and
+// has to be able to allocate that many bytes.
+func randomCount(env *Environment, obj Object, system bool) (int, *Error) {
+    value, err := indexValue(env, obj)
+    if err != nil {
+        return 0, err
+    }
+    if !system {
+        if !value.IsInt64() || value.Int64() != int64(int32(value.Int64())) {
+            return 0, overflowError("Python int too large to convert to C int")
+        }
+        return int(value.Int64()), nil
+    }
+    if !value.IsInt64() {
+        return 0, overflowError("Python int too large to convert to C ssize_t")
+    }
+    if value.Sign() > 0 {
+        if _, err := allocation(int(value.Int64()), 1); err != nil {
+            return 0, err
+        }
+    }
+    return int(value.Int64()), nil
+}
+
+// randomBits is self.getrandbits(k)
+func randomBits(env *Environment, self Object, k int) (*big.Int, *Error) {
+    if !overridden(self, "getrandbits") {
+        return asRandom(self).bits(k), nil
+    }
+    result := callAttribute(env, self, "getrandbits", newInt(int64(k)))
+    if err, ok := result.(*Error); ok {
+        return nil, err
+    }
+    n, ok := toBigInt(result)
+    if !ok {
+        return nil, typeError("'%s' object cannot be interpreted as an integer", typeName(result))
+    }
+    return n, nil
+}
+
+// randBelow is _randbelow(n) for n > 0. Like __init_subclass__, it picks
+// getrandbits unless a subclass only brought its own random().
+func randBelow(env *Environment, self Object, n *big.Int) (*big.Int, *Error) {
+    withBits := true
+    for _, cls := range typeOf(self).MRO {
+        if _, ok := cls.Dict.GetStr("getrandbits"); ok || cls == randomType || cls == systemRandomType {
+            break
+        }
+        if _, ok := cls.Dict.GetStr("random"); ok {
+            withBits = false
+            break
+        }
+    }
+    if withBits {
+        k := n.BitLen()
+        for {
+            r, err := randomBits(env, self, k)
+            if err != nil {
+                return nil, err
+            }
+            if r.Cmp(n) < 0 {
+                return r, nil
+            }
+        }
+    }
+
+    // Only 53 bits to work with: reject the uneven tail of [0, 1)
+    const maxSize = 1 << 53
+    if !n.IsInt64() || n.Int64() >= maxSize {
+        r, err := randomFloat(env, self)
+        if err != nil {
+            return nil, err
+        }
+        product, _ := new(big.Float).Mul(big.NewFloat(r), new(big.Float).SetInt(n)).Int(nil)
+        return product, nil
+    }
+    limit := float64(maxSize-maxSize%n.Int64()) / maxSize
+    for {
+        r, err := randomFloat(env, self)
+        if err != nil {
+            return nil, err
+        }
+        if r < limit {
+            return big.NewInt(int64(math.Floor(r*maxSize)) % n.Int64()), nil
+        }
+    }
+}
+
+// randIndex is randBelow for a sequence length
+func randIndex(env *Environment, self Object, n int) (int, *Error) {
+    r, err := randBelow(env, self, big.NewInt(int64(n)))
+    if err != nil {
+        return 0, err
+    }
+    return int(r.Int64()), nil
+}
+
+// rangeArgument is a randrange bound: an index, or a float that happens to
+// be integral
+func rangeArgument(env *Environment, obj Object, what string) (*big.Int, *Error) {
+    if f, ok := payload(obj).(*Float); ok {
+        if f.Value != math.Trunc(f.Value) || math.IsInf(f.Value, 0) {
+            return nil, valueError("non-integer %s for randrange()", what)
+        }
+        n, _ := big.NewFloat(f.Value).Int(nil)
+        return n, nil
+    }
+    return indexValue(env, obj)
+}
+
+// randRange is randrange(start, stop, step); stop is nil for randrange(n)
+func randRange(env *Environment, self Object, start, stop, step Object) Object {
+    istart, err := rangeArgument(env, start, "arg 1")
+    if err != nil {
+        return err
+    }
+    if stop == nil || stop == NULL {
+        if step != nil {
+            if n, ok := toBigInt(step); !ok || n.Cmp(big.NewInt(1)) != 0 {
+                return typeError("Missing a non-None stop argument")
+            }
+        }
+        if istart.Sign() > 0 {
+            r, err := randBelow(env, self, istart)
+            if err != nil {
+                return err
+            }
+            return newBigInt(r)
+        }
+        return valueError("empty range for randrange()")
+    }
+    istop, err := rangeArgument(env, stop, "stop")
+    if err != nil {
+        return err
+    }
+    width := new(big.Int).Sub(istop, istart)
+    istep := big.NewInt(1)
+    if step != nil {
+        if istep, err = rangeArgument(env, step, "step"); err != nil {
+            return err
+        }
+    }
+    if istep.Cmp(big.NewInt(1)) == 0 {
+        if width.Sign() > 0 {
+            r, err := randBelow(env, self, width)
+            if err != nil {
+                return err
+            }
+            return newBigInt(r.Add(r, istart))
+        }
+        return valueError("empty range for randrange() (%s, %s, %s)", istart, istop, width)
+    }
+    var n *big.Int
+    switch istep.Sign() {
+    case 1:
+        n = floorDiv(new(big.Int).Add(width, new(big.Int).Sub(istep, big.NewInt(1))), istep)
+    case -1:
+        n = floorDiv(new(big.Int).Add(width, new(big.Int).Add(istep, big.NewInt(1))), istep)
+    default:
+        return valueError("zero step for randrange()")
+    }
+    if n.Sign() <= 0 {
+        return valueError("empty range for randrange()")
+    }
+    r, err := randBelow(env, self, n)
+    if err != nil {
+        return err
+    }
+    return newBigInt(r.Add(istart, r.Mul(r, istep)))
+}
+
+func floorDiv(a, b *big.Int) *big.Int {
+    q, m := new(big.Int).DivMod(a, b, new(big.Int))
+    if m.Sign() != 0 && b.Sign() < 0 {
+        q.Add(q, big.NewInt(1))
+    }
+    return q
+}
+
+// bisectRight finds where x goes in the sorted cumulative weights
+func bisectRight(env *Environment, a []Object, x Object, hi int) (int, *Error) {
+    lo := 0
+    for lo < hi {
+        mid := (lo + hi) / 2
+        less, err := lessThan(env, x, a[mid])
+        if err != nil {
+            return 0, err
+        }
+        if less {
+            hi = mid
+        } else {
+            lo = mid + 1
+        }
+    }
+    return lo, nil
+}
+
+// accumulate is list(itertools.accumulate(values))
+func accumulate(env *Environment, values Object) ([]Object, *Error) {
+    var sums []Object
+    err := iterate(env, values, func(item Object) *Error {
+        if len(sums) > 0 {
+            item = binaryOperation(env, "+", sums[len(sums)-1], item)
+            if err, ok := item.(*Error); ok {
+                return err
+            }
+        }
+        sums = append(sums, item)
+        return nil
+    })
+    return sums, err
+}
+
+// choices is Random.choices: equal odds unless weighted, with replacement
+func choices(env *Environment, self, population, weights, cumWeights Object, k int) Object {
+    n, err := length(env, population)
+    if err != nil {
+        return err
+    }
+    result := make([]Object, 0, k)
+    pick := func(i int) *Error {
+        item := getItem(env, population, newInt(int64(i)))
+        if err, ok := item.(*Error); ok {
+            return err
+        }
+        result = append(result, item)
+        return nil
+    }
+    if cumWeights == nil {
+        if weights == nil {
+            for i := 0; i < k; i++ {
+                r, err := randomFloat(env, self)
+                if err != nil {
+                    return err
+                }
+                if err := pick(int(math.Floor(r * float64(n)))); err != nil {
+                    return err
+                }
+            }
+            return &List{Elements: result}
+        }
+        sums, err := accumulate(env, weights)
+        if err != nil {
+            if count, ok := payload(weights).(*Integer); ok {
+                return typeError("The number of choices must be a keyword argument: k=%s", count.Inspect())
+            }
+            return err
+        }
+        cumWeights = &List{Elements: sums}
+    } else if weights != nil {
+        return typeError("Cannot specify both weights and cumulative weights")
+    }
+
+    var cum []Object
+    if err := iterate(env, cumWeights, func(item Object) *Error {
+        cum = append(cum, item)
+        return nil
+    }); err != nil {
+        return err
+    }
+    if len(cum) != n {
+        return valueError("The number of weights does not match the population")
+    }
+    if n == 0 {
+        return indexError("list index out of range")
+    }
+    total := binaryOperation(env, "+", cum[n-1], &Float{Value: 0})
+    if err, ok := total.(*Error); ok {
+        return err
+    }
+    t, _, ferr := toFloat(total)
+    if ferr != nil {
+        return ferr
+    }
+    if t <= 0 {
+        return valueError("Total of weights must be greater than zero")
+    }
+    if math.IsInf(t, 0) || math.IsNaN(t) {
+        return valueError("Total of weights must be finite")
+    }
+    for i := 0; i < k; i++ {
+        r, err := randomFloat(env, self)
+        if err != nil {
+            return err
+        }
+        index, err := bisectRight(env, cum, &Float{Value: r * t}, n-1)
+        if err != nil {
+            return err
+        }
+        if err := pick(index); err != nil {
+            return err
+        }
+    }
+    return &List{Elements: result}
+}
+
+// sample is Random.sample: k distinct positions, drawn from a shrinking
+// pool when that is small, otherwise by rejecting repeats
+func sample(env *Environment, self, population Object, k int, counts Object) Object {
+    switch value := payload(population).(type) {
+    case *Dict, *Set:
+        return typeError("Population must be a sequence.  For dicts or sets, use sorted(d).")
+    case *Instance:
+        if value.Class.lookupName("__getitem__") == nil {
+            return typeError("Population must be a sequence.  For dicts or sets, use sorted(d).")
+        }
+    }
+    n, err := length(env, population)
+    if err != nil {
+        return err
+    }
+    if counts != nil && counts != NULL {
+        cum, err := accumulate(env, counts)
+        if err != nil {
+            return err
+        }
+        if len(cum) != n {
+            return valueError("The number of counts does not match the population")
+        }
+        if n == 0 {
+            return indexError("pop from empty list")
+        }
+        total, ok := payload(cum[n-1]).(*Integer)
+        if !ok {
+            return typeError("Counts must be integers")
+        }
+        if total.Value.Sign() <= 0 {
+            return valueError("Total of counts must be greater than zero")
+        }
+        positions := sample(env, self, &Range{Start: big.NewInt(0), Stop: total.Value, Step: big.NewInt(1)}, k, nil)
+        if isError(positions) {
+            return positions
+        }
+        result := positions.(*List).Elements
+        for i, position := range result {
+            index, err := bisectRight(env, cum[:n-1], position, n-1)
+            if err != nil {
+                return err
+            }
+            item := getItem(env, population, newInt(int64(index)))
+            if isError(item) {
+                return item
+            }
+            result[i] = item
+        }
+        return &List{Elements: result}
+    }
+
+    if k < 0 || k > n {
+        return valueError("Sample larger than population or is negative")
+    }
+    result := make([]Object, k)
+    setSize := 21
+    if k > 5 {
+        setSize += int(math.Pow(4, math.Ceil(logarithm(float64(k*3))/logarithm(4))))
+    }
+    if n <= setSize {
+        var pool []Object
+        if err := iterate(env, population, func(item Object) *Error {
+            pool = append(pool, item)
+            return nil
+        }); err != nil {
+            return err
+        }
+        for i := 0; i < k; i++ {
+            j, err := randIndex(env, self, n-i)
+            if err != nil {
+                return err
+            }
+            result[i] = pool[j]
+            pool[j] = pool[n-i-1]
+        }
+        return &List{Elements: result}
+    }
+    selected := map[int]bool{}
+    for i := 0; i < k; i++ {
+        j, err := randIndex(env, self, n)
+        if err != nil {
+            return err
+        }
+        for selected[j] {
+            if j, err = randIndex(env, self, n); err != nil {
+                return err
+            }
+        }
+        selected[j] = true
+        item := getItem(env, population, newInt(int64(j)))
+        if isError(item) {
+            return item
+        }
+        result[i] = item
+    }
+    return &List{Elements: result}
+}
+
+// shuffle is Fisher-Yates from the top, in place
+func shuffle(env *Environment, self, x Object) Object {
+    n, err := length(env, x)
+    if err != nil {
+        return err
+    }
+    for i := n - 1; i > 0; i-- {
+        j, err := randIndex(env, self, i+1)
+        if err != nil {
+            return err
+        }
+        if list, ok := x.(*List); ok {
+            list.Elements[i], list.Elements[j] = list.Elements[j], list.Elements[i]
+            continue
+        }
+        a := getItem(env, x, newInt(int64(j)))
+        if isError(a) {
+            return a
+        }
+        b := getItem(env, x, newInt(int64(i)))
+        if isError(b) {
+            return b
+        }
+        if err := setItem(env, x, newInt(int64(i)), a); err != nil {
+            return err
+        }
+        if err := setItem(env, x, newInt(int64(j)), b); err != nil {
+            return err
+        }
+    }
+    return NULL
+}
+
+// gauss is Box-Muller, keeping the second value for next time
+func gauss(env *Environment, self, mu, sigma Object) Object {
+    r := asRandom(self)
+    z := r.gaussNext
+    r.gaussNext = NULL
+    if z == NULL {
+        u1, err := randomFloat(env, self)
+        if err != nil {
+            return err
+        }
+        u2, err := randomFloat(env, self)
+        if err != nil {
+            return err
+        }
+        x2pi := u1 * 2 * math.Pi
+        g2rad := math.Sqrt(-2.0 * logarithm(1.0-u2))
+        z = &Float{Value: cosine(x2pi) * g2rad}
+        r.gaussNext = &Float{Value: sine(x2pi) * g2rad}
+    }
+    scaled := binaryOperation(env, "*", z, sigma)
+    if isError(scaled) {
+        return scaled
+    }
+    return binaryOperation(env, "+", mu, scaled)
+}
+
+// getState is (3, the 624 words plus the position, gauss_next)
+func (r *Random) getState() Object {
+    words := make([]Object, mtSize+1)
+    for i, w := range r.mt {
+        words[i] = newInt(int64(w))
+    }
+    words[mtSize] = newInt(int64(r.index))
+    return &Tuple{Elements: []Object{newInt(3), &Tuple{Elements: words}, r.gaussNext}}
+}
+
+// setState undoes getState. Version 2 states are taken too, reduced mod
+// 2**32 the way the Python side does.
+func (r *Random) setState(env *Environment, state Object) *Error {
+    first := getItem(env, state, newInt(0))
+    if err, ok := first.(*Error); ok {
+        return err
+    }
+    version, _ := toBigInt(first)
+    if version == nil || (version.Cmp(big.NewInt(3)) != 0 && version.Cmp(big.NewInt(2)) != 0) {
+        text, err := reprString(env, first)
+        if err != nil {
+            return err
+        }
+        return valueError("state with version %s passed to Random.setstate() of version 3", text)
+    }
+    var parts []Object
+    if err := iterate(env, state, func(item Object) *Error {
+        parts = append(parts, item)
+        return nil
+    }); err != nil {
+        return err
+    }
+    if len(parts) != 3 {
+        if len(parts) > 3 {
+            return valueError("too many values to unpack (expected 3)")
+        }
+        return valueError("not enough values to unpack (expected 3, got %d)", len(parts))
+    }
+    tuple, ok := payload(parts[1]).(*Tuple)
+    if !ok {
+        return typeError("state vector must be a tuple")
+    }
+    if len(tuple.Elements) != mtSize+1 {
+        return valueError("state vector is the wrong size")
+    }
+    var mt [mtSize]uint32
+    for i := 0; i < mtSize; i++ {
+        n, ok := toBigInt(tuple.Elements[i])
+        if !ok {
+            return typeError("'%s' object cannot be interpreted as an integer", typeName(tuple.Elements[i]))
+        }
+        if version.Int64() == 2 {
+            n = new(big.Int).Mod(n, new(big.Int).Lsh(big.NewInt(1), 32))
+        }
+        if n.Sign() < 0 {
+            return overflowError("can't convert negative int to unsigned")
+        }
+        if n.BitLen() > 64 {
+            return overflowError("Python int too large to convert to C unsigned long")
+        }
+        mt[i] = uint32(n.Uint64())
+    }
+    index, ok := toBigInt(tuple.Elements[mtSize])
+    if !ok {
+        return typeError("'%s' object cannot be interpreted as an integer", typeName(tuple.Elements[mtSize]))
+    }
+    if !index.IsInt64() || index.Int64() < 0 || index.Int64() > mtSize {
+        return valueError("invalid state")
+    }
+    r.mt = mt
+    r.index = int(index.Int64())
+    r.gaussNext = parts[2]
+    return nil
+}
+
+func noState() *Error {
+    return newErrorKind(notImplementedErrorType, "System entropy source does not have state.")
+}
+
+func init() {
+    registerModule("random", buildRandom)
+    for _, cls := range []*Class{randomType, systemRandomType} {
+        cls.Dict.SetStr("__module__", &String{Value: "random"})
+    }
+    randomType.Dict.SetStr("VERSION", newInt(3))
+    initRandom()
+    initSystemRandom()
+}
+
+// randomMethods are the ones the module exposes, bound to a shared instance
+var randomMethods = []string{"seed", "random", "uniform", "randint", "choice", "randrange", "sample",
+    "shuffle", "choices", "gauss", "getstate", "setstate", "getrandbits", "randbytes"}
+
+func buildRandom(m *Module) {
+    m.Env.Set("Random", randomType)
+    m.Env.Set("SystemRandom", systemRandomType)
+    shared := &Random{gaussNext: NULL}
+    shared.seedRandomly()
+    for _, name := range randomMethods {
+        method, _ := randomType.Dict.GetStr(name)
+        m.Env.Set(name, &BoundMethod{Self: shared, Function: method})
+    }
+}
+
+func initRandom() {
+    randomType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        cls, err := newClassArg("Random", args)
+        if err != nil {
+            return err
+        }
+        if cls == randomType && kwargs.Len() > 0 {
+            return typeError("Random() takes no keyword arguments")
+        }
+        r := &Random{gaussNext: NULL, system: cls.isSubclass(systemRandomType)}
+        r.seedRandomly()
+        return wrapBuiltinValue(cls, randomType, r)
+    })
+    randomType.define("__init__", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if len(args) > 2 {
+            return typeError("Random.__init__() takes from 1 to 2 positional arguments but %d were given", len(args))
+        }
+        values, err := parseArgs("Random.__init__", args[1:], kwargs, []string{"x"}, 0)
+        if err != nil {
+            return err
+        }
+        if result := callAttribute(env, args[0], "seed", nilToNone(values[0])); isError(result) {
+            return result
+        }
+        asRandom(args[0]).gaussNext = NULL
+        return NULL
+    })
+    randomType.define("seed", func(env *Environment, args []Object, kwargs *Dict) Object {
+        values, err := parseArgs("seed", args[1:], kwargs, []string{"a", "version"}, 0)
+        if err != nil {
+            return err
+        }
+        if err := asRandom(args[0]).seed(env, nilToNone(values[0]), values[1]); err != nil {
+            return err
+        }
+        return NULL
+    })
+    randomType.method("random", 0, 0, func(env *Environment, args []Object) Object {
+        return &Float{Value: asRandom(args[0]).float()}
+    })
+    randomType.method("getrandbits", 1, 1, func(env *Environment, args []Object) Object {
+        k, err := randomCount(env, args[1], asRandom(args[0]).system)
+        if err != nil {
+            return err
+        }
+        if k < 0 {
+            return valueError("number of bits must be non-negative")
+        }
+        return newBigInt(asRandom(args[0]).bits(k))
+    })
+    randomType.method("randbytes", 1, 1, func(env *Environment, args []Object) Object {
+        n, err := randomCount(env, args[1], false)
+        if err != nil {
+            return err
+        }
+        if n < 0 {
+            return valueError("number of bits must be non-negative")
+        }
+        if n > math.MaxInt32/8 {
+            return overflowError("Python int too large to convert to C int")
+        }
+        bits, err := randomBits(env, args[0], n*8)
+        if err != nil {
+            return err
+        }
+        if bits.BitLen() > n*8 || bits.Sign() < 0 {
+            return overflowError("int too big to convert")
+        }
+        out := make([]byte, n)
+        bits.FillBytes(out)
+        for i, j := 0, n-1; i < j; i, j = i+1, j-1 {
+            out[i], out[j] = out[j], out[i]
+        }
+        return &Bytes{Value: out}
+    })
+    randomType.define("getstate", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("getstate", args[1:], kwargs, 0, 0); err != nil {
+            return err
+        }
+        return asRandom(args[0]).getState()
+    })
+    randomType.method("setstate", 1, 1, func(env *Environment, args []Object) Object {
+        if err := asRandom(args[0]).setState(env, args[1]); err != nil {
+            return err
+        }
+        return NULL
+    })
+    randomType.define("randrange", func(env *Environment, args []Object, kwargs *Dict) Object {
+        values, err := parseArgs("randrange", args[1:], kwargs, []string{"start", "stop", "step"}, 1)
+        if err != nil {
+            return err
+        }
+        return randRange(env, args[0], values[0], values[1], values[2])
+    })
+    randomType.define("randint", func(env *Environment, args []Object, kwargs *Dict) Object {
+        values, err := parseArgs("randint", args[1:], kwargs, []string{"a", "b"}, 2)
+        if err != nil {
+            return err
+        }
+        stop := binaryOperation(env, "+", values[1], newInt(1))
+        if isError(stop) {
+            return stop
+        }
+        return randRange(env, args[0], values[0], stop, nil)
+    })
+    randomType.method("choice", 1, 1, func(env *Environment, args []Object) Object {
+        n, err := length(env, args[1])
+        if err != nil {
+            return err
+        }
+        if n == 0 {
+            return indexError("Cannot choose from an empty sequence")
+        }
+        i, err := randIndex(env, args[0], n)
+        if err != nil {
+            return err
+        }
+        return getItem(env, args[1], newInt(int64(i)))
+    })
+    randomType.define("choices", func(env *Environment, args []Object, kwargs *Dict) Object {
+        values, err := parseArgs("choices", args[1:], kwargs, []string{"population", "weights", "*cum_weights", "*k"}, 1)
+        if err != nil {
+            return err
+        }
+        k := 1
+        if values[3] != nil {
+            if k, err = toIndex(env, values[3]); err != nil {
+                return err
+            }
+            if k < 0 {
+                k = 0
+            }
+        }
+        for _, i := range []int{1, 2} {
+            if values[i] == NULL {
+                values[i] = nil
+            }
+        }
+        return choices(env, args[0], values[0], values[1], values[2], k)
+    })
+    randomType.define("sample", func(env *Environment, args []Object, kwargs *Dict) Object {
+        values, err := parseArgs("sample", args[1:], kwargs, []string{"population", "k", "*counts"}, 2)
+        if err != nil {
+            return err
+        }
+        k, err := toIndex(env, values[1])
+        if err != nil {
+            return err
+        }
+        return sample(env, args[0], values[0], k, values[2])
+    })
+    randomType.method("shuffle", 1, 1, func(env *Environment, args []Object) Object {
+        return shuffle(env, args[0], args[1])
+    })
+    randomType.method("uniform", 2, 2, func(env *Environment, args []Object) Object {
+        r, err := randomFloat(env, args[0])
+        if err != nil {
+            return err
+        }
+        span := binaryOperation(env, "-", args[2], args[1])
+        if isError(span) {
+            return span
+        }
+        scaled := binaryOperation(env, "*", span, &Float{Value: r})
+        if isError(scaled) {
+            return scaled
+        }
+        return binaryOperation(env, "+", args[1], scaled)
+    })
+    randomType.define("gauss", func(env *Environment, args []Object, kwargs *Dict) Object {
+        values, err := parseArgs("gauss", args[1:], kwargs, []string{"mu", "sigma"}, 0)
+        if err != nil {
+            return err
+        }
+        mu, sigma := Object(&Float{Value: 0}), Object(&Float{Value: 1})
+        if values[0] != nil {
+            mu = values[0]
+        }
+        if values[1] != nil {
+            sigma = values[1]
+        }
+        return gauss(env, args[0], mu, sigma)
+    })
+    // gauss_next is writable, since Python code that mimics Random.__init__
+    // resets it by hand
+    randomType.property("gauss_next", func(self Object) Object {
+        return asRandom(self).gaussNext
+    })
+    gaussNext, _ := randomType.Dict.GetStr("gauss_next")
+    gaussNext.(*Property).Setter = &Builtin{Name: "gauss_next", Fn: func(env *Environment, args []Object, kwargs *Dict) Object {
+        asRandom(args[0]).gaussNext = args[1]
+        return NULL
+    }}
+}
+
+// initSystemRandom: same methods, no state, nothing to seed
+func initSystemRandom() {
+    systemRandomType.define("seed", func(env *Environment, args []Object, kwargs *Dict) Object {
+        return NULL
+    })
+    systemRandomType.define("getstate", func(env *Environment, args []Object, kwargs *Dict) Object {
+        return noState()
+    })
+    systemRandomType.define("setstate", func(env *Environment, args []Object, kwargs *Dict) Object {
+        return noState()
+    })
+    systemRandomType.method("randbytes", 1, 1, func(env *Environment, args []Object) Object {
+        n, err := randomCount(env, args[1], true)
+        if err != nil {
+            return err
+        }
+        if n < 0 {
+            return valueError("negative argument not allowed")
+        }
+        out := make([]byte, n)
+        rand.Read(out)
+        return &Bytes{Value: out}
+    })
+}
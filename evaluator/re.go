@@ -0,0 +1,993 @@
+// Comments in this file are inspired by Jonathan Sidwell - he finds the one clause that matters in a thousand pages
+
+package evaluator
+
+import (
+    "fmt"
+    "math"
+    "strconv"
+    "strings"
+)
+
+// re: compile, search and the rest, over the engine in regex.go. Patterns
+// and matches are Go values with classes of their own, the way files are
+
+var (
+    reErrorType   = newBuiltinClass("error", exceptionType)
+    patternType   = newBuiltinClass("Pattern", objectType)
+    matchType     = newBuiltinClass("Match", objectType)
+    regexFlagType = newBuiltinClass("RegexFlag", intType)
+)
+
+// RegexPattern is a compiled pattern
+type RegexPattern struct {
+    source  Object // the str or bytes it was compiled from
+    isBytes bool
+    re      *regex
+}
+
+func (p *RegexPattern) Type() ObjectType { return PATTERN_OBJ }
+
+// Inspect is re.compile(...) with the pattern's repr cut off at 200
+// characters, and UNICODE left unsaid for a str pattern, where it goes without saying
+func (p *RegexPattern) Inspect() string {
+    source := []rune(p.source.Inspect())
+    if len(source) > 200 {
+        source = source[:200]
+    }
+    flags := p.re.flags
+    if !p.isBytes && flags&(reLocale|reUnicode|reASCII) == reUnicode {
+        flags &^= reUnicode
+    }
+    if flags == 0 {
+        return "re.compile(" + string(source) + ")"
+    }
+    var names []string
+    for _, name := range []string{"TEMPLATE", "IGNORECASE", "LOCALE", "MULTILINE", "DOTALL", "UNICODE", "VERBOSE", "DEBUG", "ASCII"} {
+        if flag := regexFlagValues[name]; flags&flag != 0 {
+            names = append(names, "re."+name)
+            flags &^= flag
+        }
+    }
+    if flags != 0 {
+        names = append(names, fmt.Sprintf("0x%x", flags))
+    }
+    return "re.compile(" + string(source) + ", " + strings.Join(names, "|") + ")"
+}
+
+// RegexMatch is one successful match, and everything it knows about the groups
+type RegexMatch struct {
+    pattern   *RegexPattern
+    subject   Object
+    text      []rune
+    pos       int
+    endpos    int
+    spans     []int // start and end of each group, the whole match first; -1 if unmatched
+    lastIndex int
+}
+
+func (m *RegexMatch) Type() ObjectType { return MATCH_OBJ }
+
+func (m *RegexMatch) Inspect() string {
+    match := []rune(m.group(0, NULL).Inspect())
+    if len(match) > 50 {
+        match = match[:50]
+    }
+    return fmt.Sprintf("<re.Match object; span=(%d, %d), match=%s>", m.spans[0], m.spans[1], string(match))
+}
+
+// slice is text[start:end] as the kind of object the subject was
+func (p *RegexPattern) slice(text []rune, start, end int) Object {
+    if !p.isBytes {
+        return &String{Value: fromCodePoints(text[start:end])}
+    }
+    data := make([]byte, end-start)
+    for i, r := range text[start:end] {
+        data[i] = byte(r)
+    }
+    return &Bytes{Value: data}
+}
+
+func (m *RegexMatch) group(g int, def Object) Object {
+    if m.spans[2*g] < 0 {
+        return def
+    }
+    return m.pattern.slice(m.text, m.spans[2*g], m.spans[2*g+1])
+}
+
+// groupIndex is the group a number or name picks out
+func (m *RegexMatch) groupIndex(obj Object) (int, *Error) {
+    if n, ok := toBigInt(obj); ok {
+        if n.IsInt64() && n.Int64() >= 0 && n.Int64() <= int64(m.pattern.re.groups) {
+            return int(n.Int64()), nil
+        }
+    } else if name, ok := payload(obj).(*String); ok {
+        if g, ok := m.pattern.re.index[name.Value]; ok {
+            return g, nil
+        }
+    }
+    return 0, indexError("no such group")
+}
+
+// reText is the subject of a match one rune to a character, bytes included
+func (p *RegexPattern) reText(obj Object) ([]rune, *Error) {
+    if s, ok := payload(obj).(*String); ok {
+        if p.isBytes {
+            return nil, typeError("cannot use a bytes pattern on a string-like object")
+        }
+        return codePoints(s.Value), nil
+    }
+    data, ok, err := bytesLike(obj)
+    switch {
+    case !ok:
+        return nil, typeError("expected string or bytes-like object, got '%s'", typeName(obj))
+    case err != nil:
+        return nil, err
+    case !p.isBytes:
+        return nil, typeError("cannot use a string pattern on a bytes-like object")
+    }
+    text := make([]rune, len(data))
+    for i, c := range data {
+        text[i] = rune(c)
+    }
+    return text, nil
+}
+
+// scan finds every match from pos to endpos that finditer would. Since 3.7
+// a match right after an empty one has to move on before it can be empty too
+func (p *RegexPattern) scan(text []rune, pos, endpos int, found func(spans []int, lastIndex int) bool) {
+    mustAdvance := false
+    for pos <= endpos {
+        spans, lastIndex, ok := p.re.exec(text, pos, endpos, true, false, mustAdvance)
+        if !ok || !found(spans, lastIndex) {
+            return
+        }
+        mustAdvance = spans[1] == spans[0]
+        pos = spans[1]
+    }
+}
+
+func (p *RegexPattern) newMatch(subject Object, text []rune, pos, endpos int, spans []int, lastIndex int) *RegexMatch {
+    return &RegexMatch{pattern: p, subject: subject, text: text, pos: pos, endpos: endpos, spans: spans, lastIndex: lastIndex}
+}
+
+// bounds are the pos and endpos arguments, clipped to the subject the way sre clips them
+func bounds(env *Environment, text []rune, pos, endpos Object) (int, int, *Error) {
+    clip := func(obj Object, value int) (int, *Error) {
+        if obj == nil {
+            return value, nil
+        }
+        n, err := indexValue(env, obj)
+        if err != nil {
+            return 0, err
+        }
+        switch {
+        case n.Sign() < 0:
+            return 0, nil
+        case !n.IsInt64() || n.Int64() > int64(len(text)):
+            return len(text), nil
+        }
+        return int(n.Int64()), nil
+    }
+    start, err := clip(pos, 0)
+    if err != nil {
+        return 0, 0, err
+    }
+    end, err := clip(endpos, len(text))
+    return start, end, err
+}
+
+// joinPieces is "".join(pieces) - or b"".join, for a bytes subject - with
+// join's complaint about anything of the wrong kind
+func (p *RegexPattern) joinPieces(pieces []Object) Object {
+    if !p.isBytes {
+        var b strings.Builder
+        for i, piece := range pieces {
+            s, ok := payload(piece).(*String)
+            if !ok {
+                return typeError("sequence item %d: expected str instance, %s found", i, typeName(piece))
+            }
+            b.WriteString(s.Value)
+        }
+        return &String{Value: b.String()}
+    }
+    var data []byte
+    for i, piece := range pieces {
+        chunk, ok, err := bytesLike(piece)
+        if !ok {
+            return typeError("sequence item %d: expected a bytes-like object, %s found", i, typeName(piece))
+        }
+        if err != nil {
+            return err
+        }
+        data = append(data, chunk...)
+    }
+    return &Bytes{Value: data}
+}
+
+// reTemplatePiece is a stretch of literal text, or a group when group >= 0
+type reTemplatePiece struct {
+    literal Object
+    group   int
+}
+
+// parseTemplate reads a replacement string - \1, \g<name> and the escapes -
+// the way sre_parse.parse_template does
+func (p *RegexPattern) parseTemplate(repl Object) (pieces []reTemplatePiece, failure *Error) {
+    var src []rune
+    isBytes := false
+    if s, ok := payload(repl).(*String); ok {
+        src = codePoints(s.Value)
+    } else if data, ok, _ := bytesLike(repl); ok {
+        isBytes = true
+        for _, c := range data {
+            src = append(src, rune(c))
+        }
+    } else {
+        return nil, typeError("decoding to str: need a bytes-like object, %s found", typeName(repl))
+    }
+    defer func() {
+        if r := recover(); r != nil {
+            e, ok := r.(*reError)
+            if !ok {
+                panic(r)
+            }
+            pieces, failure = nil, reErrorObject(e, repl)
+        }
+    }()
+
+    s := &reParser{src: src, isBytes: isBytes}
+    s.advance()
+    var literal []rune
+    flush := func() {
+        if len(literal) == 0 {
+            return
+        }
+        var text Object = &String{Value: fromCodePoints(literal)}
+        if isBytes {
+            data := make([]byte, len(literal))
+            for i, r := range literal {
+                data[i] = byte(r)
+            }
+            text = &Bytes{Value: data}
+        }
+        pieces = append(pieces, reTemplatePiece{literal: text, group: -1})
+        literal = nil
+    }
+    addGroup := func(index, offset int) {
+        if index > p.re.groups {
+            s.fail(fmt.Sprintf("invalid group reference %d", index), offset)
+        }
+        flush()
+        pieces = append(pieces, reTemplatePiece{group: index})
+    }
+
+    for this := s.get(); this.ok; this = s.get() {
+        if !this.escaped {
+            literal = append(literal, this.c)
+            continue
+        }
+        c := this.c
+        switch {
+        case c == 'g':
+            if !s.match('<') {
+                s.fail("missing <", 0)
+            }
+            name := s.getuntil('>', "group name")
+            offset := codePointCount(name) + 1
+            var index int
+            if isIdentifier(name) {
+                g, ok := p.re.index[name]
+                if !ok {
+                    panic(&reError{kind: reIndex, msg: "unknown group name " + strRepr(name)})
+                }
+                index = g
+            } else {
+                n, err := strconv.ParseInt(strings.TrimPrefix(name, "+"), 10, 64)
+                if err != nil || n < 0 || strings.HasPrefix(name, "+-") {
+                    s.fail("bad character in group name "+strRepr(name), offset)
+                }
+                if n >= reMaxGroups {
+                    s.fail(fmt.Sprintf("invalid group reference %d", n), offset)
+                }
+                index = int(n)
+            }
+            addGroup(index, offset)
+        case c == '0':
+            digits := "0" + s.getwhile(2, reOctDigits)
+            n, _ := strconv.ParseInt(digits, 8, 32)
+            literal = append(literal, rune(n&0xff))
+        case isASCIIDigit(c):
+            digits := string(c)
+            octal := false
+            if s.next.in(reDigits) {
+                digits += string(s.get().c)
+                if isOctal(c) && isOctal(rune(digits[1])) && s.next.in(reOctDigits) {
+                    digits += string(s.get().c)
+                    octal = true
+                    n, _ := strconv.ParseInt(digits, 8, 32)
+                    if n > 0o377 {
+                        s.fail(fmt.Sprintf("octal escape value \\%s outside of range 0-0o377", digits), len(digits)+1)
+                    }
+                    literal = append(literal, rune(n))
+                }
+            }
+            if !octal {
+                index, _ := strconv.Atoi(digits)
+                addGroup(index, len(digits))
+            }
+        default:
+            if r, ok := reEscapes[c]; ok {
+                literal = append(literal, r)
+            } else if isASCIILetter(c) {
+                s.fail("bad escape "+this.String(), 2)
+            } else {
+                literal = append(literal, '\\', c)
+            }
+        }
+    }
+    flush()
+    return pieces, nil
+}
+
+// expand fills a parsed template in from the groups
+func (m *RegexMatch) expand(pieces []reTemplatePiece) Object {
+    parts := make([]Object, len(pieces))
+    empty := m.pattern.slice(m.text, 0, 0)
+    for i, piece := range pieces {
+        if piece.group < 0 {
+            parts[i] = piece.literal
+        } else {
+            parts[i] = m.group(piece.group, empty)
+        }
+    }
+    return m.pattern.joinPieces(parts)
+}
+
+// substitute is sub and subn: each of the first count matches (all of them
+// when count is 0) swapped for repl, a template or a function of the match
+func (p *RegexPattern) substitute(env *Environment, repl, subject Object, count int) (Object, int) {
+    text, err := p.reText(subject)
+    if err != nil {
+        return err, 0
+    }
+    var template []reTemplatePiece
+    literal := !isCallable(repl)
+    if literal {
+        if s, ok := payload(repl).(*String); !ok || strings.Contains(s.Value, "\\") {
+            if data, ok, _ := bytesLike(repl); !ok || strings.Contains(string(data), "\\") {
+                if template, err = p.parseTemplate(repl); err != nil {
+                    return err, 0
+                }
+                literal = false
+            }
+        }
+    }
+
+    var pieces []Object
+    var failure Object
+    last, n := 0, 0
+    p.scan(text, 0, len(text), func(spans []int, lastIndex int) bool {
+        if count != 0 && n >= count {
+            return false
+        }
+        if spans[0] > last {
+            pieces = append(pieces, p.slice(text, last, spans[0]))
+        }
+        var item Object
+        switch {
+        case literal:
+            item = repl
+        case template != nil:
+            item = p.newMatch(subject, text, 0, len(text), spans, lastIndex).expand(template)
+        default:
+            item = applyFunction(env, repl, []Object{p.newMatch(subject, text, 0, len(text), spans, lastIndex)}, nil)
+        }
+        if isError(item) {
+            failure = item
+            return false
+        }
+        if item != NULL {
+            pieces = append(pieces, item)
+        }
+        last = spans[1]
+        n++
+        return true
+    })
+    if failure != nil {
+        return failure, 0
+    }
+    if last < len(text) {
+        pieces = append(pieces, p.slice(text, last, len(text)))
+    }
+    return p.joinPieces(pieces), n
+}
+
+// split is re.split: the pieces between matches, and the groups of each match between them
+func (p *RegexPattern) split(subject Object, maxsplit int) Object {
+    text, err := p.reText(subject)
+    if err != nil {
+        return err
+    }
+    var parts []Object
+    last, n := 0, 0
+    p.scan(text, 0, len(text), func(spans []int, lastIndex int) bool {
+        if maxsplit != 0 && n >= maxsplit {
+            return false
+        }
+        parts = append(parts, p.slice(text, last, spans[0]))
+        for g := 1; g <= p.re.groups; g++ {
+            if spans[2*g] < 0 {
+                parts = append(parts, NULL)
+            } else {
+                parts = append(parts, p.slice(text, spans[2*g], spans[2*g+1]))
+            }
+        }
+        last = spans[1]
+        n++
+        return true
+    })
+    parts = append(parts, p.slice(text, last, len(text)))
+    return &List{Elements: parts}
+}
+
+// findall is every match - or its group, or a tuple of its groups, when it has them
+func (p *RegexPattern) findall(text []rune, pos, endpos int) Object {
+    var found []Object
+    empty := p.slice(text, 0, 0)
+    p.scan(text, pos, endpos, func(spans []int, lastIndex int) bool {
+        groups := make([]Object, p.re.groups+1)
+        for g := range groups {
+            groups[g] = empty
+            if spans[2*g] >= 0 {
+                groups[g] = p.slice(text, spans[2*g], spans[2*g+1])
+            }
+        }
+        switch p.re.groups {
+        case 0:
+            found = append(found, groups[0])
+        case 1:
+            found = append(found, groups[1])
+        default:
+            found = append(found, &Tuple{Elements: groups[1:]})
+        }
+        return true
+    })
+    return &List{Elements: found}
+}
+
+func (p *RegexPattern) finditer(subject Object, text []rune, pos, endpos int) Object {
+    mustAdvance := false
+    return newIterator(callableIteratorType, func(env *Environment) (Object, bool) {
+        if pos > endpos {
+            return nil, false
+        }
+        spans, lastIndex, ok := p.re.exec(text, pos, endpos, true, false, mustAdvance)
+        if !ok {
+            return nil, false
+        }
+        mustAdvance = spans[1] == spans[0]
+        pos = spans[1]
+        return p.newMatch(subject, text, 0, len(text), spans, lastIndex), true
+    })
+}
+
+// reErrorObject is a pattern's problem as the exception Python would raise.
+// pattern is the source it was found in
+func reErrorObject(e *reError, pattern Object) *Error {
+    switch e.kind {
+    case reValue:
+        return valueError("%s", e.msg)
+    case reOverflow:
+        return overflowError("%s", e.msg)
+    case reIndex:
+        return indexError("%s", e.msg)
+    }
+    exc := newException(reErrorType)
+    pos := Object(NULL)
+    if e.pos >= 0 {
+        pos = newInt(int64(e.pos))
+    }
+    fillReError(exc, &String{Value: e.msg}, pattern, pos)
+    return &Error{Exception: exc}
+}
+
+// fillReError is re.error's __init__: the message gets the position, and
+// the line and column too when the pattern runs over more than one line
+func fillReError(e *Exception, msg, pattern, pos Object) {
+    e.Fields["msg"], e.Fields["pattern"], e.Fields["pos"] = msg, pattern, pos
+    e.Fields["lineno"], e.Fields["colno"] = NULL, NULL
+    text := msg.Inspect()
+    if s, ok := msg.(*String); ok {
+        text = s.Value
+    }
+    n, isInt := toBigInt(pos)
+    var source []rune
+    if s, ok := payload(pattern).(*String); ok {
+        source = codePoints(s.Value)
+    } else if data, ok, _ := bytesLike(pattern); ok {
+        for _, c := range data {
+            source = append(source, rune(c))
+        }
+    } else {
+        isInt = false
+    }
+    if isInt && n.IsInt64() {
+        at := int(min(max(n.Int64(), 0), int64(len(source))))
+        lineno, colno := 1, at+1
+        for i, c := range source[:at] {
+            if c == '\n' {
+                lineno, colno = lineno+1, at-i
+            }
+        }
+        e.Fields["lineno"], e.Fields["colno"] = newInt(int64(lineno)), newInt(int64(colno))
+        text = fmt.Sprintf("%s at position %d", text, n.Int64())
+        if containsRune(source, '\n') {
+            text = fmt.Sprintf("%s (line %d, column %d)", text, lineno, colno)
+        }
+    }
+    e.Args = &Tuple{Elements: []Object{&String{Value: text}}}
+}
+
+// regexFlagValues are re's flags by name, and regexFlagOrder the order
+// RegexFlag lists them in
+var regexFlagValues = map[string]int{
+    "ASCII": reASCII, "IGNORECASE": reIgnoreCase, "LOCALE": reLocale, "UNICODE": reUnicode,
+    "MULTILINE": reMultiline, "DOTALL": reDotAll, "VERBOSE": reVerbose, "TEMPLATE": reTemplate, "DEBUG": reDebug,
+}
+
+var regexFlagOrder = []string{"ASCII", "IGNORECASE", "LOCALE", "UNICODE", "MULTILINE", "DOTALL", "VERBOSE", "TEMPLATE", "DEBUG"}
+
+func newRegexFlag(value int64) Object {
+    return wrapBuiltinValue(regexFlagType, intType, newInt(value))
+}
+
+func regexFlagRepr(obj Object) string {
+    n, _ := toBigInt(obj)
+    value := n.Int64()
+    if value == 0 {
+        return "re.NOFLAG"
+    }
+    var names []string
+    for _, name := range regexFlagOrder {
+        if flag := int64(regexFlagValues[name]); value&flag != 0 {
+            names = append(names, "re."+name)
+            value &^= flag
+        }
+    }
+    if value != 0 {
+        names = append(names, fmt.Sprintf("0x%x", value))
+    }
+    return strings.Join(names, "|")
+}
+
+func init() {
+    registerModule("re", buildRe)
+
+    reErrorType.Dict.SetStr("__module__", &String{Value: "re"})
+    exceptionFields[reErrorType] = []string{"msg", "pattern", "pos", "lineno", "colno"}
+    reErrorType.define("__init__", func(env *Environment, args []Object, kwargs *Dict) Object {
+        values, err := parseArgs("error", args[1:], kwargs, []string{"msg", "pattern", "pos"}, 1)
+        if err != nil {
+            return err
+        }
+        for i := range values {
+            if values[i] == nil {
+                values[i] = NULL
+            }
+        }
+        fillReError(asException(args[0]), values[0], values[1], values[2])
+        return NULL
+    })
+
+    regexFlagType.Dict.SetStr("__module__", &String{Value: "re"})
+    regexFlagType.method("__repr__", 0, 0, func(env *Environment, args []Object) Object {
+        return &String{Value: regexFlagRepr(args[0])}
+    })
+    regexFlagType.method("__str__", 0, 0, func(env *Environment, args []Object) Object {
+        return &String{Value: regexFlagRepr(args[0])}
+    })
+    for _, op := range []struct {
+        names []string
+        fn    func(a, b int64) int64
+    }{
+        {[]string{"__or__", "__ror__"}, func(a, b int64) int64 { return a | b }},
+        {[]string{"__and__", "__rand__"}, func(a, b int64) int64 { return a & b }},
+        {[]string{"__xor__", "__rxor__"}, func(a, b int64) int64 { return a ^ b }},
+    } {
+        fn := op.fn
+        for _, name := range op.names {
+            regexFlagType.method(name, 1, 1, func(env *Environment, args []Object) Object {
+                a, _ := toBigInt(args[0])
+                b, ok := toBigInt(args[1])
+                if !ok || !b.IsInt64() {
+                    return NotImplemented
+                }
+                return newRegexFlag(fn(a.Int64(), b.Int64()))
+            })
+        }
+    }
+    regexFlagType.method("__invert__", 0, 0, func(env *Environment, args []Object) Object {
+        n, _ := toBigInt(args[0])
+        all := int64(0)
+        for _, flag := range regexFlagValues {
+            all |= int64(flag)
+        }
+        return newRegexFlag(^n.Int64() & all)
+    })
+
+    initPattern()
+    initMatch()
+}
+
+// patternArgs reads the (string, pos, endpos) every search method takes
+func patternArgs(env *Environment, name string, args []Object, kwargs *Dict) (*RegexPattern, Object, []rune, int, int, *Error) {
+    p := args[0].(*RegexPattern)
+    values, err := parseArgs(name, args[1:], kwargs, []string{"string", "pos", "endpos"}, 1)
+    if err != nil {
+        return nil, nil, nil, 0, 0, err
+    }
+    text, err := p.reText(values[0])
+    if err != nil {
+        return nil, nil, nil, 0, 0, err
+    }
+    pos, endpos, err := bounds(env, text, values[1], values[2])
+    return p, values[0], text, pos, endpos, err
+}
+
+func initPattern() {
+    patternType.Dict.SetStr("__module__", &String{Value: "re"})
+    for _, mode := range []struct {
+        name         string
+        search, full bool
+    }{{"match", false, false}, {"fullmatch", false, true}, {"search", true, false}} {
+        mode := mode
+        patternType.define(mode.name, func(env *Environment, args []Object, kwargs *Dict) Object {
+            p, subject, text, pos, endpos, err := patternArgs(env, mode.name, args, kwargs)
+            if err != nil {
+                return err
+            }
+            spans, lastIndex, ok := p.re.exec(text, pos, endpos, mode.search, mode.full, false)
+            if !ok {
+                return NULL
+            }
+            return p.newMatch(subject, text, pos, endpos, spans, lastIndex)
+        })
+    }
+    patternType.define("findall", func(env *Environment, args []Object, kwargs *Dict) Object {
+        p, _, text, pos, endpos, err := patternArgs(env, "findall", args, kwargs)
+        if err != nil {
+            return err
+        }
+        return p.findall(text, pos, endpos)
+    })
+    patternType.define("finditer", func(env *Environment, args []Object, kwargs *Dict) Object {
+        p, subject, text, pos, endpos, err := patternArgs(env, "finditer", args, kwargs)
+        if err != nil {
+            return err
+        }
+        return p.finditer(subject, text, pos, endpos)
+    })
+    patternType.define("split", func(env *Environment, args []Object, kwargs *Dict) Object {
+        values, err := parseArgs("split", args[1:], kwargs, []string{"string", "maxsplit"}, 1)
+        if err != nil {
+            return err
+        }
+        maxsplit := 0
+        if values[1] != nil {
+            if maxsplit, err = toIndex(env, values[1]); err != nil {
+                return err
+            }
+        }
+        return args[0].(*RegexPattern).split(values[0], maxsplit)
+    })
+    for _, name := range []string{"sub", "subn"} {
+        name := name
+        patternType.define(name, func(env *Environment, args []Object, kwargs *Dict) Object {
+            values, err := parseArgs(name, args[1:], kwargs, []string{"repl", "string", "count"}, 2)
+            if err != nil {
+                return err
+            }
+            count := 0
+            if values[2] != nil {
+                if count, err = toIndex(env, values[2]); err != nil {
+                    return err
+                }
+            }
+            result, n := args[0].(*RegexPattern).substitute(env, values[0], values[1], count)
+            if isError(result) || name == "sub" {
+                return result
+            }
+            return &Tuple{Elements: []Object{result, newInt(int64(n))}}
+        })
+    }
+    patternType.method("__repr__", 0, 0, func(env *Environment, args []Object) Object {
+        return &String{Value: args[0].Inspect()}
+    })
+    patternType.method("__eq__", 1, 1, func(env *Environment, args []Object) Object {
+        p := args[0].(*RegexPattern)
+        q, ok := args[1].(*RegexPattern)
+        if !ok {
+            return NotImplemented
+        }
+        return nativeBool(p.isBytes == q.isBytes && p.re.flags == q.re.flags && p.source.Inspect() == q.source.Inspect())
+    })
+    patternType.method("__hash__", 0, 0, func(env *Environment, args []Object) Object {
+        p := args[0].(*RegexPattern)
+        return newInt(hashString(p.source.Inspect()) ^ int64(p.re.flags))
+    })
+    patternType.property("pattern", func(self Object) Object { return self.(*RegexPattern).source })
+    patternType.property("flags", func(self Object) Object { return newInt(int64(self.(*RegexPattern).re.flags)) })
+    patternType.property("groups", func(self Object) Object { return newInt(int64(self.(*RegexPattern).re.groups)) })
+    patternType.property("groupindex", func(self Object) Object {
+        re := self.(*RegexPattern).re
+        index := NewDict()
+        for g, name := range re.names {
+            if name != "" {
+                index.SetStr(name, newInt(int64(g)))
+            }
+        }
+        return index
+    })
+}
+
+func initMatch() {
+    matchType.Dict.SetStr("__module__", &String{Value: "re"})
+    group := func(env *Environment, m *RegexMatch, obj Object) Object {
+        g, err := m.groupIndex(obj)
+        if err != nil {
+            return err
+        }
+        return m.group(g, NULL)
+    }
+    matchType.method("group", 0, -1, func(env *Environment, args []Object) Object {
+        m := args[0].(*RegexMatch)
+        switch len(args) {
+        case 1:
+            return m.group(0, NULL)
+        case 2:
+            return group(env, m, args[1])
+        }
+        groups := make([]Object, len(args)-1)
+        for i, arg := range args[1:] {
+            if groups[i] = group(env, m, arg); isError(groups[i]) {
+                return groups[i]
+            }
+        }
+        return &Tuple{Elements: groups}
+    })
+    matchType.method("__repr__", 0, 0, func(env *Environment, args []Object) Object {
+        return &String{Value: args[0].Inspect()}
+    })
+    matchType.method("__getitem__", 1, 1, func(env *Environment, args []Object) Object {
+        return group(env, args[0].(*RegexMatch), args[1])
+    })
+    matchType.define("groups", func(env *Environment, args []Object, kwargs *Dict) Object {
+        values, err := parseArgs("groups", args[1:], kwargs, []string{"default"}, 0)
+        if err != nil {
+            return err
+        }
+        m, def := args[0].(*RegexMatch), values[0]
+        if def == nil {
+            def = NULL
+        }
+        groups := make([]Object, m.pattern.re.groups)
+        for i := range groups {
+            groups[i] = m.group(i+1, def)
+        }
+        return &Tuple{Elements: groups}
+    })
+    matchType.define("groupdict", func(env *Environment, args []Object, kwargs *Dict) Object {
+        values, err := parseArgs("groupdict", args[1:], kwargs, []string{"default"}, 0)
+        if err != nil {
+            return err
+        }
+        m, def := args[0].(*RegexMatch), values[0]
+        if def == nil {
+            def = NULL
+        }
+        groups := NewDict()
+        for g, name := range m.pattern.re.names {
+            if name != "" {
+                groups.SetStr(name, m.group(g, def))
+            }
+        }
+        return groups
+    })
+    span := func(name string, pick func(start, end int) Object) {
+        matchType.method(name, 0, 1, func(env *Environment, args []Object) Object {
+            m, g := args[0].(*RegexMatch), 0
+            if len(args) == 2 {
+                var err *Error
+                if g, err = m.groupIndex(args[1]); err != nil {
+                    return err
+                }
+            }
+            return pick(m.spans[2*g], m.spans[2*g+1])
+        })
+    }
+    span("start", func(start, end int) Object { return newInt(int64(start)) })
+    span("end", func(start, end int) Object { return newInt(int64(end)) })
+    span("span", func(start, end int) Object {
+        return &Tuple{Elements: []Object{newInt(int64(start)), newInt(int64(end))}}
+    })
+    matchType.method("expand", 1, 1, func(env *Environment, args []Object) Object {
+        m := args[0].(*RegexMatch)
+        template, err := m.pattern.parseTemplate(args[1])
+        if err != nil {
+            return err
+        }
+        return m.expand(template)
+    })
+    matchType.property("string", func(self Object) Object { return self.(*RegexMatch).subject })
+    matchType.property("re", func(self Object) Object { return self.(*RegexMatch).pattern })
+    matchType.property("pos", func(self Object) Object { return newInt(int64(self.(*RegexMatch).pos)) })
+    matchType.property("endpos", func(self Object) Object { return newInt(int64(self.(*RegexMatch).endpos)) })
+    matchType.property("lastindex", func(self Object) Object {
+        if m := self.(*RegexMatch); m.lastIndex >= 0 {
+            return newInt(int64(m.lastIndex))
+        }
+        return NULL
+    })
+    matchType.property("lastgroup", func(self Object) Object {
+        if m := self.(*RegexMatch); m.lastIndex >= 0 && m.pattern.re.names[m.lastIndex] != "" {
+            return &String{Value: m.pattern.re.names[m.lastIndex]}
+        }
+        return NULL
+    })
+    matchType.property("regs", func(self Object) Object {
+        m := self.(*RegexMatch)
+        regs := make([]Object, len(m.spans)/2)
+        for g := range regs {
+            regs[g] = &Tuple{Elements: []Object{newInt(int64(m.spans[2*g])), newInt(int64(m.spans[2*g+1]))}}
+        }
+        return &Tuple{Elements: regs}
+    })
+}
+
+// reCacheKey is how re remembers patterns it has compiled before
+type reCacheKey struct {
+    isBytes bool
+    source  string
+    flags   int
+}
+
+const reMaxCache = 512
+
+func buildRe(m *Module) {
+    cache := map[reCacheKey]*RegexPattern{}
+
+    compile := func(env *Environment, pattern, flagsArg Object) (*RegexPattern, *Error) {
+        flags := 0
+        if flagsArg != nil {
+            n, ok := toBigInt(flagsArg)
+            if !ok {
+                return nil, typeError("unsupported operand type(s) for &: '%s' and 'RegexFlag'", typeName(flagsArg))
+            }
+            if !n.IsInt64() || n.Int64() > math.MaxInt32 || n.Int64() < math.MinInt32 {
+                return nil, overflowError("Python int too large to convert to C int")
+            }
+            flags = int(n.Int64())
+        }
+        if p, ok := pattern.(*RegexPattern); ok {
+            if flags != 0 {
+                return nil, valueError("cannot process flags argument with a compiled pattern")
+            }
+            return p, nil
+        }
+        var key reCacheKey
+        var src []rune
+        if s, ok := payload(pattern).(*String); ok {
+            key, src = reCacheKey{source: s.Value, flags: flags}, codePoints(s.Value)
+        } else if b, ok := payload(pattern).(*Bytes); ok {
+            key = reCacheKey{isBytes: true, source: string(b.Value), flags: flags}
+            for _, c := range b.Value {
+                src = append(src, rune(c))
+            }
+        } else {
+            return nil, typeError("first argument must be string or compiled pattern")
+        }
+        if p, ok := cache[key]; ok {
+            return p, nil
+        }
+        re, e := compileRegex(src, key.isBytes, flags)
+        if e != nil {
+            return nil, reErrorObject(e, pattern)
+        }
+        p := &RegexPattern{source: pattern, isBytes: key.isBytes, re: re}
+        if len(cache) >= reMaxCache {
+            clear(cache)
+        }
+        cache[key] = p
+        return p, nil
+    }
+
+    m.Env.Set("error", reErrorType)
+    m.Env.Set("Pattern", patternType)
+    m.Env.Set("Match", matchType)
+    m.Env.Set("RegexFlag", regexFlagType)
+    for name, value := range regexFlagValues {
+        m.Env.Set(name, newRegexFlag(int64(value)))
+    }
+    for short, name := range map[string]string{"A": "ASCII", "I": "IGNORECASE", "L": "LOCALE", "U": "UNICODE",
+        "M": "MULTILINE", "S": "DOTALL", "X": "VERBOSE", "T": "TEMPLATE"} {
+        m.Env.Set(short, newRegexFlag(int64(regexFlagValues[name])))
+    }
+    m.Env.Set("NOFLAG", newRegexFlag(0))
+
+    m.function("compile", func(env *Environment, args []Object, kwargs *Dict) Object {
+        values, err := parseArgs("compile", args, kwargs, []string{"pattern", "flags"}, 1)
+        if err != nil {
+            return err
+        }
+        p, err := compile(env, values[0], values[1])
+        if err != nil {
+            return err
+        }
+        return p
+    })
+    m.function("purge", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("purge", args, kwargs, 0, 0); err != nil {
+            return err
+        }
+        clear(cache)
+        return NULL
+    })
+
+    // the rest compile the pattern and hand over to the Pattern method of the same name
+    forward := func(name string, params []string, required int) {
+        method, _ := patternType.Dict.GetStr(name)
+        m.function(name, func(env *Environment, args []Object, kwargs *Dict) Object {
+            values, err := parseArgs(name, args, kwargs, append(params, "flags"), required)
+            if err != nil {
+                return err
+            }
+            p, err := compile(env, values[0], values[len(values)-1])
+            if err != nil {
+                return err
+            }
+            forwarded := []Object{p}
+            for _, value := range values[1 : len(values)-1] {
+                if value != nil {
+                    forwarded = append(forwarded, value)
+                }
+            }
+            return applyFunction(env, method, forwarded, nil)
+        })
+    }
+    for _, name := range []string{"match", "fullmatch", "search", "findall", "finditer"} {
+        forward(name, []string{"pattern", "string"}, 2)
+    }
+    forward("split", []string{"pattern", "string", "maxsplit"}, 2)
+    forward("sub", []string{"pattern", "repl", "string", "count"}, 3)
+    forward("subn", []string{"pattern", "repl", "string", "count"}, 3)
+
+    m.function("escape", func(env *Environment, args []Object, kwargs *Dict) Object {
+        values, err := parseArgs("escape", args, kwargs, []string{"pattern"}, 1)
+        if err != nil {
+            return err
+        }
+        const special = "()[]{}?*+-|^$\\.&~# \t\n\r\v\f"
+        switch pattern := payload(values[0]).(type) {
+        case *String:
+            var b strings.Builder
+            for _, r := range codePoints(pattern.Value) {
+                if strings.ContainsRune(special, r) {
+                    b.WriteByte('\\')
+                }
+                writeCodePoint(&b, r)
+            }
+            return &String{Value: b.String()}
+        case *Bytes:
+            var data []byte
+            for _, c := range pattern.Value {
+                if c < 0x80 && strings.IndexByte(special, c) >= 0 {
+                    data = append(data, '\\')
+                }
+                data = append(data, c)
+            }
+            return &Bytes{Value: data}
+        }
+        return newErrorKind(attributeErrorType, "'%s' object has no attribute 'translate'", typeName(values[0]))
+    })
+}
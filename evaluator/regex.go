@@ -0,0 +1,1519 @@
+// Comments in this file are inspired by Cameron Dennis - he'd try every road to a verdict, and back out of each one that failed
+
+package evaluator
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+    "unicode"
+)
+
+// The engine behind the re module. Go's regexp is RE2, which won't hear of
+// backreferences or lookaround, so patterns are parsed the way sre_parse
+// parses them - same errors, same positions - and matched by backtracking,
+// one continuation at a time, the way sre walks its opcodes
+
+// The flags, numbered as sre numbers them
+const (
+    reTemplate   = 1
+    reIgnoreCase = 2
+    reLocale     = 4
+    reMultiline  = 8
+    reDotAll     = 16
+    reUnicode    = 32
+    reVerbose    = 64
+    reDebug      = 128
+    reASCII      = 256
+
+    reTypeFlags   = reASCII | reLocale | reUnicode
+    reGlobalFlags = reDebug | reTemplate
+
+    reMaxRepeat = 1<<32 - 1
+    reMaxGroups = 1<<30 - 1
+)
+
+var reFlagLetters = map[rune]int{
+    'i': reIgnoreCase, 'L': reLocale, 'm': reMultiline, 's': reDotAll,
+    'x': reVerbose, 'a': reASCII, 't': reTemplate, 'u': reUnicode,
+}
+
+// reErrorKind says which exception a bad pattern turns into
+type reErrorKind int
+
+const (
+    reSyntax   reErrorKind = iota // re.error
+    reValue                       // ValueError, for flags that don't go together
+    reOverflow                    // OverflowError, for repeat counts sre can't store
+    reIndex                       // IndexError, for a template naming a group that isn't there
+)
+
+// reError is what's wrong with a pattern, and where; pos is -1 when the
+// complaint is about the pattern as a whole
+type reError struct {
+    kind reErrorKind
+    msg  string
+    pos  int
+}
+
+// reToken is one step of the pattern: a character, or a backslash and the
+// character after it. ok is false at the end of the pattern
+type reToken struct {
+    c       rune
+    escaped bool
+    ok      bool
+}
+
+func (t reToken) is(c rune) bool { return t.ok && !t.escaped && t.c == c }
+
+// in is sre_parse's `this in chars`, which an escape never is
+func (t reToken) in(chars string) bool { return t.ok && !t.escaped && strings.ContainsRune(chars, t.c) }
+
+func (t reToken) width() int {
+    switch {
+    case !t.ok:
+        return 0
+    case t.escaped:
+        return 2
+    }
+    return 1
+}
+
+func (t reToken) String() string {
+    if t.escaped {
+        return "\\" + string(t.c)
+    }
+    return string(t.c)
+}
+
+// The opcodes of a parsed pattern, before the flags are known
+type reOp int
+
+const (
+    reOpLiteral reOp = iota
+    reOpAny
+    reOpIn
+    reOpAt
+    reOpBranch
+    reOpSubpattern
+    reOpMaxRepeat
+    reOpMinRepeat
+    reOpPossessiveRepeat
+    reOpAssert
+    reOpAssertNot
+    reOpAtomic
+    reOpGroupRef
+    reOpGroupRefExists
+)
+
+// reItem is one opcode of the parsed pattern with whatever it needs
+type reItem struct {
+    op       reOp
+    c        rune        // reOpLiteral; reOpAt's kind: ^ $ A Z b B
+    set      []reSetItem // reOpIn
+    negate   bool        // reOpIn
+    group    int         // the group a subpattern captures (-1 for none) or a reference names
+    addFlags int         // reOpSubpattern
+    delFlags int         // reOpSubpattern
+    body     []*reItem   // subpatterns, repeats, lookarounds; the yes branch of a conditional
+    no       []*reItem   // the no branch of a conditional
+    branches [][]*reItem // reOpBranch
+    min, max int64       // repeats
+    behind   bool        // lookbehind
+}
+
+func isRepeatOp(op reOp) bool {
+    return op == reOpMaxRepeat || op == reOpMinRepeat || op == reOpPossessiveRepeat
+}
+
+// reSetItem is one member of a character set: a range of characters
+// (a literal is a range of one), or a category like \d when cat is set
+type reSetItem struct {
+    lo, hi rune
+    cat    rune
+}
+
+// reParser is sre_parse's Tokenizer and State rolled into one
+type reParser struct {
+    src     []rune
+    isBytes bool
+    index   int
+    next    reToken
+
+    flags            int
+    names            map[string]int
+    widths           [][2]int64 // each group's width, once it's closed
+    closed           []bool
+    lookbehindGroups int // the first group opened inside a lookbehind, -1 outside one
+    grouprefpos      map[int]int
+}
+
+// fail abandons the parse; compileRegex recovers it
+func (p *reParser) fail(msg string, offset int) {
+    panic(&reError{kind: reSyntax, msg: p.message(msg), pos: p.tell() - offset})
+}
+
+// message is msg as a bytes pattern tells it, with anything past ASCII escaped
+func (p *reParser) message(msg string) string {
+    if !p.isBytes {
+        return msg
+    }
+    var b strings.Builder
+    for _, r := range msg {
+        if r < 0x80 {
+            b.WriteRune(r)
+        } else {
+            fmt.Fprintf(&b, "\\x%02x", r)
+        }
+    }
+    return b.String()
+}
+
+func (p *reParser) advance() {
+    i := p.index
+    if i >= len(p.src) {
+        p.next = reToken{}
+        return
+    }
+    p.next = reToken{c: p.src[i], ok: true}
+    if p.src[i] == '\\' {
+        i++
+        if i >= len(p.src) {
+            panic(&reError{kind: reSyntax, msg: "bad escape (end of pattern)", pos: len(p.src) - 1})
+        }
+        p.next = reToken{c: p.src[i], escaped: true, ok: true}
+    }
+    p.index = i + 1
+}
+
+func (p *reParser) tell() int { return p.index - p.next.width() }
+
+func (p *reParser) seek(index int) {
+    p.index = index
+    p.advance()
+}
+
+func (p *reParser) get() reToken {
+    t := p.next
+    p.advance()
+    return t
+}
+
+func (p *reParser) match(c rune) bool {
+    if p.next.is(c) {
+        p.advance()
+        return true
+    }
+    return false
+}
+
+// getwhile takes up to n characters out of chars, or as many as there are when n is -1
+func (p *reParser) getwhile(n int, chars string) string {
+    var b strings.Builder
+    for ; n != 0 && p.next.in(chars); n-- {
+        b.WriteRune(p.get().c)
+    }
+    return b.String()
+}
+
+// getuntil reads a name up to its terminator
+func (p *reParser) getuntil(terminator rune, name string) string {
+    var b strings.Builder
+    length := 0
+    for {
+        c := p.get()
+        switch {
+        case !c.ok && length == 0:
+            p.fail("missing "+name, 0)
+        case !c.ok:
+            p.fail(fmt.Sprintf("missing %c, unterminated name", terminator), length)
+        case c.is(terminator):
+            if length == 0 {
+                p.fail("missing "+name, 1)
+            }
+            return b.String()
+        }
+        b.WriteString(c.String())
+        length += c.width()
+    }
+}
+
+func (p *reParser) checkGroupName(name string, offset int) {
+    if !isIdentifier(name) {
+        p.fail(fmt.Sprintf("bad character in group name %s", strRepr(name)), codePointCount(name)+offset)
+    }
+}
+
+func (p *reParser) groups() int { return len(p.widths) }
+
+func (p *reParser) checkGroup(gid int) bool { return gid < p.groups() && p.closed[gid] }
+
+func (p *reParser) checkLookbehindGroup(gid int) {
+    if p.lookbehindGroups < 0 {
+        return
+    }
+    if !p.checkGroup(gid) {
+        p.fail("cannot refer to an open group", 0)
+    }
+    if gid >= p.lookbehindGroups {
+        p.fail("cannot refer to group defined in the same lookbehind subpattern", 0)
+    }
+}
+
+func (p *reParser) openGroup(name string, named bool) int {
+    gid := p.groups()
+    p.widths = append(p.widths, [2]int64{})
+    p.closed = append(p.closed, false)
+    if gid >= reMaxGroups {
+        p.fail("too many groups", codePointCount(name)+1)
+    }
+    if named {
+        if old, ok := p.names[name]; ok {
+            p.fail(fmt.Sprintf("redefinition of group name %s as group %d; was group %d", strRepr(name), gid, old), codePointCount(name)+1)
+        }
+        p.names[name] = gid
+    }
+    return gid
+}
+
+func (p *reParser) closeGroup(gid int, body []*reItem) {
+    lo, hi := p.width(body)
+    p.widths[gid] = [2]int64{lo, hi}
+    p.closed[gid] = true
+}
+
+// width is the fewest and most characters items can match, as getwidth counts them
+func (p *reParser) width(items []*reItem) (int64, int64) {
+    add := func(a, b int64) int64 { return min(a+b, reMaxRepeat) }
+    mul := func(a, b int64) int64 {
+        if a != 0 && b > reMaxRepeat/a {
+            return reMaxRepeat
+        }
+        return a * b
+    }
+    var lo, hi int64
+    for _, item := range items {
+        var i, j int64
+        switch item.op {
+        case reOpBranch:
+            i = reMaxRepeat - 1
+            for _, branch := range item.branches {
+                l, h := p.width(branch)
+                i, j = min(i, l), max(j, h)
+            }
+        case reOpAtomic, reOpSubpattern:
+            i, j = p.width(item.body)
+        case reOpMaxRepeat, reOpMinRepeat, reOpPossessiveRepeat:
+            i, j = p.width(item.body)
+            i, j = mul(i, item.min), mul(j, item.max)
+        case reOpLiteral, reOpAny, reOpIn:
+            i, j = 1, 1
+        case reOpGroupRef:
+            i, j = p.widths[item.group][0], p.widths[item.group][1]
+        case reOpGroupRefExists:
+            i, j = p.width(item.body)
+            if item.no != nil {
+                l, h := p.width(item.no)
+                i, j = min(i, l), max(j, h)
+            } else {
+                i = 0
+            }
+        }
+        lo, hi = add(lo, i), add(hi, j)
+    }
+    return min(lo, reMaxRepeat-1), min(hi, reMaxRepeat)
+}
+
+func isOctal(c rune) bool { return c >= '0' && c <= '7' }
+func isASCIIDigit(c rune) bool { return c >= '0' && c <= '9' }
+func isASCIILetter(c rune) bool { return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' }
+
+const (
+    reDigits    = "0123456789"
+    reOctDigits = "01234567"
+    reHexDigits = "0123456789abcdefABCDEF"
+)
+
+var reEscapes = map[rune]rune{'a': '\a', 'b': '\b', 'f': '\f', 'n': '\n', 'r': '\r', 't': '\t', 'v': '\v', '\\': '\\'}
+
+// unicodeEscape is \x, \u, \U or \N - the escapes that spell out a code
+// point - or ok is false when c isn't one of them
+func (p *reParser) unicodeEscape(c rune) (value rune, ok bool) {
+    escape := "\\" + string(c)
+    digits := map[rune]int{'x': 2, 'u': 4, 'U': 8}[c]
+    switch {
+    case c == 'x' || (c == 'u' || c == 'U') && !p.isBytes:
+        escape += p.getwhile(digits, reHexDigits)
+        if len(escape) != digits+2 {
+            p.fail("incomplete escape "+escape, len(escape))
+        }
+        n, _ := strconv.ParseInt(escape[2:], 16, 64)
+        if n > unicode.MaxRune {
+            p.fail("bad escape "+escape, len(escape))
+        }
+        return rune(n), true
+    case c == 'N' && !p.isBytes:
+        if !p.match('{') {
+            p.fail("missing {", 0)
+        }
+        name := p.getuntil('}', "character name")
+        p.fail("undefined character name "+strRepr(name), codePointCount(name)+4)
+    }
+    return 0, false
+}
+
+// classEscape is a backslash inside a [set]
+func (p *reParser) classEscape(t reToken) reSetItem {
+    c := t.c
+    if r, ok := reEscapes[c]; ok {
+        return reSetItem{lo: r, hi: r}
+    }
+    if strings.ContainsRune("dDsSwW", c) {
+        return reSetItem{cat: c}
+    }
+    if r, ok := p.unicodeEscape(c); ok {
+        return reSetItem{lo: r, hi: r}
+    }
+    if isOctal(c) {
+        escape := "\\" + string(c) + p.getwhile(2, reOctDigits)
+        n, _ := strconv.ParseInt(escape[1:], 8, 32)
+        if n > 0o377 {
+            p.fail(fmt.Sprintf("octal escape value %s outside of range 0-0o377", escape), len(escape))
+        }
+        return reSetItem{lo: rune(n), hi: rune(n)}
+    }
+    if isASCIIDigit(c) || isASCIILetter(c) {
+        p.fail("bad escape "+t.String(), 2)
+    }
+    return reSetItem{lo: c, hi: c}
+}
+
+// escape is a backslash anywhere else
+func (p *reParser) escape(t reToken) *reItem {
+    c := t.c
+    switch c {
+    case 'A', 'Z', 'b', 'B':
+        return &reItem{op: reOpAt, c: c}
+    case 'd', 'D', 's', 'S', 'w', 'W':
+        return &reItem{op: reOpIn, set: []reSetItem{{cat: c}}}
+    }
+    if r, ok := reEscapes[c]; ok {
+        return &reItem{op: reOpLiteral, c: r}
+    }
+    if r, ok := p.unicodeEscape(c); ok {
+        return &reItem{op: reOpLiteral, c: r}
+    }
+    switch {
+    case c == '0':
+        escape := "0" + p.getwhile(2, reOctDigits)
+        n, _ := strconv.ParseInt(escape, 8, 32)
+        return &reItem{op: reOpLiteral, c: rune(n)}
+    case isASCIIDigit(c):
+        // an octal escape, or else a group reference (sigh, says sre_parse)
+        escape := "\\" + string(c)
+        if p.next.in(reDigits) {
+            escape += string(p.get().c)
+            if isOctal(rune(escape[1])) && isOctal(rune(escape[2])) && p.next.in(reOctDigits) {
+                escape += string(p.get().c)
+                n, _ := strconv.ParseInt(escape[1:], 8, 32)
+                if n > 0o377 {
+                    p.fail(fmt.Sprintf("octal escape value %s outside of range 0-0o377", escape), len(escape))
+                }
+                return &reItem{op: reOpLiteral, c: rune(n)}
+            }
+        }
+        group, _ := strconv.Atoi(escape[1:])
+        if group < p.groups() {
+            if !p.checkGroup(group) {
+                p.fail("cannot refer to an open group", len(escape))
+            }
+            p.checkLookbehindGroup(group)
+            return &reItem{op: reOpGroupRef, group: group}
+        }
+        p.fail(fmt.Sprintf("invalid group reference %d", group), len(escape)-1)
+    case isASCIILetter(c):
+        p.fail("bad escape "+t.String(), 2)
+    }
+    return &reItem{op: reOpLiteral, c: c}
+}
+
+// parseSub is an alternation: a|b|c
+func (p *reParser) parseSub(verbose bool, nested int) []*reItem {
+    var branches [][]*reItem
+    for {
+        branches = append(branches, p.parse(verbose, nested+1, nested == 0 && len(branches) == 0))
+        if !p.match('|') {
+            break
+        }
+        if nested == 0 {
+            verbose = p.flags&reVerbose != 0
+        }
+    }
+    if len(branches) == 1 {
+        return branches[0]
+    }
+    return []*reItem{{op: reOpBranch, branches: branches}}
+}
+
+// parse is everything up to the next | or )
+func (p *reParser) parse(verbose bool, nested int, first bool) []*reItem {
+    var items []*reItem
+    literal := func(c rune) { items = append(items, &reItem{op: reOpLiteral, c: c}) }
+
+    for p.next.ok && !p.next.in("|)") {
+        this := p.get()
+        if verbose && this.in(" \t\n\r\v\f") {
+            continue
+        }
+        if verbose && this.is('#') {
+            for t := p.get(); t.ok && !t.is('\n'); t = p.get() {
+            }
+            continue
+        }
+
+        switch {
+        case this.escaped:
+            items = append(items, p.escape(this))
+
+        case !this.in(".\\[{()*+?^$|"):
+            literal(this.c)
+
+        case this.c == '[':
+            items = append(items, p.parseSet())
+
+        case strings.ContainsRune("*+?{", this.c):
+            here := p.tell()
+            var lo, hi int64
+            switch this.c {
+            case '?':
+                lo, hi = 0, 1
+            case '*':
+                lo, hi = 0, reMaxRepeat
+            case '+':
+                lo, hi = 1, reMaxRepeat
+            case '{':
+                if p.next.is('}') {
+                    literal(this.c)
+                    continue
+                }
+                lo, hi = 0, reMaxRepeat
+                low := p.getwhile(-1, reDigits)
+                high := low
+                if p.match(',') {
+                    high = p.getwhile(-1, reDigits)
+                }
+                if !p.match('}') {
+                    literal(this.c)
+                    p.seek(here)
+                    continue
+                }
+                count := func(digits string) int64 {
+                    n, err := strconv.ParseInt(digits, 10, 64)
+                    if err != nil || n >= reMaxRepeat {
+                        panic(&reError{kind: reOverflow, msg: "the repetition number is too large"})
+                    }
+                    return n
+                }
+                if low != "" {
+                    lo = count(low)
+                }
+                if high != "" {
+                    hi = count(high)
+                    if hi < lo {
+                        p.fail("min repeat greater than max repeat", p.tell()-here)
+                    }
+                }
+            }
+            if len(items) == 0 || items[len(items)-1].op == reOpAt {
+                p.fail("nothing to repeat", p.tell()-here+1)
+            }
+            last := items[len(items)-1]
+            if isRepeatOp(last.op) {
+                p.fail("multiple repeat", p.tell()-here+1)
+            }
+            body := []*reItem{last}
+            if last.op == reOpSubpattern && last.group < 0 && last.addFlags == 0 && last.delFlags == 0 {
+                body = last.body
+            }
+            op := reOpMaxRepeat
+            if p.match('?') {
+                op = reOpMinRepeat
+            } else if p.match('+') {
+                op = reOpPossessiveRepeat
+            }
+            items[len(items)-1] = &reItem{op: op, min: lo, max: hi, body: body}
+
+        case this.c == '.':
+            items = append(items, &reItem{op: reOpAny})
+
+        case this.c == '(':
+            item, global := p.parseGroup(verbose, nested, first && len(items) == 0)
+            if global {
+                verbose = p.flags&reVerbose != 0
+            } else if item != nil {
+                items = append(items, item)
+            }
+
+        case this.c == '^', this.c == '$':
+            items = append(items, &reItem{op: reOpAt, c: this.c})
+        }
+    }
+
+    // non-capturing groups without flags are just their contents
+    unpacked := items[:0:0]
+    for _, item := range items {
+        if item.op == reOpSubpattern && item.group < 0 && item.addFlags == 0 && item.delFlags == 0 {
+            unpacked = append(unpacked, item.body...)
+        } else {
+            unpacked = append(unpacked, item)
+        }
+    }
+    return unpacked
+}
+
+// parseSet is a [character set], its opening bracket already read
+func (p *reParser) parseSet() *reItem {
+    here := p.tell() - 1
+    var set []reSetItem
+    negate := p.match('^')
+    for {
+        this := p.get()
+        if !this.ok {
+            p.fail("unterminated character set", p.tell()-here)
+        }
+        if this.is(']') && len(set) > 0 {
+            break
+        }
+        code1 := reSetItem{lo: this.c, hi: this.c}
+        if this.escaped {
+            code1 = p.classEscape(this)
+        }
+        if !p.match('-') {
+            set = append(set, code1)
+            continue
+        }
+        that := p.get()
+        if !that.ok {
+            p.fail("unterminated character set", p.tell()-here)
+        }
+        if that.is(']') {
+            set = append(set, code1, reSetItem{lo: '-', hi: '-'})
+            break
+        }
+        code2 := reSetItem{lo: that.c, hi: that.c}
+        if that.escaped {
+            code2 = p.classEscape(that)
+        }
+        if code1.cat != 0 || code2.cat != 0 || code2.lo < code1.lo {
+            p.fail(fmt.Sprintf("bad character range %s-%s", this, that), this.width()+1+that.width())
+        }
+        set = append(set, reSetItem{lo: code1.lo, hi: code2.lo})
+    }
+    return &reItem{op: reOpIn, set: set, negate: negate}
+}
+
+// parseGroup is whatever starts with '(': a group, an extension, or inline
+// flags. global says the flags were the pattern-wide kind; item is nil for
+// things like comments that leave nothing behind
+func (p *reParser) parseGroup(verbose bool, nested int, first bool) (item *reItem, global bool) {
+    start := p.tell() - 1
+    capture, atomic := true, false
+    name, named := "", false
+    addFlags, delFlags := 0, 0
+    endOfGroup := func() {
+        if !p.match(')') {
+            p.fail("missing ), unterminated subpattern", p.tell()-start)
+        }
+    }
+
+    if p.match('?') {
+        char := p.get()
+        if !char.ok {
+            p.fail("unexpected end of pattern", 0)
+        }
+        switch {
+        case char.is('P'):
+            switch {
+            case p.match('<'):
+                name, named = p.getuntil('>', "group name"), true
+                p.checkGroupName(name, 1)
+            case p.match('='):
+                name := p.getuntil(')', "group name")
+                p.checkGroupName(name, 1)
+                gid, ok := p.names[name]
+                if !ok {
+                    p.fail("unknown group name "+strRepr(name), codePointCount(name)+1)
+                }
+                if !p.checkGroup(gid) {
+                    p.fail("cannot refer to an open group", codePointCount(name)+1)
+                }
+                p.checkLookbehindGroup(gid)
+                return &reItem{op: reOpGroupRef, group: gid}, false
+            default:
+                char := p.get()
+                if !char.ok {
+                    p.fail("unexpected end of pattern", 0)
+                }
+                p.fail("unknown extension ?P"+char.String(), char.width()+2)
+            }
+
+        case char.is(':'):
+            capture = false
+
+        case char.is('#'):
+            for {
+                if !p.next.ok {
+                    p.fail("missing ), unterminated comment", p.tell()-start)
+                }
+                if p.get().is(')') {
+                    return nil, false
+                }
+            }
+
+        case char.in("=!<"):
+            behind := false
+            outermost := false
+            if char.is('<') {
+                if char = p.get(); !char.ok {
+                    p.fail("unexpected end of pattern", 0)
+                }
+                if !char.in("=!") {
+                    p.fail("unknown extension ?<"+char.String(), char.width()+2)
+                }
+                behind = true
+                if outermost = p.lookbehindGroups < 0; outermost {
+                    p.lookbehindGroups = p.groups()
+                }
+            }
+            body := p.parseSub(verbose, nested+1)
+            if outermost {
+                p.lookbehindGroups = -1
+            }
+            endOfGroup()
+            op := reOpAssert
+            if char.is('!') {
+                op = reOpAssertNot
+            }
+            return &reItem{op: op, body: body, behind: behind}, false
+
+        case char.is('('):
+            return p.parseConditional(verbose, nested, start), false
+
+        case char.is('>'):
+            capture, atomic = false, true
+
+        case char.is('-') || char.ok && !char.escaped && reFlagLetters[char.c] != 0:
+            var isGlobal bool
+            addFlags, delFlags, isGlobal = p.parseFlags(char)
+            if isGlobal {
+                if !first {
+                    p.fail("global flags not at the start of the expression", p.tell()-start)
+                }
+                return nil, true
+            }
+            capture = false
+
+        default:
+            p.fail("unknown extension ?"+char.String(), char.width()+1)
+        }
+    }
+
+    group := -1
+    if capture {
+        group = p.openGroup(name, named)
+    }
+    subVerbose := (verbose || addFlags&reVerbose != 0) && delFlags&reVerbose == 0
+    body := p.parseSub(subVerbose, nested+1)
+    endOfGroup()
+    if group >= 0 {
+        p.closeGroup(group, body)
+    }
+    if atomic {
+        return &reItem{op: reOpAtomic, body: body}, false
+    }
+    return &reItem{op: reOpSubpattern, group: group, addFlags: addFlags, delFlags: delFlags, body: body}, false
+}
+
+// parseConditional is (?(group)yes|no), its "(?(" already read
+func (p *reParser) parseConditional(verbose bool, nested int, start int) *reItem {
+    condName := p.getuntil(')', "group name")
+    offset := codePointCount(condName) + 1
+    var cond int
+    if isIdentifier(condName) {
+        p.checkGroupName(condName, 1)
+        gid, ok := p.names[condName]
+        if !ok {
+            p.fail("unknown group name "+strRepr(condName), offset)
+        }
+        cond = gid
+    } else {
+        n, err := strconv.ParseInt(strings.TrimPrefix(condName, "+"), 10, 64)
+        if err != nil || n < 0 || strings.HasPrefix(condName, "+-") {
+            p.fail("bad character in group name "+strRepr(condName), offset)
+        }
+        if n == 0 {
+            p.fail("bad group number", offset)
+        }
+        if n >= reMaxGroups {
+            p.fail(fmt.Sprintf("invalid group reference %d", n), offset)
+        }
+        cond = int(n)
+        if _, ok := p.grouprefpos[cond]; !ok {
+            p.grouprefpos[cond] = p.tell() - offset
+        }
+    }
+    p.checkLookbehindGroup(cond)
+    yes := p.parse(verbose, nested+1, false)
+    var no []*reItem
+    if p.match('|') {
+        no = p.parse(verbose, nested+1, false)
+        if no == nil {
+            no = []*reItem{}
+        }
+        if p.next.is('|') {
+            p.fail("conditional backref with more than two branches", 0)
+        }
+    }
+    if !p.match(')') {
+        p.fail("missing ), unterminated subpattern", p.tell()-start)
+    }
+    return &reItem{op: reOpGroupRefExists, group: cond, body: yes, no: no}
+}
+
+// parseFlags reads (?aiLmsux-imsx:...) or the global (?aiLmsux), from its first letter
+func (p *reParser) parseFlags(char reToken) (addFlags, delFlags int, global bool) {
+    isFlag := func(t reToken) bool { return t.ok && !t.escaped && reFlagLetters[t.c] != 0 }
+    isAlpha := func(t reToken) bool { return !t.escaped && unicode.IsLetter(t.c) }
+    if !char.is('-') {
+        for {
+            flag := reFlagLetters[char.c]
+            if !p.isBytes && char.c == 'L' {
+                p.fail("bad inline flags: cannot use 'L' flag with a str pattern", 0)
+            }
+            if p.isBytes && char.c == 'u' {
+                p.fail("bad inline flags: cannot use 'u' flag with a bytes pattern", 0)
+            }
+            addFlags |= flag
+            if flag&reTypeFlags != 0 && addFlags&reTypeFlags != flag {
+                p.fail("bad inline flags: flags 'a', 'u' and 'L' are incompatible", 0)
+            }
+            if char = p.get(); !char.ok {
+                p.fail("missing -, : or )", 0)
+            }
+            if char.in(")-:") {
+                break
+            }
+            if !isFlag(char) {
+                msg := "missing -, : or )"
+                if isAlpha(char) {
+                    msg = "unknown flag"
+                }
+                p.fail(msg, char.width())
+            }
+        }
+    }
+    if char.is(')') {
+        p.flags |= addFlags
+        return 0, 0, true
+    }
+    if addFlags&reGlobalFlags != 0 {
+        p.fail("bad inline flags: cannot turn on global flag", 1)
+    }
+    if char.is('-') {
+        if char = p.get(); !char.ok {
+            p.fail("missing flag", 0)
+        }
+        if !isFlag(char) {
+            msg := "missing flag"
+            if isAlpha(char) {
+                msg = "unknown flag"
+            }
+            p.fail(msg, char.width())
+        }
+        for {
+            flag := reFlagLetters[char.c]
+            if flag&reTypeFlags != 0 {
+                p.fail("bad inline flags: cannot turn off flags 'a', 'u' and 'L'", 0)
+            }
+            delFlags |= flag
+            if char = p.get(); !char.ok {
+                p.fail("missing :", 0)
+            }
+            if char.is(':') {
+                break
+            }
+            if !isFlag(char) {
+                msg := "missing :"
+                if isAlpha(char) {
+                    msg = "unknown flag"
+                }
+                p.fail(msg, char.width())
+            }
+        }
+    }
+    if delFlags&reGlobalFlags != 0 {
+        p.fail("bad inline flags: cannot turn off global flag", 1)
+    }
+    if addFlags&delFlags != 0 {
+        p.fail("bad inline flags: flag turned on and off", 1)
+    }
+    return addFlags, delFlags, false
+}
+
+// fixFlags settles what the flags mean for this kind of pattern
+func fixFlags(isBytes bool, flags int) (int, *reError) {
+    fail := func(msg string) (int, *reError) { return 0, &reError{kind: reValue, msg: msg, pos: -1} }
+    if !isBytes {
+        switch {
+        case flags&reLocale != 0:
+            return fail("cannot use LOCALE flag with a str pattern")
+        case flags&reASCII == 0:
+            flags |= reUnicode
+        case flags&reUnicode != 0:
+            return fail("ASCII and UNICODE flags are incompatible")
+        }
+        return flags, nil
+    }
+    switch {
+    case flags&reUnicode != 0:
+        return fail("cannot use UNICODE flag with a bytes pattern")
+    case flags&reLocale != 0 && flags&reASCII != 0:
+        return fail("ASCII and LOCALE flags are incompatible")
+    }
+    return flags, nil
+}
+
+// regex is a compiled pattern
+type regex struct {
+    root   reNode
+    groups int            // capturing groups, not counting the whole match
+    names  []string       // names[g] is group g's name, or "" if it has none
+    index  map[string]int // group numbers by name
+    flags  int            // after the inline flags and fixFlags have had their say
+    prefix rune           // a character every match starts with, when hasPrefix
+    hasPrefix bool
+}
+
+// compileRegex parses and compiles src - a str's code points, or a bytes
+// object's bytes one to a rune
+func compileRegex(src []rune, isBytes bool, flags int) (re *regex, err *reError) {
+    defer func() {
+        if r := recover(); r != nil {
+            e, ok := r.(*reError)
+            if !ok {
+                panic(r)
+            }
+            re, err = nil, e
+        }
+    }()
+
+    p := &reParser{src: src, isBytes: isBytes, flags: flags, names: map[string]int{},
+        lookbehindGroups: -1, grouprefpos: map[int]int{}}
+    p.widths, p.closed = [][2]int64{{}}, []bool{true}
+    p.advance()
+    items := p.parseSub(flags&reVerbose != 0, 0)
+    if p.flags, err = fixFlags(isBytes, p.flags); err != nil {
+        return nil, err
+    }
+    if p.next.ok {
+        p.fail("unbalanced parenthesis", 0)
+    }
+    for g, pos := range p.grouprefpos {
+        if g >= p.groups() {
+            return nil, &reError{kind: reSyntax, msg: fmt.Sprintf("invalid group reference %d", g), pos: pos}
+        }
+    }
+
+    re = &regex{groups: p.groups() - 1, names: make([]string, p.groups()), index: p.names, flags: p.flags}
+    for name, g := range p.names {
+        re.names[g] = name
+    }
+    c := &reCompiler{parser: p}
+    re.root = c.compile(items, p.flags)
+    re.prefix, re.hasPrefix = firstCharacter(re.root)
+    return re, nil
+}
+
+// reCompiler turns parsed items into nodes, now that the flags are settled
+type reCompiler struct {
+    parser *reParser
+}
+
+func (c *reCompiler) compile(items []*reItem, flags int) reNode {
+    var seq reSeq
+    for _, item := range items {
+        node := c.compileItem(item, flags)
+        // runs of plain characters are matched as a string
+        if ch, ok := plainCharacter(node); ok && len(seq) > 0 {
+            switch last := seq[len(seq)-1].(type) {
+            case *reString:
+                last.runes = append(last.runes, ch)
+                continue
+            case *reChar:
+                if prev, ok := plainCharacter(last); ok {
+                    seq[len(seq)-1] = &reString{runes: []rune{prev, ch}}
+                    continue
+                }
+            }
+        }
+        seq = append(seq, node)
+    }
+    if len(seq) == 1 {
+        return seq[0]
+    }
+    return seq
+}
+
+// plainCharacter is the character a literal matches, if that's the only one
+func plainCharacter(node reNode) (rune, bool) {
+    if ch, ok := node.(*reChar); ok && len(ch.chars) == 1 && !ch.negate {
+        return ch.chars[0], true
+    }
+    return 0, false
+}
+
+// foldMode is how a pattern compiled with flags ignores case: not at all,
+// in ASCII only, or across Unicode
+func (c *reCompiler) foldMode(flags int) int {
+    switch {
+    case flags&reIgnoreCase == 0:
+        return 0
+    case c.parser.isBytes || flags&reASCII != 0:
+        return 1
+    }
+    return 2
+}
+
+func (c *reCompiler) compileItem(item *reItem, flags int) reNode {
+    ascii := c.parser.isBytes || flags&reASCII != 0
+    switch item.op {
+    case reOpLiteral:
+        return &reChar{chars: caseVariants(item.c, c.foldMode(flags))}
+    case reOpAny:
+        return &reAny{dotAll: flags&reDotAll != 0}
+    case reOpIn:
+        return &reSet{items: item.set, negate: item.negate, fold: c.foldMode(flags), ascii: ascii}
+    case reOpAt:
+        return &reAt{kind: item.c, multiline: flags&reMultiline != 0, ascii: ascii}
+    case reOpBranch:
+        alt := make(reAlt, len(item.branches))
+        for i, branch := range item.branches {
+            alt[i] = c.compile(branch, flags)
+        }
+        return alt
+    case reOpSubpattern:
+        body := c.compile(item.body, flags&^item.delFlags|item.addFlags)
+        if item.group < 0 {
+            return body
+        }
+        return &reCapture{n: item.group, body: body}
+    case reOpMaxRepeat, reOpMinRepeat, reOpPossessiveRepeat:
+        return &reRepeat{body: c.compile(item.body, flags), min: int(item.min), max: int(item.max),
+            lazy: item.op == reOpMinRepeat, possessive: item.op == reOpPossessiveRepeat}
+    case reOpAssert, reOpAssertNot:
+        look := &reLook{body: c.compile(item.body, flags), behind: item.behind, negate: item.op == reOpAssertNot}
+        if item.behind {
+            lo, hi := c.parser.width(item.body)
+            if lo != hi {
+                panic(&reError{kind: reSyntax, msg: "look-behind requires fixed-width pattern", pos: -1})
+            }
+            look.width = int(lo)
+        }
+        return look
+    case reOpAtomic:
+        return &reAtomic{body: c.compile(item.body, flags)}
+    case reOpGroupRef:
+        return &reBackref{n: item.group, fold: c.foldMode(flags)}
+    case reOpGroupRefExists:
+        cond := &reCond{n: item.group, yes: c.compile(item.body, flags), no: reSeq{}}
+        if item.no != nil {
+            cond.no = c.compile(item.no, flags)
+        }
+        return cond
+    }
+    panic("unknown regex opcode")
+}
+
+// caseVariants is c and everything that matches it when case is ignored
+func caseVariants(c rune, fold int) []rune {
+    variants := []rune{c}
+    switch fold {
+    case 1:
+        if c < 0x80 && unicode.IsLetter(c) {
+            variants = append(variants, c^0x20)
+        }
+    case 2:
+        for f := unicode.SimpleFold(c); f != c; f = unicode.SimpleFold(f) {
+            variants = append(variants, f)
+        }
+        for _, f := range []rune{unicode.ToLower(c), unicode.ToUpper(c)} {
+            if !containsRune(variants, f) {
+                variants = append(variants, f)
+            }
+        }
+    }
+    return variants
+}
+
+func containsRune(runes []rune, r rune) bool {
+    for _, x := range runes {
+        if x == r {
+            return true
+        }
+    }
+    return false
+}
+
+func foldEqual(a, b rune, fold int) bool {
+    return a == b || fold != 0 && containsRune(caseVariants(a, fold), b)
+}
+
+// The categories behind \d, \s and \w, in Unicode or just ASCII
+func isWordRune(c rune, ascii bool) bool {
+    if ascii {
+        return c < 0x80 && (isASCIILetter(c) || isASCIIDigit(c) || c == '_')
+    }
+    return c == '_' || unicode.IsLetter(c) || isNumericRune(c)
+}
+
+func inCategory(cat, c rune, ascii bool) bool {
+    var in bool
+    switch unicode.ToLower(cat) {
+    case 'd':
+        in = isASCIIDigit(c) || !ascii && isDecimalRune(c)
+    case 's':
+        in = strings.ContainsRune(" \t\n\r\f\v", c) || !ascii && isUnicodeSpace(c)
+    case 'w':
+        in = isWordRune(c, ascii)
+    }
+    return in != unicode.IsUpper(cat)
+}
+
+// reMatcher is one attempt at matching: the subject and the groups so far
+type reMatcher struct {
+    text      []rune
+    end       int   // endpos - nothing past here exists
+    caps      []int // start and end of each group, -1 while unset
+    lastIndex int
+}
+
+func (m *reMatcher) save() []int {
+    return append(append([]int(nil), m.caps...), m.lastIndex)
+}
+
+func (m *reMatcher) restore(saved []int) {
+    copy(m.caps, saved)
+    m.lastIndex = saved[len(saved)-1]
+}
+
+// reNode matches at i and hands where it ended to k, trying every way it
+// can match until k is satisfied
+type reNode interface {
+    match(m *reMatcher, i int, k func(int) bool) bool
+}
+
+// reCharNode is a node that matches exactly one character; repeating one
+// can count instead of recursing
+type reCharNode interface {
+    reNode
+    accepts(c rune) bool
+}
+
+func matchChar(n reCharNode, m *reMatcher, i int, k func(int) bool) bool {
+    return i < m.end && n.accepts(m.text[i]) && k(i+1)
+}
+
+// reChar is a literal, or [^x] when negated
+type reChar struct {
+    chars  []rune
+    negate bool
+}
+
+func (n *reChar) accepts(c rune) bool { return containsRune(n.chars, c) != n.negate }
+func (n *reChar) match(m *reMatcher, i int, k func(int) bool) bool {
+    return matchChar(n, m, i, k)
+}
+
+type reAny struct{ dotAll bool }
+
+func (n *reAny) accepts(c rune) bool { return n.dotAll || c != '\n' }
+func (n *reAny) match(m *reMatcher, i int, k func(int) bool) bool {
+    return matchChar(n, m, i, k)
+}
+
+type reSet struct {
+    items  []reSetItem
+    negate bool
+    fold   int
+    ascii  bool
+}
+
+func (n *reSet) contains(c rune) bool {
+    for _, item := range n.items {
+        if item.cat != 0 {
+            if inCategory(item.cat, c, n.ascii) {
+                return true
+            }
+        } else if c >= item.lo && c <= item.hi {
+            return true
+        }
+    }
+    return false
+}
+
+func (n *reSet) accepts(c rune) bool {
+    in := n.contains(c)
+    if !in && n.fold != 0 {
+        for _, v := range caseVariants(c, n.fold)[1:] {
+            if in = n.contains(v); in {
+                break
+            }
+        }
+    }
+    return in != n.negate
+}
+
+func (n *reSet) match(m *reMatcher, i int, k func(int) bool) bool {
+    return matchChar(n, m, i, k)
+}
+
+// reString is a run of literal characters, case and all
+type reString struct{ runes []rune }
+
+func (n *reString) matches(m *reMatcher, i int) bool {
+    if i+len(n.runes) > m.end {
+        return false
+    }
+    for j, r := range n.runes {
+        if m.text[i+j] != r {
+            return false
+        }
+    }
+    return true
+}
+
+func (n *reString) match(m *reMatcher, i int, k func(int) bool) bool {
+    return n.matches(m, i) && k(i+len(n.runes))
+}
+
+// reAt is an anchor: ^ $ \A \Z \b \B
+type reAt struct {
+    kind      rune
+    multiline bool
+    ascii     bool
+}
+
+func (n *reAt) holds(m *reMatcher, i int) bool {
+    switch n.kind {
+    case '^':
+        return i == 0 || n.multiline && m.text[i-1] == '\n'
+    case 'A':
+        return i == 0
+    case '$':
+        if n.multiline {
+            return i >= m.end || m.text[i] == '\n'
+        }
+        return i >= m.end || i+1 == m.end && m.text[i] == '\n'
+    case 'Z':
+        return i >= m.end
+    }
+    if m.end == 0 {
+        return false
+    }
+    before := i > 0 && isWordRune(m.text[i-1], n.ascii)
+    after := i < m.end && isWordRune(m.text[i], n.ascii)
+    return (before != after) == (n.kind == 'b')
+}
+
+func (n *reAt) match(m *reMatcher, i int, k func(int) bool) bool {
+    return n.holds(m, i) && k(i)
+}
+
+// reSeq is one thing after another
+type reSeq []reNode
+
+func (s reSeq) match(m *reMatcher, i int, k func(int) bool) bool {
+    return s.from(m, 0, i, k)
+}
+
+func (s reSeq) from(m *reMatcher, n, i int, k func(int) bool) bool {
+    // the nodes that can't backtrack are matched in place, without a continuation
+    for ; n < len(s); n++ {
+        switch node := s[n].(type) {
+        case reCharNode:
+            if i >= m.end || !node.accepts(m.text[i]) {
+                return false
+            }
+            i++
+            continue
+        case *reString:
+            if !node.matches(m, i) {
+                return false
+            }
+            i += len(node.runes)
+            continue
+        case *reAt:
+            if !node.holds(m, i) {
+                return false
+            }
+            continue
+        }
+        break
+    }
+    if n == len(s) {
+        return k(i)
+    }
+    return s[n].match(m, i, func(j int) bool { return s.from(m, n+1, j, k) })
+}
+
+// reAlt is a|b|c, tried left to right
+type reAlt []reNode
+
+func (a reAlt) match(m *reMatcher, i int, k func(int) bool) bool {
+    for _, branch := range a {
+        if branch.match(m, i, k) {
+            return true
+        }
+    }
+    return false
+}
+
+type reCapture struct {
+    n    int
+    body reNode
+}
+
+func (g *reCapture) match(m *reMatcher, i int, k func(int) bool) bool {
+    return g.body.match(m, i, func(j int) bool {
+        start, end, last := m.caps[2*g.n], m.caps[2*g.n+1], m.lastIndex
+        m.caps[2*g.n], m.caps[2*g.n+1], m.lastIndex = i, j, g.n
+        if k(j) {
+            return true
+        }
+        m.caps[2*g.n], m.caps[2*g.n+1], m.lastIndex = start, end, last
+        return false
+    })
+}
+
+// reBackref matches whatever group n matched; if it didn't, nothing does
+type reBackref struct {
+    n    int
+    fold int
+}
+
+func (b *reBackref) match(m *reMatcher, i int, k func(int) bool) bool {
+    start, end := m.caps[2*b.n], m.caps[2*b.n+1]
+    if start < 0 || end < 0 || i+end-start > m.end {
+        return false
+    }
+    for j := start; j < end; j++ {
+        if !foldEqual(m.text[j], m.text[i+j-start], b.fold) {
+            return false
+        }
+    }
+    return k(i + end - start)
+}
+
+type reRepeat struct {
+    body       reNode
+    min, max   int
+    lazy       bool
+    possessive bool
+}
+
+func (r *reRepeat) match(m *reMatcher, i int, k func(int) bool) bool {
+    if c, ok := r.body.(reCharNode); ok {
+        return r.matchChars(m, c, i, k)
+    }
+    switch {
+    case r.possessive:
+        saved := m.save()
+        end := -1
+        if r.greedy(m, i, 0, -1, func(j int) bool { end = j; return true }) && k(end) {
+            return true
+        }
+        m.restore(saved)
+        return false
+    case r.lazy:
+        return r.lazily(m, i, 0, -1, k)
+    }
+    return r.greedy(m, i, 0, -1, k)
+}
+
+// greedy goes round again while it can. Like sre, it won't go round again
+// after a round that matched nothing - last is where the previous round began
+func (r *reRepeat) greedy(m *reMatcher, i, count, last int, k func(int) bool) bool {
+    if count < r.min || count < r.max && i != last {
+        if r.body.match(m, i, func(j int) bool { return r.greedy(m, j, count+1, i, k) }) {
+            return true
+        }
+        if count < r.min {
+            return false
+        }
+    }
+    return k(i)
+}
+
+func (r *reRepeat) lazily(m *reMatcher, i, count, last int, k func(int) bool) bool {
+    if count >= r.min {
+        if k(i) {
+            return true
+        }
+        if count >= r.max || i == last {
+            return false
+        }
+    }
+    return r.body.match(m, i, func(j int) bool { return r.lazily(m, j, count+1, i, k) })
+}
+
+// matchChars repeats a single character by counting, the way sre's REPEAT_ONE does
+func (r *reRepeat) matchChars(m *reMatcher, c reCharNode, i int, k func(int) bool) bool {
+    limit := min(m.end-i, r.max)
+    n := 0
+    if r.lazy {
+        for ; n < r.min; n++ {
+            if n >= limit || !c.accepts(m.text[i+n]) {
+                return false
+            }
+        }
+        for !k(i + n) {
+            if n >= limit || !c.accepts(m.text[i+n]) {
+                return false
+            }
+            n++
+        }
+        return true
+    }
+    for n < limit && c.accepts(m.text[i+n]) {
+        n++
+    }
+    if r.possessive {
+        return n >= r.min && k(i+n)
+    }
+    for ; n >= r.min; n-- {
+        if k(i + n) {
+            return true
+        }
+    }
+    return false
+}
+
+// reLook is a lookahead or lookbehind, which matches without moving
+type reLook struct {
+    body   reNode
+    behind bool
+    negate bool
+    width  int
+}
+
+func (l *reLook) match(m *reMatcher, i int, k func(int) bool) bool {
+    start := i
+    if l.behind {
+        if start = i - l.width; start < 0 {
+            return l.negate && k(i)
+        }
+    }
+    saved := m.save()
+    found := l.body.match(m, start, func(j int) bool { return !l.behind || j == i })
+    if l.negate {
+        m.restore(saved)
+        return !found && k(i)
+    }
+    if found && k(i) {
+        return true
+    }
+    m.restore(saved)
+    return false
+}
+
+// reAtomic is (?>...): the first way it matches is the only way
+type reAtomic struct{ body reNode }
+
+func (a *reAtomic) match(m *reMatcher, i int, k func(int) bool) bool {
+    saved := m.save()
+    end := -1
+    if a.body.match(m, i, func(j int) bool { end = j; return true }) && k(end) {
+        return true
+    }
+    m.restore(saved)
+    return false
+}
+
+// reCond is (?(n)yes|no)
+type reCond struct {
+    n       int
+    yes, no reNode
+}
+
+func (c *reCond) match(m *reMatcher, i int, k func(int) bool) bool {
+    if m.caps[2*c.n] >= 0 && m.caps[2*c.n+1] >= 0 {
+        return c.yes.match(m, i, k)
+    }
+    return c.no.match(m, i, k)
+}
+
+// firstCharacter is a character every match has to start with, if there is
+// one - search can skip straight to where it appears
+func firstCharacter(node reNode) (rune, bool) {
+    switch n := node.(type) {
+    case *reString:
+        return n.runes[0], true
+    case *reChar:
+        if len(n.chars) == 1 && !n.negate {
+            return n.chars[0], true
+        }
+    case reSeq:
+        if len(n) > 0 {
+            return firstCharacter(n[0])
+        }
+    case *reCapture:
+        return firstCharacter(n.body)
+    case *reAtomic:
+        return firstCharacter(n.body)
+    case *reRepeat:
+        if n.min > 0 {
+            return firstCharacter(n.body)
+        }
+    }
+    return 0, false
+}
+
+// exec looks for a match in text[:end] starting at pos - only there unless
+// searching. A full match has to reach end; mustAdvance turns down an empty
+// match at pos, so a scan that just found one moves along. It gives back the
+// spans of every group, the whole match first, and the last group to close
+func (re *regex) exec(text []rune, pos, end int, search, full, mustAdvance bool) ([]int, int, bool) {
+    m := &reMatcher{text: text, end: end, caps: make([]int, 2*(re.groups+1))}
+    if search && pos > end {
+        return nil, 0, false
+    }
+    for start := pos; ; start++ {
+        if search && re.hasPrefix {
+            for start < end && text[start] != re.prefix {
+                start++
+            }
+            if start >= end {
+                return nil, 0, false
+            }
+        }
+        for i := range m.caps {
+            m.caps[i] = -1
+        }
+        m.lastIndex = -1
+        matchEnd := -1
+        found := re.root.match(m, start, func(j int) bool {
+            if full && j != end || mustAdvance && start == pos && j == start {
+                return false
+            }
+            matchEnd = j
+            return true
+        })
+        if found {
+            m.caps[0], m.caps[1] = start, matchEnd
+            return m.caps, m.lastIndex, true
+        }
+        if !search || start >= end {
+            return nil, 0, false
+        }
+    }
+}
@@ -0,0 +1,1210 @@
+// Comments in this file are inspired by Katrina Bennett - precise down to the last character
+
+package evaluator
+
+import (
+    "fmt"
+    "hash/fnv"
+    "math"
+    "strings"
+    "unicode"
+    "unicode/utf8"
+)
+
+// strRepr quotes a string the way Python's repr does: single quotes unless
+// the text has single quotes and no double ones
+func strRepr(s string) string {
+    quote := '\''
+    if strings.ContainsRune(s, '\'') && !strings.ContainsRune(s, '"') {
+        quote = '"'
+    }
+
+    var b strings.Builder
+    b.WriteRune(quote)
+    for _, r := range codePoints(s) {
+        switch {
+        case r == quote || r == '\\':
+            b.WriteRune('\\')
+            b.WriteRune(r)
+        case r == '\n':
+            b.WriteString(`\n`)
+        case r == '\r':
+            b.WriteString(`\r`)
+        case r == '\t':
+            b.WriteString(`\t`)
+        case r < 0x20 || r == 0x7f:
+            fmt.Fprintf(&b, `\x%02x`, r)
+        case r < 0x7f || unicode.IsPrint(r):
+            b.WriteRune(r)
+        case r < 0x100:
+            fmt.Fprintf(&b, `\x%02x`, r)
+        case r < 0x10000:
+            fmt.Fprintf(&b, `\u%04x`, r)
+        default:
+            fmt.Fprintf(&b, `\U%08x`, r)
+        }
+    }
+    b.WriteRune(quote)
+    return b.String()
+}
+
+// Lone surrogates - what surrogateescape hands back - can't live in UTF-8, so
+// a string keeps them as the three bytes UTF-8 would have used. Valid UTF-8
+// never contains those, so everything else reads the same.
+
+// decodeCodePoint is utf8.DecodeRuneInString that also reads surrogates back
+func decodeCodePoint(s string) (rune, int) {
+    if len(s) >= 3 && s[0] == 0xed && s[1] >= 0xa0 && s[1] <= 0xbf && s[2]&0xc0 == 0x80 {
+        return 0xd000 | rune(s[1]&0x3f)<<6 | rune(s[2]&0x3f), 3
+    }
+    return utf8.DecodeRuneInString(s)
+}
+
+func isSurrogate(r rune) bool { return r >= 0xd800 && r <= 0xdfff }
+
+// writeCodePoint is WriteRune that can write surrogates
+func writeCodePoint(b *strings.Builder, r rune) {
+    if isSurrogate(r) {
+        b.WriteByte(0xed)
+        b.WriteByte(byte(0x80 | r>>6&0x3f))
+        b.WriteByte(byte(0x80 | r&0x3f))
+        return
+    }
+    b.WriteRune(r)
+}
+
+// codePoints is []rune(s), surrogates included
+func codePoints(s string) []rune {
+    if !strings.Contains(s, "\xed") {
+        return []rune(s)
+    }
+    text := make([]rune, 0, len(s))
+    for len(s) > 0 {
+        r, size := decodeCodePoint(s)
+        text = append(text, r)
+        s = s[size:]
+    }
+    return text
+}
+
+// stringIndex is what subscripting a str needs: nothing for ASCII, whose
+// bytes already are its code points, the decoded code points otherwise
+type stringIndex struct {
+    ascii  bool
+    points []rune
+}
+
+// codePoints decodes s once per String, so s[i] in a loop stays O(1). Strings
+// never change, and they can be shared between interpreters - two of them
+// decoding at once just store the same answer.
+func (s *String) codePoints() *stringIndex {
+    if index := s.index.Load(); index != nil {
+        return index
+    }
+    index := &stringIndex{ascii: true}
+    for i := 0; i < len(s.Value); i++ {
+        if s.Value[i] >= utf8.RuneSelf {
+            index.ascii = false
+            index.points = codePoints(s.Value)
+            break
+        }
+    }
+    s.index.Store(index)
+    return index
+}
+
+// length is len(s)
+func (x *stringIndex) length(s string) int {
+    if x.ascii {
+        return len(s)
+    }
+    return len(x.points)
+}
+
+// slice is s[start:stop:step], already clipped to the string
+func (x *stringIndex) slice(s string, start, stop, step int) string {
+    n := sliceLength(start, stop, step)
+    if x.ascii {
+        if step == 1 {
+            return s[start : start+n]
+        }
+        b := make([]byte, 0, n)
+        for i := start; n > 0; i, n = i+step, n-1 {
+            b = append(b, s[i])
+        }
+        return string(b)
+    }
+    if step == 1 {
+        return fromCodePoints(x.points[start : start+n])
+    }
+    selected := make([]rune, 0, n)
+    for i := start; n > 0; i, n = i+step, n-1 {
+        selected = append(selected, x.points[i])
+    }
+    return fromCodePoints(selected)
+}
+
+// fromCodePoints is string(text), surrogates included
+func fromCodePoints(text []rune) string {
+    var b strings.Builder
+    for _, r := range text {
+        writeCodePoint(&b, r)
+    }
+    return b.String()
+}
+
+func codePointCount(s string) int {
+    if !strings.Contains(s, "\xed") {
+        return utf8.RuneCountInString(s)
+    }
+    return len(codePoints(s))
+}
+
+// hashString never returns -1, which CPython reserves for errors
+func hashString(s string) int64 {
+    h := fnv.New64a()
+    h.Write([]byte(s))
+    hash := int64(h.Sum64())
+    if hash == -1 {
+        return -2
+    }
+    return hash
+}
+
+
+func asString(obj Object) *String { return payload(obj).(*String) }
+
+var strIteratorType = newIteratorClass("str_iterator")
+
+// isUnicodeSpace is Python's idea of whitespace, which takes in the
+// information separators Go leaves out
+func isUnicodeSpace(r rune) bool {
+    return unicode.IsSpace(r) || r >= 0x1c && r <= 0x1f
+}
+
+// isLineBreak is a line boundary for splitlines
+func isLineBreak(r rune) bool {
+    switch r {
+    case '\n', '\r', '\v', '\f', 0x1c, 0x1d, 0x1e, 0x85, 0x2028, 0x2029:
+        return true
+    }
+    return false
+}
+
+func isCased(r rune) bool {
+    return unicode.IsUpper(r) || unicode.IsLower(r) || unicode.IsTitle(r)
+}
+
+// Where one character turns into several, Go's simple case mapping falls
+// short of Python's full one
+var (
+    specialUpper = map[rune]string{'ß': "SS", 'ŉ': "ʼN", 'ﬀ': "FF", 'ﬁ': "FI", 'ﬂ': "FL", 'ﬃ': "FFI", 'ﬄ': "FFL", 'ﬅ': "ST", 'ﬆ': "ST"}
+    specialTitle = map[rune]string{'ß': "Ss", 'ŉ': "ʼN", 'ﬀ': "Ff", 'ﬁ': "Fi", 'ﬂ': "Fl", 'ﬃ': "Ffi", 'ﬄ': "Ffl", 'ﬅ': "St", 'ﬆ': "St"}
+    specialLower = map[rune]string{'İ': "i̇"}
+    specialFold  = map[rune]string{'ß': "ss", 'ẞ': "ss", 'İ': "i̇", 'ŉ': "ʼn", 'ﬀ': "ff", 'ﬁ': "fi", 'ﬂ': "fl", 'ﬃ': "ffi", 'ﬄ': "ffl", 'ﬅ': "st", 'ﬆ': "st"}
+)
+
+func upperRune(r rune) string {
+    if s, ok := specialUpper[r]; ok {
+        return s
+    }
+    return string(unicode.ToUpper(r))
+}
+
+func titleRune(r rune) string {
+    if s, ok := specialTitle[r]; ok {
+        return s
+    }
+    return string(unicode.ToTitle(r))
+}
+
+// lowerRune lowercases text[i]; a capital sigma at the end of a word
+// becomes the final form
+func lowerRune(text []rune, i int) string {
+    r := text[i]
+    if s, ok := specialLower[r]; ok {
+        return s
+    }
+    if r == 'Σ' && i > 0 && isCased(text[i-1]) && (i+1 == len(text) || !isCased(text[i+1])) {
+        return "ς"
+    }
+    return string(unicode.ToLower(r))
+}
+
+// otherDigits are the digits that aren't decimal: superscripts, circled
+// numbers and the like
+var otherDigits = &unicode.RangeTable{
+    R16: []unicode.Range16{
+        {Lo: 0x00b2, Hi: 0x00b3, Stride: 1}, {Lo: 0x00b9, Hi: 0x00b9, Stride: 1},
+        {Lo: 0x1369, Hi: 0x1371, Stride: 1}, {Lo: 0x19da, Hi: 0x19da, Stride: 1},
+        {Lo: 0x2070, Hi: 0x2070, Stride: 1}, {Lo: 0x2074, Hi: 0x2079, Stride: 1},
+        {Lo: 0x2080, Hi: 0x2089, Stride: 1}, {Lo: 0x2460, Hi: 0x2468, Stride: 1},
+        {Lo: 0x2474, Hi: 0x247c, Stride: 1}, {Lo: 0x2488, Hi: 0x2490, Stride: 1},
+        {Lo: 0x24ea, Hi: 0x24ea, Stride: 1}, {Lo: 0x24f5, Hi: 0x24fd, Stride: 1},
+        {Lo: 0x24ff, Hi: 0x24ff, Stride: 1}, {Lo: 0x2776, Hi: 0x277e, Stride: 1},
+        {Lo: 0x2780, Hi: 0x2788, Stride: 1}, {Lo: 0x278a, Hi: 0x2792, Stride: 1},
+    },
+    R32: []unicode.Range32{
+        {Lo: 0x10a40, Hi: 0x10a43, Stride: 1}, {Lo: 0x1f100, Hi: 0x1f10a, Stride: 1},
+    },
+    LatinOffset: 2,
+}
+
+// cjkNumerals are ideographs Unicode gives a numeric value
+const cjkNumerals = "〇一二三四五六七八九十百千万億兆零廿卅"
+
+func isDecimalRune(r rune) bool { return unicode.Is(unicode.Nd, r) }
+func isDigitRune(r rune) bool   { return isDecimalRune(r) || unicode.Is(otherDigits, r) }
+func isNumericRune(r rune) bool { return unicode.IsNumber(r) || strings.ContainsRune(cjkNumerals, r) }
+
+func isIdentifierStart(r rune) bool {
+    return r == '_' || unicode.IsLetter(r) || unicode.Is(unicode.Nl, r)
+}
+
+func isIdentifierChar(r rune) bool {
+    return isIdentifierStart(r) || unicode.In(r, unicode.Mn, unicode.Mc, unicode.Nd, unicode.Pc)
+}
+
+func isIdentifier(s string) bool {
+    for i, r := range s {
+        if i == 0 && !isIdentifierStart(r) || !isIdentifierChar(r) {
+            return false
+        }
+    }
+    return s != ""
+}
+
+// strPredicates are the is*() methods that ask the same question of every
+// character and say False for the empty string
+var strPredicates = map[string]func(r rune) bool{
+    "isalpha":     unicode.IsLetter,
+    "isdecimal":   isDecimalRune,
+    "isdigit":     isDigitRune,
+    "isnumeric":   isNumericRune,
+    "isspace":     isUnicodeSpace,
+    "isalnum":     func(r rune) bool { return unicode.IsLetter(r) || isNumericRune(r) },
+    "isprintable": unicode.IsPrint,
+    "isascii":     func(r rune) bool { return r < utf8.RuneSelf },
+}
+
+// strArgument is an argument that has to be a str
+func strArgument(obj Object) (string, *Error) {
+    s, ok := payload(obj).(*String)
+    if !ok {
+        return "", typeError("must be str, not %s", typeName(obj))
+    }
+    return s.Value, nil
+}
+
+// separatorArgument is the sep of split and friends: a non-empty str or None
+func separatorArgument(obj Object) (string, bool, *Error) {
+    if obj == nil || obj == NULL {
+        return "", false, nil
+    }
+    s, ok := payload(obj).(*String)
+    if !ok {
+        return "", false, typeError("must be str or None, not %s", typeName(obj))
+    }
+    if s.Value == "" {
+        return "", false, valueError("empty separator")
+    }
+    return s.Value, true, nil
+}
+
+// substringBounds reads the optional start and end that narrow find, count
+// and friends. Unlike a slice's, start is never pulled back to the length.
+func substringBounds(env *Environment, args []Object, n int) (int, int, *Error) {
+    bounds := []int{0, n}
+    for i, arg := range args {
+        if arg == NULL {
+            continue
+        }
+        v, err := sliceIndex(env, arg)
+        if err != nil {
+            return 0, 0, err
+        }
+        switch {
+        case v < 0:
+            v += n
+            if v < 0 {
+                v = 0
+            }
+        case i == 1 && v > n:
+            v = n
+        }
+        bounds[i] = v
+    }
+    return bounds[0], bounds[1], nil
+}
+
+func hasPrefixRunes(text, prefix []rune) bool {
+    if len(prefix) > len(text) {
+        return false
+    }
+    for i, r := range prefix {
+        if text[i] != r {
+            return false
+        }
+    }
+    return true
+}
+
+// indexRunes finds sub in text[start:end], from the left or from the right
+func indexRunes(text, sub []rune, start, end int, last bool) int {
+    if start > len(text) || end-start < len(sub) {
+        return -1
+    }
+    if last {
+        for i := end - len(sub); i >= start; i-- {
+            if hasPrefixRunes(text[i:], sub) {
+                return i
+            }
+        }
+        return -1
+    }
+    for i := start; i+len(sub) <= end; i++ {
+        if hasPrefixRunes(text[i:], sub) {
+            return i
+        }
+    }
+    return -1
+}
+
+// countRunes counts the non-overlapping occurrences of sub in text[start:end]
+func countRunes(text, sub []rune, start, end int) int {
+    if start > len(text) || end < start {
+        return 0
+    }
+    if len(sub) == 0 {
+        return end - start + 1
+    }
+    count := 0
+    for i := start; i+len(sub) <= end; {
+        if hasPrefixRunes(text[i:], sub) {
+            count++
+            i += len(sub)
+        } else {
+            i++
+        }
+    }
+    return count
+}
+
+// strFind is find, rfind, index and rindex
+func strFind(env *Environment, args []Object, last, strict bool) Object {
+    text := codePoints(asString(args[0]).Value)
+    sub, err := strArgument(args[1])
+    if err != nil {
+        return err
+    }
+    start, end, err := substringBounds(env, args[2:], len(text))
+    if err != nil {
+        return err
+    }
+    i := indexRunes(text, codePoints(sub), start, end, last)
+    if i < 0 && strict {
+        return valueError("substring not found")
+    }
+    return newInt(int64(i))
+}
+
+// strAffix is startswith and endswith, which also take a tuple of choices
+func strAffix(env *Environment, name string, args []Object, suffix bool) Object {
+    text := codePoints(asString(args[0]).Value)
+    start, end, err := substringBounds(env, args[2:], len(text))
+    if err != nil {
+        return err
+    }
+    choices := []Object{args[1]}
+    if tuple, ok := payload(args[1]).(*Tuple); ok {
+        choices = tuple.Elements
+    } else if _, ok := payload(args[1]).(*String); !ok {
+        return typeError("%s first arg must be str or a tuple of str, not %s", name, typeName(args[1]))
+    }
+    for _, choice := range choices {
+        s, ok := payload(choice).(*String)
+        if !ok {
+            return typeError("tuple for %s must only contain str, not %s", name, typeName(choice))
+        }
+        affix := codePoints(s.Value)
+        if start > len(text) || end-len(affix) < start {
+            continue
+        }
+        at := start
+        if suffix {
+            at = end - len(affix)
+        }
+        if hasPrefixRunes(text[at:end], affix) {
+            return TRUE
+        }
+    }
+    return FALSE
+}
+
+// splitWhitespace splits on runs of whitespace, dropping empty strings at
+// either end. From the right, for rsplit, when last is set.
+func splitWhitespace(text []rune, maxsplit int, last bool) []string {
+    parts := []string{}
+    if last {
+        for i := len(text); ; {
+            for i > 0 && isUnicodeSpace(text[i-1]) {
+                i--
+            }
+            if i == 0 {
+                break
+            }
+            if maxsplit == 0 {
+                parts = append(parts, fromCodePoints(text[:i]))
+                break
+            }
+            j := i
+            for j > 0 && !isUnicodeSpace(text[j-1]) {
+                j--
+            }
+            parts = append(parts, fromCodePoints(text[j:i]))
+            maxsplit--
+            i = j
+        }
+        for l, r := 0, len(parts)-1; l < r; l, r = l+1, r-1 {
+            parts[l], parts[r] = parts[r], parts[l]
+        }
+        return parts
+    }
+    for i := 0; ; {
+        for i < len(text) && isUnicodeSpace(text[i]) {
+            i++
+        }
+        if i == len(text) {
+            break
+        }
+        if maxsplit == 0 {
+            parts = append(parts, fromCodePoints(text[i:]))
+            break
+        }
+        j := i
+        for j < len(text) && !isUnicodeSpace(text[j]) {
+            j++
+        }
+        parts = append(parts, fromCodePoints(text[i:j]))
+        maxsplit--
+        i = j
+    }
+    return parts
+}
+
+// rsplitSeparator is strings.SplitN working in from the right
+func rsplitSeparator(s, sep string, maxsplit int) []string {
+    parts := []string{}
+    for maxsplit != 0 {
+        i := strings.LastIndex(s, sep)
+        if i < 0 {
+            break
+        }
+        parts = append(parts, s[i+len(sep):])
+        s = s[:i]
+        maxsplit--
+    }
+    parts = append(parts, s)
+    for l, r := 0, len(parts)-1; l < r; l, r = l+1, r-1 {
+        parts[l], parts[r] = parts[r], parts[l]
+    }
+    return parts
+}
+
+// strSplit is split and rsplit
+func strSplit(env *Environment, name string, args []Object, kwargs *Dict) Object {
+    params, err := parseArgs(name, args[1:], kwargs, []string{"sep", "maxsplit"}, 0)
+    if err != nil {
+        return err
+    }
+    sep, hasSep, err := separatorArgument(params[0])
+    if err != nil {
+        return err
+    }
+    maxsplit := -1
+    if params[1] != nil {
+        if maxsplit, err = toIndex(env, params[1]); err != nil {
+            return err
+        }
+    }
+
+    s := asString(args[0]).Value
+    var parts []string
+    switch {
+    case !hasSep:
+        parts = splitWhitespace(codePoints(s), maxsplit, name == "rsplit")
+    case name == "rsplit":
+        parts = rsplitSeparator(s, sep, maxsplit)
+    case maxsplit < 0:
+        parts = strings.Split(s, sep)
+    default:
+        parts = strings.SplitN(s, sep, maxsplit+1)
+    }
+    return stringList(parts)
+}
+
+func stringList(parts []string) *List {
+    elements := make([]Object, len(parts))
+    for i, part := range parts {
+        elements[i] = &String{Value: part}
+    }
+    return &List{Elements: elements}
+}
+
+// strStrip is strip, lstrip and rstrip
+func strStrip(args []Object, left, right bool) Object {
+    s := asString(args[0]).Value
+    cut := isUnicodeSpace
+    if len(args) > 1 && args[1] != NULL {
+        chars, ok := payload(args[1]).(*String)
+        if !ok {
+            return typeError("strip arg must be None or str")
+        }
+        cut = func(r rune) bool { return strings.ContainsRune(chars.Value, r) }
+    }
+    if left {
+        s = strings.TrimLeftFunc(s, cut)
+    }
+    if right {
+        s = strings.TrimRightFunc(s, cut)
+    }
+    return &String{Value: s}
+}
+
+// strPad is center, ljust and rjust
+func strPad(env *Environment, args []Object, align byte) Object {
+    width, err := toIndex(env, args[1])
+    if err != nil {
+        return err
+    }
+    fill := ' '
+    if len(args) > 2 {
+        s, ok := payload(args[2]).(*String)
+        if !ok {
+            return typeError("The fill character must be a unicode character, not %s", typeName(args[2]))
+        }
+        if utf8.RuneCountInString(s.Value) != 1 {
+            return typeError("The fill character must be exactly one character long")
+        }
+        fill, _ = utf8.DecodeRuneInString(s.Value)
+    }
+    s := asString(args[0]).Value
+    margin := width - utf8.RuneCountInString(s)
+    if margin <= 0 {
+        return &String{Value: s}
+    }
+    if _, err := allocation(margin, utf8.RuneLen(fill)); err != nil {
+        return err
+    }
+    left := 0
+    switch align {
+    case '>':
+        left = margin
+    case '^':
+        left = margin/2 + (margin & width & 1)
+    }
+    padding := string(fill)
+    return &String{Value: strings.Repeat(padding, left) + s + strings.Repeat(padding, margin-left)}
+}
+
+// strPartition is partition and rpartition
+func strPartition(args []Object, last bool) Object {
+    s := asString(args[0]).Value
+    sep, err := strArgument(args[1])
+    if err != nil {
+        return err
+    }
+    if sep == "" {
+        return valueError("empty separator")
+    }
+    i := strings.Index(s, sep)
+    if last {
+        i = strings.LastIndex(s, sep)
+    }
+    parts := []string{s, "", ""}
+    switch {
+    case i >= 0:
+        parts = []string{s[:i], sep, s[i+len(sep):]}
+    case last:
+        parts = []string{"", "", s}
+    }
+    return &Tuple{Elements: stringList(parts).Elements}
+}
+
+func splitLines(s string, keepends bool) []string {
+    lines := []string{}
+    text := codePoints(s)
+    start := 0
+    for i := 0; i < len(text); i++ {
+        if !isLineBreak(text[i]) {
+            continue
+        }
+        end := i
+        if text[i] == '\r' && i+1 < len(text) && text[i+1] == '\n' {
+            i++
+        }
+        if keepends {
+            end = i + 1
+        }
+        lines = append(lines, fromCodePoints(text[start:end]))
+        start = i + 1
+    }
+    if start < len(text) {
+        lines = append(lines, fromCodePoints(text[start:]))
+    }
+    return lines
+}
+
+func expandTabs(s string, tabsize int) (string, *Error) {
+    var b strings.Builder
+    column := 0
+    for _, r := range s {
+        switch r {
+        case '\t':
+            if tabsize > 0 {
+                spaces := tabsize - column%tabsize
+                if _, err := allocation(b.Len()+spaces, 1); err != nil {
+                    return "", err
+                }
+                b.WriteString(strings.Repeat(" ", spaces))
+                column += spaces
+            }
+            continue
+        case '\n', '\r':
+            column = -1
+        }
+        b.WriteRune(r)
+        column++
+    }
+    return b.String(), nil
+}
+
+// translateString runs every character through table[ord(c)]; a lookup
+// error leaves the character alone
+func translateString(env *Environment, s string, table Object) Object {
+    var b strings.Builder
+    for _, r := range s {
+        mapped := getItem(env, table, newInt(int64(r)))
+        if err, ok := mapped.(*Error); ok {
+            if err.matches(lookupErrorType) {
+                b.WriteRune(r)
+                continue
+            }
+            return err
+        }
+        switch value := payload(mapped).(type) {
+        case *NullObject:
+        case *String:
+            b.WriteString(value.Value)
+        case *Integer:
+            if !value.Value.IsInt64() || value.Value.Int64() < 0 || value.Value.Int64() > unicode.MaxRune {
+                return valueError("character mapping must be in range(0x110000)")
+            }
+            b.WriteRune(rune(value.Value.Int64()))
+        default:
+            return typeError("character mapping must return integer, None or str")
+        }
+    }
+    return &String{Value: b.String()}
+}
+
+// makeTrans is str.maketrans: a dict from code points to what they become
+func makeTrans(env *Environment, args []Object, kwargs *Dict) Object {
+    if err := checkArgs("maketrans", args, kwargs, 1, 3); err != nil {
+        return err
+    }
+    table := NewDict()
+    if len(args) == 1 {
+        source, ok := payload(args[0]).(*Dict)
+        if !ok {
+            return typeError("if you give only one argument to maketrans it must be a dict")
+        }
+        for _, entry := range source.Entries() {
+            key := entry.Key
+            switch k := payload(key).(type) {
+            case *String:
+                if utf8.RuneCountInString(k.Value) != 1 {
+                    return valueError("string keys in translate table must be of length 1")
+                }
+                r, _ := utf8.DecodeRuneInString(k.Value)
+                key = newInt(int64(r))
+            case *Integer:
+            default:
+                return typeError("keys in translate table must be strings or integers")
+            }
+            if err := table.Set(env, key, entry.Value); err != nil {
+                return err
+            }
+        }
+        return table
+    }
+
+    for i, arg := range args {
+        if _, ok := payload(arg).(*String); !ok {
+            return typeError("maketrans() argument %d must be str, not %s", i+1, typeName(arg))
+        }
+    }
+    from, to := codePoints(asString(args[0]).Value), codePoints(asString(args[1]).Value)
+    if len(from) != len(to) {
+        return valueError("the first two maketrans arguments must have equal length")
+    }
+    for i, r := range from {
+        if err := table.Set(env, newInt(int64(r)), newInt(int64(to[i]))); err != nil {
+            return err
+        }
+    }
+    if len(args) == 3 {
+        for _, r := range asString(args[2]).Value {
+            if err := table.Set(env, newInt(int64(r)), NULL); err != nil {
+                return err
+            }
+        }
+    }
+    return table
+}
+
+func init() {
+    strType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        cls, err := newClassArg("str", args)
+        if err != nil {
+            return err
+        }
+        params, err := parseArgs("str", args[1:], kwargs, []string{"object", "encoding", "errors"}, 0)
+        if err != nil {
+            return err
+        }
+        var value Object = &String{Value: ""}
+        if params[1] != nil || params[2] != nil {
+            // str(b, encoding) is b.decode(encoding)
+            options, err := codecOptions("str", params[1:])
+            if err != nil {
+                return err
+            }
+            var data []byte
+            if params[0] != nil {
+                if _, ok := payload(params[0]).(*String); ok {
+                    return typeError("decoding str is not supported")
+                }
+                var isBytes bool
+                if data, isBytes, err = bytesLike(params[0]); !isBytes {
+                    return typeError("decoding to str: need a bytes-like object, %s found", typeName(params[0]))
+                }
+                if err != nil {
+                    return err
+                }
+            }
+            value = decodeBytes(data, options[0], options[1])
+            if isError(value) {
+                return value
+            }
+        } else if params[0] != nil {
+            value = strOf(env, params[0])
+            if isError(value) {
+                return value
+            }
+            value = &String{Value: asString(value).Value}
+        }
+        return wrapBuiltinValue(cls, strType, value)
+    })
+    strType.method("__add__", 1, 1, func(env *Environment, args []Object) Object {
+        other, ok := payload(args[1]).(*String)
+        if !ok {
+            return typeError("can only concatenate str (not \"%s\") to str", typeName(args[1]))
+        }
+        return &String{Value: asString(args[0]).Value + other.Value}
+    })
+    for _, name := range []string{"__mul__", "__rmul__"} {
+        strType.method(name, 1, 1, func(env *Environment, args []Object) Object {
+            n, result := repeatCount(env, args[1])
+            if result != nil {
+                return result
+            }
+            s := asString(args[0]).Value
+            if _, err := allocation(n, len(s)); err != nil {
+                return err
+            }
+            return &String{Value: strings.Repeat(s, n)}
+        })
+    }
+    for _, operator := range []string{"==", "!=", "<", "<=", ">", ">="} {
+        operator := operator
+        strType.method(comparisonOperators[operator][0], 1, 1, func(env *Environment, args []Object) Object {
+            other, ok := payload(args[1]).(*String)
+            if !ok {
+                return NotImplemented
+            }
+            // UTF-8 sorts bytewise in code point order
+            return compareResult(operator, strings.Compare(asString(args[0]).Value, other.Value))
+        })
+    }
+    strType.method("__hash__", 0, 0, func(env *Environment, args []Object) Object {
+        return newInt(hashString(asString(args[0]).Value))
+    })
+    strType.method("__len__", 0, 0, func(env *Environment, args []Object) Object {
+        s := asString(args[0])
+        return newInt(int64(s.codePoints().length(s.Value)))
+    })
+    strType.method("__contains__", 1, 1, func(env *Environment, args []Object) Object {
+        sub, ok := payload(args[1]).(*String)
+        if !ok {
+            return typeError("'in <string>' requires string as left operand, not %s", typeName(args[1]))
+        }
+        return nativeBool(strings.Contains(asString(args[0]).Value, sub.Value))
+    })
+    strType.method("__getitem__", 1, 1, func(env *Environment, args []Object) Object {
+        s := asString(args[0])
+        text := s.codePoints()
+        n := text.length(s.Value)
+        if slice, ok := args[1].(*Slice); ok {
+            start, stop, step, err := slice.indices(env, n)
+            if err != nil {
+                return err
+            }
+            return &String{Value: text.slice(s.Value, start, stop, step)}
+        }
+        if _, ok := toBigInt(args[1]); !ok && typeOf(args[1]).lookupName("__index__") == nil {
+            return typeError("string indices must be integers, not '%s'", typeName(args[1]))
+        }
+        i, err := sequenceIndex(env, args[1], n, "string")
+        if err != nil {
+            return err
+        }
+        return &String{Value: text.slice(s.Value, i, i+1, 1)}
+    })
+    strType.method("__iter__", 0, 0, func(env *Environment, args []Object) Object {
+        s := asString(args[0]).Value
+        return newIterator(strIteratorType, func(env *Environment) (Object, bool) {
+            if s == "" {
+                return nil, false
+            }
+            _, size := decodeCodePoint(s)
+            value := s[:size]
+            s = s[size:]
+            return &String{Value: value}, true
+        })
+    })
+    strType.method("__str__", 0, 0, func(env *Environment, args []Object) Object {
+        if s, ok := args[0].(*String); ok {
+            return s
+        }
+        return &String{Value: asString(args[0]).Value}
+    })
+    strType.method("__repr__", 0, 0, func(env *Environment, args []Object) Object {
+        return &String{Value: strRepr(asString(args[0]).Value)}
+    })
+    initStrMethods()
+}
+
+func initStrMethods() {
+    strType.define("split", func(env *Environment, args []Object, kwargs *Dict) Object {
+        return strSplit(env, "split", args, kwargs)
+    })
+    strType.define("rsplit", func(env *Environment, args []Object, kwargs *Dict) Object {
+        return strSplit(env, "rsplit", args, kwargs)
+    })
+    strType.define("splitlines", func(env *Environment, args []Object, kwargs *Dict) Object {
+        params, err := parseArgs("splitlines", args[1:], kwargs, []string{"keepends"}, 0)
+        if err != nil {
+            return err
+        }
+        keepends := false
+        if params[0] != nil {
+            n, err := toIndex(env, params[0])
+            if err != nil {
+                return err
+            }
+            keepends = n != 0
+        }
+        return stringList(splitLines(asString(args[0]).Value, keepends))
+    })
+    strType.method("join", 1, 1, func(env *Environment, args []Object) Object {
+        if typeOf(args[1]).lookupName("__iter__") == nil && typeOf(args[1]).lookupName("__getitem__") == nil {
+            return typeError("can only join an iterable")
+        }
+        items, err := iterableToSlice(env, args[1])
+        if err != nil {
+            return err
+        }
+        parts := make([]string, len(items))
+        for i, item := range items {
+            s, ok := payload(item).(*String)
+            if !ok {
+                return typeError("sequence item %d: expected str instance, %s found", i, typeName(item))
+            }
+            parts[i] = s.Value
+        }
+        return &String{Value: strings.Join(parts, asString(args[0]).Value)}
+    })
+    strType.method("strip", 0, 1, func(env *Environment, args []Object) Object {
+        return strStrip(args, true, true)
+    })
+    strType.method("lstrip", 0, 1, func(env *Environment, args []Object) Object {
+        return strStrip(args, true, false)
+    })
+    strType.method("rstrip", 0, 1, func(env *Environment, args []Object) Object {
+        return strStrip(args, false, true)
+    })
+    strType.method("replace", 2, 3, func(env *Environment, args []Object) Object {
+        for i, arg := range args[1:3] {
+            if _, ok := payload(arg).(*String); !ok {
+                return typeError("replace() argument %d must be str, not %s", i+1, typeName(arg))
+            }
+        }
+        count := -1
+        if len(args) > 3 {
+            n, err := toIndex(env, args[3])
+            if err != nil {
+                return err
+            }
+            count = n
+        }
+        return &String{Value: strings.Replace(asString(args[0]).Value, asString(args[1]).Value, asString(args[2]).Value, count)}
+    })
+    strType.method("find", 1, 3, func(env *Environment, args []Object) Object {
+        return strFind(env, args, false, false)
+    })
+    strType.method("rfind", 1, 3, func(env *Environment, args []Object) Object {
+        return strFind(env, args, true, false)
+    })
+    strType.method("index", 1, 3, func(env *Environment, args []Object) Object {
+        return strFind(env, args, false, true)
+    })
+    strType.method("rindex", 1, 3, func(env *Environment, args []Object) Object {
+        return strFind(env, args, true, true)
+    })
+    strType.method("count", 1, 3, func(env *Environment, args []Object) Object {
+        text := codePoints(asString(args[0]).Value)
+        sub, err := strArgument(args[1])
+        if err != nil {
+            return err
+        }
+        start, end, err := substringBounds(env, args[2:], len(text))
+        if err != nil {
+            return err
+        }
+        return newInt(int64(countRunes(text, codePoints(sub), start, end)))
+    })
+    strType.method("startswith", 1, 3, func(env *Environment, args []Object) Object {
+        return strAffix(env, "startswith", args, false)
+    })
+    strType.method("endswith", 1, 3, func(env *Environment, args []Object) Object {
+        return strAffix(env, "endswith", args, true)
+    })
+    strType.method("removeprefix", 1, 1, func(env *Environment, args []Object) Object {
+        prefix, ok := payload(args[1]).(*String)
+        if !ok {
+            return typeError("removeprefix() argument must be str, not %s", typeName(args[1]))
+        }
+        return &String{Value: strings.TrimPrefix(asString(args[0]).Value, prefix.Value)}
+    })
+    strType.method("removesuffix", 1, 1, func(env *Environment, args []Object) Object {
+        suffix, ok := payload(args[1]).(*String)
+        if !ok {
+            return typeError("removesuffix() argument must be str, not %s", typeName(args[1]))
+        }
+        return &String{Value: strings.TrimSuffix(asString(args[0]).Value, suffix.Value)}
+    })
+    strType.method("partition", 1, 1, func(env *Environment, args []Object) Object {
+        return strPartition(args, false)
+    })
+    strType.method("rpartition", 1, 1, func(env *Environment, args []Object) Object {
+        return strPartition(args, true)
+    })
+
+    strType.method("upper", 0, 0, func(env *Environment, args []Object) Object {
+        var b strings.Builder
+        for _, r := range asString(args[0]).Value {
+            b.WriteString(upperRune(r))
+        }
+        return &String{Value: b.String()}
+    })
+    strType.method("lower", 0, 0, func(env *Environment, args []Object) Object {
+        text := codePoints(asString(args[0]).Value)
+        var b strings.Builder
+        for i := range text {
+            b.WriteString(lowerRune(text, i))
+        }
+        return &String{Value: b.String()}
+    })
+    strType.method("casefold", 0, 0, func(env *Environment, args []Object) Object {
+        var b strings.Builder
+        for _, r := range asString(args[0]).Value {
+            if s, ok := specialFold[r]; ok {
+                b.WriteString(s)
+            } else {
+                b.WriteRune(unicode.ToLower(unicode.ToUpper(r)))
+            }
+        }
+        return &String{Value: b.String()}
+    })
+    strType.method("swapcase", 0, 0, func(env *Environment, args []Object) Object {
+        text := codePoints(asString(args[0]).Value)
+        var b strings.Builder
+        for i, r := range text {
+            switch {
+            case unicode.IsUpper(r):
+                b.WriteString(lowerRune(text, i))
+            case unicode.IsLower(r):
+                b.WriteString(upperRune(r))
+            default:
+                b.WriteRune(r)
+            }
+        }
+        return &String{Value: b.String()}
+    })
+    strType.method("title", 0, 0, func(env *Environment, args []Object) Object {
+        text := codePoints(asString(args[0]).Value)
+        var b strings.Builder
+        previousCased := false
+        for i, r := range text {
+            if previousCased {
+                b.WriteString(lowerRune(text, i))
+            } else {
+                b.WriteString(titleRune(r))
+            }
+            previousCased = isCased(r)
+        }
+        return &String{Value: b.String()}
+    })
+    strType.method("capitalize", 0, 0, func(env *Environment, args []Object) Object {
+        text := codePoints(asString(args[0]).Value)
+        var b strings.Builder
+        for i, r := range text {
+            if i == 0 {
+                b.WriteString(titleRune(r))
+            } else {
+                b.WriteString(lowerRune(text, i))
+            }
+        }
+        return &String{Value: b.String()}
+    })
+
+    for name, predicate := range strPredicates {
+        name, predicate := name, predicate
+        strType.method(name, 0, 0, func(env *Environment, args []Object) Object {
+            s := asString(args[0]).Value
+            for _, r := range s {
+                if !predicate(r) {
+                    return FALSE
+                }
+            }
+            // the empty string is printable and ASCII, and nothing else
+            return nativeBool(s != "" || name == "isprintable" || name == "isascii")
+        })
+    }
+    strType.method("isidentifier", 0, 0, func(env *Environment, args []Object) Object {
+        return nativeBool(isIdentifier(asString(args[0]).Value))
+    })
+    strType.method("islower", 0, 0, func(env *Environment, args []Object) Object {
+        cased := false
+        for _, r := range asString(args[0]).Value {
+            if unicode.IsUpper(r) || unicode.IsTitle(r) {
+                return FALSE
+            }
+            cased = cased || unicode.IsLower(r)
+        }
+        return nativeBool(cased)
+    })
+    strType.method("isupper", 0, 0, func(env *Environment, args []Object) Object {
+        cased := false
+        for _, r := range asString(args[0]).Value {
+            if unicode.IsLower(r) || unicode.IsTitle(r) {
+                return FALSE
+            }
+            cased = cased || unicode.IsUpper(r)
+        }
+        return nativeBool(cased)
+    })
+    strType.method("istitle", 0, 0, func(env *Environment, args []Object) Object {
+        cased, previousCased := false, false
+        for _, r := range asString(args[0]).Value {
+            switch {
+            case unicode.IsUpper(r) || unicode.IsTitle(r):
+                if previousCased {
+                    return FALSE
+                }
+                previousCased, cased = true, true
+            case unicode.IsLower(r):
+                if !previousCased {
+                    return FALSE
+                }
+                previousCased, cased = true, true
+            default:
+                previousCased = false
+            }
+        }
+        return nativeBool(cased)
+    })
+
+    strType.method("center", 1, 2, func(env *Environment, args []Object) Object {
+        return strPad(env, args, '^')
+    })
+    strType.method("ljust", 1, 2, func(env *Environment, args []Object) Object {
+        return strPad(env, args, '<')
+    })
+    strType.method("rjust", 1, 2, func(env *Environment, args []Object) Object {
+        return strPad(env, args, '>')
+    })
+    strType.method("zfill", 1, 1, func(env *Environment, args []Object) Object {
+        width, err := toIndex(env, args[1])
+        if err != nil {
+            return err
+        }
+        s := asString(args[0]).Value
+        fill := width - utf8.RuneCountInString(s)
+        if fill <= 0 {
+            return &String{Value: s}
+        }
+        if _, err := allocation(fill, 1); err != nil {
+            return err
+        }
+        sign := ""
+        if s != "" && (s[0] == '+' || s[0] == '-') {
+            sign, s = s[:1], s[1:]
+        }
+        return &String{Value: sign + strings.Repeat("0", fill) + s}
+    })
+    strType.define("expandtabs", func(env *Environment, args []Object, kwargs *Dict) Object {
+        params, err := parseArgs("expandtabs", args[1:], kwargs, []string{"tabsize"}, 0)
+        if err != nil {
+            return err
+        }
+        tabsize := 8
+        if params[0] != nil {
+            if tabsize, err = toIndex(env, params[0]); err != nil {
+                return err
+            }
+            if tabsize > math.MaxInt32 {
+                return overflowError("Python int too large to convert to C int")
+            }
+        }
+        expanded, err := expandTabs(asString(args[0]).Value, tabsize)
+        if err != nil {
+            return err
+        }
+        return &String{Value: expanded}
+    })
+
+    strType.define("encode", func(env *Environment, args []Object, kwargs *Dict) Object {
+        params, err := parseArgs("encode", args[1:], kwargs, []string{"encoding", "errors"}, 0)
+        if err != nil {
+            return err
+        }
+        options := []string{"utf-8", "strict"}
+        for i, param := range params {
+            if param == nil {
+                continue
+            }
+            s, ok := payload(param).(*String)
+            if !ok {
+                return typeError("encode() argument '%s' must be str, not %s", []string{"encoding", "errors"}[i], typeName(param))
+            }
+            options[i] = s.Value
+        }
+        return encodeString(asString(args[0]).Value, options[0], options[1])
+    })
+    strType.method("translate", 1, 1, func(env *Environment, args []Object) Object {
+        return translateString(env, asString(args[0]).Value, args[1])
+    })
+    strType.Dict.SetStr("maketrans", &StaticMethod{Function: &Builtin{Name: "maketrans", Fn: makeTrans}, Dict: NewDict()})
+}
@@ -0,0 +1,58 @@
+// Comments in this file are inspired by Norma - Louis's assistant, who knows the state of the whole firm
+
+package evaluator
+
+import (
+    "bufio"
+    "math"
+    "runtime"
+    "sort"
+    "strings"
+)
+
+// sys: the interpreter's own bookkeeping, open to the program it runs
+
+func init() {
+    registerModule("sys", func(m *Module) {
+        interp := m.Env.interp
+        m.Env.Set("modules", interp.modules)
+        m.Env.Set("path", interp.path)
+        m.Env.Set("platform", &String{Value: runtime.GOOS})
+        m.Env.Set("maxsize", newInt(math.MaxInt64))
+        for _, stream := range []*File{
+            standardStream("<stdin>", bufio.NewReader(stdinReader{}), nil, "surrogateescape"),
+            standardStream("<stdout>", nil, stdoutWriter{}, "surrogateescape"),
+            standardStream("<stderr>", nil, stderrWriter{}, "backslashreplace"),
+        } {
+            name := strings.Trim(stream.name.(*String).Value, "<>")
+            m.Env.Set(name, stream)
+            m.Env.Set("__"+name+"__", stream)
+        }
+
+        names := []string{}
+        for name := range nativeModules {
+            names = append(names, name)
+        }
+        sort.Strings(names)
+        builtinNames := &Tuple{}
+        for _, name := range names {
+            builtinNames.Elements = append(builtinNames.Elements, &String{Value: name})
+        }
+        m.Env.Set("builtin_module_names", builtinNames)
+    })
+}
+
+// stream is sys.stdout, sys.stderr or sys.stdin - whatever the program has
+// left there, which print() and input() have to go through
+func (i *interpreter) stream(name string) (Object, *Error) {
+    if stream, ok := i.sys.Env.store[name]; ok {
+        return stream, nil
+    }
+    return nil, runtimeError("lost sys.%s", name)
+}
+
+// setStandardStream swaps both sys.<name> and the sys.__<name>__ kept to restore it
+func (i *interpreter) setStandardStream(name string, stream *File) {
+    i.sys.Env.Set(name, stream)
+    i.sys.Env.Set("__"+name+"__", stream)
+}
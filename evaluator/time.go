@@ -0,0 +1,945 @@
+// Comments in this file are inspired by Faye Richardson - she came in with a stopwatch, and timed every billable minute
+
+package evaluator
+
+import (
+    "fmt"
+    "math"
+    "math/big"
+    "regexp"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// time: clocks, sleep, struct_time, and the strftime/strptime directives
+// datetime formats with too. Go's time package does the arithmetic; the
+// formatting follows the C library's, directive by directive.
+
+var (
+    structTimeType = newBuiltinClass("struct_time", tupleType)
+    clockStart     = time.Now() // what monotonic() and perf_counter() count from
+)
+
+var (
+    dayNames   = []string{"Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday", "Sunday"}
+    monthNames = []string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"}
+)
+
+var structTimeFields = []string{"tm_year", "tm_mon", "tm_mday", "tm_hour", "tm_min", "tm_sec", "tm_wday", "tm_yday", "tm_isdst"}
+
+func init() {
+    registerModule("time", buildTime)
+
+    structTimeType.Dict.SetStr("__module__", &String{Value: "time"})
+    initStructTime()
+}
+
+// wallClock is a broken-down time, whether it came from a struct_time or a
+// datetime
+type wallClock struct {
+    year, month, day     int
+    hour, minute, second int
+    microsecond          int
+    weekday              int // Monday is 0
+    yearday              int // January 1st is 1
+    isdst                int // -1 when nobody knows
+    zone                 Object // tm_zone or tzname(), None when there isn't one
+    offset               Object // tm_gmtoff in seconds, or utcoffset() as a timedelta; None when unknown
+}
+
+func clockOf(t time.Time) wallClock {
+    name, offset := t.Zone()
+    isdst := 0
+    if t.IsDST() {
+        isdst = 1
+    }
+    return wallClock{
+        year: t.Year(), month: int(t.Month()), day: t.Day(),
+        hour: t.Hour(), minute: t.Minute(), second: t.Second(), microsecond: t.Nanosecond() / 1000,
+        weekday: (int(t.Weekday()) + 6) % 7, yearday: t.YearDay(), isdst: isdst,
+        zone: &String{Value: name}, offset: newInt(int64(offset)),
+    }
+}
+
+func newStructTime(c wallClock) Object {
+    values := []int{c.year, c.month, c.day, c.hour, c.minute, c.second, c.weekday, c.yearday, c.isdst}
+    elements := make([]Object, len(values))
+    for i, v := range values {
+        elements[i] = newInt(int64(v))
+    }
+    st := &Instance{Class: structTimeType, Dict: NewDict(), Value: &Tuple{Elements: elements}}
+    st.Dict.SetStr("tm_zone", nilToNone(c.zone))
+    st.Dict.SetStr("tm_gmtoff", nilToNone(c.offset))
+    return st
+}
+
+func initStructTime() {
+    structTimeType.defineNew(func(env *Environment, args []Object, kwargs *Dict) Object {
+        cls, err := newClassArg("struct_time", args)
+        if err != nil {
+            return err
+        }
+        values, err := parseArgs("struct_time", args[1:], kwargs, []string{"sequence", "dict"}, 1)
+        if err != nil {
+            return err
+        }
+        elements, err := iterableToSlice(env, values[0])
+        if err != nil {
+            return typeError("constructor requires a sequence")
+        }
+        switch n := len(elements); {
+        case n < 9:
+            return typeError("time.struct_time() takes an at least 9-sequence (%d-sequence given)", n)
+        case n > 11:
+            return typeError("time.struct_time() takes an at most 11-sequence (%d-sequence given)", n)
+        }
+        st := &Instance{Class: cls, Dict: NewDict(), Value: &Tuple{Elements: elements[:9]}}
+        extra := append(elements[9:], NULL, NULL)
+        st.Dict.SetStr("tm_zone", extra[0])
+        st.Dict.SetStr("tm_gmtoff", extra[1])
+        return st
+    })
+    for i, name := range structTimeFields {
+        i := i
+        structTimeType.property(name, func(self Object) Object {
+            return payload(self).(*Tuple).Elements[i]
+        })
+    }
+    structTimeType.method("__repr__", 0, 0, func(env *Environment, args []Object) Object {
+        parts := make([]string, len(structTimeFields))
+        for i, element := range payload(args[0]).(*Tuple).Elements {
+            s, err := reprString(env, element)
+            if err != nil {
+                return err
+            }
+            parts[i] = structTimeFields[i] + "=" + s
+        }
+        return &String{Value: "time.struct_time(" + strings.Join(parts, ", ") + ")"}
+    })
+    structTimeType.Dict.SetStr("n_fields", newInt(11))
+    structTimeType.Dict.SetStr("n_sequence_fields", newInt(9))
+    structTimeType.Dict.SetStr("n_unnamed_fields", newInt(0))
+}
+
+// timeTuple reads a struct_time, or any 9-tuple, and checks it over the
+// way strftime() does; out-of-range zeroes are let through as the first
+func timeTuple(env *Environment, obj Object, fname string) (wallClock, *Error) {
+    tuple, ok := payload(obj).(*Tuple)
+    if !ok {
+        return wallClock{}, typeError("Tuple or struct_time argument required")
+    }
+    if len(tuple.Elements) != 9 {
+        return wallClock{}, typeError("%s(): illegal time tuple argument", fname)
+    }
+    values := make([]int, 9)
+    for i, element := range tuple.Elements {
+        n, err := toIndex(env, element)
+        if err != nil {
+            return wallClock{}, err
+        }
+        values[i] = n
+    }
+    c := wallClock{year: values[0], month: values[1], day: values[2], hour: values[3], minute: values[4], second: values[5],
+        weekday: values[6], yearday: values[7], isdst: values[8], zone: NULL, offset: NULL}
+    if inst, ok := obj.(*Instance); ok && inst.Class.isSubclass(structTimeType) {
+        c.zone, _ = inst.Dict.GetStr("tm_zone")
+        c.offset, _ = inst.Dict.GetStr("tm_gmtoff")
+    }
+    checks := []struct {
+        value    *int
+        low, top int
+        what     string
+    }{
+        {&c.month, 1, 12, "month out of range"},
+        {&c.day, 1, 31, "day of month out of range"},
+        {&c.hour, 0, 23, "hour out of range"},
+        {&c.minute, 0, 59, "minute out of range"},
+        {&c.second, 0, 61, "seconds out of range"},
+        {&c.yearday, 1, 366, "day of year out of range"},
+    }
+    for _, check := range checks {
+        if *check.value == 0 && check.low == 1 {
+            *check.value = 1
+        }
+        if *check.value < check.low || *check.value > check.top {
+            return wallClock{}, valueError("%s", check.what)
+        }
+    }
+    if c.weekday < 0 {
+        return wallClock{}, valueError("day of week out of range")
+    }
+    c.weekday %= 7
+    c.isdst = max(-1, min(c.isdst, 1))
+    return c, nil
+}
+
+// timestamp reads the seconds gmtime() and friends take, None being now
+func timestamp(env *Environment, obj Object) (time.Time, *Error) {
+    if obj == nil || obj == NULL {
+        return time.Now(), nil
+    }
+    seconds, err := secondsArgument(obj)
+    if err != nil {
+        return time.Time{}, err
+    }
+    if math.IsNaN(seconds) {
+        return time.Time{}, valueError("Invalid value NaN (not a number)")
+    }
+    if math.Abs(seconds) > 1<<62 {
+        return time.Time{}, overflowError("timestamp out of range for platform time_t")
+    }
+    return time.Unix(int64(math.Floor(seconds)), 0), nil
+}
+
+func secondsArgument(obj Object) (float64, *Error) {
+    seconds, ok, err := toFloat(obj)
+    if err != nil {
+        return 0, err
+    }
+    if !ok {
+        return 0, typeError("'%s' object cannot be interpreted as an integer", typeName(obj))
+    }
+    return seconds, nil
+}
+
+// sleepDuration is time.sleep's argument in nanoseconds, which like
+// CPython's _PyTime_t have to fit an int64
+func sleepDuration(obj Object) (time.Duration, *Error) {
+    if n, ok := toBigInt(obj); ok {
+        ns := new(big.Int).Mul(n, big.NewInt(int64(time.Second)))
+        if !ns.IsInt64() {
+            return 0, overflowError("timestamp too large to convert to C _PyTime_t")
+        }
+        return time.Duration(ns.Int64()), nil
+    }
+    seconds, err := secondsArgument(obj)
+    if err != nil {
+        return 0, err
+    }
+    if math.IsNaN(seconds) {
+        return 0, valueError("Invalid value NaN (not a number)")
+    }
+    ns := seconds * float64(time.Second)
+    if ns >= math.MaxInt64 || ns < math.MinInt64 {
+        return 0, overflowError("timestamp out of range for platform time_t")
+    }
+    return time.Duration(ns), nil
+}
+
+// sleep waits, waking early with a KeyboardInterrupt once RunContext's
+// context is done
+func sleep(env *Environment, d time.Duration) Object {
+    timer := time.NewTimer(d)
+    defer timer.Stop()
+    if env.interp.ctx == nil {
+        <-timer.C
+        return NULL
+    }
+    select {
+    case <-timer.C:
+        return NULL
+    case <-env.interp.ctx.Done():
+        return env.interp.interrupted()
+    }
+}
+
+// localZones is what the C library says about the local zone: standard and
+// daylight offsets west of UTC, and their names, after CPython's
+// init_timezone, which looks at January and July
+func localZones() (timezone, altzone int, names [2]string) {
+    year := time.Now().Year()
+    janName, janOffset := time.Date(year, 1, 1, 0, 0, 0, 0, time.Local).Zone()
+    julName, julOffset := time.Date(year, 7, 1, 0, 0, 0, 0, time.Local).Zone()
+    if -janOffset < -julOffset {
+        return -julOffset, -janOffset, [2]string{julName, janName}
+    }
+    return -janOffset, -julOffset, [2]string{janName, julName}
+}
+
+func buildTime(m *Module) {
+    m.Env.Set("struct_time", structTimeType)
+    timezone, altzone, names := localZones()
+    m.Env.Set("timezone", newInt(int64(timezone)))
+    m.Env.Set("altzone", newInt(int64(altzone)))
+    daylight := 0
+    if timezone != altzone {
+        daylight = 1
+    }
+    m.Env.Set("daylight", newInt(int64(daylight)))
+    m.Env.Set("tzname", &Tuple{Elements: []Object{&String{Value: names[0]}, &String{Value: names[1]}}})
+
+    seconds := func(name string, d func() time.Duration) {
+        m.function(name, func(env *Environment, args []Object, kwargs *Dict) Object {
+            if err := checkArgs(name, args, kwargs, 0, 0); err != nil {
+                return err
+            }
+            return &Float{Value: d().Seconds()}
+        })
+        m.function(name+"_ns", func(env *Environment, args []Object, kwargs *Dict) Object {
+            if err := checkArgs(name+"_ns", args, kwargs, 0, 0); err != nil {
+                return err
+            }
+            return newInt(d().Nanoseconds())
+        })
+    }
+    seconds("time", func() time.Duration { return time.Duration(time.Now().UnixNano()) })
+    seconds("monotonic", func() time.Duration { return time.Since(clockStart) })
+    seconds("perf_counter", func() time.Duration { return time.Since(clockStart) })
+
+    m.function("sleep", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := argumentCount("sleep", args, kwargs, 1); err != nil {
+            return err
+        }
+        d, err := sleepDuration(args[0])
+        if err != nil {
+            return err
+        }
+        if d < 0 {
+            return valueError("sleep length must be non-negative")
+        }
+        return sleep(env, d)
+    })
+
+    broken := func(name string, in func(t time.Time) wallClock) {
+        m.function(name, func(env *Environment, args []Object, kwargs *Dict) Object {
+            if err := checkArgs(name, args, kwargs, 0, 1); err != nil {
+                return err
+            }
+            t, err := timestamp(env, argOrNil(args, 0))
+            if err != nil {
+                return err
+            }
+            return newStructTime(in(t))
+        })
+    }
+    broken("gmtime", func(t time.Time) wallClock {
+        c := clockOf(t.UTC())
+        c.zone = &String{Value: "GMT"}
+        return c
+    })
+    broken("localtime", func(t time.Time) wallClock { return clockOf(t.Local()) })
+
+    m.function("mktime", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := argumentCount("mktime", args, kwargs, 1); err != nil {
+            return err
+        }
+        tuple, ok := payload(args[0]).(*Tuple)
+        if !ok {
+            return typeError("Tuple or struct_time argument required")
+        }
+        if len(tuple.Elements) != 9 {
+            return typeError("mktime(): illegal time tuple argument")
+        }
+        values := make([]int, 6)
+        for i := range values {
+            n, err := toIndex(env, tuple.Elements[i])
+            if err != nil {
+                return err
+            }
+            values[i] = n
+        }
+        t := time.Date(values[0], time.Month(values[1]), values[2], values[3], values[4], values[5], 0, time.Local)
+        return &Float{Value: float64(t.Unix())}
+    })
+    m.function("asctime", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("asctime", args, kwargs, 0, 1); err != nil {
+            return err
+        }
+        c := clockOf(time.Now().Local())
+        if len(args) == 1 {
+            var err *Error
+            if c, err = timeTuple(env, args[0], "asctime"); err != nil {
+                return err
+            }
+        }
+        return &String{Value: asctime(c)}
+    })
+    m.function("ctime", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("ctime", args, kwargs, 0, 1); err != nil {
+            return err
+        }
+        t, err := timestamp(env, argOrNil(args, 0))
+        if err != nil {
+            return err
+        }
+        return &String{Value: asctime(clockOf(t.Local()))}
+    })
+
+    m.function("strftime", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("strftime", args, kwargs, 1, 2); err != nil {
+            return err
+        }
+        format, ok := payload(args[0]).(*String)
+        if !ok {
+            return typeError("strftime() argument 1 must be str, not %s", typeName(args[0]))
+        }
+        c := clockOf(time.Now().Local())
+        if len(args) == 2 {
+            var err *Error
+            if c, err = timeTuple(env, args[1], "strftime"); err != nil {
+                return err
+            }
+        }
+        text, err := strftime(env, format.Value, c, false)
+        if err != nil {
+            return err
+        }
+        return &String{Value: text}
+    })
+    m.function("strptime", func(env *Environment, args []Object, kwargs *Dict) Object {
+        if err := checkArgs("strptime", args, kwargs, 1, 2); err != nil {
+            return err
+        }
+        data, ok := payload(args[0]).(*String)
+        if !ok {
+            return typeError("strptime() argument 0 must be str, not %s", typeOf(args[0]).qualname())
+        }
+        format := "%a %b %d %H:%M:%S %Y"
+        if len(args) == 2 {
+            s, ok := payload(args[1]).(*String)
+            if !ok {
+                return typeError("strptime() argument 1 must be str, not %s", typeOf(args[1]).qualname())
+            }
+            format = s.Value
+        }
+        c, err := strptime(data.Value, format)
+        if err != nil {
+            return err
+        }
+        c.microsecond = 0
+        if offset, ok := c.offset.(*TimeDelta); ok {
+            c.offset = newInt(offset.days*86400 + offset.seconds)
+        }
+        return newStructTime(c)
+    })
+}
+
+func argOrNil(args []Object, i int) Object {
+    if i < len(args) {
+        return args[i]
+    }
+    return nil
+}
+
+// asctime is "Thu Jan  1 00:00:00 1970", the C library's one fixed format
+func asctime(c wallClock) string {
+    return fmt.Sprintf("%.3s %.3s%3d %02d:%02d:%02d %d", dayNames[c.weekday], monthNames[c.month-1], c.day, c.hour, c.minute, c.second, c.year)
+}
+
+// strftime formats c the way glibc does. datetime passes withMicros for
+// the %f it understands; the C library leaves %f alone, like anything else
+// it doesn't know.
+func strftime(env *Environment, format string, c wallClock, withMicros bool) (string, *Error) {
+    var out strings.Builder
+    sundayWeekday := (c.weekday + 1) % 7
+    for i := 0; i < len(format); i++ {
+        if format[i] != '%' || i+1 == len(format) {
+            out.WriteByte(format[i])
+            continue
+        }
+        start := i
+        i++
+        flag := byte(0)
+        if strings.IndexByte("-_0^#", format[i]) >= 0 && i+1 < len(format) {
+            flag = format[i]
+            i++
+        }
+        number := func(n, width int, pad byte) {
+            switch flag {
+            case '-':
+                width = 0
+            case '_':
+                pad = ' '
+            case '0':
+                pad = '0'
+            }
+            s := strconv.Itoa(n)
+            for len(s) < width {
+                s = string(pad) + s
+            }
+            out.WriteString(s)
+        }
+        text := func(s string) {
+            if flag == '^' || flag == '#' {
+                s = strings.ToUpper(s)
+            }
+            out.WriteString(s)
+        }
+        nested := func(f string) {
+            s, _ := strftime(env, f, c, withMicros)
+            out.WriteString(s)
+        }
+        hour12 := (c.hour+11)%12 + 1
+        switch format[i] {
+        case 'a':
+            text(dayNames[c.weekday][:3])
+        case 'A':
+            text(dayNames[c.weekday])
+        case 'b', 'h':
+            text(monthNames[c.month-1][:3])
+        case 'B':
+            text(monthNames[c.month-1])
+        case 'c':
+            nested("%a %b %e %H:%M:%S %Y")
+        case 'C':
+            number(c.year/100, 2, '0')
+        case 'd':
+            number(c.day, 2, '0')
+        case 'D', 'x':
+            nested("%m/%d/%y")
+        case 'e':
+            number(c.day, 2, ' ')
+        case 'F':
+            nested("%Y-%m-%d")
+        case 'f':
+            if !withMicros {
+                out.WriteString(format[start : i+1])
+                break
+            }
+            number(c.microsecond, 6, '0')
+        case 'G', 'g', 'V':
+            year, week := time.Date(c.year, time.Month(c.month), c.day, 12, 0, 0, 0, time.UTC).ISOWeek()
+            switch format[i] {
+            case 'G':
+                number(year, 1, '0')
+            case 'g':
+                number(year%100, 2, '0')
+            default:
+                number(week, 2, '0')
+            }
+        case 'H':
+            number(c.hour, 2, '0')
+        case 'I':
+            number(hour12, 2, '0')
+        case 'j':
+            number(c.yearday, 3, '0')
+        case 'k':
+            number(c.hour, 2, ' ')
+        case 'l':
+            number(hour12, 2, ' ')
+        case 'm':
+            number(c.month, 2, '0')
+        case 'M':
+            number(c.minute, 2, '0')
+        case 'n':
+            out.WriteByte('\n')
+        case 'p', 'P':
+            half := "AM"
+            if c.hour >= 12 {
+                half = "PM"
+            }
+            if format[i] == 'P' {
+                half = strings.ToLower(half)
+            }
+            text(half)
+        case 'r':
+            nested("%I:%M:%S %p")
+        case 'R':
+            nested("%H:%M")
+        case 's':
+            t := time.Date(c.year, time.Month(c.month), c.day, c.hour, c.minute, c.second, 0, time.Local)
+            number(int(t.Unix()), 1, '0')
+        case 'S':
+            number(c.second, 2, '0')
+        case 't':
+            out.WriteByte('\t')
+        case 'T', 'X':
+            nested("%H:%M:%S")
+        case 'u':
+            number(c.weekday+1, 1, '0')
+        case 'U':
+            number((c.yearday-1+7-sundayWeekday)/7, 2, '0')
+        case 'w':
+            number(sundayWeekday, 1, '0')
+        case 'W':
+            number((c.yearday-1+7-c.weekday)/7, 2, '0')
+        case 'y':
+            number(c.year%100, 2, '0')
+        case 'Y':
+            number(c.year, 1, '0')
+        case 'z':
+            s, err := formatOffset(env, c, "")
+            if err != nil {
+                return "", err
+            }
+            out.WriteString(s)
+        case 'Z':
+            if s, ok := c.zone.(*String); ok {
+                text(s.Value)
+            }
+        case '%':
+            out.WriteByte('%')
+        default:
+            out.WriteString(format[start : i+1])
+        }
+    }
+    return out.String(), nil
+}
+
+// formatOffset is %z: +HHMM, with seconds and microseconds only when a
+// datetime's offset has them
+func formatOffset(env *Environment, c wallClock, sep string) (string, *Error) {
+    if c.zone == NULL && c.offset != nil {
+        if _, ok := c.offset.(*TimeDelta); !ok {
+            return "", nil // a struct_time without a zone has no offset to show
+        }
+    }
+    var micros int64
+    switch offset := c.offset.(type) {
+    case *TimeDelta:
+        micros = offset.total()
+    case nil:
+        return "", nil
+    default:
+        if offset == NULL {
+            return "", nil
+        }
+        n, err := toIndex(env, offset)
+        if err != nil {
+            return "", err
+        }
+        micros = int64(n) * 1e6
+    }
+    sign := '+'
+    if micros < 0 {
+        sign, micros = '-', -micros
+    }
+    seconds := micros / 1e6
+    s := fmt.Sprintf("%c%02d%s%02d", sign, seconds/3600, sep, seconds/60%60)
+    if micros%60e6 != 0 {
+        s += fmt.Sprintf("%s%02d", sep, seconds%60)
+        if micros%1e6 != 0 {
+            s += fmt.Sprintf(".%06d", micros%1e6)
+        }
+    }
+    return s, nil
+}
+
+// strptimePatterns are _strptime's regular expressions, one per directive
+var strptimePatterns = map[byte]string{
+    'd': `3[01]|[12]\d|0[1-9]|[1-9]| [1-9]`,
+    'f': `[0-9]{1,6}`,
+    'H': `2[0-3]|[0-1]\d|\d`,
+    'I': `1[0-2]|0[1-9]|[1-9]`,
+    'G': `\d\d\d\d`,
+    'j': `36[0-6]|3[0-5]\d|[12]\d\d|0[1-9]\d|00[1-9]|[1-9]\d|0[1-9]|[1-9]`,
+    'm': `1[0-2]|0[1-9]|[1-9]`,
+    'M': `[0-5]\d|\d`,
+    'S': `6[0-1]|[0-5]\d|\d`,
+    'U': `5[0-3]|[0-4]\d|\d`,
+    'W': `5[0-3]|[0-4]\d|\d`,
+    'w': `[0-6]`,
+    'u': `[1-7]`,
+    'V': `5[0-3]|0[1-9]|[1-4]\d|\d`,
+    'y': `\d\d`,
+    'Y': `\d\d\d\d`,
+    'z': `[+-]\d\d:?[0-5]\d(?::?[0-5]\d(?:\.\d{1,6})?)?|(?-i:Z)`,
+    'p': `am|pm`,
+    'a': namesPattern(dayNames, 3),
+    'A': namesPattern(dayNames, 0),
+    'b': namesPattern(monthNames, 3),
+    'B': namesPattern(monthNames, 0),
+}
+
+// strptimeComposites are the directives that stand for whole formats
+var strptimeComposites = map[byte]string{'c': "%a %b %d %H:%M:%S %Y", 'x': "%m/%d/%y", 'X': "%H:%M:%S"}
+
+// namesPattern matches any of names, cut to size when it isn't 0, the
+// longest alternatives first
+func namesPattern(names []string, size int) string {
+    choices := make([]string, len(names))
+    for i, name := range names {
+        if size > 0 {
+            name = name[:size]
+        }
+        choices[i] = strings.ToLower(name)
+    }
+    sort.SliceStable(choices, func(i, j int) bool { return len(choices[i]) > len(choices[j]) })
+    return strings.Join(choices, "|")
+}
+
+// zoneNames are the %Z names strptime knows: UTC, GMT and the local ones,
+// standard first and then daylight
+func zoneNames() (standard, daylight []string) {
+    timezone, altzone, names := localZones()
+    standard = []string{"utc", "gmt", strings.ToLower(names[0])}
+    if timezone != altzone {
+        daylight = []string{strings.ToLower(names[1])}
+    }
+    return standard, daylight
+}
+
+// strptimeRegexp turns format into a regular expression with a group for
+// each directive, named after it
+func strptimeRegexp(format, whole string) (string, *Error) {
+    var pattern strings.Builder
+    for i := 0; i < len(format); i++ {
+        switch c := format[i]; {
+        case c == '%':
+            if i+1 == len(format) {
+                return "", valueError("stray %% in format '%s'", whole)
+            }
+            i++
+            directive := format[i]
+            if composite, ok := strptimeComposites[directive]; ok {
+                inner, err := strptimeRegexp(composite, whole)
+                if err != nil {
+                    return "", err
+                }
+                pattern.WriteString(inner)
+                continue
+            }
+            if directive == '%' {
+                pattern.WriteByte('%')
+                continue
+            }
+            body, ok := strptimePatterns[directive]
+            if directive == 'Z' {
+                standard, daylight := zoneNames()
+                body, ok = namesPattern(append(standard, daylight...), 0), true
+            }
+            if !ok {
+                return "", valueError("'%c' is a bad directive in format '%s'", directive, whole)
+            }
+            fmt.Fprintf(&pattern, "(?P<%c>%s)", directive, body)
+        case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v':
+            for i+1 < len(format) && strings.IndexByte(" \t\n\r\f\v", format[i+1]) >= 0 {
+                i++
+            }
+            pattern.WriteString(`\s+`)
+        default:
+            pattern.WriteString(regexp.QuoteMeta(string(c)))
+        }
+    }
+    return pattern.String(), nil
+}
+
+// strptime parses data after CPython's _strptime, filling in what format
+// left out the same way: 1900-01-01, and the weekday and day of the year
+// worked out from the rest. The offset comes back as a timedelta.
+func strptime(data, format string) (wallClock, *Error) {
+    pattern, err := strptimeRegexp(format, format)
+    if err != nil {
+        return wallClock{}, err
+    }
+    re := regexp.MustCompile("(?i)^(?:" + pattern + ")")
+    match := re.FindStringSubmatch(data)
+    if match == nil {
+        return wallClock{}, valueError("time data %s does not match format %s", strRepr(data), strRepr(format))
+    }
+    if len(match[0]) != len(data) {
+        return wallClock{}, valueError("unconverted data remains: %s", data[len(match[0]):])
+    }
+    found := map[string]string{}
+    for i, name := range re.SubexpNames() {
+        if name != "" && match[i] != "" {
+            found[name] = match[i]
+        }
+    }
+    number := func(key string) int {
+        n, _ := strconv.Atoi(strings.TrimSpace(found[key]))
+        return n
+    }
+    index := func(names []string, key string, size int) int {
+        for i, name := range names {
+            if size > 0 {
+                name = name[:size]
+            }
+            if strings.EqualFold(name, found[key]) {
+                return i
+            }
+        }
+        return -1
+    }
+
+    c := wallClock{year: -1, month: 1, day: 1, weekday: -1, yearday: -1, isdst: -1, zone: NULL, offset: NULL}
+    isoYear, isoWeek, week, weekStart := -1, -1, -1, 0
+    for key := range found {
+        switch key {
+        case "y":
+            c.year = number("y")
+            if c.year <= 68 {
+                c.year += 2000
+            } else {
+                c.year += 1900
+            }
+        case "Y":
+            c.year = number("Y")
+        case "G":
+            isoYear = number("G")
+        case "m":
+            c.month = number("m")
+        case "B":
+            c.month = index(monthNames, "B", 0) + 1
+        case "b":
+            c.month = index(monthNames, "b", 3) + 1
+        case "d":
+            c.day = number("d")
+        case "H":
+            c.hour = number("H")
+        case "I":
+            c.hour = number("I")
+            switch strings.ToLower(found["p"]) {
+            case "", "am":
+                if c.hour == 12 {
+                    c.hour = 0
+                }
+            case "pm":
+                if c.hour != 12 {
+                    c.hour += 12
+                }
+            }
+        case "M":
+            c.minute = number("M")
+        case "S":
+            c.second = number("S")
+        case "f":
+            c.microsecond, _ = strconv.Atoi((found["f"] + "00000")[:6])
+        case "A":
+            c.weekday = index(dayNames, "A", 0)
+        case "a":
+            c.weekday = index(dayNames, "a", 3)
+        case "w":
+            c.weekday = (number("w") + 6) % 7
+        case "u":
+            c.weekday = number("u") - 1
+        case "j":
+            c.yearday = number("j")
+        case "U", "W":
+            week = number(key)
+            if key == "W" {
+                weekStart = 1
+            }
+        case "V":
+            isoWeek = number("V")
+        case "z":
+            offset, err := parseStrptimeOffset(found["z"])
+            if err != nil {
+                return wallClock{}, err
+            }
+            c.offset = offset
+        case "Z":
+            c.zone = &String{Value: found["Z"]}
+            standard, daylight := zoneNames()
+            name := strings.ToLower(found["Z"])
+            for isdst, names := range [][]string{standard, daylight} {
+                for _, candidate := range names {
+                    if candidate == name {
+                        c.isdst = isdst
+                    }
+                }
+            }
+            if _, _, names := localZones(); names[0] == names[1] && len(daylight) > 0 && name != "utc" && name != "gmt" {
+                c.isdst = -1
+            }
+        }
+    }
+
+    switch {
+    case c.year == -1 && isoYear != -1:
+        if isoWeek == -1 || c.weekday == -1 {
+            return wallClock{}, valueError("ISO year directive '%%G' must be used with the ISO week directive '%%V' and a weekday directive ('%%A', '%%a', '%%w', or '%%u').")
+        }
+        if c.yearday != -1 {
+            return wallClock{}, valueError("Day of the year directive '%%j' is not compatible with ISO year directive '%%G'. Use '%%Y' instead.")
+        }
+    case isoWeek != -1:
+        if c.year == -1 || c.weekday == -1 {
+            return wallClock{}, valueError("ISO week directive '%%V' must be used with the ISO year directive '%%G' and a weekday directive ('%%A', '%%a', '%%w', or '%%u').")
+        }
+        return wallClock{}, valueError("ISO week directive '%%V' is incompatible with the year directive '%%Y'. Use the ISO year '%%G' instead.")
+    }
+    leapYearFix := false
+    if c.year == -1 {
+        c.year = 1900
+        if c.month == 2 && c.day == 29 {
+            c.year, leapYearFix = 1904, true
+        }
+    }
+    if c.yearday == -1 && c.weekday != -1 {
+        if week != -1 {
+            c.yearday = julianFromWeek(c.year, week, c.weekday, weekStart == 1)
+        } else if isoYear != -1 && isoWeek != -1 {
+            c.year, c.yearday = julianFromISOWeek(isoYear, isoWeek, c.weekday+1)
+        }
+        if c.yearday != -1 && c.yearday <= 0 {
+            c.year--
+            c.yearday += daysBeforeMonth(c.year, 13)
+        }
+    }
+    if c.yearday == -1 {
+        if c.day > daysInMonth(c.year, c.month) {
+            return wallClock{}, valueError("day is out of range for month")
+        }
+        c.yearday = daysBeforeMonth(c.year, c.month) + c.day
+    } else {
+        c.year, c.month, c.day = fromOrdinal(c.yearday - 1 + toOrdinal(c.year, 1, 1))
+    }
+    if c.weekday == -1 {
+        c.weekday = (toOrdinal(c.year, c.month, c.day) + 6) % 7
+    }
+    if leapYearFix {
+        c.year = 1900
+    }
+    return c, nil
+}
+
+// julianFromWeek is the day of the year for a %U or %W week and a weekday
+func julianFromWeek(year, week, weekday int, mondayFirst bool) int {
+    firstWeekday := (toOrdinal(year, 1, 1) + 6) % 7
+    if !mondayFirst {
+        firstWeekday = (firstWeekday + 1) % 7
+        weekday = (weekday + 1) % 7
+    }
+    if week == 0 {
+        return 1 + weekday - firstWeekday
+    }
+    return 1 + (7-firstWeekday)%7 + 7*(week-1) + weekday
+}
+
+// julianFromISOWeek is the year and the day of it for an ISO week date
+func julianFromISOWeek(isoYear, isoWeek, isoWeekday int) (int, int) {
+    correction := (toOrdinal(isoYear, 1, 4)+6)%7 + 1 + 3
+    ordinal := isoWeek*7 + isoWeekday - correction
+    if ordinal < 1 {
+        ordinal += toOrdinal(isoYear, 1, 1)
+        isoYear--
+        ordinal -= toOrdinal(isoYear, 1, 1)
+    }
+    return isoYear, ordinal
+}
+
+// parseStrptimeOffset reads %z's +HH[:]MM[[:]SS[.ffffff]], or Z
+func parseStrptimeOffset(z string) (Object, *Error) {
+    if z == "Z" || z == "z" {
+        return &TimeDelta{}, nil
+    }
+    digits := z[1:]
+    if len(digits) > 2 && digits[2] == ':' {
+        if len(digits) > 5 && digits[5] != ':' {
+            return nil, valueError("Inconsistent use of : in %s", z)
+        }
+        digits = strings.ReplaceAll(digits, ":", "")
+    }
+    fraction := ""
+    if dot := strings.IndexByte(digits, '.'); dot >= 0 {
+        digits, fraction = digits[:dot], digits[dot+1:]
+    }
+    hours, _ := strconv.Atoi(digits[0:2])
+    minutes, _ := strconv.Atoi(digits[2:4])
+    seconds := 0
+    if len(digits) >= 6 {
+        seconds, _ = strconv.Atoi(digits[4:6])
+    }
+    micros := 0
+    if fraction != "" {
+        micros, _ = strconv.Atoi((fraction + "00000")[:6])
+    }
+    total := int64(hours*3600+minutes*60+seconds)*1e6 + int64(micros)
+    if z[0] == '-' {
+        total = -total
+    }
+    return timeDeltaOf(total), nil
+}
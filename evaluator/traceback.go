@@ -0,0 +1,622 @@
+// Comments in this file are inspired by Gretchen Bodinski - she keeps the record of where everyone was, and when
+
+package evaluator
+
+import (
+    "fmt"
+    "interpreter/parser"
+    "os"
+    "strings"
+    "unicode/utf8"
+)
+
+// Frame is one call in progress: the code's name and the file it came from
+type Frame struct {
+    Name     string
+    Filename string
+}
+
+func (f *Frame) Type() ObjectType { return FRAME_OBJ }
+func (f *Frame) Inspect() string {
+    return fmt.Sprintf("<frame at %p, file '%s', code %s>", f, f.Filename, f.Name)
+}
+
+// Traceback is one entry in an exception's record: the frame and the node it
+// failed on. The head is the outermost frame; Next leads to where it was raised.
+type Traceback struct {
+    Frame    *Frame
+    Position parser.Position
+    Node     interface{}
+    Next     *Traceback
+}
+
+func (tb *Traceback) Type() ObjectType { return TRACEBACK_OBJ }
+func (tb *Traceback) Inspect() string  { return fmt.Sprintf("<traceback object at %p>", tb) }
+
+var (
+    tracebackType = newBuiltinClass("traceback", objectType)
+    frameType     = newBuiltinClass("frame", objectType)
+)
+
+func (tb *Traceback) attribute(name string) (Object, bool) {
+    switch name {
+    case "tb_frame":
+        return tb.Frame, true
+    case "tb_lineno":
+        return newInt(int64(tb.Position.Line)), true
+    case "tb_next":
+        if tb.Next == nil {
+            return NULL, true
+        }
+        return tb.Next, true
+    }
+    return nil, false
+}
+
+// recursionLimit is how many frames deep a program may go, like sys.getrecursionlimit()
+const recursionLimit = 1000
+
+// runFrame evaluates a function or class body in a frame of its own. An error
+// leaving the frame still needs the caller's line on its traceback.
+func runFrame(env *Environment, name string, body func() Object) Object {
+    interp := env.interp
+    if interp.depth >= recursionLimit {
+        return newErrorKind(recursionErrorType, "maximum recursion depth exceeded")
+    }
+    interp.depth++
+    env.frame = &Frame{Name: name, Filename: env.frame.Filename}
+    result := body()
+    interp.depth--
+    if err, ok := result.(*Error); ok {
+        err.traced = false
+    }
+    return result
+}
+
+// trace puts the current frame on the exception's traceback as the error leaves it
+func (err *Error) trace(env *Environment, node interface{}, pos parser.Position) {
+    if err.Exception == nil || env.frame == nil || pos.Line == 0 {
+        return
+    }
+    e := err.Exception
+    e.Traceback = &Traceback{Frame: env.frame, Position: pos, Node: node, Next: e.Traceback}
+    err.traced = true
+}
+
+// locate pins an error from Go code on the node that caused it, rather than
+// whatever statement it surfaces in
+func locate(obj Object, env *Environment, node interface{}) Object {
+    if err, ok := obj.(*Error); ok && !err.traced {
+        if located, ok := node.(parser.Located); ok {
+            err.trace(env, node, *located.Span())
+        }
+    }
+    return obj
+}
+
+// RegisterSource makes source text available to this interpreter's
+// tracebacks under filename
+func (e *Environment) RegisterSource(filename, source string) {
+    e.interp.sources[filename] = strings.SplitAfter(source, "\n")
+}
+
+// sourceLine is line lineno of filename, or "" when it can't be had
+func (i *interpreter) sourceLine(filename string, lineno int) string {
+    lines, ok := i.sources[filename]
+    if !ok {
+        if strings.HasPrefix(filename, "<") {
+            return ""
+        }
+        data, err := os.ReadFile(filename)
+        if err != nil {
+            return ""
+        }
+        lines = strings.SplitAfter(string(data), "\n")
+        i.sources[filename] = lines
+    }
+    if lineno < 1 || lineno > len(lines) {
+        return ""
+    }
+    return strings.TrimRight(lines[lineno-1], "\r\n")
+}
+
+// FormatTraceback renders an error the way Python reports an uncaught
+// exception, chained exceptions and groups included. Anything else is "".
+func FormatTraceback(obj Object, env *Environment) string {
+    err, ok := obj.(*Error)
+    if !ok || err.Exception == nil {
+        return ""
+    }
+    var out strings.Builder
+    printer := &tracebackPrinter{env: env, out: &out}
+    printer.format(newTracebackException(err.Exception))
+    return out.String()
+}
+
+// ExitStatus is the status an uncaught SystemExit asks the process to exit
+// with: its code, 0 for None, and 1 for anything else, which goes to
+// sys.stderr first like python does. ok is false for every other result.
+func ExitStatus(obj Object, env *Environment) (status int, ok bool) {
+    err, ok := obj.(*Error)
+    if !ok || err.Exception == nil || !err.Exception.Class.isSubclass(systemExitType) {
+        return 0, false
+    }
+    code := err.Exception.Fields["code"]
+    if code == nil || code == NULL {
+        return 0, true
+    }
+    if n, ok := toBigInt(code); ok {
+        if !n.IsInt64() {
+            return -1, true
+        }
+        return int(int32(n.Int64())), true
+    }
+    text := "<exception str() failed>"
+    if str, ok := strOf(env, code).(*String); ok {
+        text = str.Value
+    }
+    if stderr, err := env.interp.stream("stderr"); err == nil {
+        writeStream(env, stderr, text+"\n")
+    }
+    return 1, true
+}
+
+// tracebackException is an exception with the chain we will follow when printing it.
+// Each exception is printed once, however many times it turns up.
+type tracebackException struct {
+    exc        *Exception
+    cause      *tracebackException
+    context    *tracebackException
+    exceptions []*tracebackException
+}
+
+func newTracebackException(e *Exception) *tracebackException {
+    seen := map[*Exception]bool{e: true}
+    root := &tracebackException{exc: e}
+    queue := []*tracebackException{root}
+    for len(queue) > 0 {
+        te := queue[len(queue)-1]
+        queue = queue[:len(queue)-1]
+        e := te.exc
+        if e.Cause != nil && !seen[e.Cause] {
+            seen[e.Cause] = true
+            te.cause = &tracebackException{exc: e.Cause}
+        }
+        if te.cause == nil && !e.SuppressContext && e.Context != nil && !seen[e.Context] {
+            seen[e.Context] = true
+            te.context = &tracebackException{exc: e.Context}
+        }
+        if isExceptionGroup(e) {
+            for _, member := range groupMembers(e) {
+                seen[member] = true
+                te.exceptions = append(te.exceptions, &tracebackException{exc: member})
+            }
+        }
+        if te.cause != nil {
+            queue = append(queue, te.cause)
+        }
+        if te.context != nil {
+            queue = append(queue, te.context)
+        }
+        queue = append(queue, te.exceptions...)
+    }
+    return root
+}
+
+func groupMembers(e *Exception) []*Exception {
+    var members []*Exception
+    if exceptions, ok := e.Fields["exceptions"].(*Tuple); ok {
+        for _, member := range exceptions.Elements {
+            if member, ok := member.(*Exception); ok {
+                members = append(members, member)
+            }
+        }
+    }
+    return members
+}
+
+const (
+    causeMessage   = "\nThe above exception was the direct cause of the following exception:\n\n"
+    contextMessage = "\nDuring handling of the above exception, another exception occurred:\n\n"
+
+    recursiveCutoff = 3
+    maxGroupWidth   = 15
+    maxGroupDepth   = 10
+)
+
+// tracebackPrinter writes the report, boxing in the members of exception groups
+type tracebackPrinter struct {
+    env        *Environment
+    out        *strings.Builder
+    groupDepth int
+    needClose  bool
+}
+
+func (p *tracebackPrinter) indent() string {
+    return strings.Repeat(" ", 2*p.groupDepth)
+}
+
+// emit writes text with every line prefixed by the current group margin
+func (p *tracebackPrinter) emit(text string, margin string) {
+    prefix := p.indent()
+    if p.groupDepth > 0 {
+        prefix += margin + " "
+    }
+    for _, line := range strings.SplitAfter(text, "\n") {
+        if line != "" {
+            p.out.WriteString(prefix + line)
+        }
+    }
+}
+
+func (p *tracebackPrinter) format(te *tracebackException) {
+    type link struct {
+        message string
+        te      *tracebackException
+    }
+    var chain []link
+    for exc := te; exc != nil; {
+        switch {
+        case exc.cause != nil:
+            chain = append(chain, link{causeMessage, exc})
+            exc = exc.cause
+        case exc.context != nil:
+            chain = append(chain, link{contextMessage, exc})
+            exc = exc.context
+        default:
+            chain = append(chain, link{"", exc})
+            exc = nil
+        }
+    }
+
+    for i := len(chain) - 1; i >= 0; i-- {
+        message, exc := chain[i].message, chain[i].te
+        if message != "" {
+            p.emit(message, "|")
+        }
+        stack := formatStack(p.env.interp, exc.exc.Traceback)
+        switch {
+        case exc.exceptions == nil:
+            if stack != "" {
+                p.emit("Traceback (most recent call last):\n", "|")
+                p.emit(stack, "|")
+            }
+            p.emit(p.formatExceptionOnly(exc.exc), "|")
+        case p.groupDepth > maxGroupDepth:
+            p.emit(fmt.Sprintf("... (max_group_depth is %d)\n", maxGroupDepth), "|")
+        default:
+            p.formatGroup(exc, stack)
+        }
+    }
+}
+
+func (p *tracebackPrinter) formatGroup(te *tracebackException, stack string) {
+    topLevel := p.groupDepth == 0
+    if topLevel {
+        p.groupDepth++
+    }
+    if stack != "" {
+        margin := "|"
+        if topLevel {
+            margin = "+"
+        }
+        p.emit("Exception Group Traceback (most recent call last):\n", margin)
+        p.emit(stack, "|")
+    }
+    p.emit(p.formatExceptionOnly(te.exc), "|")
+
+    n := len(te.exceptions)
+    if n > maxGroupWidth {
+        n = maxGroupWidth + 1
+    }
+    p.needClose = false
+    for i := 0; i < n; i++ {
+        last := i == n-1
+        if last {
+            p.needClose = true // unless a nested group closes the box for us
+        }
+        truncated := i >= maxGroupWidth
+        title := fmt.Sprint(i + 1)
+        if truncated {
+            title = "..."
+        }
+        corner := "  "
+        if i == 0 {
+            corner = "+-"
+        }
+        p.out.WriteString(p.indent() + corner + "+---------------- " + title + " ----------------\n")
+        p.groupDepth++
+        if !truncated {
+            p.format(te.exceptions[i])
+        } else {
+            remaining := len(te.exceptions) - maxGroupWidth
+            p.emit(fmt.Sprintf("and %d more exception%s\n", remaining, plural(remaining)), "|")
+        }
+        if last && p.needClose {
+            p.out.WriteString(p.indent() + "+------------------------------------\n")
+            p.needClose = false
+        }
+        p.groupDepth--
+    }
+    if topLevel {
+        p.groupDepth = 0
+    }
+}
+
+// formatExceptionOnly is the "Name: message" line and any notes after it
+func (p *tracebackPrinter) formatExceptionOnly(e *Exception) string {
+    var out strings.Builder
+    if e.Class.isSubclass(syntaxErrorType) {
+        out.WriteString(p.formatSyntaxError(e))
+    } else {
+        out.WriteString(formatException(p.env, e) + "\n")
+    }
+
+    notes, ok := e.Dict.GetStr("__notes__")
+    if !ok || notes == NULL {
+        return out.String()
+    }
+    var items []Object
+    switch notes := notes.(type) {
+    case *List:
+        items = notes.Elements
+    case *Tuple:
+        items = notes.Elements
+    case *String:
+        for _, r := range notes.Value {
+            items = append(items, &String{Value: string(r)})
+        }
+    default:
+        repr, err := reprString(p.env, notes)
+        if err != nil {
+            repr = "<__notes__ repr() failed>"
+        }
+        out.WriteString(repr + "\n")
+        return out.String()
+    }
+    for _, note := range items {
+        text := "<note str() failed>"
+        if str := strOf(p.env, note); !isError(str) {
+            text = payload(str).(*String).Value
+        }
+        for _, line := range strings.Split(text, "\n") {
+            out.WriteString(line + "\n")
+        }
+    }
+    return out.String()
+}
+
+// formatSyntaxError shows the offending line with carets under the problem
+func (p *tracebackPrinter) formatSyntaxError(e *Exception) string {
+    var out strings.Builder
+    filenameSuffix := ""
+    filename, hasFile := e.Fields["filename"].(*String)
+    if lineno, ok := e.Fields["lineno"].(*Integer); ok {
+        name := "<string>"
+        if hasFile && filename.Value != "" {
+            name = filename.Value
+        }
+        fmt.Fprintf(&out, "  File \"%s\", line %s\n", name, lineno.Value)
+    } else if hasFile {
+        filenameSuffix = " (" + filename.Value + ")"
+    }
+
+    if text, ok := e.Fields["text"].(*String); ok {
+        rtext := strings.TrimRight(text.Value, "\n")
+        ltext := strings.TrimLeft(rtext, " \n\f")
+        spaces := utf8.RuneCountInString(rtext) - utf8.RuneCountInString(ltext)
+        fmt.Fprintf(&out, "    %s\n", ltext)
+
+        if offsetObj, ok := e.Fields["offset"].(*Integer); ok {
+            length := int64(utf8.RuneCountInString(text.Value))
+            offset := offsetObj.Value.Int64()
+            end := offset
+            if endObj, ok := e.Fields["end_offset"].(*Integer); ok && endObj.Value.Sign() != 0 {
+                end = endObj.Value.Int64()
+            }
+            if length > 0 && offset > length {
+                offset = length + 1
+            }
+            if length > 0 && end > length {
+                end = length + 1
+            }
+            if offset >= end || end < 0 {
+                end = offset + 1
+            }
+            col, endCol := int(offset)-1-spaces, int(end)-1-spaces
+            if col >= 0 {
+                var caretSpace strings.Builder
+                for i, r := range []rune(ltext) {
+                    if i >= col {
+                        break
+                    }
+                    if r == '\t' || r == '\f' || r == '\v' {
+                        caretSpace.WriteRune(r)
+                    } else {
+                        caretSpace.WriteRune(' ')
+                    }
+                }
+                fmt.Fprintf(&out, "    %s%s\n", caretSpace.String(), strings.Repeat("^", endCol-col))
+            }
+        }
+    }
+
+    msg := "<no detail available>"
+    if text := strOf(p.env, e.Fields["msg"]); !isError(text) && e.Fields["msg"] != NULL {
+        if value := payload(text).(*String).Value; value != "" {
+            msg = value
+        }
+    }
+    fmt.Fprintf(&out, "%s: %s%s\n", exceptionName(e.Class), msg, filenameSuffix)
+    return out.String()
+}
+
+// formatStack lists the frames outermost first, folding runaway recursion
+func formatStack(interp *interpreter, tb *Traceback) string {
+    var out strings.Builder
+    var last *Traceback
+    count := 0
+    flush := func() {
+        if count > recursiveCutoff {
+            n := count - recursiveCutoff
+            fmt.Fprintf(&out, "  [Previous line repeated %d more time%s]\n", n, plural(n))
+        }
+    }
+    for ; tb != nil; tb = tb.Next {
+        if last == nil || last.Frame.Filename != tb.Frame.Filename ||
+            last.Position.Line != tb.Position.Line || last.Frame.Name != tb.Frame.Name {
+            flush()
+            last = tb
+            count = 0
+        }
+        count++
+        if count > recursiveCutoff {
+            continue
+        }
+        out.WriteString(formatFrame(interp, tb))
+    }
+    flush()
+    return out.String()
+}
+
+// formatFrame is one entry: where, the line itself, and carets under the part that failed
+func formatFrame(interp *interpreter, tb *Traceback) string {
+    var out strings.Builder
+    pos := tb.Position
+    fmt.Fprintf(&out, "  File \"%s\", line %d, in %s\n", tb.Frame.Filename, pos.Line, tb.Frame.Name)
+
+    line := interp.sourceLine(tb.Frame.Filename, pos.Line)
+    stripped := strings.TrimSpace(line)
+    if stripped == "" {
+        return out.String()
+    }
+    fmt.Fprintf(&out, "    %s\n", stripped)
+
+    start := charOffset(line, pos.Column)
+    end := charOffset(line, pos.EndColumn)
+    var anchors *caretAnchors
+    if pos.Line == pos.EndLine {
+        anchors = findCaretAnchors(tb.Node, line, pos)
+    } else {
+        end = utf8.RuneCountInString(strings.TrimRightFunc(line, isSpaceRune))
+    }
+
+    strippedLength := utf8.RuneCountInString(stripped)
+    if end-start >= strippedLength && (anchors == nil || anchors.rightStart-anchors.leftEnd <= 0) {
+        return out.String()
+    }
+    indent := utf8.RuneCountInString(line) - utf8.RuneCountInString(strings.TrimLeftFunc(line, isSpaceRune))
+    out.WriteString("    " + strings.Repeat(" ", start-indent))
+    if anchors != nil {
+        out.WriteString(strings.Repeat("~", anchors.leftEnd))
+        out.WriteString(strings.Repeat("^", anchors.rightStart-anchors.leftEnd))
+        out.WriteString(strings.Repeat("~", end-start-anchors.rightStart))
+    } else {
+        out.WriteString(strings.Repeat("^", end-start))
+    }
+    out.WriteString("\n")
+    return out.String()
+}
+
+// caretAnchors mark the operator of a binary operation or the brackets of a
+// subscript, as character offsets into the failing segment
+type caretAnchors struct {
+    leftEnd, rightStart int
+}
+
+// caretOperators are the infix operators Python marks with carets of their own;
+// comparisons and and/or get the plain treatment
+var caretOperators = map[string]bool{
+    "+": true, "-": true, "*": true, "/": true, "//": true, "%": true, "**": true,
+    "@": true, "<<": true, ">>": true, "&": true, "|": true, "^": true,
+}
+
+func findCaretAnchors(node interface{}, line string, pos parser.Position) *caretAnchors {
+    if pos.Column < 0 || pos.EndColumn > len(line) || pos.Column > pos.EndColumn {
+        return nil
+    }
+    segment := line[pos.Column:pos.EndColumn]
+    // offsets into the segment, in bytes, of where a child node starts or ends
+    startOf := func(child interface{}) (int, bool) {
+        located, ok := child.(parser.Located)
+        if !ok || located.Span().Line != pos.Line {
+            return 0, false
+        }
+        return located.Span().Column - pos.Column, true
+    }
+    endOf := func(child interface{}) (int, bool) {
+        located, ok := child.(parser.Located)
+        if !ok || located.Span().EndLine != pos.Line {
+            return 0, false
+        }
+        return located.Span().EndColumn - pos.Column, true
+    }
+    normalize := func(offset int) int {
+        return charOffset(segment, offset)
+    }
+
+    switch node := node.(type) {
+    case *parser.InfixExpression:
+        if !caretOperators[node.Operator] {
+            return nil
+        }
+        leftEnd, ok := endOf(node.Left)
+        rightStart, ok2 := startOf(node.Right)
+        if !ok || !ok2 || leftEnd > rightStart || rightStart > len(segment) {
+            return nil
+        }
+        // the operator is the first thing between the operands that isn't
+        // whitespace or a closing parenthesis
+        for i := leftEnd; i < rightStart; i++ {
+            if isSpaceByte(segment[i]) {
+                continue
+            }
+            right := i + 1
+            if i+1 < rightStart && !isSpaceByte(segment[i+1]) {
+                right++
+            }
+            if i+1 < rightStart && segment[i] == ')' {
+                continue
+            }
+            return &caretAnchors{normalize(i), normalize(right)}
+        }
+        return nil
+    case *parser.IndexExpression:
+        leftEnd, ok := endOf(node.Left)
+        indexEnd, ok2 := endOf(node.Index)
+        if !ok || !ok2 || leftEnd > len(segment) || indexEnd >= len(segment) {
+            return nil
+        }
+        left, right := leftEnd, indexEnd+1
+        for left < len(segment) && segment[left] != '[' {
+            left++
+        }
+        for right < len(segment) && segment[right] != ']' {
+            right++
+        }
+        if right < len(segment) {
+            right++
+        }
+        return &caretAnchors{normalize(left), normalize(right)}
+    }
+    return nil
+}
+
+// charOffset turns a byte column into a character column
+func charOffset(s string, offset int) int {
+    if offset > len(s) {
+        offset = len(s)
+    }
+    if offset < 0 {
+        return 0
+    }
+    return utf8.RuneCountInString(s[:offset])
+}
+
+func isSpaceByte(c byte) bool {
+    return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v'
+}
+
+func isSpaceRune(r rune) bool {
+    return r < utf8.RuneSelf && isSpaceByte(byte(r))
+}
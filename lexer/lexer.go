@@ -2,23 +2,45 @@ package lexer
 
 import (
     "interpreter/token"
+    "strings"
 )
 
 type Lexer struct {
-    input         string
-    position      int
-    readPosition  int
-    ch            byte
-    currentIndent int
+    input        string
+    position     int
+    readPosition int
+    ch           byte
+
+    indents   []int         // open indentation levels, innermost last
+    depth     int           // (), [] and {} nesting; newlines inside are ignored
+    pending   []token.Token // INDENT/DEDENT tokens waiting to be handed out
+    lineStart bool          // at the beginning of a physical line outside brackets
+    logical   bool          // next token starts a new logical line
+
+    line       int // current line, 1-based
+    lineOffset int // byte offset where the current line begins
 }
 
 func New(input string) *Lexer {
-    l := &Lexer{input: input}
+    l := &Lexer{input: input, indents: []int{0}, lineStart: true, logical: true, line: 1}
     l.readChar()
     return l
 }
 
+// Clone is an independent copy of the lexer, so the parser can look ahead
+// past its peek token without losing its place
+func (l *Lexer) Clone() *Lexer {
+    clone := *l
+    clone.indents = append([]int{}, l.indents...)
+    clone.pending = append([]token.Token{}, l.pending...)
+    return &clone
+}
+
 func (l *Lexer) readChar() {
+    if l.ch == '\n' {
+        l.line++
+        l.lineOffset = l.readPosition
+    }
     if l.readPosition >= len(l.input) {
         l.ch = 0
     } else {
@@ -28,155 +50,401 @@ func (l *Lexer) readChar() {
     l.readPosition++
 }
 
+func (l *Lexer) peekChar() byte {
+    if l.readPosition >= len(l.input) {
+        return 0
+    }
+    return l.input[l.readPosition]
+}
+
 func (l *Lexer) NextToken() token.Token {
-    var tok token.Token
+    if len(l.pending) > 0 {
+        return l.popPending()
+    }
 
-    if l.ch == '\n' {
-        l.readChar()
-        tok = l.handleIndentation()
-        if tok.Type != token.ILLEGAL {
-            return tok
+    for {
+        if l.lineStart {
+            l.lineStart = false
+            if tok, ok := l.handleIndentation(); ok {
+                return tok
+            }
         }
+
+        l.skipWhitespace()
+
+        switch {
+        case l.ch == '#':
+            for l.ch != '\n' && l.ch != 0 {
+                l.readChar()
+            }
+            continue
+        case l.ch == '\\' && (l.peekChar() == '\n' || l.peekChar() == '\r'):
+            // Explicit line joining - the next line continues this one
+            l.readChar()
+            if l.ch == '\r' {
+                l.readChar()
+            }
+            l.readChar()
+            continue
+        case l.ch == '\n':
+            l.readChar()
+            if l.depth == 0 {
+                l.lineStart = true
+                l.logical = true
+            }
+            continue
+        }
+        break
     }
 
-    l.skipWhitespace()
+    line, column := l.line, l.position-l.lineOffset
+    tok := l.scanToken()
+    tok.Line, tok.Column = line, column
+    tok.EndLine, tok.EndColumn = l.line, l.position-l.lineOffset
+    tok.LineStart = tok.LineStart || l.logical
+    l.logical = false
+    return tok
+}
+
+func (l *Lexer) scanToken() token.Token {
+    var tok token.Token
 
     switch l.ch {
     case '=':
-        tok = newToken(token.ASSIGN, l.ch)
+        tok = l.twoCharToken('=', token.EQ, token.ASSIGN)
+    case '!':
+        if l.peekChar() == '=' {
+            l.readChar()
+            tok = token.Token{Type: token.NOT_EQ, Literal: "!="}
+        } else {
+            tok = newToken(token.ILLEGAL, l.ch)
+        }
     case '+':
         tok = newToken(token.PLUS, l.ch)
     case '-':
         tok = newToken(token.MINUS, l.ch)
     case '*':
-        tok = newToken(token.ASTERISK, l.ch)
+        tok = l.twoCharToken('*', token.POWER, token.ASTERISK)
     case '/':
-        tok = newToken(token.SLASH, l.ch)
+        tok = l.twoCharToken('/', token.DOUBLE_SLASH, token.SLASH)
+    case '%':
+        tok = newToken(token.PERCENT, l.ch)
+    case '@':
+        tok = newToken(token.AT, l.ch)
+    case '&':
+        tok = newToken(token.AMPERSAND, l.ch)
+    case '|':
+        tok = newToken(token.PIPE, l.ch)
+    case '^':
+        tok = newToken(token.CARET, l.ch)
+    case '~':
+        tok = newToken(token.TILDE, l.ch)
+    case '<':
+        switch l.peekChar() {
+        case '=':
+            tok = l.twoCharToken('=', token.LTE, token.LT)
+        case '<':
+            tok = l.twoCharToken('<', token.LSHIFT, token.LT)
+        default:
+            tok = newToken(token.LT, l.ch)
+        }
+    case '>':
+        switch l.peekChar() {
+        case '=':
+            tok = l.twoCharToken('=', token.GTE, token.GT)
+        case '>':
+            tok = l.twoCharToken('>', token.RSHIFT, token.GT)
+        default:
+            tok = newToken(token.GT, l.ch)
+        }
     case '(':
+        l.depth++
         tok = newToken(token.LPAREN, l.ch)
     case ')':
+        l.closeBracket()
         tok = newToken(token.RPAREN, l.ch)
     case '{':
+        l.depth++
         tok = newToken(token.LBRACE, l.ch)
     case '}':
+        l.closeBracket()
         tok = newToken(token.RBRACE, l.ch)
     case '[':
+        l.depth++
         tok = newToken(token.LBRACKET, l.ch)
     case ']':
+        l.closeBracket()
         tok = newToken(token.RBRACKET, l.ch)
     case ',':
         tok = newToken(token.COMMA, l.ch)
     case ':':
-        tok = newToken(token.COLON, l.ch)
-    case '>':
-        tok = newToken(token.GT, l.ch)
-    case '"':
-        tok.Literal = l.readString('"')
-        tok.Type = token.STRING
-        return tok
-    case '\'':
-        tok.Literal = l.readString('\'')
-        tok.Type = token.STRING
-        return tok
-    case 0:
-        if l.currentIndent > 0 {
-            return l.handleEOF()
+        tok = l.twoCharToken('=', token.WALRUS, token.COLON)
+    case ';':
+        tok = newToken(token.SEMICOLON, l.ch)
+    case '.':
+        if isDigit(l.peekChar()) {
+            return l.readNumber()
         }
-        tok.Literal = ""
-        tok.Type = token.EOF
+        tok = newToken(token.DOT, l.ch)
+    case '"', '\'':
+        return l.readString(l.position)
+    case 0:
+        return l.handleEOF()
     default:
         if isLetter(l.ch) {
-            tok.Literal = l.readIdentifier()
-            tok.Type = token.LookupIdent(tok.Literal)
-            return tok
+            start := l.position
+            ident := l.readIdentifier()
+            if (l.ch == '"' || l.ch == '\'') && isStringPrefix(ident) {
+                return l.readString(start)
+            }
+            return token.Token{Type: token.LookupIdent(ident), Literal: ident}
         } else if isDigit(l.ch) {
-            tok.Literal = l.readNumber()
-            tok.Type = token.INT
-            return tok
+            return l.readNumber()
         } else {
             tok = newToken(token.ILLEGAL, l.ch)
         }
     }
 
+    if assign, ok := augmented[tok.Type]; ok && l.peekChar() == '=' {
+        l.readChar()
+        tok = token.Token{Type: assign, Literal: tok.Literal + "="}
+    }
     l.readChar()
     return tok
 }
 
-func (l *Lexer) skipWhitespace() {
-    for l.ch == ' ' || l.ch == '\t' {
+// augmented are the operators that may take an = after them, like +=
+var augmented = map[token.TokenType]token.TokenType{
+    token.PLUS:         token.PLUS_ASSIGN,
+    token.MINUS:        token.MINUS_ASSIGN,
+    token.ASTERISK:     token.ASTERISK_ASSIGN,
+    token.SLASH:        token.SLASH_ASSIGN,
+    token.DOUBLE_SLASH: token.DOUBLE_SLASH_ASSIGN,
+    token.PERCENT:      token.PERCENT_ASSIGN,
+    token.POWER:        token.POWER_ASSIGN,
+    token.AT:           token.AT_ASSIGN,
+    token.AMPERSAND:    token.AMPERSAND_ASSIGN,
+    token.PIPE:         token.PIPE_ASSIGN,
+    token.CARET:        token.CARET_ASSIGN,
+    token.LSHIFT:       token.LSHIFT_ASSIGN,
+    token.RSHIFT:       token.RSHIFT_ASSIGN,
+}
+
+// twoCharToken produces `long` when the next character is `next`, `short` otherwise
+func (l *Lexer) twoCharToken(next byte, long, short token.TokenType) token.Token {
+    if l.peekChar() == next {
+        first := l.ch
         l.readChar()
+        return token.Token{Type: long, Literal: string([]byte{first, l.ch})}
     }
+    return newToken(short, l.ch)
 }
 
-// Manages Python-style indentation tokens
-func (l *Lexer) handleIndentation() token.Token {
-    indentLevel := 0
+func (l *Lexer) closeBracket() {
+    if l.depth > 0 {
+        l.depth--
+    }
+}
 
-    for l.ch == ' ' || l.ch == '\t' {
-        if l.ch == ' ' {
-            indentLevel++
-        } else if l.ch == '\t' {
-            indentLevel += 4
-        }
+func (l *Lexer) skipWhitespace() {
+    for l.ch == ' ' || l.ch == '\t' || l.ch == '\r' || l.ch == '\f' {
         l.readChar()
     }
+}
 
-    if indentLevel > l.currentIndent {
-        l.currentIndent = indentLevel
-        return token.Token{Type: token.INDENT, Literal: ""}
-    } else if indentLevel < l.currentIndent {
-        l.currentIndent -= 4
-        return token.Token{Type: token.DEDENT, Literal: ""}
-    }
+// Manages Python-style indentation tokens. Blank and comment-only lines
+// never change the indentation level.
+func (l *Lexer) handleIndentation() (token.Token, bool) {
+    for {
+        indentLevel := 0
+        for l.ch == ' ' || l.ch == '\t' || l.ch == '\f' {
+            if l.ch == '\t' {
+                indentLevel = (indentLevel/8 + 1) * 8
+            } else if l.ch == ' ' {
+                indentLevel++
+            }
+            l.readChar()
+        }
+
+        switch l.ch {
+        case '#':
+            for l.ch != '\n' && l.ch != 0 {
+                l.readChar()
+            }
+            fallthrough
+        case '\n', '\r':
+            for l.ch == '\r' {
+                l.readChar()
+            }
+            if l.ch == '\n' {
+                l.readChar()
+            }
+            continue
+        case 0:
+            return token.Token{}, false
+        }
 
-    return token.Token{Type: token.ILLEGAL, Literal: ""}
+        // they all sit where the line's first token starts
+        at := func(tok token.Token) token.Token {
+            tok.Line, tok.Column = l.line, l.position-l.lineOffset
+            tok.EndLine, tok.EndColumn = tok.Line, tok.Column
+            return tok
+        }
+        current := l.indents[len(l.indents)-1]
+        if indentLevel > current {
+            l.indents = append(l.indents, indentLevel)
+            return at(token.Token{Type: token.INDENT, Literal: "", LineStart: true}), true
+        }
+
+        for indentLevel < l.indents[len(l.indents)-1] {
+            l.indents = l.indents[:len(l.indents)-1]
+            l.pending = append(l.pending, at(token.Token{Type: token.DEDENT, Literal: "", LineStart: true}))
+        }
+        if indentLevel != l.indents[len(l.indents)-1] {
+            l.pending = append(l.pending, at(token.Token{
+                Type:      token.ILLEGAL,
+                Literal:   "unindent does not match any outer indentation level",
+                LineStart: true,
+            }))
+        }
+        if len(l.pending) > 0 {
+            return l.popPending(), true
+        }
+        return token.Token{}, false
+    }
 }
 
 func (l *Lexer) handleEOF() token.Token {
-    if l.currentIndent > 0 {
-        l.currentIndent -= 4
-        return token.Token{Type: token.DEDENT, Literal: ""}
+    if len(l.indents) > 1 {
+        l.indents = l.indents[:len(l.indents)-1]
+        return token.Token{Type: token.DEDENT, Literal: "", LineStart: true}
+    }
+    return token.Token{Type: token.EOF, Literal: "", LineStart: true}
+}
+
+// popPending hands out queued tokens; the token after them still opens the line
+func (l *Lexer) popPending() token.Token {
+    tok := l.pending[0]
+    l.pending = l.pending[1:]
+    if len(l.pending) == 0 {
+        l.logical = true
     }
-    return token.Token{Type: token.EOF, Literal: ""}
+    return tok
 }
 
 func (l *Lexer) readIdentifier() string {
     position := l.position
-    for isLetter(l.ch) {
+    for isLetter(l.ch) || isDigit(l.ch) {
         l.readChar()
     }
     return l.input[position:l.position]
 }
 
-func (l *Lexer) readNumber() string {
+// readNumber scans integer, float and imaginary literals, including 0x/0o/0b
+// prefixes, underscores and exponents. The parser does the actual conversion.
+func (l *Lexer) readNumber() token.Token {
     position := l.position
-    for isDigit(l.ch) || l.ch == '.' {
+    tokType := token.TokenType(token.INT)
+
+    if l.ch == '0' && strings.ContainsRune("xXoObB", rune(l.peekChar())) {
         l.readChar()
+        l.readChar()
+        for isHexDigit(l.ch) || l.ch == '_' {
+            l.readChar()
+        }
+        return token.Token{Type: tokType, Literal: l.input[position:l.position]}
     }
-    return l.input[position:l.position]
-}
 
-func (l *Lexer) readString(quote byte) string {
-    position := l.position + 1
-    for {
+    for isDigit(l.ch) || l.ch == '_' {
         l.readChar()
-        if l.ch == quote || l.ch == 0 {
-            break
+    }
+    if l.ch == '.' {
+        tokType = token.FLOAT
+        l.readChar()
+        for isDigit(l.ch) || l.ch == '_' {
+            l.readChar()
+        }
+    }
+    if l.ch == 'e' || l.ch == 'E' {
+        next := l.peekChar()
+        if isDigit(next) || ((next == '+' || next == '-') && l.readPosition+1 < len(l.input) && isDigit(l.input[l.readPosition+1])) {
+            tokType = token.FLOAT
+            l.readChar()
+            if l.ch == '+' || l.ch == '-' {
+                l.readChar()
+            }
+            for isDigit(l.ch) || l.ch == '_' {
+                l.readChar()
+            }
         }
     }
-    str := l.input[position:l.position]
+    if l.ch == 'j' || l.ch == 'J' {
+        tokType = token.IMAG
+        l.readChar()
+    }
+    return token.Token{Type: tokType, Literal: l.input[position:l.position]}
+}
+
+// readString scans a string literal starting at `start` (which may point at a
+// prefix such as r or b). The literal keeps its prefix and quotes so the
+// parser can decode escapes according to the prefix.
+func (l *Lexer) readString(start int) token.Token {
+    quote := l.ch
+    triple := l.peekChar() == quote && l.readPosition+1 < len(l.input) && l.input[l.readPosition+1] == quote
+    if triple {
+        l.readChar()
+        l.readChar()
+    }
     l.readChar()
-    return str
+
+    for {
+        switch {
+        case l.ch == 0:
+            return token.Token{Type: token.ILLEGAL, Literal: "unterminated string literal"}
+        case l.ch == '\\':
+            l.readChar()
+            if l.ch != 0 {
+                l.readChar()
+            }
+            continue
+        case l.ch == '\n' && !triple:
+            return token.Token{Type: token.ILLEGAL, Literal: "unterminated string literal"}
+        case l.ch == quote:
+            if !triple {
+                l.readChar()
+                return token.Token{Type: token.STRING, Literal: l.input[start:l.position]}
+            }
+            if l.peekChar() == quote && l.readPosition+1 < len(l.input) && l.input[l.readPosition+1] == quote {
+                l.readChar()
+                l.readChar()
+                l.readChar()
+                return token.Token{Type: token.STRING, Literal: l.input[start:l.position]}
+            }
+        }
+        l.readChar()
+    }
+}
+
+func isStringPrefix(ident string) bool {
+    switch strings.ToLower(ident) {
+    case "r", "u", "b", "f", "br", "rb", "fr", "rf":
+        return true
+    }
+    return false
 }
 
 func isLetter(ch byte) bool {
-    return ('a' <= ch && ch <= 'z') || ('A' <= ch && ch <= 'Z') || ch == '_'
+    return ('a' <= ch && ch <= 'z') || ('A' <= ch && ch <= 'Z') || ch == '_' || ch >= 0x80
 }
 
 func isDigit(ch byte) bool {
     return '0' <= ch && ch <= '9'
 }
 
+func isHexDigit(ch byte) bool {
+    return isDigit(ch) || ('a' <= ch && ch <= 'f') || ('A' <= ch && ch <= 'F')
+}
+
 func newToken(tokenType token.TokenType, ch byte) token.Token {
     return token.Token{Type: tokenType, Literal: string(ch)}
-}
\ No newline at end of file
+}
@@ -60,4 +60,56 @@ func TestNextToken(t *testing.T) {
             t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
         }
     }
-}
\ No newline at end of file
+}
+func TestTokenPositions(t *testing.T) {
+    input := "x = 1\nif y:\n    name(\"é\")"
+
+    tests := []struct {
+        literal                          string
+        line, column, endLine, endColumn int
+    }{
+        {"x", 1, 0, 1, 1},
+        {"=", 1, 2, 1, 3},
+        {"1", 1, 4, 1, 5},
+        {"if", 2, 0, 2, 2},
+        {"y", 2, 3, 2, 4},
+        {":", 2, 4, 2, 5},
+        {"name", 3, 4, 3, 8},
+        {"(", 3, 8, 3, 9},
+        {"\"é\"", 3, 9, 3, 13},
+    }
+
+    l := New(input)
+    for i, tt := range tests {
+        tok := l.NextToken()
+        if tok.Type == token.INDENT {
+            tok = l.NextToken()
+        }
+        if tok.Literal != tt.literal {
+            t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.literal, tok.Literal)
+        }
+        if tok.Line != tt.line || tok.Column != tt.column || tok.EndLine != tt.endLine || tok.EndColumn != tt.endColumn {
+            t.Errorf("tests[%d] - %q at %d:%d-%d:%d, expected %d:%d-%d:%d", i, tok.Literal,
+                tok.Line, tok.Column, tok.EndLine, tok.EndColumn, tt.line, tt.column, tt.endLine, tt.endColumn)
+        }
+    }
+}
+
+func TestAssignmentOperators(t *testing.T) {
+    input := "a += 1; b **= 2; c //= 3; d >>= e <= f; g := h == i"
+
+    expected := []token.TokenType{
+        token.IDENT, token.PLUS_ASSIGN, token.INT, token.SEMICOLON,
+        token.IDENT, token.POWER_ASSIGN, token.INT, token.SEMICOLON,
+        token.IDENT, token.DOUBLE_SLASH_ASSIGN, token.INT, token.SEMICOLON,
+        token.IDENT, token.RSHIFT_ASSIGN, token.IDENT, token.LTE, token.IDENT, token.SEMICOLON,
+        token.IDENT, token.WALRUS, token.IDENT, token.EQ, token.IDENT,
+    }
+
+    l := New(input)
+    for i, tokenType := range expected {
+        if tok := l.NextToken(); tok.Type != tokenType {
+            t.Fatalf("tests[%d] - type wrong. expected=%q, got=%q", i, tokenType, tok.Type)
+        }
+    }
+}
@@ -3,17 +3,77 @@ package main
 
 import (
     "fmt"
+    "interpreter/evaluator"
+    "interpreter/lexer"
+    "interpreter/parser"
     "interpreter/repl"
     "os"
     "os/user"
+    "path/filepath"
+    "strconv"
 )
 
 func main() {
+    args := os.Args[1:]
+    optimize := optimizeFromEnvironment()
+    if len(args) > 0 && args[0] == "-O" {
+        optimize = true
+        args = args[1:]
+    }
+    if len(args) > 0 {
+        os.Exit(runFile(args[0], optimize))
+    }
     user, err := user.Current()
     if err != nil {
         panic(err)
     }
     fmt.Printf("Hello %s! This is the Python interpreter!\n", user.Username)
     fmt.Printf("Feel free to type in commands\n")
-    repl.Start(os.Stdin, os.Stdout)
-}
\ No newline at end of file
+    env := evaluator.NewEnvironment()
+    env.AddSearchPath(filepath.SplitList(os.Getenv("PYTHONPATH"))...)
+    env.SetOptimize(optimize)
+    os.Exit(repl.Start(os.Stdin, os.Stdout, env))
+}
+
+// optimizeFromEnvironment is PYTHONOPTIMIZE, which turns on -O when set to
+// anything but nothing or 0
+func optimizeFromEnvironment() bool {
+    value := os.Getenv("PYTHONOPTIMIZE")
+    if n, err := strconv.Atoi(value); err == nil {
+        return n != 0
+    }
+    return value != ""
+}
+
+// runFile runs a script the way `python script.py` does, returning the exit
+// status. optimize is -O or PYTHONOPTIMIZE, which skip assert statements.
+func runFile(filename string, optimize bool) int {
+    source, err := os.ReadFile(filename)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "can't open file '%s': %v\n", filename, err)
+        return 2
+    }
+    if path, err := filepath.Abs(filename); err == nil {
+        filename = path
+    }
+    env := evaluator.NewFileEnvironment(filename)
+    env.AddSearchPath(filepath.SplitList(os.Getenv("PYTHONPATH"))...)
+    env.RegisterSource(filename, string(source))
+    p := parser.New(lexer.New(string(source)))
+    program := p.ParseProgram()
+    if len(p.Errors()) != 0 {
+        fmt.Fprint(os.Stderr, evaluator.FormatTraceback(evaluator.ParseError(p, filename, string(source)), env))
+        return 1
+    }
+
+    env.SetOptimize(optimize)
+    result := evaluator.Eval(program, env)
+    if status, ok := evaluator.ExitStatus(result, env); ok {
+        return status
+    }
+    if traceback := evaluator.FormatTraceback(result, env); traceback != "" {
+        fmt.Fprint(os.Stderr, traceback)
+        return 1
+    }
+    return 0
+}
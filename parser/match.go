@@ -0,0 +1,649 @@
+package parser
+
+import (
+    "fmt"
+    "interpreter/token"
+)
+
+// match and case are soft keywords (PEP 634): they only mean something at the
+// start of a match statement or inside its block, so a variable called match
+// keeps working everywhere else.
+
+// atMatchStatement tells whether curTok is the `match` of a match statement
+// rather than a name. Only a match statement's first line ends in a colon.
+func (p *Parser) atMatchStatement() bool {
+    if p.curTok.Type != token.IDENT || p.curTok.Literal != "match" {
+        return false
+    }
+    if !p.peekStartsExpression() && (p.peekTok.LineStart || !p.peekTokenIs(token.ASTERISK)) {
+        return false
+    }
+    l := p.l.Clone()
+    last := p.peekTok
+    for tok := l.NextToken(); !tok.LineStart && tok.Type != token.EOF; tok = l.NextToken() {
+        last = tok
+    }
+    return last.Type == token.COLON
+}
+
+func (p *Parser) parseMatchStatement() *MatchStatement {
+    p.nextToken() // Skip 'match'
+
+    subject := p.parseMatchSubject()
+    if subject == nil || !p.expectPeek(token.COLON) {
+        return nil
+    }
+    if !p.expectPeek(token.INDENT) {
+        return nil
+    }
+    p.indentLevel++
+    p.nextToken() // Skip INDENT
+
+    stmt := &MatchStatement{Subject: subject}
+    for p.curTok.Type != token.DEDENT && p.curTok.Type != token.EOF {
+        if p.curTok.Type != token.IDENT || p.curTok.Literal != "case" {
+            p.addError(fmt.Sprintf("expected 'case', got %s", p.curTok.Type))
+            return nil
+        }
+        matchCase := p.parseMatchCase()
+        if matchCase == nil {
+            p.skipLine()
+            return nil
+        }
+        stmt.Cases = append(stmt.Cases, matchCase)
+        p.nextToken()
+    }
+    p.indentLevel--
+
+    for i, matchCase := range stmt.Cases {
+        last := i == len(stmt.Cases)-1
+        if !p.checkPattern(matchCase.Pattern, last || matchCase.Guard != nil, &[]string{}) {
+            return nil
+        }
+    }
+    return stmt
+}
+
+// parseMatchSubject parses what follows `match`: an expression, or a tuple
+// of them which may have starred items
+func (p *Parser) parseMatchSubject() Expression {
+    start := p.curTok
+    elements := []Expression{}
+    for {
+        var element Expression
+        if p.curTok.Type == token.ASTERISK {
+            starStart := p.curTok
+            p.nextToken() // Skip '*'
+            element = &StarredExpression{Value: p.parseExpression(BITOR)}
+            p.locate(element, starStart)
+        } else {
+            element = p.parseNamedExpression()
+        }
+        if element == nil {
+            return nil
+        }
+        elements = append(elements, element)
+        if !p.peekTokenIs(token.COMMA) {
+            break
+        }
+        p.nextToken()
+        if p.peekTokenIs(token.COLON) {
+            break
+        }
+        p.nextToken()
+    }
+    if _, starred := elements[0].(*StarredExpression); len(elements) == 1 && !starred && p.curTok.Type != token.COMMA {
+        return elements[0]
+    }
+    tuple := &TupleLiteral{Elements: elements}
+    p.locate(tuple, start)
+    return tuple
+}
+
+// parseMatchCase parses `case pattern [if guard]:` and its block; curTok is 'case'
+func (p *Parser) parseMatchCase() *MatchCase {
+    p.nextToken() // Skip 'case'
+
+    matchCase := &MatchCase{Pattern: p.parseCasePattern()}
+    if matchCase.Pattern == nil {
+        return nil
+    }
+    if p.peekTokenIs(token.IF) {
+        p.nextToken()
+        p.nextToken() // Skip 'if'
+        if matchCase.Guard = p.parseNamedExpression(); matchCase.Guard == nil {
+            return nil
+        }
+    }
+    if !p.expectPeek(token.COLON) {
+        return nil
+    }
+    p.nextToken() // Skip ':'
+    matchCase.Body = p.parseBlock()
+    return matchCase
+}
+
+// parseCasePattern parses the pattern after `case`, where a, b without
+// brackets is a sequence pattern
+func (p *Parser) parseCasePattern() Pattern {
+    start := p.curTok
+    first := p.parseStarOrPattern()
+    if first == nil {
+        return nil
+    }
+    if !p.peekTokenIs(token.COMMA) {
+        if _, star := first.(*MatchStar); star {
+            p.addError("invalid syntax: can't use starred pattern here")
+            return nil
+        }
+        return first
+    }
+
+    sequence := &MatchSequence{Patterns: []Pattern{first}}
+    for p.peekTokenIs(token.COMMA) {
+        p.nextToken()
+        if p.peekTokenIs(token.COLON) || p.peekTokenIs(token.IF) {
+            break
+        }
+        p.nextToken()
+        pattern := p.parseStarOrPattern()
+        if pattern == nil {
+            return nil
+        }
+        sequence.Patterns = append(sequence.Patterns, pattern)
+    }
+    p.locate(sequence, start)
+    return sequence
+}
+
+// parseStarOrPattern parses an element of a sequence pattern, which may be *name
+func (p *Parser) parseStarOrPattern() Pattern {
+    if p.curTok.Type != token.ASTERISK {
+        return p.parsePattern()
+    }
+    start := p.curTok
+    if !p.expectPeek(token.IDENT) {
+        return nil
+    }
+    star := &MatchStar{}
+    if p.curTok.Literal != "_" {
+        star.Name = p.curTok.Literal
+    }
+    p.locate(star, start)
+    return star
+}
+
+// parsePattern parses alternatives joined by | and an optional `as name`
+func (p *Parser) parsePattern() Pattern {
+    start := p.curTok
+    pattern := p.parseClosedPattern()
+    if pattern == nil {
+        return nil
+    }
+
+    if p.peekTokenIs(token.PIPE) {
+        or := &MatchOr{Patterns: []Pattern{pattern}}
+        for p.peekTokenIs(token.PIPE) {
+            p.nextToken()
+            p.nextToken() // Skip '|'
+            alternative := p.parseClosedPattern()
+            if alternative == nil {
+                return nil
+            }
+            or.Patterns = append(or.Patterns, alternative)
+        }
+        p.locate(or, start)
+        pattern = or
+    }
+
+    if p.peekTokenIs(token.AS) {
+        p.nextToken()
+        p.nextToken() // Skip 'as'
+        if p.curTok.Type != token.IDENT || p.peekTokenIs(token.DOT) || p.peekTokenIs(token.LPAREN) || p.peekTokenIs(token.LBRACKET) {
+            p.addError("invalid pattern target")
+            return nil
+        }
+        if p.curTok.Literal == "_" {
+            p.addError("cannot use '_' as a target")
+            return nil
+        }
+        pattern = &MatchAs{Pattern: pattern, Name: p.curTok.Literal}
+        p.locate(pattern, start)
+    }
+    return pattern
+}
+
+// parseClosedPattern parses one pattern that needs no | or `as` around it
+func (p *Parser) parseClosedPattern() Pattern {
+    start := p.curTok
+    var pattern Pattern
+
+    switch p.curTok.Type {
+    case token.IDENT:
+        if !p.peekTokenIs(token.DOT) && !p.peekTokenIs(token.LPAREN) {
+            if p.curTok.Literal == "_" {
+                pattern = &MatchAs{}
+            } else {
+                pattern = &MatchAs{Name: p.curTok.Literal}
+            }
+            break
+        }
+        name := p.parseValueName()
+        if name == nil {
+            return nil
+        }
+        if p.peekTokenIs(token.LPAREN) {
+            p.nextToken()
+            pattern = p.parseClassPattern(name)
+        } else {
+            pattern = &MatchValue{Value: name}
+        }
+    case token.INT, token.FLOAT, token.IMAG, token.STRING, token.MINUS:
+        value := p.parseLiteralPattern()
+        if value == nil {
+            return nil
+        }
+        pattern = &MatchValue{Value: value}
+    case token.NONE:
+        pattern = &MatchSingleton{Value: &NoneLiteral{}}
+    case token.TRUE:
+        pattern = &MatchSingleton{Value: &BooleanLiteral{Value: true}}
+    case token.FALSE:
+        pattern = &MatchSingleton{Value: &BooleanLiteral{Value: false}}
+    case token.LPAREN:
+        pattern = p.parseGroupPattern()
+    case token.LBRACKET:
+        patterns := p.parsePatternsUntil(token.RBRACKET)
+        if patterns == nil {
+            return nil
+        }
+        pattern = &MatchSequence{Patterns: patterns}
+    case token.LBRACE:
+        pattern = p.parseMappingPattern()
+    default:
+        p.addError(fmt.Sprintf("invalid syntax: unexpected %s in pattern", p.curTok.Type))
+        return nil
+    }
+
+    if pattern == nil {
+        return nil
+    }
+    p.locate(pattern, start)
+    return pattern
+}
+
+// parseValueName parses the dotted name of a value or class pattern
+func (p *Parser) parseValueName() Expression {
+    start := p.curTok
+    var name Expression = &Identifier{Value: p.curTok.Literal}
+    p.locate(name, start)
+    for p.peekTokenIs(token.DOT) {
+        p.nextToken()
+        if !p.expectPeek(token.IDENT) {
+            return nil
+        }
+        name = &AttributeExpression{Object: name, Name: p.curTok.Literal}
+        p.locate(name, start)
+    }
+    return name
+}
+
+// parseLiteralPattern parses strings, numbers, optionally negated ones and
+// complex literals like -1+2j
+func (p *Parser) parseLiteralPattern() Expression {
+    start := p.curTok
+    var value Expression
+    switch p.curTok.Type {
+    case token.STRING:
+        for _, prefix := range p.curTok.Literal {
+            if prefix == 'f' || prefix == 'F' {
+                p.addError("patterns may only match literals and attribute lookups")
+                return nil
+            }
+            if prefix == '\'' || prefix == '"' {
+                break
+            }
+        }
+        value = p.parseStringLiteral()
+    case token.MINUS:
+        if !p.peekTokenIs(token.INT) && !p.peekTokenIs(token.FLOAT) && !p.peekTokenIs(token.IMAG) {
+            p.addPeekError(fmt.Sprintf("invalid syntax: unexpected %s in pattern", p.peekTok.Type))
+            return nil
+        }
+        p.nextToken()
+        number := p.parseLiteralPattern()
+        if number == nil {
+            return nil
+        }
+        if complex, ok := number.(*InfixExpression); ok {
+            // -1+2j negates only the real part
+            complex.Left = &PrefixExpression{Operator: "-", Right: complex.Left}
+            return complex
+        }
+        value = &PrefixExpression{Operator: "-", Right: number}
+    case token.INT:
+        value = p.parseIntegerLiteral()
+    case token.IMAG:
+        value = &ImaginaryLiteral{Value: p.curTok.Literal}
+    default:
+        value = p.parseFloatLiteral()
+    }
+    if value != nil {
+        p.locate(value, start)
+    }
+    if p.peekTokenIs(token.PLUS) || p.peekTokenIs(token.MINUS) {
+        if start.Type == token.IMAG {
+            p.addError("real number required in complex literal")
+            return nil
+        }
+        p.nextToken()
+        operator := p.curTok.Literal
+        if !p.peekTokenIs(token.IMAG) {
+            p.addError("imaginary number required in complex literal")
+            return nil
+        }
+        p.nextToken()
+        imaginary := &ImaginaryLiteral{Value: p.curTok.Literal}
+        p.locate(imaginary, p.curTok)
+        value = &InfixExpression{Left: value, Operator: operator, Right: imaginary}
+        p.locate(value, start)
+    }
+    return value
+}
+
+// parseGroupPattern parses (pattern), which is just the pattern, and the
+// tuple-like sequence patterns () and (a, b)
+func (p *Parser) parseGroupPattern() Pattern {
+    if p.peekTokenIs(token.RPAREN) {
+        p.nextToken()
+        return &MatchSequence{Patterns: []Pattern{}}
+    }
+    p.nextToken() // Skip '('
+    first := p.parseStarOrPattern()
+    if first == nil {
+        return nil
+    }
+    if _, star := first.(*MatchStar); !star && p.peekTokenIs(token.RPAREN) {
+        p.nextToken()
+        return first
+    }
+    if !p.expectPeek(token.COMMA) {
+        return nil
+    }
+    rest := p.parsePatternsUntil(token.RPAREN)
+    if rest == nil {
+        return nil
+    }
+    return &MatchSequence{Patterns: append([]Pattern{first}, rest...)}
+}
+
+// parsePatternsUntil parses comma separated patterns, stars allowed, up to
+// the closing token; curTok is the token before the first one
+func (p *Parser) parsePatternsUntil(end token.TokenType) []Pattern {
+    patterns := []Pattern{}
+    for !p.peekTokenIs(end) {
+        p.nextToken()
+        pattern := p.parseStarOrPattern()
+        if pattern == nil {
+            return nil
+        }
+        patterns = append(patterns, pattern)
+        if !p.peekTokenIs(token.COMMA) {
+            break
+        }
+        p.nextToken()
+    }
+    if !p.expectPeek(end) {
+        return nil
+    }
+    return patterns
+}
+
+// parseMappingPattern parses {key: pattern, ..., **rest}; curTok is '{'
+func (p *Parser) parseMappingPattern() Pattern {
+    mapping := &MatchMapping{}
+    for !p.peekTokenIs(token.RBRACE) {
+        p.nextToken()
+        if mapping.Rest != "" {
+            p.addError("invalid syntax: **rest must come last in a mapping pattern")
+            return nil
+        }
+        if p.curTok.Type == token.POWER {
+            if !p.expectPeek(token.IDENT) {
+                return nil
+            }
+            if p.curTok.Literal == "_" {
+                p.addError("invalid syntax: can't use **_ in a mapping pattern")
+                return nil
+            }
+            mapping.Rest = p.curTok.Literal
+        } else {
+            key := p.parseMappingKey()
+            if key == nil || !p.expectPeek(token.COLON) {
+                return nil
+            }
+            p.nextToken() // Skip ':'
+            pattern := p.parsePattern()
+            if pattern == nil {
+                return nil
+            }
+            mapping.Keys = append(mapping.Keys, key)
+            mapping.Patterns = append(mapping.Patterns, pattern)
+        }
+        if !p.peekTokenIs(token.COMMA) {
+            break
+        }
+        p.nextToken()
+    }
+    if !p.expectPeek(token.RBRACE) {
+        return nil
+    }
+
+    seen := map[string]bool{}
+    for _, key := range mapping.Keys {
+        if literal := literalKey(key); literal != "" {
+            if seen[literal] {
+                p.addError(fmt.Sprintf("mapping pattern checks duplicate key (%s)", literal))
+                return nil
+            }
+            seen[literal] = true
+        }
+    }
+    return mapping
+}
+
+// parseMappingKey parses a key of a mapping pattern: a literal or a dotted name
+func (p *Parser) parseMappingKey() Expression {
+    start := p.curTok
+    switch p.curTok.Type {
+    case token.INT, token.FLOAT, token.IMAG, token.STRING, token.MINUS:
+        return p.parseLiteralPattern()
+    case token.NONE:
+        key := &NoneLiteral{}
+        p.locate(key, start)
+        return key
+    case token.TRUE, token.FALSE:
+        key := &BooleanLiteral{Value: p.curTok.Type == token.TRUE}
+        p.locate(key, start)
+        return key
+    case token.IDENT:
+        if p.peekTokenIs(token.DOT) {
+            return p.parseValueName()
+        }
+    }
+    p.addError(fmt.Sprintf("invalid syntax: unexpected %s in mapping pattern key", p.curTok.Type))
+    return nil
+}
+
+// literalKey is how a literal mapping key reads in the duplicate key error,
+// or empty for keys only known at runtime
+func literalKey(key Expression) string {
+    switch key := key.(type) {
+    case *StringLiteral:
+        return fmt.Sprintf("'%s'", key.Value)
+    case *IntegerLiteral:
+        return key.Value
+    case *FloatLiteral:
+        return key.Value
+    case *ImaginaryLiteral:
+        return key.Value
+    case *InfixExpression:
+        left, right := literalKey(key.Left), literalKey(key.Right)
+        if left != "" && right != "" {
+            return left + " " + key.Operator + " " + right
+        }
+    case *PrefixExpression:
+        if right := literalKey(key.Right); right != "" {
+            return "-" + right
+        }
+    case *NoneLiteral:
+        return "None"
+    case *BooleanLiteral:
+        if key.Value {
+            return "True"
+        }
+        return "False"
+    }
+    return ""
+}
+
+// parseClassPattern parses the arguments of Cls(a, b, x=c); curTok is '('
+func (p *Parser) parseClassPattern(class Expression) Pattern {
+    pattern := &MatchClass{Class: class}
+    for !p.peekTokenIs(token.RPAREN) {
+        p.nextToken()
+        if p.curTok.Type == token.IDENT && p.peekTokenIs(token.ASSIGN) {
+            name := p.curTok.Literal
+            for _, attr := range pattern.KwdAttrs {
+                if attr == name {
+                    p.addError(fmt.Sprintf("attribute name repeated in class pattern: %s", name))
+                    return nil
+                }
+            }
+            p.nextToken()
+            p.nextToken() // Skip '='
+            value := p.parsePattern()
+            if value == nil {
+                return nil
+            }
+            pattern.KwdAttrs = append(pattern.KwdAttrs, name)
+            pattern.KwdPatterns = append(pattern.KwdPatterns, value)
+        } else {
+            if len(pattern.KwdAttrs) > 0 {
+                p.addError("positional patterns follow keyword patterns")
+                return nil
+            }
+            value := p.parsePattern()
+            if value == nil {
+                return nil
+            }
+            pattern.Patterns = append(pattern.Patterns, value)
+        }
+        if !p.peekTokenIs(token.COMMA) {
+            break
+        }
+        p.nextToken()
+    }
+    if !p.expectPeek(token.RPAREN) {
+        return nil
+    }
+    return pattern
+}
+
+// checkPattern does what CPython's compiler checks once a case is parsed:
+// nothing may hide the patterns after it, every alternative binds the same
+// names, and no name is bound twice. names collects what the pattern binds.
+func (p *Parser) checkPattern(pattern Pattern, allowIrrefutable bool, names *[]string) bool {
+    switch pattern := pattern.(type) {
+    case *MatchAs:
+        if pattern.Pattern == nil && !allowIrrefutable {
+            if pattern.Name != "" {
+                p.addError(fmt.Sprintf("name capture '%s' makes remaining patterns unreachable", pattern.Name))
+            } else {
+                p.addError("wildcard makes remaining patterns unreachable")
+            }
+            return false
+        }
+        if pattern.Pattern != nil && !p.checkPattern(pattern.Pattern, allowIrrefutable, names) {
+            return false
+        }
+        return pattern.Name == "" || p.storeName(pattern.Name, names)
+    case *MatchStar:
+        return pattern.Name == "" || p.storeName(pattern.Name, names)
+    case *MatchSequence:
+        stars := 0
+        for _, element := range pattern.Patterns {
+            if _, star := element.(*MatchStar); star {
+                stars++
+            }
+        }
+        if stars > 1 {
+            p.addError("multiple starred names in sequence pattern")
+            return false
+        }
+        return p.checkPatterns(pattern.Patterns, names)
+    case *MatchMapping:
+        if !p.checkPatterns(pattern.Patterns, names) {
+            return false
+        }
+        return pattern.Rest == "" || p.storeName(pattern.Rest, names)
+    case *MatchClass:
+        return p.checkPatterns(pattern.Patterns, names) && p.checkPatterns(pattern.KwdPatterns, names)
+    case *MatchOr:
+        var bound []string
+        for i, alternative := range pattern.Patterns {
+            alternativeNames := []string{}
+            if !p.checkPattern(alternative, allowIrrefutable && i == len(pattern.Patterns)-1, &alternativeNames) {
+                return false
+            }
+            if i > 0 && !sameNames(bound, alternativeNames) {
+                p.addError("alternative patterns bind different names")
+                return false
+            }
+            bound = alternativeNames
+        }
+        for _, name := range bound {
+            if !p.storeName(name, names) {
+                return false
+            }
+        }
+    }
+    return true
+}
+
+// checkPatterns checks sub-patterns, which may always be irrefutable
+func (p *Parser) checkPatterns(patterns []Pattern, names *[]string) bool {
+    for _, pattern := range patterns {
+        if !p.checkPattern(pattern, true, names) {
+            return false
+        }
+    }
+    return true
+}
+
+func (p *Parser) storeName(name string, names *[]string) bool {
+    for _, bound := range *names {
+        if bound == name {
+            p.addError(fmt.Sprintf("multiple assignments to name '%s' in pattern", name))
+            return false
+        }
+    }
+    *names = append(*names, name)
+    return true
+}
+
+func sameNames(a, b []string) bool {
+    if len(a) != len(b) {
+        return false
+    }
+    seen := map[string]bool{}
+    for _, name := range a {
+        seen[name] = true
+    }
+    for _, name := range b {
+        if !seen[name] {
+            return false
+        }
+    }
+    return true
+}
@@ -4,28 +4,59 @@ import (
     "fmt"
     "interpreter/lexer"
     "interpreter/token"
+    "reflect"
 )
 
 // Precedence levels - critical for proper parsing order!
+// They follow Python's grammar from the loosest binding to the tightest.
 const (
     _ int = iota
     LOWEST
-    EQUALS      // ==
-    LESSGREATER // < or >
+    TERNARY     // a if cond else b
+    OR          // or
+    AND         // and
+    NOT         // not x
+    COMPARISON  // ==, <, in, is ... (all chain at the same level)
+    BITOR       // |
+    BITXOR      // ^
+    BITAND      // &
+    SHIFT       // << or >>
     SUM         // +
-    PRODUCT     // *
-    CALL        // function calls
+    PRODUCT     // *, /, //, %, @
+    PREFIX      // -x, +x, ~x
+    POWER       // **
+    CALL        // function calls, subscripts and attribute access
 )
 
 var precedences = map[token.TokenType]int{
-    token.EQ:       EQUALS,
-    token.NOT_EQ:   EQUALS,
-    token.LT:       LESSGREATER,
-    token.GT:       LESSGREATER,
-    token.PLUS:     SUM,
-    token.MINUS:    SUM,
-    token.ASTERISK: PRODUCT,
-    token.SLASH:    PRODUCT,
+    token.IF:           TERNARY,
+    token.OR:           OR,
+    token.AND:          AND,
+    token.EQ:           COMPARISON,
+    token.NOT_EQ:       COMPARISON,
+    token.LT:           COMPARISON,
+    token.GT:           COMPARISON,
+    token.LTE:          COMPARISON,
+    token.GTE:          COMPARISON,
+    token.IN:           COMPARISON,
+    token.NOT:          COMPARISON, // only ever infix as `not in`
+    token.IS:           COMPARISON,
+    token.PIPE:         BITOR,
+    token.CARET:        BITXOR,
+    token.AMPERSAND:    BITAND,
+    token.LSHIFT:       SHIFT,
+    token.RSHIFT:       SHIFT,
+    token.PLUS:         SUM,
+    token.MINUS:        SUM,
+    token.ASTERISK:     PRODUCT,
+    token.SLASH:        PRODUCT,
+    token.DOUBLE_SLASH: PRODUCT,
+    token.PERCENT:      PRODUCT,
+    token.AT:           PRODUCT,
+    token.POWER:        POWER,
+    token.LPAREN:       CALL,
+    token.LBRACKET:     CALL,
+    token.DOT:          CALL,
 }
 
 // Parser - Don't mess with this unless you know what you're doing!
@@ -34,7 +65,11 @@ type Parser struct {
     curTok      token.Token
     peekTok     token.Token
     errors      []string
+    errorsAt    []Position // where each of errors was found
     indentLevel int
+    inFunction  bool // yield is only allowed in a def
+    sawYield    bool // the def being parsed is a generator
+    nested      int  // defs and classes we are inside; import * wants none
 }
 
 func New(l *lexer.Lexer) *Parser {
@@ -53,15 +88,6 @@ func (p *Parser) nextToken() {
 func (p *Parser) ParseProgram() *Program {
     program := &Program{Statements: []Statement{}}
 
-    // Special case for if statements
-    if p.curTok.Type == token.IF {
-        stmt := p.parseIfStatement()
-        if stmt != nil {
-            program.Statements = append(program.Statements, stmt)
-        }
-        return program
-    }
-
     for p.curTok.Type != token.EOF {
         stmt := p.parseStatement()
         if stmt != nil {
@@ -71,36 +97,169 @@ func (p *Parser) ParseProgram() *Program {
         p.nextToken()
     }
 
+    if len(p.errors) == 0 {
+        p.analyzeScopes(program)
+    }
     return program
 }
 
 // parseStatement handles different statement types
 func (p *Parser) parseStatement() Statement {
+    start := p.curTok
+    stmt := p.parseStatementAt()
+    p.locate(stmt, start)
+    return stmt
+}
+
+func (p *Parser) parseStatementAt() Statement {
+    if p.atMatchStatement() {
+        return p.parseMatchStatement()
+    }
     switch p.curTok.Type {
     case token.DEF:
         return p.parseFunctionDefinition()
+    case token.CLASS:
+        return p.parseClassDefinition()
     case token.IF:
         return p.parseIfStatement()
+    case token.WHILE:
+        return p.parseWhileStatement()
+    case token.FOR:
+        return p.parseForStatement()
+    case token.TRY:
+        return p.parseTryStatement()
+    case token.AT:
+        return p.parseDecorated()
+    case token.WITH:
+        return p.parseWithStatement()
+    case token.INDENT, token.DEDENT, token.COLON, token.SEMICOLON:
+        return nil
+    case token.ILLEGAL:
+        p.addError(p.curTok.Literal)
+        p.skipLine()
+        return nil
+    default:
+        if p.curTok.Type == token.EOF {
+            return nil
+        }
+        start := p.curTok
+        stmt := p.parseSimpleStatement()
+        p.locate(stmt, start)
+        p.endSimpleStatement()
+        return stmt
+    }
+}
+
+// parseSimpleStatement parses the statements that fit on a single line
+func (p *Parser) parseSimpleStatement() Statement {
+    switch p.curTok.Type {
     case token.RETURN:
         return p.parseReturnStatement()
-    case token.IDENT:
+    case token.RAISE:
+        return p.parseRaiseStatement()
+    case token.PASS:
+        return &PassStatement{}
+    case token.BREAK:
+        return &BreakStatement{}
+    case token.CONTINUE:
+        return &ContinueStatement{}
+    case token.GLOBAL, token.NONLOCAL:
+        return p.parseDeclaration()
+    case token.DEL:
+        return p.parseDeleteStatement()
+    case token.ASSERT:
+        return p.parseAssertStatement()
+    case token.IMPORT:
+        return p.parseImportStatement()
+    case token.FROM:
+        return p.parseFromImportStatement()
+    default:
+        var expr Expression
+        if p.curTok.Type == token.YIELD {
+            expr = p.parseYieldExpression()
+        } else {
+            expr = p.parseExpressionList()
+        }
         if p.peekTokenIs(token.ASSIGN) {
-            return p.parseAssignmentStatement()
+            return p.parseAssignmentStatement(expr)
         }
-        return p.parseExpressionStatement()
-    case token.INDENT, token.DEDENT, token.COLON:
-        return nil
-    default:
-        if p.curTok.Type == token.EOF {
+        if _, ok := augmentedOperators[p.peekTok.Type]; ok {
+            return p.parseAugmentedAssignment(expr)
+        }
+        return &ExpressionStatement{Expression: expr}
+    }
+}
+
+// endSimpleStatement makes sure nothing trails a simple statement on its line
+func (p *Parser) endSimpleStatement() {
+    if p.peekTokenIs(token.SEMICOLON) {
+        p.nextToken()
+        return
+    }
+    if !p.peekTok.LineStart && !p.peekTokenIs(token.EOF) {
+        p.addPeekError(fmt.Sprintf("invalid syntax: unexpected %s", p.peekTok.Type))
+        p.skipLine()
+    }
+}
+
+// skipLine discards the rest of a broken line so one mistake means one error
+func (p *Parser) skipLine() {
+    for !p.peekTok.LineStart && !p.peekTokenIs(token.EOF) {
+        p.nextToken()
+    }
+}
+
+// parseDecorated parses the @decorator lines in front of a def or class.
+// Any expression will do as a decorator, like PEP 614 says.
+func (p *Parser) parseDecorated() Statement {
+    decorators := []Expression{}
+    for p.curTok.Type == token.AT {
+        p.nextToken() // Skip '@'
+        decorator := p.parseExpression(LOWEST)
+        if decorator == nil {
+            return nil
+        }
+        decorators = append(decorators, decorator)
+        if !p.peekTok.LineStart {
+            p.addPeekError(fmt.Sprintf("invalid syntax: unexpected %s after decorator", p.peekTok.Type))
+            p.skipLine()
+            return nil
+        }
+        p.nextToken()
+    }
+
+    // the statement starts at def or class, not at the first decorator
+    start := p.curTok
+    switch p.curTok.Type {
+    case token.DEF:
+        def := p.parseFunctionDefinition()
+        if def == nil {
+            return nil
+        }
+        p.locate(def, start)
+        def.Decorators = decorators
+        return def
+    case token.CLASS:
+        class := p.parseClassDefinition()
+        if class == nil {
             return nil
         }
-        return p.parseExpressionStatement()
+        p.locate(class, start)
+        class.Decorators = decorators
+        return class
     }
+    p.addError(fmt.Sprintf("expected def or class after decorator, got %s", p.curTok.Type))
+    p.skipLine()
+    return nil
 }
 
 func (p *Parser) parseFunctionDefinition() *FunctionDefinition {
     p.nextToken() // Skip 'def'
 
+    if p.curTok.Type != token.IDENT {
+        p.addError(fmt.Sprintf("expected function name, got %s", p.curTok.Type))
+        return nil
+    }
     name := p.curTok.Literal
     p.nextToken() // Skip function name
 
@@ -110,7 +269,10 @@ func (p *Parser) parseFunctionDefinition() *FunctionDefinition {
     }
 
     p.nextToken() // Skip '('
-    parameters := p.parseFunctionParameters()
+    signature, ok := p.parseParameters(token.RPAREN)
+    if !ok {
+        return nil
+    }
 
     if p.curTok.Type != token.RPAREN {
         p.addError(fmt.Sprintf("expected ')', got %s", p.curTok.Type))
@@ -126,36 +288,187 @@ func (p *Parser) parseFunctionDefinition() *FunctionDefinition {
 
     p.nextToken() // Skip ':'
 
+    inFunction, sawYield := p.inFunction, p.sawYield
+    p.inFunction, p.sawYield = true, false
+    p.nested++
     body := p.parseBlock()
+    p.nested--
+    generator := p.sawYield
+    p.inFunction, p.sawYield = inFunction, sawYield
+
+    return &FunctionDefinition{Name: name, Signature: signature, Body: body, Generator: generator}
+}
+
+// parseParameters parses a def or lambda parameter list - defaults, /, *args,
+// keyword-only parameters and **kwargs - and leaves curTok on the end token
+func (p *Parser) parseParameters(end token.TokenType) (Signature, bool) {
+    signature := Signature{Parameters: []string{}}
+    seen := map[string]bool{}
+    star := false // past a bare * or *args
+
+    // declare takes the parameter name under curTok, refusing duplicates
+    declare := func() (string, bool) {
+        if p.curTok.Type != token.IDENT {
+            p.addError(fmt.Sprintf("expected parameter name, got %s", p.curTok.Type))
+            return "", false
+        }
+        name := p.curTok.Literal
+        if seen[name] {
+            p.addError(fmt.Sprintf("duplicate argument '%s' in function definition", name))
+            return "", false
+        }
+        seen[name] = true
+        p.nextToken() // Skip the name
+        return name, true
+    }
+
+    for p.curTok.Type != end {
+        switch p.curTok.Type {
+        case token.SLASH:
+            switch {
+            case star:
+                p.addError("/ must be ahead of *")
+                return signature, false
+            case signature.PositionalOnly > 0:
+                p.addError("/ may appear only once")
+                return signature, false
+            case len(signature.Parameters) == 0:
+                p.addError("at least one argument must precede /")
+                return signature, false
+            }
+            signature.PositionalOnly = len(signature.Parameters)
+            p.nextToken() // Skip '/'
+        case token.ASTERISK:
+            if star {
+                p.addError("* argument may appear only once")
+                return signature, false
+            }
+            star = true
+            p.nextToken() // Skip '*'
+            if p.curTok.Type == token.IDENT {
+                name, ok := declare()
+                if !ok {
+                    return signature, false
+                }
+                signature.VarArgs = name
+                if p.curTok.Type == token.ASSIGN {
+                    p.addError("var-positional argument cannot have default value")
+                    return signature, false
+                }
+            }
+        case token.POWER:
+            p.nextToken() // Skip '**'
+            name, ok := declare()
+            if !ok {
+                return signature, false
+            }
+            signature.KwArgs = name
+            if p.curTok.Type == token.ASSIGN {
+                p.addError("var-keyword argument cannot have default value")
+                return signature, false
+            }
+            if p.curTok.Type == token.COMMA {
+                p.nextToken() // Skip ','
+            }
+            if p.curTok.Type != end {
+                p.addError("arguments cannot follow var-keyword argument")
+                return signature, false
+            }
+            continue
+        default:
+            name, ok := declare()
+            if !ok {
+                return signature, false
+            }
+            var value Expression
+            if p.curTok.Type == token.ASSIGN {
+                p.nextToken() // Skip '='
+                if value = p.parseExpression(LOWEST); value == nil {
+                    return signature, false
+                }
+                p.nextToken() // Skip the default
+            }
+            if star {
+                signature.KeywordOnly = append(signature.KeywordOnly, name)
+                signature.KwDefaults = append(signature.KwDefaults, value)
+            } else {
+                if value != nil {
+                    signature.Defaults = append(signature.Defaults, value)
+                } else if len(signature.Defaults) > 0 {
+                    p.addError("non-default argument follows default argument")
+                    return signature, false
+                }
+                signature.Parameters = append(signature.Parameters, name)
+            }
+        }
+
+        if p.curTok.Type != token.COMMA {
+            break
+        }
+        p.nextToken() // Skip ','
+    }
 
-    return &FunctionDefinition{Name: name, Parameters: parameters, Body: body}
+    if star && signature.VarArgs == "" && len(signature.KeywordOnly) == 0 {
+        p.addError("named arguments must follow bare *")
+        return signature, false
+    }
+    return signature, true
 }
 
-func (p *Parser) parseFunctionParameters() []string {
-    parameters := []string{}
+func (p *Parser) parseClassDefinition() *ClassDefinition {
+    p.nextToken() // Skip 'class'
 
-    if p.curTok.Type == token.RPAREN {
-        return parameters
+    if p.curTok.Type != token.IDENT {
+        p.addError(fmt.Sprintf("expected class name, got %s", p.curTok.Type))
+        return nil
     }
+    class := &ClassDefinition{Name: p.curTok.Literal}
+    p.nextToken() // Skip class name
 
-    parameters = append(parameters, p.curTok.Literal)
+    if p.curTok.Type == token.LPAREN {
+        class.Bases, class.Keywords = p.parseCallArguments()
+        p.nextToken() // Skip ')'
+    }
 
-    for p.peekTok.Type == token.COMMA {
-        p.nextToken() // Skip current parameter
-        p.nextToken() // Move to next parameter
-        parameters = append(parameters, p.curTok.Literal)
+    if p.curTok.Type != token.COLON {
+        p.addError(fmt.Sprintf("expected ':', got %s", p.curTok.Type))
+        return nil
     }
 
-    return parameters
+    p.nextToken() // Skip ':'
+    inFunction := p.inFunction
+    p.inFunction = false
+    p.nested++
+    class.Body = p.parseBlock()
+    p.nested--
+    p.inFunction = inFunction
+    return class
 }
 
 // Indentation is CRUCIAL - one wrong tab and the whole code falls apart!
+// A block is either an indented suite or simple statements on the same line
+// as the colon, like `if x: return y`.
 func (p *Parser) parseBlock() []Statement {
     block := []Statement{}
 
     if p.curTok.Type != token.INDENT {
-        p.addError(fmt.Sprintf("expected INDENT, got %s", p.curTok.Type))
-        return block
+        if p.curTok.LineStart || p.curTok.Type == token.EOF {
+            p.addError(fmt.Sprintf("expected INDENT, got %s", p.curTok.Type))
+            return block
+        }
+        for {
+            stmt := p.parseSimpleStatement()
+            if stmt != nil {
+                block = append(block, stmt)
+            }
+            if p.peekTokenIs(token.SEMICOLON) {
+                p.nextToken()
+            }
+            if p.peekTok.LineStart || p.peekTokenIs(token.EOF) {
+                return block
+            }
+            p.nextToken()
+        }
     }
 
     p.indentLevel++
@@ -176,16 +489,15 @@ func (p *Parser) parseBlock() []Statement {
 
 // parseIfStatement - unlike Harvey who cuts corners, I handle EVERY edge case
 func (p *Parser) parseIfStatement() *IfStatement {
-    p.nextToken() // Skip 'if'
+    p.nextToken() // Skip 'if' or 'elif'
 
-    condition := p.parseExpression(LOWEST)
+    condition := p.parseNamedExpression()
     if condition == nil {
         p.addError(fmt.Sprintf("failed to parse condition after 'if'"))
         return nil
     }
 
-    if p.curTok.Type != token.COLON {
-        p.addError(fmt.Sprintf("expected ':', got %s", p.curTok.Type))
+    if !p.expectPeek(token.COLON) {
         return nil
     }
 
@@ -194,16 +506,18 @@ func (p *Parser) parseIfStatement() *IfStatement {
     consequence := p.parseBlock()
 
     var alternative []Statement
-    if p.curTok.Type == token.ELSE {
-        p.nextToken() // Skip 'else'
-
-        if p.curTok.Type != token.COLON {
-            p.addError(fmt.Sprintf("expected ':', got %s", p.curTok.Type))
+    if p.peekTokenIs(token.ELIF) {
+        p.nextToken()
+        elif := p.parseIfStatement()
+        if elif == nil {
+            return nil
+        }
+        alternative = []Statement{elif}
+    } else if p.peekTokenIs(token.ELSE) {
+        alternative = p.parseElseBlock()
+        if alternative == nil {
             return nil
         }
-
-        p.nextToken() // Skip ':'
-        alternative = p.parseBlock()
     }
 
     return &IfStatement{
@@ -218,215 +532,1725 @@ func (p *Parser) ParseIfStatement() *IfStatement {
         p.addError(fmt.Sprintf("expected 'if', got %s", p.curTok.Type))
         return nil
     }
-    
+
     return p.parseIfStatement()
 }
 
-func (p *Parser) parseReturnStatement() *ReturnStatement {
-    p.nextToken() // Skip 'return'
-    value := p.parseExpression(LOWEST)
-    return &ReturnStatement{Value: value}
-}
+// parseElseBlock parses an `else:` suite; peekTok must be the 'else'
+func (p *Parser) parseElseBlock() []Statement {
+    p.nextToken() // Move onto 'else'
 
-func (p *Parser) parseExpressionStatement() *ExpressionStatement {
-    expr := p.parseExpression(LOWEST)
-    return &ExpressionStatement{Expression: expr}
+    if !p.expectPeek(token.COLON) {
+        return nil
+    }
+
+    p.nextToken() // Skip ':'
+    return p.parseBlock()
 }
 
-// This is where the REAL magic happens - the Litt test of parsing
-func (p *Parser) parseExpression(precedence int) Expression {
-    var leftExp Expression
+func (p *Parser) parseWhileStatement() *WhileStatement {
+    p.nextToken() // Skip 'while'
 
-    switch p.curTok.Type {
-    case token.IDENT:
-        leftExp = &Identifier{Value: p.curTok.Literal}
-    case token.INT:
-        leftExp = p.parseIntegerLiteral()
-    case token.FLOAT:
-        leftExp = p.parseFloatLiteral()
-    case token.STRING:
-        leftExp = p.parseStringLiteral()
-    case token.LPAREN:
-        leftExp = p.parseGroupedExpression()
-    default:
-        p.addError(fmt.Sprintf("unexpected token: %s", p.curTok.Type))
+    condition := p.parseNamedExpression()
+    if condition == nil || !p.expectPeek(token.COLON) {
         return nil
     }
 
-    for !p.peekTokenIs(token.SEMICOLON) && precedence < p.peekPrecedence() {
-        switch p.peekTok.Type {
-        case token.PLUS, token.MINUS, token.ASTERISK, token.SLASH, token.EQ, token.NOT_EQ, token.LT, token.GT:
-            p.nextToken()
-            leftExp = p.parseInfixExpression(leftExp)
-        default:
-            return leftExp
+    p.nextToken() // Skip ':'
+    stmt := &WhileStatement{Condition: condition, Body: p.parseBlock()}
+
+    if p.peekTokenIs(token.ELSE) {
+        if stmt.Else = p.parseElseBlock(); stmt.Else == nil {
+            return nil
         }
     }
-
-    return leftExp
+    return stmt
 }
 
-func (p *Parser) parseGroupedExpression() Expression {
-    p.nextToken() // Skip '('
-    exp := p.parseExpression(LOWEST)
-    if !p.expectPeek(token.RPAREN) {
-        p.addError(fmt.Sprintf("expected ')', got %s", p.curTok.Type))
-        return nil
+// parseWithStatement parses `with a as x, b as y:` as well as the
+// parenthesized `with (a as x, b as y):`
+func (p *Parser) parseWithStatement() *WithStatement {
+    p.nextToken() // Skip 'with'
+    stmt := &WithStatement{}
+    var first Expression // a parenthesized start that was an expression after all
+
+    if p.curTok.Type == token.LPAREN {
+        start := p.curTok
+        items := []*WithItem{}
+        trailingComma := false
+        for !p.peekTokenIs(token.RPAREN) {
+            p.nextToken()
+            item := p.parseWithItem(nil)
+            if item == nil {
+                return nil
+            }
+            items = append(items, item)
+            if trailingComma = p.peekTokenIs(token.COMMA); !trailingComma {
+                break
+            }
+            p.nextToken()
+        }
+        if !p.expectPeek(token.RPAREN) {
+            return nil
+        }
+
+        if p.peekTokenIs(token.COLON) && len(items) > 0 {
+            stmt.Items = items
+        } else {
+            // only the start of an expression, like (a).b or (a, b) as c
+            elements := []Expression{}
+            for _, item := range items {
+                if item.Target != nil {
+                    p.addPeekError(fmt.Sprintf("invalid syntax: unexpected %s", p.peekTok.Type))
+                    return nil
+                }
+                elements = append(elements, item.Context)
+            }
+            if len(elements) == 1 && !trailingComma {
+                first = elements[0]
+            } else {
+                first = &TupleLiteral{Elements: elements}
+                p.locate(first, start)
+            }
+            if first = p.parseInfixExpressions(first, LOWEST, start); first == nil {
+                return nil
+            }
+        }
     }
-    return exp
-}
 
-func (p *Parser) parseInfixExpression(left Expression) Expression {
-    operator := p.curTok.Literal
-    precedence := p.curPrecedence()
-    p.nextToken()
-    right := p.parseExpression(precedence)
+    for stmt.Items == nil || first != nil || p.peekTokenIs(token.COMMA) {
+        if stmt.Items != nil && first == nil {
+            p.nextToken()
+            p.nextToken() // Skip ','
+        }
+        item := p.parseWithItem(first)
+        if item == nil {
+            return nil
+        }
+        first = nil
+        stmt.Items = append(stmt.Items, item)
+    }
 
-    return &InfixExpression{
-        Left:     left,
-        Operator: operator,
-        Right:    right,
+    if !p.expectPeek(token.COLON) {
+        return nil
     }
+    p.nextToken() // Skip ':'
+    stmt.Body = p.parseBlock()
+    return stmt
 }
 
-func (p *Parser) parseIntegerLiteral() Expression {
-    value := p.curTok.Literal
-    return &IntegerLiteral{Value: value}
+// parseWithItem parses `context [as target]`. first is the context when it
+// has been parsed already; otherwise curTok is where it starts.
+func (p *Parser) parseWithItem(first Expression) *WithItem {
+    item := &WithItem{Context: first}
+    if item.Context == nil {
+        if item.Context = p.parseExpression(LOWEST); item.Context == nil {
+            return nil
+        }
+    }
+    if p.peekTokenIs(token.AS) {
+        p.nextToken()
+        p.nextToken() // Skip 'as'
+        item.Target = p.parseExpression(LOWEST)
+        if item.Target == nil || !p.checkTarget(item.Target) {
+            return nil
+        }
+    }
+    return item
 }
 
-func (p *Parser) parseFloatLiteral() Expression {
-    value := p.curTok.Literal
-    return &FloatLiteral{Value: value}
-}
+func (p *Parser) parseForStatement() *ForStatement {
+    p.nextToken() // Skip 'for'
 
-func (p *Parser) parseStringLiteral() Expression {
-    value := p.curTok.Literal
-    return &StringLiteral{Value: value}
-}
+    target := p.parseTargetList()
+    if target == nil || !p.expectPeek(token.IN) {
+        return nil
+    }
 
-func (p *Parser) parseAssignmentStatement() *AssignmentStatement {
-    mainIdent := &Identifier{Value: p.curTok.Literal}
-    p.nextToken() // Skip identifier
-    
-    if p.curTok.Type != token.ASSIGN {
-        p.addError(fmt.Sprintf("expected '=', got %s", p.curTok.Type))
+    p.nextToken() // Skip 'in'
+    iterable := p.parseExpressionList()
+    if iterable == nil || !p.expectPeek(token.COLON) {
         return nil
     }
-    
-    p.nextToken() // Skip '='
-    
-    // Handle chained assignments like a = b = 5
-    if p.curTok.Type == token.IDENT && p.peekTokenIs(token.ASSIGN) {
-        rightAssignment := p.parseAssignmentStatement()
-        return &AssignmentStatement{
-            Name: mainIdent,
-            Value: rightAssignment.Value,
+
+    p.nextToken() // Skip ':'
+    stmt := &ForStatement{Target: target, Iterable: iterable, Body: p.parseBlock()}
+
+    if p.peekTokenIs(token.ELSE) {
+        if stmt.Else = p.parseElseBlock(); stmt.Else == nil {
+            return nil
         }
     }
-    
-    value := p.parseExpression(LOWEST)
-    return &AssignmentStatement{
-        Name: mainIdent,
-        Value: value,
-    }
+    return stmt
 }
 
-func (p *Parser) peekPrecedence() int {
-    if prec, ok := precedences[p.peekTok.Type]; ok {
-        return prec
+// parseTargetList parses assignment targets that must stop before `in`
+func (p *Parser) parseTargetList() Expression {
+    first := p.parseExpression(COMPARISON)
+    if !p.peekTokenIs(token.COMMA) {
+        return first
     }
-    return LOWEST
-}
 
-func (p *Parser) curPrecedence() int {
-    if prec, ok := precedences[p.curTok.Type]; ok {
-        return prec
+    targets := []Expression{first}
+    for p.peekTokenIs(token.COMMA) {
+        p.nextToken()
+        if p.peekTokenIs(token.IN) {
+            break
+        }
+        p.nextToken()
+        targets = append(targets, p.parseExpression(COMPARISON))
     }
-    return LOWEST
+    return &TupleLiteral{Elements: targets}
 }
 
-func (p *Parser) peekTokenIs(t token.TokenType) bool {
-    return p.peekTok.Type == t
+func (p *Parser) parseReturnStatement() *ReturnStatement {
+    if p.peekEndsStatement() {
+        return &ReturnStatement{}
+    }
+    p.nextToken() // Skip 'return'
+    value := p.parseExpressionList()
+    return &ReturnStatement{Value: value}
 }
 
-func (p *Parser) expectPeek(t token.TokenType) bool {
-    if p.peekTokenIs(t) {
+// parseYieldExpression handles `yield`, `yield a, b` and `yield from it`,
+// with curTok on 'yield'. Any def containing one is a generator.
+func (p *Parser) parseYieldExpression() Expression {
+    start := p.curTok
+    if !p.inFunction {
+        p.addError("'yield' outside function")
+    }
+    p.sawYield = true
+
+    expr := &YieldExpression{}
+    if p.peekTokenIs(token.FROM) {
         p.nextToken()
-        return true
+        p.nextToken() // Skip 'from'
+        expr.From = true
+        if expr.Value = p.parseExpression(LOWEST); expr.Value == nil {
+            return nil
+        }
+    } else if p.peekStartsExpression() {
+        p.nextToken()
+        if expr.Value = p.parseExpressionList(); expr.Value == nil {
+            return nil
+        }
     }
-    p.addError(fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekTok.Type))
-    return false
+    p.locate(expr, start)
+    return expr
 }
 
-func (p *Parser) addError(msg string) {
-    p.errors = append(p.errors, msg)
-}
+// parseRaiseStatement handles `raise`, `raise exc` and `raise exc from cause`
+func (p *Parser) parseRaiseStatement() *RaiseStatement {
+    stmt := &RaiseStatement{}
+    if p.peekEndsStatement() {
+        return stmt
+    }
+    p.nextToken() // Skip 'raise'
+    stmt.Exception = p.parseExpression(LOWEST)
 
-func (p *Parser) Errors() []string {
-    return p.errors
+    if p.peekTokenIs(token.FROM) {
+        p.nextToken()
+        p.nextToken() // Skip 'from'
+        stmt.Cause = p.parseExpression(LOWEST)
+    }
+    return stmt
 }
 
-// AST Node types
-type Program struct {
-    Statements []Statement
+// parseDeclaration handles `global a, b` and `nonlocal a, b`
+// parseAssertStatement parses `assert test` and `assert test, message`
+func (p *Parser) parseAssertStatement() *AssertStatement {
+    p.nextToken() // Skip 'assert'
+    stmt := &AssertStatement{Test: p.parseExpression(LOWEST)}
+    if stmt.Test == nil {
+        return nil
+    }
+    if p.peekTokenIs(token.COMMA) {
+        p.nextToken()
+        p.nextToken() // Skip ','
+        if stmt.Message = p.parseExpression(LOWEST); stmt.Message == nil {
+            return nil
+        }
+    }
+    return stmt
 }
 
-type Statement interface{}
-
-type Expression interface{}
+func (p *Parser) parseDeclaration() Statement {
+    nonlocal := p.curTok.Type == token.NONLOCAL
+    names := []string{}
+    for {
+        if !p.expectPeek(token.IDENT) {
+            return nil
+        }
+        names = append(names, p.curTok.Literal)
+        if !p.peekTokenIs(token.COMMA) {
+            break
+        }
+        p.nextToken()
+    }
+    if nonlocal {
+        return &NonlocalStatement{Names: names}
+    }
+    return &GlobalStatement{Names: names}
+}
 
-type FunctionDefinition struct {
-    Name       string
-    Parameters []string
-    Body       []Statement
+// parseDeleteStatement handles `del a, b.c, d[e]`
+func (p *Parser) parseDeleteStatement() Statement {
+    p.nextToken() // Skip 'del'
+    target := p.parseExpressionList()
+    if target == nil {
+        return nil
+    }
+    targets := []Expression{target}
+    if tuple, ok := target.(*TupleLiteral); ok {
+        targets = tuple.Elements // del (a, b) deletes both, just like del a, b
+    }
+    for _, target := range targets {
+        if !p.checkTargetFor(target, "delete") {
+            return nil
+        }
+    }
+    return &DeleteStatement{Targets: targets}
 }
 
-type IfStatement struct {
-    Condition   Expression
-    Consequence []Statement
-    Alternative []Statement
+// parseImportStatement handles `import a.b as c, d`, with curTok on 'import'
+func (p *Parser) parseImportStatement() Statement {
+    stmt := &ImportStatement{}
+    for {
+        p.nextToken() // Skip 'import' or ','
+        name := p.parseDottedName()
+        if name == "" {
+            return nil
+        }
+        alias := &ImportName{Name: name}
+        if p.peekTokenIs(token.AS) {
+            p.nextToken()
+            if !p.expectPeek(token.IDENT) {
+                return nil
+            }
+            alias.Alias = p.curTok.Literal
+        }
+        stmt.Names = append(stmt.Names, alias)
+        if !p.peekTokenIs(token.COMMA) {
+            return stmt
+        }
+        p.nextToken()
+    }
 }
 
-type ReturnStatement struct {
+// parseFromImportStatement handles `from ..a.b import c as d, e`, the
+// parenthesized list and `import *`, with curTok on 'from'
+func (p *Parser) parseFromImportStatement() Statement {
+    stmt := &FromImportStatement{}
+    p.nextToken() // Skip 'from'
+    for p.curTok.Type == token.DOT {
+        stmt.Level++
+        p.nextToken()
+    }
+    if p.curTok.Type == token.IMPORT {
+        if stmt.Level == 0 {
+            p.addError("invalid syntax: expected a module name after 'from'")
+            return nil
+        }
+    } else {
+        if stmt.Module = p.parseDottedName(); stmt.Module == "" {
+            return nil
+        }
+        if !p.expectPeek(token.IMPORT) {
+            return nil
+        }
+    }
+
+    if p.peekTokenIs(token.ASTERISK) {
+        p.nextToken()
+        if p.nested > 0 {
+            p.addError("import * only allowed at module level")
+        }
+        return stmt
+    }
+    parenthesized := p.peekTokenIs(token.LPAREN)
+    if parenthesized {
+        p.nextToken()
+    }
+    for {
+        if !p.expectPeek(token.IDENT) {
+            return nil
+        }
+        alias := &ImportName{Name: p.curTok.Literal}
+        if p.peekTokenIs(token.AS) {
+            p.nextToken()
+            if !p.expectPeek(token.IDENT) {
+                return nil
+            }
+            alias.Alias = p.curTok.Literal
+        }
+        stmt.Names = append(stmt.Names, alias)
+        if !p.peekTokenIs(token.COMMA) {
+            break
+        }
+        p.nextToken()
+        if parenthesized && p.peekTokenIs(token.RPAREN) {
+            break
+        }
+        if !parenthesized && p.peekEndsStatement() {
+            p.addError("trailing comma not allowed without surrounding parentheses")
+            return nil
+        }
+    }
+    if parenthesized && !p.expectPeek(token.RPAREN) {
+        return nil
+    }
+    return stmt
+}
+
+// parseDottedName reads a.b.c, leaving curTok on its last name
+func (p *Parser) parseDottedName() string {
+    if p.curTok.Type != token.IDENT {
+        p.addError(fmt.Sprintf("invalid syntax: expected a module name, got %s", p.curTok.Type))
+        return ""
+    }
+    name := p.curTok.Literal
+    for p.peekTokenIs(token.DOT) {
+        p.nextToken()
+        if !p.expectPeek(token.IDENT) {
+            return ""
+        }
+        name += "." + p.curTok.Literal
+    }
+    return name
+}
+
+// parseTryStatement - every deal Harvey closes has a plan B. And a plan C.
+func (p *Parser) parseTryStatement() *TryStatement {
+    if !p.expectPeek(token.COLON) {
+        return nil
+    }
+    p.nextToken() // Skip ':'
+    stmt := &TryStatement{Body: p.parseBlock()}
+
+    for p.peekTokenIs(token.EXCEPT) {
+        p.nextToken() // Move onto 'except'
+        star := p.peekTokenIs(token.ASTERISK)
+        if star {
+            p.nextToken()
+        }
+        if len(stmt.Handlers) > 0 {
+            if star != stmt.Star {
+                p.addError("cannot have both 'except' and 'except*' on the same 'try'")
+                return nil
+            }
+            if stmt.Handlers[len(stmt.Handlers)-1].Type == nil {
+                p.addError("default 'except:' must be last")
+                return nil
+            }
+        }
+        stmt.Star = star
+
+        handler := &ExceptHandler{}
+        if !p.peekTokenIs(token.COLON) {
+            p.nextToken()
+            handler.Type = p.parseExpressionList()
+            if p.peekTokenIs(token.AS) {
+                p.nextToken()
+                if !p.expectPeek(token.IDENT) {
+                    return nil
+                }
+                handler.Name = p.curTok.Literal
+            }
+        } else if star {
+            p.addError("expected one or more exception types")
+            return nil
+        }
+
+        if !p.expectPeek(token.COLON) {
+            return nil
+        }
+        p.nextToken() // Skip ':'
+        handler.Body = p.parseBlock()
+        if star {
+            p.checkExceptStarBody(handler.Body, false)
+        }
+        stmt.Handlers = append(stmt.Handlers, handler)
+    }
+
+    if p.peekTokenIs(token.ELSE) && len(stmt.Handlers) > 0 {
+        if stmt.Else = p.parseElseBlock(); stmt.Else == nil {
+            return nil
+        }
+    }
+    if p.peekTokenIs(token.FINALLY) {
+        p.nextToken() // Move onto 'finally'
+        if !p.expectPeek(token.COLON) {
+            return nil
+        }
+        p.nextToken() // Skip ':'
+        stmt.Finally = p.parseBlock()
+    }
+
+    if len(stmt.Handlers) == 0 && stmt.Finally == nil {
+        p.addError("expected 'except' or 'finally' block")
+        return nil
+    }
+    return stmt
+}
+
+// checkExceptStarBody rejects jumps out of an except* block, which Python
+// forbids because several handlers may run for the same exception group
+func (p *Parser) checkExceptStarBody(body []Statement, inLoop bool) {
+    for _, stmt := range body {
+        switch stmt := stmt.(type) {
+        case *ReturnStatement:
+            p.addError("'break', 'continue' and 'return' cannot appear in an except* block")
+        case *BreakStatement, *ContinueStatement:
+            if !inLoop {
+                p.addError("'break', 'continue' and 'return' cannot appear in an except* block")
+            }
+        case *IfStatement:
+            p.checkExceptStarBody(stmt.Consequence, inLoop)
+            p.checkExceptStarBody(stmt.Alternative, inLoop)
+        case *WhileStatement:
+            p.checkExceptStarBody(stmt.Body, true)
+            p.checkExceptStarBody(stmt.Else, inLoop)
+        case *ForStatement:
+            p.checkExceptStarBody(stmt.Body, true)
+            p.checkExceptStarBody(stmt.Else, inLoop)
+        case *TryStatement:
+            p.checkExceptStarBody(stmt.Body, inLoop)
+            for _, handler := range stmt.Handlers {
+                p.checkExceptStarBody(handler.Body, inLoop)
+            }
+            p.checkExceptStarBody(stmt.Else, inLoop)
+            p.checkExceptStarBody(stmt.Finally, inLoop)
+        }
+    }
+}
+
+// peekEndsStatement reports whether the current simple statement has no more tokens
+func (p *Parser) peekEndsStatement() bool {
+    return p.peekTok.LineStart || p.peekTokenIs(token.SEMICOLON) || p.peekTokenIs(token.EOF)
+}
+
+func (p *Parser) parseExpressionStatement() *ExpressionStatement {
+    expr := p.parseExpression(LOWEST)
+    return &ExpressionStatement{Expression: expr}
+}
+
+// parseExpressionList parses `a, b, c` into a tuple; a lone expression stays as is
+func (p *Parser) parseExpressionList() Expression {
+    start := p.curTok
+    first := p.parseExpression(LOWEST)
+    if !p.peekTokenIs(token.COMMA) {
+        return first
+    }
+
+    elements := []Expression{first}
+    for p.peekTokenIs(token.COMMA) {
+        p.nextToken()
+        if !p.peekStartsExpression() {
+            break
+        }
+        p.nextToken()
+        elements = append(elements, p.parseExpression(LOWEST))
+    }
+    tuple := &TupleLiteral{Elements: elements}
+    p.locate(tuple, start)
+    return tuple
+}
+
+// parseNamedExpression parses an expression where `name := value` may stand
+// as well: conditions, arguments, subscripts and anything in brackets
+func (p *Parser) parseNamedExpression() Expression {
+    if p.curTok.Type != token.IDENT || !p.peekTokenIs(token.WALRUS) {
+        expr := p.parseExpression(LOWEST)
+        if expr != nil && p.peekTokenIs(token.WALRUS) {
+            p.addError(fmt.Sprintf("cannot use assignment expressions with %s", describeExpression(expr)))
+            p.skipLine()
+            return nil
+        }
+        return expr
+    }
+
+    start := p.curTok
+    target := &Identifier{Value: p.curTok.Literal}
+    p.locate(target, start)
+    p.nextToken()
+    p.nextToken() // Skip ':='
+    value := p.parseExpression(LOWEST)
+    if value == nil {
+        return nil
+    }
+    named := &NamedExpression{Target: target, Value: value}
+    p.locate(named, start)
+    return named
+}
+
+// This is where the REAL magic happens - the Litt test of parsing
+func (p *Parser) parseExpression(precedence int) Expression {
+    var leftExp Expression
+    start := p.curTok
+
+    switch p.curTok.Type {
+    case token.IDENT:
+        leftExp = &Identifier{Value: p.curTok.Literal}
+    case token.INT:
+        leftExp = p.parseIntegerLiteral()
+    case token.FLOAT:
+        leftExp = p.parseFloatLiteral()
+    case token.IMAG:
+        leftExp = &ImaginaryLiteral{Value: p.curTok.Literal}
+    case token.STRING:
+        leftExp = p.parseStringLiteral()
+    case token.TRUE:
+        leftExp = &BooleanLiteral{Value: true}
+    case token.FALSE:
+        leftExp = &BooleanLiteral{Value: false}
+    case token.NONE:
+        leftExp = &NoneLiteral{}
+    case token.LPAREN:
+        leftExp = p.parseGroupedExpression()
+    case token.LBRACKET:
+        leftExp = p.parseListLiteral()
+    case token.LBRACE:
+        leftExp = p.parseDictOrSetLiteral()
+    case token.MINUS, token.PLUS, token.TILDE:
+        leftExp = p.parsePrefixExpression(PREFIX)
+    case token.NOT:
+        leftExp = p.parsePrefixExpression(NOT)
+    case token.LAMBDA:
+        leftExp = p.parseLambdaExpression()
+    case token.ILLEGAL:
+        p.addError(p.curTok.Literal)
+        return nil
+    default:
+        p.addError(fmt.Sprintf("unexpected token: %s", p.curTok.Type))
+        return nil
+    }
+
+    p.locate(leftExp, start)
+    return p.parseInfixExpressions(leftExp, precedence, start)
+}
+
+// parseInfixExpressions extends leftExp, which began at start, with every
+// operator that binds tighter than precedence
+func (p *Parser) parseInfixExpressions(leftExp Expression, precedence int, start token.Token) Expression {
+    for leftExp != nil && !p.peekTokenIs(token.SEMICOLON) && precedence < p.peekPrecedence() {
+        switch p.peekTok.Type {
+        case token.PLUS, token.MINUS, token.ASTERISK, token.SLASH, token.DOUBLE_SLASH, token.PERCENT,
+            token.AT, token.POWER, token.PIPE, token.CARET, token.AMPERSAND, token.LSHIFT, token.RSHIFT,
+            token.AND, token.OR:
+            p.nextToken()
+            leftExp = p.parseInfixExpression(leftExp)
+        case token.EQ, token.NOT_EQ, token.LT, token.GT, token.LTE, token.GTE, token.IN, token.NOT, token.IS:
+            leftExp = p.parseComparisonExpression(leftExp)
+        case token.LPAREN:
+            p.nextToken()
+            leftExp = p.parseCallExpression(leftExp)
+        case token.LBRACKET:
+            p.nextToken()
+            leftExp = p.parseIndexExpression(leftExp)
+        case token.DOT:
+            p.nextToken()
+            if !p.expectPeek(token.IDENT) {
+                return nil
+            }
+            leftExp = &AttributeExpression{Object: leftExp, Name: p.curTok.Literal}
+        case token.IF:
+            leftExp = p.parseConditionalExpression(leftExp)
+        default:
+            return leftExp
+        }
+        p.locate(leftExp, start)
+    }
+
+    return leftExp
+}
+
+// peekStartsExpression tells a trailing comma apart from one that continues a list
+func (p *Parser) peekStartsExpression() bool {
+    if p.peekTok.LineStart {
+        return false
+    }
+    switch p.peekTok.Type {
+    case token.IDENT, token.INT, token.FLOAT, token.IMAG, token.STRING, token.TRUE, token.FALSE, token.NONE,
+        token.LPAREN, token.LBRACKET, token.LBRACE, token.MINUS, token.PLUS, token.TILDE, token.NOT,
+        token.LAMBDA:
+        return true
+    }
+    return false
+}
+
+// parseConditionalExpression parses `consequence if condition else alternative`;
+// peekTok is the 'if'. The alternative may itself be a conditional or a lambda.
+func (p *Parser) parseConditionalExpression(consequence Expression) Expression {
+    p.nextToken()
+    p.nextToken() // Skip 'if'
+    condition := p.parseExpression(TERNARY)
+    if condition == nil {
+        return nil
+    }
+    if !p.peekTokenIs(token.ELSE) {
+        p.addError("expected 'else' after 'if' expression")
+        return nil
+    }
+    p.nextToken()
+    p.nextToken() // Skip 'else'
+    alternative := p.parseExpression(LOWEST)
+    if alternative == nil {
+        return nil
+    }
+    return &ConditionalExpression{Condition: condition, Consequence: consequence, Alternative: alternative}
+}
+
+// parseLambdaExpression parses `lambda parameters: body`; curTok is 'lambda'
+func (p *Parser) parseLambdaExpression() Expression {
+    p.nextToken() // Skip 'lambda'
+    signature, ok := p.parseParameters(token.COLON)
+    if !ok {
+        return nil
+    }
+    if p.curTok.Type != token.COLON {
+        p.addError(fmt.Sprintf("expected ':', got %s", p.curTok.Type))
+        return nil
+    }
+    p.nextToken() // Skip ':'
+
+    inFunction, sawYield := p.inFunction, p.sawYield
+    p.inFunction, p.sawYield = true, false
+    body := p.parseExpression(LOWEST)
+    generator := p.sawYield
+    p.inFunction, p.sawYield = inFunction, sawYield
+
+    if body == nil {
+        return nil
+    }
+    return &LambdaExpression{Signature: signature, Body: body, Generator: generator}
+}
+
+func (p *Parser) parsePrefixExpression(precedence int) Expression {
+    operator := p.curTok.Literal
+    p.nextToken()
+    right := p.parseExpression(precedence)
+    if right == nil {
+        return nil
+    }
+    return &PrefixExpression{Operator: operator, Right: right}
+}
+
+// parseGroupedExpression handles (expr), () and (a, b) tuples, as well as
+// (yield x) and generator expressions
+func (p *Parser) parseGroupedExpression() Expression {
+    if p.peekTokenIs(token.RPAREN) {
+        p.nextToken()
+        return &TupleLiteral{Elements: []Expression{}}
+    }
+
+    p.nextToken() // Skip '('
+    if p.curTok.Type == token.YIELD {
+        exp := p.parseYieldExpression()
+        if exp == nil || !p.expectPeek(token.RPAREN) {
+            return nil
+        }
+        return exp
+    }
+    exp := p.parseNamedExpression()
+
+    if p.peekTokenIs(token.FOR) {
+        exp = p.parseGeneratorExpression(exp)
+    } else if p.peekTokenIs(token.COMMA) {
+        elements := []Expression{exp}
+        for p.peekTokenIs(token.COMMA) {
+            p.nextToken()
+            if p.peekTokenIs(token.RPAREN) {
+                break
+            }
+            p.nextToken()
+            elements = append(elements, p.parseNamedExpression())
+        }
+        exp = &TupleLiteral{Elements: elements}
+    }
+
+    if !p.expectPeek(token.RPAREN) {
+        return nil
+    }
+    return exp
+}
+
+// parseGeneratorExpression parses the `for ... in ... if ...` clauses that
+// follow the element of a generator expression; peekTok is the first 'for'
+func (p *Parser) parseGeneratorExpression(element Expression) Expression {
+    if element == nil {
+        return nil
+    }
+    generator := &GeneratorExpression{Element: element}
+    for p.peekTokenIs(token.FOR) {
+        p.nextToken()
+        p.nextToken() // Skip 'for'
+        clause := &ComprehensionClause{Target: p.parseTargetList()}
+        if clause.Target == nil || !p.checkTarget(clause.Target) || !p.expectPeek(token.IN) {
+            return nil
+        }
+        p.nextToken() // Skip 'in'
+        if clause.Iterable = p.parseExpression(TERNARY); clause.Iterable == nil {
+            return nil
+        }
+        for p.peekTokenIs(token.IF) {
+            p.nextToken()
+            p.nextToken() // Skip 'if'
+            condition := p.parseExpression(TERNARY)
+            if condition == nil {
+                return nil
+            }
+            clause.Conditions = append(clause.Conditions, condition)
+        }
+        generator.Clauses = append(generator.Clauses, clause)
+    }
+    return generator
+}
+
+func (p *Parser) parseListLiteral() Expression {
+    return &ListLiteral{Elements: p.parseExpressionsUntil(token.RBRACKET)}
+}
+
+// parseExpressionsUntil parses comma separated expressions up to the closing
+// token, allowing a trailing comma. curTok ends on the closing token.
+func (p *Parser) parseExpressionsUntil(end token.TokenType) []Expression {
+    elements := []Expression{}
+
+    for !p.peekTokenIs(end) {
+        p.nextToken()
+        elements = append(elements, p.parseNamedExpression())
+        if !p.peekTokenIs(token.COMMA) {
+            break
+        }
+        p.nextToken()
+    }
+
+    if !p.expectPeek(end) {
+        return nil
+    }
+    return elements
+}
+
+func (p *Parser) parseDictOrSetLiteral() Expression {
+    if p.peekTokenIs(token.RBRACE) {
+        p.nextToken()
+        return &DictLiteral{}
+    }
+
+    p.nextToken() // Skip '{'
+    first := p.parseNamedExpression()
+    if !p.peekTokenIs(token.COLON) {
+        elements := []Expression{first}
+        if p.peekTokenIs(token.COMMA) {
+            p.nextToken()
+            elements = append(elements, p.parseExpressionsUntil(token.RBRACE)...)
+        } else if !p.expectPeek(token.RBRACE) {
+            return nil
+        }
+        return &SetLiteral{Elements: elements}
+    }
+
+    dict := &DictLiteral{}
+    key := first
+    for {
+        if !p.expectPeek(token.COLON) {
+            return nil
+        }
+        p.nextToken() // Skip ':'
+        dict.Keys = append(dict.Keys, key)
+        dict.Values = append(dict.Values, p.parseExpression(LOWEST))
+
+        if !p.peekTokenIs(token.COMMA) {
+            break
+        }
+        p.nextToken()
+        if p.peekTokenIs(token.RBRACE) {
+            break
+        }
+        p.nextToken()
+        key = p.parseExpression(LOWEST)
+    }
+
+    if !p.expectPeek(token.RBRACE) {
+        return nil
+    }
+    return dict
+}
+
+func (p *Parser) parseInfixExpression(left Expression) Expression {
+    operator := p.curTok.Literal
+    precedence := p.curPrecedence()
+    if operator == "**" {
+        precedence-- // right associative
+    }
+    p.nextToken()
+    right := p.parseExpression(precedence)
+    if right == nil {
+        return nil
+    }
+
+    return &InfixExpression{
+        Left:     left,
+        Operator: operator,
+        Right:    right,
+    }
+}
+
+// parseComparisonExpression collects a whole chain like a < b <= c so each
+// operand is evaluated only once
+func (p *Parser) parseComparisonExpression(left Expression) Expression {
+    comparison := &ComparisonExpression{Left: left}
+
+    for precedences[p.peekTok.Type] == COMPARISON {
+        p.nextToken()
+        operator := p.curTok.Literal
+        switch p.curTok.Type {
+        case token.NOT:
+            if !p.expectPeek(token.IN) {
+                return nil
+            }
+            operator = "not in"
+        case token.IS:
+            if p.peekTokenIs(token.NOT) {
+                p.nextToken()
+                operator = "is not"
+            }
+        }
+
+        p.nextToken()
+        right := p.parseExpression(COMPARISON)
+        if right == nil {
+            return nil
+        }
+        comparison.Operators = append(comparison.Operators, operator)
+        comparison.Comparators = append(comparison.Comparators, right)
+    }
+
+    return comparison
+}
+
+func (p *Parser) parseCallExpression(function Expression) Expression {
+    args, keywords := p.parseCallArguments()
+    if args == nil {
+        return nil
+    }
+    return &CallExpression{Function: function, Arguments: args, Keywords: keywords}
+}
+
+// parseCallArguments parses `(a, *rest, key=value, **more)` with curTok on
+// '(' and leaves curTok on ')'
+func (p *Parser) parseCallArguments() ([]Expression, []*KeywordArgument) {
+    args := []Expression{}
+    var keywords []*KeywordArgument
+    unpacked := false // seen a **mapping
+
+    for !p.peekTokenIs(token.RPAREN) {
+        p.nextToken()
+        if p.curTok.Type == token.IDENT && p.peekTokenIs(token.ASSIGN) {
+            name := p.curTok.Literal
+            p.nextToken()
+            p.nextToken()
+            keywords = append(keywords, &KeywordArgument{Name: name, Value: p.parseExpression(LOWEST)})
+        } else if p.curTok.Type == token.POWER {
+            p.nextToken() // Skip '**'
+            keywords = append(keywords, &KeywordArgument{Value: p.parseExpression(LOWEST)})
+            unpacked = true
+        } else if p.curTok.Type == token.ASTERISK {
+            if unpacked {
+                p.addError("iterable argument unpacking follows keyword argument unpacking")
+            }
+            start := p.curTok
+            p.nextToken() // Skip '*'
+            starred := &StarredExpression{Value: p.parseExpression(LOWEST)}
+            p.locate(starred, start)
+            args = append(args, starred)
+        } else {
+            if unpacked {
+                p.addError("positional argument follows keyword argument unpacking")
+            } else if len(keywords) > 0 {
+                p.addError("positional argument follows keyword argument")
+            }
+            start := p.curTok
+            arg := p.parseNamedExpression()
+            if p.peekTokenIs(token.FOR) {
+                arg = p.parseGeneratorExpression(arg)
+                p.locate(arg, start)
+                if len(args) > 0 || len(keywords) > 0 || p.peekTokenIs(token.COMMA) {
+                    p.addError("Generator expression must be parenthesized")
+                }
+            }
+            args = append(args, arg)
+        }
+
+        if !p.peekTokenIs(token.COMMA) {
+            break
+        }
+        p.nextToken()
+    }
+
+    if !p.expectPeek(token.RPAREN) {
+        return nil, nil
+    }
+    return args, keywords
+}
+
+// parseIndexExpression handles subscripts, including slices and tuples of them
+func (p *Parser) parseIndexExpression(left Expression) Expression {
+    p.nextToken() // Skip '['
+
+    index := p.parseSubscript()
+    if p.peekTokenIs(token.COMMA) {
+        elements := []Expression{index}
+        for p.peekTokenIs(token.COMMA) {
+            p.nextToken()
+            if p.peekTokenIs(token.RBRACKET) {
+                break
+            }
+            p.nextToken()
+            elements = append(elements, p.parseSubscript())
+        }
+        index = &TupleLiteral{Elements: elements}
+    }
+
+    if !p.expectPeek(token.RBRACKET) {
+        return nil
+    }
+    return &IndexExpression{Left: left, Index: index}
+}
+
+// parseSubscript parses either an expression or a lower:upper:step slice
+func (p *Parser) parseSubscript() Expression {
+    start := p.curTok
+    var lower Expression
+    if p.curTok.Type != token.COLON {
+        lower = p.parseNamedExpression()
+        if !p.peekTokenIs(token.COLON) {
+            return lower
+        }
+        p.nextToken()
+    }
+
+    slice := &SliceExpression{Lower: lower}
+    if p.peekStartsExpression() {
+        p.nextToken()
+        slice.Upper = p.parseExpression(LOWEST)
+    }
+    if p.peekTokenIs(token.COLON) {
+        p.nextToken()
+        if p.peekStartsExpression() {
+            p.nextToken()
+            slice.Step = p.parseExpression(LOWEST)
+        }
+    }
+    p.locate(slice, start)
+    return slice
+}
+
+func (p *Parser) parseIntegerLiteral() Expression {
+    value := p.curTok.Literal
+    if len(value) > 1 && value[0] == '0' && isDecimal(value) && !allZeros(value) {
+        p.addError("leading zeros in decimal integer literals are not permitted")
+    }
+    return &IntegerLiteral{Value: value}
+}
+
+func (p *Parser) parseFloatLiteral() Expression {
+    value := p.curTok.Literal
+    return &FloatLiteral{Value: value}
+}
+
+// parseStringLiteral decodes the literal and joins adjacent ones: "a" "b" == "ab".
+// Bytes join bytes only.
+func (p *Parser) parseStringLiteral() Expression {
+    value, isBytes, err := unquote(p.curTok.Literal)
+    if err != nil {
+        p.addError(err.Error())
+        return nil
+    }
+    for p.peekTokenIs(token.STRING) && !p.peekTok.LineStart {
+        p.nextToken()
+        next, nextIsBytes, err := unquote(p.curTok.Literal)
+        if err != nil {
+            p.addError(err.Error())
+            return nil
+        }
+        if nextIsBytes != isBytes {
+            p.addError("cannot mix bytes and nonbytes literals")
+            return nil
+        }
+        value += next
+    }
+    if isBytes {
+        return &BytesLiteral{Value: value}
+    }
+    return &StringLiteral{Value: value}
+}
+
+// parseAssignmentStatement handles `target = value`, including chains like
+// a = b = 5 and unpacking like a, b = b, a. curTok is the end of the first target.
+func (p *Parser) parseAssignmentStatement(first Expression) *AssignmentStatement {
+    targets := []Expression{first}
+    var value Expression
+
+    for p.peekTokenIs(token.ASSIGN) {
+        if !p.checkTarget(targets[len(targets)-1]) {
+            return nil
+        }
+        p.nextToken() // Move onto '='
+        p.nextToken() // Skip '='
+        if p.curTok.Type == token.YIELD {
+            value = p.parseYieldExpression()
+        } else {
+            value = p.parseExpressionList()
+        }
+        if value == nil {
+            return nil
+        }
+        if p.peekTokenIs(token.ASSIGN) {
+            targets = append(targets, value)
+        }
+    }
+
+    stmt := &AssignmentStatement{Targets: targets, Value: value}
+    if ident, ok := first.(*Identifier); ok {
+        stmt.Name = ident
+    }
+    return stmt
+}
+
+// augmentedOperators maps each op= token to the binary operator it applies
+var augmentedOperators = map[token.TokenType]string{
+    token.PLUS_ASSIGN:         "+",
+    token.MINUS_ASSIGN:        "-",
+    token.ASTERISK_ASSIGN:     "*",
+    token.SLASH_ASSIGN:        "/",
+    token.DOUBLE_SLASH_ASSIGN: "//",
+    token.PERCENT_ASSIGN:      "%",
+    token.POWER_ASSIGN:        "**",
+    token.AT_ASSIGN:           "@",
+    token.AMPERSAND_ASSIGN:    "&",
+    token.PIPE_ASSIGN:         "|",
+    token.CARET_ASSIGN:        "^",
+    token.LSHIFT_ASSIGN:       "<<",
+    token.RSHIFT_ASSIGN:       ">>",
+}
+
+// parseAugmentedAssignment handles `target op= value`; peekTok is the op=.
+// Only a single name, attribute or subscript can be the target.
+func (p *Parser) parseAugmentedAssignment(target Expression) *AugmentedAssignStatement {
+    switch target.(type) {
+    case *Identifier, *AttributeExpression, *IndexExpression:
+    default:
+        p.addError(fmt.Sprintf("'%s' is an illegal expression for augmented assignment", describeExpression(target)))
+        p.skipLine()
+        return nil
+    }
+    p.nextToken() // Move onto the op=
+    stmt := &AugmentedAssignStatement{Target: target, Operator: augmentedOperators[p.curTok.Type]}
+    p.nextToken() // Skip it
+    if p.curTok.Type == token.YIELD {
+        stmt.Value = p.parseYieldExpression()
+    } else {
+        stmt.Value = p.parseExpressionList()
+    }
+    if stmt.Value == nil {
+        return nil
+    }
+    return stmt
+}
+
+// checkTarget reports whether an expression may appear on the left of '='
+func (p *Parser) checkTarget(target Expression) bool {
+    return p.checkTargetFor(target, "assign to")
+}
+
+// checkTargetFor is checkTarget for any statement that takes targets, like del
+func (p *Parser) checkTargetFor(target Expression, action string) bool {
+    var elements []Expression
+    switch target := target.(type) {
+    case *Identifier, *AttributeExpression, *IndexExpression:
+        return true
+    case *TupleLiteral:
+        elements = target.Elements
+    case *ListLiteral:
+        elements = target.Elements
+    default:
+        p.addError(fmt.Sprintf("cannot %s %s", action, describeExpression(target)))
+        return false
+    }
+    for _, element := range elements {
+        if !p.checkTargetFor(element, action) {
+            return false
+        }
+    }
+    return true
+}
+
+// locate records the span from start to the current token on a freshly
+// parsed node. Nodes that already know their place keep it, so (a + b)
+// stays without its parentheses, like CPython's AST.
+func (p *Parser) locate(node interface{}, start token.Token) {
+    located, ok := node.(Located)
+    if !ok || reflect.ValueOf(node).IsNil() || located.Span().Line != 0 {
+        return
+    }
+    *located.Span() = Position{
+        Line:      start.Line,
+        Column:    start.Column,
+        EndLine:   p.curTok.EndLine,
+        EndColumn: p.curTok.EndColumn,
+    }
+}
+
+func (p *Parser) peekPrecedence() int {
+    if p.peekTok.LineStart {
+        return LOWEST // a new logical line always ends the expression
+    }
+    if prec, ok := precedences[p.peekTok.Type]; ok {
+        return prec
+    }
+    return LOWEST
+}
+
+func (p *Parser) curPrecedence() int {
+    if prec, ok := precedences[p.curTok.Type]; ok {
+        return prec
+    }
+    return LOWEST
+}
+
+func (p *Parser) peekTokenIs(t token.TokenType) bool {
+    return p.peekTok.Type == t
+}
+
+func (p *Parser) expectPeek(t token.TokenType) bool {
+    if p.peekTokenIs(t) {
+        p.nextToken()
+        return true
+    }
+    p.addPeekError(fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekTok.Type))
+    return false
+}
+
+func (p *Parser) addError(msg string) {
+    p.addErrorAt(p.curTok, msg)
+}
+
+// addErrorAt is addError blaming a token other than the current one
+func (p *Parser) addErrorAt(tok token.Token, msg string) {
+    p.addErrorSpan(Position{Line: tok.Line, Column: tok.Column, EndLine: tok.EndLine, EndColumn: tok.EndColumn}, msg)
+}
+
+// addPeekError blames the next token, or the end of this line when the
+// next token is already on another one, the way "expected ':'" does
+func (p *Parser) addPeekError(msg string) {
+    if p.peekTok.LineStart || p.peekTok.Type == token.EOF {
+        end := p.curTok.EndColumn
+        p.addErrorSpan(Position{Line: p.curTok.EndLine, Column: end, EndLine: p.curTok.EndLine, EndColumn: end + 1}, msg)
+        return
+    }
+    p.addErrorAt(p.peekTok, msg)
+}
+
+func (p *Parser) addErrorSpan(at Position, msg string) {
+    p.errors = append(p.errors, msg)
+    p.errorsAt = append(p.errorsAt, at)
+}
+
+func (p *Parser) Errors() []string {
+    return p.errors
+}
+
+// ErrorPositions says where in the source each of Errors() points
+func (p *Parser) ErrorPositions() []Position {
+    return p.errorsAt
+}
+
+func isDecimal(s string) bool {
+    for i := 0; i < len(s); i++ {
+        if (s[i] < '0' || s[i] > '9') && s[i] != '_' {
+            return false
+        }
+    }
+    return true
+}
+
+func allZeros(s string) bool {
+    for i := 0; i < len(s); i++ {
+        if s[i] != '0' && s[i] != '_' {
+            return false
+        }
+    }
+    return true
+}
+
+func describeExpression(expr Expression) string {
+    switch expr.(type) {
+    case *CallExpression:
+        return "function call"
+    case *IntegerLiteral, *FloatLiteral, *ImaginaryLiteral, *StringLiteral, *BytesLiteral, *BooleanLiteral, *NoneLiteral:
+        return "literal"
+    case *InfixExpression, *PrefixExpression:
+        return "expression"
+    case *ComparisonExpression:
+        return "comparison"
+    case *YieldExpression:
+        return "yield expression"
+    case *GeneratorExpression:
+        return "generator expression"
+    case *ConditionalExpression:
+        return "conditional expression"
+    case *LambdaExpression:
+        return "lambda"
+    case *DictLiteral:
+        return "dict literal"
+    case *SetLiteral:
+        return "set display"
+    case *TupleLiteral:
+        return "tuple"
+    case *ListLiteral:
+        return "list"
+    case *AttributeExpression:
+        return "attribute"
+    case *IndexExpression:
+        return "subscript"
+    case *NamedExpression:
+        return "named expression"
+    }
+    return "expression"
+}
+
+// AST Node types
+// Position is where a node sits in the source: 1-based lines, 0-based byte
+// columns, with an exclusive end
+type Position struct {
+    Line, Column       int
+    EndLine, EndColumn int
+}
+
+func (p *Position) Span() *Position { return p }
+
+// Located is implemented by every node that embeds a Position
+type Located interface {
+    Span() *Position
+}
+
+type Program struct {
+    Statements []Statement
+}
+
+type Statement interface{}
+
+type Expression interface{}
+
+type FunctionDefinition struct {
+    Position
+    Name string
+    Signature
+    Body       []Statement
+    Generator  bool         // the body yields
+    Decorators []Expression // outermost first
+    Scope      *Scope
+}
+
+// Signature is the parameter list of a def or lambda, laid out like Python's ast.arguments
+type Signature struct {
+    Parameters     []string     // positional parameters, the positional-only ones first
+    PositionalOnly int          // how many Parameters come before the /
+    Defaults       []Expression // defaults for the last len(Defaults) Parameters
+    VarArgs        string       // the *args name, if any
+    KeywordOnly    []string
+    KwDefaults     []Expression // one per KeywordOnly, nil where there is no default
+    KwArgs         string       // the **kwargs name, if any
+}
+
+type ClassDefinition struct {
+    Position
+    Name       string
+    Bases      []Expression
+    Keywords   []*KeywordArgument
+    Body       []Statement
+    Decorators []Expression // outermost first
+    Scope      *Scope
+}
+
+type IfStatement struct {
+    Position
+    Condition   Expression
+    Consequence []Statement
+    Alternative []Statement
+}
+
+type WhileStatement struct {
+    Position
+    Condition Expression
+    Body      []Statement
+    Else      []Statement
+}
+
+type ForStatement struct {
+    Position
+    Target   Expression
+    Iterable Expression
+    Body     []Statement
+    Else     []Statement
+}
+
+// WithStatement is `with a as x, b as y:`; each item's manager wraps the ones after it
+type WithStatement struct {
+    Position
+    Items []*WithItem
+    Body  []Statement
+}
+
+// WithItem is one `context [as target]` of a with statement
+type WithItem struct {
+    Context Expression
+    Target  Expression // nil without `as`
+}
+
+// MatchStatement is `match subject:` and its case blocks, tried in order
+type MatchStatement struct {
+    Position
+    Subject Expression
+    Cases   []*MatchCase
+}
+
+// MatchCase is `case pattern if guard:`
+type MatchCase struct {
+    Pattern Pattern
+    Guard   Expression // nil without `if`
+    Body    []Statement
+}
+
+// Pattern is one of the Match* nodes below, laid out like Python's ast.pattern
+type Pattern interface{}
+
+// MatchValue matches what compares equal to a literal or a dotted name like Color.RED
+type MatchValue struct {
+    Position
+    Value Expression
+}
+
+// MatchSingleton is None, True or False, which match by identity
+type MatchSingleton struct {
+    Position
+    Value Expression
+}
+
+// MatchSequence is [a, *rest] or (a, b), or a bare a, b after `case`
+type MatchSequence struct {
+    Position
+    Patterns []Pattern
+}
+
+// MatchStar is the *name in a sequence pattern; Name is empty for *_
+type MatchStar struct {
+    Position
+    Name string
+}
+
+// MatchMapping is {key: pattern, **rest}
+type MatchMapping struct {
+    Position
+    Keys     []Expression
+    Patterns []Pattern
+    Rest     string // empty without **rest
+}
+
+// MatchClass is Cls(a, b, x=c); the positional patterns go by __match_args__
+type MatchClass struct {
+    Position
+    Class       Expression
+    Patterns    []Pattern
+    KwdAttrs    []string
+    KwdPatterns []Pattern
+}
+
+// MatchAs is `pattern as name`. Without a Pattern it is a capture, and the
+// wildcard _ has no Name either.
+type MatchAs struct {
+    Position
+    Pattern Pattern
+    Name    string
+}
+
+// MatchOr is a | b | c
+type MatchOr struct {
+    Position
+    Patterns []Pattern
+}
+
+type ReturnStatement struct {
+    Position
     Value Expression
 }
 
+// AssertStatement is `assert test, message`; Message is nil without one
+type AssertStatement struct {
+    Position
+    Test    Expression
+    Message Expression
+}
+
+// GlobalStatement is `global a, b`
+type GlobalStatement struct {
+    Position
+    Names []string
+}
+
+// NonlocalStatement is `nonlocal a, b`
+type NonlocalStatement struct {
+    Position
+    Names []string
+}
+
+// DeleteStatement is `del a, b.c, d[e]`
+type DeleteStatement struct {
+    Position
+    Targets []Expression
+}
+
+// ImportStatement is `import a.b as c, d`
+type ImportStatement struct {
+    Position
+    Names []*ImportName
+}
+
+// FromImportStatement is `from ..a import b as c`. Names is nil for `import *`.
+type FromImportStatement struct {
+    Position
+    Module string // dotted, and empty for `from . import x`
+    Level  int    // how many dots lead the module name
+    Names  []*ImportName
+}
+
+// ImportName is one name being imported and what it gets bound to
+type ImportName struct {
+    Name  string
+    Alias string // empty without `as`
+}
+
+type RaiseStatement struct {
+    Position
+    Exception Expression // nil for a bare `raise`
+    Cause     Expression
+}
+
+type TryStatement struct {
+    Position
+    Body     []Statement
+    Handlers []*ExceptHandler
+    Else     []Statement
+    Finally  []Statement // nil when there is no finally clause
+    Star     bool        // except* handlers, for exception groups
+}
+
+type ExceptHandler struct {
+    Type Expression // nil for a bare `except:`
+    Name string
+    Body []Statement
+}
+
+type PassStatement struct {
+    Position
+}
+
+type BreakStatement struct {
+    Position
+}
+
+type ContinueStatement struct {
+    Position
+}
+
 type ExpressionStatement struct {
+    Position
     Expression Expression
 }
 
 type Identifier struct {
+    Position
     Value string
 }
 
 type IntegerLiteral struct {
+    Position
     Value string
 }
 
 type FloatLiteral struct {
+    Position
+    Value string
+}
+
+// ImaginaryLiteral is 2j: the literal, j and all
+type ImaginaryLiteral struct {
+    Position
     Value string
 }
 
 type StringLiteral struct {
+    Position
     Value string
 }
 
+// BytesLiteral holds its bytes in a Go string, one byte each
+type BytesLiteral struct {
+    Position
+    Value string
+}
+
+type BooleanLiteral struct {
+    Position
+    Value bool
+}
+
+type NoneLiteral struct {
+    Position
+}
+
+type PrefixExpression struct {
+    Position
+    Operator string
+    Right    Expression
+}
+
 type InfixExpression struct {
+    Position
     Left     Expression
     Operator string
     Right    Expression
 }
 
+// ComparisonExpression is a chain: Left Operators[0] Comparators[0] Operators[1] ...
+type ComparisonExpression struct {
+    Position
+    Left        Expression
+    Operators   []string
+    Comparators []Expression
+}
+
 type CallExpression struct {
+    Position
     Function  Expression
     Arguments []Expression
+    Keywords  []*KeywordArgument
+}
+
+// KeywordArgument is name=value in a call, or **value when Name is empty
+type KeywordArgument struct {
+    Name  string
+    Value Expression
+}
+
+// StarredExpression is *value, unpacked into the surrounding call
+type StarredExpression struct {
+    Position
+    Value Expression
+}
+
+// ConditionalExpression is `consequence if condition else alternative`
+type ConditionalExpression struct {
+    Position
+    Condition   Expression
+    Consequence Expression
+    Alternative Expression
+}
+
+// LambdaExpression is an anonymous function whose body is a single expression
+type LambdaExpression struct {
+    Position
+    Signature
+    Body      Expression
+    Generator bool // the body yields
+    Scope     *Scope
+}
+
+type AttributeExpression struct {
+    Position
+    Object Expression
+    Name   string
+}
+
+type IndexExpression struct {
+    Position
+    Left  Expression
+    Index Expression
+}
+
+// SliceExpression is lower:upper:step inside a subscript; any part may be nil
+type SliceExpression struct {
+    Position
+    Lower Expression
+    Upper Expression
+    Step  Expression
+}
+
+// YieldExpression is `yield value`, or `yield from value` when From is set.
+// Value is nil for a bare yield.
+type YieldExpression struct {
+    Position
+    Value Expression
+    From  bool
+}
+
+// GeneratorExpression is (element for target in iterable if condition ...)
+type GeneratorExpression struct {
+    Position
+    Element Expression
+    Clauses []*ComprehensionClause
+    Scope   *Scope
+}
+
+// ComprehensionClause is one `for target in iterable` and the ifs after it
+type ComprehensionClause struct {
+    Target     Expression
+    Iterable   Expression
+    Conditions []Expression
 }
 
 type ListLiteral struct {
+    Position
+    Elements []Expression
+}
+
+type TupleLiteral struct {
+    Position
+    Elements []Expression
+}
+
+type DictLiteral struct {
+    Position
+    Keys   []Expression
+    Values []Expression
+}
+
+type SetLiteral struct {
+    Position
     Elements []Expression
 }
 
+// AugmentedAssignStatement is `target op= value`, with Operator the binary
+// operator, like "+" for +=
+type AugmentedAssignStatement struct {
+    Position
+    Target   Expression
+    Operator string
+    Value    Expression
+}
+
+// NamedExpression is `target := value`, which evaluates to the value
+type NamedExpression struct {
+    Position
+    Target *Identifier
+    Value  Expression
+}
+
+// AssignmentStatement assigns Value to every target in Targets. Name is set
+// when the first target is a plain identifier.
 type AssignmentStatement struct {
-    Name  *Identifier
-    Value Expression
-}
\ No newline at end of file
+    Position
+    Name    *Identifier
+    Targets []Expression
+    Value   Expression
+}
@@ -271,6 +271,68 @@ func TestParseAssignmentStatement(t *testing.T) {
     }
 }
 
+func TestParseTryStatement(t *testing.T) {
+    input := `
+try:
+    x = 1
+except (KeyError, IndexError) as e:
+    raise ValueError() from e
+except:
+    raise
+else:
+    pass
+finally:
+    x = 2
+`
+    p := New(lexer.New(input))
+    program := p.ParseProgram()
+    checkParserErrors(t, p)
+
+    stmt, ok := program.Statements[0].(*TryStatement)
+    if !ok {
+        t.Fatalf("program.Statements[0] is not TryStatement. got=%T", program.Statements[0])
+    }
+    if len(stmt.Handlers) != 2 || stmt.Star {
+        t.Fatalf("wrong handlers. got=%d star=%v", len(stmt.Handlers), stmt.Star)
+    }
+    if stmt.Handlers[0].Name != "e" || stmt.Handlers[1].Type != nil {
+        t.Errorf("wrong handler clauses. got name=%q type=%v", stmt.Handlers[0].Name, stmt.Handlers[1].Type)
+    }
+    raise, ok := stmt.Handlers[0].Body[0].(*RaiseStatement)
+    if !ok || raise.Cause == nil {
+        t.Errorf("handler body is not raise ... from. got=%T", stmt.Handlers[0].Body[0])
+    }
+    if bare := stmt.Handlers[1].Body[0].(*RaiseStatement); bare.Exception != nil {
+        t.Errorf("bare raise has an exception: %v", bare.Exception)
+    }
+    if len(stmt.Else) != 1 || len(stmt.Finally) != 1 {
+        t.Errorf("wrong else/finally. got=%d, %d", len(stmt.Else), len(stmt.Finally))
+    }
+}
+
+func TestTryStatementErrors(t *testing.T) {
+    tests := []struct {
+        input    string
+        expected string
+    }{
+        {"try:\n    pass\nx = 1", "expected 'except' or 'finally' block"},
+        {"try:\n    pass\nexcept:\n    pass\nexcept ValueError:\n    pass", "default 'except:' must be last"},
+        {"try:\n    pass\nexcept* ValueError:\n    pass\nexcept TypeError:\n    pass",
+            "cannot have both 'except' and 'except*' on the same 'try'"},
+        {"try:\n    pass\nexcept*:\n    pass", "expected one or more exception types"},
+        {"def f():\n    try:\n        pass\n    except* ValueError:\n        return 1",
+            "'break', 'continue' and 'return' cannot appear in an except* block"},
+    }
+    for _, tt := range tests {
+        p := New(lexer.New(tt.input))
+        p.ParseProgram()
+        errors := p.Errors()
+        if len(errors) == 0 || errors[0] != tt.expected {
+            t.Errorf("parse(%q) wrong errors. expected=%q, got=%q", tt.input, tt.expected, errors)
+        }
+    }
+}
+
 func checkParserErrors(t *testing.T, p *Parser) {
     errors := p.Errors()
     if len(errors) == 0 {
@@ -282,4 +344,490 @@ func checkParserErrors(t *testing.T, p *Parser) {
         t.Errorf("parser error: %s", msg)
     }
     t.FailNow()
-}
\ No newline at end of file
+}
+func TestNodePositions(t *testing.T) {
+    input := "total = (a + b) * c[1]\nif total:\n    pass"
+    p := New(lexer.New(input))
+    program := p.ParseProgram()
+    checkParserErrors(t, p)
+
+    assign := program.Statements[0].(*AssignmentStatement)
+    product := assign.Value.(*InfixExpression)
+    sum := product.Left.(*InfixExpression)
+    index := product.Right.(*IndexExpression)
+    ifStmt := program.Statements[1].(*IfStatement)
+
+    tests := []struct {
+        name     string
+        got      Position
+        expected Position
+    }{
+        {"assignment", assign.Position, Position{1, 0, 1, 22}},
+        {"product", product.Position, Position{1, 8, 1, 22}},
+        {"parenthesized sum", sum.Position, Position{1, 9, 1, 14}},
+        {"subscript", index.Position, Position{1, 18, 1, 22}},
+        {"if statement", ifStmt.Position, Position{2, 0, 3, 8}},
+    }
+    for _, tt := range tests {
+        if tt.got != tt.expected {
+            t.Errorf("%s span wrong. expected=%v, got=%v", tt.name, tt.expected, tt.got)
+        }
+    }
+}
+
+func TestParseGenerators(t *testing.T) {
+    input := `
+def gen():
+    x = yield
+    yield from (i * 2 for i in x if i)
+def plain():
+    return sum(a for a in b)
+`
+    p := New(lexer.New(input))
+    program := p.ParseProgram()
+    checkParserErrors(t, p)
+
+    gen := program.Statements[0].(*FunctionDefinition)
+    plain := program.Statements[1].(*FunctionDefinition)
+    if !gen.Generator || plain.Generator {
+        t.Fatalf("wrong generator flags. gen=%v plain=%v", gen.Generator, plain.Generator)
+    }
+    delegate, ok := gen.Body[1].(*ExpressionStatement).Expression.(*YieldExpression)
+    if !ok || !delegate.From {
+        t.Fatalf("second statement is not yield from. got=%T", gen.Body[1].(*ExpressionStatement).Expression)
+    }
+    genexp, ok := delegate.Value.(*GeneratorExpression)
+    if !ok || len(genexp.Clauses) != 1 || len(genexp.Clauses[0].Conditions) != 1 {
+        t.Fatalf("yield from value is not a generator expression with one if. got=%T", delegate.Value)
+    }
+
+    for input, expected := range map[string]string{
+        "yield 1":                 "'yield' outside function",
+        "class A:\n    yield 1":   "'yield' outside function",
+        "f(x for x in y, 1)":      "Generator expression must be parenthesized",
+        "f(1, x for x in y)":      "Generator expression must be parenthesized",
+    } {
+        p := New(lexer.New(input))
+        p.ParseProgram()
+        if len(p.Errors()) == 0 || p.Errors()[0] != expected {
+            t.Errorf("parsing %q: expected error %q, got %v", input, expected, p.Errors())
+        }
+    }
+}
+
+func TestErrorPositions(t *testing.T) {
+    for input, expected := range map[string]Position{
+        "x = = 1":                    {Line: 1, Column: 4, EndLine: 1, EndColumn: 5},
+        "x = 1\nif x\n    pass":      {Line: 2, Column: 4, EndLine: 2, EndColumn: 5},
+        "f(1)\nprint(1) print(2)":    {Line: 2, Column: 9, EndLine: 2, EndColumn: 14},
+        "def f():\n    nonlocal q\n": {Line: 2, Column: 4, EndLine: 2, EndColumn: 14},
+    } {
+        p := New(lexer.New(input))
+        p.ParseProgram()
+        if len(p.ErrorPositions()) == 0 || p.ErrorPositions()[0] != expected {
+            t.Errorf("parsing %q: expected an error at %+v, got %v at %+v", input, expected, p.Errors(), p.ErrorPositions())
+        }
+    }
+}
+
+func TestParseLambdaAndConditional(t *testing.T) {
+    input := `
+def f(a, b=1, /, c=2, *rest, d, e=3, **kw):
+    pass
+g = lambda x, y=2: x if y else lambda: y
+h(*args, key=1, **more)
+`
+    p := New(lexer.New(input))
+    program := p.ParseProgram()
+    checkParserErrors(t, p)
+
+    def := program.Statements[0].(*FunctionDefinition)
+    if len(def.Parameters) != 3 || def.PositionalOnly != 2 || len(def.Defaults) != 2 || def.VarArgs != "rest" ||
+        len(def.KeywordOnly) != 2 || def.KwDefaults[0] != nil || def.KwDefaults[1] == nil || def.KwArgs != "kw" {
+        t.Fatalf("wrong signature: %+v", def.Signature)
+    }
+
+    lambda, ok := program.Statements[1].(*AssignmentStatement).Value.(*LambdaExpression)
+    if !ok || len(lambda.Parameters) != 2 || len(lambda.Defaults) != 1 {
+        t.Fatalf("value is not a two parameter lambda. got=%T", program.Statements[1].(*AssignmentStatement).Value)
+    }
+    conditional, ok := lambda.Body.(*ConditionalExpression)
+    if !ok {
+        t.Fatalf("lambda body is not a conditional expression. got=%T", lambda.Body)
+    }
+    if _, ok := conditional.Alternative.(*LambdaExpression); !ok {
+        t.Fatalf("alternative is not a lambda. got=%T", conditional.Alternative)
+    }
+
+    call := program.Statements[2].(*ExpressionStatement).Expression.(*CallExpression)
+    if _, ok := call.Arguments[0].(*StarredExpression); !ok || len(call.Keywords) != 2 || call.Keywords[1].Name != "" {
+        t.Fatalf("wrong call arguments: %+v %+v", call.Arguments, call.Keywords)
+    }
+
+    for input, expected := range map[string]string{
+        "def f(a=1, b): pass":  "non-default argument follows default argument",
+        "def f(a, a): pass":    "duplicate argument 'a' in function definition",
+        "def f(*): pass":       "named arguments must follow bare *",
+        "def f(/, a): pass":    "at least one argument must precede /",
+        "def f(*a, /): pass":   "/ must be ahead of *",
+        "def f(**k, a): pass":  "arguments cannot follow var-keyword argument",
+        "f(**k, *a)":           "iterable argument unpacking follows keyword argument unpacking",
+        "f(**k, a)":            "positional argument follows keyword argument unpacking",
+        "x = 1 if 2":           "expected 'else' after 'if' expression",
+    } {
+        p := New(lexer.New(input))
+        p.ParseProgram()
+        if len(p.Errors()) == 0 || p.Errors()[0] != expected {
+            t.Errorf("parsing %q: expected error %q, got %v", input, expected, p.Errors())
+        }
+    }
+}
+
+func TestParseDecorators(t *testing.T) {
+    input := `
+@cache
+@route("/", methods=["GET"])
+def index():
+    pass
+
+@handlers[0].register
+class Plugin:
+    pass
+`
+    p := New(lexer.New(input))
+    program := p.ParseProgram()
+    checkParserErrors(t, p)
+
+    def := program.Statements[0].(*FunctionDefinition)
+    if len(def.Decorators) != 2 || def.Line != 4 {
+        t.Fatalf("expected 2 decorators on a def at line 4. got=%d at line %d", len(def.Decorators), def.Line)
+    }
+    if _, ok := def.Decorators[1].(*CallExpression); !ok {
+        t.Errorf("second decorator is not a call. got=%T", def.Decorators[1])
+    }
+    class := program.Statements[1].(*ClassDefinition)
+    if len(class.Decorators) != 1 {
+        t.Fatalf("expected 1 class decorator. got=%d", len(class.Decorators))
+    }
+    if _, ok := class.Decorators[0].(*AttributeExpression); !ok {
+        t.Errorf("class decorator is not an attribute. got=%T", class.Decorators[0])
+    }
+
+    p = New(lexer.New("@decorator\nx = 1"))
+    p.ParseProgram()
+    if len(p.Errors()) == 0 || p.Errors()[0] != "expected def or class after decorator, got IDENT" {
+        t.Errorf("wrong errors for a decorated assignment: %v", p.Errors())
+    }
+}
+
+func TestParseWithStatement(t *testing.T) {
+    input := `
+with open(a) as f, lock:
+    pass
+with (a as x, b as (y, z),):
+    pass
+with (a, b):
+    pass
+with (a) + b as c:
+    pass
+`
+    p := New(lexer.New(input))
+    program := p.ParseProgram()
+    checkParserErrors(t, p)
+
+    tests := []struct {
+        items   int
+        targets []bool
+    }{
+        {2, []bool{true, false}},
+        {2, []bool{true, true}},
+        {2, []bool{false, false}},
+        {1, []bool{true}},
+    }
+    for i, tt := range tests {
+        stmt, ok := program.Statements[i].(*WithStatement)
+        if !ok {
+            t.Fatalf("statement %d is not a WithStatement. got=%T", i, program.Statements[i])
+        }
+        if len(stmt.Items) != tt.items {
+            t.Fatalf("statement %d: expected %d items. got=%d", i, tt.items, len(stmt.Items))
+        }
+        for j, hasTarget := range tt.targets {
+            if (stmt.Items[j].Target != nil) != hasTarget {
+                t.Errorf("statement %d item %d: target presence should be %v", i, j, hasTarget)
+            }
+        }
+    }
+    last := program.Statements[3].(*WithStatement)
+    if _, ok := last.Items[0].Context.(*InfixExpression); !ok {
+        t.Errorf("(a) + b should parse as one expression. got=%T", last.Items[0].Context)
+    }
+}
+
+func TestParseImports(t *testing.T) {
+    input := `
+import os.path as p, sys
+from ..pkg.mod import (a as b,
+    c,)
+from . import d
+from x import *
+`
+    p := New(lexer.New(input))
+    program := p.ParseProgram()
+    checkParserErrors(t, p)
+
+    imp := program.Statements[0].(*ImportStatement)
+    if len(imp.Names) != 2 || imp.Names[0].Name != "os.path" || imp.Names[0].Alias != "p" || imp.Names[1].Alias != "" {
+        t.Errorf("wrong names for import: %+v %+v", imp.Names[0], imp.Names[1])
+    }
+    tests := []struct {
+        module string
+        level  int
+        names  []string
+    }{
+        {"pkg.mod", 2, []string{"a as b", "c"}},
+        {"", 1, []string{"d"}},
+        {"x", 0, nil},
+    }
+    for i, tt := range tests {
+        from := program.Statements[i+1].(*FromImportStatement)
+        if from.Module != tt.module || from.Level != tt.level || len(from.Names) != len(tt.names) {
+            t.Fatalf("statement %d: got module %q level %d with %d names", i+1, from.Module, from.Level, len(from.Names))
+        }
+        for j, name := range from.Names {
+            got := name.Name
+            if name.Alias != "" {
+                got += " as " + name.Alias
+            }
+            if got != tt.names[j] {
+                t.Errorf("statement %d name %d: expected %q, got %q", i+1, j, tt.names[j], got)
+            }
+        }
+    }
+
+    errors := map[string]string{
+        "from x import a,":               "trailing comma not allowed without surrounding parentheses",
+        "def f():\n    from x import *": "import * only allowed at module level",
+    }
+    for input, expected := range errors {
+        p := New(lexer.New(input))
+        p.ParseProgram()
+        if len(p.Errors()) == 0 || p.Errors()[0] != expected {
+            t.Errorf("wrong errors for %q: %v", input, p.Errors())
+        }
+    }
+}
+
+func TestScopeAnalysis(t *testing.T) {
+    input := `
+def outer(a):
+    b = 1
+    def inner():
+        nonlocal b
+        global c
+        b = a + c + d
+    return inner
+`
+    p := New(lexer.New(input))
+    program := p.ParseProgram()
+    checkParserErrors(t, p)
+
+    outer := program.Statements[0].(*FunctionDefinition)
+    inner := outer.Body[1].(*FunctionDefinition)
+    tests := []struct {
+        scope *Scope
+        name  string
+        kind  SymbolKind
+    }{
+        {outer.Scope, "a", Cell},
+        {outer.Scope, "b", Cell},
+        {outer.Scope, "inner", Local},
+        {inner.Scope, "a", Free},
+        {inner.Scope, "b", Free},
+        {inner.Scope, "c", GlobalExplicit},
+        {inner.Scope, "d", GlobalImplicit},
+    }
+    for _, tt := range tests {
+        if kind := tt.scope.Kind(tt.name); kind != tt.kind {
+            t.Errorf("%s should be %s. got=%s", tt.name, tt.kind, kind)
+        }
+    }
+
+    errors := map[string]string{
+        "nonlocal x":                                   "nonlocal declaration not allowed at module level",
+        "def f():\n    nonlocal x":                     "no binding for nonlocal 'x' found",
+        "def f(a):\n    global a":                      "name 'a' is parameter and global",
+        "def f():\n    x = 1\n    global x":           "name 'x' is assigned to before global declaration",
+        "def f():\n    print(x)\n    nonlocal x":      "name 'x' is used prior to nonlocal declaration",
+        "x = 1\ndef f():\n    global x\n    nonlocal x": "name 'x' is nonlocal and global",
+        "del f()":                                      "cannot delete function call",
+    }
+    for input, expected := range errors {
+        p := New(lexer.New(input))
+        p.ParseProgram()
+        if len(p.Errors()) == 0 || p.Errors()[0] != expected {
+            t.Errorf("wrong errors for %q: %v", input, p.Errors())
+        }
+    }
+}
+
+func TestParseMatchStatement(t *testing.T) {
+    input := `
+match = [1]
+match[0]
+match command:
+    case "go", direction if direction:
+        pass
+    case Point(x=0, y=rest) | [1, *rest] as p:
+        pass
+    case {"k": -1, **others}:
+        pass
+    case _:
+        pass
+`
+    p := New(lexer.New(input))
+    program := p.ParseProgram()
+    checkParserErrors(t, p)
+
+    if _, ok := program.Statements[1].(*ExpressionStatement); !ok {
+        t.Fatalf("match[0] should stay an expression. got=%T", program.Statements[1])
+    }
+    stmt, ok := program.Statements[2].(*MatchStatement)
+    if !ok || len(stmt.Cases) != 4 {
+        t.Fatalf("expected a MatchStatement with 4 cases. got=%T", program.Statements[2])
+    }
+    if seq, ok := stmt.Cases[0].Pattern.(*MatchSequence); !ok || len(seq.Patterns) != 2 || stmt.Cases[0].Guard == nil {
+        t.Errorf("first case should be a guarded sequence of two. got=%T", stmt.Cases[0].Pattern)
+    }
+    as, ok := stmt.Cases[1].Pattern.(*MatchAs)
+    if !ok || as.Name != "p" {
+        t.Fatalf("second case should be bound to p. got=%T", stmt.Cases[1].Pattern)
+    }
+    or, ok := as.Pattern.(*MatchOr)
+    if !ok || len(or.Patterns) != 2 {
+        t.Fatalf("second case should have two alternatives. got=%T", as.Pattern)
+    }
+    if class, ok := or.Patterns[0].(*MatchClass); !ok || len(class.KwdAttrs) != 2 {
+        t.Errorf("expected a class pattern with two keywords. got=%T", or.Patterns[0])
+    }
+    if mapping, ok := stmt.Cases[2].Pattern.(*MatchMapping); !ok || mapping.Rest != "others" {
+        t.Errorf("expected a mapping pattern with **others. got=%T", stmt.Cases[2].Pattern)
+    }
+    if wildcard, ok := stmt.Cases[3].Pattern.(*MatchAs); !ok || wildcard.Name != "" || wildcard.Pattern != nil {
+        t.Errorf("expected the wildcard. got=%T", stmt.Cases[3].Pattern)
+    }
+
+    errors := map[string]string{
+        "match x:\n    case a:\n        pass\n    case 1:\n        pass": "name capture 'a' makes remaining patterns unreachable",
+        "match x:\n    case _ | 1:\n        pass":                        "wildcard makes remaining patterns unreachable",
+        "match x:\n    case [a] | [b]:\n        pass":                    "alternative patterns bind different names",
+        "match x:\n    case (a, a):\n        pass":                       "multiple assignments to name 'a' in pattern",
+        "match x:\n    case [*a, *b]:\n        pass":                    "multiple starred names in sequence pattern",
+        "match x:\n    case {'a': 1, 'a': 2}:\n        pass":             "mapping pattern checks duplicate key ('a')",
+        "match x:\n    case C(x=1, 2):\n        pass":                    "positional patterns follow keyword patterns",
+        "match x:\n    case a as _:\n        pass":                       "cannot use '_' as a target",
+    }
+    for input, expected := range errors {
+        p := New(lexer.New(input))
+        p.ParseProgram()
+        if len(p.Errors()) == 0 || p.Errors()[0] != expected {
+            t.Errorf("wrong errors for %q: %v", input, p.Errors())
+        }
+    }
+}
+
+func TestParseWalrusAssertAndAugmented(t *testing.T) {
+    input := `
+if (n := len(a)) > 1:
+    pass
+x.count += 1
+assert n, "message"
+`
+    p := New(lexer.New(input))
+    program := p.ParseProgram()
+    checkParserErrors(t, p)
+
+    condition := program.Statements[0].(*IfStatement).Condition.(*ComparisonExpression)
+    if named, ok := condition.Left.(*NamedExpression); !ok || named.Target.Value != "n" {
+        t.Errorf("expected n := len(a) on the left. got=%T", condition.Left)
+    }
+    augmented, ok := program.Statements[1].(*AugmentedAssignStatement)
+    if !ok || augmented.Operator != "+" {
+        t.Fatalf("expected an augmented assignment with +. got=%T", program.Statements[1])
+    }
+    if _, ok := augmented.Target.(*AttributeExpression); !ok {
+        t.Errorf("expected an attribute target. got=%T", augmented.Target)
+    }
+    if assert, ok := program.Statements[2].(*AssertStatement); !ok || assert.Message == nil {
+        t.Errorf("expected an assert with a message. got=%T", program.Statements[2])
+    }
+
+    errors := map[string]string{
+        "x := 1":                                 "invalid syntax: unexpected :=",
+        "(a.b := 1)":                             "cannot use assignment expressions with attribute",
+        "a, b += 1":                              "'tuple' is an illegal expression for augmented assignment",
+        "f() -= 1":                               "'function call' is an illegal expression for augmented assignment",
+        "list((x := 1) for x in y)":              "assignment expression cannot rebind comprehension iteration variable 'x'",
+        "list(x for x in (y := z))":              "assignment expression cannot be used in a comprehension iterable expression",
+        "class A:\n    list((y := x) for x in z)": "assignment expression within a comprehension cannot be used in a class body",
+    }
+    for input, expected := range errors {
+        p := New(lexer.New(input))
+        p.ParseProgram()
+        if len(p.Errors()) == 0 || p.Errors()[0] != expected {
+            t.Errorf("wrong errors for %q: %v", input, p.Errors())
+        }
+    }
+}
+
+func TestParseBytesLiterals(t *testing.T) {
+    p := New(lexer.New(`x = b'a\x00' B"\xff" rb'\n' b'\u00e9'`))
+    program := p.ParseProgram()
+    checkParserErrors(t, p)
+
+    literal, ok := program.Statements[0].(*AssignmentStatement).Value.(*BytesLiteral)
+    if !ok {
+        t.Fatalf("expected a bytes literal. got=%T", program.Statements[0].(*AssignmentStatement).Value)
+    }
+    if expected := "a\x00\xff\\n\\u00e9"; literal.Value != expected {
+        t.Errorf("wrong bytes. expected=%q, got=%q", expected, literal.Value)
+    }
+
+    errors := map[string]string{
+        `b'a' 'b'`: "cannot mix bytes and nonbytes literals",
+        `b'é'`:     "bytes can only contain ASCII literal characters",
+    }
+    for input, expected := range errors {
+        p := New(lexer.New(input))
+        p.ParseProgram()
+        if len(p.Errors()) == 0 || p.Errors()[0] != expected {
+            t.Errorf("wrong errors for %q: %v", input, p.Errors())
+        }
+    }
+}
+
+func TestParseImaginaryLiterals(t *testing.T) {
+    p := New(lexer.New("x = 1_0.5J"))
+    program := p.ParseProgram()
+    checkParserErrors(t, p)
+
+    literal, ok := program.Statements[0].(*AssignmentStatement).Value.(*ImaginaryLiteral)
+    if !ok {
+        t.Fatalf("expected an imaginary literal. got=%T", program.Statements[0].(*AssignmentStatement).Value)
+    }
+    if literal.Value != "1_0.5J" {
+        t.Errorf("wrong literal. expected=%q, got=%q", "1_0.5J", literal.Value)
+    }
+
+    errors := map[string]string{
+        "match x:\n    case 1j+1:\n        pass": "real number required in complex literal",
+        "match x:\n    case 1+1:\n        pass":  "imaginary number required in complex literal",
+    }
+    for input, expected := range errors {
+        p := New(lexer.New(input))
+        p.ParseProgram()
+        if len(p.Errors()) == 0 || p.Errors()[0] != expected {
+            t.Errorf("wrong errors for %q: %v", input, p.Errors())
+        }
+    }
+}
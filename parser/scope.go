@@ -0,0 +1,559 @@
+package parser
+
+import (
+    "fmt"
+)
+
+// Scope analysis runs once the whole program is parsed, like CPython's
+// symtable: every def, lambda, class body and comprehension learns which of
+// its names are its own, which belong to an enclosing def and which are global.
+
+// SymbolKind says where a name used in a scope lives
+type SymbolKind int
+
+const (
+    GlobalImplicit SymbolKind = iota // not bound by any enclosing def: globals, then builtins
+    Local                            // bound in this scope
+    Cell                             // bound in this scope and used by a nested one
+    Free                             // bound in an enclosing def, or declared nonlocal
+    GlobalExplicit                   // declared global
+)
+
+func (k SymbolKind) String() string {
+    return [...]string{"global implicit", "local", "cell", "free", "global explicit"}[k]
+}
+
+// Scope is the symbol table of one def, lambda, class body or comprehension
+type Scope struct {
+    Symbols map[string]SymbolKind
+}
+
+// Kind is how name resolves here. Names the scope never mentions are
+// implicitly global, and so is everything at module level, where Scope is nil.
+func (s *Scope) Kind(name string) SymbolKind {
+    if s == nil {
+        return GlobalImplicit
+    }
+    return s.Symbols[name]
+}
+
+type blockType int
+
+const (
+    moduleBlock blockType = iota
+    functionBlock
+    classBlock
+)
+
+// symbolTable collects what one block does with its names, in source order
+type symbolTable struct {
+    block     blockType
+    scope     *Scope
+    parent    *symbolTable
+    params    map[string]bool
+    bound     map[string]bool
+    used      map[string]bool
+    globals   map[string]bool
+    nonlocals map[string]bool
+    children  []*symbolTable
+
+    declaredAt map[string]Position // the nonlocal statements, for errors
+
+    comprehension bool            // a generator expression's own scope
+    iterationVars map[string]bool // what its for clauses bind
+}
+
+func newSymbolTable(block blockType, scope *Scope) *symbolTable {
+    return &symbolTable{
+        block:         block,
+        scope:         scope,
+        params:        map[string]bool{},
+        bound:         map[string]bool{},
+        used:          map[string]bool{},
+        globals:       map[string]bool{},
+        nonlocals:     map[string]bool{},
+        declaredAt:    map[string]Position{},
+        iterationVars: map[string]bool{},
+    }
+}
+
+// scopeAnalyzer walks the tree building symbol tables, then resolves them
+type scopeAnalyzer struct {
+    p        *Parser
+    table    *symbolTable
+    iterable int      // inside a comprehension's iterable, where := is not allowed
+    at       Position // the statement being walked, which errors point at
+}
+
+// analyzeScopes fills in the Scope of every block in the program
+func (p *Parser) analyzeScopes(program *Program) {
+    a := &scopeAnalyzer{p: p, table: newSymbolTable(moduleBlock, nil)}
+    a.statements(program.Statements)
+    a.resolve(a.table, map[string]bool{})
+}
+
+func (a *scopeAnalyzer) errorf(format string, args ...interface{}) {
+    a.p.addErrorSpan(a.at, fmt.Sprintf(format, args...))
+}
+
+// nested runs visit inside a new block of the given type
+func (a *scopeAnalyzer) nested(block blockType, params []string, visit func()) *Scope {
+    scope := &Scope{Symbols: map[string]SymbolKind{}}
+    table := newSymbolTable(block, scope)
+    table.parent = a.table
+    for _, name := range params {
+        table.params[name] = true
+        table.bound[name] = true
+    }
+    a.table.children = append(a.table.children, table)
+    outer, iterable := a.table, a.iterable
+    a.table, a.iterable = table, 0
+    visit()
+    a.table, a.iterable = outer, iterable
+    return scope
+}
+
+func (a *scopeAnalyzer) bind(name string) {
+    a.table.bound[name] = true
+}
+
+func (a *scopeAnalyzer) use(name string) {
+    a.table.used[name] = true
+}
+
+// namedTarget binds the target of :=. In a comprehension the name belongs
+// to the scope the comprehension stands in, as PEP 572 says, and every
+// comprehension on the way there passes it through.
+func (a *scopeAnalyzer) namedTarget(name string) {
+    if a.iterable > 0 {
+        a.errorf("assignment expression cannot be used in a comprehension iterable expression")
+        return
+    }
+    t := a.table
+    comprehensions := []*symbolTable{}
+    for ; t.comprehension; t = t.parent {
+        if t.iterationVars[name] {
+            a.errorf("assignment expression cannot rebind comprehension iteration variable '%s'", name)
+            return
+        }
+        comprehensions = append(comprehensions, t)
+    }
+    if t.block == classBlock && len(comprehensions) > 0 {
+        a.errorf("assignment expression within a comprehension cannot be used in a class body")
+        return
+    }
+
+    global := t.block == moduleBlock || t.globals[name]
+    if !t.nonlocals[name] {
+        t.bound[name] = true
+    }
+    for _, c := range comprehensions {
+        if global {
+            c.globals[name] = true
+        } else {
+            c.nonlocals[name] = true
+        }
+    }
+}
+
+// declare records a global or nonlocal statement, which must come before
+// any other mention of the name in its block
+func (a *scopeAnalyzer) declare(names []string, nonlocal bool) {
+    t := a.table
+    what := "global"
+    if nonlocal {
+        what = "nonlocal"
+        if t.block == moduleBlock {
+            a.errorf("nonlocal declaration not allowed at module level")
+            return
+        }
+    }
+    for _, name := range names {
+        switch {
+        case t.params[name]:
+            a.errorf("name '%s' is parameter and %s", name, what)
+            continue
+        case t.used[name]:
+            a.errorf("name '%s' is used prior to %s declaration", name, what)
+            continue
+        case t.bound[name]:
+            a.errorf("name '%s' is assigned to before %s declaration", name, what)
+            continue
+        case nonlocal && t.globals[name], !nonlocal && t.nonlocals[name]:
+            a.errorf("name '%s' is nonlocal and global", name)
+            continue
+        }
+        if nonlocal {
+            t.nonlocals[name] = true
+            t.declaredAt[name] = a.at
+        } else {
+            t.globals[name] = true
+        }
+    }
+}
+
+// resolve decides the kind of every name in t, given the names bound by
+// the defs around it, and marks the ones nested blocks use as cells
+func (a *scopeAnalyzer) resolve(t *symbolTable, enclosing map[string]bool) {
+    if t.block != moduleBlock {
+        for name := range t.nonlocals {
+            if !enclosing[name] {
+                a.p.addErrorSpan(t.declaredAt[name], fmt.Sprintf("no binding for nonlocal '%s' found", name))
+            }
+        }
+        kinds := t.scope.Symbols
+        for _, names := range []map[string]bool{t.used, t.bound} {
+            for name := range names {
+                switch {
+                case enclosing[name] && !t.bound[name]:
+                    kinds[name] = Free
+                case t.bound[name]:
+                    kinds[name] = Local
+                default:
+                    kinds[name] = GlobalImplicit
+                }
+            }
+        }
+        for name := range t.nonlocals {
+            kinds[name] = Free
+        }
+        for name := range t.globals {
+            kinds[name] = GlobalExplicit
+        }
+    }
+
+    // What the blocks inside can see: a def adds its own names, a class doesn't
+    inner := map[string]bool{}
+    for name := range enclosing {
+        if !t.globals[name] {
+            inner[name] = true
+        }
+    }
+    if t.block == functionBlock {
+        for name, kind := range t.scope.Symbols {
+            if kind == Local || kind == Free {
+                inner[name] = true
+            }
+        }
+    }
+    for _, child := range t.children {
+        a.resolve(child, inner)
+        if t.block == moduleBlock {
+            continue
+        }
+        for name, kind := range child.scope.Symbols {
+            if kind != Free {
+                continue
+            }
+            switch t.scope.Symbols[name] {
+            case Local:
+                if t.block == functionBlock {
+                    t.scope.Symbols[name] = Cell
+                }
+            case GlobalImplicit:
+                if _, mentioned := t.scope.Symbols[name]; !mentioned && enclosing[name] {
+                    t.scope.Symbols[name] = Free // passing through on its way down
+                }
+            }
+        }
+    }
+}
+
+func (a *scopeAnalyzer) statements(statements []Statement) {
+    for _, stmt := range statements {
+        a.statement(stmt)
+    }
+}
+
+func (a *scopeAnalyzer) statement(stmt Statement) {
+    if located, ok := stmt.(Located); ok && located.Span().Line != 0 {
+        defer func(at Position) { a.at = at }(a.at)
+        a.at = *located.Span()
+    }
+    switch stmt := stmt.(type) {
+    case *ExpressionStatement:
+        a.expression(stmt.Expression)
+    case *AssignmentStatement:
+        a.expression(stmt.Value)
+        for _, target := range stmt.Targets {
+            a.target(target)
+        }
+    case *AugmentedAssignStatement:
+        a.expression(stmt.Value)
+        a.target(stmt.Target)
+        if name, ok := stmt.Target.(*Identifier); ok {
+            a.use(name.Value)
+        }
+    case *AssertStatement:
+        a.expression(stmt.Test)
+        a.expression(stmt.Message)
+    case *ReturnStatement:
+        a.expression(stmt.Value)
+    case *RaiseStatement:
+        a.expression(stmt.Exception)
+        a.expression(stmt.Cause)
+    case *IfStatement:
+        a.expression(stmt.Condition)
+        a.statements(stmt.Consequence)
+        a.statements(stmt.Alternative)
+    case *WhileStatement:
+        a.expression(stmt.Condition)
+        a.statements(stmt.Body)
+        a.statements(stmt.Else)
+    case *ForStatement:
+        a.expression(stmt.Iterable)
+        a.target(stmt.Target)
+        a.statements(stmt.Body)
+        a.statements(stmt.Else)
+    case *WithStatement:
+        for _, item := range stmt.Items {
+            a.expression(item.Context)
+            if item.Target != nil {
+                a.target(item.Target)
+            }
+        }
+        a.statements(stmt.Body)
+    case *MatchStatement:
+        a.expression(stmt.Subject)
+        for _, matchCase := range stmt.Cases {
+            a.pattern(matchCase.Pattern)
+            a.expression(matchCase.Guard)
+            a.statements(matchCase.Body)
+        }
+    case *TryStatement:
+        a.statements(stmt.Body)
+        for _, handler := range stmt.Handlers {
+            a.expression(handler.Type)
+            if handler.Name != "" {
+                a.bind(handler.Name)
+            }
+            a.statements(handler.Body)
+        }
+        a.statements(stmt.Else)
+        a.statements(stmt.Finally)
+    case *FunctionDefinition:
+        a.expressions(stmt.Decorators)
+        a.signature(&stmt.Signature)
+        stmt.Scope = a.nested(functionBlock, stmt.names(), func() { a.statements(stmt.Body) })
+        a.bind(stmt.Name)
+    case *ClassDefinition:
+        a.expressions(stmt.Decorators)
+        a.expressions(stmt.Bases)
+        for _, keyword := range stmt.Keywords {
+            a.expression(keyword.Value)
+        }
+        stmt.Scope = a.nested(classBlock, nil, func() { a.statements(stmt.Body) })
+        a.bind(stmt.Name)
+    case *ImportStatement:
+        for _, name := range stmt.Names {
+            if name.Alias != "" {
+                a.bind(name.Alias)
+            } else {
+                a.bind(topName(name.Name))
+            }
+        }
+    case *FromImportStatement:
+        for _, name := range stmt.Names {
+            if name.Alias != "" {
+                a.bind(name.Alias)
+            } else {
+                a.bind(name.Name)
+            }
+        }
+    case *GlobalStatement:
+        a.declare(stmt.Names, false)
+    case *NonlocalStatement:
+        a.declare(stmt.Names, true)
+    case *DeleteStatement:
+        for _, target := range stmt.Targets {
+            a.target(target)
+        }
+    }
+}
+
+// target visits an assignment, for, with or del target: names in it are bound
+func (a *scopeAnalyzer) target(target Expression) {
+    switch target := target.(type) {
+    case *Identifier:
+        a.bind(target.Value)
+    case *TupleLiteral:
+        for _, element := range target.Elements {
+            a.target(element)
+        }
+    case *ListLiteral:
+        for _, element := range target.Elements {
+            a.target(element)
+        }
+    case *StarredExpression:
+        a.target(target.Value)
+    default:
+        a.expression(target) // a.b and a[b] only use a and b
+    }
+}
+
+// pattern visits a case pattern: its captures are bound, the values and
+// classes it mentions are used
+func (a *scopeAnalyzer) pattern(pattern Pattern) {
+    switch pattern := pattern.(type) {
+    case *MatchValue:
+        a.expression(pattern.Value)
+    case *MatchSequence:
+        a.patterns(pattern.Patterns)
+    case *MatchStar:
+        if pattern.Name != "" {
+            a.bind(pattern.Name)
+        }
+    case *MatchMapping:
+        a.expressions(pattern.Keys)
+        a.patterns(pattern.Patterns)
+        if pattern.Rest != "" {
+            a.bind(pattern.Rest)
+        }
+    case *MatchClass:
+        a.expression(pattern.Class)
+        a.patterns(pattern.Patterns)
+        a.patterns(pattern.KwdPatterns)
+    case *MatchAs:
+        a.pattern(pattern.Pattern)
+        if pattern.Name != "" {
+            a.bind(pattern.Name)
+        }
+    case *MatchOr:
+        a.patterns(pattern.Patterns)
+    }
+}
+
+func (a *scopeAnalyzer) patterns(patterns []Pattern) {
+    for _, pattern := range patterns {
+        a.pattern(pattern)
+    }
+}
+
+// signature visits the defaults, which run in the scope around the def
+func (a *scopeAnalyzer) signature(sig *Signature) {
+    a.expressions(sig.Defaults)
+    a.expressions(sig.KwDefaults)
+}
+
+func (a *scopeAnalyzer) expressions(exprs []Expression) {
+    for _, expr := range exprs {
+        a.expression(expr)
+    }
+}
+
+func (a *scopeAnalyzer) expression(expr Expression) {
+    switch expr := expr.(type) {
+    case *Identifier:
+        a.use(expr.Value)
+    case *PrefixExpression:
+        a.expression(expr.Right)
+    case *InfixExpression:
+        a.expression(expr.Left)
+        a.expression(expr.Right)
+    case *ComparisonExpression:
+        a.expression(expr.Left)
+        a.expressions(expr.Comparators)
+    case *CallExpression:
+        a.expression(expr.Function)
+        a.expressions(expr.Arguments)
+        for _, keyword := range expr.Keywords {
+            a.expression(keyword.Value)
+        }
+    case *StarredExpression:
+        a.expression(expr.Value)
+    case *ConditionalExpression:
+        a.expression(expr.Condition)
+        a.expression(expr.Consequence)
+        a.expression(expr.Alternative)
+    case *LambdaExpression:
+        a.signature(&expr.Signature)
+        expr.Scope = a.nested(functionBlock, expr.names(), func() { a.expression(expr.Body) })
+    case *AttributeExpression:
+        a.expression(expr.Object)
+    case *IndexExpression:
+        a.expression(expr.Left)
+        a.expression(expr.Index)
+    case *SliceExpression:
+        a.expression(expr.Lower)
+        a.expression(expr.Upper)
+        a.expression(expr.Step)
+    case *YieldExpression:
+        a.expression(expr.Value)
+    case *GeneratorExpression:
+        // The first iterable is evaluated where the expression stands
+        a.iterable++
+        a.expression(expr.Clauses[0].Iterable)
+        a.iterable--
+        expr.Scope = a.nested(functionBlock, nil, func() {
+            a.table.comprehension = true
+            for i, clause := range expr.Clauses {
+                if i > 0 {
+                    a.iterable++
+                    a.expression(clause.Iterable)
+                    a.iterable--
+                }
+                for _, name := range targetNames(clause.Target) {
+                    a.table.iterationVars[name] = true
+                }
+                a.target(clause.Target)
+                a.expressions(clause.Conditions)
+            }
+            a.expression(expr.Element)
+        })
+    case *NamedExpression:
+        a.expression(expr.Value)
+        a.namedTarget(expr.Target.Value)
+    case *ListLiteral:
+        a.expressions(expr.Elements)
+    case *TupleLiteral:
+        a.expressions(expr.Elements)
+    case *SetLiteral:
+        a.expressions(expr.Elements)
+    case *DictLiteral:
+        a.expressions(expr.Keys)
+        a.expressions(expr.Values)
+    }
+}
+
+// names lists every parameter the signature binds
+func (sig *Signature) names() []string {
+    names := append(append([]string{}, sig.Parameters...), sig.KeywordOnly...)
+    for _, name := range []string{sig.VarArgs, sig.KwArgs} {
+        if name != "" {
+            names = append(names, name)
+        }
+    }
+    return names
+}
+
+// targetNames lists the names an assignment target binds
+func targetNames(target Expression) []string {
+    switch target := target.(type) {
+    case *Identifier:
+        return []string{target.Value}
+    case *StarredExpression:
+        return targetNames(target.Value)
+    case *TupleLiteral:
+        return elementNames(target.Elements)
+    case *ListLiteral:
+        return elementNames(target.Elements)
+    }
+    return nil
+}
+
+func elementNames(elements []Expression) []string {
+    names := []string{}
+    for _, element := range elements {
+        names = append(names, targetNames(element)...)
+    }
+    return names
+}
+
+func topName(dotted string) string {
+    for i, c := range dotted {
+        if c == '.' {
+            return dotted[:i]
+        }
+    }
+    return dotted
+}
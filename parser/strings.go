@@ -0,0 +1,129 @@
+package parser
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+    "unicode/utf8"
+)
+
+// unquote turns the raw text of a string token (prefix and quotes included)
+// into the value it denotes. For bytes literals the value holds the raw
+// bytes, one per byte of the Go string.
+func unquote(literal string) (value string, isBytes bool, err error) {
+    quoteAt := strings.IndexAny(literal, `"'`)
+    if quoteAt < 0 {
+        return "", false, fmt.Errorf("invalid string literal: %s", literal)
+    }
+    prefix := strings.ToLower(literal[:quoteAt])
+    body := literal[quoteAt:]
+
+    quoteLen := 1
+    if len(body) >= 6 && (strings.HasPrefix(body, `"""`) || strings.HasPrefix(body, `'''`)) {
+        quoteLen = 3
+    }
+    body = body[quoteLen : len(body)-quoteLen]
+
+    switch {
+    case strings.ContainsAny(prefix, "b"):
+        for i := 0; i < len(body); i++ {
+            if body[i] >= 0x80 {
+                return "", true, fmt.Errorf("bytes can only contain ASCII literal characters")
+            }
+        }
+        if strings.ContainsAny(prefix, "r") {
+            return body, true, nil
+        }
+        value, err = unescape(body, true)
+        return value, true, err
+    case strings.ContainsAny(prefix, "f"):
+        return "", false, fmt.Errorf("f-strings are not supported")
+    case strings.ContainsAny(prefix, "r"):
+        return body, false, nil
+    }
+    value, err = unescape(body, false)
+    return value, false, err
+}
+
+// unescape interprets Python backslash escapes. In bytes \x and octal
+// escapes are raw bytes, and \u, \U mean nothing at all.
+func unescape(s string, isBytes bool) (string, error) {
+    if !strings.Contains(s, `\`) {
+        return s, nil
+    }
+
+    var out strings.Builder
+    for i := 0; i < len(s); i++ {
+        if s[i] != '\\' || i+1 == len(s) {
+            out.WriteByte(s[i])
+            continue
+        }
+        i++
+        switch c := s[i]; c {
+        case '\n':
+            // backslash-newline is a line continuation inside the literal
+        case '\\', '\'', '"':
+            out.WriteByte(c)
+        case 'a':
+            out.WriteByte('\a')
+        case 'b':
+            out.WriteByte('\b')
+        case 'f':
+            out.WriteByte('\f')
+        case 'n':
+            out.WriteByte('\n')
+        case 'r':
+            out.WriteByte('\r')
+        case 't':
+            out.WriteByte('\t')
+        case 'v':
+            out.WriteByte('\v')
+        case '0', '1', '2', '3', '4', '5', '6', '7':
+            j := i
+            for j < len(s) && j < i+3 && s[j] >= '0' && s[j] <= '7' {
+                j++
+            }
+            code, _ := strconv.ParseUint(s[i:j], 8, 32)
+            if isBytes {
+                out.WriteByte(byte(code))
+            } else {
+                out.WriteRune(rune(code))
+            }
+            i = j - 1
+        case 'u', 'U':
+            if isBytes {
+                out.WriteByte('\\')
+                out.WriteByte(c)
+                continue
+            }
+            fallthrough
+        case 'x':
+            width := map[byte]int{'x': 2, 'u': 4, 'U': 8}[c]
+            if i+1+width > len(s) {
+                return "", fmt.Errorf("truncated \\%c escape", c)
+            }
+            code, err := strconv.ParseUint(s[i+1:i+1+width], 16, 32)
+            if err != nil {
+                return "", fmt.Errorf("truncated \\%c escape", c)
+            }
+            if code > utf8.MaxRune {
+                return "", fmt.Errorf("illegal Unicode character")
+            }
+            switch {
+            case isBytes:
+                out.WriteByte(byte(code))
+            case code >= 0xd800 && code <= 0xdfff:
+                // lone surrogates keep the bytes UTF-8 would have given them,
+                // which is how the evaluator stores them too
+                out.WriteString(string([]byte{0xed, byte(0x80 | code>>6&0x3f), byte(0x80 | code&0x3f)}))
+            default:
+                out.WriteRune(rune(code))
+            }
+            i += width
+        default:
+            out.WriteByte('\\')
+            out.WriteByte(c)
+        }
+    }
+    return out.String(), nil
+}
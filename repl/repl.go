@@ -7,41 +7,46 @@ import (
     "interpreter/lexer"
     "interpreter/parser"
     "io"
+    "strings"
 )
 
 const PROMPT = ">> "
 
-func Start(in io.Reader, out io.Writer) {
-    scanner := bufio.NewScanner(in)
-    env := evaluator.NewEnvironment()
+// Start reads and runs lines in env until the input runs out or the program
+// raises SystemExit, returning the status the process should exit with
+func Start(in io.Reader, out io.Writer, env *evaluator.Environment) int {
+    reader := bufio.NewReader(in)
+    // the program's print() and input() share our terminal
+    env.SetStdout(out)
+    env.SetStderr(out)
+    env.SetStdin(reader)
 
     for {
         fmt.Fprintf(out, PROMPT)
-        scanned := scanner.Scan()
-        if !scanned {
-            return
+        line, err := reader.ReadString('\n')
+        if line == "" && err != nil {
+            return 0
         }
-
-        line := scanner.Text()
+        line = strings.TrimRight(line, "\r\n")
         l := lexer.New(line)
         p := parser.New(l)
 
         program := p.ParseProgram()
         if len(p.Errors()) != 0 {
-            printParserErrors(out, p.Errors())
+            fmt.Fprint(out, evaluator.FormatTraceback(evaluator.ParseError(p, "<stdin>", line), env))
             continue
         }
 
         evaluated := evaluator.Eval(program, env)
-        if evaluated != nil {
-            fmt.Fprintf(out, "%s\n", evaluated.Inspect())
+        if status, ok := evaluator.ExitStatus(evaluated, env); ok {
+            return status
+        }
+        if traceback := evaluator.FormatTraceback(evaluated, env); traceback != "" {
+            fmt.Fprint(out, traceback)
+            continue
+        }
+        if evaluated != nil && evaluated != evaluator.NULL {
+            fmt.Fprintf(out, "%s\n", evaluator.Repr(evaluated, env))
         }
     }
 }
-
-func printParserErrors(out io.Writer, errors []string) {
-    fmt.Fprintf(out, "Parser errors:\n")
-    for _, msg := range errors {
-        fmt.Fprintf(out, "\t%s\n", msg)
-    }
-}
\ No newline at end of file
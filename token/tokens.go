@@ -5,6 +5,13 @@ type TokenType string // Allow to use many different values as token types
 type Token struct {
     Type    TokenType
     Literal string
+    // LineStart is set on the first token of every logical line so the
+    // parser can tell where one statement ends and the next begins.
+    LineStart bool
+    // Where the token sits: lines are 1-based, columns are 0-based byte
+    // offsets, and the end is exclusive.
+    Line, Column       int
+    EndLine, EndColumn int
 }
 
 const (
@@ -16,20 +23,48 @@ const (
     IDENT = "IDENT" 
     INT   = "INT"   
     FLOAT = "FLOAT"
+    IMAG  = "IMAG"
     STRING = "STRING" 
 
-    ASSIGN   = "="
-    PLUS     = "+"
-    MINUS    = "-"
-    ASTERISK = "*"
-    SLASH    = "/"
-    EQ       = "=="
-    NOT_EQ   = "!="
-    LT       = "<"
-    GT       = ">"
-    LTE      = "<="
-    GTE      = ">="
+    ASSIGN       = "="
+    PLUS         = "+"
+    MINUS        = "-"
+    ASTERISK     = "*"
+    SLASH        = "/"
+    POWER        = "**"
+    DOUBLE_SLASH = "//"
+    PERCENT      = "%"
+    AT           = "@"
+    AMPERSAND    = "&"
+    PIPE         = "|"
+    CARET        = "^"
+    TILDE        = "~"
+    LSHIFT       = "<<"
+    RSHIFT       = ">>"
+    EQ           = "=="
+    NOT_EQ       = "!="
+    LT           = "<"
+    GT           = ">"
+    LTE          = "<="
+    GTE          = ">="
 
+    // Augmented assignment, x op= y
+    PLUS_ASSIGN         = "+="
+    MINUS_ASSIGN        = "-="
+    ASTERISK_ASSIGN     = "*="
+    SLASH_ASSIGN        = "/="
+    DOUBLE_SLASH_ASSIGN = "//="
+    PERCENT_ASSIGN      = "%="
+    POWER_ASSIGN        = "**="
+    AT_ASSIGN           = "@="
+    AMPERSAND_ASSIGN    = "&="
+    PIPE_ASSIGN         = "|="
+    CARET_ASSIGN        = "^="
+    LSHIFT_ASSIGN       = "<<="
+    RSHIFT_ASSIGN       = ">>="
+    WALRUS              = ":="
+
+    DOT       = "."
     COMMA     = ","
     COLON     = ":"
     SEMICOLON = ";"
@@ -59,11 +94,22 @@ const (
     TRY      = "TRY"
     EXCEPT   = "EXCEPT"
     FINALLY  = "FINALLY"
+    RAISE    = "RAISE"
+    YIELD    = "YIELD"
     WITH     = "WITH"
     LAMBDA   = "LAMBDA"
     PASS     = "PASS"
     BREAK    = "BREAK"
     CONTINUE = "CONTINUE"
+    GLOBAL   = "GLOBAL"
+    NONLOCAL = "NONLOCAL"
+    DEL      = "DEL"
+    ASSERT   = "ASSERT"
+    AND      = "AND"
+    OR       = "OR"
+    NOT      = "NOT"
+    IN       = "IN"
+    IS       = "IS"
     TRUE     = "TRUE"
     FALSE    = "FALSE"
     NONE     = "NONE"
@@ -84,11 +130,22 @@ var keywords = map[string]TokenType{
     "try":      TRY,
     "except":   EXCEPT,
     "finally":  FINALLY,
+    "raise":    RAISE,
+    "yield":    YIELD,
     "with":     WITH,
     "lambda":   LAMBDA,
     "pass":     PASS,
     "break":    BREAK,
     "continue": CONTINUE,
+    "global":   GLOBAL,
+    "nonlocal": NONLOCAL,
+    "del":      DEL,
+    "assert":   ASSERT,
+    "and":      AND,
+    "or":       OR,
+    "not":      NOT,
+    "in":       IN,
+    "is":       IS,
     "True":     TRUE,
     "False":    FALSE,
     "None":     NONE,